@@ -0,0 +1,277 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// Config is the configuration of the peer, loaded from a JSON file.
+type Config struct {
+	Mode         string        `json:"mode"`         // "server" or "client"
+	Addr         string        `json:"addr"`         // Address to listen on or connect to
+	Peers        []string      `json:"peers"`        // Addresses checked in preflight mode, addr if unset
+	Dictionaries []string      `json:"dictionaries"` // XML dictionaries loaded on top of the default one
+	OriginHost   string        `json:"origin_host"`
+	OriginRealm  string        `json:"origin_realm"`
+	VendorID     uint32        `json:"vendor_id"`
+	ProductName  string        `json:"product_name"`
+	Applications []Application `json:"applications"`
+	Answers      []Answer      `json:"answers"`  // Canned answers, in server and client mode
+	Requests     []Request     `json:"requests"` // Requests sent in client mode
+	Timeout      Duration      `json:"timeout"`  // Time to wait for answers in client mode
+}
+
+// Application is an application advertised in CER/CEA.
+type Application struct {
+	Auth   uint32 `json:"auth,omitempty"`   // Auth-Application-Id
+	Acct   uint32 `json:"acct,omitempty"`   // Acct-Application-Id
+	Vendor uint32 `json:"vendor,omitempty"` // Vendor-Id, for vendor specific applications
+}
+
+// Answer is a canned answer for requests of a command, e.g. CCR.
+type Answer struct {
+	Command    string   `json:"command"`     // Short name of the request, e.g. "CCR"
+	ResultCode uint32   `json:"result_code"` // Result-Code, 2001 if unset
+	Echo       []string `json:"echo"`        // AVPs copied from the request, Session-Id if unset
+	AVP        []AVP    `json:"avps"`        // AVPs added to the answer
+}
+
+// Request is a request sent by the peer in client mode.
+type Request struct {
+	Command     string   `json:"command"`     // Short name of the request, e.g. "CCR"
+	Application uint32   `json:"application"` // Application-Id of the header
+	Count       int      `json:"count"`       // Number of requests, 1 if unset
+	Interval    Duration `json:"interval"`    // Interval between requests
+	AVP         []AVP    `json:"avps"`        // AVPs of the request
+}
+
+// AVP is an AVP defined by name, with a value converted according to the
+// data type in the dictionary. Grouped AVPs take a list of AVPs as value,
+// Enumerated AVPs take either a number or the name of an item, and
+// Address, IPv4 and Time AVPs take strings like "10.0.0.1" and
+// "2015-01-02T15:04:05Z".
+type AVP struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value"`
+}
+
+// Duration is a time.Duration written as a string, e.g. "1s".
+type Duration time.Duration
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// LoadConfig reads the configuration from a JSON file.
+func LoadConfig(filename string) (*Config, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ReadConfig(f)
+}
+
+// ReadConfig reads the configuration in JSON from r.
+func ReadConfig(r io.Reader) (*Config, error) {
+	cfg := &Config{
+		Mode:        "server",
+		Addr:        ":3868",
+		VendorID:    13,
+		ProductName: "go-diameter",
+		Timeout:     Duration(5 * time.Second),
+	}
+	if err := json.NewDecoder(r).Decode(cfg); err != nil {
+		return nil, err
+	}
+	switch {
+	case cfg.Mode != "server" && cfg.Mode != "client":
+		return nil, fmt.Errorf("invalid mode %q", cfg.Mode)
+	case cfg.OriginHost == "" || cfg.OriginRealm == "":
+		return nil, fmt.Errorf("origin_host and origin_realm are required")
+	case len(cfg.Applications) == 0:
+		return nil, fmt.Errorf("at least one application is required")
+	}
+	return cfg, nil
+}
+
+// LoadDictionaries loads the dictionaries of the configuration into dp.
+func (cfg *Config) LoadDictionaries(dp *dict.Parser) error {
+	for _, filename := range cfg.Dictionaries {
+		if err := dp.LoadFile(filename); err != nil {
+			return fmt.Errorf("%s: %v", filename, err)
+		}
+	}
+	return nil
+}
+
+// ApplicationIDs returns the ids of the applications of the configuration.
+func (cfg *Config) ApplicationIDs() []uint32 {
+	var ids []uint32
+	for _, app := range cfg.Applications {
+		if app.Auth != 0 {
+			ids = append(ids, app.Auth)
+		}
+		if app.Acct != 0 {
+			ids = append(ids, app.Acct)
+		}
+	}
+	return ids
+}
+
+// NewAVPs builds AVPs from their definitions, resolving names and data
+// types through the dictionary of application appid.
+func NewAVPs(dp *dict.Parser, appid uint32, defs []AVP) ([]*diam.AVP, error) {
+	avps := make([]*diam.AVP, 0, len(defs))
+	for _, def := range defs {
+		a, err := newAVP(dp, appid, def)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", def.Name, err)
+		}
+		avps = append(avps, a)
+	}
+	return avps, nil
+}
+
+func newAVP(dp *dict.Parser, appid uint32, def AVP) (*diam.AVP, error) {
+	dictAVP, err := dp.FindAVP(appid, def.Name)
+	if err != nil {
+		return nil, err
+	}
+	var flags uint8
+	for _, f := range strings.Split(dictAVP.Must, ",") {
+		switch strings.TrimSpace(f) {
+		case "M":
+			flags |= avp.Mbit
+		case "V":
+			flags |= avp.Vbit
+		}
+	}
+	var data datatype.Type
+	if dictAVP.Data.Type == datatype.GroupedType {
+		var defs []AVP
+		if err = json.Unmarshal(def.Value, &defs); err != nil {
+			return nil, err
+		}
+		avps, err := NewAVPs(dp, appid, defs)
+		if err != nil {
+			return nil, err
+		}
+		data = &diam.GroupedAVP{AVP: avps}
+	} else if data, err = newData(dictAVP, def.Value); err != nil {
+		return nil, err
+	}
+	return diam.NewAVP(dictAVP.Code, flags, dictAVP.VendorID, data), nil
+}
+
+// newData converts a JSON value to the data type of the dictionary AVP.
+func newData(dictAVP *dict.AVP, v json.RawMessage) (datatype.Type, error) {
+	var s string
+	var n json.Number
+	d := json.NewDecoder(bytes.NewReader(v))
+	d.UseNumber()
+	switch dictAVP.Data.Type {
+	case datatype.Integer32Type, datatype.Integer64Type,
+		datatype.Unsigned32Type, datatype.Unsigned64Type,
+		datatype.Float32Type, datatype.Float64Type:
+		if err := d.Decode(&n); err != nil {
+			return nil, err
+		}
+	case datatype.EnumeratedType:
+		var x interface{}
+		if err := d.Decode(&x); err != nil {
+			return nil, err
+		}
+		switch x := x.(type) {
+		case json.Number:
+			n = x
+		case string:
+			for _, item := range dictAVP.Data.Enum {
+				if item.Name == x {
+					return datatype.Enumerated(item.Code), nil
+				}
+			}
+			return nil, fmt.Errorf("unknown item %q", x)
+		default:
+			return nil, fmt.Errorf("invalid value %s", v)
+		}
+	default:
+		if err := d.Decode(&s); err != nil {
+			return nil, err
+		}
+	}
+	switch dictAVP.Data.Type {
+	case datatype.Integer32Type, datatype.EnumeratedType:
+		i, err := strconv.ParseInt(n.String(), 10, 32)
+		if dictAVP.Data.Type == datatype.EnumeratedType {
+			return datatype.Enumerated(i), err
+		}
+		return datatype.Integer32(i), err
+	case datatype.Integer64Type:
+		i, err := strconv.ParseInt(n.String(), 10, 64)
+		return datatype.Integer64(i), err
+	case datatype.Unsigned32Type:
+		i, err := strconv.ParseUint(n.String(), 10, 32)
+		return datatype.Unsigned32(i), err
+	case datatype.Unsigned64Type:
+		i, err := strconv.ParseUint(n.String(), 10, 64)
+		return datatype.Unsigned64(i), err
+	case datatype.Float32Type:
+		f, err := strconv.ParseFloat(n.String(), 32)
+		return datatype.Float32(f), err
+	case datatype.Float64Type:
+		f, err := strconv.ParseFloat(n.String(), 64)
+		return datatype.Float64(f), err
+	case datatype.AddressType, datatype.IPv4Type:
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address %q", s)
+		}
+		if dictAVP.Data.Type == datatype.IPv4Type {
+			return datatype.IPv4(ip), nil
+		}
+		return datatype.Address(ip), nil
+	case datatype.TimeType:
+		t, err := time.Parse(time.RFC3339, s)
+		return datatype.Time(t), err
+	case datatype.DiameterIdentityType:
+		return datatype.DiameterIdentity(s), nil
+	case datatype.DiameterURIType:
+		return datatype.DiameterURI(s), nil
+	case datatype.IPFilterRuleType:
+		return datatype.IPFilterRule(s), nil
+	case datatype.QoSFilterRuleType:
+		return datatype.QoSFilterRule(s), nil
+	case datatype.OctetStringType:
+		return datatype.OctetString(s), nil
+	case datatype.UTF8StringType:
+		return datatype.UTF8String(s), nil
+	}
+	return nil, fmt.Errorf("unsupported data type %s", dictAVP.Data.TypeName)
+}
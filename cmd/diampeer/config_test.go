@@ -0,0 +1,108 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+const testConfig = `{
+	"mode": "server",
+	"origin_host": "ocs.example.com",
+	"origin_realm": "example.com",
+	"applications": [{"auth": 4}, {"acct": 3, "vendor": 10415}],
+	"answers": [{
+		"command": "CCR",
+		"avps": [
+			{"name": "CC-Request-Type", "value": "INITIAL_REQUEST"},
+			{"name": "Host-IP-Address", "value": "10.0.0.1"},
+			{"name": "Granted-Service-Unit", "value": [
+				{"name": "CC-Time", "value": 3600}
+			]}
+		]
+	}],
+	"timeout": "1s"
+}`
+
+func TestReadConfig(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Addr != ":3868" {
+		t.Fatalf("Unexpected Addr. Want :3868, have %q", cfg.Addr)
+	}
+	if cfg.Timeout != Duration(time.Second) {
+		t.Fatalf("Unexpected Timeout. Want 1s, have %s", time.Duration(cfg.Timeout))
+	}
+	ids := cfg.ApplicationIDs()
+	if len(ids) != 2 || ids[0] != 4 || ids[1] != 3 {
+		t.Fatalf("Unexpected application ids. Want [4 3], have %v", ids)
+	}
+}
+
+func TestReadConfig_Invalid(t *testing.T) {
+	for _, s := range []string{
+		`{"mode": "proxy", "origin_host": "a", "origin_realm": "b", "applications": [{"auth": 4}]}`,
+		`{"origin_realm": "b", "applications": [{"auth": 4}]}`,
+		`{"origin_host": "a", "origin_realm": "b"}`,
+		`{"origin_host": "a", "origin_realm": "b", "applications": [{"auth": 4}], "timeout": "x"}`,
+	} {
+		if _, err := ReadConfig(strings.NewReader(s)); err == nil {
+			t.Fatalf("Unexpected success reading %s", s)
+		}
+	}
+}
+
+func TestNewAVPs(t *testing.T) {
+	cfg, err := ReadConfig(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	avps, err := NewAVPs(dict.Default, 4, cfg.Answers[0].AVP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(avps) != 3 {
+		t.Fatalf("Unexpected number of AVPs. Want 3, have %d", len(avps))
+	}
+	if v := avps[0].Data.(datatype.Enumerated); v != 1 {
+		t.Fatalf("Unexpected CC-Request-Type. Want 1, have %d", v)
+	}
+	if v := avps[1].Data.(datatype.Address); !net.IP(v).Equal(net.ParseIP("10.0.0.1")) {
+		t.Fatalf("Unexpected Host-IP-Address. Want 10.0.0.1, have %s", net.IP(v))
+	}
+	g, ok := avps[2].Data.(*diam.GroupedAVP)
+	if !ok || len(g.AVP) != 1 {
+		t.Fatalf("Unexpected Granted-Service-Unit: %s", avps[2])
+	}
+	if g.AVP[0].Code != avp.CCTime || g.AVP[0].Data.(datatype.Unsigned32) != 3600 {
+		t.Fatalf("Unexpected CC-Time: %s", g.AVP[0])
+	}
+	if avps[2].Flags&avp.Mbit == 0 {
+		t.Fatal("Unexpected flags, missing M-bit")
+	}
+}
+
+func TestNewAVPs_Invalid(t *testing.T) {
+	for _, def := range []AVP{
+		{Name: "No-Such-AVP", Value: []byte(`1`)},
+		{Name: "CC-Request-Type", Value: []byte(`"NO_SUCH_ITEM"`)},
+		{Name: "CC-Time", Value: []byte(`"x"`)},
+		{Name: "Host-IP-Address", Value: []byte(`"not an ip"`)},
+	} {
+		if _, err := NewAVPs(dict.Default, 4, []AVP{def}); err == nil {
+			t.Fatalf("Unexpected success building %s", def.Name)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Diameter peer simulator.
+//
+// diampeer acts as a Diameter server or client peer configured from a
+// JSON file: identity, dictionaries, applications, canned answers and,
+// in client mode, the requests to send. It can stand up a fake OCS or
+// HSS without writing Go code.
+//
+// Example configuration of a fake OCS:
+//
+//	{
+//		"mode": "server",
+//		"addr": ":3868",
+//		"origin_host": "ocs.example.com",
+//		"origin_realm": "example.com",
+//		"applications": [{"auth": 4}],
+//		"answers": [{
+//			"command": "CCR",
+//			"result_code": 2001,
+//			"echo": ["Session-Id", "CC-Request-Type", "CC-Request-Number"],
+//			"avps": [
+//				{"name": "Auth-Application-Id", "value": 4},
+//				{"name": "Granted-Service-Unit", "value": [
+//					{"name": "CC-Time", "value": 3600}
+//				]}
+//			]
+//		}]
+//	}
+//
+// The capabilities exchange (CER/CEA) of each peering, with what was
+// advertised by both sides and negotiated, can be exported as JSON for
+// audit and troubleshooting:
+//
+//	diampeer -config ocs.json -capabilities peers.json
+//
+// In client mode, the peer connects to addr, sends the configured
+// requests and prints the answers. Session-Id, Origin-Host and
+// Origin-Realm are added to requests when missing.
+//
+//	diampeer -config ocs.json
+//
+// In preflight mode, the peer connects to each of the configured peers,
+// or addr, performs the capabilities exchange, optionally sends DPR, and
+// prints a summary. It exits with a non-zero status if any peer failed,
+// which makes it usable as a deployment smoke test:
+//
+//	diampeer -config ocs.json -preflight -dpr
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/session"
+	"github.com/ibrohimislam/go-diameter/diam/sm"
+)
+
+func main() {
+	config := flag.String("config", "diampeer.json", "configuration file in JSON")
+	addr := flag.String("addr", "", "address to listen on or connect to, overrides the configuration")
+	silent := flag.Bool("s", false, "silent mode, do not print messages")
+	capsFile := flag.String("capabilities", "", "file to export the capabilities exchange of peers to, in JSON")
+	preflight := flag.Bool("preflight", false, "check the capabilities exchange with the configured peers and exit")
+	dpr := flag.Bool("dpr", false, "send DPR to the peers in preflight mode")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *addr != "" {
+		cfg.Addr = *addr
+	}
+	if err = cfg.LoadDictionaries(dict.Default); err != nil {
+		log.Fatal(err)
+	}
+	settings := &sm.Settings{
+		OriginHost:    datatype.DiameterIdentity(cfg.OriginHost),
+		OriginRealm:   datatype.DiameterIdentity(cfg.OriginRealm),
+		VendorID:      datatype.Unsigned32(cfg.VendorID),
+		ProductName:   datatype.UTF8String(cfg.ProductName),
+		OriginStateID: datatype.Unsigned32(time.Now().Unix()),
+		Applications:  cfg.ApplicationIDs(),
+	}
+	mux := sm.New(settings)
+	for _, a := range cfg.Answers {
+		mux.Handle(a.Command, handleAnswer(a, *silent))
+	}
+	go printErrors(mux.ErrorReports())
+	if *capsFile != "" {
+		go exportCapabilities(mux, mux.Events().Subscribe(16), *capsFile)
+	}
+
+	if *preflight {
+		if !runPreflight(cfg, mux, *dpr) {
+			os.Exit(1)
+		}
+		return
+	}
+	if cfg.Mode == "server" {
+		mux.HandleFunc("ALL", handleALL)
+		log.Println("Starting diameter peer on", cfg.Addr)
+		srv := &sm.Server{Addr: cfg.Addr, Handler: mux}
+		log.Fatal(srv.ListenAndServe())
+	}
+	if err = runClient(cfg, mux, *silent); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printErrors(ec <-chan *diam.ErrorReport) {
+	for err := range ec {
+		log.Println(err)
+	}
+}
+
+// exportCapabilities writes the capabilities exchange of the peers to
+// filename every time a peer goes up or down.
+func exportCapabilities(mux *sm.StateMachine, events <-chan *sm.Event, filename string) {
+	for e := range events {
+		if e.Type != sm.PeerUp && e.Type != sm.PeerDown {
+			continue
+		}
+		f, err := os.Create(filename)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err = mux.ExportCapabilities(f); err != nil {
+			log.Println(err)
+		}
+		f.Close()
+	}
+}
+
+func handleALL(c diam.Conn, m *diam.Message) {
+	log.Printf("Received unexpected message from %s:\n%s", c.RemoteAddr(), m)
+}
+
+// handleAnswer returns a handler that answers requests with the canned
+// answer a.
+func handleAnswer(a Answer, silent bool) diam.HandlerFunc {
+	if a.ResultCode == 0 {
+		a.ResultCode = diam.Success
+	}
+	if a.Echo == nil {
+		a.Echo = []string{"Session-Id"}
+	}
+	return func(c diam.Conn, m *diam.Message) {
+		if !silent {
+			log.Printf("Received %s from %s:\n%s", a.Command, c.RemoteAddr(), m)
+		}
+		ans := m.Answer(a.ResultCode)
+		for _, name := range a.Echo {
+			if v, err := m.FindAVPByName(name); err == nil {
+				ans.AddAVP(v)
+			}
+		}
+		avps, err := NewAVPs(m.Dictionary(), m.Header.ApplicationID, a.AVP)
+		if err != nil {
+			log.Printf("Failed to build answer to %s: %v", a.Command, err)
+			return
+		}
+		for _, v := range avps {
+			ans.AddAVP(v)
+		}
+		ans.SortAVPs()
+		if _, err = ans.WriteTo(c); err != nil {
+			log.Printf("Failed to write message to %s: %s\n%s", c.RemoteAddr(), err, ans)
+			return
+		}
+		if !silent {
+			log.Printf("Sent answer to %s:\n%s", c.RemoteAddr(), ans)
+		}
+	}
+}
+
+// newClient returns a client advertising the applications of the
+// configuration.
+func newClient(cfg *Config, mux *sm.StateMachine) *sm.Client {
+	cli := &sm.Client{
+		Handler:        mux,
+		EnableWatchdog: true,
+	}
+	for _, app := range cfg.Applications {
+		switch {
+		case app.Vendor != 0 && app.Auth != 0:
+			cli.VendorSpecificApplicationID = append(cli.VendorSpecificApplicationID,
+				sm.VendorAuthApplicationID(app.Vendor, app.Auth))
+		case app.Vendor != 0:
+			cli.VendorSpecificApplicationID = append(cli.VendorSpecificApplicationID,
+				sm.VendorAcctApplicationID(app.Vendor, app.Acct))
+		case app.Auth != 0:
+			cli.AuthApplicationID = append(cli.AuthApplicationID,
+				diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(app.Auth)))
+		default:
+			cli.AcctApplicationID = append(cli.AcctApplicationID,
+				diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(app.Acct)))
+		}
+	}
+	return cli
+}
+
+// runPreflight checks the capabilities exchange with the configured
+// peers, prints a summary and reports whether all of them succeeded.
+func runPreflight(cfg *Config, mux *sm.StateMachine, dpr bool) bool {
+	peers := cfg.Peers
+	if len(peers) == 0 {
+		peers = []string{cfg.Addr}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.Timeout))
+	defer cancel()
+	cli := newClient(cfg, mux)
+	results, err := cli.Preflight(ctx, dpr, peers...)
+	if results == nil {
+		log.Println(err)
+		return false
+	}
+	if werr := sm.WritePreflight(os.Stdout, results); werr != nil {
+		log.Println(werr)
+	}
+	return err == nil
+}
+
+// runClient connects to the peer, sends the configured requests and
+// waits for their answers.
+func runClient(cfg *Config, mux *sm.StateMachine, silent bool) error {
+	cli := newClient(cfg, mux)
+	answers := make(chan *diam.Message, 16)
+	for _, r := range cfg.Requests {
+		cmd := strings.TrimSuffix(r.Command, "R") + "A"
+		mux.HandleFunc(cmd, func(c diam.Conn, m *diam.Message) {
+			answers <- m
+		})
+	}
+	log.Println("Connecting to", cfg.Addr)
+	c, err := cli.Dial(cfg.Addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	ids := session.NewIDGenerator(datatype.DiameterIdentity(cfg.OriginHost), "")
+	for _, r := range cfg.Requests {
+		if r.Count == 0 {
+			r.Count = 1
+		}
+		for i := 0; i < r.Count; i++ {
+			m, err := newRequest(cfg, ids, r)
+			if err != nil {
+				return err
+			}
+			if _, err = m.WriteTo(c); err != nil {
+				return err
+			}
+			if !silent {
+				log.Printf("Sent %s to %s:\n%s", r.Command, c.RemoteAddr(), m)
+			}
+			select {
+			case a := <-answers:
+				if !silent {
+					log.Printf("Received answer from %s:\n%s", c.RemoteAddr(), a)
+				}
+			case <-time.After(time.Duration(cfg.Timeout)):
+				return fmt.Errorf("timeout waiting for answer to %s", r.Command)
+			}
+			time.Sleep(time.Duration(r.Interval))
+		}
+	}
+	return nil
+}
+
+// newRequest builds a request from its configuration, adding the
+// Session-Id, Origin-Host and Origin-Realm AVPs when missing.
+func newRequest(cfg *Config, ids *session.IDGenerator, r Request) (*diam.Message, error) {
+	cmd, err := findCommand(dict.Default, r.Application, r.Command)
+	if err != nil {
+		return nil, err
+	}
+	m := diam.NewRequest(cmd.Code, r.Application, dict.Default)
+	avps, err := NewAVPs(dict.Default, r.Application, r.AVP)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range avps {
+		m.AddAVP(a)
+	}
+	for _, rule := range cmd.Request.Rule {
+		if _, err = m.FindAVPByName(rule.AVP); err == nil {
+			continue
+		}
+		switch rule.AVP {
+		case "Session-Id":
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, ids.New())
+		case "Origin-Host":
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity(cfg.OriginHost))
+		case "Origin-Realm":
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity(cfg.OriginRealm))
+		}
+	}
+	m.SortAVPs()
+	return m, nil
+}
+
+// findCommand returns the command of application appid with the given
+// short name, e.g. "CCR".
+func findCommand(dp *dict.Parser, appid uint32, name string) (*dict.Command, error) {
+	short := strings.TrimSuffix(name, "R")
+	for _, app := range dp.Apps() {
+		if app.ID != appid && app.ID != 0 {
+			continue
+		}
+		for _, cmd := range app.Command {
+			if cmd.Short == short {
+				return cmd, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown command %s in application %d", name, appid)
+}
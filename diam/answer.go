@@ -0,0 +1,116 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+)
+
+// The AnswerFunc type is an alternative to HandlerFunc for handlers of
+// requests, that return the answer instead of writing it. The answer
+// returned is written to the connection of the request, nothing is
+// written if it is nil. Errors are answered with their Result-Code, see
+// ResultError, or DIAMETER_UNABLE_TO_COMPLY (5012).
+//
+// The context is the one of the connection, see Conn.Context.
+//
+// Example:
+//
+//	mux.HandleAnswerFunc("CCR", func(ctx context.Context, req *diam.Message) (*diam.Message, error) {
+//		var ccr CCR
+//		if err := req.Unmarshal(&ccr); err != nil {
+//			return nil, &diam.ResultError{ResultCode: diam.MissingAVP, Err: err}
+//		}
+//		a := req.Answer(diam.Success)
+//		...
+//		return a, nil
+//	})
+type AnswerFunc func(ctx context.Context, req *Message) (*Message, error)
+
+// ServeDIAM calls f with the requests, and writes its answer. Answers
+// are dropped.
+func (f AnswerFunc) ServeDIAM(c Conn, m *Message) {
+	answerHandler{f: f}.ServeDIAM(c, m)
+}
+
+// HandleAnswerFunc registers the AnswerFunc for the requests of the
+// given command, like HandleFunc. Errors returned by f, other than
+// *ResultError, and the errors writing answers are sent to the
+// ErrorReports of the mux.
+func (mux *ServeMux) HandleAnswerFunc(cmd string, f AnswerFunc) {
+	mux.Handle(cmd, answerHandler{f: f, reporter: mux})
+}
+
+// ResultError is an error of AnswerFuncs to answer the request with
+// the given Result-Code, e.g. DIAMETER_MISSING_AVP (5005), and the
+// FailedAVP in a Failed-AVP, if set.
+type ResultError struct {
+	ResultCode uint32
+	FailedAVP  *AVP
+	Err        error // Optional cause
+}
+
+// Error implements the error interface.
+func (e *ResultError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("result code %d: %s", e.ResultCode, e.Err)
+}
+
+type answerHandler struct {
+	f        AnswerFunc
+	reporter ErrorReporter
+}
+
+func (h answerHandler) ServeDIAM(c Conn, m *Message) {
+	if !isRequest(m) {
+		return
+	}
+	a, err := h.f(c.Context(), m)
+	if err != nil {
+		a = errorAnswer(m, err)
+		if _, ok := err.(*ResultError); !ok {
+			h.report(c, m, err)
+		}
+	}
+	if a == nil {
+		return
+	}
+	if _, err = a.WriteTo(c); err != nil {
+		h.report(c, m, err)
+	}
+}
+
+func (h answerHandler) report(c Conn, m *Message, err error) {
+	if h.reporter != nil {
+		h.reporter.Error(&ErrorReport{Conn: c, Message: m, Error: err})
+	}
+}
+
+// errorAnswer returns the answer to the request m for err, with the
+// Session-Id and Proxy-Info AVPs of m.
+func errorAnswer(m *Message, err error) *Message {
+	code, failed := uint32(UnableToComply), (*AVP)(nil)
+	switch e := err.(type) {
+	case *ResultError:
+		code, failed = e.ResultCode, e.FailedAVP
+	case *AVPDecodeError:
+		code, failed = e.ResultCode, e.FailedAVP
+	}
+	a := m.Answer(code)
+	if sid, err := m.FindAVP(avp.SessionID, 0); err == nil {
+		a.InsertAVP(sid)
+	}
+	if failed != nil {
+		a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &GroupedAVP{AVP: []*AVP{failed}})
+	}
+	CopyProxyInfo(a, m)
+	return a
+}
@@ -0,0 +1,84 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestServeMux_HandleAnswerFunc(t *testing.T) {
+	var ret error
+	missing := diam.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(0))
+	smux := diam.NewServeMux()
+	smux.HandleAnswerFunc("CCR", func(ctx context.Context, req *diam.Message) (*diam.Message, error) {
+		if ret != nil {
+			return nil, ret
+		}
+		if _, err := req.FindAVP(avp.CCRequestType, 0); err != nil {
+			return nil, &diam.ResultError{ResultCode: diam.MissingAVP, FailedAVP: missing, Err: err}
+		}
+		return req.Answer(diam.Success), nil
+	})
+	c := diamtest.NewFakeConn(nil, nil)
+	resultCode := func(m *diam.Message) uint32 {
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return uint32(rc.Data.(datatype.Unsigned32))
+	}
+
+	smux.ServeDIAM(c, newEnumCCR(1, 0))
+	req := newProxiedCCR(newProxyInfo("p1", "s1"))
+	smux.ServeDIAM(c, req)
+	ret = errors.New("backend unavailable")
+	smux.ServeDIAM(c, req)
+	w := c.Written()
+	if len(w) != 3 {
+		t.Fatalf("Unexpected number of answers: %d", len(w))
+	}
+	for i, code := range []uint32{diam.Success, diam.MissingAVP, diam.UnableToComply} {
+		if rc := resultCode(w[i]); rc != code {
+			t.Fatalf("Unexpected Result-Code of answer %d. Want %d, have %d", i, code, rc)
+		}
+	}
+	// Error answers have the Session-Id, Failed-AVP and Proxy-Info.
+	for _, code := range []uint32{avp.SessionID, avp.FailedAVP, avp.ProxyInfo} {
+		if _, err := w[1].FindAVP(code, 0); err != nil {
+			t.Fatalf("Missing AVP %d in error answer: %s", code, w[1])
+		}
+	}
+	// Only unexpected errors are reported.
+	select {
+	case err := <-smux.ErrorReports():
+		if err.Error != ret {
+			t.Fatalf("Unexpected error: %v", err.Error)
+		}
+	default:
+		t.Fatal("Missing error report")
+	}
+	select {
+	case err := <-smux.ErrorReports():
+		t.Fatalf("Unexpected error report: %v", err.Error)
+	default:
+	}
+
+	// Nothing is written for nil answers.
+	f := diam.AnswerFunc(func(ctx context.Context, req *diam.Message) (*diam.Message, error) {
+		return nil, nil
+	})
+	f.ServeDIAM(c, req)
+	if n := len(c.Written()); n != 3 {
+		t.Fatalf("Unexpected number of answers: %d", n)
+	}
+}
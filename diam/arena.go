@@ -0,0 +1,129 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import "sync"
+
+// avpArena allocates the AVPs of a message decoded in arena mode, and
+// the lists of AVPs of the message and its grouped AVPs, in large
+// blocks instead of one allocation per AVP.
+//
+// Methods of a nil *avpArena allocate from the heap.
+type avpArena struct {
+	avps    []AVP  // current block of AVPs
+	ptrs    []*AVP // current block of AVP lists
+	scratch []*AVP // lists being decoded, see push and list
+}
+
+var arenaPool sync.Pool
+
+// avgAVPLen is the estimated average length of AVPs, used to size the
+// first block of arenas from the length of messages.
+const avgAVPLen = 12
+
+// newArena returns an arena for a message body of size bytes.
+func newArena(size int) *avpArena {
+	n := size/avgAVPLen + 1
+	ar, _ := arenaPool.Get().(*avpArena)
+	if ar == nil {
+		ar = &avpArena{}
+	}
+	if cap(ar.avps) < n {
+		ar.avps = make([]AVP, 0, n)
+		ar.ptrs = make([]*AVP, 0, n)
+	}
+	return ar
+}
+
+// newAVP returns a new zero AVP.
+func (ar *avpArena) newAVP() *AVP {
+	if ar == nil {
+		return &AVP{}
+	}
+	if len(ar.avps) == cap(ar.avps) {
+		// Continue in a new block, AVPs in the full one stay valid.
+		ar.avps = make([]AVP, 0, 2*cap(ar.avps)+1)
+	}
+	ar.avps = ar.avps[:len(ar.avps)+1]
+	return &ar.avps[len(ar.avps)-1]
+}
+
+// mark returns the start of a new AVP list.
+func (ar *avpArena) mark() int {
+	if ar == nil {
+		return 0
+	}
+	return len(ar.scratch)
+}
+
+// push adds a to the AVP list being decoded. Without an arena, it is
+// appended to avps.
+func (ar *avpArena) push(avps []*AVP, a *AVP) []*AVP {
+	if ar == nil {
+		return append(avps, a)
+	}
+	ar.scratch = append(ar.scratch, a)
+	return avps
+}
+
+// list returns the AVPs pushed since the mark start, as a list whose
+// capacity is its length, so appending to it does not overwrite other
+// lists.
+func (ar *avpArena) list(start int) []*AVP {
+	avps := ar.scratch[start:]
+	n := len(avps)
+	if n == 0 {
+		return nil
+	}
+	if len(ar.ptrs)+n > cap(ar.ptrs) {
+		c := 2 * cap(ar.ptrs)
+		if c < n {
+			c = n
+		}
+		ar.ptrs = make([]*AVP, 0, c)
+	}
+	l := len(ar.ptrs)
+	ar.ptrs = append(ar.ptrs, avps...)
+	ar.scratch = ar.scratch[:start]
+	return ar.ptrs[l : l+n : l+n]
+}
+
+// release clears the arena and returns it to the pool. Only the last,
+// largest, blocks are kept, earlier blocks are left to the GC.
+func (ar *avpArena) release() {
+	avps := ar.avps
+	for i := range avps {
+		avps[i] = AVP{}
+	}
+	ptrs := ar.ptrs
+	for i := range ptrs {
+		ptrs[i] = nil
+	}
+	scratch := ar.scratch[:cap(ar.scratch)]
+	for i := range scratch {
+		scratch[i] = nil
+	}
+	ar.avps = avps[:0]
+	ar.ptrs = ptrs[:0]
+	ar.scratch = scratch[:0]
+	arenaPool.Put(ar)
+}
+
+// Release releases the AVPs of a message decoded in arena mode, see
+// Server.ArenaDecode, to be reused by other messages. It does nothing
+// for other messages.
+//
+// After Release, m has no AVPs and the AVPs it had, including their
+// data and the AVPs embedded in grouped AVPs, must not be used.
+// Handlers that keep AVPs or pass them to other goroutines must not
+// call Release, or copy the AVPs first.
+func (m *Message) Release() {
+	if m.arena == nil {
+		return
+	}
+	m.arena.release()
+	m.arena = nil
+	m.AVP = nil
+}
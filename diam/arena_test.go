@@ -0,0 +1,87 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// groupedMessage returns a CER with n grouped AVPs, serialized.
+func groupedMessage(t testing.TB, n int) []byte {
+	m := NewRequest(CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	for i := 0; i < n; i++ {
+		m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &GroupedAVP{
+			AVP: []*AVP{
+				NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+				NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(i)),
+			},
+		})
+	}
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestReadMessage_Arena(t *testing.T) {
+	b := groupedMessage(t, 100)
+	m, err := readMessage(bytes.NewReader(b), dict.Default, decodeArena)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.arena == nil {
+		t.Fatal("Message decoded without arena")
+	}
+	if n := len(m.AVP); n != 101 || cap(m.AVP) != n {
+		t.Fatalf("Unexpected AVP list. Want 101 AVPs, have len %d cap %d", n, cap(m.AVP))
+	}
+	// Appending to lists in the arena must not affect other lists.
+	g := m.AVP[1].Data.(*GroupedAVP)
+	g.AVP = append(g.AVP, NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1)))
+	if a := m.AVP[2].Data.(*GroupedAVP).AVP[0]; a.Code != avp.VendorID {
+		t.Fatalf("Unexpected AVP after append: %s", a)
+	}
+	g.AVP = g.AVP[:2]
+	rb, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(rb, b) {
+		t.Fatal("Message decoded in arena serializes differently")
+	}
+	m.Release()
+	if m.AVP != nil {
+		t.Fatal("Unexpected AVPs after Release")
+	}
+	m.Release() // No-op.
+}
+
+func BenchmarkReadMessage_Grouped(b *testing.B) {
+	benchmarkReadMessageMode(b, 0)
+}
+
+func BenchmarkReadMessage_GroupedArena(b *testing.B) {
+	benchmarkReadMessageMode(b, decodeArena)
+}
+
+func benchmarkReadMessageMode(b *testing.B, mode decodeMode) {
+	reader := bytes.NewReader(groupedMessage(b, 300))
+	b.ReportAllocs()
+	for n := 0; n < b.N; n++ {
+		m, err := readMessage(reader, dict.Default, mode)
+		if err != nil {
+			b.Fatal(err)
+		}
+		m.Release()
+		reader.Seek(0, 0)
+	}
+}
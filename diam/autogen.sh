@@ -30,7 +30,7 @@ EOF
 cat $dict | sed \
 	-e 's/-//g' \
 	-ne 's/.*command code="\(.*\)" .* name="\(.*\)".*/\2 = \1/p' \
-	| sort -u >> $src
+	| awk '!seen[$1]++' | sort >> $src
 
 echo ')' >> $src
 
@@ -53,11 +53,16 @@ package avp
 const (
 EOF
 
+# Commands and AVPs sharing their name across applications keep the
+# code of the first dictionary, in file name order. The SIP-Method AVP
+# of RFC 4740 (393) shares its name with the 3GPP SIP-Method AVP (824),
+# which keeps the constant.
 cat $dict | sed \
+	-e '/avp name="SIP-Method" code="393"/d' \
 	-e 's/-Id\([-"s]\)/-ID\1/g' \
 	-e 's/-//g' \
 	-ne 's/.*avp name="\(.*\)" code="\([0-9]*\)".*/\1 = \2/p' \
-	| sort -u >> $src
+	| awk '!seen[$1]++' | sort >> $src
 
 echo ')' >> $src
 
@@ -79,7 +84,8 @@ package dict
 import "bytes"
 
 // Default is a Parser object with pre-loaded
-// Base Protocol and Credit Control dictionaries.
+// Base Protocol, Credit Control, NAS, 3GPP Ro/Rf, Gx, Zh/Zn and SLg/SLh,
+// Mobile IPv4 and SIP dictionaries.
 var Default *Parser
 
 func init() {
@@ -88,6 +94,11 @@ func init() {
 	Default.Load(bytes.NewReader([]byte(creditcontrolXML)))
 	Default.Load(bytes.NewReader([]byte(networkaccessserverXML)))
 	Default.Load(bytes.NewReader([]byte(tgpprorfXML)))
+	Default.Load(bytes.NewReader([]byte(tgppgxXML)))
+	Default.Load(bytes.NewReader([]byte(mobileipv4XML)))
+	Default.Load(bytes.NewReader([]byte(sipXML)))
+	Default.Load(bytes.NewReader([]byte(tgppzhznXML)))
+	Default.Load(bytes.NewReader([]byte(tgppslgslhXML)))
 }
 
 EOF
@@ -5,6 +5,7 @@
 package diam
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -31,10 +32,10 @@ func NewAVP(code uint32, flags uint8, vendor uint32, data datatype.Type) *AVP {
 		VendorID: vendor,
 		Data:     data,
 	}
-	a.Length = a.headerLen() + a.Data.Len() // no padding length
 	if vendor > 0 && flags&avp.Vbit != avp.Vbit {
 		a.Flags |= avp.Vbit
 	}
+	a.Length = a.headerLen() + a.Data.Len() // no padding length
 	return a
 }
 
@@ -51,9 +52,111 @@ func DecodeAVP(data []byte, application uint32, dictionary *dict.Parser) (*AVP,
 // DecodeFromBytes decodes the bytes of a Diameter AVP.
 // It uses the given application id and dictionary for decoding the bytes.
 func (a *AVP) DecodeFromBytes(data []byte, application uint32, dictionary *dict.Parser) error {
+	return a.decodeFromBytes(data, application, dictionary, false, nil)
+}
+
+// decodeAVP is like DecodeAVP, in relay mode when relay is true. The
+// AVP and the AVPs embedded in it are allocated and counted by the
+// decoder d, if not nil.
+func decodeAVP(data []byte, application uint32, dictionary *dict.Parser, relay bool, d *avpDecoder) (*AVP, error) {
+	avp, err := d.newAVP()
+	if err != nil {
+		return nil, err
+	}
+	if err := avp.decodeFromBytes(data, application, dictionary, relay, d); err != nil {
+		return nil, err
+	}
+	return avp, nil
+}
+
+// decodeFromBytes decodes the bytes of a Diameter AVP. In relay mode,
+// AVPs unknown to the dictionary are decoded as datatype.Raw so they
+// can be forwarded bit-exact, instead of failing.
+func (a *AVP) decodeFromBytes(data []byte, application uint32, dictionary *dict.Parser, relay bool, d *avpDecoder) error {
+	payload, err := a.decodeHeader(data)
+	if err != nil {
+		return newAVPDecodeError(InvalidAVPLenght, a, nil, err)
+	}
+	return a.decodePayload(payload, application, dictionary, relay, d)
+}
+
+// decodePayload decodes the data of the AVP from payload, using the
+// code and vendor id from its header.
+func (a *AVP) decodePayload(payload []byte, application uint32, dictionary *dict.Parser, relay bool, d *avpDecoder) error {
+	// Find this code in the dictionary.
+	dictAVP, err := dictionary.FindAVPWithVendor(application, a.Code, a.VendorID)
+	if err != nil {
+		if !relay {
+			return newAVPDecodeError(AVPUnsupported, a, payload, err)
+		}
+		a.Data = datatype.Raw{
+			Flags:   a.Flags,
+			Payload: append([]byte(nil), payload...),
+		}
+		return nil
+	}
+
+	if !dictAVP.Data.ValidLength(len(payload)) {
+		return newAVPDecodeError(InvalidAVPLenght, a, payload,
+			lengthError(a, dictAVP, len(payload)))
+	}
+	a.Data, err = datatype.Decode(dictAVP.Data.Type, payload)
+	if err != nil {
+		return newAVPDecodeError(InvalidAVPValue, a, payload, err)
+	}
+	// Handle grouped AVPs.
+	if a.Data.Type() == datatype.GroupedType {
+		a.Data, err = decodeGrouped(
+			a.Data.(datatype.Grouped),
+			application, dictionary, relay, d,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lengthError returns the *AVPLengthError of the AVP a with n octets of
+// data, out of the limits of dictAVP.
+func lengthError(a *AVP, dictAVP *dict.AVP, n int) *AVPLengthError {
+	return &AVPLengthError{
+		Code:     a.Code,
+		VendorID: a.VendorID,
+		Length:   n,
+		Min:      dictAVP.Data.MinLength,
+		Max:      dictAVP.Data.MaxLength,
+	}
+}
+
+// checkLength returns an *AVPLengthError if the data of the AVP a, or
+// of the AVPs embedded in it, is out of the length limits of the
+// dictionary. AVPs unknown to the dictionary are not checked.
+func checkLength(d *dict.Parser, appID uint32, a *AVP) error {
+	if g, ok := a.Data.(*GroupedAVP); ok {
+		for _, ga := range g.AVP {
+			if err := checkLength(d, appID, ga); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	dictAVP, err := d.FindAVPWithVendor(appID, a.Code, a.VendorID)
+	if err != nil || a.Data == nil {
+		return nil
+	}
+	if n := a.Data.Len(); !dictAVP.Data.ValidLength(n) {
+		return lengthError(a, dictAVP, n)
+	}
+	return nil
+}
+
+// decodeHeader decodes the AVP header from data and returns the payload
+// of the AVP, without padding.
+func (a *AVP) decodeHeader(data []byte) ([]byte, error) {
 	dl := len(data)
 	if dl < 8 {
-		return fmt.Errorf("Not enough data to decode AVP header: %d bytes", dl)
+		return nil, fmt.Errorf("Not enough data to decode AVP header: %d bytes", dl)
 	}
 	a.Code = binary.BigEndian.Uint32(data[0:4])
 
@@ -61,9 +164,12 @@ func (a *AVP) DecodeFromBytes(data []byte, application uint32, dictionary *dict.
 	a.Length = int(uint24to32(data[5:8]))
 	if dl < int(a.Length) {
 		fmt.Printf("%#v\n", data)
-		return fmt.Errorf("Not enough data to decode AVP [1]: %d != %d",
+		return nil, fmt.Errorf("Not enough data to decode AVP [1]: %d != %d",
 			dl, a.Length)
 	}
+	if a.Length < a.headerLen() {
+		return nil, fmt.Errorf("Invalid AVP length: %d", a.Length)
+	}
 	data = data[:a.Length] // this cuts padded bytes off
 
 	var hdrLength int
@@ -78,42 +184,14 @@ func (a *AVP) DecodeFromBytes(data []byte, application uint32, dictionary *dict.
 		hdrLength = 8
 	}
 
-	// fmt.Printf("decoding AVP[%d v:%d]...\n", a.Code, a.VendorID)
-
-	// fmt.Printf("header: %#v\n", data[:hdrLength])
-
-	// Find this code in the dictionary.
-	dictAVP, err := dictionary.FindAVPWithVendor(application, a.Code, a.VendorID)
-	if err != nil {
-		return err
-	}
 	bodyLen := a.Length - hdrLength
 	if n := len(payload); n < bodyLen {
-		return fmt.Errorf(
+		return nil, fmt.Errorf(
 			"Not enough data to decode AVP [2]: %d != %d",
 			hdrLength, n,
 		)
 	}
-
-	//fmt.Printf("payload: %#v\n", payload)
-
-	a.Data, err = datatype.Decode(dictAVP.Data.Type, payload)
-	if err != nil {
-		return err
-	}
-	// Handle grouped AVPs.
-	if a.Data.Type() == datatype.GroupedType {
-		//fmt.Printf("decoding grouped AVP [%d] ------- \n", a.Code)
-		a.Data, err = DecodeGrouped(
-			a.Data.(datatype.Grouped),
-			application, dictionary,
-		)
-		//fmt.Printf("decoding grouped AVP [%d][end]------- \n", a.Code)
-		if err != nil {
-			return err
-		}
-	}
-	return nil
+	return payload, nil
 }
 
 // Serialize returns the byte sequence that represents this AVP.
@@ -152,13 +230,15 @@ func (a *AVP) SerializeTo(b []byte) error {
 	return nil
 }
 
-// Len returns the length of this AVP in bytes with padding.
+// Len returns the length of this AVP in bytes with padding. It is
+// computed from Data when Length is not set.
 func (a *AVP) Len() int {
-	//fmt.Printf("a[%d].Len(): %d %d\n", a.Code, a.Length, a.headerLen()+a.Data.Len()+a.Data.Padding())
-	//return a.headerLen() + a.Data.Len() + a.Data.Padding()
-	//fmt.Printf("a.Len(): %d\n", a.Length)
-	//fmt.Printf("a.Len() + padding?: %d\n", (a.Length+3)/4*4)
-	return (a.Length + 3) / 4 * 4
+	n := a.Length
+	if n == 0 && a.Data != nil {
+		// AVPs built as struct literals rather than with NewAVP.
+		n = a.headerLen() + a.Data.Len()
+	}
+	return (n + 3) / 4 * 4
 }
 
 func (a *AVP) headerLen() int {
@@ -168,12 +248,34 @@ func (a *AVP) headerLen() int {
 	return 8
 }
 
+// Equal reports whether a and o have the same code, flags, vendor id and
+// data. Embedded AVPs of grouped AVPs are compared recursively.
+func (a *AVP) Equal(o *AVP) bool {
+	if a == nil || o == nil {
+		return a == o
+	}
+	if a.Code != o.Code || a.Flags != o.Flags || a.VendorID != o.VendorID {
+		return false
+	}
+	if a.Data == nil || o.Data == nil {
+		return a.Data == o.Data
+	}
+	if a.Data.Type() != o.Data.Type() {
+		return false
+	}
+	if ag, ok := a.Data.(*GroupedAVP); ok {
+		og, ok := o.Data.(*GroupedAVP)
+		return ok && equalAVPs(ag.AVP, og.AVP)
+	}
+	return bytes.Equal(a.Data.Serialize(), o.Data.Serialize())
+}
+
 func (a *AVP) String() string {
 	return fmt.Sprintf("{Code:%d,Flags:0x%x,Length:%d,VendorId:%d,Value:%s}",
 		a.Code,
 		a.Flags,
 		a.Len(),
 		a.VendorID,
-		a.Data,
+		a.value(),
 	)
 }
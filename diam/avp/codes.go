@@ -11,6 +11,8 @@ const (
 	ADCRuleBaseName                       = 1095
 	AFChargingIdentifier                  = 505
 	AFCorrelationInformation              = 1276
+	APNAggregateMaxBitrateDL              = 1040
+	APNAggregateMaxBitrateUL              = 1041
 	ARAPChallengeResponse                 = 84
 	ARAPFeatures                          = 71
 	ARAPPassword                          = 70
@@ -42,13 +44,16 @@ const (
 	AcctTunnelConnection                  = 68
 	AcctTunnelPacketsLost                 = 86
 	AccumulatedCost                       = 2052
+	AccuracyFulfilmentIndicator           = 2513
 	Adaptations                           = 1217
 	AdditionalContentInformation          = 1207
+	AdditionalServingNode                 = 2406
 	AdditionalTypeInformation             = 1205
 	AddressData                           = 897
 	AddressDomain                         = 898
 	AddressType                           = 899
 	AddresseeType                         = 1208
+	AgeOfLocationEstimate                 = 2514
 	AllocationRetentionPriority           = 1034
 	AlternateChargedPartyAddress          = 1280
 	AoCCostInformation                    = 2053
@@ -80,7 +85,11 @@ const (
 	BaseTimeInterval                      = 1265
 	BasicServiceCode                      = 3411
 	BearerCapability                      = 3412
+	BearerControlMode                     = 1023
+	BearerIdentifier                      = 1020
+	BearerOperation                       = 1021
 	BearerService                         = 854
+	BootstrapInfoCreationTime             = 408
 	CCCorrelationID                       = 411
 	CCInputOctets                         = 412
 	CCMoney                               = 413
@@ -114,16 +123,23 @@ const (
 	CallingStationID                      = 31
 	CarrierSelectRoutingInformation       = 2023
 	CauseCode                             = 861
+	CellGlobalIdentity                    = 1604
 	ChangeCondition                       = 2037
 	ChangeTime                            = 2038
 	ChargeReasonCode                      = 2118
 	ChargedParty                          = 857
 	ChargingCharacteristicsSelectionMode  = 2066
 	ChargingRuleBaseName                  = 1004
+	ChargingRuleDefinition                = 1003
+	ChargingRuleInstall                   = 1001
+	ChargingRuleName                      = 1005
+	ChargingRuleRemove                    = 1002
+	ChargingRuleReport                    = 1018
 	CheckBalanceResult                    = 422
 	Class                                 = 25
 	ClassIdentifier                       = 1214
 	ClientAddress                         = 2018
+	ConfidentialityKey                    = 625
 	ConfigurationToken                    = 78
 	ConnectInfo                           = 77
 	ContentClass                          = 1220
@@ -141,6 +157,7 @@ const (
 	CurrentTariff                         = 2056
 	DRMContent                            = 1221
 	DataCodingScheme                      = 2001
+	DefaultEPSBearerQoS                   = 1049
 	DeferredLocationEventType             = 1230
 	DeliveryReportRequested               = 1216
 	DeliveryStatus                        = 2104
@@ -148,11 +165,31 @@ const (
 	DestinationInterface                  = 2002
 	DestinationRealm                      = 283
 	Diagnostics                           = 2039
+	DigestAlgorithm                       = 111
+	DigestAuthParam                       = 117
+	DigestCNonce                          = 113
+	DigestDomain                          = 119
+	DigestEntityBodyHash                  = 112
+	DigestHA1                             = 121
+	DigestMethod                          = 108
+	DigestNextnonce                       = 107
+	DigestNonce                           = 105
+	DigestNonceCount                      = 114
+	DigestOpaque                          = 116
+	DigestQoP                             = 110
+	DigestRealm                           = 104
+	DigestResponse                        = 103
+	DigestResponseAuth                    = 106
+	DigestStale                           = 120
+	DigestURI                             = 109
+	DigestUsername                        = 115
 	DirectDebitingFailureHandling         = 428
 	DisconnectCause                       = 273
 	DomainName                            = 1200
 	DynamicAddressFlag                    = 2051
 	DynamicAddressFlagExtension           = 2068
+	ECGI                                  = 2517
+	EUTRANPositioningData                 = 2516
 	EarlyMediaDescription                 = 1272
 	Envelope                              = 1266
 	EnvelopeEndTime                       = 1267
@@ -163,18 +200,25 @@ const (
 	Event                                 = 825
 	EventChargingTimeStamp                = 1258
 	EventTimestamp                        = 55
+	EventTrigger                          = 1006
 	EventType                             = 823
 	ExperimentalResult                    = 297
 	ExperimentalResultCode                = 298
 	Expires                               = 888
 	Exponent                              = 429
 	FailedAVP                             = 279
+	FeatureList                           = 630
+	FeatureListID                         = 629
 	FileRepairSupported                   = 1224
 	FilterID                              = 11
 	FinalUnitAction                       = 449
 	FinalUnitIndication                   = 430
 	FirmwareRevision                      = 267
 	FixedUserLocationInfo                 = 2825
+	FlowDescription                       = 507
+	FlowDirection                         = 1080
+	FlowInformation                       = 1058
+	FlowStatus                            = 511
 	Flows                                 = 510
 	ForwardingPending                     = 3415
 	FramedAppletalkLink                   = 37
@@ -194,12 +238,25 @@ const (
 	FramedRoute                           = 22
 	FramedRouting                         = 10
 	FromAddress                           = 2708
+	GAAServiceIdentifier                  = 403
+	GBAType                               = 410
+	GBAUserSecSettings                    = 400
+	GBA_UAwarenessIndicator               = 407
+	GERANGANSSPositioningData             = 2526
+	GERANPositioningData                  = 2525
+	GERANPositioningInfo                  = 2524
 	GGSNAddress                           = 847
+	GMLCAddress                           = 2405
+	GMLCNumber                            = 1474
 	GSUPoolIdentifier                     = 453
 	GSUPoolReference                      = 457
+	GUSSTimestamp                         = 409
 	GrantedServiceUnit                    = 431
+	GuaranteedBitrateDL                   = 1025
 	GuaranteedBitrateUL                   = 1026
+	HorizontalAccuracy                    = 2505
 	HostIPAddress                         = 257
+	IMEI                                  = 1402
 	IMSApplicationReferenceIdentifier     = 2601
 	IMSChargingIdentifier                 = 841
 	IMSCommunicationServiceIdentifier     = 1281
@@ -207,6 +264,7 @@ const (
 	IMSIUnauthenticatedFlag               = 2308
 	IMSInformation                        = 876
 	IMSVisitedNetworkIdentifier           = 2713
+	IPCANType                             = 1027
 	IPRealmDefaultIndication              = 2603
 	ISUPCause                             = 3416
 	ISUPCauseDiagnostics                  = 3422
@@ -219,27 +277,46 @@ const (
 	IncrementalCost                       = 2062
 	InitialIMSChargingIdentifier          = 2321
 	InstanceID                            = 3402
+	IntegrityKey                          = 626
 	InterOperatorIdentifier               = 838
 	InterfaceID                           = 2003
 	InterfacePort                         = 2004
 	InterfaceText                         = 2005
 	InterfaceType                         = 2006
+	KeyExpiryTime                         = 404
 	LCSAPN                                = 1231
+	LCSCapabilitiesSets                   = 2404
 	LCSClientDialedByMS                   = 1233
 	LCSClientExternalID                   = 1234
 	LCSClientID                           = 1232
 	LCSClientName                         = 1235
 	LCSClientType                         = 1241
+	LCSCodeword                           = 2511
 	LCSDataCodingScheme                   = 1236
+	LCSEPSClientName                      = 2501
 	LCSFormatIndicator                    = 1237
 	LCSInformation                        = 878
 	LCSNameString                         = 1238
+	LCSPriority                           = 2503
+	LCSPrivacyCheck                       = 2512
+	LCSPrivacyCheckNonSession             = 2521
+	LCSPrivacyCheckSession                = 2522
+	LCSQoS                                = 2504
+	LCSQoSClass                           = 2523
+	LCSReferenceNumber                    = 2531
 	LCSRequestorID                        = 1239
 	LCSRequestorIDString                  = 1240
+	LCSRequestorName                      = 2502
+	LCSServiceTypeID                      = 2520
+	LCSSupportedGADShapes                 = 2510
+	LMSI                                  = 2400
+	LRAFlags                              = 2549
+	LRRFlags                              = 2530
 	LocalGWInsertedIndication             = 2604
 	LocalSequenceNumber                   = 2063
 	LocationEstimate                      = 1242
 	LocationEstimateType                  = 1243
+	LocationEvent                         = 2518
 	LocationType                          = 1244
 	LoginIPHost                           = 14
 	LoginIPv6Host                         = 98
@@ -259,6 +336,36 @@ const (
 	MBMSServiceType                       = 906
 	MBMSSessionIdentity                   = 908
 	MBMSUserServiceType                   = 1225
+	MEKeyMaterial                         = 405
+	MIPAlgorithmType                      = 345
+	MIPAuthInputDataLength                = 338
+	MIPAuthenticatorLength                = 339
+	MIPAuthenticatorOffset                = 340
+	MIPCandidateHomeAgentHost             = 336
+	MIPFAChallenge                        = 344
+	MIPFAtoHAMSA                          = 328
+	MIPFAtoHASPI                          = 318
+	MIPFAtoMNMSA                          = 326
+	MIPFAtoMNSPI                          = 319
+	MIPFeatureVector                      = 337
+	MIPFilterRule                         = 342
+	MIPHAtoFAMSA                          = 329
+	MIPHAtoFASPI                          = 323
+	MIPHAtoMNMSA                          = 332
+	MIPHomeAgentAddress                   = 334
+	MIPHomeAgentHost                      = 348
+	MIPMNAAAAuth                          = 322
+	MIPMNAAASPI                           = 341
+	MIPMNtoFAMSA                          = 325
+	MIPMNtoHAMSA                          = 331
+	MIPMSALifetime                        = 367
+	MIPMobileNodeAddress                  = 333
+	MIPNonce                              = 335
+	MIPOriginatingForeignAAA              = 347
+	MIPRegReply                           = 321
+	MIPRegRequest                         = 320
+	MIPReplayMode                         = 346
+	MIPSessionKey                         = 343
 	MMBoxStorageRequested                 = 1248
 	MMContentType                         = 1203
 	MMEName                               = 2402
@@ -268,6 +375,7 @@ const (
 	MMTelInformation                      = 2030
 	MMTelSServiceType                     = 2031
 	MSCAddress                            = 3417
+	MSCNumber                             = 2403
 	MSISDN                                = 701
 	MTCIWFAddress                         = 3406
 	MandatoryCapability                   = 604
@@ -280,9 +388,12 @@ const (
 	MessageID                             = 1210
 	MessageSize                           = 1212
 	MessageType                           = 1211
+	MeteringMethod                        = 1007
+	MonitoringKey                         = 1066
 	MultiRoundTimeOut                     = 272
 	MultipleServicesCreditControl         = 456
 	MultipleServicesIndicator             = 455
+	NAFHostname                           = 402
 	NASFilterRule                         = 400
 	NASPort                               = 5
 	NASPortID                             = 87
@@ -291,6 +402,7 @@ const (
 	NNIType                               = 2704
 	NeighbourNodeAddress                  = 2705
 	NetworkCallReferenceNumber            = 3418
+	NetworkRequestSupport                 = 1024
 	NextTariff                            = 2057
 	NodeFunctionality                     = 862
 	NodeID                                = 2064
@@ -302,7 +414,9 @@ const (
 	NumberOfReceivedTalkBursts            = 1282
 	NumberOfTalkBursts                    = 1283
 	NumberPortabilityRoutingInformation   = 2024
+	Offline                               = 1008
 	OfflineCharging                       = 1278
+	Online                                = 1009
 	OnlineChargingFlag                    = 2303
 	OptionalCapability                    = 605
 	OriginHost                            = 264
@@ -317,14 +431,19 @@ const (
 	OriginatorSCCPAddress                 = 2008
 	OutgoingSessionID                     = 2320
 	OutgoingTrunkGroupID                  = 853
+	PCCRuleStatus                         = 1019
 	PDNConnectionChargingID               = 2050
 	PDPAddress                            = 1227
 	PDPAddressPrefixLength                = 2606
 	PDPContextType                        = 1247
+	PLAFlags                              = 2546
+	PLRFlags                              = 2545
+	PPRAddress                            = 2407
 	PSAppendFreeFormatData                = 867
 	PSFreeFormatData                      = 866
 	PSFurnishChargingInformation          = 865
 	PSInformation                         = 874
+	PacketFilterIdentifier                = 1060
 	ParticipantAccessPriority             = 1259
 	ParticipantActionType                 = 2049
 	ParticipantGroup                      = 1260
@@ -345,6 +464,9 @@ const (
 	PoCUserRoleinfoUnits                  = 1254
 	PortLimit                             = 62
 	PositioningData                       = 1245
+	Precedence                            = 1010
+	PreemptionCapability                  = 1047
+	PreemptionVulnerability               = 1048
 	PreferredAoCCurrency                  = 2315
 	PresenceReportingAreaIdentifier       = 2821
 	PresenceReportingAreaInformation      = 2822
@@ -357,6 +479,7 @@ const (
 	ProxyHost                             = 280
 	ProxyInfo                             = 284
 	ProxyState                            = 33
+	PublicIdentity                        = 601
 	QoSClassIdentifier                    = 1028
 	QoSFilterRule                         = 407
 	QoSInformation                        = 1016
@@ -391,17 +514,23 @@ const (
 	ReplyApplicID                         = 1223
 	ReplyMessage                          = 18
 	ReplyPathRequested                    = 2011
+	ReportingLevel                        = 1011
 	ReportingReason                       = 872
 	RequestedAction                       = 436
 	RequestedPartyAddress                 = 1251
 	RequestedServiceUnit                  = 437
 	RequiredMBMSBearerCapabilities        = 901
+	ResponseTime                          = 2509
 	RestrictionFilterRule                 = 438
 	ResultCode                            = 268
+	RevalidationTime                      = 1042
 	RoleOfNode                            = 829
 	RouteHeaderReceived                   = 3403
 	RouteHeaderTransmitted                = 3404
 	RouteRecord                           = 282
+	RuleActivationTime                    = 1043
+	RuleDeactivationTime                  = 1044
+	RuleFailureCode                       = 1031
 	SDPAnswerTimestamp                    = 1275
 	SDPMediaComponent                     = 843
 	SDPMediaDescription                   = 845
@@ -411,13 +540,43 @@ const (
 	SDPTimeStamps                         = 1273
 	SDPType                               = 2036
 	SGSNAddress                           = 1228
+	SGSNName                              = 2409
+	SGSNNumber                            = 1489
+	SGSNRealm                             = 2410
 	SGWAddress                            = 2067
 	SGWChange                             = 2065
+	SIPAOR                                = 122
+	SIPAccountingInformation              = 368
+	SIPAccountingServerURI                = 369
+	SIPAuthDataItem                       = 376
+	SIPAuthenticate                       = 379
+	SIPAuthenticationInfo                 = 381
+	SIPAuthenticationScheme               = 377
+	SIPAuthorization                      = 380
+	SIPCreditControlServerURI             = 370
+	SIPDeregistrationReason               = 383
+	SIPItemNumber                         = 378
+	SIPMandatoryCapability                = 373
 	SIPMethod                             = 824
+	SIPNumberAuthItems                    = 382
+	SIPOptionalCapability                 = 374
+	SIPReasonCode                         = 384
+	SIPReasonInfo                         = 385
 	SIPRequestTimestamp                   = 834
 	SIPRequestTimestampFraction           = 2301
 	SIPResponseTimestamp                  = 835
 	SIPResponseTimestampFraction          = 2302
+	SIPServerAssignmentType               = 375
+	SIPServerCapabilities                 = 372
+	SIPServerURI                          = 371
+	SIPSupportedUserDataType              = 388
+	SIPUserAuthorizationType              = 387
+	SIPUserData                           = 389
+	SIPUserDataAlreadyAvailable           = 392
+	SIPUserDataContents                   = 391
+	SIPUserDataType                       = 390
+	SIPVisitedNetworkID                   = 386
+	SLgLocationType                       = 2500
 	SMDeviceTriggerIndicator              = 3407
 	SMDeviceTriggerInformation            = 3405
 	SMDischargeTime                       = 2012
@@ -455,6 +614,7 @@ const (
 	SessionDirection                      = 2707
 	SessionID                             = 263
 	SessionPriority                       = 650
+	SessionReleaseCause                   = 1045
 	SessionServerFailover                 = 271
 	SessionTimeout                        = 27
 	SponsorIdentity                       = 531
@@ -468,6 +628,7 @@ const (
 	SubscriptionIDData                    = 444
 	SubscriptionIDType                    = 450
 	SupplementaryService                  = 2048
+	SupportedFeatures                     = 628
 	SupportedVendorID                     = 265
 	TADIdentifier                         = 2717
 	TDFIPAddress                          = 1091
@@ -504,10 +665,12 @@ const (
 	TimeQuotaType                         = 1271
 	TimeStamps                            = 833
 	TimeUsage                             = 2045
+	ToSTrafficClass                       = 1014
 	TokenText                             = 1215
 	TotalNumberOfMessagesExploded         = 2113
 	TotalNumberOfMessagesSent             = 2114
 	TrafficDataVolumes                    = 2046
+	TransactionIdentifier                 = 401
 	TranscoderInsertedIndication          = 2605
 	TransitIOIList                        = 2701
 	Trigger                               = 1264
@@ -525,9 +688,17 @@ const (
 	TunnelType                            = 64
 	Tunneling                             = 401
 	TypeNumber                            = 1204
+	UICCKeyMaterial                       = 406
+	UTRANGANSSPositioningData             = 2529
+	UTRANPositioningData                  = 2528
+	UTRANPositioningInfo                  = 2527
 	UnitCost                              = 2061
 	UnitQuotaThreshold                    = 1226
 	UnitValue                             = 445
+	UsageMonitoringInformation            = 1067
+	UsageMonitoringLevel                  = 1068
+	UsageMonitoringReport                 = 1069
+	UsageMonitoringSupport                = 1070
 	UsedServiceUnit                       = 446
 	UserCSGInformation                    = 2319
 	UserData                              = 606
@@ -545,8 +716,12 @@ const (
 	VLRNumber                             = 3420
 	ValidityTime                          = 448
 	ValueDigits                           = 447
+	VelocityEstimate                      = 2515
+	VelocityRequested                     = 2508
 	VendorID                              = 266
 	VendorSpecificApplicationID           = 260
+	VerticalAccuracy                      = 2506
+	VerticalRequested                     = 2507
 	VolumeQuotaThreshold                  = 869
 	ePDGAddress                           = 3425
 )
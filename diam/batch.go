@@ -0,0 +1,53 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import "golang.org/x/net/context"
+
+// SendBatch writes the messages to the connection with a single write,
+// cutting the syscall overhead of bursts, e.g. replays of accounting
+// records. It returns the error of each message, nil for the ones
+// written.
+//
+// Messages are processed like with Message.WriteTo: the egress hooks
+// are called for each one, and messages they reject are not written.
+// When the write fails, messages written entirely before the failure
+// have no error, and the others have the *WriteError. With WriteLanes,
+// the batch is scheduled as a whole in the lane of its first message.
+func (w *response) SendBatch(msgs []*Message) []error {
+	errs := make([]error, len(msgs))
+	var b []byte
+	ends := make([]int, len(msgs)) // end of each message in b, or -1
+	for i, m := range msgs {
+		ends[i] = -1
+		if err := w.egress(m); err != nil {
+			errs[i] = err
+			continue
+		}
+		mb, err := encode(w, m)
+		if err == nil && mb == nil {
+			mb, err = m.Serialize()
+		}
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		b = append(b, mb...)
+		ends[i] = len(b)
+	}
+	if len(b) == 0 {
+		return errs
+	}
+	n, err := w.writeContext(context.Background(), b)
+	if err == nil {
+		return errs
+	}
+	for i, end := range ends {
+		if end > n {
+			errs[i] = err
+		}
+	}
+	return errs
+}
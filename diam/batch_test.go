@@ -0,0 +1,74 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestConn_SendBatch(t *testing.T) {
+	mc := make(chan *diam.Message, 10)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("ACR", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	errRejected := errors.New("rejected")
+	cmux := diam.NewServeMux()
+	cmux.HandleEgress(func(c diam.Conn, m *diam.Message) error {
+		if m.Header.HopByHopID == 2 {
+			return errRejected
+		}
+		return nil
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	var msgs []*diam.Message
+	for i := 1; i <= 4; i++ {
+		m := diam.NewRequest(diam.Accounting, 3, nil)
+		m.Header.HopByHopID = uint32(i)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		msgs = append(msgs, m)
+	}
+	errs := cli.SendBatch(msgs)
+	if len(errs) != len(msgs) {
+		t.Fatalf("Unexpected number of errors: %d", len(errs))
+	}
+	for i, err := range errs {
+		if i == 1 {
+			if err != errRejected {
+				t.Fatalf("Unexpected error for message %d: %v", i, err)
+			}
+		} else if err != nil {
+			t.Fatalf("Unexpected error for message %d: %v", i, err)
+		}
+	}
+	for _, want := range []uint32{1, 3, 4} {
+		select {
+		case m := <-mc:
+			if m.Header.HopByHopID != want {
+				t.Fatalf("Unexpected hop-by-hop id. Want %d, have %d",
+					want, m.Header.HopByHopID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for ACR")
+		}
+	}
+	if s := cli.Stats(); s.MessagesOut != 3 {
+		t.Fatalf("Unexpected number of messages out: %d", s.MessagesOut)
+	}
+}
@@ -10,6 +10,8 @@ import (
 	"crypto/tls"
 	"net"
 
+	"golang.org/x/net/context"
+
 	"github.com/ibrohimislam/go-diameter/diam/dict"
 )
 
@@ -19,15 +21,44 @@ import (
 // If dict is nil, dict.Default is used.
 func Dial(addr string, handler Handler, dp *dict.Parser) (Conn, error) {
 	srv := &Server{Addr: addr, Handler: handler, Dict: dp}
-	return dial(srv)
+	return dial(context.Background(), srv)
+}
+
+// DialContext is like Dial, but gives up connecting when ctx is done,
+// e.g. at its deadline, returning the error of ctx. The connection is
+// not affected by ctx once established.
+func DialContext(ctx context.Context, addr string, handler Handler, dp *dict.Parser) (Conn, error) {
+	srv := &Server{Addr: addr, Handler: handler, Dict: dp}
+	return dial(ctx, srv)
+}
+
+// DialNetwork is like Dial, but connects using the transport registered
+// as network, e.g. "sctp". See RegisterDialer.
+func DialNetwork(network, addr string, handler Handler, dp *dict.Parser) (Conn, error) {
+	srv := &Server{Network: network, Addr: addr, Handler: handler, Dict: dp}
+	return dial(context.Background(), srv)
 }
 
-func dial(srv *Server) (Conn, error) {
+// Dial connects to the peer pointed to by srv.Addr and returns the
+// Conn that can be used to send diameter messages, like Dial. Incoming
+// messages are read and handled with the settings of srv, e.g. its
+// Codec and decode modes, using the transport of srv.Network.
+func (srv *Server) Dial() (Conn, error) {
+	return dial(context.Background(), srv)
+}
+
+// DialContext is like Server.Dial, but gives up connecting when ctx is
+// done, see DialContext.
+func (srv *Server) DialContext(ctx context.Context) (Conn, error) {
+	return dial(ctx, srv)
+}
+
+func dial(ctx context.Context, srv *Server) (Conn, error) {
 	addr := srv.Addr
 	if len(addr) == 0 {
 		addr = ":3868"
 	}
-	rw, err := net.Dial("tcp", addr)
+	rw, err := srv.dial(ctx, srv.network(), addr)
 	if err != nil {
 		return nil, err
 	}
@@ -42,17 +73,71 @@ func dial(srv *Server) (Conn, error) {
 // DialTLS is the same as Dial, but for TLS.
 func DialTLS(addr, certFile, keyFile string, handler Handler, dp *dict.Parser) (Conn, error) {
 	srv := &Server{Addr: addr, Handler: handler, Dict: dp}
-	return dialTLS(srv, certFile, keyFile)
+	return dialTLS(context.Background(), srv, certFile, keyFile)
+}
+
+// DialTLSConfig is like DialTLS, but with the TLS configuration of
+// config, e.g. with the RootCAs and ServerName used to verify the
+// peer, and the client certificates. Unlike DialTLS, the certificate
+// of the peer is verified unless config.InsecureSkipVerify is set.
+func DialTLSConfig(addr string, config *tls.Config, handler Handler, dp *dict.Parser) (Conn, error) {
+	srv := &Server{Addr: addr, Handler: handler, Dict: dp, TLSConfig: config}
+	return dialTLS(context.Background(), srv, "", "")
+}
+
+// DialTLS is like Server.Dial, but for TLS.
+func (srv *Server) DialTLS(certFile, keyFile string) (Conn, error) {
+	return dialTLS(context.Background(), srv, certFile, keyFile)
 }
 
-func dialTLS(srv *Server, certFile, keyFile string) (Conn, error) {
+// DialTLSContext is like Server.DialTLS, but gives up connecting and
+// the TLS handshake when ctx is done, see DialContext.
+func (srv *Server) DialTLSContext(ctx context.Context, certFile, keyFile string) (Conn, error) {
+	return dialTLS(ctx, srv, certFile, keyFile)
+}
+
+func dialTLS(ctx context.Context, srv *Server, certFile, keyFile string) (Conn, error) {
 	addr := srv.Addr
 	if len(addr) == 0 {
 		addr = ":3868"
 	}
+	config, err := clientTLSConfig(srv, addr, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	rw, err := srv.dial(ctx, srv.network(), addr)
+	if err != nil {
+		return nil, err
+	}
+	tc := tls.Client(rw, config)
+	if ctx.Done() != nil {
+		if err = tc.HandshakeContext(ctx); err != nil {
+			rw.Close()
+			return nil, err
+		}
+	}
+	c, err := srv.newConn(tc)
+	if err != nil {
+		return nil, err
+	}
+	go c.serve()
+	return c.writer, nil
+}
+
+// clientTLSConfig returns a copy of srv.TLSConfig with the certificate
+// of the files, if any. Without srv.TLSConfig, the certificate of the
+// peer is not verified. The ServerName defaults to the host of addr.
+func clientTLSConfig(srv *Server, addr, certFile, keyFile string) (*tls.Config, error) {
 	config := &tls.Config{InsecureSkipVerify: true}
 	if srv.TLSConfig != nil {
-		*config = *srv.TLSConfig
+		config = srv.TLSConfig.Clone()
+	}
+	if len(config.ServerName) == 0 && !config.InsecureSkipVerify {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		config.ServerName = host
 	}
 	if len(certFile) != 0 {
 		var err error
@@ -62,14 +147,5 @@ func dialTLS(srv *Server, certFile, keyFile string) (Conn, error) {
 			return nil, err
 		}
 	}
-	rw, err := net.Dial("tcp", addr)
-	if err != nil {
-		return nil, err
-	}
-	c, err := srv.newConn(tls.Client(rw, config))
-	if err != nil {
-		return nil, err
-	}
-	go c.serve()
-	return c.writer, nil
+	return config, nil
 }
@@ -0,0 +1,38 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestClientTLSConfig(t *testing.T) {
+	config, err := clientTLSConfig(&Server{}, "peer.example.com:3868", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !config.InsecureSkipVerify {
+		t.Fatal("Peers are verified without a TLSConfig")
+	}
+	srv := &Server{TLSConfig: &tls.Config{RootCAs: x509.NewCertPool(), MinVersion: tls.VersionTLS12}}
+	if config, err = clientTLSConfig(srv, "peer.example.com:3868", "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if config.InsecureSkipVerify || config.RootCAs != srv.TLSConfig.RootCAs || config.MinVersion != tls.VersionTLS12 {
+		t.Fatalf("Unexpected config: %+v", config)
+	}
+	if config.ServerName != "peer.example.com" {
+		t.Fatalf("Unexpected ServerName. Want peer.example.com, have %q", config.ServerName)
+	}
+	if len(srv.TLSConfig.ServerName) != 0 {
+		t.Fatal("TLSConfig of the server was modified")
+	}
+	srv.TLSConfig.ServerName = "diameter.example.com"
+	if config, _ = clientTLSConfig(srv, "10.0.0.1:3868", "", ""); config.ServerName != "diameter.example.com" {
+		t.Fatalf("Unexpected ServerName. Want diameter.example.com, have %q", config.ServerName)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bufio"
+
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// Codec is the wire format of the messages of a connection, see
+// Server.Codec. The default is the binary format of RFC 6733, see
+// DiameterCodec.
+//
+// Alternative codecs allow experimenting with other framings, e.g.
+// JSON for debugging bridges or batch frames, while reusing the
+// ServeMux, sessions and state machine of this package unchanged.
+//
+// Codecs are shared by all connections of a Server and must be safe
+// for concurrent use.
+type Codec interface {
+	// ReadMessage reads the next message from r, decoding its AVPs
+	// with the given dictionary. Errors close the connection.
+	ReadMessage(r *bufio.Reader, dictionary *dict.Parser) (*Message, error)
+
+	// Encode returns the serialized message m.
+	Encode(m *Message) ([]byte, error)
+}
+
+// DiameterCodec is the Codec of the binary format of RFC 6733.
+var DiameterCodec Codec = diameterCodec{}
+
+type diameterCodec struct{}
+
+func (diameterCodec) ReadMessage(r *bufio.Reader, dictionary *dict.Parser) (*Message, error) {
+	return ReadMessage(r, dictionary)
+}
+
+func (diameterCodec) Encode(m *Message) ([]byte, error) {
+	return m.Serialize()
+}
+
+// codecWriter is implemented by connections that may use a Codec
+// other than the default.
+type codecWriter interface {
+	codec() Codec
+}
+
+// codec returns the Codec of the connection, or nil for the default.
+func (w *response) codec() Codec {
+	return w.conn.server.Codec
+}
+
+// encode serializes the message m with the codec of the writer, if
+// any. It returns nil and no error for writers using the default.
+func encode(writer interface{}, m *Message) ([]byte, error) {
+	if cw, ok := writer.(codecWriter); ok {
+		if codec := cw.codec(); codec != nil {
+			return codec.Encode(m)
+		}
+	}
+	return nil, nil
+}
+
+// readCodecMessage reads the next message from the connection with the
+// codec of its server.
+func (c *conn) readCodecMessage(codec Codec) (*Message, error) {
+	reads, buffered := c.sr.reads, c.buf.Reader.Buffered() > 0
+	m, err := codec.ReadMessage(c.buf.Reader, c.dictionary())
+	if err != nil {
+		return nil, err
+	}
+	c.countRead(uint32(m.Len()), c.sr.reads-reads, buffered)
+	c.countAnswer(m.Header)
+	m.meta = c.newMeta()
+	return m, nil
+}
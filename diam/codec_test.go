@@ -0,0 +1,108 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// jsonCodec is a diam.Codec of messages as JSON lines, with the AVPs
+// in their binary format.
+type jsonCodec struct{}
+
+type jsonMessage struct {
+	Header *diam.Header
+	AVP    [][]byte
+}
+
+func (jsonCodec) ReadMessage(r *bufio.Reader, dp *dict.Parser) (*diam.Message, error) {
+	line, err := r.ReadBytes('\n')
+	if err != nil {
+		return nil, err
+	}
+	var jm jsonMessage
+	if err = json.Unmarshal(line, &jm); err != nil {
+		return nil, err
+	}
+	h := jm.Header
+	m := diam.NewMessage(h.CommandCode, h.CommandFlags, h.ApplicationID, h.HopByHopID, h.EndToEndID, dp)
+	for _, b := range jm.AVP {
+		a, err := diam.DecodeAVP(b, h.ApplicationID, m.Dictionary())
+		if err != nil {
+			return nil, err
+		}
+		m.AddAVP(a)
+	}
+	return m, nil
+}
+
+func (jsonCodec) Encode(m *diam.Message) ([]byte, error) {
+	jm := jsonMessage{Header: m.Header}
+	for _, a := range m.AVP {
+		b, err := a.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		jm.AVP = append(jm.AVP, b)
+	}
+	b, err := json.Marshal(jm)
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+func TestServer_Codec(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+		a.WriteTo(c)
+	})
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.Codec = jsonCodec{}
+	srv.Start()
+	defer srv.Close()
+
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := (&diam.Server{Addr: srv.Addr, Handler: cmux, Codec: jsonCodec{}}).Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-mc:
+		oh, err := a.FindAVP(avp.OriginHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := oh.Data.(datatype.DiameterIdentity); v != "srv" {
+			t.Fatalf("Unexpected Origin-Host %q", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DWA")
+	}
+	if s := cli.Stats(); s.MessagesOut != 1 || s.MessagesIn != 1 {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+}
@@ -8,13 +8,25 @@ package diam
 
 // Diameter command codes.
 const (
-	AA                   = 265
-	AbortSession         = 274
-	Accounting           = 271
-	CapabilitiesExchange = 257
-	CreditControl        = 272
-	DeviceWatchdog       = 280
-	DisconnectPeer       = 282
-	ReAuth               = 258
-	SessionTermination   = 275
+	AA                      = 265
+	AAMobileNode            = 260
+	AbortSession            = 274
+	Accounting              = 271
+	BootstrappingInfo       = 310
+	CapabilitiesExchange    = 257
+	CreditControl           = 272
+	DeviceWatchdog          = 280
+	DisconnectPeer          = 282
+	HomeAgentMIP            = 262
+	LCSRoutingInfo          = 8388622
+	LocationInfo            = 285
+	LocationReport          = 8388621
+	MultimediaAuth          = 286
+	ProvideLocation         = 8388620
+	PushProfile             = 288
+	ReAuth                  = 258
+	RegistrationTermination = 287
+	ServerAssignment        = 284
+	SessionTermination      = 275
+	UserAuthorization       = 283
 )
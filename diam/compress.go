@@ -0,0 +1,78 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"compress/zlib"
+	"io"
+	"net"
+	"sync"
+)
+
+// ConnWrapper wraps the connections of a Server, e.g. to compress the
+// stream of messages. See Server.WrapConn.
+type ConnWrapper func(c net.Conn) net.Conn
+
+// Compress returns a ConnWrapper that compresses the stream of messages
+// of connections with zlib at the given level, e.g. zlib.BestSpeed.
+// Each message written is flushed on its own, so the peer can decode
+// it right away.
+//
+// Compression is not part of RFC 6733, and both peers must agree on it
+// out-of-band, e.g. on lab links with huge Subscription-Data payloads.
+// Peers that do not use it fail to read the first message.
+//
+// Example:
+//
+//	srv := &diam.Server{Handler: mux, WrapConn: diam.Compress(zlib.BestSpeed)}
+func Compress(level int) ConnWrapper {
+	return func(c net.Conn) net.Conn {
+		return &compressedConn{Conn: c, level: level}
+	}
+}
+
+// compressedConn is a net.Conn with a zlib compressed stream. The
+// reader and writer are created on first use, so wrapping does not
+// block on the peer.
+type compressedConn struct {
+	net.Conn
+	level int
+
+	rmu sync.Mutex
+	r   io.ReadCloser
+
+	wmu sync.Mutex
+	w   *zlib.Writer
+}
+
+func (c *compressedConn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+	if c.r == nil {
+		r, err := zlib.NewReader(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		c.r = r
+	}
+	return c.r.Read(b)
+}
+
+func (c *compressedConn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if c.w == nil {
+		w, err := zlib.NewWriterLevel(c.Conn, c.level)
+		if err != nil {
+			return 0, err
+		}
+		c.w = w
+	}
+	n, err := c.w.Write(b)
+	if err != nil {
+		return n, err
+	}
+	return n, c.w.Flush()
+}
@@ -0,0 +1,84 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"compress/zlib"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+// countingConn counts the bytes read from the network.
+type countingConn struct {
+	net.Conn
+	n *int64
+}
+
+func (c countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddInt64(c.n, int64(n))
+	return n, err
+}
+
+func TestCompress(t *testing.T) {
+	state := strings.Repeat("subscription-data", 4096)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+		a.NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString(state))
+		a.WriteTo(c)
+	})
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.WrapConn = diam.Compress(zlib.BestSpeed)
+	srv.Start()
+	defer srv.Close()
+
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	var read int64
+	compress := diam.Compress(zlib.BestSpeed)
+	cs := &diam.Server{Addr: srv.Addr, Handler: cmux, WrapConn: func(c net.Conn) net.Conn {
+		return compress(countingConn{c, &read})
+	}}
+	cli, err := cs.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-mc:
+		ps, err := a.FindAVP(avp.ProxyState, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := ps.Data.(datatype.OctetString); string(v) != state {
+			t.Fatalf("Unexpected Proxy-State of %d bytes", len(v))
+		}
+		if n := atomic.LoadInt64(&read); n >= int64(a.Len()) {
+			t.Fatalf("Answer was not compressed: read %d bytes of %d", n, a.Len())
+		}
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: no DWA received")
+	}
+}
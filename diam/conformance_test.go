@@ -0,0 +1,523 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"encoding/hex"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// Wire conformance vectors.
+//
+// The expected bytes below are golden: they were written from the
+// encoding rules of RFC 6733, independently of this package, and are
+// meant to be cross-checked with a protocol analyzer such as Wireshark.
+// If a codec change breaks one of these tests, the change is wrong,
+// not the vector.
+
+// conformanceDictXML defines vendor-specific AVPs of every data type,
+// and nested grouped AVPs, for the AVP vectors.
+var conformanceDictXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+  <application id="0">
+    <avp name="Test-Address" code="9001" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Address"/>
+    </avp>
+    <avp name="Test-DiameterIdentity" code="9002" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="DiameterIdentity"/>
+    </avp>
+    <avp name="Test-DiameterURI" code="9003" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="DiameterURI"/>
+    </avp>
+    <avp name="Test-Enumerated" code="9004" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Enumerated"/>
+    </avp>
+    <avp name="Test-Float32" code="9005" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Float32"/>
+    </avp>
+    <avp name="Test-Float64" code="9006" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Float64"/>
+    </avp>
+    <avp name="Test-Grouped" code="9007" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Grouped">
+        <rule avp="Test-Unsigned32" required="false"/>
+        <rule avp="Test-Nested" required="false"/>
+      </data>
+    </avp>
+    <avp name="Test-IPFilterRule" code="9008" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="IPFilterRule"/>
+    </avp>
+    <avp name="Test-IPv4" code="9009" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="IPv4"/>
+    </avp>
+    <avp name="Test-Integer32" code="9010" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Integer32"/>
+    </avp>
+    <avp name="Test-Integer64" code="9011" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Integer64"/>
+    </avp>
+    <avp name="Test-OctetString" code="9012" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="OctetString"/>
+    </avp>
+    <avp name="Test-QoSFilterRule" code="9013" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="QoSFilterRule"/>
+    </avp>
+    <avp name="Test-Time" code="9014" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Time"/>
+    </avp>
+    <avp name="Test-UTF8String" code="9015" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="UTF8String"/>
+    </avp>
+    <avp name="Test-Unsigned32" code="9016" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Unsigned32"/>
+    </avp>
+    <avp name="Test-Unsigned64" code="9017" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Unsigned64"/>
+    </avp>
+    <avp name="Test-Nested" code="9018" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+      <data type="Grouped">
+        <rule avp="Test-UTF8String" required="false"/>
+      </data>
+    </avp>
+  </application>
+</diameter>`
+
+var conformanceAVPs = []struct {
+	name string
+	avp  *AVP
+	wire string
+}{
+	{
+		"Address/IPv4",
+		NewAVP(9001, avp.Mbit, 10415, datatype.Address(net.ParseIP("192.168.1.1"))),
+		"00002329c0000012000028af0001c0a801010000",
+	},
+	{
+		"Address/IPv6",
+		NewAVP(9001, avp.Mbit, 10415, datatype.Address(net.ParseIP("2001:db8::1"))),
+		"00002329c000001e000028af000220010db80000000000000000000000010000",
+	},
+	{
+		"DiameterIdentity",
+		NewAVP(9002, avp.Mbit, 10415, datatype.DiameterIdentity("host.example.com")),
+		"0000232ac000001c000028af686f73742e6578616d706c652e636f6d",
+	},
+	{
+		"DiameterURI",
+		NewAVP(9003, avp.Mbit, 10415, datatype.DiameterURI("aaa://host.example.com:3868")),
+		"0000232bc0000027000028af6161613a2f2f686f73742e6578616d706c652e636f6d3a3338363800",
+	},
+	{
+		"Enumerated",
+		NewAVP(9004, avp.Mbit, 10415, datatype.Enumerated(1)),
+		"0000232cc0000010000028af00000001",
+	},
+	{
+		"Float32",
+		NewAVP(9005, avp.Mbit, 10415, datatype.Float32(1.5)),
+		"0000232dc0000010000028af3fc00000",
+	},
+	{
+		"Float64",
+		NewAVP(9006, avp.Mbit, 10415, datatype.Float64(1.5)),
+		"0000232ec0000014000028af3ff8000000000000",
+	},
+	{
+		"IPFilterRule",
+		NewAVP(9008, avp.Mbit, 10415, datatype.IPFilterRule("permit in ip from any to any")),
+		"00002330c0000028000028af7065726d697420696e2069702066726f6d20616e7920746f20616e79",
+	},
+	{
+		"IPv4",
+		NewAVP(9009, avp.Mbit, 10415, datatype.IPv4(net.ParseIP("10.0.0.1"))),
+		"00002331c0000010000028af0a000001",
+	},
+	{
+		"Integer32",
+		NewAVP(9010, avp.Mbit, 10415, datatype.Integer32(-1)),
+		"00002332c0000010000028afffffffff",
+	},
+	{
+		"Integer64",
+		NewAVP(9011, avp.Mbit, 10415, datatype.Integer64(-2)),
+		"00002333c0000014000028affffffffffffffffe",
+	},
+	{
+		"OctetString",
+		NewAVP(9012, avp.Mbit, 10415, datatype.OctetString("\x01\x02\x03")),
+		"00002334c000000f000028af01020300",
+	},
+	{
+		"QoSFilterRule",
+		NewAVP(9013, avp.Mbit, 10415, datatype.QoSFilterRule("permit out ip from any to any")),
+		"00002335c0000029000028af7065726d6974206f75742069702066726f6d20616e7920746f20616e79000000",
+	},
+	{
+		"Time",
+		NewAVP(9014, avp.Mbit, 10415, datatype.Time(time.Unix(1420070400, 0))),
+		"00002336c0000010000028afd84f0c80",
+	},
+	{
+		"UTF8String",
+		NewAVP(9015, avp.Mbit, 10415, datatype.UTF8String("héllo")),
+		"00002337c0000012000028af68c3a96c6c6f0000",
+	},
+	{
+		"Unsigned32",
+		NewAVP(9016, avp.Mbit, 10415, datatype.Unsigned32(0xdeadbeef)),
+		"00002338c0000010000028afdeadbeef",
+	},
+	{
+		"Unsigned64",
+		NewAVP(9017, avp.Mbit, 10415, datatype.Unsigned64(1<<40)),
+		"00002339c0000014000028af0000010000000000",
+	},
+	{
+		"Grouped",
+		NewAVP(9007, avp.Mbit, 10415, &GroupedAVP{
+			AVP: []*AVP{
+				NewAVP(9016, avp.Mbit, 10415, datatype.Unsigned32(1)),
+				NewAVP(9018, avp.Mbit, 10415, &GroupedAVP{
+					AVP: []*AVP{
+						NewAVP(9015, avp.Mbit, 10415, datatype.UTF8String("nested")),
+					},
+				}),
+			},
+		}),
+		"0000232fc000003c000028af" + // Test-Grouped header
+			"00002338c0000010000028af00000001" + // Unsigned32
+			"0000233ac0000020000028af" + // Test-Nested header
+			"00002337c0000012000028af6e65737465640000", // UTF8String
+	},
+}
+
+var conformanceMessages = []struct {
+	name string
+	msg  func() *Message
+	wire string
+}{
+	{
+		"CER",
+		func() *Message {
+			m := NewMessage(CapabilitiesExchange, RequestFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP("127.0.0.1")))
+			m.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415))
+			m.NewAVP(avp.ProductName, 0, 0, datatype.UTF8String("go-diameter"))
+			m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
+			m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+			m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &GroupedAVP{
+				AVP: []*AVP{
+					NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+					NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(16777238)),
+				},
+			})
+			return m
+		},
+		"010000ac80000101000000001122334455667788" + // Header
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"000001014000000e00017f0000010000" + // Host-IP-Address
+			"0000010a4000000c000028af" + // Vendor-Id
+			"0000010d00000013676f2d6469616d6574657200" + // Product-Name
+			"000001164000000c00000001" + // Origin-State-Id
+			"000001024000000c00000004" + // Auth-Application-Id
+			"00000104400000200000010a4000000c000028af000001024000000c01000016", // Vendor-Specific-Application-Id
+	},
+	{
+		"CEA",
+		func() *Message {
+			m := NewMessage(CapabilitiesExchange, 0, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP("127.0.0.2")))
+			m.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415))
+			m.NewAVP(avp.ProductName, 0, 0, datatype.UTF8String("go-diameter"))
+			m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+			return m
+		},
+		"0100008c00000101000000001122334455667788" + // Header
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"000001014000000e00017f0000020000" + // Host-IP-Address
+			"0000010a4000000c000028af" + // Vendor-Id
+			"0000010d00000013676f2d6469616d6574657200" + // Product-Name
+			"000001024000000c00000004", // Auth-Application-Id
+	},
+	{
+		"DWR",
+		func() *Message {
+			m := NewMessage(DeviceWatchdog, RequestFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
+			return m
+		},
+		"0100005080000118000000001122334455667788" + // Header
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"000001164000000c00000001", // Origin-State-Id
+	},
+	{
+		"DWA",
+		func() *Message {
+			m := NewMessage(DeviceWatchdog, 0, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			return m
+		},
+		"0100005000000118000000001122334455667788" + // Header
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00", // Origin-Realm
+	},
+	{
+		"DPR",
+		func() *Message {
+			m := NewMessage(DisconnectPeer, RequestFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, datatype.Enumerated(0))
+			return m
+		},
+		"010000508000011a000000001122334455667788" + // Header
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"000001114000000c00000000", // Disconnect-Cause
+	},
+	{
+		"DPA",
+		func() *Message {
+			m := NewMessage(DisconnectPeer, 0, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			return m
+		},
+		"010000500000011a000000001122334455667788" + // Header
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00", // Origin-Realm
+	},
+	{
+		"ACR",
+		func() *Message {
+			m := NewMessage(Accounting, RequestFlag|ProxiableFlag, 3, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.AccountingRecordType, avp.Mbit, 0, datatype.Enumerated(2))
+			m.NewAVP(avp.AccountingRecordNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+			m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(3))
+			return m
+		},
+		"0100009cc000010f000000031122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"0000011b400000136578616d706c652e636f6d00" + // Destination-Realm
+			"000001e04000000c00000002" + // Accounting-Record-Type
+			"000001e54000000c00000000" + // Accounting-Record-Number
+			"000001034000000c00000003", // Acct-Application-Id
+	},
+	{
+		"ACA",
+		func() *Message {
+			m := NewMessage(Accounting, ProxiableFlag, 3, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.AccountingRecordType, avp.Mbit, 0, datatype.Enumerated(2))
+			m.NewAVP(avp.AccountingRecordNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+			return m
+		},
+		"010000884000010f000000031122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"000001e04000000c00000002" + // Accounting-Record-Type
+			"000001e54000000c00000000", // Accounting-Record-Number
+	},
+	{
+		"RAR",
+		func() *Message {
+			m := NewMessage(ReAuth, RequestFlag|ProxiableFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+			m.NewAVP(avp.ReAuthRequestType, avp.Mbit, 0, datatype.Enumerated(0))
+			return m
+		},
+		"010000acc0000102000000001122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"0000011b400000136578616d706c652e636f6d00" + // Destination-Realm
+			"000001254000001a7365727665722e6578616d706c652e636f6d0000" + // Destination-Host
+			"000001024000000c00000004" + // Auth-Application-Id
+			"0000011d4000000c00000000", // Re-Auth-Request-Type
+	},
+	{
+		"RAA",
+		func() *Message {
+			m := NewMessage(ReAuth, ProxiableFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			return m
+		},
+		"0100007040000102000000001122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00", // Origin-Realm
+	},
+	{
+		"ASR",
+		func() *Message {
+			m := NewMessage(AbortSession, RequestFlag|ProxiableFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+			return m
+		},
+		"010000a0c0000112000000001122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"0000011b400000136578616d706c652e636f6d00" + // Destination-Realm
+			"000001254000001a7365727665722e6578616d706c652e636f6d0000" + // Destination-Host
+			"000001024000000c00000004", // Auth-Application-Id
+	},
+	{
+		"ASA",
+		func() *Message {
+			m := NewMessage(AbortSession, ProxiableFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			return m
+		},
+		"0100007040000112000000001122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00", // Origin-Realm
+	},
+	{
+		"STR",
+		func() *Message {
+			m := NewMessage(SessionTermination, RequestFlag|ProxiableFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("client.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+			m.NewAVP(avp.TerminationCause, avp.Mbit, 0, datatype.Enumerated(1))
+			return m
+		},
+		"01000090c0000113000000001122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"000001084000001a636c69656e742e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00" + // Origin-Realm
+			"0000011b400000136578616d706c652e636f6d00" + // Destination-Realm
+			"000001024000000c00000004" + // Auth-Application-Id
+			"000001274000000c00000001", // Termination-Cause
+	},
+	{
+		"STA",
+		func() *Message {
+			m := NewMessage(SessionTermination, ProxiableFlag, 0, 0x11223344, 0x55667788, dict.Default)
+			m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("client.example.com;1;2"))
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(Success))
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("server.example.com"))
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+			return m
+		},
+		"0100007040000113000000001122334455667788" + // Header
+			"000001074000001e636c69656e742e6578616d706c652e636f6d3b313b320000" + // Session-Id
+			"0000010c4000000c000007d1" + // Result-Code
+			"000001084000001a7365727665722e6578616d706c652e636f6d0000" + // Origin-Host
+			"00000128400000136578616d706c652e636f6d00", // Origin-Realm
+	},
+}
+
+func TestConformanceAVP(t *testing.T) {
+	dp, err := dict.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dp.Load(bytes.NewReader([]byte(conformanceDictXML))); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range conformanceAVPs {
+		want, _ := hex.DecodeString(v.wire)
+		have, err := v.avp.Serialize()
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if !bytes.Equal(want, have) {
+			t.Fatalf("%s: Unexpected encoding.\nWant: %x\nHave: %x", v.name, want, have)
+		}
+		a, err := DecodeAVP(want, 0, dp)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if !a.Equal(v.avp) {
+			t.Fatalf("%s: Unexpected decoding.\nWant: %s\nHave: %s", v.name, v.avp, a)
+		}
+		if have, _ = a.Serialize(); !bytes.Equal(want, have) {
+			t.Fatalf("%s: Unexpected re-encoding.\nWant: %x\nHave: %x", v.name, want, have)
+		}
+	}
+}
+
+func TestConformanceMessage(t *testing.T) {
+	for _, v := range conformanceMessages {
+		want, _ := hex.DecodeString(v.wire)
+		m := v.msg()
+		have, err := m.Serialize()
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if !bytes.Equal(want, have) {
+			t.Fatalf("%s: Unexpected encoding.\nWant:\n%sHave:\n%s",
+				v.name, hex.Dump(want), hex.Dump(have))
+		}
+		rm, err := ReadMessage(bytes.NewReader(want), dict.Default)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if !rm.Equal(m) {
+			t.Fatalf("%s: Unexpected decoding.\nWant:\n%s\nHave:\n%s", v.name, m, rm)
+		}
+		if have, _ = rm.Serialize(); !bytes.Equal(want, have) {
+			t.Fatalf("%s: Unexpected re-encoding.\nWant:\n%sHave:\n%s",
+				v.name, hex.Dump(want), hex.Dump(have))
+		}
+	}
+}
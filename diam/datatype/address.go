@@ -31,7 +31,8 @@ func DecodeAddress(b []byte) (Type, error) {
 	default:
 		return nil, fmt.Errorf("Unsupported address family: 0x%x", b[:2])
 	}
-	return Address(b[2:]), nil
+	// Copy, the read buffer of b is reused.
+	return Address(append([]byte(nil), b[2:]...)), nil
 }
 
 // Serialize implements the Type interface.
@@ -36,6 +36,7 @@ const (
 	UTF8StringType
 	Unsigned32Type
 	Unsigned64Type
+	RawType
 )
 
 // Available is a map of data types available, indexed by name.
@@ -0,0 +1,53 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package datatype
+
+import "fmt"
+
+// Raw data type holds the undecoded payload of an AVP, as received.
+//
+// It is used by relays and proxies for AVPs they do not understand,
+// which must be forwarded bit-exact rather than re-encoded from a
+// typed value. Raw is not a data type of the dictionary and has no
+// entry in Decoder.
+type Raw struct {
+	Flags   uint8  // AVP flags as received
+	Payload []byte // AVP data, without header and padding
+}
+
+// DecodeRaw decodes a Raw data type from byte array.
+// The payload is copied, and flags are left for the caller to set.
+func DecodeRaw(b []byte) (Type, error) {
+	p := make([]byte, len(b))
+	copy(p, b)
+	return Raw{Payload: p}, nil
+}
+
+// Serialize implements the Type interface.
+func (r Raw) Serialize() []byte {
+	return r.Payload
+}
+
+// Len implements the Type interface.
+func (r Raw) Len() int {
+	return len(r.Payload)
+}
+
+// Padding implements the Type interface.
+func (r Raw) Padding() int {
+	l := len(r.Payload)
+	return pad4(l) - l
+}
+
+// Type implements the Type interface.
+func (r Raw) Type() TypeID {
+	return RawType
+}
+
+// String implements the Type interface.
+func (r Raw) String() string {
+	return fmt.Sprintf("Raw{%#x},Flags:0x%x,Padding:%d",
+		r.Payload, r.Flags, r.Padding())
+}
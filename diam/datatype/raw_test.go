@@ -0,0 +1,32 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package datatype
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRaw(t *testing.T) {
+	b := []byte{0x01, 0x02, 0x03, 0x04, 0x05}
+	r, err := DecodeRaw(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[0] = 0xff
+	if v := r.Serialize(); !bytes.Equal(v, []byte{0x01, 0x02, 0x03, 0x04, 0x05}) {
+		t.Fatalf("Unexpected value. Want 0x0102030405, have %#x", v)
+	}
+	if r.Len() != 5 {
+		t.Fatalf("Unexpected len. Want 5, have %d", r.Len())
+	}
+	if r.Padding() != 3 {
+		t.Fatalf("Unexpected padding. Want 3, have %d", r.Padding())
+	}
+	if r.Type() != RawType {
+		t.Fatalf("Unexpected type. Want %d, have %d", RawType, r.Type())
+	}
+	t.Log(r)
+}
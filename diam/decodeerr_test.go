@@ -0,0 +1,215 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// readRawAnswer reads a message from r without decoding its AVPs, and
+// returns its header, Result-Code and the codes of its top level AVPs.
+func readRawAnswer(t *testing.T, r io.Reader) (*diam.Header, uint32, []uint32) {
+	b := make([]byte, diam.HeaderLength)
+	if _, err := io.ReadFull(r, b); err != nil {
+		t.Fatal(err)
+	}
+	h, err := diam.DecodeHeader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = make([]byte, h.MessageLength-diam.HeaderLength)
+	if _, err = io.ReadFull(r, b); err != nil {
+		t.Fatal(err)
+	}
+	var rc uint32
+	var codes []uint32
+	for len(b) >= 12 {
+		code := binary.BigEndian.Uint32(b[0:4])
+		l := int(binary.BigEndian.Uint32(b[4:8]) & 0xffffff)
+		if code == avp.ResultCode {
+			rc = binary.BigEndian.Uint32(b[8:12])
+		}
+		codes = append(codes, code)
+		b = b[(l+3)/4*4:]
+	}
+	return h, rc, codes
+}
+
+func TestServer_AnswerDecodeErrors(t *testing.T) {
+	for i, test := range []struct {
+		msg    []byte
+		code   uint32
+		flags  uint8
+		failed bool
+	}{
+		// Host-IP-Address with 1 byte of data.
+		{
+			rawMessage(diam.CapabilitiesExchange, []byte{0, 0, 1, 1, 0x40, 0, 0, 9, 1, 0, 0, 0}),
+			diam.InvalidAVPValue, 0, true,
+		},
+		// AVP with a length shorter than its header.
+		{
+			rawMessage(diam.CapabilitiesExchange, []byte{0, 0, 1, 8, 0x40, 0, 0, 4}),
+			diam.InvalidAVPLenght, 0, true,
+		},
+		// AVP unknown to the dictionary.
+		{
+			rawMessage(diam.CapabilitiesExchange, []byte{0, 0, 0x23, 0x28, 0x40, 0, 0, 12, 0, 0, 0, 1}),
+			diam.AVPUnsupported, 0, true,
+		},
+		{rawMessage(9999, nil), diam.CommandUnsupported, diam.ErrorFlag, false},
+	} {
+		mc := make(chan *diam.Message, 1)
+		smux := diam.NewServeMux()
+		smux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+			mc <- m
+		})
+		srv := diamtest.NewUnstartedServer(smux, nil)
+		srv.Config.AnswerDecodeErrors = true
+		srv.Start()
+		cli, err := net.Dial("tcp", srv.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = cli.Write(test.msg); err != nil {
+			t.Fatal(err)
+		}
+		cli.SetReadDeadline(time.Now().Add(time.Second))
+		h, rc, codes := readRawAnswer(t, cli)
+		if rc != test.code || h.CommandFlags != test.flags {
+			t.Fatalf("Test %d: unexpected answer with Result-Code %d and flags %#x",
+				i, rc, h.CommandFlags)
+		}
+		var failed bool
+		for _, code := range codes {
+			failed = failed || code == avp.FailedAVP
+		}
+		if failed != test.failed {
+			t.Fatalf("Test %d: unexpected Failed-AVP in %v", i, codes)
+		}
+		select {
+		case err := <-smux.ErrorReports():
+			if err.Error == nil {
+				t.Fatalf("Test %d: unexpected error report", i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Test %d: timed out waiting for error report", i)
+		}
+		// The connection survives the error.
+		if _, err = sendCER(cli); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-mc:
+		case <-time.After(time.Second):
+			t.Fatalf("Test %d: timed out waiting for CER", i)
+		}
+		cli.Close()
+		srv.Close()
+	}
+}
+
+func TestServer_AnswerDecodeErrorsFraming(t *testing.T) {
+	srv := diamtest.NewUnstartedServer(diam.NewServeMux(), nil)
+	srv.Config.AnswerDecodeErrors = true
+	srv.Start()
+	defer srv.Close()
+	cli, err := net.Dial("tcp", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	// Message length shorter than the header.
+	b := rawMessage(diam.CapabilitiesExchange, nil)
+	b[3] = 8
+	if _, err = cli.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	cli.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = cli.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Unexpected error: %v, want EOF", err)
+	}
+}
+
+const lengthDictXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="16777003">
+		<command code="16777210" short="LT" name="Length-Test">
+			<request><rule avp="Test-IMSI" required="false" max="1"/></request>
+			<answer><rule avp="Test-IMSI" required="false" max="1"/></answer>
+		</command>
+		<avp name="Test-IMSI" code="9100" must="M" may="P" must-not="V" may-encrypt="N">
+			<data type="UTF8String" min-length="6" max-length="15"/>
+		</avp>
+	</application>
+</diameter>`
+
+func TestAVPLengthLimits(t *testing.T) {
+	d, err := dict.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = d.Load(strings.NewReader(lengthDictXML)); err != nil {
+		t.Fatal(err)
+	}
+	if !d.HasLengthLimits() {
+		t.Fatal("Length limits were not loaded")
+	}
+	// Encode.
+	m := diam.NewRequest(16777210, 16777003, d)
+	for _, v := range []datatype.UTF8String{"00101", "0010101234567890"} {
+		_, err = m.NewAVP(9100, avp.Mbit, 0, v)
+		if _, ok := err.(*diam.AVPLengthError); !ok {
+			t.Fatalf("Unexpected error for %q: %v", v, err)
+		}
+	}
+	if _, err = m.NewAVPByName("Test-IMSI", datatype.UTF8String("00101")); err == nil {
+		t.Fatal("Truncated IMSI was added by name")
+	}
+	if _, err = m.NewAVP(9100, avp.Mbit, 0, datatype.UTF8String("001010123456789")); err != nil {
+		t.Fatal(err)
+	}
+	// Decode.
+	m = diam.NewRequest(16777210, 16777003, d)
+	m.AddAVP(diam.NewAVP(9100, avp.Mbit, 0, datatype.UTF8String("00101")))
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = diam.ReadMessage(bytes.NewReader(b), d)
+	de, ok := err.(*diam.AVPDecodeError)
+	if !ok {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if de.ResultCode != diam.InvalidAVPLenght {
+		t.Fatalf("Unexpected result code. Want %d, have %d", diam.InvalidAVPLenght, de.ResultCode)
+	}
+	if le, ok := de.Err.(*diam.AVPLengthError); !ok || le.Length != 5 || le.Min != 6 {
+		t.Fatalf("Unexpected decode error: %#v", de.Err)
+	}
+	// Invalid limits.
+	for _, data := range []string{
+		`<data type="Unsigned32" max-length="4"/>`,
+		`<data type="OctetString" min-length="8" max-length="4"/>`,
+	} {
+		err = d.Load(strings.NewReader(strings.Replace(lengthDictXML,
+			`<data type="UTF8String" min-length="6" max-length="15"/>`, data, 1)))
+		if err == nil {
+			t.Fatalf("Invalid limits were loaded: %s", data)
+		}
+	}
+}
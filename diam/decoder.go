@@ -0,0 +1,102 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// AVPCountError is returned when decoding messages with more AVPs than
+// allowed, see Server.MaxAVPs.
+type AVPCountError struct {
+	Max int // Maximum number of AVPs allowed
+}
+
+// Error implements the error interface.
+func (e *AVPCountError) Error() string {
+	return fmt.Sprintf("message exceeds the limit of %d AVPs", e.Max)
+}
+
+// AVPDecodeError is returned when decoding messages with an AVP that
+// cannot be decoded, see Server.AnswerDecodeErrors.
+type AVPDecodeError struct {
+	// ResultCode to answer requests with: DIAMETER_INVALID_AVP_LENGTH
+	// (5014), DIAMETER_INVALID_AVP_VALUE (5004) or, for AVPs unknown
+	// to the dictionary, DIAMETER_AVP_UNSUPPORTED (5001).
+	ResultCode uint32
+
+	// FailedAVP is the AVP that failed to decode, with the data
+	// received as datatype.Raw, for the Failed-AVP of answers.
+	FailedAVP *AVP
+
+	Err error // Error of the decoder
+}
+
+// Error implements the error interface.
+func (e *AVPDecodeError) Error() string {
+	return fmt.Sprintf("Failed to decode AVP: %s", e.Err)
+}
+
+// AVPLengthError is returned when the data of an AVP is shorter or
+// longer than the limits of its dictionary, see dict.Data.ValidLength.
+// AVPs received with such data fail to decode with
+// DIAMETER_INVALID_AVP_LENGTH (5014).
+type AVPLengthError struct {
+	Code     uint32 // Code of the AVP
+	VendorID uint32 // Vendor id of the AVP
+	Length   int    // Length of the data, in octets
+	Min      int    // Minimum length of the dictionary
+	Max      int    // Maximum length of the dictionary, or zero
+}
+
+// Error implements the error interface.
+func (e *AVPLengthError) Error() string {
+	return fmt.Sprintf("length %d of AVP %d (vendor %d) is out of the range %d-%d",
+		e.Length, e.Code, e.VendorID, e.Min, e.Max)
+}
+
+// newAVPDecodeError returns an *AVPDecodeError for the AVP a, whose
+// header is decoded, with the given payload.
+func newAVPDecodeError(code uint32, a *AVP, payload []byte, err error) *AVPDecodeError {
+	failed := NewAVP(a.Code, a.Flags, a.VendorID, datatype.Raw{
+		Flags:   a.Flags,
+		Payload: append([]byte(nil), payload...),
+	})
+	return &AVPDecodeError{ResultCode: code, FailedAVP: failed, Err: err}
+}
+
+// avpDecoder holds the state of decoding the AVPs of a message: the
+// arena AVPs are allocated in, and the count of AVPs decoded.
+//
+// Methods of a nil *avpDecoder allocate from the heap without limits.
+type avpDecoder struct {
+	arena *avpArena // or nil
+	max   int       // maximum number of AVPs, or zero
+	count int       // AVPs decoded, top level and embedded
+}
+
+// newAVP returns a new zero AVP, or an *AVPCountError after max AVPs.
+func (d *avpDecoder) newAVP() (*AVP, error) {
+	if d == nil {
+		return &AVP{}, nil
+	}
+	if d.max > 0 {
+		if d.count == d.max {
+			return nil, &AVPCountError{Max: d.max}
+		}
+		d.count++
+	}
+	return d.arena.newAVP(), nil
+}
+
+// allocator returns the arena of the decoder, or nil.
+func (d *avpDecoder) allocator() *avpArena {
+	if d == nil {
+		return nil
+	}
+	return d.arena
+}
@@ -0,0 +1,44 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestReadMessage_MaxAVPs(t *testing.T) {
+	// 1 top level AVP plus 10 grouped AVPs with 2 AVPs each.
+	b := groupedMessage(t, 10)
+	for _, test := range []struct {
+		mode decodeMode
+		max  int
+		ok   bool
+	}{
+		{0, 31, true},
+		{0, 30, false},
+		{decodeArena, 30, false},
+		{decodeLazy, 11, true},
+		{decodeLazy, 10, false},
+	} {
+		buf := newReaderBuffer()
+		_, err := readMessageBuffer(bytes.NewReader(b), dict.Default, test.mode, buf, test.max)
+		if test.ok {
+			if err != nil {
+				t.Fatalf("Unexpected error with mode %d and max %d: %v", test.mode, test.max, err)
+			}
+			continue
+		}
+		de, ok := err.(*decodeError)
+		if !ok {
+			t.Fatalf("Unexpected error with mode %d and max %d: %v", test.mode, test.max, err)
+		}
+		if cerr, ok := de.err.(*AVPCountError); !ok || cerr.Max != test.max {
+			t.Fatalf("Unexpected error with mode %d and max %d: %v", test.mode, test.max, de.err)
+		}
+	}
+}
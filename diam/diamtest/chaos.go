@@ -0,0 +1,248 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// ReorderWindow is the maximum time a message is held back by Chaos
+// to be delivered after the next one.
+const ReorderWindow = 100 * time.Millisecond
+
+// Chaos configures the failures injected by connections wrapped with
+// NewChaosConn or NewChaosListener, to test the retransmission and
+// failover logic of applications.
+//
+// Failures are injected in the messages written to the connection,
+// i.e. for a wrapped server listener, in the messages the server sends
+// to its clients. Probabilities range from 0 (never) to 1 (always).
+//
+// A Chaos may be shared by many connections and must not be modified
+// after use.
+type Chaos struct {
+	Latency time.Duration // Delay added to every message
+	Jitter  time.Duration // Maximum random delay added to Latency
+
+	DropRequests float64 // Probability of dropping requests
+	DropAnswers  float64 // Probability of dropping answers
+
+	// CorruptAVPs is the probability of setting the length of the
+	// first AVP of messages beyond the end of the message, so the
+	// peer fails to decode them.
+	CorruptAVPs float64
+
+	// Reorder is the probability of holding a message back and
+	// delivering it after the next one, or after ReorderWindow.
+	Reorder float64
+
+	// Seed initializes the random source, for reproducible runs.
+	Seed int64
+
+	mu  sync.Mutex
+	rnd *rand.Rand
+}
+
+// roll returns true with probability p.
+func (ch *Chaos) roll(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	if p >= 1 {
+		return true
+	}
+	return ch.float64() < p
+}
+
+// delay returns the delay of the next message.
+func (ch *Chaos) delay() time.Duration {
+	d := ch.Latency
+	if ch.Jitter > 0 {
+		d += time.Duration(ch.float64() * float64(ch.Jitter))
+	}
+	return d
+}
+
+func (ch *Chaos) float64() float64 {
+	ch.mu.Lock()
+	defer ch.mu.Unlock()
+	if ch.rnd == nil {
+		ch.rnd = rand.New(rand.NewSource(ch.Seed))
+	}
+	return ch.rnd.Float64()
+}
+
+// NewChaosListener returns a listener whose connections inject the
+// failures configured in ch, e.g. to wrap the Listener of a Server
+// before calling Start:
+//
+//	srv := diamtest.NewUnstartedServer(mux, nil)
+//	srv.Listener = diamtest.NewChaosListener(srv.Listener, &diamtest.Chaos{
+//		DropAnswers: 0.1,
+//	})
+//	srv.Start()
+func NewChaosListener(l net.Listener, ch *Chaos) net.Listener {
+	return &chaosListener{Listener: l, chaos: ch}
+}
+
+type chaosListener struct {
+	net.Listener
+	chaos *Chaos
+}
+
+func (l *chaosListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return NewChaosConn(c, l.chaos), nil
+}
+
+// NewChaosConn returns a connection that injects the failures
+// configured in ch in the Diameter messages written to c.
+//
+// Writes return before messages are written to c, and write errors
+// close the connection.
+func NewChaosConn(c net.Conn, ch *Chaos) net.Conn {
+	cc := &chaosConn{
+		Conn:  c,
+		chaos: ch,
+		queue: make(chan delayed, 1024),
+		done:  make(chan struct{}),
+	}
+	go cc.writeLoop()
+	return cc
+}
+
+type delayed struct {
+	b   []byte
+	due time.Time
+}
+
+type chaosConn struct {
+	net.Conn
+	chaos *Chaos
+
+	mu   sync.Mutex // guards buf, held and gen
+	buf  []byte     // partial message written
+	held []byte     // message held back for reordering
+	gen  int        // increments when held is delivered
+
+	queue     chan delayed
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func (c *chaosConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.done:
+		return 0, diam.ErrConnClosed
+	default:
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf = append(c.buf, b...)
+	for len(c.buf) >= diam.HeaderLength {
+		l := int(c.buf[1])<<16 | int(c.buf[2])<<8 | int(c.buf[3])
+		if l < diam.HeaderLength {
+			// Not a Diameter message, pass it through.
+			l = len(c.buf)
+		}
+		if len(c.buf) < l {
+			break
+		}
+		m := make([]byte, l)
+		copy(m, c.buf)
+		c.buf = c.buf[l:]
+		c.inject(m)
+	}
+	if len(c.buf) == 0 {
+		c.buf = nil
+	}
+	return len(b), nil
+}
+
+// inject applies the failures to the message m and queues it.
+// Must be called with the lock held.
+func (c *chaosConn) inject(m []byte) {
+	ch := c.chaos
+	if m[4]&diam.RequestFlag != 0 {
+		if ch.roll(ch.DropRequests) {
+			return
+		}
+	} else if ch.roll(ch.DropAnswers) {
+		return
+	}
+	if len(m) >= diam.HeaderLength+8 && ch.roll(ch.CorruptAVPs) {
+		m[diam.HeaderLength+5] = 0xff
+		m[diam.HeaderLength+6] = 0xff
+		m[diam.HeaderLength+7] = 0xff
+	}
+	if c.held == nil && ch.roll(ch.Reorder) {
+		c.held = m
+		gen := c.gen
+		time.AfterFunc(ReorderWindow, func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			if c.held != nil && c.gen == gen {
+				c.release()
+			}
+		})
+		return
+	}
+	c.enqueue(m)
+	if c.held != nil {
+		c.release()
+	}
+}
+
+// release queues the held message. Must be called with the lock held.
+func (c *chaosConn) release() {
+	c.enqueue(c.held)
+	c.held = nil
+	c.gen++
+}
+
+// enqueue queues m for the write loop. Must be called with the lock
+// held.
+func (c *chaosConn) enqueue(m []byte) {
+	select {
+	case c.queue <- delayed{m, time.Now().Add(c.chaos.delay())}:
+	case <-c.done:
+	}
+}
+
+func (c *chaosConn) writeLoop() {
+	for {
+		select {
+		case d := <-c.queue:
+			if wait := d.due.Sub(time.Now()); wait > 0 {
+				t := time.NewTimer(wait)
+				select {
+				case <-t.C:
+				case <-c.done:
+					t.Stop()
+					return
+				}
+			}
+			if _, err := c.Conn.Write(d.b); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *chaosConn) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.Conn.Close()
+}
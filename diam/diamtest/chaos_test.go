@@ -0,0 +1,111 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// chaosServer starts a server that answers ACR through a listener
+// with the given Chaos, and a client connected to it that sends the
+// answers to the returned channel.
+func chaosServer(t *testing.T, ch *Chaos) (diam.Conn, chan *diam.Message, func()) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("ACR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	srv := NewUnstartedServer(smux, nil)
+	srv.Listener = NewChaosListener(srv.Listener, ch)
+	srv.Start()
+	mc := make(chan *diam.Message, 2)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("ACA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	return cli, mc, func() {
+		cli.Close()
+		srv.Close()
+	}
+}
+
+func sendACR(t *testing.T, c diam.Conn) *diam.Message {
+	m := diam.NewRequest(diam.Accounting, 3, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err := m.WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestChaos_Latency(t *testing.T) {
+	cli, mc, done := chaosServer(t, &Chaos{Latency: 100 * time.Millisecond})
+	defer done()
+	start := time.Now()
+	sendACR(t, cli)
+	select {
+	case <-mc:
+		if d := time.Since(start); d < 100*time.Millisecond {
+			t.Fatalf("Unexpected latency. Want at least 100ms, have %s", d)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for ACA")
+	}
+}
+
+func TestChaos_DropAnswers(t *testing.T) {
+	cli, mc, done := chaosServer(t, &Chaos{DropAnswers: 1})
+	defer done()
+	sendACR(t, cli)
+	select {
+	case <-mc:
+		t.Fatal("Unexpected ACA")
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestChaos_CorruptAVPs(t *testing.T) {
+	cli, mc, done := chaosServer(t, &Chaos{CorruptAVPs: 1})
+	defer done()
+	sendACR(t, cli)
+	select {
+	case <-mc:
+		t.Fatal("Unexpected ACA")
+	case <-cli.Done():
+		if err := cli.Err(); err == nil || err == io.EOF {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the connection to close")
+	}
+}
+
+func TestChaos_Reorder(t *testing.T) {
+	cli, mc, done := chaosServer(t, &Chaos{Reorder: 1})
+	defer done()
+	first := sendACR(t, cli)
+	second := sendACR(t, cli)
+	for _, want := range []*diam.Message{second, first} {
+		select {
+		case a := <-mc:
+			if a.Header.HopByHopID != want.Header.HopByHopID {
+				t.Fatalf("Unexpected Hop-by-Hop ID. Want %#x, have %#x",
+					want.Header.HopByHopID, a.Header.HopByHopID)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for ACA")
+		}
+	}
+}
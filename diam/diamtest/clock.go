@@ -0,0 +1,153 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// FakeClock is a diam.Clock whose time only moves forward when
+// advanced with Advance, firing the timers that expire, so tests of
+// timer-heavy components run instantly and deterministically.
+type FakeClock struct {
+	mu     sync.Mutex
+	cond   *sync.Cond // signals changes of timers
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock returns a FakeClock set at the given time.
+func NewFakeClock(now time.Time) *FakeClock {
+	fc := &FakeClock{now: now}
+	fc.cond = sync.NewCond(&fc.mu)
+	return fc
+}
+
+type fakeTimer struct {
+	fc   *FakeClock
+	when time.Time
+	fire func(now time.Time)
+}
+
+// Now implements the diam.Clock interface.
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+// After implements the diam.Clock interface.
+func (fc *FakeClock) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	fc.add(d, func(now time.Time) { ch <- now })
+	return ch
+}
+
+// AfterFunc implements the diam.Clock interface.
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) diam.Timer {
+	return fc.add(d, func(time.Time) { go f() })
+}
+
+// Advance moves the time of the clock forward by d and fires the
+// timers that expire, in order.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	end := fc.now.Add(d)
+	for {
+		t := fc.next(end)
+		if t == nil {
+			break
+		}
+		fc.remove(t)
+		fc.now = t.when
+		fc.mu.Unlock()
+		t.fire(t.when)
+		fc.mu.Lock()
+	}
+	fc.now = end
+	fc.mu.Unlock()
+}
+
+// Timers returns the number of timers waiting to fire.
+func (fc *FakeClock) Timers() int {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return len(fc.timers)
+}
+
+// BlockUntil blocks until at least n timers are waiting to fire, e.g.
+// to make sure a goroutine under test is waiting for a timer before
+// advancing the clock.
+func (fc *FakeClock) BlockUntil(n int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	for len(fc.timers) < n {
+		fc.cond.Wait()
+	}
+}
+
+func (fc *FakeClock) add(d time.Duration, fire func(time.Time)) *fakeTimer {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	t := &fakeTimer{fc: fc, when: fc.now.Add(d), fire: fire}
+	if d <= 0 {
+		// Fire immediately, like time.After.
+		fire(fc.now)
+		return t
+	}
+	fc.timers = append(fc.timers, t)
+	fc.cond.Broadcast()
+	return t
+}
+
+// next returns the first timer that expires until end, or nil. Must
+// be called with the lock held.
+func (fc *FakeClock) next(end time.Time) *fakeTimer {
+	var first *fakeTimer
+	for _, t := range fc.timers {
+		if t.when.After(end) {
+			continue
+		}
+		if first == nil || t.when.Before(first.when) {
+			first = t
+		}
+	}
+	return first
+}
+
+// remove removes the timer t and returns true if it was waiting. Must
+// be called with the lock held.
+func (fc *FakeClock) remove(t *fakeTimer) bool {
+	for i, v := range fc.timers {
+		if v == t {
+			fc.timers = append(fc.timers[:i], fc.timers[i+1:]...)
+			fc.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+// Stop implements the diam.Timer interface.
+func (t *fakeTimer) Stop() bool {
+	t.fc.mu.Lock()
+	defer t.fc.mu.Unlock()
+	return t.fc.remove(t)
+}
+
+// Reset implements the diam.Timer interface.
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	fc := t.fc
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	active := fc.remove(t)
+	t.when = fc.now.Add(d)
+	fc.timers = append(fc.timers, t)
+	fc.cond.Broadcast()
+	return active
+}
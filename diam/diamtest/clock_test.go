@@ -0,0 +1,49 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Unix(1000, 0)
+	fc := NewFakeClock(start)
+	fired := make(chan int, 3)
+	fc.AfterFunc(2*time.Second, func() { fired <- 2 })
+	stopped := fc.AfterFunc(time.Second, func() { fired <- 0 })
+	ch := fc.After(time.Second)
+	if !stopped.Stop() {
+		t.Fatal("Unexpected inactive timer")
+	}
+	if n := fc.Timers(); n != 2 {
+		t.Fatalf("Unexpected timers. Want 2, have %d", n)
+	}
+	fc.Advance(time.Second)
+	select {
+	case now := <-ch:
+		if want := start.Add(time.Second); !now.Equal(want) {
+			t.Fatalf("Unexpected time. Want %s, have %s", want, now)
+		}
+	default:
+		t.Fatal("After did not fire")
+	}
+	fc.Advance(time.Second)
+	select {
+	case v := <-fired:
+		if v != 2 {
+			t.Fatalf("Unexpected timer fired: %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for AfterFunc")
+	}
+	if now := fc.Now(); !now.Equal(start.Add(2 * time.Second)) {
+		t.Fatalf("Unexpected time: %s", now)
+	}
+	if n := fc.Timers(); n != 0 {
+		t.Fatalf("Unexpected timers. Want 0, have %d", n)
+	}
+}
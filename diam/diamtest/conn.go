@@ -0,0 +1,189 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+// ConnSettings configures a FakeConn. All fields are optional.
+type ConnSettings struct {
+	LocalAddr  net.Addr             // Uses 127.0.0.1:3868 if unset
+	RemoteAddr net.Addr             // Uses 127.0.0.1:49152 if unset
+	TLS        *tls.ConnectionState // Nil for connections without TLS
+	Dict       *dict.Parser         // Uses dict.Default if unset
+	Context    context.Context      // Uses context.Background if unset
+}
+
+// FakeConn is a diam.Conn that captures the messages written to it
+// instead of sending them to a peer, for unit tests of handlers that
+// need no sockets nor a real state machine:
+//
+//	c := diamtest.NewFakeConn(nil, &smpeer.Metadata{OriginHost: "cli"})
+//	handleCCR(c, ccr)
+//	cca := c.Written()[0]
+//
+// Messages written are decoded with the dictionary of the connection.
+type FakeConn struct {
+	local, remote net.Addr
+	tls           *tls.ConnectionState
+	dict          *dict.Parser
+	done          chan struct{}
+
+	mu       sync.Mutex
+	ctx      context.Context
+	err      error // set when closed
+	writeErr error
+	written  []*diam.Message
+	stats    diam.ConnStats
+}
+
+// NewFakeConn returns a FakeConn configured with settings, which may be
+// nil. When meta is not nil, the context of the connection carries it
+// as if the peer passed the CER/CEA handshake, see smpeer.FromContext.
+func NewFakeConn(settings *ConnSettings, meta *smpeer.Metadata) *FakeConn {
+	if settings == nil {
+		settings = &ConnSettings{}
+	}
+	c := &FakeConn{
+		local:  settings.LocalAddr,
+		remote: settings.RemoteAddr,
+		tls:    settings.TLS,
+		dict:   settings.Dict,
+		ctx:    settings.Context,
+		done:   make(chan struct{}),
+	}
+	if c.local == nil {
+		c.local = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 3868}
+	}
+	if c.remote == nil {
+		c.remote = &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 49152}
+	}
+	if c.dict == nil {
+		c.dict = dict.Default
+	}
+	if c.ctx == nil {
+		c.ctx = context.Background()
+	}
+	if meta != nil {
+		c.ctx = smpeer.NewContext(c.ctx, meta)
+	}
+	return c
+}
+
+// Written returns the messages written to the connection so far.
+func (c *FakeConn) Written() []*diam.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msgs := make([]*diam.Message, len(c.written))
+	copy(msgs, c.written)
+	return msgs
+}
+
+// FailWrites makes writes to the connection fail with err from now on,
+// or succeed again when err is nil.
+func (c *FakeConn) FailWrites(err error) {
+	c.mu.Lock()
+	c.writeErr = err
+	c.mu.Unlock()
+}
+
+// Write implements the diam.Conn interface. The bytes must be one or
+// more diameter messages.
+func (c *FakeConn) Write(b []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.writeErr != nil {
+		return 0, c.writeErr
+	}
+	r := bytes.NewReader(b)
+	for r.Len() > 0 {
+		m, err := diam.ReadMessage(r, c.dict)
+		if err != nil {
+			return len(b) - r.Len(), err
+		}
+		c.written = append(c.written, m)
+		c.stats.MessagesOut++
+	}
+	c.stats.BytesOut += uint64(len(b))
+	c.stats.LastWrite = time.Now()
+	return len(b), nil
+}
+
+// SendBatch implements the diam.Conn interface.
+func (c *FakeConn) SendBatch(msgs []*diam.Message) []error {
+	errs := make([]error, len(msgs))
+	for i, m := range msgs {
+		_, errs[i] = m.WriteTo(c)
+	}
+	return errs
+}
+
+// Close implements the diam.Conn interface.
+func (c *FakeConn) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.err == nil {
+		c.err = diam.ErrConnClosed
+		close(c.done)
+	}
+}
+
+// LocalAddr implements the diam.Conn interface.
+func (c *FakeConn) LocalAddr() net.Addr { return c.local }
+
+// RemoteAddr implements the diam.Conn interface.
+func (c *FakeConn) RemoteAddr() net.Addr { return c.remote }
+
+// TLS implements the diam.Conn interface.
+func (c *FakeConn) TLS() *tls.ConnectionState { return c.tls }
+
+// Dictionary implements the diam.Conn interface.
+func (c *FakeConn) Dictionary() *dict.Parser { return c.dict }
+
+// Context implements the diam.Conn interface.
+func (c *FakeConn) Context() context.Context {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ctx
+}
+
+// SetContext implements the diam.Conn interface.
+func (c *FakeConn) SetContext(ctx context.Context) {
+	c.mu.Lock()
+	c.ctx = ctx
+	c.mu.Unlock()
+}
+
+// Done implements the diam.Conn interface.
+func (c *FakeConn) Done() <-chan struct{} { return c.done }
+
+// Err implements the diam.Conn interface.
+func (c *FakeConn) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+// Stats implements the diam.Conn interface. Only the outgoing
+// statistics are tracked.
+func (c *FakeConn) Stats() diam.ConnStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
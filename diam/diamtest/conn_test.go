@@ -0,0 +1,57 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+func TestFakeConn(t *testing.T) {
+	var _ diam.Conn = (*FakeConn)(nil)
+	c := NewFakeConn(nil, &smpeer.Metadata{OriginHost: "cli"})
+	h := diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		meta, ok := smpeer.FromContext(c.Context())
+		if !ok {
+			t.Fatal("Missing peer metadata")
+		}
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.DestinationHost, avp.Mbit, 0, meta.OriginHost)
+		a.WriteTo(c)
+	})
+	req := diam.NewRequest(diam.CreditControl, 4, dict.Default)
+	req.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	h.ServeDIAM(c, req)
+	written := c.Written()
+	if len(written) != 1 {
+		t.Fatalf("Unexpected # of messages written: %d", len(written))
+	}
+	dst, err := written[0].FindAVP(avp.DestinationHost, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dst.Data.(datatype.DiameterIdentity) != "cli" {
+		t.Fatalf("Unexpected Destination-Host: %s", dst.Data)
+	}
+	if s := c.Stats(); s.MessagesOut != 1 || s.BytesOut != uint64(written[0].Len()) {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+	werr := errors.New("write failed")
+	c.FailWrites(werr)
+	if _, err = req.Answer(diam.Success).WriteTo(c); err != werr {
+		t.Fatalf("Unexpected error. Want %v, have %v", werr, err)
+	}
+	c.Close()
+	<-c.Done()
+	if c.Err() != diam.ErrConnClosed {
+		t.Fatalf("Unexpected error: %v", c.Err())
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict
+
+import (
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	diamdict "github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// Ids and codes of the test dictionaries.
+const (
+	AcctAppID = 1001 // Application id of Acct
+	AuthAppID = 1002 // Application id of Auth
+
+	// VendorAppID is the application id of Vendor, a vendor specific
+	// application of VendorID.
+	VendorAppID = 1003
+	VendorID    = 99999
+
+	// Test-Vendor command of Vendor, with the Test-Vendor-Value AVP.
+	TestVendorCommand = 9000
+	TestVendorValue   = 9001
+)
+
+// Acct returns a minimal accounting application, with no commands
+// or AVPs, for capabilities exchange tests.
+func Acct() *diamdict.App {
+	return NewApp(AcctAppID, "acct")
+}
+
+// Auth returns a minimal authorization application, with no commands
+// or AVPs, for capabilities exchange tests.
+func Auth() *diamdict.App {
+	return NewApp(AuthAppID, "auth")
+}
+
+// Vendor returns a minimal vendor specific authorization application
+// with the Test-Vendor command (TV), whose requests and answers carry
+// the Test-Vendor-Value AVP of type UTF8String.
+func Vendor() *diamdict.App {
+	sessionID := NewRule("Session-Id", true, 1)
+	sessionID.Fixed = true
+	request := []*diamdict.Rule{
+		sessionID,
+		NewRule("Origin-Host", true, 1),
+		NewRule("Origin-Realm", true, 1),
+		NewRule("Test-Vendor-Value", false, 1),
+	}
+	answer := []*diamdict.Rule{
+		sessionID,
+		NewRule("Result-Code", true, 1),
+		NewRule("Origin-Host", true, 1),
+		NewRule("Origin-Realm", true, 1),
+		NewRule("Test-Vendor-Value", false, 1),
+	}
+	app := NewApp(VendorAppID, "auth",
+		NewVendor(VendorID, "Test-Vendor"),
+		NewCommand(TestVendorCommand, "TV", "Test-Vendor", request, answer),
+		NewAVP("Test-Vendor-Value", TestVendorValue, VendorID, datatype.UTF8StringType),
+	)
+	app.Name = "Test-Vendor"
+	return app
+}
+
+var loadDefault struct {
+	sync.Once
+	err error
+}
+
+// LoadDefault loads the Acct, Auth and Vendor applications into
+// dict.Default. It may be called multiple times, and only loads the
+// applications once.
+func LoadDefault() error {
+	loadDefault.Do(func() {
+		loadDefault.err = Load(diamdict.Default, Acct(), Auth(), Vendor())
+	})
+	return loadDefault.err
+}
@@ -0,0 +1,99 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	diamdict "github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// NewApp returns a dictionary application with the given id and type,
+// "auth" or "acct", and the given vendors, commands and AVPs.
+func NewApp(id uint32, typ string, items ...interface{}) *diamdict.App {
+	app := &diamdict.App{ID: id, Type: typ}
+	for _, item := range items {
+		switch v := item.(type) {
+		case *diamdict.Vendor:
+			app.Vendor = append(app.Vendor, v)
+		case *diamdict.Command:
+			app.Command = append(app.Command, v)
+		case *diamdict.AVP:
+			app.AVP = append(app.AVP, v)
+		default:
+			panic(fmt.Sprintf("diamtest/dict: unsupported item %T", item))
+		}
+	}
+	return app
+}
+
+// NewVendor returns a dictionary vendor.
+func NewVendor(id uint32, name string) *diamdict.Vendor {
+	return &diamdict.Vendor{ID: id, Name: name}
+}
+
+// NewCommand returns a dictionary command with the given rules for
+// its request and answer.
+func NewCommand(code uint32, short, name string, request, answer []*diamdict.Rule) *diamdict.Command {
+	return &diamdict.Command{
+		Code:    code,
+		Short:   short,
+		Name:    name,
+		Request: diamdict.CommandRule{Rule: request},
+		Answer:  diamdict.CommandRule{Rule: answer},
+	}
+}
+
+// NewRule returns a rule for the AVP with the given name. A max of
+// zero means no limit.
+func NewRule(avp string, required bool, max int) *diamdict.Rule {
+	return &diamdict.Rule{AVP: avp, Required: required, Max: max}
+}
+
+// NewAVP returns a dictionary AVP of the given data type. The V bit is
+// required for AVPs with a vendor id, and the M bit for the others.
+func NewAVP(name string, code, vendorID uint32, typ datatype.TypeID) *diamdict.AVP {
+	a := &diamdict.AVP{
+		Name:       name,
+		Code:       code,
+		VendorID:   vendorID,
+		May:        "P",
+		MayEncrypt: "-",
+		Data:       diamdict.Data{Type: typ, TypeName: typeName(typ)},
+	}
+	if vendorID != 0 {
+		a.Must, a.MustNot = "V", "M"
+	} else {
+		a.Must, a.MustNot = "M", "V"
+	}
+	return a
+}
+
+// typeName returns the dictionary name of the data type typ.
+func typeName(typ datatype.TypeID) string {
+	for name, id := range datatype.Available {
+		if id == typ {
+			return name
+		}
+	}
+	panic(fmt.Sprintf("diamtest/dict: unsupported data type %d", typ))
+}
+
+// XML returns the dictionary XML of the given applications.
+func XML(apps ...*diamdict.App) string {
+	b, err := xml.MarshalIndent(&diamdict.File{App: apps}, "", "\t")
+	if err != nil {
+		panic(fmt.Sprintf("diamtest/dict: %s", err))
+	}
+	return xml.Header + string(b) + "\n"
+}
+
+// Load loads the given applications into the dictionary parser p.
+func Load(p *diamdict.Parser, apps ...*diamdict.App) error {
+	return p.Load(bytes.NewReader([]byte(XML(apps...))))
+}
@@ -0,0 +1,78 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	diamdict "github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestXML_Deterministic(t *testing.T) {
+	want := XML(Acct(), Auth(), Vendor())
+	for i := 0; i < 10; i++ {
+		if have := XML(Acct(), Auth(), Vendor()); have != want {
+			t.Fatalf("Unexpected XML:\n%s\nwant:\n%s", have, want)
+		}
+	}
+}
+
+func TestLoadDefault(t *testing.T) {
+	if err := LoadDefault(); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDefault(); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []uint32{AcctAppID, AuthAppID, VendorAppID} {
+		if _, err := diamdict.Default.App(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cmd, err := diamdict.Default.FindCommand(VendorAppID, TestVendorCommand)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Short != "TV" {
+		t.Fatalf("Unexpected command %q", cmd.Short)
+	}
+}
+
+func TestVendor_Message(t *testing.T) {
+	p, _ := diamdict.NewParser()
+	if err := Load(p, Vendor()); err != nil {
+		t.Fatal(err)
+	}
+	m := diam.NewRequest(TestVendorCommand, VendorAppID, p)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(TestVendorValue, 0, VendorID, datatype.UTF8String("hello"))
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = diam.ReadMessage(bytes.NewReader(b), p); err == nil {
+		t.Fatal("Unexpected decode of Session-Id without the base dictionary")
+	}
+	if err := Load(p, NewApp(0, "",
+		NewAVP("Session-Id", avp.SessionID, 0, datatype.UTF8StringType),
+	)); err != nil {
+		t.Fatal(err)
+	}
+	m, err = diam.ReadMessage(bytes.NewReader(b), p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := m.FindAVP("Test-Vendor-Value", VendorID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := a.Data.(datatype.UTF8String); v != "hello" {
+		t.Fatalf("Unexpected Test-Vendor-Value %q", v)
+	}
+}
@@ -0,0 +1,21 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package dict provides minimal dictionaries for testing Diameter
+// applications, and helpers to build dictionaries programmatically,
+// so tests don't need to embed dictionary XML.
+//
+// The dictionaries are deterministic: their application ids, codes and
+// vendor ids never change, and their XML is always the same.
+//
+// Example:
+//
+//	func init() {
+//		dict.LoadDefault()
+//	}
+//
+//	settings := &sm.Settings{...}
+//	m := diam.NewRequest(diam.CapabilitiesExchange, 0, nil)
+//	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(dict.AuthAppID))
+package dict
@@ -0,0 +1,212 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+)
+
+// A DiffOption configures which parts of the messages are compared by Diff.
+type DiffOption func(*differ)
+
+// IgnoreHopByHopID makes Diff ignore the Hop-by-Hop Identifier of the
+// message headers.
+func IgnoreHopByHopID() DiffOption {
+	return func(d *differ) { d.ignoreHopByHop = true }
+}
+
+// IgnoreEndToEndID makes Diff ignore the End-to-End Identifier of the
+// message headers.
+func IgnoreEndToEndID() DiffOption {
+	return func(d *differ) { d.ignoreEndToEnd = true }
+}
+
+// IgnoreAVP makes Diff ignore all AVPs with the given code and vendor id,
+// including embedded AVPs of grouped AVPs.
+func IgnoreAVP(code, vendorID uint32) DiffOption {
+	return func(d *differ) { d.ignore[avpKey{code, vendorID}] = true }
+}
+
+// IgnoreVolatile makes Diff ignore the fields that usually change from
+// one run to another: the Hop-by-Hop and End-to-End Identifiers and
+// the Origin-State-Id AVP.
+func IgnoreVolatile() DiffOption {
+	return func(d *differ) {
+		IgnoreHopByHopID()(d)
+		IgnoreEndToEndID()(d)
+		IgnoreAVP(avp.OriginStateID, 0)(d)
+	}
+}
+
+type avpKey struct {
+	code     uint32
+	vendorID uint32
+}
+
+type differ struct {
+	ignoreHopByHop bool
+	ignoreEndToEnd bool
+	ignore         map[avpKey]bool
+
+	m *diam.Message
+	b bytes.Buffer
+}
+
+// Diff returns a human readable, AVP-level description of the differences
+// between m1 and m2, or an empty string if the messages are equal.
+//
+// Header fields are reported as "Header.Field: v1 != v2". AVPs that
+// only exist in m1 are prefixed with "-", and AVPs that only exist in m2
+// are prefixed with "+". Grouped AVPs present in both messages are
+// compared recursively.
+func Diff(m1, m2 *diam.Message, opts ...DiffOption) string {
+	d := &differ{ignore: make(map[avpKey]bool), m: m1}
+	for _, opt := range opts {
+		opt(d)
+	}
+	switch {
+	case m1 == nil && m2 == nil:
+		return ""
+	case m1 == nil || m2 == nil:
+		return fmt.Sprintf("Message: %v != %v\n", m1 != nil, m2 != nil)
+	}
+	d.header(m1.Header, m2.Header)
+	d.avps(d.filter(m1.AVP), d.filter(m2.AVP), 0)
+	return d.b.String()
+}
+
+func (d *differ) header(h1, h2 *diam.Header) {
+	d.field("Version", h1.Version, h2.Version)
+	d.field("CommandFlags", h1.CommandFlags, h2.CommandFlags)
+	d.field("CommandCode", h1.CommandCode, h2.CommandCode)
+	d.field("ApplicationID", h1.ApplicationID, h2.ApplicationID)
+	if !d.ignoreHopByHop {
+		d.field("HopByHopID", h1.HopByHopID, h2.HopByHopID)
+	}
+	if !d.ignoreEndToEnd {
+		d.field("EndToEndID", h1.EndToEndID, h2.EndToEndID)
+	}
+}
+
+func (d *differ) field(name string, v1, v2 interface{}) {
+	if v1 != v2 {
+		fmt.Fprintf(&d.b, "Header.%s: %v != %v\n", name, v1, v2)
+	}
+}
+
+// avps writes the differences between a and b, aligned on their longest
+// common subsequence.
+func (d *differ) avps(a, b []*diam.AVP, indent int) {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if d.equal(a[i], b[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && d.equal(a[i], b[j]):
+			i, j = i+1, j+1
+		case i < len(a) && j < len(b) && sameKey(a[i], b[j]) &&
+			lcs[i+1][j+1] == lcs[i][j]:
+			d.changed(a[i], b[j], indent)
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && lcs[i+1][j] >= lcs[i][j+1]):
+			d.line("-", a[i], indent)
+			i++
+		default:
+			d.line("+", b[j], indent)
+			j++
+		}
+	}
+}
+
+// changed writes the differences between two AVPs with the same code and
+// vendor id.
+func (d *differ) changed(a, b *diam.AVP, indent int) {
+	ga, aok := a.Data.(*diam.GroupedAVP)
+	gb, bok := b.Data.(*diam.GroupedAVP)
+	if !aok || !bok || a.Flags != b.Flags {
+		d.line("-", a, indent)
+		d.line("+", b, indent)
+		return
+	}
+	fmt.Fprintf(&d.b, " %s%s {\n", strings.Repeat("\t", indent+1), d.name(a))
+	d.avps(d.filter(ga.AVP), d.filter(gb.AVP), indent+1)
+	fmt.Fprintf(&d.b, " %s}\n", strings.Repeat("\t", indent+1))
+}
+
+func (d *differ) line(prefix string, a *diam.AVP, indent int) {
+	fmt.Fprintf(&d.b, "%s%s%s %s\n",
+		prefix, strings.Repeat("\t", indent+1), d.name(a), a)
+}
+
+func (d *differ) name(a *diam.AVP) string {
+	dictAVP, err := d.m.Dictionary().FindAVPWithVendor(
+		d.m.Header.ApplicationID,
+		a.Code,
+		a.VendorID,
+	)
+	if err != nil {
+		return "Unknown"
+	}
+	return dictAVP.Name
+}
+
+// filter returns avps without the ignored AVPs.
+func (d *differ) filter(avps []*diam.AVP) []*diam.AVP {
+	if len(d.ignore) == 0 {
+		return avps
+	}
+	r := make([]*diam.AVP, 0, len(avps))
+	for _, a := range avps {
+		if !d.ignore[avpKey{a.Code, a.VendorID}] {
+			r = append(r, a)
+		}
+	}
+	return r
+}
+
+// equal is like diam.AVP.Equal but skips the ignored AVPs embedded in
+// grouped AVPs.
+func (d *differ) equal(a, b *diam.AVP) bool {
+	if !sameKey(a, b) || a.Flags != b.Flags {
+		return false
+	}
+	ga, aok := a.Data.(*diam.GroupedAVP)
+	gb, bok := b.Data.(*diam.GroupedAVP)
+	if !aok || !bok {
+		return a.Equal(b)
+	}
+	fa, fb := d.filter(ga.AVP), d.filter(gb.AVP)
+	if len(fa) != len(fb) {
+		return false
+	}
+	for i := range fa {
+		if !d.equal(fa[i], fb[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameKey(a, b *diam.AVP) bool {
+	return a.Code == b.Code && a.VendorID == b.VendorID
+}
@@ -0,0 +1,88 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diamtest
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func testCER(hbh, e2e, state uint32, product string) *diam.Message {
+	m := diam.NewMessage(diam.CapabilitiesExchange, diam.RequestFlag, 0, hbh, e2e, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("localhost"))
+	m.NewAVP(avp.ProductName, 0, 0, datatype.UTF8String(product))
+	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(state))
+	m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4)),
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+		},
+	})
+	return m
+}
+
+func TestDiff_Equal(t *testing.T) {
+	m1 := testCER(1, 2, 3, "go-diameter")
+	m2 := testCER(1, 2, 3, "go-diameter")
+	if !m1.Equal(m2) {
+		t.Fatal("Unexpected difference between messages")
+	}
+	if d := Diff(m1, m2); d != "" {
+		t.Fatalf("Unexpected diff:\n%s", d)
+	}
+}
+
+func TestDiff_IgnoreVolatile(t *testing.T) {
+	m1 := testCER(1, 2, 3, "go-diameter")
+	m2 := testCER(4, 5, 6, "go-diameter")
+	if m1.Equal(m2) {
+		t.Fatal("Unexpected equal messages")
+	}
+	d := Diff(m1, m2)
+	for _, want := range []string{
+		"Header.HopByHopID: 1 != 4",
+		"Header.EndToEndID: 2 != 5",
+		"-\tOrigin-State-Id",
+		"+\tOrigin-State-Id",
+	} {
+		if !strings.Contains(d, want) {
+			t.Fatalf("Unexpected diff. Want %q in:\n%s", want, d)
+		}
+	}
+	if d = Diff(m1, m2, IgnoreVolatile()); d != "" {
+		t.Fatalf("Unexpected diff:\n%s", d)
+	}
+}
+
+func TestDiff_AVPs(t *testing.T) {
+	m1 := testCER(1, 2, 3, "go-diameter")
+	m2 := testCER(1, 2, 3, "other")
+	m2.NewAVP(avp.FirmwareRevision, 0, 0, datatype.Unsigned32(1))
+	g := m2.AVP[4].Data.(*diam.GroupedAVP)
+	g.AVP[1] = diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(13))
+	d := Diff(m1, m2)
+	want := []string{
+		"-\tProduct-Name",
+		"+\tProduct-Name",
+		" \tVendor-Specific-Application-Id {",
+		"-\t\tVendor-Id {Code:266,Flags:0x40,Length:12,VendorId:0,Value:Unsigned32{10415}}",
+		"+\t\tVendor-Id {Code:266,Flags:0x40,Length:12,VendorId:0,Value:Unsigned32{13}}",
+		"+\tFirmware-Revision",
+	}
+	for _, w := range want {
+		if !strings.Contains(d, w) {
+			t.Fatalf("Unexpected diff. Want %q in:\n%s", w, d)
+		}
+	}
+	if strings.Contains(d, "Origin-Host") {
+		t.Fatalf("Unexpected Origin-Host in diff:\n%s", d)
+	}
+}
@@ -3,4 +3,7 @@
 // found in the LICENSE file.
 
 // Package diamtest provides utilities for Diameter testing.
+//
+// Server runs handlers on a local socket for end-to-end tests, while
+// FakeConn captures the messages written by handlers in unit tests.
 package diamtest
@@ -9,7 +9,8 @@ package dict
 import "bytes"
 
 // Default is a Parser object with pre-loaded
-// Base Protocol and Credit Control dictionaries.
+// Base Protocol, Credit Control, NAS, 3GPP Ro/Rf, Gx, Zh/Zn and SLg/SLh,
+// Mobile IPv4 and SIP dictionaries.
 var Default *Parser
 
 func init() {
@@ -18,6 +19,11 @@ func init() {
 	Default.Load(bytes.NewReader([]byte(creditcontrolXML)))
 	Default.Load(bytes.NewReader([]byte(networkaccessserverXML)))
 	Default.Load(bytes.NewReader([]byte(tgpprorfXML)))
+	Default.Load(bytes.NewReader([]byte(tgppgxXML)))
+	Default.Load(bytes.NewReader([]byte(mobileipv4XML)))
+	Default.Load(bytes.NewReader([]byte(sipXML)))
+	Default.Load(bytes.NewReader([]byte(tgppzhznXML)))
+	Default.Load(bytes.NewReader([]byte(tgppslgslhXML)))
 }
 
 var baseXML = `<?xml version="1.0" encoding="UTF-8"?>
@@ -936,6 +942,306 @@ var creditcontrolXML = `<?xml version="1.0" encoding="UTF-8"?>
 	</application>
 </diameter>`
 
+var mobileipv4XML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+
+	<application id="2">
+		<!-- Diameter Mobile IPv4 Application -->
+		<!-- http://tools.ietf.org/html/rfc4004 -->
+
+		<command code="260" short="AM" name="AA-Mobile-Node">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4004#section-5.1 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="User-Name" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="MIP-Reg-Request" required="true" max="1"/>
+				<rule avp="MIP-MN-AAA-Auth" required="true" max="1"/>
+				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="MIP-Mobile-Node-Address" required="false" max="1"/>
+				<rule avp="MIP-Home-Agent-Address" required="false" max="1"/>
+				<rule avp="MIP-Feature-Vector" required="false" max="1"/>
+				<rule avp="MIP-Originating-Foreign-AAA" required="false" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Auth-Session-State" required="false" max="1"/>
+				<rule avp="MIP-FA-Challenge" required="false" max="1"/>
+				<rule avp="MIP-Candidate-Home-Agent-Host" required="false" max="1"/>
+				<rule avp="MIP-Home-Agent-Host" required="false" max="1"/>
+				<rule avp="MIP-HA-to-FA-SPI" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4004#section-5.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Auth-Session-State" required="false" max="1"/>
+				<rule avp="Error-Message" required="false" max="1"/>
+				<rule avp="Error-Reporting-Host" required="false" max="1"/>
+				<rule avp="Re-Auth-Request-Type" required="false" max="1"/>
+				<rule avp="MIP-Feature-Vector" required="false" max="1"/>
+				<rule avp="MIP-Reg-Reply" required="false" max="1"/>
+				<rule avp="MIP-MN-to-FA-MSA" required="false" max="1"/>
+				<rule avp="MIP-MN-to-HA-MSA" required="false" max="1"/>
+				<rule avp="MIP-FA-to-MN-MSA" required="false" max="1"/>
+				<rule avp="MIP-FA-to-HA-MSA" required="false" max="1"/>
+				<rule avp="MIP-HA-to-MN-MSA" required="false" max="1"/>
+				<rule avp="MIP-MSA-Lifetime" required="false" max="1"/>
+				<rule avp="MIP-Home-Agent-Address" required="false" max="1"/>
+				<rule avp="MIP-Mobile-Node-Address" required="false" max="1"/>
+				<rule avp="MIP-Filter-Rule" required="false"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+			</answer>
+		</command>
+
+		<command code="262" short="HA" name="Home-Agent-MIP">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4004#section-5.3 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Authorization-Lifetime" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="MIP-Reg-Request" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="User-Name" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="MIP-Feature-Vector" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="MIP-MN-to-HA-MSA" required="false" max="1"/>
+				<rule avp="MIP-MN-to-FA-MSA" required="false" max="1"/>
+				<rule avp="MIP-HA-to-MN-MSA" required="false" max="1"/>
+				<rule avp="MIP-HA-to-FA-MSA" required="false" max="1"/>
+				<rule avp="MIP-MSA-Lifetime" required="false" max="1"/>
+				<rule avp="MIP-Originating-Foreign-AAA" required="false" max="1"/>
+				<rule avp="MIP-Mobile-Node-Address" required="false" max="1"/>
+				<rule avp="MIP-Home-Agent-Address" required="false" max="1"/>
+				<rule avp="MIP-Filter-Rule" required="false"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4004#section-5.4 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Acct-Multi-Session-Id" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="Error-Reporting-Host" required="false" max="1"/>
+				<rule avp="Error-Message" required="false" max="1"/>
+				<rule avp="MIP-Reg-Reply" required="false" max="1"/>
+				<rule avp="MIP-Home-Agent-Address" required="false" max="1"/>
+				<rule avp="MIP-Mobile-Node-Address" required="false" max="1"/>
+				<rule avp="MIP-FA-to-HA-SPI" required="false" max="1"/>
+				<rule avp="MIP-FA-to-MN-SPI" required="false" max="1"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+			</answer>
+		</command>
+
+		<avp name="MIP-FA-to-HA-SPI" code="318" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-FA-to-MN-SPI" code="319" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-Reg-Request" code="320" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MIP-Reg-Reply" code="321" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MIP-MN-AAA-Auth" code="322" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-MN-AAA-SPI" required="true" max="1"/>
+				<rule avp="MIP-Auth-Input-Data-Length" required="true" max="1"/>
+				<rule avp="MIP-Authenticator-Length" required="true" max="1"/>
+				<rule avp="MIP-Authenticator-Offset" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-HA-to-FA-SPI" code="323" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-MN-to-FA-MSA" code="325" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-Algorithm-Type" required="true" max="1"/>
+				<rule avp="MIP-Nonce" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-FA-to-MN-MSA" code="326" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-FA-to-MN-SPI" required="true" max="1"/>
+				<rule avp="MIP-Algorithm-Type" required="true" max="1"/>
+				<rule avp="MIP-Session-Key" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-FA-to-HA-MSA" code="328" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-FA-to-HA-SPI" required="true" max="1"/>
+				<rule avp="MIP-Algorithm-Type" required="true" max="1"/>
+				<rule avp="MIP-Session-Key" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-HA-to-FA-MSA" code="329" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-HA-to-FA-SPI" required="true" max="1"/>
+				<rule avp="MIP-Algorithm-Type" required="true" max="1"/>
+				<rule avp="MIP-Session-Key" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-MN-to-HA-MSA" code="331" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-Algorithm-Type" required="true" max="1"/>
+				<rule avp="MIP-Replay-Mode" required="true" max="1"/>
+				<rule avp="MIP-Nonce" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-HA-to-MN-MSA" code="332" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="MIP-Algorithm-Type" required="true" max="1"/>
+				<rule avp="MIP-Replay-Mode" required="true" max="1"/>
+				<rule avp="MIP-Session-Key" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-Mobile-Node-Address" code="333" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Address"/>
+		</avp>
+
+		<avp name="MIP-Home-Agent-Address" code="334" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Address"/>
+		</avp>
+
+		<avp name="MIP-Nonce" code="335" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MIP-Candidate-Home-Agent-Host" code="336" must="M" may="P" must-not="V" may-encrypt="N">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="MIP-Feature-Vector" code="337" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-Auth-Input-Data-Length" code="338" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-Authenticator-Length" code="339" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-Authenticator-Offset" code="340" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-MN-AAA-SPI" code="341" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MIP-Filter-Rule" code="342" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="IPFilterRule"/>
+		</avp>
+
+		<avp name="MIP-Session-Key" code="343" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MIP-FA-Challenge" code="344" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MIP-Algorithm-Type" code="345" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Enumerated">
+				<item code="2" name="HMAC-SHA-1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-Replay-Mode" code="346" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Enumerated">
+				<item code="1" name="None"/>
+				<item code="2" name="Timestamps"/>
+				<item code="3" name="Nonces"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-Originating-Foreign-AAA" code="347" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-Home-Agent-Host" code="348" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Grouped">
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MIP-MSA-Lifetime" code="367" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4004 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+	</application>
+</diameter>`
+
 var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 <diameter>
 
@@ -1900,1269 +2206,1625 @@ var networkaccessserverXML = `<?xml version="1.0" encoding="UTF-8"?>
 	</application>
 </diameter>`
 
-var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
+var sipXML = `<?xml version="1.0" encoding="UTF-8"?>
 <diameter>
-	<application id="4">
-		<vendor id="10415" name="TGPP"/>
-
-		<avp name="TGPP-Charging-Characteristics" code="13" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="TGPP-Charging-Id" code="2" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
-
-		<avp name="TGPP-GGSN-MCC-MNC" code="9" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="TGPP-IMSI" code="1" must="V" may="P" must-not="M" may-encrypt="" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="TGPP-IMSI-MCC-MNC" code="8" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
 
-		<avp name="TGPP-MS-TimeZone" code="23" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
+	<application id="6">
+		<!-- Diameter Session Initiation Protocol (SIP) Application -->
+		<!-- http://tools.ietf.org/html/rfc4740 -->
 
-		<avp name="TGPP-NSAPI" code="10" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
+		<command code="283" short="UA" name="User-Authorization">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.1 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="SIP-AOR" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="SIP-Visited-Network-Id" required="false" max="1"/>
+				<rule avp="SIP-User-Authorization-Type" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="SIP-Server-URI" required="false" max="1"/>
+				<rule avp="SIP-Server-Capabilities" required="false" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Auth-Grace-Period" required="false" max="1"/>
+				<rule avp="Redirect-Host" required="false" max="1"/>
+				<rule avp="Redirect-Host-Usage" required="false" max="1"/>
+				<rule avp="Redirect-Max-Cache-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
 
-		<avp name="TGPP-PDP-Type" code="3" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Ipv4"/>
-				<item code="1" name="PPP"/>
-				<item code="2" name="Ipv6"/>
-				<item code="3" name="Ipv4v6"/>
-			</data>
-		</avp>
+		<command code="284" short="SA" name="Server-Assignment">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.3 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="SIP-Server-Assignment-Type" required="true" max="1"/>
+				<rule avp="SIP-User-Data-Already-Available" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="SIP-Server-URI" required="false" max="1"/>
+				<rule avp="SIP-Supported-User-Data-Type" required="false"/>
+				<rule avp="SIP-AOR" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.4 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="SIP-User-Data" required="false"/>
+				<rule avp="SIP-Accounting-Information" required="false" max="1"/>
+				<rule avp="SIP-Supported-User-Data-Type" required="false"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="Auth-Grace-Period" required="false" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Redirect-Host" required="false" max="1"/>
+				<rule avp="Redirect-Host-Usage" required="false" max="1"/>
+				<rule avp="Redirect-Max-Cache-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
 
-		<avp name="TGPP-RAT-Type" code="21" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
+		<command code="285" short="LI" name="Location-Info">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.5 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="SIP-AOR" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.6 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="SIP-Server-URI" required="false" max="1"/>
+				<rule avp="SIP-Server-Capabilities" required="false" max="1"/>
+				<rule avp="Auth-Grace-Period" required="false" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Redirect-Host" required="false" max="1"/>
+				<rule avp="Redirect-Host-Usage" required="false" max="1"/>
+				<rule avp="Redirect-Max-Cache-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
 
-		<avp name="TGPP-Selection-Mode" code="12" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+		<command code="286" short="MA" name="Multimedia-Auth">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.7 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="SIP-AOR" required="true" max="1"/>
+				<rule avp="SIP-Method" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="SIP-Server-URI" required="false" max="1"/>
+				<rule avp="SIP-Number-Auth-Items" required="false" max="1"/>
+				<rule avp="SIP-Auth-Data-Item" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.8 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="SIP-AOR" required="false" max="1"/>
+				<rule avp="SIP-Number-Auth-Items" required="false" max="1"/>
+				<rule avp="SIP-Auth-Data-Item" required="false"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Auth-Grace-Period" required="false" max="1"/>
+				<rule avp="Redirect-Host" required="false" max="1"/>
+				<rule avp="Redirect-Host-Usage" required="false" max="1"/>
+				<rule avp="Redirect-Max-Cache-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<command code="287" short="RT" name="Registration-Termination">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.9 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="true" max="1"/>
+				<rule avp="SIP-Deregistration-Reason" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="SIP-AOR" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.10 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Auth-Grace-Period" required="false" max="1"/>
+				<rule avp="Redirect-Host" required="false" max="1"/>
+				<rule avp="Redirect-Host-Usage" required="false" max="1"/>
+				<rule avp="Redirect-Max-Cache-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<command code="288" short="PP" name="Push-Profile">
+			<request>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.11 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="User-Name" required="true" max="1"/>
+				<rule avp="SIP-User-Data" required="false"/>
+				<rule avp="SIP-Accounting-Information" required="false" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="Authorization-Lifetime" required="false" max="1"/>
+				<rule avp="Auth-Grace-Period" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- http://tools.ietf.org/html/rfc4740#section-8.12 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Redirect-Host" required="false" max="1"/>
+				<rule avp="Redirect-Host-Usage" required="false" max="1"/>
+				<rule avp="Redirect-Max-Cache-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<avp name="Digest-Response" code="103" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="TGPP-Session-Stop-Indicator" code="11" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Digest-Realm" code="104" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="TGPP-SGSN-MCC-MNC" code="18" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+		<avp name="Digest-Nonce" code="105" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="TGPP-User-Location-Info" code="22" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Digest-Response-Auth" code="106" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Access-Network-Charging-Identifier-Value" code="503" must="M,V"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Digest-Nextnonce" code="107" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Access-Network-Information" code="1263" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Digest-Method" code="108" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Access-Transfer-Information" code="2709" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Access-Transfer-Type" required="false" max="1"/>
-				<rule avp="Access-Network-Information" required="false" max="1"/>
-			</data>
+		<avp name="Digest-URI" code="109" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Access-Transfer-Type" code="2710" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="PS to CS Transfer"/>
-				<item code="1" name="CS to PS Transfer"/>
-			</data>
+		<avp name="Digest-QoP" code="110" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Account-Expiration" code="2309" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="Digest-Algorithm" code="111" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Accumulated-Cost" code="2052" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Value-Digits" required="true" max="1"/>
-				<rule avp="Exponent" required="false" max="1"/>
-			</data>
+		<avp name="Digest-Entity-Body-Hash" code="112" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Adaptations" code="1217" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Yes"/>
-				<item code="1" name="No"/>
-			</data>
+		<avp name="Digest-CNonce" code="113" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="ADC-Rule-Base-Name" code="1095" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="Digest-Nonce-Count" code="114" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Additional-Content-Information" code="1207" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Type-Number" required="false" max="1"/>
-				<rule avp="Additional-Type-Information" required="false" max="1"/>
-				<rule avp="Content-Size" required="false" max="1"/>
-			</data>
+		<avp name="Digest-Username" code="115" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Additional-Type-Information" code="1205" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Digest-Opaque" code="116" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Address-Data" code="897" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Digest-Auth-Param" code="117" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Address-Domain" code="898" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Domain-Name" required="false" max="1"/>
-				<rule avp="TGPP-IMSI-MCC-MNC" required="false" max="1"/>
-			</data>
+		<avp name="Digest-Domain" code="119" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Addressee-Type" code="1208" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="TO"/>
-				<item code="1" name="CC"/>
-				<item code="2" name="BCC"/>
-			</data>
+		<avp name="Digest-Stale" code="120" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Address-Type" code="899" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="e-mail address"/>
-				<item code="1" name="MSISDN"/>
-				<item code="2" name="IPv4 Address"/>
-				<item code="3" name="IPv6 Address"/>
-				<item code="4" name="Numeric Shortcode"/>
-				<item code="5" name="Alphanumeric Shortcode"/>
-				<item code="6" name="Other"/>
-				<item code="7" name="IMSI"/>
-			</data>
+		<avp name="Digest-HA1" code="121" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4590 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="AF-Charging-Identifier" code="505" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="SIP-AOR" code="122" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="AF-Correlation-Information" code="1276" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Accounting-Information" code="368" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Grouped">
-				<rule avp="AF-Charging-Identifier" required="true" max="1"/>
-				<rule avp="Flows" required="false"/>
+				<rule avp="SIP-Accounting-Server-URI" required="false"/>
+				<rule avp="SIP-Credit-Control-Server-URI" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="Allocation-Retention-Priority" code="1034" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Priority-Level" required="true" max="1"/>
-				<rule avp="Pre-emption-Capability" required="false" max="1"/>
-				<rule avp="Pre-emption-Vulnerability" required="false" max="1"/>
-			</data>
+		<avp name="SIP-Accounting-Server-URI" code="369" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="DiameterURI"/>
 		</avp>
 
-		<avp name="Alternate-Charged-Party-Address" code="1280" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Credit-Control-Server-URI" code="370" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="DiameterURI"/>
+		</avp>
+
+		<avp name="SIP-Server-URI" code="371" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="AoC-Cost-Information" code="2053" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Server-Capabilities" code="372" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Grouped">
-				<rule avp="Accumulated-Cost" required="false" max="1"/>
-				<rule avp="Incremental-Cost" required="false"/>
-				<rule avp="Currency-Code" required="false" max="1"/>
+				<rule avp="SIP-Mandatory-Capability" required="false"/>
+				<rule avp="SIP-Optional-Capability" required="false"/>
+				<rule avp="SIP-Server-URI" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="AoC-Format" code="2310" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="MONETARY"/>
-				<item code="1" name="NON_MONETARY"/>
-				<item code="2" name="CAI"/>
-			</data>
+		<avp name="SIP-Mandatory-Capability" code="373" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="AoC-Information" code="2054" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Optional-Capability" code="374" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="SIP-Server-Assignment-Type" code="375" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Enumerated">
+				<item code="0" name="NO_ASSIGNMENT"/>
+				<item code="1" name="REGISTRATION"/>
+				<item code="2" name="RE_REGISTRATION"/>
+				<item code="3" name="UNREGISTERED_USER"/>
+				<item code="4" name="TIMEOUT_DEREGISTRATION"/>
+				<item code="5" name="USER_DEREGISTRATION"/>
+				<item code="6" name="TIMEOUT_DEREGISTRATION_STORE_SERVER_NAME"/>
+				<item code="7" name="USER_DEREGISTRATION_STORE_SERVER_NAME"/>
+				<item code="8" name="ADMINISTRATIVE_DEREGISTRATION"/>
+				<item code="9" name="AUTHENTICATION_FAILURE"/>
+				<item code="10" name="AUTHENTICATION_TIMEOUT"/>
+				<item code="11" name="DEREGISTRATION_TOO_MUCH_DATA"/>
+			</data>
+		</avp>
+
+		<avp name="SIP-Auth-Data-Item" code="376" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Grouped">
-				<rule avp="AoC-Cost-Information" required="false" max="1"/>
-				<rule avp="Tariff-Information" required="false" max="1"/>
-				<rule avp="AoC-Subscription-Information" required="false" max="1"/>
+				<rule avp="SIP-Authentication-Scheme" required="true" max="1"/>
+				<rule avp="SIP-Item-Number" required="false" max="1"/>
+				<rule avp="SIP-Authenticate" required="false" max="1"/>
+				<rule avp="SIP-Authorization" required="false" max="1"/>
+				<rule avp="SIP-Authentication-Info" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="AoC-Request-Type" code="2055" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Authentication-Scheme" code="377" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Enumerated">
-				<item code="0" name="AoC_NOT_REQUESTED"/>
-				<item code="1" name="AoC_FULL"/>
-				<item code="2" name="AoC_COST_ONLY"/>
-				<item code="3" name="AoC_TARIFF_ONLY"/>
+				<item code="0" name="DIGEST"/>
 			</data>
 		</avp>
 
-		<avp name="AoC-Service" code="2311" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Item-Number" code="378" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="SIP-Authenticate" code="379" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Grouped">
-				<rule avp="AoC-Service-Obligatory-Type" required="false" max="1"/>
-				<rule avp="AoC-Service-Type" required="false" max="1"/>
+				<rule avp="Digest-Realm" required="true" max="1"/>
+				<rule avp="Digest-Nonce" required="true" max="1"/>
+				<rule avp="Digest-Domain" required="false" max="1"/>
+				<rule avp="Digest-Opaque" required="false" max="1"/>
+				<rule avp="Digest-Stale" required="false" max="1"/>
+				<rule avp="Digest-Algorithm" required="false" max="1"/>
+				<rule avp="Digest-QoP" required="false" max="1"/>
+				<rule avp="Digest-HA1" required="false" max="1"/>
+				<rule avp="Digest-Auth-Param" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="AoC-Service-Obligatory-Type" code="2312" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="NON_BINDING"/>
-				<item code="1" name="BINDING"/>
+		<avp name="SIP-Authorization" code="380" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Grouped">
+				<rule avp="Digest-Username" required="true" max="1"/>
+				<rule avp="Digest-Realm" required="true" max="1"/>
+				<rule avp="Digest-Nonce" required="true" max="1"/>
+				<rule avp="Digest-URI" required="true" max="1"/>
+				<rule avp="Digest-Response" required="true" max="1"/>
+				<rule avp="Digest-Algorithm" required="false" max="1"/>
+				<rule avp="Digest-CNonce" required="false" max="1"/>
+				<rule avp="Digest-Opaque" required="false" max="1"/>
+				<rule avp="Digest-QoP" required="false" max="1"/>
+				<rule avp="Digest-Nonce-Count" required="false" max="1"/>
+				<rule avp="Digest-Method" required="false" max="1"/>
+				<rule avp="Digest-Entity-Body-Hash" required="false" max="1"/>
+				<rule avp="Digest-Auth-Param" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="SIP-Authentication-Info" code="381" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Grouped">
+				<rule avp="Digest-Nextnonce" required="false" max="1"/>
+				<rule avp="Digest-QoP" required="false" max="1"/>
+				<rule avp="Digest-Response-Auth" required="false" max="1"/>
+				<rule avp="Digest-CNonce" required="false" max="1"/>
+				<rule avp="Digest-Nonce-Count" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="AoC-Service-Type" code="2313" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="NONE"/>
-				<item code="1" name="AOC-S"/>
-				<item code="2" name="AOC-D"/>
-				<item code="3" name="AOC-E"/>
-			</data>
+		<avp name="SIP-Number-Auth-Items" code="382" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="AoC-Subscription-Information" code="2314" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Deregistration-Reason" code="383" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Grouped">
-				<rule avp="AoC-Service" required="false"/>
-				<rule avp="AoC-Format" required="false" max="1"/>
-				<rule avp="Preferred-AoC-Currency" required="false" max="1"/>
+				<rule avp="SIP-Reason-Code" required="true" max="1"/>
+				<rule avp="SIP-Reason-Info" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Application-Port-Identifer" code="3010" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="SIP-Reason-Code" code="384" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Enumerated">
+				<item code="0" name="PERMANENT_TERMINATION"/>
+				<item code="1" name="NEW_SIP_SERVER_ASSIGNED"/>
+				<item code="2" name="SIP_SERVER_CHANGE"/>
+				<item code="3" name="REMOVE_SIP_SERVER"/>
+			</data>
 		</avp>
 
-		<avp name="Application-Provided-Called-Party-Address" code="837" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Reason-Info" code="385" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Application-Server" code="836" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Visited-Network-Id" code="386" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Application-Server-Id" code="2101" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="SIP-User-Authorization-Type" code="387" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Enumerated">
+				<item code="0" name="REGISTRATION"/>
+				<item code="1" name="DEREGISTRATION"/>
+				<item code="2" name="REGISTRATION_AND_CAPABILITIES"/>
+			</data>
 		</avp>
 
-		<avp name="Application-Service-Provider-Identity" code="532" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="SIP-Supported-User-Data-Type" code="388" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Application-Server-Information" code="850" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-User-Data" code="389" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="Grouped">
-				<rule avp="Application-Server" required="false" max="1"/>
-				<rule avp="Application-Provided-Called-Party-Address" required="false"/>
-				<rule avp="Status- AS-Code" required="false" max="1"/>
+				<rule avp="SIP-User-Data-Type" required="true" max="1"/>
+				<rule avp="SIP-User-Data-Contents" required="true" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Application-Session-Id" code="2103" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
-		</avp>
-
-		<avp name="Applic-Id" code="1218" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-User-Data-Type" code="390" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Associated-Party-Address" code="2035" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="SIP-User-Data-Contents" code="391" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Associated-URI" code="856" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="SIP-User-Data-Already-Available" code="392" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
+			<data type="Enumerated">
+				<item code="0" name="USER_DATA_NOT_AVAILABLE"/>
+				<item code="1" name="USER_DATA_ALREADY_AVAILABLE"/>
+			</data>
 		</avp>
 
-		<avp name="Authorised-QoS" code="849" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Method" code="393" must="M" may="P" must-not="V" may-encrypt="Y">
+			<!-- http://tools.ietf.org/html/rfc4740#section-9 -->
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Aux-Applic-Info" code="1219" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
+	</application>
+</diameter>`
 
-		<avp name="Base-Time-Interval" code="1265" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
-		</avp>
+var tgppgxXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="16777238" type="auth" name="TGPP Gx">
+		<!-- 3GPP TS 29.212 Policy and Charging Control over Gx -->
+		<vendor id="10415" name="TGPP"/>
 
-		<avp name="Basic-Service-Code" code="3411" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Bearer-Service" required="false" max="1"/>
-				<rule avp="Teleservice" required="false" max="1"/>
-			</data>
-		</avp>
+		<command code="272" short="CC" name="Credit-Control">
+			<request>
+				<!-- 3GPP TS 29.212 section 5.6.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="CC-Request-Type" required="true" max="1"/>
+				<rule avp="CC-Request-Number" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="Subscription-Id" required="false"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Network-Request-Support" required="false" max="1"/>
+				<rule avp="Bearer-Identifier" required="false" max="1"/>
+				<rule avp="Bearer-Operation" required="false" max="1"/>
+				<rule avp="Framed-IP-Address" required="false" max="1"/>
+				<rule avp="Framed-IPv6-Prefix" required="false" max="1"/>
+				<rule avp="IP-CAN-Type" required="false" max="1"/>
+				<rule avp="RAT-Type" required="false" max="1"/>
+				<rule avp="Termination-Cause" required="false" max="1"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="Default-EPS-Bearer-QoS" required="false" max="1"/>
+				<rule avp="Called-Station-Id" required="false" max="1"/>
+				<rule avp="Charging-Rule-Report" required="false"/>
+				<rule avp="Event-Trigger" required="false"/>
+				<rule avp="Usage-Monitoring-Information" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.212 section 5.6.3 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="CC-Request-Type" required="true" max="1"/>
+				<rule avp="CC-Request-Number" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Bearer-Control-Mode" required="false" max="1"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="Charging-Rule-Remove" required="false"/>
+				<rule avp="Charging-Rule-Install" required="false"/>
+				<rule avp="Event-Trigger" required="false"/>
+				<rule avp="Usage-Monitoring-Information" required="false"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="Default-EPS-Bearer-QoS" required="false" max="1"/>
+				<rule avp="Revalidation-Time" required="false" max="1"/>
+				<rule avp="Error-Message" required="false" max="1"/>
+				<rule avp="Error-Reporting-Host" required="false" max="1"/>
+				<rule avp="Failed-AVP" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
 
-		<avp name="Bearer-Capability" code="3412" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
+		<command code="258" short="RA" name="Re-Auth">
+			<request>
+				<!-- 3GPP TS 29.212 section 5.6.4 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Auth-Application-Id" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="true" max="1"/>
+				<rule avp="Re-Auth-Request-Type" required="true" max="1"/>
+				<rule avp="Session-Release-Cause" required="false" max="1"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="Charging-Rule-Remove" required="false"/>
+				<rule avp="Charging-Rule-Install" required="false"/>
+				<rule avp="Event-Trigger" required="false"/>
+				<rule avp="Usage-Monitoring-Information" required="false"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="Default-EPS-Bearer-QoS" required="false" max="1"/>
+				<rule avp="Revalidation-Time" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.212 section 5.6.5 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Origin-State-Id" required="false" max="1"/>
+				<rule avp="IP-CAN-Type" required="false" max="1"/>
+				<rule avp="RAT-Type" required="false" max="1"/>
+				<rule avp="Charging-Rule-Report" required="false"/>
+				<rule avp="Error-Message" required="false" max="1"/>
+				<rule avp="Error-Reporting-Host" required="false" max="1"/>
+				<rule avp="Failed-AVP" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+			</answer>
+		</command>
 
-		<avp name="Bearer-Service" code="854" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
+		<!-- Credit control and NAS AVPs used on Gx -->
 
-		<avp name="BSSID" code="2716" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="CC-Request-Number" code="415" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Called-Asserted-Identity" code="1250" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="CC-Request-Type" code="416" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Enumerated">
+				<item code="1" name="INITIAL_REQUEST"/>
+				<item code="2" name="UPDATE_REQUEST"/>
+				<item code="3" name="TERMINATION_REQUEST"/>
+				<item code="4" name="EVENT_REQUEST"/>
+			</data>
 		</avp>
 
-		<avp name="Called-Party-Address" code="832" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="CC-Input-Octets" code="412" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned64"/>
 		</avp>
 
-		<avp name="Calling-Party-Address" code="831" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="CC-Output-Octets" code="414" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned64"/>
 		</avp>
 
-		<avp name="Carrier-Select-Routing-Information" code="2023" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="CC-Time" code="420" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Cause-Code" code="861" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Integer32"/>
+		<avp name="CC-Total-Octets" code="421" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned64"/>
 		</avp>
 
-		<avp name="CG-Address" code="846" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Granted-Service-Unit" code="431" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Grouped">
+				<rule avp="CC-Time" required="false" max="1"/>
+				<rule avp="CC-Total-Octets" required="false" max="1"/>
+				<rule avp="CC-Input-Octets" required="false" max="1"/>
+				<rule avp="CC-Output-Octets" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Change-Condition" code="2037" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Integer32"/>
+		<avp name="Rating-Group" code="432" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Change-Time" code="2038" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="Service-Identifier" code="439" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Charge-Reason-Code" code="2118" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="UNKNOWN"/>
-				<item code="1" name="USAGE"/>
-				<item code="2" name="COMMUNICATION-ATTEMPT-CHARGE"/>
-				<item code="3" name="SETUP-CHARGE"/>
-				<item code="4" name="ADD-ON-CHARGE"/>
+		<avp name="Subscription-Id" code="443" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Grouped">
+				<rule avp="Subscription-Id-Type" required="true" max="1"/>
+				<rule avp="Subscription-Id-Data" required="true" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Charged-Party" code="857" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Subscription-Id-Data" code="444" must="M" may="P" must-not="V" may-encrypt="Y">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Charging-Characteristics-Selection-Mode" code="2066" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Subscription-Id-Type" code="450" must="M" may="P" must-not="V" may-encrypt="Y">
 			<data type="Enumerated">
-				<item code="0" name="Serving-Node-Supplied"/>
-				<item code="1" name="Subscription-specific"/>
-				<item code="2" name="APN-specific"/>
-				<item code="3" name="Home-Default"/>
-				<item code="4" name="Roaming-Default"/>
-				<item code="5" name="Visiting-Default"/>
+				<item code="0" name="END_USER_E164"/>
+				<item code="1" name="END_USER_IMSI"/>
+				<item code="2" name="END_USER_SIP_URI"/>
+				<item code="3" name="END_USER_NAI"/>
+				<item code="4" name="END_USER_PRIVATE"/>
 			</data>
 		</avp>
 
-		<avp name="Charging-Rule-Base-Name" code="1004" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Used-Service-Unit" code="446" must="M" may="P" must-not="V" may-encrypt="Y">
+			<data type="Grouped">
+				<rule avp="CC-Time" required="false" max="1"/>
+				<rule avp="CC-Total-Octets" required="false" max="1"/>
+				<rule avp="CC-Input-Octets" required="false" max="1"/>
+				<rule avp="CC-Output-Octets" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Class-Identifier" code="1214" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Personal"/>
-				<item code="1" name="Advertisement"/>
-				<item code="2" name="Informational"/>
-				<item code="3" name="Auto"/>
-			</data>
+		<avp name="Called-Station-Id" code="30" must="M" may="-" must-not="V" may-encrypt="Y">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Client-Address" code="2018" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Framed-IP-Address" code="8" must="M" may="-" must-not="V" may-encrypt="Y">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="CN-IP-Multicast-Distribution" code="921" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="NO-IP-MULTICAST"/>
-				<item code="1" name="IP-MULTICAST"/>
-			</data>
+		<avp name="Framed-IPv6-Prefix" code="97" must="M" may="-" must-not="V" may-encrypt="Y">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="CN-Operator-Selection-Entity" code="3421" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="The Serving Network has been selected by the UE"/>
-				<item code="1" name="The Serving Network has been selected by the network"/>
-			</data>
+		<!-- 3GPP TS 29.214 AVPs used on Gx -->
+
+		<avp name="Flow-Description" code="507" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="IPFilterRule"/>
 		</avp>
 
-		<avp name="Content-Class" code="1220" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Flow-Status" code="511" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="text"/>
-				<item code="1" name="image-basic"/>
-				<item code="2" name="image-rich"/>
-				<item code="3" name="video-basic"/>
-				<item code="4" name="video-rich"/>
-				<item code="5" name="megapixel"/>
-				<item code="6" name="content-basic"/>
-				<item code="7" name="content-rich"/>
+				<item code="0" name="ENABLED-UPLINK"/>
+				<item code="1" name="ENABLED-DOWNLINK"/>
+				<item code="2" name="ENABLED"/>
+				<item code="3" name="DISABLED"/>
+				<item code="4" name="REMOVED"/>
 			</data>
 		</avp>
 
-		<avp name="Content-Disposition" code="828" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Max-Requested-Bandwidth-DL" code="515" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Content-Id" code="2116" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Max-Requested-Bandwidth-UL" code="516" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Content-Provider-Id" code="2117" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Supported-Features" code="628" must="V" may="P" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Vendor-Id" required="true" max="1"/>
+				<rule avp="Feature-List-ID" required="true" max="1"/>
+				<rule avp="Feature-List" required="true" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Content-Length" code="827" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Feature-List-ID" code="629" must="V" may="P" must-not="M" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Content-Size" code="1206" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Feature-List" code="630" must="V" may="P" must-not="M" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="CSG-Access-Mode" code="2317" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Closed mode"/>
-				<item code="1" name="Hybrid Mode"/>
+		<!-- 3GPP TS 29.212 AVPs -->
+
+		<avp name="Charging-Rule-Install" code="1001" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Charging-Rule-Definition" required="false"/>
+				<rule avp="Charging-Rule-Name" required="false"/>
+				<rule avp="Charging-Rule-Base-Name" required="false"/>
+				<rule avp="Bearer-Identifier" required="false" max="1"/>
+				<rule avp="Rule-Activation-Time" required="false" max="1"/>
+				<rule avp="Rule-Deactivation-Time" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="CSG-Id" code="1437" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Charging-Rule-Remove" code="1002" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Charging-Rule-Name" required="false"/>
+				<rule avp="Charging-Rule-Base-Name" required="false"/>
+			</data>
 		</avp>
 
-		<avp name="CSG-Membership-Indication" code="2318" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Not CSG member"/>
-				<item code="1" name="CSG Member"/>
+		<avp name="Charging-Rule-Definition" code="1003" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Charging-Rule-Name" required="true" max="1"/>
+				<rule avp="Service-Identifier" required="false" max="1"/>
+				<rule avp="Rating-Group" required="false" max="1"/>
+				<rule avp="Flow-Information" required="false"/>
+				<rule avp="Flow-Status" required="false" max="1"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="Reporting-Level" required="false" max="1"/>
+				<rule avp="Online" required="false" max="1"/>
+				<rule avp="Offline" required="false" max="1"/>
+				<rule avp="Metering-Method" required="false" max="1"/>
+				<rule avp="Precedence" required="false" max="1"/>
+				<rule avp="Monitoring-Key" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Content-Type" code="826" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Charging-Rule-Base-Name" code="1004" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Current-Tariff" code="2056" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Currency-Code" required="false" max="1"/>
-				<rule avp="Scale-Factor" required="false" max="1"/>
-				<rule avp="Rate-Element" required="false"/>
+		<avp name="Charging-Rule-Name" code="1005" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Event-Trigger" code="1006" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="SGSN_CHANGE"/>
+				<item code="1" name="QOS_CHANGE"/>
+				<item code="2" name="RAT_CHANGE"/>
+				<item code="3" name="TFT_CHANGE"/>
+				<item code="4" name="PLMN_CHANGE"/>
+				<item code="5" name="LOSS_OF_BEARER"/>
+				<item code="6" name="RECOVERY_OF_BEARER"/>
+				<item code="7" name="IP-CAN_CHANGE"/>
+				<item code="11" name="QOS_CHANGE_EXCEEDING_AUTHORIZATION"/>
+				<item code="12" name="RAI_CHANGE"/>
+				<item code="13" name="USER_LOCATION_CHANGE"/>
+				<item code="14" name="NO_EVENT_TRIGGERS"/>
+				<item code="15" name="OUT_OF_CREDIT"/>
+				<item code="16" name="REALLOCATION_OF_CREDIT"/>
+				<item code="17" name="REVALIDATION_TIMEOUT"/>
+				<item code="18" name="UE_IP_ADDRESS_ALLOCATE"/>
+				<item code="19" name="UE_IP_ADDRESS_RELEASE"/>
+				<item code="20" name="DEFAULT_EPS_BEARER_QOS_CHANGE"/>
+				<item code="21" name="AN_GW_CHANGE"/>
+				<item code="22" name="SUCCESSFUL_RESOURCE_ALLOCATION"/>
+				<item code="23" name="RESOURCE_MODIFICATION_REQUEST"/>
+				<item code="24" name="PGW_TRACE_CONTROL"/>
+				<item code="25" name="UE_TIME_ZONE_CHANGE"/>
+				<item code="26" name="TAI_CHANGE"/>
+				<item code="27" name="ECGI_CHANGE"/>
+				<item code="28" name="CHARGING_CORRELATION_EXCHANGE"/>
+				<item code="29" name="APN-AMBR_MODIFICATION_FAILURE"/>
+				<item code="30" name="USER_CSG_INFORMATION_CHANGE"/>
+				<item code="33" name="USAGE_REPORT"/>
+			</data>
+		</avp>
+
+		<avp name="Metering-Method" code="1007" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="DURATION"/>
+				<item code="1" name="VOLUME"/>
+				<item code="2" name="DURATION_VOLUME"/>
+				<item code="3" name="EVENT"/>
 			</data>
 		</avp>
 
-		<avp name="CUG-Information" code="2304" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Offline" code="1008" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="DISABLE_OFFLINE"/>
+				<item code="1" name="ENABLE_OFFLINE"/>
+			</data>
 		</avp>
 
-		<avp name="Data-Coding-Scheme" code="2001" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Integer32"/>
+		<avp name="Online" code="1009" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="DISABLE_ONLINE"/>
+				<item code="1" name="ENABLE_ONLINE"/>
+			</data>
 		</avp>
 
-		<avp name="Deferred-Location-Event-Type" code="1230" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Precedence" code="1010" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Delivery-Report-Requested" code="1216" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Reporting-Level" code="1011" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="No"/>
-				<item code="1" name="Yes"/>
+				<item code="0" name="SERVICE_IDENTIFIER_LEVEL"/>
+				<item code="1" name="RATING_GROUP_LEVEL"/>
+				<item code="2" name="SPONSORED_CONNECTIVITY_LEVEL"/>
 			</data>
 		</avp>
 
-		<avp name="Delivery-Status" code="2104" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="ToS-Traffic-Class" code="1014" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Destination-Interface" code="2002" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="QoS-Information" code="1016" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Interface-Id" required="true" max="1"/>
-				<rule avp="Interface-Text" required="true" max="1"/>
-				<rule avp="Interface-Port" required="false" max="1"/>
-				<rule avp="Interface-Type" required="false" max="1"/>
+				<rule avp="QoS-Class-Identifier" required="false" max="1"/>
+				<rule avp="Max-Requested-Bandwidth-UL" required="false" max="1"/>
+				<rule avp="Max-Requested-Bandwidth-DL" required="false" max="1"/>
+				<rule avp="Guaranteed-Bitrate-UL" required="false" max="1"/>
+				<rule avp="Guaranteed-Bitrate-DL" required="false" max="1"/>
+				<rule avp="Bearer-Identifier" required="false" max="1"/>
+				<rule avp="Allocation-Retention-Priority" required="false" max="1"/>
+				<rule avp="APN-Aggregate-Max-Bitrate-UL" required="false" max="1"/>
+				<rule avp="APN-Aggregate-Max-Bitrate-DL" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Diagnostics" code="2039" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Integer32"/>
+		<avp name="Charging-Rule-Report" code="1018" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Charging-Rule-Name" required="false"/>
+				<rule avp="Charging-Rule-Base-Name" required="false"/>
+				<rule avp="Bearer-Identifier" required="false" max="1"/>
+				<rule avp="PCC-Rule-Status" required="false" max="1"/>
+				<rule avp="Rule-Failure-Code" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Domain-Name" code="1200" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="PCC-Rule-Status" code="1019" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="ACTIVE"/>
+				<item code="1" name="INACTIVE"/>
+				<item code="2" name="TEMPORARILY_INACTIVE"/>
+			</data>
 		</avp>
 
-		<avp name="DRM-Content" code="1221" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Bearer-Identifier" code="1020" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Bearer-Operation" code="1021" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="No"/>
-				<item code="1" name="Yes"/>
+				<item code="0" name="TERMINATION"/>
+				<item code="1" name="ESTABLISHMENT"/>
+				<item code="2" name="MODIFICATION"/>
 			</data>
 		</avp>
 
-		<avp name="Dynamic-Address-Flag" code="2051" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Bearer-Control-Mode" code="1023" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Static"/>
-				<item code="1" name="Dynamic"/>
+				<item code="0" name="UE_ONLY"/>
+				<item code="1" name="RESERVED"/>
+				<item code="2" name="UE_NW"/>
 			</data>
 		</avp>
 
-		<avp name="Dynamic-Address-Flag-Extension" code="2068" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Network-Request-Support" code="1024" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Static"/>
-				<item code="1" name="Dynamic"/>
+				<item code="0" name="NETWORK_REQUEST NOT SUPPORTED"/>
+				<item code="1" name="NETWORK_REQUEST SUPPORTED"/>
 			</data>
 		</avp>
 
-		<avp name="Early-Media-Description" code="1272" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="SDP-TimeStamps" required="false" max="1"/>
-				<rule avp="SDP-Media-Component" required="false"/>
-				<rule avp="SDP-Session-Description" required="false"/>
-			</data>
+		<avp name="Guaranteed-Bitrate-DL" code="1025" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="ePDG-Address" code="3425" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Guaranteed-Bitrate-UL" code="1026" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Envelope" code="1266" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Envelope-Start-Time" required="true" max="1"/>
-				<rule avp="Envelope-End-Time" required="false" max="1"/>
-				<rule avp="CC-Total-Octets" required="false" max="1"/>
-				<rule avp="CC-Input-Octets" required="false" max="1"/>
-				<rule avp="CC-Output-Octets" required="false" max="1"/>
-				<rule avp="CC-Service-Specific-Units" required="false" max="1"/>
+		<avp name="IP-CAN-Type" code="1027" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="3GPP-GPRS"/>
+				<item code="1" name="DOCSIS"/>
+				<item code="2" name="xDSL"/>
+				<item code="3" name="WiMAX"/>
+				<item code="4" name="3GPP2"/>
+				<item code="5" name="3GPP-EPS"/>
+				<item code="6" name="Non-3GPP-EPS"/>
 			</data>
 		</avp>
 
-		<avp name="Envelope-End-Time" code="1267" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="QoS-Class-Identifier" code="1028" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="1" name="QCI_1"/>
+				<item code="2" name="QCI_2"/>
+				<item code="3" name="QCI_3"/>
+				<item code="4" name="QCI_4"/>
+				<item code="5" name="QCI_5"/>
+				<item code="6" name="QCI_6"/>
+				<item code="7" name="QCI_7"/>
+				<item code="8" name="QCI_8"/>
+				<item code="9" name="QCI_9"/>
+			</data>
 		</avp>
 
-		<avp name="Envelope-Reporting" code="1268" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Rule-Failure-Code" code="1031" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="DO_NOT_REPORT_ENVELOPES"/>
-				<item code="1" name="REPORT_ENVELOPES"/>
-				<item code="2" name="REPORT_ENVELOPES_WITH_VOLUME"/>
-				<item code="3" name="REPORT_ENVELOPES_WITH_EVENTS"/>
-				<item code="4" name="REPORT_ENVELOPES_WITH_VOLUME_AND_EVENTS"/>
+				<item code="1" name="UNKNOWN_RULE_NAME"/>
+				<item code="2" name="RATING_GROUP_ERROR"/>
+				<item code="3" name="SERVICE_IDENTIFIER_ERROR"/>
+				<item code="4" name="GW/PCEF_MALFUNCTION"/>
+				<item code="5" name="RESOURCES_LIMITATION"/>
+				<item code="6" name="MAX_NR_BEARERS_REACHED"/>
+				<item code="7" name="UNKNOWN_BEARER_ID"/>
+				<item code="8" name="MISSING_BEARER_ID"/>
+				<item code="9" name="MISSING_FLOW_INFORMATION"/>
+				<item code="10" name="RESOURCE_ALLOCATION_FAILURE"/>
+				<item code="11" name="UNSUCCESSFUL_QOS_VALIDATION"/>
+				<item code="12" name="INCORRECT_FLOW_INFORMATION"/>
+			</data>
+		</avp>
+
+		<avp name="RAT-Type" code="1032" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="WLAN"/>
+				<item code="1" name="VIRTUAL"/>
+				<item code="1000" name="UTRAN"/>
+				<item code="1001" name="GERAN"/>
+				<item code="1002" name="GAN"/>
+				<item code="1003" name="HSPA_EVOLUTION"/>
+				<item code="1004" name="EUTRAN"/>
+				<item code="2000" name="CDMA2000_1X"/>
+				<item code="2001" name="HRPD"/>
+				<item code="2002" name="UMB"/>
+				<item code="2003" name="EHRPD"/>
 			</data>
 		</avp>
 
-		<avp name="Envelope-Start-Time" code="1269" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Allocation-Retention-Priority" code="1034" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Priority-Level" required="true" max="1"/>
+				<rule avp="Pre-emption-Capability" required="false" max="1"/>
+				<rule avp="Pre-emption-Vulnerability" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="APN-Aggregate-Max-Bitrate-DL" code="1040" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="APN-Aggregate-Max-Bitrate-UL" code="1041" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Revalidation-Time" code="1042" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Time"/>
 		</avp>
 
-		<avp name="Event" code="825" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Rule-Activation-Time" code="1043" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="Event-Charging-TimeStamp" code="1258" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Rule-Deactivation-Time" code="1044" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Time"/>
 		</avp>
 
-		<avp name="Event-Type" code="823" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="SIP-Method" required="false" max="1"/>
-				<rule avp="Event" required="false" max="1"/>
-				<rule avp="Expires" required="true" max="1"/>
+		<avp name="Session-Release-Cause" code="1045" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="UNSPECIFIED_REASON"/>
+				<item code="1" name="UE_SUBSCRIPTION_REASON"/>
+				<item code="2" name="INSUFFICIENT_SERVER_RESOURCES"/>
 			</data>
 		</avp>
 
-		<avp name="Expires" code="888" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Priority-Level" code="1046" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="File-Repair-Supported" code="1224" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="Pre-emption-Capability" code="1047" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="SUPPORTED"/>
-				<item code="1" name="NOT_SUPPORTED"/>
+				<item code="0" name="PRE-EMPTION_CAPABILITY_ENABLED"/>
+				<item code="1" name="PRE-EMPTION_CAPABILITY_DISABLED"/>
 			</data>
 		</avp>
 
-		<avp name="Fixed-User-Location-Info" code="2825" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="SSID" required="false" max="1"/>
-				<rule avp="BSSID" required="false" max="1"/>
-				<rule avp="Logical-Access-Id" required="true" max="1"/>
-				<rule avp="Physical-Access-Id" required="true" max="1"/>
+		<avp name="Pre-emption-Vulnerability" code="1048" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="PRE-EMPTION_VULNERABILITY_ENABLED"/>
+				<item code="1" name="PRE-EMPTION_VULNERABILITY_DISABLED"/>
 			</data>
 		</avp>
 
-		<avp name="Flows" code="510" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="Default-EPS-Bearer-QoS" code="1049" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Media-Component-Number" required="true" max="1"/>
-				<rule avp="Flow-Number" required="false"/>
+				<rule avp="QoS-Class-Identifier" required="false" max="1"/>
+				<rule avp="Allocation-Retention-Priority" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="From-Address" code="2708" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="Forwarding-Pending" code="3415" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Forwarding not pending"/>
-				<item code="1" name="Forwarding pending"/>
+		<avp name="Flow-Information" code="1058" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Flow-Description" required="false" max="1"/>
+				<rule avp="Packet-Filter-Identifier" required="false" max="1"/>
+				<rule avp="ToS-Traffic-Class" required="false" max="1"/>
+				<rule avp="Flow-Direction" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="GGSN-Address" code="847" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Packet-Filter-Identifier" code="1060" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Guaranteed-Bitrate-UL" code="1026" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Monitoring-Key" code="1066" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="IMS-Application-Reference-Identifier" code="2601" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Usage-Monitoring-Information" code="1067" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Monitoring-Key" required="false" max="1"/>
+				<rule avp="Granted-Service-Unit" required="false" max="2"/>
+				<rule avp="Used-Service-Unit" required="false" max="2"/>
+				<rule avp="Usage-Monitoring-Level" required="false" max="1"/>
+				<rule avp="Usage-Monitoring-Report" required="false" max="1"/>
+				<rule avp="Usage-Monitoring-Support" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="IMS-Charging-Identifier" code="841" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Usage-Monitoring-Level" code="1068" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="SESSION_LEVEL"/>
+				<item code="1" name="PCC_RULE_LEVEL"/>
+				<item code="2" name="ADC_RULE_LEVEL"/>
+			</data>
 		</avp>
 
-		<avp name="IMS-Communication-Service-Identifier" code="1281" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Usage-Monitoring-Report" code="1069" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="USAGE_MONITORING_REPORT_REQUIRED"/>
+			</data>
 		</avp>
 
-		<avp name="IMS-Emergency-Indicator" code="2322" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Usage-Monitoring-Support" code="1070" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Non Emergency"/>
-				<item code="1" name="Emergency"/>
+				<item code="0" name="USAGE_MONITORING_DISABLED"/>
 			</data>
 		</avp>
 
-		<avp name="IMS-Information" code="876" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Event-Type" required="false" max="1"/>
-				<rule avp="Role-Of-Node" required="false" max="1"/>
-				<rule avp="Node-Functionality" required="true" max="1"/>
-				<rule avp="User-Session-Id" required="false" max="1"/>
-				<rule avp="Outgoing-Session-Id" required="false" max="1"/>
-				<rule avp="Session-Priority" required="false" max="1"/>
-				<rule avp="Calling-Party-Address" required="false"/>
-				<rule avp="Called-Party-Address" required="false" max="1"/>
-				<rule avp="Called-Asserted-Identity" required="false"/>
-				<rule avp="Number-Portability-Routing-Information" required="false" max="1"/>
-				<rule avp="Carrier-Select-Routing-Information" required="false" max="1"/>
-				<rule avp="Alternate-Charged-Party-Address" required="false" max="1"/>
-				<rule avp="Requested-Party-Address" required="false"/>
-				<rule avp="Associated-URI" required="false"/>
-				<rule avp="Time-Stamps" required="false" max="1"/>
-				<rule avp="Application-Server-Information" required="false"/>
-				<rule avp="Inter-Operator-Identifier" required="false"/>
-				<rule avp="Transit-IOI-List" required="false"/>
-				<rule avp="IMS-Charging-Identifier" required="false" max="1"/>
-				<rule avp="SDP-Session-Description" required="false"/>
-				<rule avp="SDP-Media-Component" required="false"/>
-				<rule avp="Served-Party-IP-Address" required="false" max="1"/>
-				<rule avp="Server-Capabilities" required="false" max="1"/>
-				<rule avp="Trunk-Group-Id" required="false" max="1"/>
-				<rule avp="Bearer-Service" required="false" max="1"/>
-				<rule avp="Service-Id" required="false" max="1"/>
-				<rule avp="Service-Specific-Info" required="false"/>
-				<rule avp="Message-Body" required="false"/>
-				<rule avp="Cause-Code" required="false" max="1"/>
-				<rule avp="Reason-Header" required="false"/>
-				<rule avp="Access-Network-Information" required="false"/>
-				<rule avp="Early-Media-Description" required="false"/>
-				<rule avp="IMS-Communication-Service-Identifier" required="false" max="1"/>
-				<rule avp="IMS-Application-Reference-Identifier" required="false" max="1"/>
-				<rule avp="Online-Charging-Flag" required="false" max="1"/>
-				<rule avp="Real-Time-Tariff-Information" required="false" max="1"/>
-				<rule avp="Account-Expiration" required="false" max="1"/>
-				<rule avp="Initial-IMS-Charging-Identifier" required="false" max="1"/>
-				<rule avp="NNI-Information" required="false"/>
-				<rule avp="From-Address" required="false" max="1"/>
-				<rule avp="IMS-Emergency-Indicator" required="false" max="1"/>
-				<rule avp="IMS-Visited-Network-Identifier" required="false" max="1"/>
-				<rule avp="Access-Transfer-Information" required="false"/>
-				<rule avp="Related-IMS-Charging-Identifier" required="false" max="1"/>
-				<rule avp="Related-IMS-Charging-Identifier-Node" required="false" max="1"/>
-				<rule avp="Route-Header-Received" required="false" max="1"/>
-				<rule avp="Route-Header-Transmitted" required="false" max="1"/>
-				<rule avp="Instance-Id" required="false" max="1"/>
-				<rule avp="TAD-Identifier" required="false" max="1"/>
+		<avp name="Flow-Direction" code="1080" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="UNSPECIFIED"/>
+				<item code="1" name="DOWNLINK"/>
+				<item code="2" name="UPLINK"/>
+				<item code="3" name="BIDIRECTIONAL"/>
 			</data>
 		</avp>
 
-		<avp name="IMS-Visited-Network-Identifier" code="2713" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+	</application>
+</diameter>`
+
+var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="4">
+		<vendor id="10415" name="TGPP"/>
+
+		<avp name="TGPP-Charging-Characteristics" code="13" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="IMSI-Unauthenticated-Flag" code="2308" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Authenticated"/>
-				<item code="1" name="Unauthenticated"/>
-			</data>
+		<avp name="TGPP-Charging-Id" code="2" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Incoming-Trunk-Group-Id" code="852" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="TGPP-GGSN-MCC-MNC" code="9" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Incremental-Cost" code="2062" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Value-Digits" required="true" max="1"/>
-				<rule avp="Exponent" required="false" max="1"/>
-			</data>
+		<avp name="TGPP-IMSI" code="1" must="V" may="P" must-not="M" may-encrypt="" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Initial-IMS-Charging-Identifier" code="2321" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="TGPP-IMSI-MCC-MNC" code="8" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Instance-Id" code="3402" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="TGPP-MS-TimeZone" code="23" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Interface-Id" code="2003" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="TGPP-NSAPI" code="10" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Interface-Port" code="2004" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="TGPP-PDP-Type" code="3" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Ipv4"/>
+				<item code="1" name="PPP"/>
+				<item code="2" name="Ipv6"/>
+				<item code="3" name="Ipv4v6"/>
+			</data>
+		</avp>
+
+		<avp name="TGPP-RAT-Type" code="21" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="TGPP-Selection-Mode" code="12" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Interface-Text" code="2005" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="TGPP-Session-Stop-Indicator" code="11" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="TGPP-SGSN-MCC-MNC" code="18" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Interface-Type" code="2006" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Unknown"/>
-				<item code="1" name="MOBILE_ORIGINATING"/>
-				<item code="2" name="MOBILE_TERMINATING"/>
-				<item code="3" name="APPLICATION_ORIGINATING"/>
-				<item code="4" name="APPLICATION_TERMINATION"/>
-			</data>
+		<avp name="TGPP-User-Location-Info" code="22" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Inter-Operator-Identifier" code="838" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Originating-IOI" required="false" max="1"/>
-				<rule avp="Terminating-IOI" required="false" max="1"/>
-			</data>
+		<avp name="Access-Network-Charging-Identifier-Value" code="503" must="M,V"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="IP-Realm-Default-Indication" code="2603" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Default IP Realm Not used"/>
-				<item code="1" name="Default IP realm used"/>
-			</data>
+		<avp name="Access-Network-Information" code="1263" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="ISUP-Cause" code="3416" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Access-Transfer-Information" code="2709" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="ISUP-Cause-Location" required="false" max="1"/>
-				<rule avp="ISUP-Cause-Value" required="false" max="1"/>
-				<rule avp="ISUP-Cause-Diagnostic" required="false" max="1"/>
+				<rule avp="Access-Transfer-Type" required="false" max="1"/>
+				<rule avp="Access-Network-Information" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="ISUP-Cause-Diagnostics" code="3422" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Access-Transfer-Type" code="2710" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="PS to CS Transfer"/>
+				<item code="1" name="CS to PS Transfer"/>
+			</data>
 		</avp>
 
-		<avp name="ISUP-Cause-Location" code="3423" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Account-Expiration" code="2309" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="ISUP-Cause-Value" code="3424" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Accumulated-Cost" code="2052" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Value-Digits" required="true" max="1"/>
+				<rule avp="Exponent" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="ISUP-Location-Number" code="3414" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Adaptations" code="1217" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Yes"/>
+				<item code="1" name="No"/>
+			</data>
 		</avp>
 
-		<avp name="LCS-APN" code="1231" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="ADC-Rule-Base-Name" code="1095" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="LCS-Client-Dialed-By-MS" code="1233" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Additional-Content-Information" code="1207" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Type-Number" required="false" max="1"/>
+				<rule avp="Additional-Type-Information" required="false" max="1"/>
+				<rule avp="Content-Size" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Additional-Type-Information" code="1205" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="LCS-Client-External-Id" code="1234" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Address-Data" code="897" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="LCS-Client-Id" code="1232" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Address-Domain" code="898" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="LCS-Client-Type" required="false" max="1"/>
-				<rule avp="LCS-Client-External-Id" required="false" max="1"/>
-				<rule avp="LCS-Client-Dialed-By-MS" required="false" max="1"/>
-				<rule avp="LCS-Client-Name" required="false" max="1"/>
-				<rule avp="LCS-APN" required="false" max="1"/>
-				<rule avp="LCS-Requestor-Id" required="false" max="1"/>
+				<rule avp="Domain-Name" required="false" max="1"/>
+				<rule avp="TGPP-IMSI-MCC-MNC" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="LCS-Client-Name" code="1235" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="LCS-Data-Coding-Scheme" required="false" max="1"/>
-				<rule avp="LCS-Name-String" required="false" max="1"/>
-				<rule avp="LCS-Format-Indicator" required="false" max="1"/>
+		<avp name="Addressee-Type" code="1208" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="TO"/>
+				<item code="1" name="CC"/>
+				<item code="2" name="BCC"/>
 			</data>
 		</avp>
 
-		<avp name="LCS-Client-Type" code="1241" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Address-Type" code="899" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="EMERGENCY_SERVICES"/>
-				<item code="1" name="VALUE_ADDED_SERVICES"/>
-				<item code="2" name="PLMN_OPERATOR_SERVICES"/>
-				<item code="3" name="LAWFUL_INTERCEPT_SERVICES"/>
+				<item code="0" name="e-mail address"/>
+				<item code="1" name="MSISDN"/>
+				<item code="2" name="IPv4 Address"/>
+				<item code="3" name="IPv6 Address"/>
+				<item code="4" name="Numeric Shortcode"/>
+				<item code="5" name="Alphanumeric Shortcode"/>
+				<item code="6" name="Other"/>
+				<item code="7" name="IMSI"/>
 			</data>
 		</avp>
 
-		<avp name="LCS-Data-Coding-Scheme" code="1236" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="AF-Charging-Identifier" code="505" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="LCS-Format-Indicator" code="1237" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="LOGICAL_NAME"/>
-				<item code="1" name="EMAIL_ADDRESS"/>
-				<item code="2" name="MSISDN"/>
-				<item code="3" name="URL"/>
-				<item code="4" name="SIP_URL"/>
+		<avp name="AF-Correlation-Information" code="1276" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="AF-Charging-Identifier" required="true" max="1"/>
+				<rule avp="Flows" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="LCS-Information" code="878" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Allocation-Retention-Priority" code="1034" must="V" may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="LCS-Client-Id" required="false" max="1"/>
-				<rule avp="Location-Type" required="false" max="1"/>
-				<rule avp="Location-Estimate" required="false" max="1"/>
-				<rule avp="Positioning-Data" required="false" max="1"/>
-				<rule avp="TGPP-IMSI" required="false" max="1"/>
-				<rule avp="MSISDN" required="false" max="1"/>
+				<rule avp="Priority-Level" required="true" max="1"/>
+				<rule avp="Pre-emption-Capability" required="false" max="1"/>
+				<rule avp="Pre-emption-Vulnerability" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="LCS-Name-String" code="1238" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Alternate-Charged-Party-Address" code="1280" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="LCS-Requestor-Id" code="1239" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="AoC-Cost-Information" code="2053" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="LCS-Data-Coding-Scheme" required="false" max="1"/>
-				<rule avp="LCS-Requestor-Id-String" required="false" max="1"/>
+				<rule avp="Accumulated-Cost" required="false" max="1"/>
+				<rule avp="Incremental-Cost" required="false"/>
+				<rule avp="Currency-Code" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="LCS-Requestor-Id-String" code="1240" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="Local-GW-Inserted-Indication" code="2604" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="AoC-Format" code="2310" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Local GW Not Inserted"/>
-				<item code="1" name="Local GW Inserted"/>
+				<item code="0" name="MONETARY"/>
+				<item code="1" name="NON_MONETARY"/>
+				<item code="2" name="CAI"/>
 			</data>
 		</avp>
 
-		<avp name="Local-Sequence-Number" code="2063" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
-		</avp>
-
-		<avp name="Location-Estimate" code="1242" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="AoC-Information" code="2054" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="AoC-Cost-Information" required="false" max="1"/>
+				<rule avp="Tariff-Information" required="false" max="1"/>
+				<rule avp="AoC-Subscription-Information" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Location-Estimate-Type" code="1243" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="AoC-Request-Type" code="2055" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="CURRENT_LOCATION"/>
-				<item code="1" name="CURRENT_LAST_KNOWN_LOCATION"/>
-				<item code="2" name="INITIAL_LOCATION"/>
-				<item code="3" name="ACTIVATE_DEFERRED_LOCATION"/>
-				<item code="4" name="CANCEL_DEFERRED_LOCATION"/>
+				<item code="0" name="AoC_NOT_REQUESTED"/>
+				<item code="1" name="AoC_FULL"/>
+				<item code="2" name="AoC_COST_ONLY"/>
+				<item code="3" name="AoC_TARIFF_ONLY"/>
 			</data>
 		</avp>
 
-		<avp name="Location-Type" code="1244" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="AoC-Service" code="2311" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Location-Estimate-Type" required="false" max="1"/>
-				<rule avp="Deferred-Location-Event-Type" required="false" max="1"/>
+				<rule avp="AoC-Service-Obligatory-Type" required="false" max="1"/>
+				<rule avp="AoC-Service-Type" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Low-Balance-Indication" code="2020" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="AoC-Service-Obligatory-Type" code="2312" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="NOT-APPLICABLE"/>
-				<item code="1" name="YES"/>
+				<item code="0" name="NON_BINDING"/>
+				<item code="1" name="BINDING"/>
 			</data>
 		</avp>
 
-		<avp name="Low-Priority-Indicator" code="2602" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="AoC-Service-Type" code="2313" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="NO"/>
-				<item code="1" name="YES"/>
+				<item code="0" name="NONE"/>
+				<item code="1" name="AOC-S"/>
+				<item code="2" name="AOC-D"/>
+				<item code="3" name="AOC-E"/>
 			</data>
 		</avp>
 
-		<avp name="Mandatory-Capability" code="604" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="AoC-Subscription-Information" code="2314" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="AoC-Service" required="false"/>
+				<rule avp="AoC-Format" required="false" max="1"/>
+				<rule avp="Preferred-AoC-Currency" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Max-Requested-Bandwidth-DL" code="515" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="Application-Port-Identifer" code="3010" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Max-Requested-Bandwidth-UL" code="516" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Application-Provided-Called-Party-Address" code="837" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MBMS-2G-3G-Indicator" code="907" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="2G"/>
-				<item code="1" name="3G"/>
-				<item code="2" name="2G-AND-3G"/>
-			</data>
+		<avp name="Application-Server" code="836" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MBMS-Charged-Party" code="2323" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Content Provider"/>
-				<item code="1" name="Subscriber"/>
-			</data>
-		</avp>
-
-		<avp name="MBMS-GW-Address" code="2307" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
-		</avp>
-
-		<avp name="MBMS-Information" code="880" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="TMGI" required="false" max="1"/>
-				<rule avp="MBMS-Service-Type" required="false" max="1"/>
-				<rule avp="MBMS-User-Service-Type" required="false" max="1"/>
-				<rule avp="File-Repair-Supported" required="false" max="1"/>
-				<rule avp="Required-MBMS-Bearer-Capabilities" required="false" max="1"/>
-				<rule avp="MBMS-2G-3G-Indicator" required="false" max="1"/>
-				<rule avp="RAI" required="false" max="1"/>
-				<rule avp="MBMS-Service-Area" required="false"/>
-				<rule avp="MBMS-Session-Identity" required="false" max="1"/>
-				<rule avp="CN-IP-Multicast-Distribution" required="false" max="1"/>
-				<rule avp="MBMS-GW-Address" required="false" max="1"/>
-				<rule avp="MBMS-Charged-Party" required="false" max="1"/>
-				<rule avp="MSISDN" required="false"/>
-			</data>
+		<avp name="Application-Server-Id" code="2101" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MBMS-Service-Area" code="903" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="Application-Service-Provider-Identity" code="532" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="MBMS-Service-Type" code="906" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="MULTICAST"/>
-				<item code="1" name="BROADCAST"/>
+		<avp name="Application-Server-Information" code="850" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Application-Server" required="false" max="1"/>
+				<rule avp="Application-Provided-Called-Party-Address" required="false"/>
+				<rule avp="Status- AS-Code" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="MBMS-Session-Identity" code="908" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
-
-		<avp name="MBMS-User-Service-Type" code="1225" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="1" name="DOWNLOAD"/>
-				<item code="2" name="STREAMING"/>
-			</data>
+		<avp name="Application-Session-Id" code="2103" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Media-Initiator-Flag" code="882" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="called party"/>
-				<item code="1" name="calling party"/>
-				<item code="2" name="unknown"/>
-			</data>
+		<avp name="Applic-Id" code="1218" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Media-Initiator-Party" code="1288" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Associated-Party-Address" code="2035" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Message-Body" code="889" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Content-Type" required="true" max="1"/>
-				<rule avp="Content-Length" required="true" max="1"/>
-				<rule avp="Content-Disposition" required="false" max="1"/>
-				<rule avp="Originator" required="false" max="1"/>
-			</data>
+		<avp name="Associated-URI" code="856" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Message-Class" code="1213" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Class-Identifier" required="false" max="1"/>
-				<rule avp="Token-Text" required="false" max="1"/>
-			</data>
+		<avp name="Authorised-QoS" code="849" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Message-Id" code="1210" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Aux-Applic-Info" code="1219" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Message-Size" code="1212" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Base-Time-Interval" code="1265" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Message-Type" code="1211" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="1" name="m-send-req"/>
-				<item code="2" name="m-send-conf"/>
-				<item code="3" name="m-notification-ind"/>
-				<item code="4" name="m-notifyresp-ind"/>
-				<item code="5" name="m-retrieve-conf"/>
-				<item code="6" name="m-acknowledge-ind"/>
-				<item code="7" name="m-delivery-ind"/>
-				<item code="8" name="m-read-rec-ind"/>
-				<item code="9" name="m-read-orig-ind"/>
-				<item code="10" name="m-forward-req"/>
-				<item code="11" name="m-forward-conf"/>
-				<item code="12" name="m-mbox-store-conf"/>
-				<item code="13" name="m-mbox-view-conf"/>
-				<item code="14" name="m-mbox-upload-conf"/>
-				<item code="15" name="m-mbox-delete-conf"/>
-			</data>
-		</avp>
-
-		<avp name="MMBox-Storage-Requested" code="1248" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="No"/>
-				<item code="1" name="Yes"/>
-			</data>
-		</avp>
-
-		<avp name="MM-Content-Type" code="1203" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Basic-Service-Code" code="3411" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Type-Number" required="false" max="1"/>
-				<rule avp="Additional-Type-Information" required="false" max="1"/>
-				<rule avp="Content-Size" required="false" max="1"/>
-				<rule avp="Additional-Content-Information" required="false"/>
+				<rule avp="Bearer-Service" required="false" max="1"/>
+				<rule avp="Teleservice" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="MME-Name" code="2402" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="DiameterIdentity"/>
+		<avp name="Bearer-Capability" code="3412" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="MME-Number-for-MT-SMS" code="1645" must="V"	may="-" must-not="M" may-encrypt="N" vendor-id="10415">
+		<avp name="Bearer-Service" code="854" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="MME-Realm" code="2408" must="V"	may="-" must-not="M" may-encrypt="N" vendor-id="10415">
-			<data type="DiameterIdentity"/>
+		<avp name="BSSID" code="2716" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MMS-Information" code="877" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Originator-Address" required="false" max="1"/>
-				<rule avp="Recipient-Address" required="false"/>
-				<rule avp="Submission-Time" required="false" max="1"/>
-				<rule avp="MM-Content-Type" required="false" max="1"/>
-				<rule avp="Priority" required="false" max="1"/>
-				<rule avp="Message-Id" required="false" max="1"/>
-				<rule avp="Message-Type" required="false" max="1"/>
-				<rule avp="Message-Size" required="false" max="1"/>
-				<rule avp="Message-Class" required="false" max="1"/>
-				<rule avp="Delivery-Report-Requested" required="false" max="1"/>
-				<rule avp="Read-Reply-Report-Requested" required="false" max="1"/>
-				<rule avp="MMBox-Storage-Requested" required="false" max="1"/>
-				<rule avp="Applic-Id" required="false" max="1"/>
-				<rule avp="Reply-Applic-Id" required="false" max="1"/>
-				<rule avp="Aux-Applic-Info" required="false" max="1"/>
-				<rule avp="Content-Class" required="false" max="1"/>
-				<rule avp="DRM-Content" required="false" max="1"/>
-				<rule avp="Adaptations" required="false" max="1"/>
-				<rule avp="VASP-Id" required="false" max="1"/>
-				<rule avp="VAS-Id" required="false" max="1"/>
-			</data>
+		<avp name="Called-Asserted-Identity" code="1250" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MMTel-Information" code="2030" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Supplementary-Service" required="false"/>
-			</data>
+		<avp name="Called-Party-Address" code="832" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MMTel-SService-Type" code="2031" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Calling-Party-Address" code="831" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MSC-Address" code="3417" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Carrier-Select-Routing-Information" code="2023" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="MSISDN" code="701" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Cause-Code" code="861" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Integer32"/>
 		</avp>
 
-		<avp name="MTC-IWF-Address" code="3406" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="CG-Address" code="846" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Address"/>
 		</avp>
 
-		<avp name="Neighbour-Node-Address" code="2705" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Change-Condition" code="2037" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Integer32"/>
 		</avp>
 
-		<avp name="Network-Call-Reference-Number" code="3418" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Change-Time" code="2038" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="Next-Tariff" code="2057" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Currency-Code" required="false" max="1"/>
-				<rule avp="Scale-Factor" required="false" max="1"/>
-				<rule avp="Rate-Element" required="false"/>
+		<avp name="Charge-Reason-Code" code="2118" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="UNKNOWN"/>
+				<item code="1" name="USAGE"/>
+				<item code="2" name="COMMUNICATION-ATTEMPT-CHARGE"/>
+				<item code="3" name="SETUP-CHARGE"/>
+				<item code="4" name="ADD-ON-CHARGE"/>
 			</data>
 		</avp>
 
-		<avp name="NNI-Information" code="2703" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Session-Direction" required="false" max="1"/>
-				<rule avp="NNI-Type" required="false" max="1"/>
-				<rule avp="Relationship-Mode" required="false" max="1"/>
-				<rule avp="Neighbour-Node-Address" required="false" max="1"/>
-			</data>
+		<avp name="Charged-Party" code="857" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="NNI-Type" code="2704" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Charging-Characteristics-Selection-Mode" code="2066" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="non-roaming"/>
-				<item code="1" name="roaming without loopback"/>
-				<item code="2" name="roaming with loopback"/>
+				<item code="0" name="Serving-Node-Supplied"/>
+				<item code="1" name="Subscription-specific"/>
+				<item code="2" name="APN-specific"/>
+				<item code="3" name="Home-Default"/>
+				<item code="4" name="Roaming-Default"/>
+				<item code="5" name="Visiting-Default"/>
 			</data>
 		</avp>
 
-		<avp name="Node-Functionality" code="862" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Charging-Rule-Base-Name" code="1004" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Class-Identifier" code="1214" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="S-CSCF"/>
-				<item code="1" name="P-CSCF"/>
-				<item code="2" name="I-CSCF"/>
-				<item code="3" name="MRFC"/>
-				<item code="4" name="MGCF"/>
-				<item code="5" name="BGCF"/>
-				<item code="6" name="AS"/>
-				<item code="7" name="IBCF"/>
-				<item code="8" name="S-GW"/>
-				<item code="9" name="P-GW"/>
-				<item code="10" name="HSGW"/>
-				<item code="11" name="E-CSCF"/>
-				<item code="12" name="MME"/>
-				<item code="13" name="TRF"/>
-				<item code="14" name="TF"/>
-				<item code="15" name="ATCF"/>
-				<item code="16" name="Proxy Function"/>
-				<item code="17" name="ePDG"/>
+				<item code="0" name="Personal"/>
+				<item code="1" name="Advertisement"/>
+				<item code="2" name="Informational"/>
+				<item code="3" name="Auto"/>
 			</data>
 		</avp>
 
-		<avp name="Node-Id" code="2064" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Client-Address" code="2018" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
 		</avp>
 
-		<avp name="Number-Of-Diversions" code="2034" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="CN-IP-Multicast-Distribution" code="921" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="NO-IP-MULTICAST"/>
+				<item code="1" name="IP-MULTICAST"/>
+			</data>
 		</avp>
 
-		<avp name="Number-Of-Messages-Sent" code="2019" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="CN-Operator-Selection-Entity" code="3421" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="The Serving Network has been selected by the UE"/>
+				<item code="1" name="The Serving Network has been selected by the network"/>
+			</data>
 		</avp>
 
-		<avp name="Number-Of-Messages-Successfully-Exploded" code="2111" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Content-Class" code="1220" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="text"/>
+				<item code="1" name="image-basic"/>
+				<item code="2" name="image-rich"/>
+				<item code="3" name="video-basic"/>
+				<item code="4" name="video-rich"/>
+				<item code="5" name="megapixel"/>
+				<item code="6" name="content-basic"/>
+				<item code="7" name="content-rich"/>
+			</data>
 		</avp>
 
-		<avp name="Number-Of-Messages-Successfully-Sent" code="2112" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Content-Disposition" code="828" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Number-Of-Participants" code="885" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Content-Id" code="2116" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Number-Of-Received-Talk-Bursts" code="1282" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Content-Provider-Id" code="2117" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Number-Of-Talk-Bursts" code="1283" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Content-Length" code="827" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Number-Portability-Routing-Information" code="2024" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Content-Size" code="1206" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Offline-Charging" code="1278" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Quota-Consumption-Time" required="false" max="1"/>
-				<rule avp="Time-Quota-Mechanism" required="false" max="1"/>
-				<rule avp="Envelope-Reporting" required="false" max="1"/>
-				<rule avp="Multiple-Services-Credit-Control" required="false"/>
-				<rule avp="AVP" required="false"/>
+		<avp name="CSG-Access-Mode" code="2317" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Closed mode"/>
+				<item code="1" name="Hybrid Mode"/>
 			</data>
 		</avp>
 
-		<avp name="Online-Charging-Flag" code="2303" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="CSG-Id" code="1437" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="CSG-Membership-Indication" code="2318" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="ECF address not provided"/>
-				<item code="1" name="ECF address provided"/>
+				<item code="0" name="Not CSG member"/>
+				<item code="1" name="CSG Member"/>
 			</data>
 		</avp>
 
-		<avp name="Optional-Capability" code="605" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Content-Type" code="826" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Originating-IOI" code="839" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Current-Tariff" code="2056" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Currency-Code" required="false" max="1"/>
+				<rule avp="Scale-Factor" required="false" max="1"/>
+				<rule avp="Rate-Element" required="false"/>
+			</data>
 		</avp>
 
-		<avp name="Originator-SCCP-Address" code="2008" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="CUG-Information" code="2304" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Originator" code="864" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Calling Party"/>
-				<item code="1" name="Called Party"/>
-			</data>
+		<avp name="Data-Coding-Scheme" code="2001" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Integer32"/>
 		</avp>
 
-		<avp name="Originator-Address" code="886" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Address-Type" required="false" max="1"/>
-				<rule avp="Address-Data" required="false" max="1"/>
-				<rule avp="Address-Domain" required="false" max="1"/>
-			</data>
+		<avp name="Deferred-Location-Event-Type" code="1230" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Originator-Received-Address" code="2027" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Address-Type" required="false" max="1"/>
-				<rule avp="Address-Data" required="false" max="1"/>
-				<rule avp="Address-Domain" required="false" max="1"/>
+		<avp name="Delivery-Report-Requested" code="1216" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="No"/>
+				<item code="1" name="Yes"/>
 			</data>
 		</avp>
 
-		<avp name="Originator-Interface" code="2009" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Delivery-Status" code="2104" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Destination-Interface" code="2002" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
 				<rule avp="Interface-Id" required="true" max="1"/>
 				<rule avp="Interface-Text" required="true" max="1"/>
@@ -3171,1150 +3833,2634 @@ var tgpprorfXML = `<?xml version="1.0" encoding="UTF-8"?>
 			</data>
 		</avp>
 
-		<avp name="Outgoing-Session-Id" code="2320" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Diagnostics" code="2039" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Integer32"/>
 		</avp>
 
-		<avp name="Outgoing-Trunk-Group-Id" code="853" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Domain-Name" code="1200" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Participant-Access-Priority" code="1259" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="DRM-Content" code="1221" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="1" name="Pre-emptive priority"/>
-				<item code="2" name="High priority"/>
-				<item code="3" name="Normal priority"/>
-				<item code="4" name="Low priority"/>
+				<item code="0" name="No"/>
+				<item code="1" name="Yes"/>
 			</data>
 		</avp>
 
-		<avp name="Participant-Action-Type" code="2049" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Dynamic-Address-Flag" code="2051" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="CREATE_CONF"/>
-				<item code="1" name="JOIN_CONF"/>
-				<item code="2" name="INVITE_INTO_CONF"/>
-				<item code="3" name="QUIT_CONF"/>
+				<item code="0" name="Static"/>
+				<item code="1" name="Dynamic"/>
 			</data>
 		</avp>
 
-		<avp name="Participant-Group" code="1260" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Called-Party-Address" required="false" max="1"/>
-				<rule avp="Participant-Access-Priority" required="false" max="1"/>
-				<rule avp="User-Participating-Type" required="false" max="1"/>
+		<avp name="Dynamic-Address-Flag-Extension" code="2068" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Static"/>
+				<item code="1" name="Dynamic"/>
 			</data>
 		</avp>
 
-		<avp name="Participants-Involved" code="887" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="PDN-Connection-Charging-Id" code="2050" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Early-Media-Description" code="1272" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SDP-TimeStamps" required="false" max="1"/>
+				<rule avp="SDP-Media-Component" required="false"/>
+				<rule avp="SDP-Session-Description" required="false"/>
+			</data>
 		</avp>
 
-		<avp name="PDP-Address" code="1227" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="ePDG-Address" code="3425" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Address"/>
 		</avp>
 
-		<avp name="PDP-Context-Type" code="1247" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Primary"/>
-				<item code="1" name="Secondary"/>
+		<avp name="Envelope" code="1266" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Envelope-Start-Time" required="true" max="1"/>
+				<rule avp="Envelope-End-Time" required="false" max="1"/>
+				<rule avp="CC-Total-Octets" required="false" max="1"/>
+				<rule avp="CC-Input-Octets" required="false" max="1"/>
+				<rule avp="CC-Output-Octets" required="false" max="1"/>
+				<rule avp="CC-Service-Specific-Units" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="PDP-Address-Prefix-Length" code="2606" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Envelope-End-Time" code="1267" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="PoC-Change-Condition" code="1261" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Envelope-Reporting" code="1268" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="ServiceChange"/>
-				<item code="1" name="VolumeLimit"/>
-				<item code="2" name="TimeLimit"/>
-				<item code="3" name="NumberofTalkBurstLimit"/>
-				<item code="4" name="NumberofActiveParticipants"/>
-				<item code="5" name="TariffTime"/>
+				<item code="0" name="DO_NOT_REPORT_ENVELOPES"/>
+				<item code="1" name="REPORT_ENVELOPES"/>
+				<item code="2" name="REPORT_ENVELOPES_WITH_VOLUME"/>
+				<item code="3" name="REPORT_ENVELOPES_WITH_EVENTS"/>
+				<item code="4" name="REPORT_ENVELOPES_WITH_VOLUME_AND_EVENTS"/>
 			</data>
 		</avp>
 
-		<avp name="PoC-Change-Time" code="1262" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Envelope-Start-Time" code="1269" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Time"/>
 		</avp>
 
-		<avp name="PoC-Controlling-Address" code="858" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Event" code="825" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="PoC-Event-Type" code="2025" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Normal"/>
-				<item code="1" name="Instant Ppersonal Aalert event"/>
-				<item code="2" name="PoC Group Advertisement event"/>
-				<item code="3" name="Early Ssession Setting-up event"/>
-				<item code="4" name="PoC Talk Burst"/>
-			</data>
-		</avp>
-
-		<avp name="PoC-Group-Name" code="859" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Event-Charging-TimeStamp" code="1258" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="PoC-Information" code="879" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Event-Type" code="823" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="PoC-Server-Role" required="false" max="1"/>
-				<rule avp="PoC-Session-Type" required="false" max="1"/>
-				<rule avp="PoC-User-Role" required="false" max="1"/>
-				<rule avp="PoC-Session-Initiation-type" required="false" max="1"/>
-				<rule avp="PoC-Event-Type" required="false" max="1"/>
-				<rule avp="Number-Of-Participants" required="false" max="1"/>
-				<rule avp="Participants-Involved" required="false"/>
-				<rule avp="Participant-Group" required="false"/>
-				<rule avp="Talk-Burst-Exchange" required="false"/>
-				<rule avp="PoC-Controlling-Address" required="false" max="1"/>
-				<rule avp="PoC-Group-Name" required="false" max="1"/>
-				<rule avp="PoC-Session-Id" required="false" max="1"/>
-				<rule avp="Charged-Party" required="false" max="1"/>
-			</data>
-		</avp>
-
-		<avp name="PoC-Server-Role" code="883" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Participating PoC Server"/>
-				<item code="1" name="Controlling PoC Server"/>
+				<rule avp="SIP-Method" required="false" max="1"/>
+				<rule avp="Event" required="false" max="1"/>
+				<rule avp="Expires" required="true" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="PoC-Session-Id" code="1229" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Expires" code="888" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="PoC-Session-Initiation-type" code="1277" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="File-Repair-Supported" code="1224" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Pre-established"/>
-				<item code="1" name="On-demand"/>
+				<item code="0" name="SUPPORTED"/>
+				<item code="1" name="NOT_SUPPORTED"/>
 			</data>
 		</avp>
 
-		<avp name="PoC-Session-Type" code="884" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="1 to 1 PoC session"/>
-				<item code="1" name="Chat PoC group session"/>
-				<item code="2" name="Pre-arranged PoC group session"/>
-				<item code="3" name="Ad-hoc PoC group session"/>
+		<avp name="Fixed-User-Location-Info" code="2825" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SSID" required="false" max="1"/>
+				<rule avp="BSSID" required="false" max="1"/>
+				<rule avp="Logical-Access-Id" required="true" max="1"/>
+				<rule avp="Physical-Access-Id" required="true" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="PoC-User-Role" code="1252" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Flows" code="510" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="PoC-User-Role-Ids" required="false" max="1"/>
-				<rule avp="PoC-User-Role-info-Units" required="false" max="1"/>
+				<rule avp="Media-Component-Number" required="true" max="1"/>
+				<rule avp="Flow-Number" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="PoC-User-Role-Ids" code="1253" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="From-Address" code="2708" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="PoC-User-Role-info-Units" code="1254" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Forwarding-Pending" code="3415" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="1" name="Moderator"/>
-				<item code="2" name="Dispatcher"/>
-				<item code="3" name="Session-Owner"/>
-				<item code="4" name="Session-Participant"/>
+				<item code="0" name="Forwarding not pending"/>
+				<item code="1" name="Forwarding pending"/>
 			</data>
 		</avp>
 
-		<avp name="Positioning-Data" code="1245" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="GGSN-Address" code="847" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
 		</avp>
 
-		<avp name="Preferred-AoC-Currency" code="2315" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Guaranteed-Bitrate-UL" code="1026" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="IMS-Application-Reference-Identifier" code="2601" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="IMS-Charging-Identifier" code="841" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="IMS-Communication-Service-Identifier" code="1281" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="IMS-Emergency-Indicator" code="2322" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Non Emergency"/>
+				<item code="1" name="Emergency"/>
+			</data>
+		</avp>
+
+		<avp name="IMS-Information" code="876" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Event-Type" required="false" max="1"/>
+				<rule avp="Role-Of-Node" required="false" max="1"/>
+				<rule avp="Node-Functionality" required="true" max="1"/>
+				<rule avp="User-Session-Id" required="false" max="1"/>
+				<rule avp="Outgoing-Session-Id" required="false" max="1"/>
+				<rule avp="Session-Priority" required="false" max="1"/>
+				<rule avp="Calling-Party-Address" required="false"/>
+				<rule avp="Called-Party-Address" required="false" max="1"/>
+				<rule avp="Called-Asserted-Identity" required="false"/>
+				<rule avp="Number-Portability-Routing-Information" required="false" max="1"/>
+				<rule avp="Carrier-Select-Routing-Information" required="false" max="1"/>
+				<rule avp="Alternate-Charged-Party-Address" required="false" max="1"/>
+				<rule avp="Requested-Party-Address" required="false"/>
+				<rule avp="Associated-URI" required="false"/>
+				<rule avp="Time-Stamps" required="false" max="1"/>
+				<rule avp="Application-Server-Information" required="false"/>
+				<rule avp="Inter-Operator-Identifier" required="false"/>
+				<rule avp="Transit-IOI-List" required="false"/>
+				<rule avp="IMS-Charging-Identifier" required="false" max="1"/>
+				<rule avp="SDP-Session-Description" required="false"/>
+				<rule avp="SDP-Media-Component" required="false"/>
+				<rule avp="Served-Party-IP-Address" required="false" max="1"/>
+				<rule avp="Server-Capabilities" required="false" max="1"/>
+				<rule avp="Trunk-Group-Id" required="false" max="1"/>
+				<rule avp="Bearer-Service" required="false" max="1"/>
+				<rule avp="Service-Id" required="false" max="1"/>
+				<rule avp="Service-Specific-Info" required="false"/>
+				<rule avp="Message-Body" required="false"/>
+				<rule avp="Cause-Code" required="false" max="1"/>
+				<rule avp="Reason-Header" required="false"/>
+				<rule avp="Access-Network-Information" required="false"/>
+				<rule avp="Early-Media-Description" required="false"/>
+				<rule avp="IMS-Communication-Service-Identifier" required="false" max="1"/>
+				<rule avp="IMS-Application-Reference-Identifier" required="false" max="1"/>
+				<rule avp="Online-Charging-Flag" required="false" max="1"/>
+				<rule avp="Real-Time-Tariff-Information" required="false" max="1"/>
+				<rule avp="Account-Expiration" required="false" max="1"/>
+				<rule avp="Initial-IMS-Charging-Identifier" required="false" max="1"/>
+				<rule avp="NNI-Information" required="false"/>
+				<rule avp="From-Address" required="false" max="1"/>
+				<rule avp="IMS-Emergency-Indicator" required="false" max="1"/>
+				<rule avp="IMS-Visited-Network-Identifier" required="false" max="1"/>
+				<rule avp="Access-Transfer-Information" required="false"/>
+				<rule avp="Related-IMS-Charging-Identifier" required="false" max="1"/>
+				<rule avp="Related-IMS-Charging-Identifier-Node" required="false" max="1"/>
+				<rule avp="Route-Header-Received" required="false" max="1"/>
+				<rule avp="Route-Header-Transmitted" required="false" max="1"/>
+				<rule avp="Instance-Id" required="false" max="1"/>
+				<rule avp="TAD-Identifier" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="IMS-Visited-Network-Identifier" code="2713" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="IMSI-Unauthenticated-Flag" code="2308" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Authenticated"/>
+				<item code="1" name="Unauthenticated"/>
+			</data>
+		</avp>
+
+		<avp name="Incoming-Trunk-Group-Id" code="852" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Incremental-Cost" code="2062" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Value-Digits" required="true" max="1"/>
+				<rule avp="Exponent" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Initial-IMS-Charging-Identifier" code="2321" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Instance-Id" code="3402" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Interface-Id" code="2003" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Interface-Port" code="2004" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Interface-Text" code="2005" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Interface-Type" code="2006" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Unknown"/>
+				<item code="1" name="MOBILE_ORIGINATING"/>
+				<item code="2" name="MOBILE_TERMINATING"/>
+				<item code="3" name="APPLICATION_ORIGINATING"/>
+				<item code="4" name="APPLICATION_TERMINATION"/>
+			</data>
+		</avp>
+
+		<avp name="Inter-Operator-Identifier" code="838" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Originating-IOI" required="false" max="1"/>
+				<rule avp="Terminating-IOI" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="IP-Realm-Default-Indication" code="2603" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Default IP Realm Not used"/>
+				<item code="1" name="Default IP realm used"/>
+			</data>
+		</avp>
+
+		<avp name="ISUP-Cause" code="3416" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="ISUP-Cause-Location" required="false" max="1"/>
+				<rule avp="ISUP-Cause-Value" required="false" max="1"/>
+				<rule avp="ISUP-Cause-Diagnostic" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="ISUP-Cause-Diagnostics" code="3422" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="ISUP-Cause-Location" code="3423" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="ISUP-Cause-Value" code="3424" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="ISUP-Location-Number" code="3414" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="LCS-APN" code="1231" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="LCS-Client-Dialed-By-MS" code="1233" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="LCS-Client-External-Id" code="1234" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="LCS-Client-Id" code="1232" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Client-Type" required="false" max="1"/>
+				<rule avp="LCS-Client-External-Id" required="false" max="1"/>
+				<rule avp="LCS-Client-Dialed-By-MS" required="false" max="1"/>
+				<rule avp="LCS-Client-Name" required="false" max="1"/>
+				<rule avp="LCS-APN" required="false" max="1"/>
+				<rule avp="LCS-Requestor-Id" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Client-Name" code="1235" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Data-Coding-Scheme" required="false" max="1"/>
+				<rule avp="LCS-Name-String" required="false" max="1"/>
+				<rule avp="LCS-Format-Indicator" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Client-Type" code="1241" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="EMERGENCY_SERVICES"/>
+				<item code="1" name="VALUE_ADDED_SERVICES"/>
+				<item code="2" name="PLMN_OPERATOR_SERVICES"/>
+				<item code="3" name="LAWFUL_INTERCEPT_SERVICES"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Data-Coding-Scheme" code="1236" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="LCS-Format-Indicator" code="1237" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="LOGICAL_NAME"/>
+				<item code="1" name="EMAIL_ADDRESS"/>
+				<item code="2" name="MSISDN"/>
+				<item code="3" name="URL"/>
+				<item code="4" name="SIP_URL"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Information" code="878" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Client-Id" required="false" max="1"/>
+				<rule avp="Location-Type" required="false" max="1"/>
+				<rule avp="Location-Estimate" required="false" max="1"/>
+				<rule avp="Positioning-Data" required="false" max="1"/>
+				<rule avp="TGPP-IMSI" required="false" max="1"/>
+				<rule avp="MSISDN" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Name-String" code="1238" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="LCS-Requestor-Id" code="1239" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Data-Coding-Scheme" required="false" max="1"/>
+				<rule avp="LCS-Requestor-Id-String" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Requestor-Id-String" code="1240" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Local-GW-Inserted-Indication" code="2604" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Local GW Not Inserted"/>
+				<item code="1" name="Local GW Inserted"/>
+			</data>
+		</avp>
+
+		<avp name="Local-Sequence-Number" code="2063" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Location-Estimate" code="1242" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Location-Estimate-Type" code="1243" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="CURRENT_LOCATION"/>
+				<item code="1" name="CURRENT_LAST_KNOWN_LOCATION"/>
+				<item code="2" name="INITIAL_LOCATION"/>
+				<item code="3" name="ACTIVATE_DEFERRED_LOCATION"/>
+				<item code="4" name="CANCEL_DEFERRED_LOCATION"/>
+			</data>
+		</avp>
+
+		<avp name="Location-Type" code="1244" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Location-Estimate-Type" required="false" max="1"/>
+				<rule avp="Deferred-Location-Event-Type" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Low-Balance-Indication" code="2020" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="NOT-APPLICABLE"/>
+				<item code="1" name="YES"/>
+			</data>
+		</avp>
+
+		<avp name="Low-Priority-Indicator" code="2602" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="NO"/>
+				<item code="1" name="YES"/>
+			</data>
+		</avp>
+
+		<avp name="Mandatory-Capability" code="604" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Max-Requested-Bandwidth-DL" code="515" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Max-Requested-Bandwidth-UL" code="516" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MBMS-2G-3G-Indicator" code="907" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="2G"/>
+				<item code="1" name="3G"/>
+				<item code="2" name="2G-AND-3G"/>
+			</data>
+		</avp>
+
+		<avp name="MBMS-Charged-Party" code="2323" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Content Provider"/>
+				<item code="1" name="Subscriber"/>
+			</data>
+		</avp>
+
+		<avp name="MBMS-GW-Address" code="2307" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="MBMS-Information" code="880" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="TMGI" required="false" max="1"/>
+				<rule avp="MBMS-Service-Type" required="false" max="1"/>
+				<rule avp="MBMS-User-Service-Type" required="false" max="1"/>
+				<rule avp="File-Repair-Supported" required="false" max="1"/>
+				<rule avp="Required-MBMS-Bearer-Capabilities" required="false" max="1"/>
+				<rule avp="MBMS-2G-3G-Indicator" required="false" max="1"/>
+				<rule avp="RAI" required="false" max="1"/>
+				<rule avp="MBMS-Service-Area" required="false"/>
+				<rule avp="MBMS-Session-Identity" required="false" max="1"/>
+				<rule avp="CN-IP-Multicast-Distribution" required="false" max="1"/>
+				<rule avp="MBMS-GW-Address" required="false" max="1"/>
+				<rule avp="MBMS-Charged-Party" required="false" max="1"/>
+				<rule avp="MSISDN" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="MBMS-Service-Area" code="903" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MBMS-Service-Type" code="906" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="MULTICAST"/>
+				<item code="1" name="BROADCAST"/>
+			</data>
+		</avp>
+
+		<avp name="MBMS-Session-Identity" code="908" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MBMS-User-Service-Type" code="1225" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="1" name="DOWNLOAD"/>
+				<item code="2" name="STREAMING"/>
+			</data>
+		</avp>
+
+		<avp name="Media-Initiator-Flag" code="882" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="called party"/>
+				<item code="1" name="calling party"/>
+				<item code="2" name="unknown"/>
+			</data>
+		</avp>
+
+		<avp name="Media-Initiator-Party" code="1288" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Message-Body" code="889" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Content-Type" required="true" max="1"/>
+				<rule avp="Content-Length" required="true" max="1"/>
+				<rule avp="Content-Disposition" required="false" max="1"/>
+				<rule avp="Originator" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Message-Class" code="1213" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Class-Identifier" required="false" max="1"/>
+				<rule avp="Token-Text" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Message-Id" code="1210" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Message-Size" code="1212" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Message-Type" code="1211" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="1" name="m-send-req"/>
+				<item code="2" name="m-send-conf"/>
+				<item code="3" name="m-notification-ind"/>
+				<item code="4" name="m-notifyresp-ind"/>
+				<item code="5" name="m-retrieve-conf"/>
+				<item code="6" name="m-acknowledge-ind"/>
+				<item code="7" name="m-delivery-ind"/>
+				<item code="8" name="m-read-rec-ind"/>
+				<item code="9" name="m-read-orig-ind"/>
+				<item code="10" name="m-forward-req"/>
+				<item code="11" name="m-forward-conf"/>
+				<item code="12" name="m-mbox-store-conf"/>
+				<item code="13" name="m-mbox-view-conf"/>
+				<item code="14" name="m-mbox-upload-conf"/>
+				<item code="15" name="m-mbox-delete-conf"/>
+			</data>
+		</avp>
+
+		<avp name="MMBox-Storage-Requested" code="1248" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="No"/>
+				<item code="1" name="Yes"/>
+			</data>
+		</avp>
+
+		<avp name="MM-Content-Type" code="1203" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Type-Number" required="false" max="1"/>
+				<rule avp="Additional-Type-Information" required="false" max="1"/>
+				<rule avp="Content-Size" required="false" max="1"/>
+				<rule avp="Additional-Content-Information" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="MME-Name" code="2402" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="MME-Number-for-MT-SMS" code="1645" must="V"	may="-" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MME-Realm" code="2408" must="V"	may="-" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="MMS-Information" code="877" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Originator-Address" required="false" max="1"/>
+				<rule avp="Recipient-Address" required="false"/>
+				<rule avp="Submission-Time" required="false" max="1"/>
+				<rule avp="MM-Content-Type" required="false" max="1"/>
+				<rule avp="Priority" required="false" max="1"/>
+				<rule avp="Message-Id" required="false" max="1"/>
+				<rule avp="Message-Type" required="false" max="1"/>
+				<rule avp="Message-Size" required="false" max="1"/>
+				<rule avp="Message-Class" required="false" max="1"/>
+				<rule avp="Delivery-Report-Requested" required="false" max="1"/>
+				<rule avp="Read-Reply-Report-Requested" required="false" max="1"/>
+				<rule avp="MMBox-Storage-Requested" required="false" max="1"/>
+				<rule avp="Applic-Id" required="false" max="1"/>
+				<rule avp="Reply-Applic-Id" required="false" max="1"/>
+				<rule avp="Aux-Applic-Info" required="false" max="1"/>
+				<rule avp="Content-Class" required="false" max="1"/>
+				<rule avp="DRM-Content" required="false" max="1"/>
+				<rule avp="Adaptations" required="false" max="1"/>
+				<rule avp="VASP-Id" required="false" max="1"/>
+				<rule avp="VAS-Id" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="MMTel-Information" code="2030" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Supplementary-Service" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="MMTel-SService-Type" code="2031" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="MSC-Address" code="3417" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MSISDN" code="701" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="MTC-IWF-Address" code="3406" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="Neighbour-Node-Address" code="2705" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="Network-Call-Reference-Number" code="3418" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Next-Tariff" code="2057" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Currency-Code" required="false" max="1"/>
+				<rule avp="Scale-Factor" required="false" max="1"/>
+				<rule avp="Rate-Element" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="NNI-Information" code="2703" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Session-Direction" required="false" max="1"/>
+				<rule avp="NNI-Type" required="false" max="1"/>
+				<rule avp="Relationship-Mode" required="false" max="1"/>
+				<rule avp="Neighbour-Node-Address" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="NNI-Type" code="2704" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="non-roaming"/>
+				<item code="1" name="roaming without loopback"/>
+				<item code="2" name="roaming with loopback"/>
+			</data>
+		</avp>
+
+		<avp name="Node-Functionality" code="862" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="S-CSCF"/>
+				<item code="1" name="P-CSCF"/>
+				<item code="2" name="I-CSCF"/>
+				<item code="3" name="MRFC"/>
+				<item code="4" name="MGCF"/>
+				<item code="5" name="BGCF"/>
+				<item code="6" name="AS"/>
+				<item code="7" name="IBCF"/>
+				<item code="8" name="S-GW"/>
+				<item code="9" name="P-GW"/>
+				<item code="10" name="HSGW"/>
+				<item code="11" name="E-CSCF"/>
+				<item code="12" name="MME"/>
+				<item code="13" name="TRF"/>
+				<item code="14" name="TF"/>
+				<item code="15" name="ATCF"/>
+				<item code="16" name="Proxy Function"/>
+				<item code="17" name="ePDG"/>
+			</data>
+		</avp>
+
+		<avp name="Node-Id" code="2064" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Number-Of-Diversions" code="2034" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Of-Messages-Sent" code="2019" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Of-Messages-Successfully-Exploded" code="2111" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Of-Messages-Successfully-Sent" code="2112" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Of-Participants" code="885" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Of-Received-Talk-Bursts" code="1282" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Of-Talk-Bursts" code="1283" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Number-Portability-Routing-Information" code="2024" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Offline-Charging" code="1278" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Quota-Consumption-Time" required="false" max="1"/>
+				<rule avp="Time-Quota-Mechanism" required="false" max="1"/>
+				<rule avp="Envelope-Reporting" required="false" max="1"/>
+				<rule avp="Multiple-Services-Credit-Control" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="Online-Charging-Flag" code="2303" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="ECF address not provided"/>
+				<item code="1" name="ECF address provided"/>
+			</data>
+		</avp>
+
+		<avp name="Optional-Capability" code="605" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Originating-IOI" code="839" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Originator-SCCP-Address" code="2008" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="Originator" code="864" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Calling Party"/>
+				<item code="1" name="Called Party"/>
+			</data>
+		</avp>
+
+		<avp name="Originator-Address" code="886" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Address-Type" required="false" max="1"/>
+				<rule avp="Address-Data" required="false" max="1"/>
+				<rule avp="Address-Domain" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Originator-Received-Address" code="2027" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Address-Type" required="false" max="1"/>
+				<rule avp="Address-Data" required="false" max="1"/>
+				<rule avp="Address-Domain" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Originator-Interface" code="2009" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Interface-Id" required="true" max="1"/>
+				<rule avp="Interface-Text" required="true" max="1"/>
+				<rule avp="Interface-Port" required="false" max="1"/>
+				<rule avp="Interface-Type" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Outgoing-Session-Id" code="2320" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Outgoing-Trunk-Group-Id" code="853" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Participant-Access-Priority" code="1259" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="1" name="Pre-emptive priority"/>
+				<item code="2" name="High priority"/>
+				<item code="3" name="Normal priority"/>
+				<item code="4" name="Low priority"/>
+			</data>
+		</avp>
+
+		<avp name="Participant-Action-Type" code="2049" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="CREATE_CONF"/>
+				<item code="1" name="JOIN_CONF"/>
+				<item code="2" name="INVITE_INTO_CONF"/>
+				<item code="3" name="QUIT_CONF"/>
+			</data>
+		</avp>
+
+		<avp name="Participant-Group" code="1260" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Called-Party-Address" required="false" max="1"/>
+				<rule avp="Participant-Access-Priority" required="false" max="1"/>
+				<rule avp="User-Participating-Type" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Participants-Involved" code="887" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="PDN-Connection-Charging-Id" code="2050" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="PDP-Address" code="1227" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="PDP-Context-Type" code="1247" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Primary"/>
+				<item code="1" name="Secondary"/>
+			</data>
+		</avp>
+
+		<avp name="PDP-Address-Prefix-Length" code="2606" must="V,M" may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="PoC-Change-Condition" code="1261" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="ServiceChange"/>
+				<item code="1" name="VolumeLimit"/>
+				<item code="2" name="TimeLimit"/>
+				<item code="3" name="NumberofTalkBurstLimit"/>
+				<item code="4" name="NumberofActiveParticipants"/>
+				<item code="5" name="TariffTime"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-Change-Time" code="1262" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="PoC-Controlling-Address" code="858" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="PoC-Event-Type" code="2025" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Normal"/>
+				<item code="1" name="Instant Ppersonal Aalert event"/>
+				<item code="2" name="PoC Group Advertisement event"/>
+				<item code="3" name="Early Ssession Setting-up event"/>
+				<item code="4" name="PoC Talk Burst"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-Group-Name" code="859" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="PoC-Information" code="879" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="PoC-Server-Role" required="false" max="1"/>
+				<rule avp="PoC-Session-Type" required="false" max="1"/>
+				<rule avp="PoC-User-Role" required="false" max="1"/>
+				<rule avp="PoC-Session-Initiation-type" required="false" max="1"/>
+				<rule avp="PoC-Event-Type" required="false" max="1"/>
+				<rule avp="Number-Of-Participants" required="false" max="1"/>
+				<rule avp="Participants-Involved" required="false"/>
+				<rule avp="Participant-Group" required="false"/>
+				<rule avp="Talk-Burst-Exchange" required="false"/>
+				<rule avp="PoC-Controlling-Address" required="false" max="1"/>
+				<rule avp="PoC-Group-Name" required="false" max="1"/>
+				<rule avp="PoC-Session-Id" required="false" max="1"/>
+				<rule avp="Charged-Party" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-Server-Role" code="883" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Participating PoC Server"/>
+				<item code="1" name="Controlling PoC Server"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-Session-Id" code="1229" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="PoC-Session-Initiation-type" code="1277" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Pre-established"/>
+				<item code="1" name="On-demand"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-Session-Type" code="884" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="1 to 1 PoC session"/>
+				<item code="1" name="Chat PoC group session"/>
+				<item code="2" name="Pre-arranged PoC group session"/>
+				<item code="3" name="Ad-hoc PoC group session"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-User-Role" code="1252" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="PoC-User-Role-Ids" required="false" max="1"/>
+				<rule avp="PoC-User-Role-info-Units" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="PoC-User-Role-Ids" code="1253" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="PoC-User-Role-info-Units" code="1254" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="1" name="Moderator"/>
+				<item code="2" name="Dispatcher"/>
+				<item code="3" name="Session-Owner"/>
+				<item code="4" name="Session-Participant"/>
+			</data>
+		</avp>
+
+		<avp name="Positioning-Data" code="1245" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Preferred-AoC-Currency" code="2315" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Presence-Reporting-Area-Identifier" code="2821" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Presence-Reporting-Area-Information" code="2822" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Presence-Reporting-Area-Identifier" required="false" max="1"/>
+				<rule avp="Presence-Reporting-Area-Status" required="false" max="1"/>
+				<rule avp="Presence-Reporting-Area-Elements-List" required="false" max="1"/>
+				<rule avp="AVP" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="Presence-Reporting-Area-Status" code="2823" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Priority" code="1209" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Low"/>
+				<item code="1" name="Normal"/>
+				<item code="2" name="High"/>
+			</data>
+		</avp>
+
+		<avp name="Priority-Indication" code="3006" must="V,M"	may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Non-Priority"/>
+				<item code="1" name="Priority"/>
+			</data>
+		</avp>
+
+		<avp name="Priority-Level" code="1046" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="PS-Append-Free-Format-Data" code="867" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Append"/>
+				<item code="1" name="Overwrite"/>
+			</data>
+		</avp>
+
+		<avp name="PS-Free-Format-Data" code="866" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="PS-Furnish-Charging-Information" code="865" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="TGPP-Charging-Id" required="true" max="1"/>
+				<rule avp="PS-Free-Format-Data" required="true" max="1"/>
+				<rule avp="PS-Append-Free-Format-Data" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="PS-Information" code="874" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
+				<rule avp="PDN-Connection-Charging-Id" required="false" max="1"/>
+				<rule avp="Node-Id" required="false" max="1"/>
+				<rule avp="TGPP-PDP-Type" required="false" max="1"/>
+				<rule avp="PDP-Address" required="false"/>
+				<rule avp="PDP-Address-Prefix-Length" required="false" max="1"/>
+				<rule avp="Dynamic-Address-Flag" required="false" max="1"/>
+				<rule avp="Dynamic-Address-Flag-Extension" required="false" max="1"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="SGSN-Address" required="false"/>
+				<rule avp="GGSN-Address" required="false"/>
+				<rule avp="TDF-IP-Address" required="false"/>
+				<rule avp="SGW-Address" required="false"/>
+				<rule avp="ePDG-Address" required="false"/>
+				<rule avp="CG-Address" required="false" max="1"/>
+				<rule avp="Serving-Node-Type" required="false" max="1"/>
+				<rule avp="SGW-Change" required="false" max="1"/>
+				<rule avp="TGPP-IMSI-MCC-MNC" required="false" max="1"/>
+				<rule avp="IMSI-Unauthenticated-Flag" required="false" max="1"/>
+				<rule avp="TGPP-GGSN-MCC-MNC" required="false" max="1"/>
+				<rule avp="TGPP-NSAPI" required="false" max="1"/>
+				<rule avp="Called-Station-Id" required="false" max="1"/>
+				<rule avp="TGPP-Session-Stop-Indicator" required="false" max="1"/>
+				<rule avp="TGPP-Selection-Mode" required="false" max="1"/>
+				<rule avp="TGPP-Charging-Characteristics" required="false" max="1"/>
+				<rule avp="Charging-Characteristics-Selection-Mode" required="false" max="1"/>
+				<rule avp="TGPP-SGSN-MCC-MNC" required="false" max="1"/>
+				<rule avp="TGPP-MS-TimeZone" required="false" max="1"/>
+				<rule avp="Charging-Rule-Base-Name" required="false" max="1"/>
+				<rule avp="ADC-Rule-Base-Name" required="false" max="1"/>
+				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
+				<rule avp="User-Location-Info-Time" required="false" max="1"/>
+				<rule avp="User-CSG-Information" required="false" max="1"/>
+				<rule avp="Presence-Reporting-Area-Information" required="false" max="1"/>
+				<rule avp="TGPP2-BSID" required="false" max="1"/>
+				<rule avp="TWAN-User-Location-Info" required="false" max="1"/>
+				<rule avp="TGPP-RAT-Type" required="false" max="1"/>
+				<rule avp="PS-Furnish-Charging-Information" required="false" max="1"/>
+				<rule avp="PDP-Context-Type" required="false" max="1"/>
+				<rule avp="Offline-Charging" required="false" max="1"/>
+				<rule avp="Traffic-Data-Volumes" required="false"/>
+				<rule avp="Service-Data-Container" required="false"/>
+				<rule avp="User-Equipment-Info" required="false" max="1"/>
+				<rule avp="Terminal-Information" required="false" max="1"/>
+				<rule avp="Start-Time" required="false" max="1"/>
+				<rule avp="Stop-Time" required="false" max="1"/>
+				<rule avp="Change-Condition" required="false" max="1"/>
+				<rule avp="Diagnostics" required="false" max="1"/>
+				<rule avp="Low-Priority-Indicator" required="false" max="1"/>
+				<rule avp="MME-Number-for-MT-SMS" required="false" max="1"/>
+				<rule avp="MME-Name" required="false" max="1"/>
+				<rule avp="MME-Realm" required="false" max="1"/>
+				<rule avp="Logical-Access-Id" required="false" max="1"/>
+				<rule avp="Physical-Access-Id" required="false" max="1"/>
+				<rule avp="Fixed-User-Location-Info" required="false" max="1"/>
+				<rule avp="CN-Operator-Selection-Entity" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="QoS-Information" code="1016" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="QoS-Class-Identifier" required="false" max="1"/>
+				<rule avp="Max-Requested-Bandwidth-UL" required="false" max="1"/>
+				<rule avp="Max-Requested-Bandwidth-DL" required="false" max="1"/>
+				<rule avp="Guaranteed-Bitrate-UL" required="false" max="1"/>
+				<rule avp="Guaranteed-Bitrate-DL" required="false" max="1"/>
+				<rule avp="Bearer-Identifier" required="false" max="1"/>
+				<rule avp="Allocation-Retention-Priority" required="false" max="1"/>
+				<rule avp="APN-Aggregate-Max-Bitrate-UL" required="false" max="1"/>
+				<rule avp="APN-Aggregate-Max-Bitrate-DL" required="false" max="1"/>
+				<rule avp="Conditional-APN-Aggregate-Max-Bitrate" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="QoS-Class-Identifier" code="1028" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="1" name="QCI_1"/>
+				<item code="2" name="QCI_2"/>
+				<item code="3" name="QCI_3"/>
+				<item code="4" name="QCI_4"/>
+				<item code="5" name="QCI_5"/>
+				<item code="6" name="QCI_6"/>
+				<item code="7" name="QCI_7"/>
+				<item code="8" name="QCI_8"/>
+				<item code="9" name="QCI_9"/>
+				<item code="65" name="QCI_65"/>
+				<item code="66" name="QCI_66"/>
+				<item code="69" name="QCI_69"/>
+				<item code="70" name="QCI_70"/>
+			</data>
+		</avp>
+
+		<avp name="Quota-Consumption-Time" code="881" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Quota-Holding-Time" code="871" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="RAI" code="909" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Rate-Element" code="2058" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="CC-Unit-Type" required="true" max="1"/>
+				<rule avp="Charge-Reason-Code" required="false" max="1"/>
+				<rule avp="Unit-Value" required="false" max="1"/>
+				<rule avp="Unit-Cost" required="false" max="1"/>
+				<rule avp="Unit-Quota-Threshold" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="RAT-Type" code="1032" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="WLAN"/>
+				<item code="1" name="VIRTUAL"/>
+				<item code="1000" name="UTRAN"/>
+				<item code="1001" name="GERAN"/>
+				<item code="1002" name="GAN"/>
+				<item code="1003" name="HSPA_EVOLUTION"/>
+				<item code="1004" name="EUTRAN"/>
+				<item code="2000" name="CDMA2000_1X"/>
+				<item code="2001" name="HRPD"/>
+				<item code="2002" name="UMB"/>
+				<item code="2003" name="EHRPD"/>
+			</data>
+		</avp>
+
+		<avp name="Read-Reply-Report-Requested" code="1222" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="No"/>
+				<item code="1" name="Yes"/>
+			</data>
+		</avp>
+
+		<avp name="Real-Time-Tariff-Information" code="2305" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Tariff-Information" required="false" max="1"/>
+				<rule avp="Tariff-XML" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Reason-Header" code="3401" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Received-Talk-Burst-Time" code="1284" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Received-Talk-Burst-Volume" code="1285" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Recipient-Address" code="1201" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Address-Type" required="false" max="1"/>
+				<rule avp="Address-Data" required="false" max="1"/>
+				<rule avp="Address-Domain" required="false" max="1"/>
+				<rule avp="Addressee-Type" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Recipient-Info" code="2026" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Destination-Interface" required="false" max="1"/>
+				<rule avp="Recipient-Address" required="false"/>
+				<rule avp="Recipient-Received-Address" required="false"/>
+				<rule avp="Recipient-SCCP-Address" required="false" max="1"/>
+				<rule avp="SM-Protocol-Id" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Recipient-Received-Address" code="2028" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Address-Type" required="false" max="1"/>
+				<rule avp="Address-Data" required="false" max="1"/>
+				<rule avp="Address-Domain" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Recipient-SCCP-Address" code="2010" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="Reference-Number" code="3007" must="V,M"	may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="Refund-Information" code="2022" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Relationship-Mode" code="2706" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="trusted"/>
+				<item code="1" name="non-trusted"/>
+			</data>
+		</avp>
+
+		<avp name="Related-IMS-Charging-Identifier" code="2711" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Related-IMS-Charging-Identifier-Node" code="2712" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="Remaining-Balance" code="2021" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Unit-Value" required="true" max="1"/>
+				<rule avp="Currency-Code" required="true" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Reply-Applic-Id" code="1223" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Reply-Path-Requested" code="2011" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="No Reply Path Set"/>
+				<item code="1" name="Reply path Set"/>
+			</data>
+		</avp>
+
+		<avp name="Reporting-Reason" code="872" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="THRESHOLD"/>
+				<item code="1" name="QHT"/>
+				<item code="2" name="FINAL"/>
+				<item code="3" name="QUOTA_EXHAUSTED"/>
+				<item code="4" name="VALIDITY_TIME"/>
+				<item code="5" name="OTHER_QUOTA_TYPE"/>
+				<item code="6" name="RATING_CONDITION_CHANGE"/>
+				<item code="7" name="FORCED_REAUTHORISATION"/>
+				<item code="8" name="POOL_EXHAUSTED"/>
+			</data>
+		</avp>
+
+		<avp name="Requested-Party-Address" code="1251" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Required-MBMS-Bearer-Capabilities" code="901" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Role-Of-Node" code="829" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="ORIGINATING_ROLE"/>
+				<item code="1" name="TERMINATING_ROLE"/>
+				<item code="2" name="FORWARDING_ROLE"/>
+			</data>
+		</avp>
+
+		<avp name="Route-Header-Received" code="3403" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Route-Header-Transmitted" code="3404" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Scale-Factor" code="2059" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Value-Digits" required="true" max="1"/>
+				<rule avp="Exponent" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="SDP-Answer-Timestamp" code="1275" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="SDP-Media-Component" code="843" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SDP-Media-Name" required="false" max="1"/>
+				<rule avp="SDP-Media-Description" required="false"/>
+				<rule avp="Local-GW-Inserted-Indication" required="false" max="1"/>
+				<rule avp="IP-Realm-Default-Indication" required="false" max="1"/>
+				<rule avp="Transcoder-Inserted-Indication" required="false" max="1"/>
+				<rule avp="Media-Initiator-Flag" required="false" max="1"/>
+				<rule avp="Media-Initiator-Party" required="false" max="1"/>
+				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
+				<rule avp="Access-Network-Charging-Identifier-Value" required="false" max="1"/>
+				<rule avp="SDP-Type" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="SDP-Media-Description" code="845" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="SDP-Media-Name" code="844" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="SDP-Offer-Timestamp" code="1274" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="SDP-Session-Description" code="842" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="SDP-TimeStamps" code="1273" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SDP-Offer-Timestamp" required="false" max="1"/>
+				<rule avp="SDP-Answer-Timestamp" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="SDP-Type" code="2036" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="SDP Offer"/>
+				<item code="1" name="SDP Answer"/>
+			</data>
+		</avp>
+
+		<avp name="Serving-Node" code="2401" must="V,M" may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SGSN-Number" required="false" max="1"/>
+				<rule avp="SGSN-Name" required="false" max="1"/>
+				<rule avp="SGSN-Realm" required="false" max="1"/>
+				<rule avp="MME-Name" required="false" max="1"/>
+				<rule avp="MME-Realm" required="false" max="1"/>
+				<rule avp="MSC-Number" required="false" max="1"/>
+				<rule avp="TGPP-AAA-Server-Name" required="false" max="1"/>
+				<rule avp="LCS-Capabilities-Sets" required="false" max="1"/>
+				<rule avp="GMLC-Address" required="false" max="1"/>
+				<rule avp="AVP" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="Session-Direction" code="2707" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="inbound"/>
+				<item code="1" name="outbound"/>
+			</data>
+		</avp>
+
+		<avp name="Served-Party-IP-Address" code="848" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="Server-Capabilities" code="603" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Mandatory-Capability" required="false"/>
+				<rule avp="Optional-Capability" required="false"/>
+				<rule avp="Server-Name" required="false"/>
+				<rule avp="AVP" required="false"/>
+			</data>
+		</avp>
+
+		<avp name="Server-Name" code="602" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Service-Data-Container" code="2040" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="AF-Correlation-Information" required="false" max="1"/>
+				<rule avp="Charging-Rule-Base-Name" required="false" max="1"/>
+				<rule avp="Accounting-Input-Octets" required="false" max="1"/>
+				<rule avp="Accounting-Output-Octets" required="false" max="1"/>
+				<rule avp="Local-Sequence-Number" required="false" max="1"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="Rating-Group" required="false" max="1"/>
+				<rule avp="Change-Time" required="false" max="1"/>
+				<rule avp="Service-Identifier" required="false" max="1"/>
+				<rule avp="Service-Specific-Info" required="false" max="1"/>
+				<rule avp="ADC-Rule-Base-Name" required="false" max="1"/>
+				<rule avp="SGSN-Address" required="false" max="1"/>
+				<rule avp="Time-First-Usage" required="false"/>
+				<rule avp="Time-Last-Usage" required="false" max="1"/>
+				<rule avp="Time-Usage" required="false" max="1"/>
+				<rule avp="Change-Condition" required="false"/>
+				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
+				<rule avp="TGPP2-BSID" required="false" max="1"/>
+				<rule avp="Sponsor-Identity" required="false" max="1"/>
+				<rule avp="Application-Service-Provider-Identity" required="false" max="1"/>
+				<rule avp="Presence-Reporting-Area-Status" required="false" max="1"/>
+				<rule avp="User-CSG-Information" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Service-Id" code="855" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Service-Information" code="873" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Subscription-Id" required="false"/>
+				<rule avp="AoC-Information" required="false" max="1"/>
+				<rule avp="PS-Information" required="false" max="1"/>
+				<rule avp="IMS-Information" required="false" max="1"/>
+				<rule avp="MMS-Information" required="false" max="1"/>
+				<rule avp="LCS-Information" required="false" max="1"/>
+				<rule avp="PoC-Information" required="false" max="1"/>
+				<rule avp="MBMS-Information" required="false" max="1"/>
+				<rule avp="SMS-Information" required="false" max="1"/>
+				<rule avp="VCS-Information" required="false" max="1"/>
+				<rule avp="MMTel-Information" required="false" max="1"/>
+				<rule avp="Service-Generic-Information" required="false" max="1"/>
+				<rule avp="IM-Information" required="false" max="1"/>
+				<rule avp="DCD-Information" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="Service-Mode" code="2032" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Presence-Reporting-Area-Identifier" code="2821" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="Service-Specific-Data" code="863" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Presence-Reporting-Area-Information" code="2822" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+		<avp name="Service-Specific-Info" code="1249" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Presence-Reporting-Area-Identifier" required="false" max="1"/>
-				<rule avp="Presence-Reporting-Area-Status" required="false" max="1"/>
-				<rule avp="Presence-Reporting-Area-Elements-List" required="false" max="1"/>
-				<rule avp="AVP" required="false"/>
+				<rule avp="Service-Specific-Data" required="false" max="1"/>
+				<rule avp="Service-Specific-Type" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Presence-Reporting-Area-Status" code="2823" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+		<avp name="Service-Specific-Type" code="1257" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Priority" code="1209" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Serving-Node-Type" code="2047" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Low"/>
-				<item code="1" name="Normal"/>
-				<item code="2" name="High"/>
+				<item code="0" name="SGSN"/>
+				<item code="1" name="PMIPSGW"/>
+				<item code="2" name="GTPSGW"/>
+				<item code="3" name="ePDG"/>
+				<item code="4" name="hSGW"/>
+				<item code="5" name="MME"/>
+				<item code="6" name="TWAN"/>
 			</data>
 		</avp>
 
-		<avp name="Priority-Indication" code="3006" must="V,M"	may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Session-Priority" code="650" must="V" may="-" must-not="M" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Non-Priority"/>
-				<item code="1" name="Priority"/>
+				<item code="0" name="PRIORITY-0"/>
+				<item code="1" name="PRIORITY-1"/>
+				<item code="2" name="PRIORITY-2"/>
+				<item code="3" name="PRIORITY-3"/>
+				<item code="4" name="PRIORITY-4"/>
 			</data>
 		</avp>
 
-		<avp name="Priority-Level" code="1046" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+		<avp name="SGSN-Address" code="1228" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="SGW-Address" code="2067" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="SGW-Change" code="2065" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="ACR_Start_NOT_due_to_SGW_Change"/>
+				<item code="1" name="ACR_Start_due_to_SGW_Change"/>
+			</data>
+		</avp>
+
+		<avp name="SIP-Method" code="824" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="SIP-Request-Timestamp-Fraction" code="2301" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="PS-Append-Free-Format-Data" code="867" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Request-Timestamp" code="834" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="SIP-Response-Timestamp-Fraction" code="2302" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="SIP-Response-Timestamp" code="835" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="SM-Device-Trigger-Indicator" code="3407" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Append"/>
-				<item code="1" name="Overwrite"/>
+				<item code="0" name="Not DeviceTrigger"/>
+				<item code="1" name="Device Trigger"/>
 			</data>
 		</avp>
 
-		<avp name="PS-Free-Format-Data" code="866" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SM-Device-Trigger-Information" code="3405" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="MTC-IWF-Address" required="false" max="1"/>
+				<rule avp="Reference-Number" required="false" max="1"/>
+				<rule avp="Serving-Node" required="false" max="1"/>
+				<rule avp="Validity-Time" required="false" max="1"/>
+				<rule avp="Priority-Indication" required="false" max="1"/>
+				<rule avp="Application-Port-Identifier" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="SM-Discharge-Time" code="2012" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="SM-Message-Type" code="2007" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="SUBMISSION"/>
+				<item code="1" name="DELIVERY_REPORT"/>
+				<item code="2" name="SM Service Request"/>
+			</data>
+		</avp>
+
+		<avp name="SM-Protocol-Id" code="2013" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="PS-Furnish-Charging-Information" code="865" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SM-Sequence-Number" code="3408" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="SMSC-Address" code="2017" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
+		</avp>
+
+		<avp name="SMS-Information" code="2000" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="TGPP-Charging-Id" required="true" max="1"/>
-				<rule avp="PS-Free-Format-Data" required="true" max="1"/>
-				<rule avp="PS-Append-Free-Format-Data" required="false" max="1"/>
+				<rule avp="SMS-Node" required="false" max="1"/>
+				<rule avp="Client-Address" required="false" max="1"/>
+				<rule avp="Originator-SCCP-Address" required="false" max="1"/>
+				<rule avp="SMSC-Address" required="false" max="1"/>
+				<rule avp="Data-Coding-Scheme" required="false" max="1"/>
+				<rule avp="SM-Discharge-Time" required="false" max="1"/>
+				<rule avp="SM-Message-Type" required="false" max="1"/>
+				<rule avp="Originator-Interface" required="false" max="1"/>
+				<rule avp="SM-Protocol-Id" required="false" max="1"/>
+				<rule avp="Reply-Path-Requested" required="false" max="1"/>
+				<rule avp="SM-Status" required="false" max="1"/>
+				<rule avp="SM-User-Data-Header" required="false" max="1"/>
+				<rule avp="Number-Of-Messages-Sent" required="false"/>
+				<rule avp="SM-Sequence-Number" required="false"/>
+				<rule avp="Recipient-Info" required="false"/>
+				<rule avp="Originator-Received-Address" required="false" max="1"/>
+				<rule avp="SM-Service-Type" required="false" max="1"/>
+				<rule avp="SMS-Result" required="false" max="1"/>
+				<rule avp="SM-Device-Trigger-Indicator" required="false" max="1"/>
+				<rule avp="SM-Device-Trigger-Information" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="PS-Information" code="874" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
-				<rule avp="PDN-Connection-Charging-Id" required="false" max="1"/>
-				<rule avp="Node-Id" required="false" max="1"/>
-				<rule avp="TGPP-PDP-Type" required="false" max="1"/>
-				<rule avp="PDP-Address" required="false"/>
-				<rule avp="PDP-Address-Prefix-Length" required="false" max="1"/>
-				<rule avp="Dynamic-Address-Flag" required="false" max="1"/>
-				<rule avp="Dynamic-Address-Flag-Extension" required="false" max="1"/>
-				<rule avp="QoS-Information" required="false" max="1"/>
-				<rule avp="SGSN-Address" required="false"/>
-				<rule avp="GGSN-Address" required="false"/>
-				<rule avp="TDF-IP-Address" required="false"/>
-				<rule avp="SGW-Address" required="false"/>
-				<rule avp="ePDG-Address" required="false"/>
-				<rule avp="CG-Address" required="false" max="1"/>
-				<rule avp="Serving-Node-Type" required="false" max="1"/>
-				<rule avp="SGW-Change" required="false" max="1"/>
-				<rule avp="TGPP-IMSI-MCC-MNC" required="false" max="1"/>
-				<rule avp="IMSI-Unauthenticated-Flag" required="false" max="1"/>
-				<rule avp="TGPP-GGSN-MCC-MNC" required="false" max="1"/>
-				<rule avp="TGPP-NSAPI" required="false" max="1"/>
-				<rule avp="Called-Station-Id" required="false" max="1"/>
-				<rule avp="TGPP-Session-Stop-Indicator" required="false" max="1"/>
-				<rule avp="TGPP-Selection-Mode" required="false" max="1"/>
-				<rule avp="TGPP-Charging-Characteristics" required="false" max="1"/>
-				<rule avp="Charging-Characteristics-Selection-Mode" required="false" max="1"/>
-				<rule avp="TGPP-SGSN-MCC-MNC" required="false" max="1"/>
-				<rule avp="TGPP-MS-TimeZone" required="false" max="1"/>
-				<rule avp="Charging-Rule-Base-Name" required="false" max="1"/>
-				<rule avp="ADC-Rule-Base-Name" required="false" max="1"/>
-				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
-				<rule avp="User-Location-Info-Time" required="false" max="1"/>
-				<rule avp="User-CSG-Information" required="false" max="1"/>
-				<rule avp="Presence-Reporting-Area-Information" required="false" max="1"/>
-				<rule avp="TGPP2-BSID" required="false" max="1"/>
-				<rule avp="TWAN-User-Location-Info" required="false" max="1"/>
-				<rule avp="TGPP-RAT-Type" required="false" max="1"/>
-				<rule avp="PS-Furnish-Charging-Information" required="false" max="1"/>
-				<rule avp="PDP-Context-Type" required="false" max="1"/>
-				<rule avp="Offline-Charging" required="false" max="1"/>
-				<rule avp="Traffic-Data-Volumes" required="false"/>
-				<rule avp="Service-Data-Container" required="false"/>
-				<rule avp="User-Equipment-Info" required="false" max="1"/>
-				<rule avp="Terminal-Information" required="false" max="1"/>
-				<rule avp="Start-Time" required="false" max="1"/>
-				<rule avp="Stop-Time" required="false" max="1"/>
-				<rule avp="Change-Condition" required="false" max="1"/>
-				<rule avp="Diagnostics" required="false" max="1"/>
-				<rule avp="Low-Priority-Indicator" required="false" max="1"/>
-				<rule avp="MME-Number-for-MT-SMS" required="false" max="1"/>
-				<rule avp="MME-Name" required="false" max="1"/>
-				<rule avp="MME-Realm" required="false" max="1"/>
-				<rule avp="Logical-Access-Id" required="false" max="1"/>
-				<rule avp="Physical-Access-Id" required="false" max="1"/>
-				<rule avp="Fixed-User-Location-Info" required="false" max="1"/>
-				<rule avp="CN-Operator-Selection-Entity" required="false" max="1"/>
+		<avp name="SMS-Node" code="2016" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="SMS Router"/>
+				<item code="1" name="IP-SM-GW"/>
+				<item code="2" name="SMS Router and IP-SM-GW"/>
+				<item code="3" name="SMS-SC"/>
+			</data>
+		</avp>
+
+		<avp name="SMS-Result" code="3409" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+		<avp name="SM-Service-Type" code="2029" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="VAS4SMS Short Message content processing"/>
+				<item code="1" name="VAS4SMS Short Message forwarding"/>
+				<item code="2" name="VAS4SMS Short Message Forwarding multiple subscriptions"/>
+				<item code="3" name="VAS4SMS Short Message filtering"/>
+				<item code="4" name="VAS4SMS Short Message receipt"/>
+				<item code="5" name="VAS4SMS Short Message Network Storage"/>
+				<item code="6" name="VAS4SMS Short Message to multiple destinations"/>
+				<item code="7" name="VAS4SMS Short Message Virtual Private Network (VPN)"/>
+				<item code="8" name="VAS4SMS Short Message Auto Reply"/>
+				<item code="9" name="VAS4SMS Short Message Personal Signature"/>
+				<item code="10" name="VAS4SMS Short Message Deferred Delivery"/>
+			</data>
+		</avp>
+
+		<avp name="SM-Status" code="2014" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="SM-User-Data-Header" code="2015" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Sponsor-Identity" code="531" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="SSID" code="1524" must="V"	may="-" must-not="M,P" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="Start-of-Charging" code="3419" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="Start-Time" code="2041" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="Status-AS-Code" code="2702" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="4xx"/>
+				<item code="1" name="5xx"/>
+				<item code="2" name="Timeout"/>
+			</data>
+		</avp>
+
+		<avp name="Stop-Time" code="2042" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="Submission-Time" code="1202" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="Subscriber-Role" code="2033" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Originating"/>
+				<item code="1" name="Terminating"/>
 			</data>
 		</avp>
 
-		<avp name="QoS-Information" code="1016" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="Supplementary-Service" code="2048" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="QoS-Class-Identifier" required="false" max="1"/>
-				<rule avp="Max-Requested-Bandwidth-UL" required="false" max="1"/>
-				<rule avp="Max-Requested-Bandwidth-DL" required="false" max="1"/>
-				<rule avp="Guaranteed-Bitrate-UL" required="false" max="1"/>
-				<rule avp="Guaranteed-Bitrate-DL" required="false" max="1"/>
-				<rule avp="Bearer-Identifier" required="false" max="1"/>
-				<rule avp="Allocation-Retention-Priority" required="false" max="1"/>
-				<rule avp="APN-Aggregate-Max-Bitrate-UL" required="false" max="1"/>
-				<rule avp="APN-Aggregate-Max-Bitrate-DL" required="false" max="1"/>
-				<rule avp="Conditional-APN-Aggregate-Max-Bitrate" required="false"/>
-				<rule avp="AVP" required="false"/>
+				<rule avp="MMTel-SService-Type" required="false" max="1"/>
+				<rule avp="Service-Mode" required="false" max="1"/>
+				<rule avp="Number-Of-Diversions" required="false" max="1"/>
+				<rule avp="Associated-Party-Address" required="false" max="1"/>
+				<rule avp="Service-Id" required="false" max="1"/>
+				<rule avp="Change-Time" required="false" max="1"/>
+				<rule avp="Number-Of-Participants" required="false" max="1"/>
+				<rule avp="Participant-Action-Type" required="false" max="1"/>
+				<rule avp="CUG-Information" required="false" max="1"/>
+				<rule avp="AoC-Information" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="QoS-Class-Identifier" code="1028" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="TAD-Identifier" code="2717" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="1" name="QCI_1"/>
-				<item code="2" name="QCI_2"/>
-				<item code="3" name="QCI_3"/>
-				<item code="4" name="QCI_4"/>
-				<item code="5" name="QCI_5"/>
-				<item code="6" name="QCI_6"/>
-				<item code="7" name="QCI_7"/>
-				<item code="8" name="QCI_8"/>
-				<item code="9" name="QCI_9"/>
-				<item code="65" name="QCI_65"/>
-				<item code="66" name="QCI_66"/>
-				<item code="69" name="QCI_69"/>
-				<item code="70" name="QCI_70"/>
+				<item code="0" name="CS"/>
+				<item code="1" name="PS"/>
 			</data>
 		</avp>
 
-		<avp name="Quota-Consumption-Time" code="881" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Talk-Burst-Exchange" code="1255" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="PoC-Change-Time" required="true" max="1"/>
+				<rule avp="Number-Of-Talk-Bursts" required="false" max="1"/>
+				<rule avp="Talk-Burst-Volume" required="false" max="1"/>
+				<rule avp="Talk-Burst-Time" required="false" max="1"/>
+				<rule avp="Number-Of-Received-Talk-Bursts" required="false" max="1"/>
+				<rule avp="Received-Talk-Burst-Volume" required="false" max="1"/>
+				<rule avp="Received-Talk-Burst-Time" required="false" max="1"/>
+				<rule avp="Number-Of-Participants" required="false" max="1"/>
+				<rule avp="PoC-Change-Condition" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Quota-Holding-Time" code="871" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Talk-Burst-Time" code="1286" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="RAI" code="909" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Talk-Burst-Volume" code="1287" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Rate-Element" code="2058" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Tariff-Information" code="2060" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="CC-Unit-Type" required="true" max="1"/>
-				<rule avp="Charge-Reason-Code" required="false" max="1"/>
-				<rule avp="Unit-Value" required="false" max="1"/>
-				<rule avp="Unit-Cost" required="false" max="1"/>
-				<rule avp="Unit-Quota-Threshold" required="false" max="1"/>
+				<rule avp="Current-Tariff" required="true" max="1"/>
+				<rule avp="Tariff-Time-Change" required="false" max="1"/>
+				<rule avp="Next-Tariff" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="RAT-Type" code="1032" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="WLAN"/>
-				<item code="1" name="VIRTUAL"/>
-				<item code="1000" name="UTRAN"/>
-				<item code="1001" name="GERAN"/>
-				<item code="1002" name="GAN"/>
-				<item code="1003" name="HSPA_EVOLUTION"/>
-				<item code="1004" name="EUTRAN"/>
-				<item code="2000" name="CDMA2000_1X"/>
-				<item code="2001" name="HRPD"/>
-				<item code="2002" name="UMB"/>
-				<item code="2003" name="EHRPD"/>
-			</data>
+		<avp name="Tariff-XML" code="2306" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Read-Reply-Report-Requested" code="1222" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="No"/>
-				<item code="1" name="Yes"/>
-			</data>
+		<avp name="TDF-IP-Address" code="1091" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Address"/>
 		</avp>
 
-		<avp name="Real-Time-Tariff-Information" code="2305" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Teleservice" code="3413" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="Terminal-Information" code="1401" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Tariff-Information" required="false" max="1"/>
-				<rule avp="Tariff-XML" required="false" max="1"/>
+				<rule avp="IMEI" required="false" max="1"/>
+				<rule avp="TGPP2-MEID" required="false" max="1"/>
+				<rule avp="Software-Version" required="false" max="1"/>
+				<rule avp="AVP" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="Reason-Header" code="3401" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Terminating-IOI" code="840" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Received-Talk-Burst-Time" code="1284" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Time-First-Usage" code="2043" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="Received-Talk-Burst-Volume" code="1285" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Time-Last-Usage" code="2044" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="Recipient-Address" code="1201" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Time-Quota-Mechanism" code="1270" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Address-Type" required="false" max="1"/>
-				<rule avp="Address-Data" required="false" max="1"/>
-				<rule avp="Address-Domain" required="false" max="1"/>
-				<rule avp="Addressee-Type" required="false" max="1"/>
+				<rule avp="Time-Quota-Type" required="true" max="1"/>
+				<rule avp="Base-Time-Interval" required="true" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Recipient-Info" code="2026" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Destination-Interface" required="false" max="1"/>
-				<rule avp="Recipient-Address" required="false"/>
-				<rule avp="Recipient-Received-Address" required="false"/>
-				<rule avp="Recipient-SCCP-Address" required="false" max="1"/>
-				<rule avp="SM-Protocol-Id" required="false" max="1"/>
-			</data>
+		<avp name="Time-Quota-Threshold" code="868" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Recipient-Received-Address" code="2028" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Address-Type" required="false" max="1"/>
-				<rule avp="Address-Data" required="false" max="1"/>
-				<rule avp="Address-Domain" required="false" max="1"/>
+		<avp name="Time-Quota-Type" code="1271" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="DISCRETE_TIME_PERIOD"/>
+				<item code="1" name="CONTINUOUS_TIME_PERIOD"/>
 			</data>
 		</avp>
 
-		<avp name="Recipient-SCCP-Address" code="2010" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Time-Stamps" code="833" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SIP-Request-Timestamp" required="false" max="1"/>
+				<rule avp="SIP-Response-Timestamp" required="false" max="1"/>
+				<rule avp="SIP-Request-Timestamp-Fraction" required="false" max="1"/>
+				<rule avp="SIP-Response-Timestamp-Fraction" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Reference-Number" code="3007" must="V,M"	may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Time-Usage" code="2045" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Refund-Information" code="2022" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="TMGI" code="900" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Relationship-Mode" code="2706" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="trusted"/>
-				<item code="1" name="non-trusted"/>
-			</data>
+		<avp name="Token-Text" code="1215" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Related-IMS-Charging-Identifier" code="2711" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Total-Number-Of-Messages-Exploded" code="2113" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Related-IMS-Charging-Identifier-Node" code="2712" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Total-Number-Of-Messages-Sent" code="2114" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Remaining-Balance" code="2021" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Traffic-Data-Volumes" code="2046" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Unit-Value" required="true" max="1"/>
-				<rule avp="Currency-Code" required="true" max="1"/>
+				<rule avp="QoS-Information" required="false" max="1"/>
+				<rule avp="Accounting-Input-Octets" required="false" max="1"/>
+				<rule avp="Accounting-Output-Octets" required="false" max="1"/>
+				<rule avp="Change-condition" required="false" max="1"/>
+				<rule avp="Change-Time" required="false" max="1"/>
+				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
+				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
+				<rule avp="Presence-Reporting-Area-Status" required="false" max="1"/>
+				<rule avp="User-CSG-Information" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Reply-Applic-Id" code="1223" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Transcoder-Inserted-Indication" code="2605" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Transcoder Not Inserted"/>
+				<item code="1" name="Transcoder Inserted"/>
+			</data>
+		</avp>
+
+		<avp name="Transit-IOI-List" code="2701" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Reply-Path-Requested" code="2011" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="No Reply Path Set"/>
-				<item code="1" name="Reply path Set"/>
+		<avp name="Trigger" code="1264" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Trigger-Type" required="false"/>
 			</data>
 		</avp>
 
-		<avp name="Reporting-Reason" code="872" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Trigger-Type" code="870" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="THRESHOLD"/>
-				<item code="1" name="QHT"/>
-				<item code="2" name="FINAL"/>
-				<item code="3" name="QUOTA_EXHAUSTED"/>
-				<item code="4" name="VALIDITY_TIME"/>
-				<item code="5" name="OTHER_QUOTA_TYPE"/>
-				<item code="6" name="RATING_CONDITION_CHANGE"/>
-				<item code="7" name="FORCED_REAUTHORISATION"/>
-				<item code="8" name="POOL_EXHAUSTED"/>
+				<item code="1" name="CHANGE_IN_SGSN_IP_ADDRESS"/>
+				<item code="2" name="CHANGE_IN_QOS"/>
+				<item code="3" name="CHANGE_IN_LOCATION"/>
+				<item code="4" name="CHANGE_IN_RAT"/>
+				<item code="5" name="CHANGE_IN_UE_TIMEZONE"/>
+				<item code="10" name="CHANGEINQOS_TRAFFIC_CLASS"/>
+				<item code="11" name="CHANGEINQOS_RELIABILITY_CLASS"/>
+				<item code="12" name="CHANGEINQOS_RELIABILITY_CLASS"/>
+				<item code="13" name="CHANGEINQOS_PEAK_THROUGHPUT"/>
+				<item code="14" name="CHANGEINQOS_PRECEDENCE_CLASS"/>
+				<item code="15" name="CHANGEINQOS_MEAN_THROUGHPUT"/>
+				<item code="16" name="CHANGEINQOS_MAXIMUM_BIT_RATE_FOR_UPLINK"/>
+				<item code="17" name="CHANGEINQOS_MAXIMUM_BIT_RATE_FOR_DOWNLINK"/>
+				<item code="18" name="CHANGEINQOS_RESIDUAL_BER"/>
+				<item code="19" name="CHANGEINQOS_SDU_ERROR_RATIO"/>
+				<item code="20" name="CHANGEINQOS_TRANSFER_DELAY"/>
+				<item code="21" name="CHANGEINQOS_TRAFFIC_HANDLING_PRIORITY"/>
+				<item code="22" name="CHANGEINQOS_GUARANTEED_BIT_RATE_FOR_UPLINK"/>
+				<item code="23" name="CHANGEINQOS_GUARANTEED_BIT_RATE_FOR_DOWNLINK"/>
+				<item code="24" name="CHANGEINQOS_APN_AGGREGATE_MAXIMUM_BIT_RATE"/>
+				<item code="30" name="CHANGEINLOCATION_MCC"/>
+				<item code="31" name="CHANGEINLOCATION_MNC"/>
+				<item code="32" name="CHANGEINLOCATION_RAC"/>
+				<item code="33" name="CHANGEINLOCATION_LAC"/>
+				<item code="34" name="CHANGEINLOCATION_CellId"/>
+				<item code="35" name="CHANGEINLOCATION_TAC"/>
+				<item code="36" name="CHANGEINLOCATION_ECGI"/>
+				<item code="40" name="CHANGE_IN_MEDIA_COMPOSITION"/>
+				<item code="50" name="CHANGE_IN_PARTICIPANTS_NMB"/>
+				<item code="51" name="CHANGE_IN_ THRSHLD_OF_PARTICIPANTS_NMB"/>
+				<item code="52" name="CHANGE_IN_USER_PARTICIPATING_TYPE"/>
+				<item code="60" name="CHANGE_IN_SERVICE_CONDITION"/>
+				<item code="61" name="CHANGE_IN_SERVING_NODE"/>
+				<item code="70" name="CHANGE_IN_USER_CSG_INFORMATION"/>
+				<item code="71" name="CHANGE_IN_HYBRID_SUBSCRIBED_USER_CSG_INFORMATION"/>
+				<item code="72" name="CHANGE_IN_HYBRID_UNSUBSCRIBED_USER_CSG_INFORMATION"/>
+				<item code="73" name="CHANGE_OF_UE_PRESENCE_IN_PRESENCE_REPORTING_AREA"/>
 			</data>
 		</avp>
 
-		<avp name="Requested-Party-Address" code="1251" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="Required-MBMS-Bearer-Capabilities" code="901" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
-
-		<avp name="Role-Of-Node" code="829" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="ORIGINATING_ROLE"/>
-				<item code="1" name="TERMINATING_ROLE"/>
-				<item code="2" name="FORWARDING_ROLE"/>
+		<avp name="Trunk-Group-Id" code="851" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="Incoming-Trunk-Group-Id" required="false" max="1"/>
+				<rule avp="Outgoing-Trunk-Group-Id" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Route-Header-Received" code="3403" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="TWAN-User-Location-Info" code="2714" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SSID" required="true" max="1"/>
+				<rule avp="BSSID" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Route-Header-Transmitted" code="3404" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Type-Number" code="1204" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Scale-Factor" code="2059" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Unit-Cost" code="2061" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
 				<rule avp="Value-Digits" required="true" max="1"/>
 				<rule avp="Exponent" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="SDP-Answer-Timestamp" code="1275" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="Unit-Quota-Threshold" code="1226" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="SDP-Media-Component" code="843" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="User-CSG-Information" code="2319" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="SDP-Media-Name" required="false" max="1"/>
-				<rule avp="SDP-Media-Description" required="false"/>
-				<rule avp="Local-GW-Inserted-Indication" required="false" max="1"/>
-				<rule avp="IP-Realm-Default-Indication" required="false" max="1"/>
-				<rule avp="Transcoder-Inserted-Indication" required="false" max="1"/>
-				<rule avp="Media-Initiator-Flag" required="false" max="1"/>
-				<rule avp="Media-Initiator-Party" required="false" max="1"/>
-				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
-				<rule avp="Access-Network-Charging-Identifier-Value" required="false" max="1"/>
-				<rule avp="SDP-Type" required="false" max="1"/>
+				<rule avp="CSG-Id" required="true" max="1"/>
+				<rule avp="CSG-Access-Mode" required="true" max="1"/>
+				<rule avp="CSG-Membership-Indication" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="SDP-Media-Description" code="845" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="User-Data" code="606" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="SDP-Media-Name" code="844" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="User-Location-Info-Time" code="2812" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="User-Participating-Type" code="1279" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="Normal"/>
+				<item code="1" name="NW PoC Box"/>
+				<item code="2" name="UE PoC Box"/>
+			</data>
+		</avp>
+
+		<avp name="User-Session-Id" code="830" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="SDP-Offer-Timestamp" code="1274" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="VAS-Id" code="1102" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="SDP-Session-Description" code="842" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="VASP-Id" code="1101" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="SDP-TimeStamps" code="1273" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="VCS-Information" code="3410" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="SDP-Offer-Timestamp" required="false" max="1"/>
-				<rule avp="SDP-Answer-Timestamp" required="false" max="1"/>
+				<rule avp="Bearer-Capability" required="false" max="1"/>
+				<rule avp="Network-Call-Reference-Number" required="false" max="1"/>
+				<rule avp="MSC-Address" required="false" max="1"/>
+				<rule avp="Basic-Service-Code" required="false" max="1"/>
+				<rule avp="ISUP-Location-Number" required="false" max="1"/>
+				<rule avp="VLR-Number" required="false" max="1"/>
+				<rule avp="Forwarding-Pending" required="false" max="1"/>
+				<rule avp="ISUP-Release-Cause" required="false" max="1"/>
+				<rule avp="Start-Time" required="false" max="1"/>
+				<rule avp="Start-of-Charging" required="false" max="1"/>
+				<rule avp="Stop-Time" required="false" max="1"/>
+				<rule avp="PS-Free-Format-Data" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="SDP-Type" code="2036" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="SDP Offer"/>
-				<item code="1" name="SDP Answer"/>
-			</data>
+		<avp name="VLR-Number" code="3420" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Serving-Node" code="2401" must="V,M" may="-" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="SGSN-Number" required="false" max="1"/>
-				<rule avp="SGSN-Name" required="false" max="1"/>
-				<rule avp="SGSN-Realm" required="false" max="1"/>
-				<rule avp="MME-Name" required="false" max="1"/>
-				<rule avp="MME-Realm" required="false" max="1"/>
-				<rule avp="MSC-Number" required="false" max="1"/>
-				<rule avp="TGPP-AAA-Server-Name" required="false" max="1"/>
-				<rule avp="LCS-Capabilities-Sets" required="false" max="1"/>
+		<avp name="Volume-Quota-Threshold" code="869" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+
+	</application>
+</diameter>`
+
+var tgppslgslhXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+
+	<application id="16777255" type="auth" name="TGPP SLg">
+		<!-- 3GPP TS 29.172 SLg interface between the GMLC and the MME -->
+		<vendor id="10415" name="TGPP"/>
+
+		<command code="8388620" short="PL" name="Provide-Location">
+			<request>
+				<!-- 3GPP TS 29.172 section 7.3.1 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="true" max="1"/>
+				<rule avp="SLg-Location-Type" required="true" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="MSISDN" required="false" max="1"/>
+				<rule avp="IMEI" required="false" max="1"/>
+				<rule avp="LCS-EPS-Client-Name" required="true" max="1"/>
+				<rule avp="LCS-Client-Type" required="true" max="1"/>
+				<rule avp="LCS-Requestor-Name" required="false" max="1"/>
+				<rule avp="LCS-Priority" required="false" max="1"/>
+				<rule avp="LCS-QoS" required="false" max="1"/>
+				<rule avp="Velocity-Requested" required="false" max="1"/>
+				<rule avp="LCS-Supported-GAD-Shapes" required="false" max="1"/>
+				<rule avp="LCS-Service-Type-ID" required="false" max="1"/>
+				<rule avp="LCS-Codeword" required="false" max="1"/>
+				<rule avp="LCS-Privacy-Check-Non-Session" required="false" max="1"/>
+				<rule avp="LCS-Privacy-Check-Session" required="false" max="1"/>
+				<rule avp="PLR-Flags" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.172 section 7.3.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Location-Estimate" required="false" max="1"/>
+				<rule avp="Accuracy-Fulfilment-Indicator" required="false" max="1"/>
+				<rule avp="Age-Of-Location-Estimate" required="false" max="1"/>
+				<rule avp="Velocity-Estimate" required="false" max="1"/>
+				<rule avp="EUTRAN-Positioning-Data" required="false" max="1"/>
+				<rule avp="ECGI" required="false" max="1"/>
+				<rule avp="GERAN-Positioning-Info" required="false" max="1"/>
+				<rule avp="Cell-Global-Identity" required="false" max="1"/>
+				<rule avp="UTRAN-Positioning-Info" required="false" max="1"/>
+				<rule avp="Serving-Node" required="false" max="1"/>
+				<rule avp="PLA-Flags" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<command code="8388621" short="LR" name="Location-Report">
+			<request>
+				<!-- 3GPP TS 29.172 section 7.3.3 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="true" max="1"/>
+				<rule avp="Location-Event" required="true" max="1"/>
+				<rule avp="LCS-EPS-Client-Name" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="MSISDN" required="false" max="1"/>
+				<rule avp="IMEI" required="false" max="1"/>
+				<rule avp="Location-Estimate" required="false" max="1"/>
+				<rule avp="Accuracy-Fulfilment-Indicator" required="false" max="1"/>
+				<rule avp="Age-Of-Location-Estimate" required="false" max="1"/>
+				<rule avp="Velocity-Estimate" required="false" max="1"/>
+				<rule avp="EUTRAN-Positioning-Data" required="false" max="1"/>
+				<rule avp="ECGI" required="false" max="1"/>
+				<rule avp="GERAN-Positioning-Info" required="false" max="1"/>
+				<rule avp="Cell-Global-Identity" required="false" max="1"/>
+				<rule avp="UTRAN-Positioning-Info" required="false" max="1"/>
+				<rule avp="LCS-Service-Type-ID" required="false" max="1"/>
+				<rule avp="LCS-QoS-Class" required="false" max="1"/>
+				<rule avp="Serving-Node" required="false" max="1"/>
+				<rule avp="LRR-Flags" required="false" max="1"/>
+				<rule avp="LCS-Reference-Number" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.172 section 7.3.4 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
 				<rule avp="GMLC-Address" required="false" max="1"/>
-				<rule avp="AVP" required="false"/>
-			</data>
+				<rule avp="LRA-Flags" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<avp name="MSISDN" code="701" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Session-Direction" code="2707" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="inbound"/>
-				<item code="1" name="outbound"/>
-			</data>
+		<avp name="IMEI" code="1402" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Served-Party-IP-Address" code="848" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="Cell-Global-Identity" code="1604" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Server-Capabilities" code="603" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Mandatory-Capability" required="false"/>
-				<rule avp="Optional-Capability" required="false"/>
-				<rule avp="Server-Name" required="false"/>
-				<rule avp="AVP" required="false"/>
+		<avp name="LCS-Format-Indicator" code="1237" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="LOGICAL_NAME"/>
+				<item code="1" name="EMAIL_ADDRESS"/>
+				<item code="2" name="MSISDN"/>
+				<item code="3" name="URL"/>
+				<item code="4" name="SIP_URL"/>
 			</data>
 		</avp>
 
-		<avp name="Server-Name" code="602" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LCS-Name-String" code="1238" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Service-Data-Container" code="2040" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="AF-Correlation-Information" required="false" max="1"/>
-				<rule avp="Charging-Rule-Base-Name" required="false" max="1"/>
-				<rule avp="Accounting-Input-Octets" required="false" max="1"/>
-				<rule avp="Accounting-Output-Octets" required="false" max="1"/>
-				<rule avp="Local-Sequence-Number" required="false" max="1"/>
-				<rule avp="QoS-Information" required="false" max="1"/>
-				<rule avp="Rating-Group" required="false" max="1"/>
-				<rule avp="Change-Time" required="false" max="1"/>
-				<rule avp="Service-Identifier" required="false" max="1"/>
-				<rule avp="Service-Specific-Info" required="false" max="1"/>
-				<rule avp="ADC-Rule-Base-Name" required="false" max="1"/>
-				<rule avp="SGSN-Address" required="false" max="1"/>
-				<rule avp="Time-First-Usage" required="false"/>
-				<rule avp="Time-Last-Usage" required="false" max="1"/>
-				<rule avp="Time-Usage" required="false" max="1"/>
-				<rule avp="Change-Condition" required="false"/>
-				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
-				<rule avp="TGPP2-BSID" required="false" max="1"/>
-				<rule avp="Sponsor-Identity" required="false" max="1"/>
-				<rule avp="Application-Service-Provider-Identity" required="false" max="1"/>
-				<rule avp="Presence-Reporting-Area-Status" required="false" max="1"/>
-				<rule avp="User-CSG-Information" required="false" max="1"/>
+		<avp name="LCS-Requestor-Id-String" code="1240" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+
+		<avp name="LCS-Client-Type" code="1241" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="EMERGENCY_SERVICES"/>
+				<item code="1" name="VALUE_ADDED_SERVICES"/>
+				<item code="2" name="PLMN_OPERATOR_SERVICES"/>
+				<item code="3" name="LAWFUL_INTERCEPT_SERVICES"/>
 			</data>
 		</avp>
 
-		<avp name="Service-Id" code="855" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Location-Estimate" code="1242" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Service-Information" code="873" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Subscription-Id" required="false"/>
-				<rule avp="AoC-Information" required="false" max="1"/>
-				<rule avp="PS-Information" required="false" max="1"/>
-				<rule avp="IMS-Information" required="false" max="1"/>
-				<rule avp="MMS-Information" required="false" max="1"/>
-				<rule avp="LCS-Information" required="false" max="1"/>
-				<rule avp="PoC-Information" required="false" max="1"/>
-				<rule avp="MBMS-Information" required="false" max="1"/>
-				<rule avp="SMS-Information" required="false" max="1"/>
-				<rule avp="VCS-Information" required="false" max="1"/>
-				<rule avp="MMTel-Information" required="false" max="1"/>
-				<rule avp="Service-Generic-Information" required="false" max="1"/>
-				<rule avp="IM-Information" required="false" max="1"/>
-				<rule avp="DCD-Information" required="false" max="1"/>
-			</data>
+		<avp name="SGSN-Number" code="1489" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Service-Mode" code="2032" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Serving-Node" code="2401" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="SGSN-Number" required="false" max="1"/>
+				<rule avp="SGSN-Name" required="false" max="1"/>
+				<rule avp="SGSN-Realm" required="false" max="1"/>
+				<rule avp="MME-Name" required="false" max="1"/>
+				<rule avp="MME-Realm" required="false" max="1"/>
+				<rule avp="MSC-Number" required="false" max="1"/>
+				<rule avp="LCS-Capabilities-Sets" required="false" max="1"/>
+				<rule avp="GMLC-Address" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Service-Specific-Data" code="863" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="MME-Name" code="2402" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="Service-Specific-Info" code="1249" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Service-Specific-Data" required="false" max="1"/>
-				<rule avp="Service-Specific-Type" required="false" max="1"/>
-			</data>
+		<avp name="MSC-Number" code="2403" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Service-Specific-Type" code="1257" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LCS-Capabilities-Sets" code="2404" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Serving-Node-Type" code="2047" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="SGSN"/>
-				<item code="1" name="PMIPSGW"/>
-				<item code="2" name="GTPSGW"/>
-				<item code="3" name="ePDG"/>
-				<item code="4" name="hSGW"/>
-				<item code="5" name="MME"/>
-				<item code="6" name="TWAN"/>
-			</data>
+		<avp name="GMLC-Address" code="2405" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
 		</avp>
 
-		<avp name="Session-Priority" code="650" must="V" may="-" must-not="M" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="PRIORITY-0"/>
-				<item code="1" name="PRIORITY-1"/>
-				<item code="2" name="PRIORITY-2"/>
-				<item code="3" name="PRIORITY-3"/>
-				<item code="4" name="PRIORITY-4"/>
-			</data>
+		<avp name="MME-Realm" code="2408" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="SGSN-Address" code="1228" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="SGSN-Name" code="2409" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="SGW-Address" code="2067" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="SGSN-Realm" code="2410" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="SGW-Change" code="2065" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SLg-Location-Type" code="2500" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="ACR_Start_NOT_due_to_SGW_Change"/>
-				<item code="1" name="ACR_Start_due_to_SGW_Change"/>
+				<item code="0" name="CURRENT_LOCATION"/>
+				<item code="1" name="CURRENT_OR_LAST_KNOWN_LOCATION"/>
+				<item code="2" name="INITIAL_LOCATION"/>
+				<item code="3" name="ACTIVATE_DEFERRED_LOCATION"/>
+				<item code="4" name="CANCEL_DEFERRED_LOCATION"/>
+				<item code="5" name="NOTIFICATION_VERIFICATION_ONLY"/>
 			</data>
 		</avp>
 
-		<avp name="SIP-Method" code="824" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="LCS-EPS-Client-Name" code="2501" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Name-String" required="false" max="1"/>
+				<rule avp="LCS-Format-Indicator" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="SIP-Request-Timestamp-Fraction" code="2301" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LCS-Requestor-Name" code="2502" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Requestor-Id-String" required="false" max="1"/>
+				<rule avp="LCS-Format-Indicator" required="false" max="1"/>
+			</data>
+		</avp>
+
+		<avp name="LCS-Priority" code="2503" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="SIP-Request-Timestamp" code="834" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="LCS-QoS" code="2504" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-QoS-Class" required="false" max="1"/>
+				<rule avp="Horizontal-Accuracy" required="false" max="1"/>
+				<rule avp="Vertical-Accuracy" required="false" max="1"/>
+				<rule avp="Vertical-Requested" required="false" max="1"/>
+				<rule avp="Response-Time" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="SIP-Response-Timestamp-Fraction" code="2302" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Horizontal-Accuracy" code="2505" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="SIP-Response-Timestamp" code="835" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="Vertical-Accuracy" code="2506" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="SM-Device-Trigger-Indicator" code="3407" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Vertical-Requested" code="2507" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="Not DeviceTrigger"/>
-				<item code="1" name="Device Trigger"/>
+				<item code="0" name="VERTICAL_COORDINATE_IS_NOT_REQUESTED"/>
+				<item code="1" name="VERTICAL_COORDINATE_IS_REQUESTED"/>
 			</data>
 		</avp>
 
-		<avp name="SM-Device-Trigger-Information" code="3405" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="MTC-IWF-Address" required="false" max="1"/>
-				<rule avp="Reference-Number" required="false" max="1"/>
-				<rule avp="Serving-Node" required="false" max="1"/>
-				<rule avp="Validity-Time" required="false" max="1"/>
-				<rule avp="Priority-Indication" required="false" max="1"/>
-				<rule avp="Application-Port-Identifier" required="false" max="1"/>
+		<avp name="Velocity-Requested" code="2508" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="VELOCITY_IS_NOT_REQUESTED"/>
+				<item code="1" name="VELOCITY_IS_REQUESTED"/>
 			</data>
 		</avp>
 
-		<avp name="SM-Discharge-Time" code="2012" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
-		</avp>
-
-		<avp name="SM-Message-Type" code="2007" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Response-Time" code="2509" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="SUBMISSION"/>
-				<item code="1" name="DELIVERY_REPORT"/>
-				<item code="2" name="SM Service Request"/>
+				<item code="0" name="LOW_DELAY"/>
+				<item code="1" name="DELAY_TOLERANT"/>
 			</data>
 		</avp>
 
-		<avp name="SM-Protocol-Id" code="2013" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
-		</avp>
-
-		<avp name="SM-Sequence-Number" code="3408" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LCS-Supported-GAD-Shapes" code="2510" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="SMSC-Address" code="2017" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Address"/>
+		<avp name="LCS-Codeword" code="2511" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="SMS-Information" code="2000" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="SMS-Node" required="false" max="1"/>
-				<rule avp="Client-Address" required="false" max="1"/>
-				<rule avp="Originator-SCCP-Address" required="false" max="1"/>
-				<rule avp="SMSC-Address" required="false" max="1"/>
-				<rule avp="Data-Coding-Scheme" required="false" max="1"/>
-				<rule avp="SM-Discharge-Time" required="false" max="1"/>
-				<rule avp="SM-Message-Type" required="false" max="1"/>
-				<rule avp="Originator-Interface" required="false" max="1"/>
-				<rule avp="SM-Protocol-Id" required="false" max="1"/>
-				<rule avp="Reply-Path-Requested" required="false" max="1"/>
-				<rule avp="SM-Status" required="false" max="1"/>
-				<rule avp="SM-User-Data-Header" required="false" max="1"/>
-				<rule avp="Number-Of-Messages-Sent" required="false"/>
-				<rule avp="SM-Sequence-Number" required="false"/>
-				<rule avp="Recipient-Info" required="false"/>
-				<rule avp="Originator-Received-Address" required="false" max="1"/>
-				<rule avp="SM-Service-Type" required="false" max="1"/>
-				<rule avp="SMS-Result" required="false" max="1"/>
-				<rule avp="SM-Device-Trigger-Indicator" required="false" max="1"/>
-				<rule avp="SM-Device-Trigger-Information" required="false" max="1"/>
+		<avp name="LCS-Privacy-Check" code="2512" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="ALLOWED_WITHOUT_NOTIFICATION"/>
+				<item code="1" name="ALLOWED_WITH_NOTIFICATION"/>
+				<item code="2" name="ALLOWED_IF_NO_RESPONSE"/>
+				<item code="3" name="RESTRICTED_IF_NO_RESPONSE"/>
+				<item code="4" name="NOT_ALLOWED"/>
 			</data>
 		</avp>
 
-		<avp name="SMS-Node" code="2016" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Accuracy-Fulfilment-Indicator" code="2513" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="SMS Router"/>
-				<item code="1" name="IP-SM-GW"/>
-				<item code="2" name="SMS Router and IP-SM-GW"/>
-				<item code="3" name="SMS-SC"/>
+				<item code="0" name="REQUESTED_ACCURACY_FULFILLED"/>
+				<item code="1" name="REQUESTED_ACCURACY_NOT_FULFILLED"/>
 			</data>
 		</avp>
 
-		<avp name="SMS-Result" code="3409" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Age-Of-Location-Estimate" code="2514" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="SM-Service-Type" code="2029" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="VAS4SMS Short Message content processing"/>
-				<item code="1" name="VAS4SMS Short Message forwarding"/>
-				<item code="2" name="VAS4SMS Short Message Forwarding multiple subscriptions"/>
-				<item code="3" name="VAS4SMS Short Message filtering"/>
-				<item code="4" name="VAS4SMS Short Message receipt"/>
-				<item code="5" name="VAS4SMS Short Message Network Storage"/>
-				<item code="6" name="VAS4SMS Short Message to multiple destinations"/>
-				<item code="7" name="VAS4SMS Short Message Virtual Private Network (VPN)"/>
-				<item code="8" name="VAS4SMS Short Message Auto Reply"/>
-				<item code="9" name="VAS4SMS Short Message Personal Signature"/>
-				<item code="10" name="VAS4SMS Short Message Deferred Delivery"/>
-			</data>
+		<avp name="Velocity-Estimate" code="2515" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="SM-Status" code="2014" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="EUTRAN-Positioning-Data" code="2516" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="SM-User-Data-Header" code="2015" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="ECGI" code="2517" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Sponsor-Identity" code="531" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Location-Event" code="2518" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="EMERGENCY_CALL_ORIGINATION"/>
+				<item code="1" name="EMERGENCY_CALL_RELEASE"/>
+				<item code="2" name="MO_LR"/>
+				<item code="3" name="EMERGENCY_CALL_HANDOVER"/>
+				<item code="4" name="DEFERRED_MT_LR_RESPONSE"/>
+			</data>
 		</avp>
 
-		<avp name="SSID" code="1524" must="V"	may="-" must-not="M,P" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="LCS-Service-Type-ID" code="2520" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Start-of-Charging" code="3419" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="LCS-Privacy-Check-Non-Session" code="2521" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Privacy-Check" required="true" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Start-Time" code="2041" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="LCS-Privacy-Check-Session" code="2522" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="LCS-Privacy-Check" required="true" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Status-AS-Code" code="2702" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LCS-QoS-Class" code="2523" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Enumerated">
-				<item code="0" name="4xx"/>
-				<item code="1" name="5xx"/>
-				<item code="2" name="Timeout"/>
+				<item code="0" name="ASSURED"/>
+				<item code="1" name="BEST_EFFORT"/>
 			</data>
 		</avp>
 
-		<avp name="Stop-Time" code="2042" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="GERAN-Positioning-Info" code="2524" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Grouped">
+				<rule avp="GERAN-Positioning-Data" required="false" max="1"/>
+				<rule avp="GERAN-GANSS-Positioning-Data" required="false" max="1"/>
+			</data>
 		</avp>
 
-		<avp name="Submission-Time" code="1202" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="GERAN-Positioning-Data" code="2525" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Subscriber-Role" code="2033" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Originating"/>
-				<item code="1" name="Terminating"/>
-			</data>
+		<avp name="GERAN-GANSS-Positioning-Data" code="2526" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Supplementary-Service" code="2048" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="UTRAN-Positioning-Info" code="2527" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="MMTel-SService-Type" required="false" max="1"/>
-				<rule avp="Service-Mode" required="false" max="1"/>
-				<rule avp="Number-Of-Diversions" required="false" max="1"/>
-				<rule avp="Associated-Party-Address" required="false" max="1"/>
-				<rule avp="Service-Id" required="false" max="1"/>
-				<rule avp="Change-Time" required="false" max="1"/>
-				<rule avp="Number-Of-Participants" required="false" max="1"/>
-				<rule avp="Participant-Action-Type" required="false" max="1"/>
-				<rule avp="CUG-Information" required="false" max="1"/>
-				<rule avp="AoC-Information" required="false" max="1"/>
+				<rule avp="UTRAN-Positioning-Data" required="false" max="1"/>
+				<rule avp="UTRAN-GANSS-Positioning-Data" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="TAD-Identifier" code="2717" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="CS"/>
-				<item code="1" name="PS"/>
-			</data>
+		<avp name="UTRAN-Positioning-Data" code="2528" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Talk-Burst-Exchange" code="1255" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="PoC-Change-Time" required="true" max="1"/>
-				<rule avp="Number-Of-Talk-Bursts" required="false" max="1"/>
-				<rule avp="Talk-Burst-Volume" required="false" max="1"/>
-				<rule avp="Talk-Burst-Time" required="false" max="1"/>
-				<rule avp="Number-Of-Received-Talk-Bursts" required="false" max="1"/>
-				<rule avp="Received-Talk-Burst-Volume" required="false" max="1"/>
-				<rule avp="Received-Talk-Burst-Time" required="false" max="1"/>
-				<rule avp="Number-Of-Participants" required="false" max="1"/>
-				<rule avp="PoC-Change-Condition" required="false" max="1"/>
-			</data>
+		<avp name="UTRAN-GANSS-Positioning-Data" code="2529" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Talk-Burst-Time" code="1286" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LRR-Flags" code="2530" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Talk-Burst-Volume" code="1287" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LCS-Reference-Number" code="2531" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="PLR-Flags" code="2545" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Tariff-Information" code="2060" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Current-Tariff" required="true" max="1"/>
-				<rule avp="Tariff-Time-Change" required="false" max="1"/>
-				<rule avp="Next-Tariff" required="false" max="1"/>
-			</data>
+		<avp name="PLA-Flags" code="2546" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Tariff-XML" code="2306" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="LRA-Flags" code="2549" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="TDF-IP-Address" code="1091" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="Address"/>
+	</application>
+
+	<application id="16777291" type="auth" name="TGPP SLh">
+		<!-- 3GPP TS 29.173 SLh interface between the GMLC and the HSS -->
+		<vendor id="10415" name="TGPP"/>
+
+		<command code="8388622" short="RI" name="LCS-Routing-Info">
+			<request>
+				<!-- 3GPP TS 29.173 section 6.2.1 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="MSISDN" required="false" max="1"/>
+				<rule avp="GMLC-Number" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.173 section 6.2.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="MSISDN" required="false" max="1"/>
+				<rule avp="LMSI" required="false" max="1"/>
+				<rule avp="Serving-Node" required="false" max="1"/>
+				<rule avp="Additional-Serving-Node" required="false"/>
+				<rule avp="GMLC-Address" required="false" max="1"/>
+				<rule avp="PPR-Address" required="false" max="1"/>
+				<rule avp="Supported-Features" required="false"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<avp name="MSISDN" code="701" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="SGSN-Number" code="1489" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
+		</avp>
+
+		<avp name="GMLC-Number" code="1474" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Teleservice" code="3413" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="LMSI" code="2400" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Terminal-Information" code="1401" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Serving-Node" code="2401" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="IMEI" required="false" max="1"/>
-				<rule avp="TGPP2-MEID" required="false" max="1"/>
-				<rule avp="Software-Version" required="false" max="1"/>
-				<rule avp="AVP" required="false"/>
+				<rule avp="SGSN-Number" required="false" max="1"/>
+				<rule avp="SGSN-Name" required="false" max="1"/>
+				<rule avp="SGSN-Realm" required="false" max="1"/>
+				<rule avp="MME-Name" required="false" max="1"/>
+				<rule avp="MME-Realm" required="false" max="1"/>
+				<rule avp="MSC-Number" required="false" max="1"/>
+				<rule avp="LCS-Capabilities-Sets" required="false" max="1"/>
+				<rule avp="GMLC-Address" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Terminating-IOI" code="840" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="MME-Name" code="2402" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="Time-First-Usage" code="2043" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="MSC-Number" code="2403" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Time-Last-Usage" code="2044" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="LCS-Capabilities-Sets" code="2404" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Time-Quota-Mechanism" code="1270" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Time-Quota-Type" required="true" max="1"/>
-				<rule avp="Base-Time-Interval" required="true" max="1"/>
-			</data>
+		<avp name="GMLC-Address" code="2405" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
 		</avp>
 
-		<avp name="Time-Quota-Threshold" code="868" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="MME-Realm" code="2408" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="Time-Quota-Type" code="1271" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="DISCRETE_TIME_PERIOD"/>
-				<item code="1" name="CONTINUOUS_TIME_PERIOD"/>
-			</data>
+		<avp name="SGSN-Name" code="2409" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
 		</avp>
 
-		<avp name="Time-Stamps" code="833" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SGSN-Realm" code="2410" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="DiameterIdentity"/>
+		</avp>
+
+		<avp name="Additional-Serving-Node" code="2406" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="SIP-Request-Timestamp" required="false" max="1"/>
-				<rule avp="SIP-Response-Timestamp" required="false" max="1"/>
-				<rule avp="SIP-Request-Timestamp-Fraction" required="false" max="1"/>
-				<rule avp="SIP-Response-Timestamp-Fraction" required="false" max="1"/>
+				<rule avp="SGSN-Number" required="false" max="1"/>
+				<rule avp="SGSN-Name" required="false" max="1"/>
+				<rule avp="SGSN-Realm" required="false" max="1"/>
+				<rule avp="MME-Name" required="false" max="1"/>
+				<rule avp="MME-Realm" required="false" max="1"/>
+				<rule avp="MSC-Number" required="false" max="1"/>
+				<rule avp="LCS-Capabilities-Sets" required="false" max="1"/>
+				<rule avp="GMLC-Address" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Time-Usage" code="2045" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="PPR-Address" code="2407" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Address"/>
 		</avp>
 
-		<avp name="TMGI" code="900" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+	</application>
+</diameter>`
+
+var tgppzhznXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+
+	<application id="16777221" type="auth" name="TGPP Zh">
+		<!-- 3GPP TS 29.109 Zh interface between the BSF and the HSS -->
+		<vendor id="10415" name="TGPP"/>
+
+		<command code="303" short="MA" name="Multimedia-Auth">
+			<request>
+				<!-- 3GPP TS 29.109 section 6.1.1 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="Public-Identity" required="false" max="1"/>
+				<rule avp="SIP-Auth-Data-Item" required="false" max="1"/>
+				<rule avp="SIP-Number-Auth-Items" required="false" max="1"/>
+				<rule avp="Server-Name" required="false" max="1"/>
+				<rule avp="GUSS-Timestamp" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.109 section 6.1.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="Public-Identity" required="false" max="1"/>
+				<rule avp="SIP-Number-Auth-Items" required="false" max="1"/>
+				<rule avp="SIP-Auth-Data-Item" required="false" max="1"/>
+				<rule avp="GBA-UserSecSettings" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
+
+		<avp name="GBA-UserSecSettings" code="400" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Token-Text" code="1215" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="GUSS-Timestamp" code="409" must="V" may="P" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
+		</avp>
+
+		<avp name="Public-Identity" code="601" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Total-Number-Of-Messages-Exploded" code="2113" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="Server-Name" code="602" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Total-Number-Of-Messages-Sent" code="2114" must="V,M"	may="P" must-not="-" may-encrypt="Y" vendor-id="10415">
+		<avp name="SIP-Number-Auth-Items" code="607" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Traffic-Data-Volumes" code="2046" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="QoS-Information" required="false" max="1"/>
-				<rule avp="Accounting-Input-Octets" required="false" max="1"/>
-				<rule avp="Accounting-Output-Octets" required="false" max="1"/>
-				<rule avp="Change-condition" required="false" max="1"/>
-				<rule avp="Change-Time" required="false" max="1"/>
-				<rule avp="TGPP-User-Location-Info" required="false" max="1"/>
-				<rule avp="TGPP-Charging-Id" required="false" max="1"/>
-				<rule avp="Presence-Reporting-Area-Status" required="false" max="1"/>
-				<rule avp="User-CSG-Information" required="false" max="1"/>
-			</data>
+		<avp name="SIP-Authentication-Scheme" code="608" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
 		</avp>
 
-		<avp name="Transcoder-Inserted-Indication" code="2605" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Transcoder Not Inserted"/>
-				<item code="1" name="Transcoder Inserted"/>
-			</data>
+		<avp name="SIP-Authenticate" code="609" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Transit-IOI-List" code="2701" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="SIP-Authorization" code="610" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Trigger" code="1264" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="SIP-Auth-Data-Item" code="612" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="Grouped">
-				<rule avp="Trigger-Type" required="false"/>
+				<rule avp="SIP-Item-Number" required="false" max="1"/>
+				<rule avp="SIP-Authentication-Scheme" required="false" max="1"/>
+				<rule avp="SIP-Authenticate" required="false" max="1"/>
+				<rule avp="SIP-Authorization" required="false" max="1"/>
+				<rule avp="Confidentiality-Key" required="false" max="1"/>
+				<rule avp="Integrity-Key" required="false" max="1"/>
 			</data>
 		</avp>
 
-		<avp name="Trigger-Type" code="870" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="1" name="CHANGE_IN_SGSN_IP_ADDRESS"/>
-				<item code="2" name="CHANGE_IN_QOS"/>
-				<item code="3" name="CHANGE_IN_LOCATION"/>
-				<item code="4" name="CHANGE_IN_RAT"/>
-				<item code="5" name="CHANGE_IN_UE_TIMEZONE"/>
-				<item code="10" name="CHANGEINQOS_TRAFFIC_CLASS"/>
-				<item code="11" name="CHANGEINQOS_RELIABILITY_CLASS"/>
-				<item code="12" name="CHANGEINQOS_RELIABILITY_CLASS"/>
-				<item code="13" name="CHANGEINQOS_PEAK_THROUGHPUT"/>
-				<item code="14" name="CHANGEINQOS_PRECEDENCE_CLASS"/>
-				<item code="15" name="CHANGEINQOS_MEAN_THROUGHPUT"/>
-				<item code="16" name="CHANGEINQOS_MAXIMUM_BIT_RATE_FOR_UPLINK"/>
-				<item code="17" name="CHANGEINQOS_MAXIMUM_BIT_RATE_FOR_DOWNLINK"/>
-				<item code="18" name="CHANGEINQOS_RESIDUAL_BER"/>
-				<item code="19" name="CHANGEINQOS_SDU_ERROR_RATIO"/>
-				<item code="20" name="CHANGEINQOS_TRANSFER_DELAY"/>
-				<item code="21" name="CHANGEINQOS_TRAFFIC_HANDLING_PRIORITY"/>
-				<item code="22" name="CHANGEINQOS_GUARANTEED_BIT_RATE_FOR_UPLINK"/>
-				<item code="23" name="CHANGEINQOS_GUARANTEED_BIT_RATE_FOR_DOWNLINK"/>
-				<item code="24" name="CHANGEINQOS_APN_AGGREGATE_MAXIMUM_BIT_RATE"/>
-				<item code="30" name="CHANGEINLOCATION_MCC"/>
-				<item code="31" name="CHANGEINLOCATION_MNC"/>
-				<item code="32" name="CHANGEINLOCATION_RAC"/>
-				<item code="33" name="CHANGEINLOCATION_LAC"/>
-				<item code="34" name="CHANGEINLOCATION_CellId"/>
-				<item code="35" name="CHANGEINLOCATION_TAC"/>
-				<item code="36" name="CHANGEINLOCATION_ECGI"/>
-				<item code="40" name="CHANGE_IN_MEDIA_COMPOSITION"/>
-				<item code="50" name="CHANGE_IN_PARTICIPANTS_NMB"/>
-				<item code="51" name="CHANGE_IN_ THRSHLD_OF_PARTICIPANTS_NMB"/>
-				<item code="52" name="CHANGE_IN_USER_PARTICIPATING_TYPE"/>
-				<item code="60" name="CHANGE_IN_SERVICE_CONDITION"/>
-				<item code="61" name="CHANGE_IN_SERVING_NODE"/>
-				<item code="70" name="CHANGE_IN_USER_CSG_INFORMATION"/>
-				<item code="71" name="CHANGE_IN_HYBRID_SUBSCRIBED_USER_CSG_INFORMATION"/>
-				<item code="72" name="CHANGE_IN_HYBRID_UNSUBSCRIBED_USER_CSG_INFORMATION"/>
-				<item code="73" name="CHANGE_OF_UE_PRESENCE_IN_PRESENCE_REPORTING_AREA"/>
-			</data>
+		<avp name="SIP-Item-Number" code="613" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
 		</avp>
 
-		<avp name="Trunk-Group-Id" code="851" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Incoming-Trunk-Group-Id" required="false" max="1"/>
-				<rule avp="Outgoing-Trunk-Group-Id" required="false" max="1"/>
-			</data>
+		<avp name="Confidentiality-Key" code="625" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="TWAN-User-Location-Info" code="2714" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="SSID" required="true" max="1"/>
-				<rule avp="BSSID" required="false" max="1"/>
-			</data>
+		<avp name="Integrity-Key" code="626" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="Type-Number" code="1204" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
-		</avp>
+	</application>
 
-		<avp name="Unit-Cost" code="2061" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Value-Digits" required="true" max="1"/>
-				<rule avp="Exponent" required="false" max="1"/>
-			</data>
-		</avp>
+	<application id="16777220" type="auth" name="TGPP Zn">
+		<!-- 3GPP TS 29.109 Zn interface between the NAF and the BSF -->
+		<vendor id="10415" name="TGPP"/>
 
-		<avp name="Unit-Quota-Threshold" code="1226" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
-		</avp>
+		<command code="310" short="BI" name="Bootstrapping-Info">
+			<request>
+				<!-- 3GPP TS 29.109 section 6.2.1 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Destination-Realm" required="true" max="1"/>
+				<rule avp="Transaction-Identifier" required="true" max="1"/>
+				<rule avp="NAF-Hostname" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="Destination-Host" required="false" max="1"/>
+				<rule avp="GAA-Service-Identifier" required="false" max="1"/>
+				<rule avp="GBA_U-Awareness-Indicator" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</request>
+			<answer>
+				<!-- 3GPP TS 29.109 section 6.2.2 -->
+				<rule avp="Session-Id" required="true" max="1"/>
+				<rule avp="Vendor-Specific-Application-Id" required="true" max="1"/>
+				<rule avp="Result-Code" required="false" max="1"/>
+				<rule avp="Experimental-Result" required="false" max="1"/>
+				<rule avp="Origin-Host" required="true" max="1"/>
+				<rule avp="Origin-Realm" required="true" max="1"/>
+				<rule avp="Auth-Session-State" required="true" max="1"/>
+				<rule avp="User-Name" required="false" max="1"/>
+				<rule avp="ME-Key-Material" required="false" max="1"/>
+				<rule avp="UICC-Key-Material" required="false" max="1"/>
+				<rule avp="Key-ExpiryTime" required="false" max="1"/>
+				<rule avp="BootstrapInfoCreationTime" required="false" max="1"/>
+				<rule avp="GBA-UserSecSettings" required="false" max="1"/>
+				<rule avp="GBA-Type" required="false" max="1"/>
+				<rule avp="Proxy-Info" required="false"/>
+				<rule avp="Route-Record" required="false"/>
+			</answer>
+		</command>
 
-		<avp name="User-CSG-Information" code="2319" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="CSG-Id" required="true" max="1"/>
-				<rule avp="CSG-Access-Mode" required="true" max="1"/>
-				<rule avp="CSG-Membership-Indication" required="false" max="1"/>
-			</data>
+		<avp name="GBA-UserSecSettings" code="400" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="User-Data" code="606" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
+		<avp name="Transaction-Identifier" code="401" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
 			<data type="OctetString"/>
 		</avp>
 
-		<avp name="User-Location-Info-Time" code="2812" must="V"	may="P" must-not="M" may-encrypt="Y" vendor-id="10415">
-			<data type="Time"/>
+		<avp name="NAF-Hostname" code="402" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="User-Participating-Type" code="1279" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Enumerated">
-				<item code="0" name="Normal"/>
-				<item code="1" name="NW PoC Box"/>
-				<item code="2" name="UE PoC Box"/>
-			</data>
+		<avp name="GAA-Service-Identifier" code="403" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="User-Session-Id" code="830" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="Key-ExpiryTime" code="404" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="VAS-Id" code="1102" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="ME-Key-Material" code="405" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="VASP-Id" code="1101" must="V,M"	may="-" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="UTF8String"/>
+		<avp name="UICC-Key-Material" code="406" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="OctetString"/>
 		</avp>
 
-		<avp name="VCS-Information" code="3410" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Grouped">
-				<rule avp="Bearer-Capability" required="false" max="1"/>
-				<rule avp="Network-Call-Reference-Number" required="false" max="1"/>
-				<rule avp="MSC-Address" required="false" max="1"/>
-				<rule avp="Basic-Service-Code" required="false" max="1"/>
-				<rule avp="ISUP-Location-Number" required="false" max="1"/>
-				<rule avp="VLR-Number" required="false" max="1"/>
-				<rule avp="Forwarding-Pending" required="false" max="1"/>
-				<rule avp="ISUP-Release-Cause" required="false" max="1"/>
-				<rule avp="Start-Time" required="false" max="1"/>
-				<rule avp="Start-of-Charging" required="false" max="1"/>
-				<rule avp="Stop-Time" required="false" max="1"/>
-				<rule avp="PS-Free-Format-Data" required="false" max="1"/>
+		<avp name="GBA_U-Awareness-Indicator" code="407" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="NO"/>
+				<item code="1" name="YES"/>
 			</data>
 		</avp>
 
-		<avp name="VLR-Number" code="3420" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="OctetString"/>
+		<avp name="BootstrapInfoCreationTime" code="408" must="V" may="P" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="Time"/>
 		</avp>
 
-		<avp name="Volume-Quota-Threshold" code="869" must="V,M" may="P" must-not="-" may-encrypt="N" vendor-id="10415">
-			<data type="Unsigned32"/>
+		<avp name="GBA-Type" code="410" must="V" may="P" must-not="M" may-encrypt="N" vendor-id="10415">
+			<data type="Enumerated">
+				<item code="0" name="3G GBA"/>
+				<item code="1" name="2G GBA"/>
+			</data>
 		</avp>
 
 	</application>
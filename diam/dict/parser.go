@@ -20,6 +20,9 @@ import (
 const (
 	// UndefinedVendorID specifies a non existing vendorID
 	UndefinedVendorID = 4294967295
+
+	// maxCommandCode is the largest command code, which is 24 bits long.
+	maxCommandCode = 1<<24 - 1
 )
 
 // Parser is the root element for dictionaries and supports multiple XML
@@ -39,6 +42,7 @@ type Parser struct {
 	command map[codeIdx]*Command // Command index
 	mu      sync.Mutex           // Protects all maps
 	once    sync.Once
+	limits  bool // Any AVP has length limits
 }
 
 type codeIdx struct {
@@ -79,12 +83,7 @@ func (p *Parser) LoadFile(filename string) error {
 func (p *Parser) Load(r io.Reader) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
-	p.once.Do(func() {
-		p.appcode = make(map[uint32]*App)
-		p.avpname = make(map[nameIdx]*AVP)
-		p.avpcode = make(map[codeIdx]*AVP)
-		p.command = make(map[codeIdx]*Command)
-	})
+	p.init()
 	f := new(File)
 	d := xml.NewDecoder(r)
 	if err := d.Decode(f); err != nil {
@@ -92,10 +91,20 @@ func (p *Parser) Load(r io.Reader) error {
 	}
 	p.file = append(p.file, f)
 	for _, app := range f.App {
-		// Cache supported applications by ID.
-		p.appcode[app.ID] = app
+		// Cache supported applications by ID. Applications split
+		// across dictionaries, like the Credit Control application
+		// and its 3GPP AVPs, keep the first one loaded, extended
+		// with the commands of the others.
+		if prev, ok := p.appcode[app.ID]; ok {
+			prev.Command = append(prev.Command, app.Command...)
+		} else {
+			p.appcode[app.ID] = app
+		}
 		// Cache commands.
 		for _, cmd := range app.Command {
+			if cmd.Code > maxCommandCode {
+				return fmt.Errorf("Command code %d of %s is larger than 24 bits", cmd.Code, cmd.Name)
+			}
 			p.command[codeIdx{app.ID, cmd.Code, UndefinedVendorID}] = cmd
 		}
 		// Cache AVPs.
@@ -111,11 +120,27 @@ func (p *Parser) Load(r io.Reader) error {
 			if err := updateType(avp); err != nil {
 				return err
 			}
+			if err := checkLimits(avp); err != nil {
+				return err
+			}
+			if avp.Data.MinLength > 0 || avp.Data.MaxLength > 0 {
+				p.limits = true
+			}
 		}
 	}
 	return nil
 }
 
+// init allocates the indexes of the Parser, once.
+func (p *Parser) init() {
+	p.once.Do(func() {
+		p.appcode = make(map[uint32]*App)
+		p.avpname = make(map[nameIdx]*AVP)
+		p.avpcode = make(map[codeIdx]*AVP)
+		p.command = make(map[codeIdx]*Command)
+	})
+}
+
 func updateType(a *AVP) error {
 	id, exists := datatype.Available[a.Data.TypeName]
 	if !exists {
@@ -125,6 +150,38 @@ func updateType(a *AVP) error {
 	return nil
 }
 
+// checkLimits checks the length limits of the AVP a.
+func checkLimits(a *AVP) error {
+	d := &a.Data
+	if d.MinLength == 0 && d.MaxLength == 0 {
+		return nil
+	}
+	switch d.Type {
+	case datatype.AddressType,
+		datatype.DiameterIdentityType,
+		datatype.DiameterURIType,
+		datatype.IPFilterRuleType,
+		datatype.OctetStringType,
+		datatype.QoSFilterRuleType,
+		datatype.UTF8StringType:
+	default:
+		return fmt.Errorf("Length limits of %s not supported for %s", a.Name, d.TypeName)
+	}
+	if d.MinLength < 0 || d.MaxLength < 0 || (d.MaxLength > 0 && d.MinLength > d.MaxLength) {
+		return fmt.Errorf("Invalid length limits of %s: min-length=%d max-length=%d",
+			a.Name, d.MinLength, d.MaxLength)
+	}
+	return nil
+}
+
+// HasLengthLimits returns true if any AVP of the dictionaries loaded
+// has length limits, see Data.ValidLength.
+func (p *Parser) HasLengthLimits() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.limits
+}
+
 // String returns the Parser represented in a human readable form.
 func (p *Parser) String() string {
 	var b bytes.Buffer
@@ -0,0 +1,110 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Dictionary parser, query functions.  Part of go-diameter.
+
+package dict
+
+import "sort"
+
+// CommandAVP is an AVP of a command, with the rule of the command for it.
+type CommandAVP struct {
+	Rule *Rule // Rule of the command
+	AVP  *AVP  // AVP of the rule, nil if not in the dictionary
+}
+
+// Applications returns the applications loaded in the Parser, one per
+// application id, ordered by id. Applications split across dictionary
+// files have the commands of all files, see Load.
+//
+// Applications must never be called concurrently with LoadFile or Load.
+func (p *Parser) Applications() []*App {
+	apps := make([]*App, 0, len(p.appcode))
+	for _, app := range p.appcode {
+		apps = append(apps, app)
+	}
+	sort.Sort(appsByID(apps))
+	return apps
+}
+
+// Commands returns the commands of the application appid, ordered by
+// code. Commands of the base protocol are not included unless appid
+// is 0.
+//
+// Commands must never be called concurrently with LoadFile or Load.
+func (p *Parser) Commands(appid uint32) ([]*Command, error) {
+	app, err := p.App(appid)
+	if err != nil {
+		return nil, err
+	}
+	cmds := make([]*Command, len(app.Command))
+	copy(cmds, app.Command)
+	sort.Sort(commandsByCode(cmds))
+	return cmds, nil
+}
+
+// AVPs returns the AVPs defined by the application appid, including
+// the ones of all dictionary files, ordered by code and vendor id.
+// AVPs of the base protocol are not included unless appid is 0.
+//
+// AVPs must never be called concurrently with LoadFile or Load.
+func (p *Parser) AVPs(appid uint32) ([]*AVP, error) {
+	if _, err := p.App(appid); err != nil {
+		return nil, err
+	}
+	var avps []*AVP
+	for idx, avp := range p.avpcode {
+		if idx.appID == appid && idx.vendorID == avp.VendorID {
+			avps = append(avps, avp)
+		}
+	}
+	sort.Sort(avpsByCode(avps))
+	return avps, nil
+}
+
+// CommandAVPs returns the AVPs of the request or answer of the command
+// code of the application appid, in the order of the rules of the
+// command. AVPs are looked up in the application and in the base
+// protocol, like FindAVP.
+//
+// CommandAVPs must never be called concurrently with LoadFile or Load.
+func (p *Parser) CommandAVPs(appid, code uint32, request bool) ([]*CommandAVP, error) {
+	cmd, err := p.FindCommand(appid, code)
+	if err != nil {
+		return nil, err
+	}
+	rules := cmd.Answer.Rule
+	if request {
+		rules = cmd.Request.Rule
+	}
+	avps := make([]*CommandAVP, len(rules))
+	for i, rule := range rules {
+		avp, _ := p.FindAVP(appid, rule.AVP)
+		avps[i] = &CommandAVP{Rule: rule, AVP: avp}
+	}
+	return avps, nil
+}
+
+type appsByID []*App
+
+func (s appsByID) Len() int           { return len(s) }
+func (s appsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s appsByID) Less(i, j int) bool { return s[i].ID < s[j].ID }
+
+type commandsByCode []*Command
+
+func (s commandsByCode) Len() int           { return len(s) }
+func (s commandsByCode) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s commandsByCode) Less(i, j int) bool { return s[i].Code < s[j].Code }
+
+type avpsByCode []*AVP
+
+func (s avpsByCode) Len() int      { return len(s) }
+func (s avpsByCode) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s avpsByCode) Less(i, j int) bool {
+	if s[i].Code != s[j].Code {
+		return s[i].Code < s[j].Code
+	}
+	return s[i].VendorID < s[j].VendorID
+}
@@ -0,0 +1,79 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict
+
+import "testing"
+
+func TestApplications(t *testing.T) {
+	apps := Default.Applications()
+	if len(apps) == 0 || len(apps) > len(Default.Apps()) {
+		t.Fatalf("Unexpected # of apps: %d", len(apps))
+	}
+	for i := 1; i < len(apps); i++ {
+		if apps[i-1].ID >= apps[i].ID {
+			t.Fatalf("Apps not ordered by unique id: %d, %d", apps[i-1].ID, apps[i].ID)
+		}
+	}
+	if apps[0].ID != 0 {
+		t.Fatalf("Unexpected app.ID. Want 0, have %d", apps[0].ID)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	cmds, err := Default.Commands(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) == 0 || cmds[0].Code != 272 {
+		t.Fatalf("Unexpected commands of Credit-Control: %v", cmds)
+	}
+	if _, err = Default.Commands(1e6); err != ErrApplicationUnsupported {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestAVPs(t *testing.T) {
+	avps, err := Default.AVPs(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for i, avp := range avps {
+		if i > 0 && avps[i-1].Code > avp.Code {
+			t.Fatalf("AVPs not ordered by code: %d, %d", avps[i-1].Code, avp.Code)
+		}
+		if avp.Code == 263 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("Session-Id not found in the base protocol")
+	}
+}
+
+func TestCommandAVPs(t *testing.T) {
+	avps, err := Default.CommandAVPs(4, 272, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(avps) == 0 {
+		t.Fatal("No AVPs in CCR")
+	}
+	first := avps[0]
+	if first.Rule.AVP != "Session-Id" || !first.Rule.Required {
+		t.Fatalf("Unexpected first rule of CCR: %#v", first.Rule)
+	}
+	if first.AVP == nil || first.AVP.Code != 263 {
+		t.Fatalf("Unexpected AVP of rule: %#v", first.AVP)
+	}
+	for _, a := range avps {
+		if a.AVP != nil && a.AVP.Name != a.Rule.AVP {
+			t.Fatalf("Unexpected AVP %s for rule %s", a.AVP.Name, a.Rule.AVP)
+		}
+	}
+	if _, err = Default.CommandAVPs(4, 1e6, false); err == nil {
+		t.Fatal("Unexpected AVPs of unknown command")
+	}
+}
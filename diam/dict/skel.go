@@ -68,17 +68,35 @@ type Data struct {
 	TypeName string          `xml:"type,attr"`
 	Enum     []*Enum         `xml:"item"` // In case of Enumerated AVP data
 	Rule     []*Rule         `xml:"rule"` // In case of Grouped AVPs
+
+	// MinLength and MaxLength limit the length in octets of the
+	// data of OctetString based AVPs, like UTF8String and
+	// DiameterIdentity. Zero means no limit. Example:
+	//
+	//	<data type="UTF8String" min-length="6" max-length="15"/>
+	MinLength int `xml:"min-length,attr"`
+	MaxLength int `xml:"max-length,attr"`
+}
+
+// ValidLength returns false if n octets of data are outside the length
+// limits of the Data.
+func (d *Data) ValidLength(n int) bool {
+	return n >= d.MinLength && (d.MaxLength == 0 || n <= d.MaxLength)
 }
 
 // Enum contains the code and name of Enumerated items.
 type Enum struct {
-	Code uint8  `xml:"code,attr"`
+	Code int32  `xml:"code,attr"`
 	Name string `xml:"name,attr"`
 }
 
 // Rule defines the usage rules of an AVP.
+//
+// Fixed AVPs, represented as < AVP > in the Command Code Format, must
+// appear before the required and optional AVPs.
 type Rule struct {
 	AVP      string `xml:"avp,attr"` // AVP Name
+	Fixed    bool   `xml:"fixed,attr"`
 	Required bool   `xml:"required,attr"`
 	Min      int    `xml:"min,attr"`
 	Max      int    `xml:"max,attr"`
@@ -0,0 +1,105 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict
+
+// Stack returns a new Parser with the dictionaries of the parsers ps,
+// in order of precedence: AVPs and commands defined by more than one
+// of them, e.g. an AVP code of a vendor defined by both a standard and
+// an operator-specific dictionary, are taken from the first parser
+// that defines them, instead of the last one loaded.
+//
+// Applications defined by more than one parser have the commands of
+// all of them. The parsers ps are not modified, and must not be loaded
+// while stacked.
+//
+// Different peers may use different stacks, see diam.Server.Dict:
+//
+//	std, _ := dict.NewParser("3gpp.xml")
+//	op, _ := dict.NewParser("operator.xml")
+//	opFirst := dict.Stack(op, std, dict.Default)
+func Stack(ps ...*Parser) *Parser {
+	s := new(Parser)
+	s.init()
+	merged := make(map[uint32]bool)
+	for _, p := range ps {
+		p.mu.Lock()
+		s.file = append(s.file, p.file...)
+		for id, app := range p.appcode {
+			prev, ok := s.appcode[id]
+			if !ok {
+				s.appcode[id] = app
+				continue
+			}
+			if !merged[id] {
+				// Copy the application of another parser
+				// before extending it.
+				c := *prev
+				c.Command = append([]*Command(nil), prev.Command...)
+				prev = &c
+				s.appcode[id] = prev
+				merged[id] = true
+			}
+			prev.Command = append(prev.Command, app.Command...)
+		}
+		for k, avp := range p.avpname {
+			if _, ok := s.avpname[k]; !ok {
+				s.avpname[k] = avp
+			}
+		}
+		for k, avp := range p.avpcode {
+			if _, ok := s.avpcode[k]; !ok {
+				s.avpcode[k] = avp
+			}
+		}
+		for k, cmd := range p.command {
+			if _, ok := s.command[k]; !ok {
+				s.command[k] = cmd
+			}
+		}
+		s.limits = s.limits || p.limits
+		p.mu.Unlock()
+	}
+	return s
+}
+
+// Prefer makes the definitions of the Parser q take precedence over the
+// ones of p for the messages of the application appID: the AVPs and
+// commands q defines for appID, or for the base protocol, are found
+// first when looked up for appID. Other applications are not affected.
+//
+// Prefer must never be called concurrently with the lookups of p, and
+// q must not be loaded afterwards.
+func (p *Parser) Prefer(appID uint32, q *Parser) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p.init()
+	// Definitions of the base protocol first, overridden by the
+	// ones of the application.
+	for _, id := range []uint32{0, appID} {
+		for k, avp := range q.avpname {
+			if k.appID == id {
+				p.avpname[nameIdx{appID, k.name, k.vendorID}] = avp
+			}
+		}
+		for k, avp := range q.avpcode {
+			if k.appID == id {
+				p.avpcode[codeIdx{appID, k.code, k.vendorID}] = avp
+			}
+		}
+		for k, cmd := range q.command {
+			if k.appID == id {
+				p.command[codeIdx{appID, k.code, k.vendorID}] = cmd
+			}
+		}
+	}
+	if _, ok := p.appcode[appID]; !ok {
+		if app, ok := q.appcode[appID]; ok {
+			p.appcode[appID] = app
+		}
+	}
+	p.limits = p.limits || q.limits
+}
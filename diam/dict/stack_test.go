@@ -0,0 +1,113 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package dict
+
+import (
+	"strings"
+	"testing"
+)
+
+const stdStackXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="16777001">
+		<command code="8388001" short="ST" name="Stack-Test">
+			<request><rule avp="Charging-Key" required="false" max="1"/></request>
+			<answer><rule avp="Charging-Key" required="false" max="1"/></answer>
+		</command>
+		<avp name="Charging-Key" code="9000" must="V" may="M" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="Unsigned32"/>
+		</avp>
+	</application>
+</diameter>`
+
+const opStackXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="0">
+		<avp name="Operator-Charging-Key" code="9000" must="V" may="M" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+	</application>
+	<application id="16777001">
+		<command code="8388002" short="OT" name="Operator-Test">
+			<request><rule avp="Operator-Charging-Key" required="false" max="1"/></request>
+			<answer><rule avp="Operator-Charging-Key" required="false" max="1"/></answer>
+		</command>
+		<avp name="Operator-Charging-Key" code="9000" must="V" may="M" must-not="-" may-encrypt="N" vendor-id="10415">
+			<data type="UTF8String"/>
+		</avp>
+	</application>
+</diameter>`
+
+func newStackParser(t *testing.T, xml string) *Parser {
+	p, err := NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = p.Load(strings.NewReader(xml)); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestStack(t *testing.T) {
+	std := newStackParser(t, stdStackXML)
+	op := newStackParser(t, opStackXML)
+	for _, test := range []struct {
+		p    *Parser
+		want string
+	}{
+		{Stack(op, std), "Operator-Charging-Key"},
+		{Stack(std, op), "Charging-Key"},
+	} {
+		avp, err := test.p.FindAVPWithVendor(16777001, 9000, 10415)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if avp.Name != test.want {
+			t.Fatalf("Unexpected AVP. Want %s, have %s", test.want, avp.Name)
+		}
+		app, err := test.p.App(16777001)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(app.Command) != 2 {
+			t.Fatalf("Unexpected commands of the stacked application: %d", len(app.Command))
+		}
+		for _, code := range []uint32{8388001, 8388002} {
+			if _, err = test.p.FindCommand(16777001, code); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	for _, p := range []*Parser{std, op} {
+		if app, _ := p.App(16777001); len(app.Command) != 1 {
+			t.Fatalf("Stacked parser was modified: %d commands", len(app.Command))
+		}
+	}
+}
+
+func TestParser_Prefer(t *testing.T) {
+	p := Stack(newStackParser(t, stdStackXML), Default)
+	p.Prefer(4, newStackParser(t, opStackXML))
+	for _, test := range []struct {
+		appID uint32
+		want  string
+	}{
+		{4, "Operator-Charging-Key"},
+		{16777001, "Charging-Key"},
+	} {
+		avp, err := p.FindAVPWithVendor(test.appID, 9000, 10415)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if avp.Name != test.want {
+			t.Fatalf("Unexpected AVP of application %d. Want %s, have %s",
+				test.appID, test.want, avp.Name)
+		}
+	}
+	if _, err := p.FindAVP(4, "Session-Id"); err != nil {
+		t.Fatal(err)
+	}
+}
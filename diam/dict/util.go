@@ -152,7 +152,7 @@ func (p *Parser) FindCommand(appid, code uint32) (*Command, error) {
 // given AVP appid, code and n. (n is the enum code in the dictionary)
 //
 // Enum must never be called concurrently with LoadFile or Load.
-func (p *Parser) Enum(appid, code uint32, n uint8) (*Enum, error) {
+func (p *Parser) Enum(appid, code uint32, n int32) (*Enum, error) {
 	avp, err := p.FindAVP(appid, code)
 	if err != nil {
 		return nil, err
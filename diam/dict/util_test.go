@@ -11,8 +11,8 @@ import (
 
 func TestApps(t *testing.T) {
 	apps := Default.Apps()
-	if len(apps) != 4 {
-		t.Fatalf("Unexpected # of apps. Want 4, have %d", len(apps))
+	if len(apps) != 11 {
+		t.Fatalf("Unexpected # of apps. Want 11, have %d", len(apps))
 	}
 	// Base protocol.
 	if apps[0].ID != 0 {
@@ -89,6 +89,25 @@ func TestFindCommand(t *testing.T) {
 	} else if cmd.Short != "CE" {
 		t.Fatalf("Unexpected command: %#v", cmd)
 	}
+	// Mobile IPv4 and SIP applications.
+	for _, test := range []struct {
+		app, code uint32
+		short     string
+	}{
+		{2, 260, "AM"},
+		{2, 262, "HA"},
+		{6, 283, "UA"},
+		{6, 286, "MA"},
+		{6, 288, "PP"},
+	} {
+		cmd, err := Default.FindCommand(test.app, test.code)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cmd.Short != test.short {
+			t.Fatalf("Unexpected command %d. Want %s, have %s", test.code, test.short, cmd.Short)
+		}
+	}
 }
 
 func TestEnum(t *testing.T) {
@@ -100,6 +119,12 @@ func TestEnum(t *testing.T) {
 			item.Name,
 		)
 	}
+	// Items beyond a byte, like RAT-Type EUTRAN.
+	if item, err := Default.Enum(16777238, 1032, 1004); err != nil {
+		t.Fatal(err)
+	} else if item.Name != "EUTRAN" {
+		t.Errorf("Unexpected value %s, expected EUTRAN", item.Name)
+	}
 }
 
 func TestRule(t *testing.T) {
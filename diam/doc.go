@@ -20,6 +20,11 @@ organized in sub-packages with specific functionality:
 
  * diam/dict: a dictionary parser that supports collections of dictionaries.
 
+ * diam/journal: write-ahead journal of outgoing requests for guaranteed
+         delivery of accounting messages.
+
+ * diam/session: session state and the stores that keep it.
+
 If you're looking to go right into code, see the examples subdirectory for
 applications like clients and servers.
 
@@ -0,0 +1,175 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"crypto/tls"
+	"errors"
+	"log"
+	"net"
+	"sync"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// DTLS secures the connections of a transport, typically SCTP, with
+// DTLS as specified for Diameter in RFC 6733 section 13 and RFC 6083.
+//
+// The standard library has no DTLS implementation. Packages providing
+// one make it available to DialDTLS and ListenDTLS with RegisterDTLS.
+type DTLS interface {
+	// Client returns the client side of a DTLS connection over c.
+	Client(c net.Conn, config *tls.Config) (SecureConn, error)
+
+	// Server returns the server side of a DTLS connection over c.
+	// Like tls.Server, it should not block: the handshake runs
+	// when the connection is served, see SecureConn.
+	Server(c net.Conn, config *tls.Config) (SecureConn, error)
+}
+
+// SecureConn is a connection secured with TLS or DTLS, like *tls.Conn.
+// Connections are served after a successful Handshake, and their
+// ConnectionState is returned by Conn.TLS.
+type SecureConn interface {
+	net.Conn
+	Handshake() error
+	ConnectionState() tls.ConnectionState
+}
+
+// ErrNoDTLS is returned by DialDTLS and ListenDTLS when no DTLS
+// implementation is registered.
+var ErrNoDTLS = errors.New("diam: no DTLS implementation registered")
+
+var (
+	dtlsMu   sync.RWMutex
+	dtlsImpl DTLS
+)
+
+// RegisterDTLS makes the DTLS implementation d available to DialDTLS
+// and ListenDTLS. If RegisterDTLS is called twice the last
+// implementation is used.
+func RegisterDTLS(d DTLS) {
+	dtlsMu.Lock()
+	dtlsImpl = d
+	dtlsMu.Unlock()
+}
+
+func registeredDTLS() (DTLS, error) {
+	dtlsMu.RLock()
+	defer dtlsMu.RUnlock()
+	if dtlsImpl == nil {
+		return nil, ErrNoDTLS
+	}
+	return dtlsImpl, nil
+}
+
+// DialDTLS is like DialTLS, but for DTLS over the transport registered
+// as network, e.g. "sctp".
+func DialDTLS(network, addr, certFile, keyFile string, handler Handler, dp *dict.Parser) (Conn, error) {
+	srv := &Server{Network: network, Addr: addr, Handler: handler, Dict: dp}
+	return srv.DialDTLS(certFile, keyFile)
+}
+
+// DialDTLS is like Server.DialTLS, but for DTLS over the transport of
+// srv.Network, SCTP if unset.
+func (srv *Server) DialDTLS(certFile, keyFile string) (Conn, error) {
+	d, err := registeredDTLS()
+	if err != nil {
+		return nil, err
+	}
+	addr := srv.Addr
+	if len(addr) == 0 {
+		addr = ":3868"
+	}
+	config, err := clientTLSConfig(srv, addr, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	rw, err := srv.dial(context.Background(), srv.dtlsNetwork(), addr)
+	if err != nil {
+		return nil, err
+	}
+	sc, err := d.Client(rw, config)
+	if err != nil {
+		rw.Close()
+		return nil, err
+	}
+	c, err := srv.newConn(sc)
+	if err != nil {
+		return nil, err
+	}
+	go c.serve()
+	return c.writer, nil
+}
+
+// ListenDTLS announces on the local network address addr using the
+// transport registered as network, e.g. "sctp", and returns a listener
+// that accepts DTLS connections. If addr is blank, ":3868" is used.
+func ListenDTLS(network, addr string, config *tls.Config) (net.Listener, error) {
+	d, err := registeredDTLS()
+	if err != nil {
+		return nil, err
+	}
+	l, err := Listen(network, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &dtlsListener{Listener: l, dtls: d, config: config}, nil
+}
+
+// ListenAndServeDTLS is like ListenAndServeTLS, but for DTLS over the
+// transport of srv.Network, SCTP if unset.
+func (srv *Server) ListenAndServeDTLS(certFile, keyFile string) error {
+	config := &tls.Config{}
+	if srv.TLSConfig != nil {
+		config = srv.TLSConfig.Clone()
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return err
+	}
+	config.Certificates = []tls.Certificate{cert}
+	l, err := ListenDTLS(srv.dtlsNetwork(), srv.Addr, config)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+// dtlsNetwork returns the transport of the server for DTLS.
+func (srv *Server) dtlsNetwork() string {
+	if len(srv.Network) == 0 {
+		return "sctp"
+	}
+	return srv.Network
+}
+
+// dtlsListener wraps the connections of a listener with DTLS.
+type dtlsListener struct {
+	net.Listener
+	dtls   DTLS
+	config *tls.Config
+}
+
+// Accept returns the next connection that the DTLS implementation
+// accepts. Connections it fails to set up are closed and skipped, so
+// that a single peer does not stop the listener.
+func (l *dtlsListener) Accept() (net.Conn, error) {
+	for {
+		c, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		sc, err := l.dtls.Server(c, l.config)
+		if err != nil {
+			log.Printf("diam: DTLS error from %s: %v", c.RemoteAddr(), err)
+			c.Close()
+			continue
+		}
+		return sc, nil
+	}
+}
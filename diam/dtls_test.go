@@ -0,0 +1,138 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// streamDTLS stands for a DTLS implementation, using TLS over the
+// stream transports available in tests.
+type streamDTLS struct{}
+
+func (streamDTLS) Client(c net.Conn, config *tls.Config) (diam.SecureConn, error) {
+	return tls.Client(c, config), nil
+}
+
+func (streamDTLS) Server(c net.Conn, config *tls.Config) (diam.SecureConn, error) {
+	return tls.Server(c, config), nil
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSecureTransport(t *testing.T) {
+	if _, err := diam.ListenDTLS("tcp", "127.0.0.1:0", nil); err != diam.ErrNoDTLS {
+		t.Fatalf("Unexpected error. Want %v, have %v", diam.ErrNoDTLS, err)
+	}
+	diam.RegisterDTLS(streamDTLS{})
+	defer diam.RegisterDTLS(nil)
+	config := &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}
+	l, err := diam.ListenDTLS("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	errc := make(chan error, 1)
+	smux := diam.NewServeMux()
+	smux.Handle("CER", handleCER(errc, true))
+	go (&diam.Server{Handler: smux}).Serve(l)
+	wait := make(chan struct{})
+	cmux := diam.NewServeMux()
+	cmux.Handle("CEA", handleCEA(errc, wait))
+	cli, err := diam.DialDTLS("tcp", l.Addr().String(), "", "", cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	sendCER(cli)
+	select {
+	case <-wait:
+	case err := <-errc:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: no CER or CEA received")
+	}
+	if cli.TLS() == nil {
+		t.Fatal("Missing DTLS connection state")
+	}
+}
+
+// failingDTLS fails to set up the first server connection.
+type failingDTLS struct {
+	streamDTLS
+	failed chan struct{}
+}
+
+func (d failingDTLS) Server(c net.Conn, config *tls.Config) (diam.SecureConn, error) {
+	select {
+	case <-d.failed:
+		return d.streamDTLS.Server(c, config)
+	default:
+		close(d.failed)
+		return nil, errors.New("bad peer")
+	}
+}
+
+func TestListenDTLS_ServerError(t *testing.T) {
+	diam.RegisterDTLS(failingDTLS{failed: make(chan struct{})})
+	defer diam.RegisterDTLS(nil)
+	l, err := diam.ListenDTLS("tcp", "127.0.0.1:0", &tls.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < 2; i++ {
+		c, err := net.Dial("tcp", l.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+	}
+	accepted := make(chan error, 1)
+	go func() {
+		c, err := l.Accept()
+		if err == nil {
+			c.Close()
+		}
+		accepted <- err
+	}()
+	select {
+	case err := <-accepted:
+		if err != nil {
+			t.Fatalf("Listener failed after a bad peer: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: second connection was not accepted")
+	}
+}
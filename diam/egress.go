@@ -0,0 +1,71 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import "fmt"
+
+// The EgressFunc type is a hook called for messages written to a
+// connection with Message.WriteTo, before they are serialized.
+//
+// Hooks may inspect and modify the message, e.g. to add vendor AVPs.
+// Returning an error aborts the write, and the error is returned by
+// Message.WriteTo.
+type EgressFunc func(c Conn, m *Message) error
+
+// The EgressHandler interface is implemented by Handlers that process
+// the outgoing messages of their connections, like ServeMux.
+type EgressHandler interface {
+	// Egress is called for every message written to the
+	// connection c, before the message is serialized.
+	Egress(c Conn, m *Message) error
+}
+
+// egressWriter is implemented by connections that run the egress
+// hooks of their handler.
+type egressWriter interface {
+	egress(m *Message) error
+}
+
+// RequireAVP returns an EgressFunc that rejects messages missing
+// any of the given AVP codes, e.g. Origin-Host and Origin-Realm.
+func RequireAVP(codes ...uint32) EgressFunc {
+	return func(c Conn, m *Message) error {
+	next:
+		for _, code := range codes {
+			for _, a := range m.AVP {
+				if a.Code == code {
+					continue next
+				}
+			}
+			return fmt.Errorf("missing mandatory AVP %d in outgoing message", code)
+		}
+		return nil
+	}
+}
+
+// StripAVP returns an EgressFunc that removes the top level AVPs with
+// any of the given codes from messages, e.g. AVPs for internal use
+// that must not leak to peers.
+func StripAVP(codes ...uint32) EgressFunc {
+	strip := make(map[uint32]bool, len(codes))
+	for _, code := range codes {
+		strip[code] = true
+	}
+	return func(c Conn, m *Message) error {
+		avps := m.AVP[:0]
+		for _, a := range m.AVP {
+			if strip[a.Code] {
+				m.Header.MessageLength -= uint32(a.Len())
+				continue
+			}
+			avps = append(avps, a)
+		}
+		for i := len(avps); i < len(m.AVP); i++ {
+			m.AVP[i] = nil
+		}
+		m.AVP = avps
+		return nil
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestServeMux_Egress(t *testing.T) {
+	errc := make(chan error, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+		a.NewAVP(avp.ProxyInfo, avp.Mbit, 0, datatype.OctetString("internal"))
+		_, err := a.WriteTo(c)
+		errc <- err
+	})
+	smux.HandleEgress(diam.StripAVP(avp.ProxyInfo))
+	smux.HandleEgress(func(c diam.Conn, m *diam.Message) error {
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+		return nil
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		if _, err := m.FindAVP(avp.OriginRealm, 0); err != nil {
+			t.Fatal("Origin-Realm was not added by the egress hook")
+		}
+		if _, err := m.FindAVP(avp.ProxyInfo, 0); err == nil {
+			t.Fatal("Proxy-Info was not stripped by the egress hook")
+		}
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DWA")
+	}
+}
+
+func TestServeMux_EgressError(t *testing.T) {
+	cmux := diam.NewServeMux()
+	cmux.HandleEgress(diam.RequireAVP(avp.OriginHost, avp.OriginRealm))
+	srv := diamtest.NewServer(diam.NewServeMux(), nil)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	if _, err = m.WriteTo(cli); err == nil {
+		t.Fatal("Unexpected message without Origin-Realm written")
+	}
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServeMux_EgressDuringHandle(t *testing.T) {
+	errc := make(chan error, 1)
+	smux := diam.NewServeMux()
+	smux.HandleEgress(func(c diam.Conn, m *diam.Message) error {
+		return nil
+	})
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		// Queue a writer on the mux while the handler runs, like
+		// sm clients registering handlers on every dial.
+		handled := make(chan struct{})
+		go func() {
+			smux.HandleFunc("DPR", func(c diam.Conn, m *diam.Message) {})
+			close(handled)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+		_, err := a.WriteTo(c)
+		errc <- err
+		<-handled
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out writing the answer")
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// EnumError reports an Enumerated AVP whose value is not one of the
+// items of its dictionary.
+type EnumError struct {
+	AVP *AVP // The offending AVP
+}
+
+// Error implements the error interface.
+func (e *EnumError) Error() string {
+	return fmt.Sprintf("value %d of AVP %d (vendor %d) is not enumerated in the dictionary",
+		e.AVP.Data, e.AVP.Code, e.AVP.VendorID)
+}
+
+// CheckEnums returns an *EnumError for each Enumerated AVP of the
+// Message, including the ones embedded in grouped AVPs, whose value is
+// not one of the items of the dictionary. AVPs whose dictionary has no
+// items, or that are unknown to the dictionary, are not checked.
+//
+// Messages read in lazy decode mode are decoded entirely first.
+func (m *Message) CheckEnums() ([]*EnumError, error) {
+	if err := m.DecodeAll(); err != nil {
+		return nil, err
+	}
+	return checkEnums(m.Dictionary(), m.Header.ApplicationID, m.AVP, nil), nil
+}
+
+// checkEnums appends the errors of the Enumerated AVPs of avps to errs.
+func checkEnums(d *dict.Parser, appID uint32, avps []*AVP, errs []*EnumError) []*EnumError {
+	for _, a := range avps {
+		switch v := a.Data.(type) {
+		case *GroupedAVP:
+			errs = checkEnums(d, appID, v.AVP, errs)
+		case datatype.Enumerated:
+			dictAVP, err := d.FindAVPWithVendor(appID, a.Code, a.VendorID)
+			if err != nil || len(dictAVP.Data.Enum) == 0 {
+				continue
+			}
+			if !enumerated(dictAVP.Data.Enum, int32(v)) {
+				errs = append(errs, &EnumError{AVP: a})
+			}
+		}
+	}
+	return errs
+}
+
+func enumerated(items []*dict.Enum, v int32) bool {
+	for _, item := range items {
+		if item.Code == v {
+			return true
+		}
+	}
+	return false
+}
+
+// EnumMode is what an EnumValidator does with messages that have
+// Enumerated AVPs with values not in the dictionary.
+type EnumMode int
+
+// Enum modes.
+const (
+	// EnumIgnore does not check the messages.
+	EnumIgnore EnumMode = iota
+
+	// EnumWarn reports the errors and handles the messages as usual.
+	EnumWarn
+
+	// EnumReject reports the errors, answers requests with
+	// DIAMETER_INVALID_AVP_VALUE (5004) and drops answers.
+	EnumReject
+)
+
+// EnumValidator checks that the values of the Enumerated AVPs of
+// incoming messages are among the items of the dictionary, e.g. for
+// strict conformance testing. Values outside of the dictionary are
+// otherwise accepted, as most Enumerated AVPs are extensible.
+//
+// Example:
+//
+//	v := &diam.EnumValidator{
+//		Apps:     map[uint32]diam.EnumMode{4: diam.EnumReject},
+//		Reporter: mux,
+//	}
+//	mux.Handle("CCR", v.Handler(handleCCR))
+type EnumValidator struct {
+	// Mode is the mode of the applications not in Apps.
+	Mode EnumMode
+
+	// Apps is the mode of each application id, optional.
+	Apps map[uint32]EnumMode
+
+	// Reporter receives an ErrorReport with an *EnumError for each
+	// offending AVP, and the errors of messages that cannot be
+	// decoded. Optional.
+	Reporter ErrorReporter
+}
+
+// Handler returns a Handler that checks the Enumerated AVPs of incoming
+// messages before calling h, according to the mode of their
+// application.
+//
+// Rejected requests are answered with the first offending AVP in the
+// Failed-AVP.
+func (v *EnumValidator) Handler(h Handler) Handler {
+	return HandlerFunc(func(c Conn, m *Message) {
+		mode, ok := v.Apps[m.Header.ApplicationID]
+		if !ok {
+			mode = v.Mode
+		}
+		if mode == EnumIgnore {
+			h.ServeDIAM(c, m)
+			return
+		}
+		errs, err := m.CheckEnums()
+		if err != nil {
+			v.report(c, m, err)
+			return
+		}
+		for _, e := range errs {
+			v.report(c, m, e)
+		}
+		if len(errs) == 0 || mode == EnumWarn {
+			h.ServeDIAM(c, m)
+			return
+		}
+		if isRequest(m) {
+			a := m.Answer(InvalidAVPValue)
+			a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &GroupedAVP{AVP: []*AVP{errs[0].AVP}})
+			if _, err := a.WriteTo(c); err != nil {
+				v.report(c, m, err)
+			}
+		}
+	})
+}
+
+func (v *EnumValidator) report(c Conn, m *Message, err error) {
+	if v.Reporter != nil {
+		v.Reporter.Error(&ErrorReport{Conn: c, Message: m, Error: err})
+	}
+}
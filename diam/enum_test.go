@@ -0,0 +1,120 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func newEnumCCR(requestType, subscriptionType int32) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(requestType))
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(subscriptionType)),
+			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String("5511999999999")),
+		},
+	})
+	return m
+}
+
+func TestMessage_CheckEnums(t *testing.T) {
+	errs, err := newEnumCCR(1, 0).CheckEnums()
+	if err != nil || len(errs) != 0 {
+		t.Fatalf("Unexpected errors: %v, %v", errs, err)
+	}
+	errs, err = newEnumCCR(9, 99).CheckEnums()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Unexpected number of errors: %v", errs)
+	}
+	for i, code := range []uint32{avp.CCRequestType, avp.SubscriptionIDType} {
+		if errs[i].AVP.Code != code {
+			t.Fatalf("Unexpected error %d: %v", i, errs[i])
+		}
+	}
+}
+
+func TestEnumValidator(t *testing.T) {
+	handled := make(chan *diam.Message, 1)
+	h := diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		handled <- m
+	})
+	smux := diam.NewServeMux()
+	v := &diam.EnumValidator{
+		Mode:     diam.EnumWarn,
+		Apps:     map[uint32]diam.EnumMode{4: diam.EnumReject},
+		Reporter: smux,
+	}
+	smux.Handle("CCR", v.Handler(h))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if _, err = newEnumCCR(9, 0).WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-mc:
+		rc, err := a.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.InvalidAVPValue {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.InvalidAVPValue, v)
+		}
+		if _, err = a.FindAVP(avp.FailedAVP, 0); err != nil {
+			t.Fatal(err)
+		}
+	case <-handled:
+		t.Fatal("Rejected CCR was handled")
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	select {
+	case err := <-smux.ErrorReports():
+		if _, ok := err.Error.(*diam.EnumError); !ok {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for error report")
+	}
+
+	// Other applications only warn.
+	m := newEnumCCR(9, 0)
+	m.Header.ApplicationID = 16777238
+	v.Handler(h).ServeDIAM(nil, m)
+	select {
+	case <-handled:
+	default:
+		t.Fatal("CCR was not handled")
+	}
+	select {
+	case err := <-smux.ErrorReports():
+		if _, ok := err.Error.(*diam.EnumError); !ok {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	default:
+		t.Fatal("Missing error report")
+	}
+}
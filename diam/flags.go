@@ -0,0 +1,266 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// FlagError is returned by CheckFlags and CheckAnswerFlags for messages
+// with command flags that violate RFC 6733 section 3.
+type FlagError struct {
+	Flag   uint8  // The offending flag, e.g. ErrorFlag
+	Reason string // Description of the violation
+}
+
+// Error implements the error interface.
+func (e *FlagError) Error() string {
+	return fmt.Sprintf("invalid command flags: %s", e.Reason)
+}
+
+// CheckFlags validates the command flags of m: answers must not set the
+// R bit, and the E bit is only set in answers with a protocol error
+// Result-Code (3xxx).
+//
+// Messages with a Result-Code AVP are considered answers. The E bit of
+// answers without Result-Code, e.g. with Experimental-Result, is not
+// validated.
+func CheckFlags(m *Message) error {
+	code, isAnswer := resultCode(m)
+	if m.Header.CommandFlags&RequestFlag != 0 {
+		if isAnswer {
+			return &FlagError{RequestFlag, "R bit set in answer"}
+		}
+		if m.Header.CommandFlags&ErrorFlag != 0 {
+			return &FlagError{ErrorFlag, "E bit set in request"}
+		}
+		return nil
+	}
+	if !isAnswer {
+		return nil
+	}
+	e := m.Header.CommandFlags&ErrorFlag != 0
+	switch {
+	case e && !isProtocolError(code):
+		return &FlagError{ErrorFlag, fmt.Sprintf("E bit set with Result-Code %d", code)}
+	case !e && isProtocolError(code):
+		return &FlagError{ErrorFlag, fmt.Sprintf("E bit not set with Result-Code %d", code)}
+	}
+	return nil
+}
+
+// CheckAnswerFlags is like CheckFlags for the answer a, and also
+// validates that the P bit of the request req is preserved in a.
+func CheckAnswerFlags(req, a *Message) error {
+	if err := CheckFlags(a); err != nil {
+		return err
+	}
+	if (req.Header.CommandFlags^a.Header.CommandFlags)&ProxiableFlag != 0 {
+		return &FlagError{ProxiableFlag, "P bit not preserved from request"}
+	}
+	return nil
+}
+
+// fixFlags corrects the command flags of m, using the request flags
+// reqFlags when known.
+func fixFlags(m *Message, reqFlags uint8, known bool) {
+	code, isAnswer := resultCode(m)
+	if isAnswer {
+		m.Header.CommandFlags &^= RequestFlag
+	}
+	if m.Header.CommandFlags&RequestFlag != 0 {
+		m.Header.CommandFlags &^= ErrorFlag
+		return
+	}
+	if isAnswer {
+		if isProtocolError(code) {
+			m.Header.CommandFlags |= ErrorFlag
+		} else {
+			m.Header.CommandFlags &^= ErrorFlag
+		}
+	}
+	if known {
+		m.Header.CommandFlags = m.Header.CommandFlags&^ProxiableFlag |
+			reqFlags&ProxiableFlag
+	}
+}
+
+func resultCode(m *Message) (uint32, bool) {
+	for _, a := range m.AVP {
+		if a.Code != avp.ResultCode || a.VendorID != 0 {
+			continue
+		}
+		if v, ok := a.Data.(datatype.Unsigned32); ok {
+			return uint32(v), true
+		}
+	}
+	return 0, false
+}
+
+// isRequest returns true if m has the R bit set and no Result-Code.
+func isRequest(m *Message) bool {
+	if m.Header.CommandFlags&RequestFlag == 0 {
+		return false
+	}
+	_, isAnswer := resultCode(m)
+	return !isAnswer
+}
+
+func isProtocolError(code uint32) bool {
+	return code >= 3000 && code < 4000
+}
+
+// FlagMode is the strictness of a FlagPolicy.
+type FlagMode int
+
+// FlagPolicy modes.
+const (
+	// FlagsReport reports violations to the Reporter of the policy,
+	// and sends or handles messages as they are.
+	FlagsReport FlagMode = iota
+
+	// FlagsFix reports violations and corrects the flags of outgoing
+	// messages. Incoming messages are handled as they are.
+	FlagsFix
+
+	// FlagsReject reports violations and rejects the messages:
+	// outgoing messages are not sent, incoming requests are answered
+	// with InvalidHDRBits (3008) and incoming answers are dropped.
+	FlagsReject
+)
+
+// FlagPolicy enforces valid command flags on the messages sent and
+// received by handlers, see CheckFlags and CheckAnswerFlags.
+//
+// To validate that answers preserve the P bit of their requests, the
+// policy tracks the requests sent and received on each connection
+// until they are answered or the connection is closed.
+//
+// Example:
+//
+//	p := &diam.FlagPolicy{Mode: diam.FlagsFix, Reporter: mux}
+//	mux.HandleEgress(p.Egress)
+//	mux.Handle("CCR", p.Handler(handleCCR))
+//	mux.Handle("RAA", p.Handler(handleRAA))
+type FlagPolicy struct {
+	Mode     FlagMode      // Strictness, FlagsReport by default
+	Reporter ErrorReporter // Receives the violations, optional
+
+	mu   sync.Mutex
+	reqs map[Conn]map[[2]uint32]uint8 // flags of unanswered requests
+}
+
+// Egress is an EgressFunc that validates outgoing messages.
+func (p *FlagPolicy) Egress(c Conn, m *Message) error {
+	flags, known, err := p.check(c, m)
+	if err != nil {
+		p.report(c, m, err)
+		switch p.Mode {
+		case FlagsFix:
+			fixFlags(m, flags, known)
+		case FlagsReject:
+			return err
+		}
+	}
+	if isRequest(m) {
+		p.track(c, m)
+	}
+	return nil
+}
+
+// Handler returns a Handler that validates incoming messages before
+// calling h.
+func (p *FlagPolicy) Handler(h Handler) Handler {
+	return HandlerFunc(func(c Conn, m *Message) {
+		if _, _, err := p.check(c, m); err != nil {
+			p.report(c, m, err)
+			if p.Mode == FlagsReject {
+				p.reject(c, m)
+				return
+			}
+		}
+		if isRequest(m) {
+			p.track(c, m)
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+// check validates the flags of m, sent or received on c. For answers,
+// it returns the flags of the request when known.
+func (p *FlagPolicy) check(c Conn, m *Message) (flags uint8, known bool, err error) {
+	if isRequest(m) {
+		return 0, false, CheckFlags(m)
+	}
+	if flags, known = p.answered(c, m); known {
+		req := &Message{Header: &Header{CommandFlags: flags}}
+		return flags, true, CheckAnswerFlags(req, m)
+	}
+	return 0, false, CheckFlags(m)
+}
+
+// reject answers the invalid request m with InvalidHDRBits. Invalid
+// answers are dropped.
+func (p *FlagPolicy) reject(c Conn, m *Message) {
+	if !isRequest(m) {
+		return
+	}
+	a := m.Answer(InvalidHDRBits)
+	if _, err := a.WriteTo(c); err != nil {
+		p.report(c, m, err)
+	}
+}
+
+func (p *FlagPolicy) report(c Conn, m *Message, err error) {
+	if p.Reporter != nil {
+		p.Reporter.Error(&ErrorReport{Conn: c, Message: m, Error: err})
+	}
+}
+
+func flagsKey(m *Message) [2]uint32 {
+	return [2]uint32{m.Header.HopByHopID, m.Header.EndToEndID}
+}
+
+// track records the flags of the request m, sent or received on c.
+func (p *FlagPolicy) track(c Conn, m *Message) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.reqs == nil {
+		p.reqs = make(map[Conn]map[[2]uint32]uint8)
+	}
+	reqs, ok := p.reqs[c]
+	if !ok {
+		reqs = make(map[[2]uint32]uint8)
+		p.reqs[c] = reqs
+		if c != nil {
+			go func() {
+				<-c.Done()
+				p.mu.Lock()
+				delete(p.reqs, c)
+				p.mu.Unlock()
+			}()
+		}
+	}
+	reqs[flagsKey(m)] = m.Header.CommandFlags
+}
+
+// answered removes the request answered by m from the tracked requests
+// of c, and returns its flags.
+func (p *FlagPolicy) answered(c Conn, m *Message) (uint8, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reqs, ok := p.reqs[c]
+	if !ok {
+		return 0, false
+	}
+	k := flagsKey(m)
+	flags, ok := reqs[k]
+	delete(reqs, k)
+	return flags, ok
+}
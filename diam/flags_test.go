@@ -0,0 +1,157 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestCheckFlags(t *testing.T) {
+	req := diam.NewRequest(diam.CreditControl, 4, nil)
+	req.Header.CommandFlags |= diam.ProxiableFlag
+	for i, test := range []struct {
+		flags uint8
+		code  uint32
+		ok    bool
+	}{
+		{diam.RequestFlag, 0, true},
+		{diam.RequestFlag | diam.ErrorFlag, 0, false},
+		{diam.RequestFlag | diam.ProxiableFlag, diam.Success, false},
+		{diam.ProxiableFlag, diam.Success, true},
+		{diam.ProxiableFlag | diam.ErrorFlag, diam.Success, false},
+		{diam.ProxiableFlag | diam.ErrorFlag, diam.UnableToDeliver, true},
+		{diam.ProxiableFlag, diam.UnableToDeliver, false},
+		{diam.ProxiableFlag, diam.MissingAVP, true},
+		{diam.ErrorFlag, 0, true},
+	} {
+		m := diam.NewMessage(diam.CreditControl, test.flags, 4, 1, 1, nil)
+		if test.code != 0 {
+			m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(test.code))
+		}
+		err := diam.CheckFlags(m)
+		if test.ok != (err == nil) {
+			t.Fatalf("Test %d: unexpected result: %v", i, err)
+		}
+		if test.ok && test.code != 0 {
+			if err = diam.CheckAnswerFlags(req, m); err != nil {
+				t.Fatalf("Test %d: %v", i, err)
+			}
+		}
+	}
+	a := req.Answer(diam.Success)
+	a.Header.CommandFlags &^= diam.ProxiableFlag
+	err, ok := diam.CheckAnswerFlags(req, a).(*diam.FlagError)
+	if !ok || err.Flag != diam.ProxiableFlag {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+// flagPolicyServer starts a server answering CCR with the Result-Code
+// and flags set by the answer function, under the policy p.
+func flagPolicyServer(p *diam.FlagPolicy, answer func(m *diam.Message) *diam.Message) (*diamtest.Server, *diam.ServeMux) {
+	smux := diam.NewServeMux()
+	p.Reporter = smux
+	smux.HandleEgress(p.Egress)
+	smux.Handle("CCR", p.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		answer(m).WriteTo(c)
+	})))
+	return diamtest.NewServer(smux, nil), smux
+}
+
+func sendFlagsCCR(t *testing.T, addr string, flags uint8) *diam.Message {
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.Header.CommandFlags |= flags
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-mc:
+		return a
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	return nil
+}
+
+func TestFlagPolicy_Fix(t *testing.T) {
+	p := &diam.FlagPolicy{Mode: diam.FlagsFix}
+	srv, smux := flagPolicyServer(p, func(m *diam.Message) *diam.Message {
+		// Drop the P bit and forget the E bit.
+		a := m.Answer(diam.UnableToDeliver)
+		a.Header.CommandFlags &^= diam.ProxiableFlag
+		return a
+	})
+	defer srv.Close()
+	a := sendFlagsCCR(t, srv.Addr, diam.ProxiableFlag)
+	want := uint8(diam.ProxiableFlag | diam.ErrorFlag)
+	if a.Header.CommandFlags != want {
+		t.Fatalf("Unexpected flags. Want %#x, have %#x", want, a.Header.CommandFlags)
+	}
+	select {
+	case err := <-smux.ErrorReports():
+		if _, ok := err.Error.(*diam.FlagError); !ok {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for error report")
+	}
+}
+
+func TestFlagPolicy_Reject(t *testing.T) {
+	p := &diam.FlagPolicy{Mode: diam.FlagsReject}
+	srv, smux := flagPolicyServer(p, func(m *diam.Message) *diam.Message {
+		return m.Answer(diam.Success)
+	})
+	defer srv.Close()
+	a := sendFlagsCCR(t, srv.Addr, diam.ErrorFlag)
+	if a.Header.CommandFlags&diam.ErrorFlag == 0 {
+		t.Fatal("Unexpected answer without the E bit")
+	}
+	rc, err := a.FindAVP(avp.ResultCode, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := rc.Data.(datatype.Unsigned32); v != diam.InvalidHDRBits {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.InvalidHDRBits, v)
+	}
+	select {
+	case err := <-smux.ErrorReports():
+		if _, ok := err.Error.(*diam.FlagError); !ok {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for error report")
+	}
+}
+
+func TestFlagPolicy_RejectEgress(t *testing.T) {
+	p := &diam.FlagPolicy{Mode: diam.FlagsReject}
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.Header.CommandFlags |= diam.ErrorFlag
+	if err := p.Egress(nil, m); err == nil {
+		t.Fatal("Unexpected success sending request with the E bit")
+	}
+	m = diam.NewRequest(diam.CreditControl, 4, nil)
+	if err := p.Egress(nil, m.Answer(diam.Success)); err != nil {
+		t.Fatal(err)
+	}
+}
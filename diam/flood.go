@@ -0,0 +1,90 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+	"time"
+)
+
+// FloodGuard protects servers from peers sending messages that cannot
+// be decoded, by limiting the rate of such messages per connection.
+//
+// Without a FloodGuard, the connection is closed on the first message
+// that cannot be decoded. With a FloodGuard, these messages are
+// reported to the ErrorReporter of the Handler and skipped, until the
+// peer exceeds one of the thresholds within Interval. The connection
+// is then disconnected with a *FloodError, see Conn.Err.
+//
+// Errors that break the framing of the stream, like an invalid header,
+// always close the connection.
+type FloodGuard struct {
+	// MaxMalformed is the maximum number of messages with AVPs that
+	// cannot be decoded accepted per Interval. Zero means no limit.
+	MaxMalformed int
+
+	// MaxUnknownCommands is the maximum number of messages of commands
+	// unknown to the dictionary accepted per Interval. Zero means no
+	// limit.
+	MaxUnknownCommands int
+
+	// Interval is the time window of the thresholds (default 1s).
+	Interval time.Duration
+
+	// Disconnect is called before closing the connection of peers
+	// that exceed the thresholds, e.g. to send DPR. It is called from
+	// the read loop of the connection and must not wait for answers.
+	// Optional.
+	Disconnect func(c Conn, err error)
+}
+
+// FloodError is the reason connections are closed by a FloodGuard.
+type FloodError struct {
+	UnknownCommand bool          // True for unknown commands, false for malformed messages
+	Count          int           // Number of messages within Interval
+	Interval       time.Duration // Interval of the FloodGuard
+}
+
+// Error implements the error interface.
+func (e *FloodError) Error() string {
+	kind := "malformed messages"
+	if e.UnknownCommand {
+		kind = "unknown commands"
+	}
+	return fmt.Sprintf("diam: peer sent %d %s in %s", e.Count, kind, e.Interval)
+}
+
+// floodCounter counts decode errors of a connection over a fixed
+// window of time.
+type floodCounter struct {
+	start     time.Time
+	malformed int
+	unknown   int
+}
+
+// add records the decode error de at the time now and returns a
+// *FloodError if a threshold of g is exceeded.
+func (fc *floodCounter) add(g *FloodGuard, de *decodeError, now time.Time) error {
+	interval := g.Interval
+	if interval <= 0 {
+		interval = time.Second
+	}
+	if now.Sub(fc.start) >= interval {
+		*fc = floodCounter{start: now}
+	}
+	count, max := &fc.malformed, g.MaxMalformed
+	if de.unknown {
+		count, max = &fc.unknown, g.MaxUnknownCommands
+	}
+	*count++
+	if max > 0 && *count > max {
+		return &FloodError{
+			UnknownCommand: de.unknown,
+			Count:          *count,
+			Interval:       interval,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+// rawMessage returns a request of the given command with the body b.
+func rawMessage(cmd uint32, b []byte) []byte {
+	h := &diam.Header{
+		Version:       1,
+		MessageLength: uint32(diam.HeaderLength + len(b)),
+		CommandFlags:  diam.RequestFlag,
+		CommandCode:   cmd,
+		HopByHopID:    1,
+		EndToEndID:    1,
+	}
+	return append(h.Serialize(), b...)
+}
+
+func TestFloodGuard(t *testing.T) {
+	for _, test := range []struct {
+		msg     []byte
+		unknown bool
+	}{
+		{rawMessage(9999, nil), true},
+		// AVP with a length larger than the message.
+		{rawMessage(diam.CapabilitiesExchange, []byte{0, 0, 1, 8, 0, 0, 0, 255}), false},
+	} {
+		mc := make(chan *diam.Message, 1)
+		conns := make(chan diam.Conn, 1)
+		smux := diam.NewServeMux()
+		smux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+			mc <- m
+		})
+		srv := diamtest.NewUnstartedServer(smux, nil)
+		srv.Config.FloodGuard = &diam.FloodGuard{
+			MaxMalformed:       2,
+			MaxUnknownCommands: 2,
+			Interval:           time.Minute,
+			Disconnect: func(c diam.Conn, err error) {
+				conns <- c
+			},
+		}
+		srv.Start()
+		defer srv.Close()
+		cli, err := net.Dial("tcp", srv.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cli.Close()
+		for i := 0; i < 2; i++ {
+			if _, err = cli.Write(test.msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The connection survives the errors under the threshold.
+		if _, err = sendCER(cli); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-mc:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for CER")
+		}
+		if _, err = cli.Write(test.msg); err != nil {
+			t.Fatal(err)
+		}
+		var c diam.Conn
+		select {
+		case c = <-conns:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for disconnect")
+		}
+		select {
+		case <-c.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for the connection to close")
+		}
+		ferr, ok := c.Err().(*diam.FloodError)
+		if !ok {
+			t.Fatalf("Unexpected error: %v", c.Err())
+		}
+		if ferr.UnknownCommand != test.unknown || ferr.Count != 3 {
+			t.Fatalf("Unexpected error: %#v", ferr)
+		}
+	}
+}
+
+func TestServer_MaxAVPs(t *testing.T) {
+	srv := diamtest.NewUnstartedServer(diam.NewServeMux(), nil)
+	srv.Config.MaxAVPs = 5
+	srv.Config.FloodGuard = &diam.FloodGuard{}
+	srv.Start()
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CEA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	// sendCER adds 7 AVPs.
+	if _, err = sendCER(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.UnableToComply {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.UnableToComply, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CEA")
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"errors"
+
+	"golang.org/x/net/context"
+)
+
+// ErrTooManyOutstanding is the error of the *WriteError returned for
+// requests written to connections that reached the MaxOutstanding of
+// their FlowControl.
+var ErrTooManyOutstanding = errors.New("diam: too many outstanding requests")
+
+// FlowControl limits the requests written to each connection that are
+// waiting for their answer, providing backpressure to applications
+// instead of an unbounded growth of outstanding requests, e.g. when
+// the peer slows down.
+//
+// Requests are matched to their answers by Hop-by-Hop Identifier, see
+// ConnStats.Outstanding. Requests never answered count until the
+// connection is closed. FlowControl does not apply to connections with
+// a Codec other than the default.
+type FlowControl struct {
+	// MaxOutstanding is the maximum number of outstanding requests
+	// per connection. Zero means no limit.
+	MaxOutstanding int
+
+	// Block makes writes of requests wait until the connection is
+	// below MaxOutstanding, the context of WriteToContext is done or
+	// the connection is closed. By default, writes fail immediately
+	// with ErrTooManyOutstanding.
+	Block bool
+}
+
+// acquire reserves the slots of the requests in b, waiting for them
+// according to the FlowControl of the server. The slots must be
+// released with release after the write.
+func (c *conn) acquire(ctx context.Context, b []byte) (int, error) {
+	fc := c.server.FlowControl
+	if fc == nil || fc.MaxOutstanding <= 0 || c.server.Codec != nil {
+		return 0, nil
+	}
+	n := countRequests(b)
+	if n == 0 {
+		return 0, nil
+	}
+	for {
+		c.statsMu.Lock()
+		if len(c.pending)+c.reserved+n <= fc.MaxOutstanding ||
+			(n > fc.MaxOutstanding && len(c.pending)+c.reserved == 0) {
+			// Batches larger than the limit are written alone.
+			c.reserved += n
+			c.statsMu.Unlock()
+			return n, nil
+		}
+		if !fc.Block {
+			c.statsMu.Unlock()
+			return 0, &WriteError{Len: len(b), Err: ErrTooManyOutstanding}
+		}
+		if c.answered == nil {
+			c.answered = make(chan struct{})
+		}
+		answered := c.answered
+		c.statsMu.Unlock()
+		select {
+		case <-answered:
+		case <-ctx.Done():
+			return 0, &WriteError{Len: len(b), Err: ctx.Err()}
+		case <-c.done:
+			return 0, &WriteError{Len: len(b), Closed: true, Err: ErrConnClosed}
+		}
+	}
+}
+
+// release releases n slots reserved by acquire.
+func (c *conn) release(n int) {
+	if n == 0 {
+		return
+	}
+	c.statsMu.Lock()
+	c.reserved -= n
+	c.wakeWriters()
+	c.statsMu.Unlock()
+}
+
+// wakeWriters wakes the writers waiting for slots. Must be called with
+// statsMu held.
+func (c *conn) wakeWriters() {
+	if c.answered != nil {
+		close(c.answered)
+		c.answered = nil
+	}
+}
+
+// countRequests returns the number of requests in the serialized
+// messages in b.
+func countRequests(b []byte) int {
+	n := 0
+	for len(b) >= HeaderLength {
+		l := int(uint24to32(b[1:4]))
+		if l < HeaderLength || l > len(b) {
+			break
+		}
+		if b[4]&RequestFlag != 0 {
+			n++
+		}
+		b = b[l:]
+	}
+	return n
+}
@@ -0,0 +1,114 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+// flowServer returns a server that answers CCRs when signaled on the
+// returned channel.
+func flowServer(t *testing.T) (*diamtest.Server, chan struct{}) {
+	answer := make(chan struct{}, 10)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		<-answer
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	return diamtest.NewServer(smux, nil), answer
+}
+
+func flowDial(t *testing.T, addr string, fc *diam.FlowControl) (diam.Conn, chan *diam.Message) {
+	mc := make(chan *diam.Message, 10)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := (&diam.Server{Addr: addr, Handler: cmux, FlowControl: fc}).Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cli, mc
+}
+
+func flowRequest(id uint32) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.Header.HopByHopID = id
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	return m
+}
+
+func TestFlowControl(t *testing.T) {
+	srv, answer := flowServer(t)
+	defer srv.Close()
+	cli, mc := flowDial(t, srv.Addr, &diam.FlowControl{MaxOutstanding: 2})
+	defer cli.Close()
+	for i := uint32(1); i <= 2; i++ {
+		if _, err := flowRequest(i).WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := flowRequest(3).WriteTo(cli)
+	if werr, ok := err.(*diam.WriteError); !ok || werr.Err != diam.ErrTooManyOutstanding || !werr.Temporary() {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Answers are not limited.
+	answer <- struct{}{}
+	select {
+	case <-mc:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	if _, err = flowRequest(3).WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	if n := cli.Stats().Outstanding; n != 2 {
+		t.Fatalf("Unexpected outstanding requests. Want 2, have %d", n)
+	}
+	close(answer)
+}
+
+func TestFlowControl_Block(t *testing.T) {
+	srv, answer := flowServer(t)
+	defer srv.Close()
+	cli, mc := flowDial(t, srv.Addr, &diam.FlowControl{MaxOutstanding: 1, Block: true})
+	defer cli.Close()
+	if _, err := flowRequest(1).WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := flowRequest(2).WriteTo(cli)
+		errc <- err
+	}()
+	select {
+	case err := <-errc:
+		t.Fatalf("Request was not blocked: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	answer <- struct{}{}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Request still blocked after answer")
+	}
+	answer <- struct{}{}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-mc:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for CCA")
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package gba provides helpers for the 3GPP Generic Bootstrapping
+// Architecture (TS 33.220): the Zh interface between the BSF and the
+// HSS, and the Zn interface between a NAF and the BSF (TS 29.109).
+//
+// The messages of both interfaces are built from Go structs, and parsed
+// back from received messages. A BSF fetches an authentication vector
+// for the UE from the HSS:
+//
+//	req := &gba.MultimediaAuthRequest{
+//		Header: gba.Header{
+//			SessionID:        "bsf.example.com;1",
+//			OriginHost:       "bsf.example.com",
+//			OriginRealm:      "example.com",
+//			DestinationRealm: "example.com",
+//		},
+//		IMPI: "user@ims.example.com",
+//	}
+//	c.Write(req.Message(dict.Default))
+//
+// And serves the keys of bootstrapped UEs to NAFs:
+//
+//	bir, err := gba.ParseBootstrappingInfoRequest(m)
+//	...
+//	ks := gba.Ks(vector.CK, vector.IK)
+//	nafID := append([]byte(bir.NAFHostname), uaProtocol...)
+//	bia := &gba.BootstrappingInfoAnswer{
+//		Result:        gba.Result{...ResultCode: diam.Success},
+//		MEKeyMaterial: gba.KsNAF(ks, vector.RAND, impi, nafID),
+//	}
+//	bia.Answer(m).WriteTo(c)
+//
+// The Zh and Zn dictionaries are part of dict.Default.
+package gba
@@ -0,0 +1,224 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gba
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/internal/threegpp"
+)
+
+// Application ids of the Zh and Zn interfaces.
+const (
+	ZhApplicationID = 16777221
+	ZnApplicationID = 16777220
+)
+
+// VendorID is the vendor id of 3GPP, the vendor of the GBA AVPs.
+const VendorID = threegpp.VendorID
+
+// MultimediaAuth is the command code of the Zh Multimedia-Auth
+// messages. The diam.MultimediaAuth constant is the one of SIP
+// (RFC 4740).
+const MultimediaAuth = 303
+
+// AKA is the SIP-Authentication-Scheme of GBA.
+const AKA = "Digest-AKAv1-MD5"
+
+// Codes of the 3GPP AVPs of Zh that have the name of a SIP (RFC 4740)
+// AVP, and therefore no constant in package avp.
+const (
+	sipNumberAuthItems      = 607
+	sipAuthenticationScheme = 608
+	sipAuthenticate         = 609
+	sipAuthorization        = 610
+	sipAuthDataItem         = 612
+	sipItemNumber           = 613
+)
+
+// Flags of the 3GPP AVPs.
+const (
+	flagVM = avp.Vbit | avp.Mbit
+	flagV  = avp.Vbit
+)
+
+// Header holds the AVPs common to the requests of Zh and Zn.
+type Header struct {
+	SessionID        string
+	OriginHost       datatype.DiameterIdentity
+	OriginRealm      datatype.DiameterIdentity
+	DestinationRealm datatype.DiameterIdentity
+	DestinationHost  datatype.DiameterIdentity // Optional
+}
+
+// request returns a new request of the application appid, with the
+// AVPs of h.
+func (h *Header) request(cmd, appid uint32, d *dict.Parser) *diam.Message {
+	m := diam.NewRequest(cmd, appid, d)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(h.SessionID))
+	m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(VendorID)),
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(appid)),
+		},
+	})
+	m.NewAVP(avp.AuthSessionState, avp.Mbit, 0, datatype.Enumerated(1)) // NO_STATE_MAINTAINED
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, h.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, h.OriginRealm)
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, h.DestinationRealm)
+	if h.DestinationHost != "" {
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, h.DestinationHost)
+	}
+	return m
+}
+
+// parse sets the field of h for the base AVP a, and returns false if
+// a is not one of them.
+func (h *Header) parse(a *diam.AVP) (bool, error) {
+	var err error
+	switch a.Code {
+	case avp.SessionID:
+		h.SessionID, err = str(a)
+	case avp.OriginHost:
+		h.OriginHost, err = identity(a)
+	case avp.OriginRealm:
+		h.OriginRealm, err = identity(a)
+	case avp.DestinationRealm:
+		h.DestinationRealm, err = identity(a)
+	case avp.DestinationHost:
+		h.DestinationHost, err = identity(a)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// Result holds the AVPs common to the answers of Zh and Zn.
+//
+// Either ResultCode or ExperimentalResultCode is sent, the latter with
+// the 3GPP vendor id, e.g. 5001 (DIAMETER_ERROR_USER_UNKNOWN).
+type Result = threegpp.Result
+
+// parseResult is threegpp.Parse, with the errors of this package.
+func parseResult(r *Result, a *diam.AVP) (bool, error) {
+	ok, err := threegpp.Parse(r, a)
+	if err != nil {
+		err = fmt.Errorf("gba: %w", err)
+	}
+	return ok, err
+}
+
+// checkMessage returns an error if m is not a message of the command
+// cmd of the application appid.
+func checkMessage(m *diam.Message, cmd, appid uint32, request bool) error {
+	if m.Header.CommandCode != cmd || m.Header.ApplicationID != appid {
+		return fmt.Errorf("gba: unexpected message %d of application %d",
+			m.Header.CommandCode, m.Header.ApplicationID)
+	}
+	if (m.Header.CommandFlags&diam.RequestFlag != 0) != request {
+		return fmt.Errorf("gba: unexpected Request flag in message %d",
+			m.Header.CommandCode)
+	}
+	return nil
+}
+
+// tgpp returns a new 3GPP AVP.
+func tgpp(code uint32, flags uint8, data datatype.Type) *diam.AVP {
+	return diam.NewAVP(code, flags, VendorID, data)
+}
+
+// avps returns the AVPs of the grouped AVP a, which must have the
+// given code.
+func avps(a *diam.AVP, code uint32) ([]*diam.AVP, error) {
+	if a.Code != code {
+		return nil, fmt.Errorf("gba: unexpected AVP code %d, want %d", a.Code, code)
+	}
+	g, ok := a.Data.(*diam.GroupedAVP)
+	if !ok {
+		return nil, fmt.Errorf("gba: AVP %d is not grouped: %T", a.Code, a.Data)
+	}
+	return g.AVP, nil
+}
+
+func typeError(a *diam.AVP) error {
+	return fmt.Errorf("gba: unexpected data type of AVP %d: %T", a.Code, a.Data)
+}
+
+func enumerated(a *diam.AVP) (int32, error) {
+	if v, ok := a.Data.(datatype.Enumerated); ok {
+		return int32(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func identity(a *diam.AVP) (datatype.DiameterIdentity, error) {
+	if v, ok := a.Data.(datatype.DiameterIdentity); ok {
+		return v, nil
+	}
+	return "", typeError(a)
+}
+
+func octets(a *diam.AVP) ([]byte, error) {
+	s, err := str(a)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func str(a *diam.AVP) (string, error) {
+	switch v := a.Data.(type) {
+	case datatype.OctetString:
+		return string(v), nil
+	case datatype.UTF8String:
+		return string(v), nil
+	}
+	return "", typeError(a)
+}
+
+func timestamp(a *diam.AVP) (time.Time, error) {
+	if v, ok := a.Data.(datatype.Time); ok {
+		return time.Time(v), nil
+	}
+	return time.Time{}, typeError(a)
+}
+
+// BTID returns the Bootstrapping Transaction Identifier of a UE
+// bootstrapped with the authentication vector of challenge rand, by the
+// BSF of the domain bsf: base64(RAND)@bsf (TS 33.220 section 4.5.2).
+func BTID(rand []byte, bsf string) string {
+	return base64.StdEncoding.EncodeToString(rand) + "@" + bsf
+}
+
+// Ks returns the key Ks = CK || IK shared by the UE and the BSF.
+func Ks(ck, ik []byte) []byte {
+	ks := make([]byte, 0, len(ck)+len(ik))
+	return append(append(ks, ck...), ik...)
+}
+
+// KsNAF returns the key Ks_NAF derived from the key ks for the UE of
+// private identity impi, bootstrapped with the challenge rand, and the
+// NAF nafID (TS 33.220 annex B). The NAF_Id is the FQDN of the NAF
+// followed by its Ua security protocol identifier.
+func KsNAF(ks, rand []byte, impi string, nafID []byte) []byte {
+	mac := hmac.New(sha256.New, ks)
+	mac.Write([]byte{0x01}) // FC
+	for _, p := range [][]byte{[]byte("gba-me"), rand, []byte(impi), nafID} {
+		var l [2]byte
+		binary.BigEndian.PutUint16(l[:], uint16(len(p)))
+		mac.Write(p)
+		mac.Write(l[:])
+	}
+	return mac.Sum(nil)
+}
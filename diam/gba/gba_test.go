@@ -0,0 +1,142 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gba
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func roundTrip(t *testing.T, m *diam.Message) *diam.Message {
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = diam.ReadMessage(bytes.NewReader(b), dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMultimediaAuth(t *testing.T) {
+	req := &MultimediaAuthRequest{
+		Header: Header{
+			SessionID:        "bsf;1",
+			OriginHost:       "bsf.example.com",
+			OriginRealm:      "example.com",
+			DestinationRealm: "example.com",
+		},
+		IMPI:          "user@ims.example.com",
+		Resync:        []byte("0123456789abcdefAUTS"),
+		GUSSTimestamp: time.Unix(1420070400, 0),
+	}
+	m := roundTrip(t, req.Message(dict.Default))
+	r, err := ParseMultimediaAuthRequest(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, req) {
+		t.Fatalf("Unexpected MAR. Want %#v, have %#v", req, r)
+	}
+
+	ans := &MultimediaAuthAnswer{
+		Result: Result{
+			SessionID:   "bsf;1",
+			OriginHost:  "hss.example.com",
+			OriginRealm: "example.com",
+			ResultCode:  diam.Success,
+		},
+		IMPI: "user@ims.example.com",
+		Vector: &AuthVector{
+			RAND: []byte("0123456789abcdef"),
+			AUTN: []byte("fedcba9876543210"),
+			XRES: []byte("xres"),
+			CK:   []byte("ck"),
+			IK:   []byte("ik"),
+		},
+		GUSS: []byte("<guss/>"),
+	}
+	a := roundTrip(t, ans.Answer(m))
+	if a.Header.HopByHopID != m.Header.HopByHopID || a.Header.CommandFlags&diam.RequestFlag != 0 {
+		t.Fatalf("Unexpected MAA header: %s", a.Header)
+	}
+	r2, err := ParseMultimediaAuthAnswer(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r2, ans) {
+		t.Fatalf("Unexpected MAA. Want %#v, have %#v", ans, r2)
+	}
+	if _, err = ParseMultimediaAuthRequest(a); err == nil {
+		t.Fatal("Unexpected MAR parsed from MAA")
+	}
+}
+
+func TestBootstrappingInfo(t *testing.T) {
+	req := &BootstrappingInfoRequest{
+		Header: Header{
+			SessionID:        "naf;1",
+			OriginHost:       "naf.example.com",
+			OriginRealm:      "example.com",
+			DestinationRealm: "example.com",
+			DestinationHost:  "bsf.example.com",
+		},
+		BTID:        BTID([]byte("0123456789abcdef"), "bsf.example.com"),
+		NAFHostname: "naf.example.com",
+		GBAUAware:   true,
+	}
+	m := roundTrip(t, req.Message(dict.Default))
+	r, err := ParseBootstrappingInfoRequest(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, req) {
+		t.Fatalf("Unexpected BIR. Want %#v, have %#v", req, r)
+	}
+
+	typ := GBA3G
+	ans := &BootstrappingInfoAnswer{
+		Result: Result{
+			SessionID:              "naf;1",
+			OriginHost:             "bsf.example.com",
+			OriginRealm:            "example.com",
+			ExperimentalResultCode: 5401, // DIAMETER_ERROR_IMPI_UNKNOWN
+		},
+		IMPI:          "user@ims.example.com",
+		MEKeyMaterial: []byte("ks_naf"),
+		KeyExpiry:     time.Unix(1420074000, 0),
+		CreationTime:  time.Unix(1420070400, 0),
+		Type:          &typ,
+	}
+	r2, err := ParseBootstrappingInfoAnswer(roundTrip(t, ans.Answer(m)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r2, ans) {
+		t.Fatalf("Unexpected BIA. Want %#v, have %#v", ans, r2)
+	}
+}
+
+func TestKsNAF(t *testing.T) {
+	ks := Ks(bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 16))
+	rand := []byte("0123456789abcdef")
+	a := KsNAF(ks, rand, "user@ims.example.com", []byte("naf1.example.com\x01\x00\x00\x00\x02"))
+	b := KsNAF(ks, rand, "user@ims.example.com", []byte("naf2.example.com\x01\x00\x00\x00\x02"))
+	if len(a) != 32 {
+		t.Fatalf("Unexpected Ks_NAF length. Want 32, have %d", len(a))
+	}
+	if bytes.Equal(a, b) {
+		t.Fatal("Unexpected Ks_NAF shared by two NAFs")
+	}
+	if want := "MDEyMzQ1Njc4OWFiY2RlZg==@bsf"; BTID(rand, "bsf") != want {
+		t.Fatalf("Unexpected BTID. Want %s, have %s", want, BTID(rand, "bsf"))
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gba
+
+import (
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/internal/threegpp"
+)
+
+// AuthVector is an AKA authentication vector, sent by the HSS in the
+// SIP-Auth-Data-Item AVP of a Zh MAA.
+type AuthVector struct {
+	RAND []byte
+	AUTN []byte
+	XRES []byte
+	CK   []byte
+	IK   []byte
+}
+
+// AVP returns the SIP-Auth-Data-Item AVP of v: the SIP-Authenticate
+// AVP carries RAND || AUTN, and the SIP-Authorization AVP carries XRES.
+func (v *AuthVector) AVP() *diam.AVP {
+	challenge := make([]byte, 0, len(v.RAND)+len(v.AUTN))
+	challenge = append(append(challenge, v.RAND...), v.AUTN...)
+	return tgpp(sipAuthDataItem, flagVM, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			tgpp(sipAuthenticationScheme, flagVM, datatype.UTF8String(AKA)),
+			tgpp(sipAuthenticate, flagVM, datatype.OctetString(challenge)),
+			tgpp(sipAuthorization, flagVM, datatype.OctetString(v.XRES)),
+			tgpp(avp.ConfidentialityKey, flagVM, datatype.OctetString(v.CK)),
+			tgpp(avp.IntegrityKey, flagVM, datatype.OctetString(v.IK)),
+		},
+	})
+}
+
+// ParseAuthVector parses the SIP-Auth-Data-Item AVP a of a Zh MAA.
+func ParseAuthVector(a *diam.AVP) (*AuthVector, error) {
+	l, err := avps(a, sipAuthDataItem)
+	if err != nil {
+		return nil, err
+	}
+	v := &AuthVector{}
+	var challenge []byte
+	for _, a := range l {
+		if a.VendorID != VendorID {
+			continue
+		}
+		switch a.Code {
+		case sipAuthenticate:
+			challenge, err = octets(a)
+		case sipAuthorization:
+			v.XRES, err = octets(a)
+		case avp.ConfidentialityKey:
+			v.CK, err = octets(a)
+		case avp.IntegrityKey:
+			v.IK, err = octets(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	// RAND is 16 octets, AUTN follows (TS 33.102).
+	if len(challenge) > 16 {
+		v.RAND, v.AUTN = challenge[:16], challenge[16:]
+	} else {
+		v.RAND = challenge
+	}
+	return v, nil
+}
+
+// MultimediaAuthRequest is the Zh MAR, sent by the BSF to the HSS to
+// fetch an authentication vector and the GBA user security settings
+// (GUSS) of a UE.
+type MultimediaAuthRequest struct {
+	Header
+	IMPI string // User-Name: IMS private identity of the UE
+
+	// Resync is RAND || AUTS, sent by the BSF when the UE failed
+	// to authenticate the network with the previous vector.
+	Resync []byte
+
+	// GUSSTimestamp is the time of the GUSS the BSF has, the HSS
+	// only sends the GUSS when it changed since.
+	GUSSTimestamp time.Time
+}
+
+// Message returns the MAR message of r.
+func (r *MultimediaAuthRequest) Message(d *dict.Parser) *diam.Message {
+	m := r.request(MultimediaAuth, ZhApplicationID, d)
+	m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String(r.IMPI))
+	m.AddAVP(tgpp(sipNumberAuthItems, flagVM, datatype.Unsigned32(1)))
+	item := []*diam.AVP{
+		tgpp(sipAuthenticationScheme, flagVM, datatype.UTF8String(AKA)),
+	}
+	if len(r.Resync) > 0 {
+		item = append(item, tgpp(sipAuthorization, flagVM, datatype.OctetString(r.Resync)))
+	}
+	m.AddAVP(tgpp(sipAuthDataItem, flagVM, &diam.GroupedAVP{AVP: item}))
+	if !r.GUSSTimestamp.IsZero() {
+		m.AddAVP(tgpp(avp.GUSSTimestamp, flagV, datatype.Time(r.GUSSTimestamp)))
+	}
+	return m
+}
+
+// ParseMultimediaAuthRequest parses the Zh MAR m.
+func ParseMultimediaAuthRequest(m *diam.Message) (*MultimediaAuthRequest, error) {
+	if err := checkMessage(m, MultimediaAuth, ZhApplicationID, true); err != nil {
+		return nil, err
+	}
+	r := &MultimediaAuthRequest{}
+	for _, a := range m.AVP {
+		ok, err := r.Header.parse(a)
+		switch {
+		case ok:
+		case a.Code == avp.UserName:
+			r.IMPI, err = str(a)
+		case a.Code == sipAuthDataItem && a.VendorID == VendorID:
+			var l []*diam.AVP
+			if l, err = avps(a, sipAuthDataItem); err != nil {
+				break
+			}
+			for _, c := range l {
+				if c.Code == sipAuthorization && c.VendorID == VendorID {
+					r.Resync, err = octets(c)
+				}
+			}
+		case a.Code == avp.GUSSTimestamp && a.VendorID == VendorID:
+			r.GUSSTimestamp, err = timestamp(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// MultimediaAuthAnswer is the Zh MAA, sent by the HSS to the BSF.
+type MultimediaAuthAnswer struct {
+	Result
+	IMPI   string
+	Vector *AuthVector
+	GUSS   []byte // GBA-UserSecSettings, optional
+}
+
+// Answer returns the MAA message of a, in answer to the MAR req.
+func (a *MultimediaAuthAnswer) Answer(req *diam.Message) *diam.Message {
+	m := threegpp.Answer(&a.Result, req)
+	if a.IMPI != "" {
+		m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String(a.IMPI))
+	}
+	if a.Vector != nil {
+		m.AddAVP(tgpp(sipNumberAuthItems, flagVM, datatype.Unsigned32(1)))
+		m.AddAVP(a.Vector.AVP())
+	}
+	if len(a.GUSS) > 0 {
+		m.AddAVP(tgpp(avp.GBAUserSecSettings, flagVM, datatype.OctetString(a.GUSS)))
+	}
+	return m
+}
+
+// ParseMultimediaAuthAnswer parses the Zh MAA m.
+func ParseMultimediaAuthAnswer(m *diam.Message) (*MultimediaAuthAnswer, error) {
+	if err := checkMessage(m, MultimediaAuth, ZhApplicationID, false); err != nil {
+		return nil, err
+	}
+	r := &MultimediaAuthAnswer{}
+	for _, a := range m.AVP {
+		ok, err := parseResult(&r.Result, a)
+		switch {
+		case ok:
+		case a.Code == avp.UserName:
+			r.IMPI, err = str(a)
+		case a.Code == sipAuthDataItem && a.VendorID == VendorID:
+			r.Vector, err = ParseAuthVector(a)
+		case a.Code == avp.GBAUserSecSettings && a.VendorID == VendorID:
+			r.GUSS, err = octets(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,154 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gba
+
+import (
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/internal/threegpp"
+)
+
+// Type is the value of the GBA-Type AVP.
+type Type int32
+
+// GBA-Type values.
+const (
+	GBA3G Type = 0
+	GBA2G Type = 1
+)
+
+// BootstrappingInfoRequest is the Zn BIR, sent by a NAF to the BSF to
+// fetch the key of the UE bootstrapped with the transaction BTID.
+type BootstrappingInfoRequest struct {
+	Header
+	BTID                 string
+	NAFHostname          string
+	GAAServiceIdentifier []byte // Optional
+	GBAUAware            bool   // The NAF supports GBA_U
+}
+
+// Message returns the BIR message of r.
+func (r *BootstrappingInfoRequest) Message(d *dict.Parser) *diam.Message {
+	m := r.request(diam.BootstrappingInfo, ZnApplicationID, d)
+	m.AddAVP(tgpp(avp.TransactionIdentifier, flagVM, datatype.OctetString(r.BTID)))
+	m.AddAVP(tgpp(avp.NAFHostname, flagVM, datatype.OctetString(r.NAFHostname)))
+	if len(r.GAAServiceIdentifier) > 0 {
+		m.AddAVP(tgpp(avp.GAAServiceIdentifier, flagVM, datatype.OctetString(r.GAAServiceIdentifier)))
+	}
+	if r.GBAUAware {
+		m.AddAVP(tgpp(avp.GBA_UAwarenessIndicator, flagVM, datatype.Enumerated(1)))
+	}
+	return m
+}
+
+// ParseBootstrappingInfoRequest parses the Zn BIR m.
+func ParseBootstrappingInfoRequest(m *diam.Message) (*BootstrappingInfoRequest, error) {
+	if err := checkMessage(m, diam.BootstrappingInfo, ZnApplicationID, true); err != nil {
+		return nil, err
+	}
+	r := &BootstrappingInfoRequest{}
+	for _, a := range m.AVP {
+		ok, err := r.Header.parse(a)
+		switch {
+		case ok, a.VendorID != VendorID:
+		case a.Code == avp.TransactionIdentifier:
+			r.BTID, err = str(a)
+		case a.Code == avp.NAFHostname:
+			r.NAFHostname, err = str(a)
+		case a.Code == avp.GAAServiceIdentifier:
+			r.GAAServiceIdentifier, err = octets(a)
+		case a.Code == avp.GBA_UAwarenessIndicator:
+			var v int32
+			v, err = enumerated(a)
+			r.GBAUAware = v == 1
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// BootstrappingInfoAnswer is the Zn BIA, sent by the BSF to a NAF.
+//
+// Zero fields are not sent.
+type BootstrappingInfoAnswer struct {
+	Result
+	IMPI            string // User-Name
+	MEKeyMaterial   []byte // Ks_NAF, or Ks_ext_NAF with GBA_U
+	UICCKeyMaterial []byte // Ks_int_NAF, with GBA_U
+	KeyExpiry       time.Time
+	CreationTime    time.Time // Bootstrapping time
+	GUSS            []byte    // GBA-UserSecSettings
+	Type            *Type
+}
+
+// Answer returns the BIA message of a, in answer to the BIR req.
+func (a *BootstrappingInfoAnswer) Answer(req *diam.Message) *diam.Message {
+	m := threegpp.Answer(&a.Result, req)
+	if a.IMPI != "" {
+		m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String(a.IMPI))
+	}
+	if len(a.MEKeyMaterial) > 0 {
+		m.AddAVP(tgpp(avp.MEKeyMaterial, flagVM, datatype.OctetString(a.MEKeyMaterial)))
+	}
+	if len(a.UICCKeyMaterial) > 0 {
+		m.AddAVP(tgpp(avp.UICCKeyMaterial, flagVM, datatype.OctetString(a.UICCKeyMaterial)))
+	}
+	if !a.KeyExpiry.IsZero() {
+		m.AddAVP(tgpp(avp.KeyExpiryTime, flagVM, datatype.Time(a.KeyExpiry)))
+	}
+	if !a.CreationTime.IsZero() {
+		m.AddAVP(tgpp(avp.BootstrapInfoCreationTime, flagV, datatype.Time(a.CreationTime)))
+	}
+	if len(a.GUSS) > 0 {
+		m.AddAVP(tgpp(avp.GBAUserSecSettings, flagVM, datatype.OctetString(a.GUSS)))
+	}
+	if a.Type != nil {
+		m.AddAVP(tgpp(avp.GBAType, flagV, datatype.Enumerated(*a.Type)))
+	}
+	return m
+}
+
+// ParseBootstrappingInfoAnswer parses the Zn BIA m.
+func ParseBootstrappingInfoAnswer(m *diam.Message) (*BootstrappingInfoAnswer, error) {
+	if err := checkMessage(m, diam.BootstrappingInfo, ZnApplicationID, false); err != nil {
+		return nil, err
+	}
+	r := &BootstrappingInfoAnswer{}
+	for _, a := range m.AVP {
+		ok, err := parseResult(&r.Result, a)
+		switch {
+		case ok:
+		case a.Code == avp.UserName:
+			r.IMPI, err = str(a)
+		case a.VendorID != VendorID:
+		case a.Code == avp.MEKeyMaterial:
+			r.MEKeyMaterial, err = octets(a)
+		case a.Code == avp.UICCKeyMaterial:
+			r.UICCKeyMaterial, err = octets(a)
+		case a.Code == avp.KeyExpiryTime:
+			r.KeyExpiry, err = timestamp(a)
+		case a.Code == avp.BootstrapInfoCreationTime:
+			r.CreationTime, err = timestamp(a)
+		case a.Code == avp.GBAUserSecSettings:
+			r.GUSS, err = octets(a)
+		case a.Code == avp.GBAType:
+			var v int32
+			if v, err = enumerated(a); err == nil {
+				t := Type(v)
+				r.Type = &t
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
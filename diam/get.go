@@ -0,0 +1,73 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package diam
+
+import (
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// Get returns the data of the AVP of the Message with the given code
+// and vendor id, searched like FindAVP, as the data type T. It returns
+// an error if the AVP is not in the Message or its data is not a T.
+// The code can be either the AVP code (int, uint32) or name (string).
+//
+// Example:
+//
+//	rg, err := diam.Get[datatype.Unsigned32](m, avp.RatingGroup, 0)
+//	mscc, err := diam.Get[*diam.GroupedAVP](m, avp.MultipleServicesCreditControl, 0)
+func Get[T datatype.Type](m *Message, code interface{}, vendorID uint32) (T, error) {
+	a, err := m.FindAVP(code, vendorID)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return dataAs[T](a)
+}
+
+// Lookup is like Get, and returns false instead of an error.
+//
+// Example:
+//
+//	if host, ok := diam.Lookup[datatype.DiameterIdentity](m, avp.OriginHost, 0); ok {
+//		...
+//	}
+func Lookup[T datatype.Type](m *Message, code interface{}, vendorID uint32) (T, bool) {
+	v, err := Get[T](m, code, vendorID)
+	return v, err == nil
+}
+
+// GetAll returns the data of every top level AVP of the Message with
+// the given code and vendor id, in order, as the data type T, like
+// FindAllAVP. It returns an error if the data of any of them is not a
+// T.
+func GetAll[T datatype.Type](m *Message, code interface{}, vendorID uint32) ([]T, error) {
+	avps, err := m.FindAllAVP(code, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]T, 0, len(avps))
+	for _, a := range avps {
+		v, err := dataAs[T](a)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// dataAs returns the data of the AVP a as the data type T.
+func dataAs[T datatype.Type](a *AVP) (T, error) {
+	v, ok := a.Data.(T)
+	if !ok {
+		return v, fmt.Errorf("data of AVP %d (vendor %d) is %T, not %T", a.Code, a.VendorID, a.Data, v)
+	}
+	return v, nil
+}
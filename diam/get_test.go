@@ -0,0 +1,49 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build go1.18
+// +build go1.18
+
+package diam_test
+
+import (
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestGet(t *testing.T) {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	for _, rg := range []uint32{1, 2} {
+		m.NewAVP(avp.MultipleServicesCreditControl, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				diam.NewAVP(avp.RatingGroup, avp.Mbit, 0, datatype.Unsigned32(rg)),
+			},
+		})
+	}
+	host, err := diam.Get[datatype.DiameterIdentity](m, avp.OriginHost, 0)
+	if err != nil || host != "cli" {
+		t.Fatalf("Unexpected Origin-Host: %q, %v", host, err)
+	}
+	// Embedded AVPs are searched like FindAVP.
+	if rg, err := diam.Get[datatype.Unsigned32](m, avp.RatingGroup, 0); err != nil || rg != 1 {
+		t.Fatalf("Unexpected Rating-Group: %d, %v", rg, err)
+	}
+	if _, err = diam.Get[datatype.UTF8String](m, avp.OriginHost, 0); err == nil {
+		t.Fatal("Unexpected nil error for the wrong data type")
+	}
+	if _, ok := diam.Lookup[datatype.UTF8String](m, avp.SessionID, 0); ok {
+		t.Fatal("Unexpected Session-Id found")
+	}
+	mscc, err := diam.GetAll[*diam.GroupedAVP](m, avp.MultipleServicesCreditControl, 0)
+	if err != nil || len(mscc) != 2 {
+		t.Fatalf("Unexpected Multiple-Services-Credit-Control: %v, %v", mscc, err)
+	}
+	if _, err = diam.GetAll[datatype.Unsigned32](m, avp.MultipleServicesCreditControl, 0); err == nil {
+		t.Fatal("Unexpected nil error for the wrong data type")
+	}
+}
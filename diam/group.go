@@ -23,16 +23,28 @@ type GroupedAVP struct {
 
 // DecodeGrouped decodes a Grouped AVP from a datatype.Grouped (byte array).
 func DecodeGrouped(data datatype.Grouped, application uint32, dictionary *dict.Parser) (*GroupedAVP, error) {
+	return decodeGrouped(data, application, dictionary, false, nil)
+}
+
+// decodeGrouped is like DecodeGrouped, in relay mode when relay is true.
+// The embedded AVPs are allocated and counted by the decoder d, if not
+// nil.
+func decodeGrouped(data datatype.Grouped, application uint32, dictionary *dict.Parser, relay bool, d *avpDecoder) (*GroupedAVP, error) {
 	g := &GroupedAVP{}
 	b := []byte(data)
+	ar := d.allocator()
+	start := ar.mark()
 	for n := 0; n < len(b); {
-		avp, err := DecodeAVP(b[n:], application, dictionary)
+		avp, err := decodeAVP(b[n:], application, dictionary, relay, d)
 		if err != nil {
 			return nil, err
 		}
-		g.AVP = append(g.AVP, avp)
+		g.AVP = ar.push(g.AVP, avp)
 		n += avp.Len()
 	}
+	if ar != nil {
+		g.AVP = ar.list(start)
+	}
 	// TODO: handle nested groups?
 	return g, nil
 }
@@ -0,0 +1,128 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gx
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// ApplicationID is the Diameter application id of Gx.
+const ApplicationID = 16777238
+
+// VendorID is the vendor id of 3GPP, the vendor of the Gx AVPs.
+const VendorID = 10415
+
+// Flags of the 3GPP AVPs.
+const (
+	flagVM = avp.Vbit | avp.Mbit
+	flagV  = avp.Vbit
+)
+
+// tgpp returns a new 3GPP AVP.
+func tgpp(code uint32, flags uint8, data datatype.Type) *diam.AVP {
+	return diam.NewAVP(code, flags, VendorID, data)
+}
+
+// group returns a new grouped AVP.
+func group(code uint32, flags uint8, vendor uint32, avps []*diam.AVP) *diam.AVP {
+	return diam.NewAVP(code, flags, vendor, &diam.GroupedAVP{AVP: avps})
+}
+
+// avps returns the AVPs of the grouped AVP a, which must have the
+// given code.
+func avps(a *diam.AVP, code uint32) ([]*diam.AVP, error) {
+	if a.Code != code {
+		return nil, fmt.Errorf("gx: unexpected AVP code %d, want %d", a.Code, code)
+	}
+	g, ok := a.Data.(*diam.GroupedAVP)
+	if !ok {
+		return nil, fmt.Errorf("gx: AVP %d is not grouped: %T", a.Code, a.Data)
+	}
+	// Skip AVPs of other vendors.
+	l := make([]*diam.AVP, 0, len(g.AVP))
+	for _, c := range g.AVP {
+		if c.VendorID == 0 || c.VendorID == VendorID {
+			l = append(l, c)
+		}
+	}
+	return l, nil
+}
+
+func typeError(a *diam.AVP) error {
+	return fmt.Errorf("gx: unexpected data type of AVP %d: %T", a.Code, a.Data)
+}
+
+func unsigned32(a *diam.AVP) (uint32, error) {
+	if v, ok := a.Data.(datatype.Unsigned32); ok {
+		return uint32(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func unsigned64(a *diam.AVP) (uint64, error) {
+	if v, ok := a.Data.(datatype.Unsigned64); ok {
+		return uint64(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func enumerated(a *diam.AVP) (int32, error) {
+	if v, ok := a.Data.(datatype.Enumerated); ok {
+		return int32(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func octets(a *diam.AVP) ([]byte, error) {
+	s, err := str(a)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func str(a *diam.AVP) (string, error) {
+	switch v := a.Data.(type) {
+	case datatype.OctetString:
+		return string(v), nil
+	case datatype.UTF8String:
+		return string(v), nil
+	case datatype.IPFilterRule:
+		return string(v), nil
+	}
+	return "", typeError(a)
+}
+
+func timestamp(a *diam.AVP) (time.Time, error) {
+	if v, ok := a.Data.(datatype.Time); ok {
+		return time.Time(v), nil
+	}
+	return time.Time{}, typeError(a)
+}
+
+// enabled returns the value of Enumerated AVPs such as Online and
+// Pre-emption-Capability, that are enabled when set to enable.
+func enabled(a *diam.AVP, enable int32) (*bool, error) {
+	v, err := enumerated(a)
+	if err != nil {
+		return nil, err
+	}
+	b := v == enable
+	return &b, nil
+}
+
+// enable returns the value of Enumerated AVPs such as Online and
+// Pre-emption-Capability for b.
+func enable(b bool, enable int32) datatype.Enumerated {
+	if b {
+		return datatype.Enumerated(enable)
+	}
+	return datatype.Enumerated(1 - enable)
+}
@@ -0,0 +1,33 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package gx provides helpers for the 3GPP Gx application (TS 29.212),
+// used between the PCRF and the PCEF for policy and charging control.
+//
+// The PCC rules installed and removed by the PCRF are built from Go
+// structs, and parsed back from the grouped AVPs of received messages:
+//
+//	rule := &gx.ChargingRuleDefinition{
+//		Name:       "video",
+//		Precedence: 100,
+//		Flows: []*gx.FlowInformation{{
+//			Description: "permit out 17 from any to 10.0.0.1 5000",
+//			Direction:   gx.Downlink,
+//		}},
+//		QoS: &gx.QoSInformation{QCI: 2, GuaranteedBitrateDL: 2000000},
+//	}
+//	cca.AddAVP((&gx.ChargingRuleInstall{
+//		Definitions: []*gx.ChargingRuleDefinition{rule},
+//	}).AVP())
+//
+// On the PCEF:
+//
+//	installs, err := gx.ChargingRuleInstalls(cca)
+//
+// A Monitor tracks the event triggers and usage monitoring keys armed
+// by the PCRF on the sessions of a PCEF, and builds the CCR-U messages
+// that report events and usage once thresholds are reached.
+//
+// The Gx dictionary is part of dict.Default.
+package gx
@@ -0,0 +1,426 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gx
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/session"
+)
+
+// ErrNotArmed is returned by Monitor.Trigger for event triggers not
+// armed by the PCRF.
+var ErrNotArmed = errors.New("gx: event trigger not armed")
+
+// ErrNotMonitored is returned by Monitor.Usage for monitoring keys not
+// armed by the PCRF.
+var ErrNotMonitored = errors.New("gx: monitoring key not armed")
+
+// CC-Request-Type values.
+const (
+	updateRequest      = 2
+	terminationRequest = 3
+)
+
+// Keys of the Monitor state in Session.State.
+const (
+	stateNumber   = "gx.cc-request-number"
+	stateRealm    = "gx.realm"
+	stateTriggers = "gx.event-triggers"
+	stateKey      = "gx.monitoring-key."
+)
+
+// Monitor tracks the event triggers and usage monitoring keys armed by
+// the PCRF on the Gx sessions of a PCEF, and builds the CCR-U messages
+// that report them. Its state is kept in the State of the sessions of
+// a session.Store.
+//
+// The Monitor follows the CCRs sent and the CCAs and RARs received
+// through an egress hook and a handler wrapper:
+//
+//	mon := gx.NewMonitor(store, time.Hour)
+//	mux.HandleEgress(mon.Egress)
+//	mux.Handle("CCA", mon.Handler(handleCCA))
+//	mux.Handle("RAR", mon.Handler(handleRAR))
+//
+// The application reports the usage of monitoring keys, and sends the
+// CCR-U returned once a threshold is reached:
+//
+//	ccr, err := mon.Usage(sid, key, gx.ServiceUnit{TotalOctets: n})
+//	if err == nil && ccr != nil {
+//		ccr.WriteTo(c)
+//	}
+//
+// Updates of a session are serialized by the Monitor, which should
+// be the only one updating sessions of the Store.
+type Monitor struct {
+	// Origin-Host and Origin-Realm of the CCR-U messages, not
+	// added when empty, e.g. when added by another egress hook.
+	OriginHost  datatype.DiameterIdentity
+	OriginRealm datatype.DiameterIdentity
+
+	mu    sync.Mutex
+	store session.Store
+	ttl   time.Duration
+}
+
+// NewMonitor creates and initializes a new Monitor that keeps sessions
+// in the store. Sessions expire after ttl, or never if zero.
+func NewMonitor(store session.Store, ttl time.Duration) *Monitor {
+	return &Monitor{store: store, ttl: ttl}
+}
+
+// Egress is a diam.EgressFunc that records the CC-Request-Number of
+// the Gx CCRs sent, so the CCR-U messages built by the Monitor follow
+// them.
+func (mon *Monitor) Egress(c diam.Conn, m *diam.Message) error {
+	if !isGx(m, diam.CreditControl) || m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return nil
+	}
+	a, err := m.FindAVP(avp.CCRequestNumber, 0)
+	if err != nil {
+		return nil
+	}
+	n, err := unsigned32(a)
+	if err != nil {
+		return nil
+	}
+	mon.update(m, func(s *session.Session) bool {
+		if v, ok := number(s); ok && v >= n {
+			return false
+		}
+		s.State[stateNumber] = strconv.FormatUint(uint64(n), 10)
+		return true
+	})
+	return nil
+}
+
+// Handler returns a diam.Handler that records the event triggers and
+// monitoring keys armed by the CCAs and RARs received before calling
+// h. The state of sessions is removed on the CCA of their termination.
+//
+// Event-Trigger AVPs replace the triggers armed, and NO_EVENT_TRIGGERS
+// disarms them all. Usage-Monitoring-Information AVPs arm monitoring
+// keys with their Granted-Service-Unit, or stop monitoring them.
+func (mon *Monitor) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		switch {
+		case m.Header.CommandFlags&diam.ErrorFlag != 0:
+		case isGx(m, diam.CreditControl) && m.Header.CommandFlags&diam.RequestFlag == 0:
+			mon.arm(m)
+		case isGx(m, diam.ReAuth) && m.Header.CommandFlags&diam.RequestFlag != 0:
+			mon.arm(m)
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+// Triggers returns the event triggers armed on the session with the
+// given id.
+func (mon *Monitor) Triggers(id string) ([]EventTrigger, error) {
+	s, err := mon.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	return triggers(s), nil
+}
+
+// Trigger returns the CCR-U that reports the event t of the session
+// with the given id, or ErrNotArmed if the PCRF did not arm it.
+// Reporting UsageReport, the usage of all monitoring keys is reported,
+// e.g. when requested with Usage-Monitoring-Report.
+func (mon *Monitor) Trigger(id string, t EventTrigger) (*diam.Message, error) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	s, err := mon.get(id)
+	if err != nil {
+		return nil, err
+	}
+	if t != UsageReport && !armed(s, t) {
+		return nil, ErrNotArmed
+	}
+	m := mon.newCCR(s, t)
+	if t == UsageReport {
+		for _, k := range keys(s) {
+			mon.report(s, m, k)
+		}
+	}
+	if err = mon.store.Put(s, mon.ttl); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Usage adds the usage of the monitoring key of the session with the
+// given id. Once the usage reaches the threshold granted by the PCRF,
+// Usage returns the CCR-U that reports it, and the key waits for a new
+// threshold. Otherwise the returned message is nil.
+func (mon *Monitor) Usage(id string, key []byte, used ServiceUnit) (*diam.Message, error) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	s, err := mon.get(id)
+	if err != nil {
+		return nil, err
+	}
+	k := string(key)
+	granted, total, ok := usage(s, k)
+	if !ok {
+		return nil, ErrNotMonitored
+	}
+	total = total.add(used)
+	setUsage(s, k, granted, total)
+	var m *diam.Message
+	if total.Reached(granted) {
+		m = mon.newCCR(s, UsageReport)
+		mon.report(s, m, k)
+	}
+	if err = mon.store.Put(s, mon.ttl); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// report adds the usage of the monitoring key k of the session s to
+// the CCR-U m, and resets it.
+func (mon *Monitor) report(s *session.Session, m *diam.Message, k string) {
+	_, used, _ := usage(s, k)
+	m.AddAVP((&UsageMonitoringInformation{
+		MonitoringKey: []byte(k),
+		Used:          used,
+	}).AVP())
+	setUsage(s, k, ServiceUnit{}, ServiceUnit{})
+}
+
+// newCCR returns a new CCR-U of the session s reporting the event t.
+func (mon *Monitor) newCCR(s *session.Session, t EventTrigger) *diam.Message {
+	n, ok := number(s)
+	if ok {
+		n++
+	}
+	s.State[stateNumber] = strconv.FormatUint(uint64(n), 10)
+	m := diam.NewRequest(diam.CreditControl, ApplicationID, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(s.ID))
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(ApplicationID))
+	if mon.OriginHost != "" {
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, mon.OriginHost)
+	}
+	if mon.OriginRealm != "" {
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, mon.OriginRealm)
+	}
+	if realm := s.State[stateRealm]; realm != "" {
+		m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity(realm))
+	}
+	if s.Peer != "" {
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, s.Peer)
+	}
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(updateRequest))
+	m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(n))
+	m.AddAVP(t.AVP())
+	return m
+}
+
+// arm records the event triggers and monitoring keys of the CCA or
+// RAR m.
+func (mon *Monitor) arm(m *diam.Message) {
+	ts, err := EventTriggers(m)
+	if err != nil {
+		return
+	}
+	umis, err := UsageMonitoringInformations(m)
+	if err != nil {
+		return
+	}
+	if a, err := m.FindAVP(avp.CCRequestType, 0); err == nil {
+		if v, err := enumerated(a); err == nil && v == terminationRequest {
+			if id, ok := sessionID(m); ok {
+				mon.forget(id)
+			}
+			return
+		}
+	}
+	mon.update(m, func(s *session.Session) bool {
+		if m.Header.CommandFlags&diam.RequestFlag == 0 {
+			if a, err := m.FindAVP(avp.OriginRealm, 0); err == nil {
+				if realm, ok := a.Data.(datatype.DiameterIdentity); ok {
+					s.State[stateRealm] = string(realm)
+				}
+			}
+		}
+		if len(ts) > 0 {
+			setTriggers(s, ts)
+		}
+		for _, u := range umis {
+			k := string(u.MonitoringKey)
+			if u.Disabled {
+				delete(s.State, stateKey+hex.EncodeToString(u.MonitoringKey))
+				continue
+			}
+			if u.Granted.IsZero() {
+				continue
+			}
+			_, used, _ := usage(s, k)
+			setUsage(s, k, u.Granted, used)
+		}
+		return true
+	})
+}
+
+// get returns the session with the given id from the Store.
+func (mon *Monitor) get(id string) (*session.Session, error) {
+	s, err := mon.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if s.State == nil {
+		s.State = make(map[string]string)
+	}
+	return s, nil
+}
+
+// forget removes the state of the Monitor from the session with the
+// given id.
+func (mon *Monitor) forget(id string) {
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	s, err := mon.store.Get(id)
+	if err != nil {
+		return
+	}
+	for k := range s.State {
+		if strings.HasPrefix(k, "gx.") {
+			delete(s.State, k)
+		}
+	}
+	mon.store.Put(s, mon.ttl)
+}
+
+// update calls fn with the session of the message m, creating it if
+// needed, and stores it if fn returns true. Store errors are ignored.
+func (mon *Monitor) update(m *diam.Message, fn func(s *session.Session) bool) {
+	id, ok := sessionID(m)
+	if !ok {
+		return
+	}
+	mon.mu.Lock()
+	defer mon.mu.Unlock()
+	s, err := mon.store.Get(id)
+	switch {
+	case err == session.ErrNotFound:
+		s = &session.Session{ID: id, ApplicationID: ApplicationID}
+	case err != nil:
+		return
+	}
+	if s.State == nil {
+		s.State = make(map[string]string)
+	}
+	if fn(s) {
+		mon.store.Put(s, mon.ttl)
+	}
+}
+
+// number returns the last CC-Request-Number of the session s.
+func number(s *session.Session) (uint32, bool) {
+	n, err := strconv.ParseUint(s.State[stateNumber], 10, 32)
+	return uint32(n), err == nil
+}
+
+// triggers returns the event triggers armed on the session s.
+func triggers(s *session.Session) []EventTrigger {
+	var l []EventTrigger
+	for _, f := range strings.Split(s.State[stateTriggers], ",") {
+		if n, err := strconv.Atoi(f); err == nil {
+			l = append(l, EventTrigger(n))
+		}
+	}
+	return l
+}
+
+// setTriggers arms the event triggers ts on the session s.
+func setTriggers(s *session.Session, ts []EventTrigger) {
+	var l []string
+	for _, t := range ts {
+		if t == NoEventTriggers {
+			delete(s.State, stateTriggers)
+			return
+		}
+		l = append(l, strconv.Itoa(int(t)))
+	}
+	s.State[stateTriggers] = strings.Join(l, ",")
+}
+
+// armed returns true if the event trigger t is armed on the session s.
+func armed(s *session.Session, t EventTrigger) bool {
+	for _, v := range triggers(s) {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
+
+// keys returns the monitoring keys of the session s, sorted.
+func keys(s *session.Session) []string {
+	var l []string
+	for k := range s.State {
+		if !strings.HasPrefix(k, stateKey) {
+			continue
+		}
+		if b, err := hex.DecodeString(k[len(stateKey):]); err == nil {
+			l = append(l, string(b))
+		}
+	}
+	sort.Strings(l)
+	return l
+}
+
+// usage returns the threshold granted and the usage of the monitoring
+// key k of the session s.
+func usage(s *session.Session, k string) (granted, used ServiceUnit, ok bool) {
+	v, ok := s.State[stateKey+hex.EncodeToString([]byte(k))]
+	if !ok {
+		return
+	}
+	fmt.Sscan(v,
+		&granted.Time, &granted.TotalOctets, &granted.InputOctets, &granted.OutputOctets,
+		&used.Time, &used.TotalOctets, &used.InputOctets, &used.OutputOctets,
+	)
+	return
+}
+
+// setUsage stores the threshold granted and the usage of the
+// monitoring key k of the session s.
+func setUsage(s *session.Session, k string, granted, used ServiceUnit) {
+	s.State[stateKey+hex.EncodeToString([]byte(k))] = fmt.Sprint(
+		granted.Time, granted.TotalOctets, granted.InputOctets, granted.OutputOctets,
+		used.Time, used.TotalOctets, used.InputOctets, used.OutputOctets,
+	)
+}
+
+// isGx returns true if m is a message of the Gx command code.
+func isGx(m *diam.Message, code uint32) bool {
+	return m.Header.ApplicationID == ApplicationID && m.Header.CommandCode == code
+}
+
+// sessionID returns the Session-Id of the message m.
+func sessionID(m *diam.Message) (string, bool) {
+	for _, a := range m.AVP {
+		if a.Code != avp.SessionID || a.VendorID != 0 {
+			continue
+		}
+		s, err := str(a)
+		return s, err == nil
+	}
+	return "", false
+}
@@ -0,0 +1,147 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/session"
+)
+
+func newCCA(sid string, requestType int32) *diam.Message {
+	m := diam.NewMessage(diam.CreditControl, 0, ApplicationID, 1, 1, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(sid))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("pcrf.example.com"))
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(requestType))
+	return m
+}
+
+func requestNumber(t *testing.T, m *diam.Message) uint32 {
+	a, err := m.FindAVP(avp.CCRequestNumber, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uint32(a.Data.(datatype.Unsigned32))
+}
+
+func TestMonitor(t *testing.T) {
+	store := session.NewMemoryStore()
+	mon := NewMonitor(store, 0)
+	served := 0
+	h := mon.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) { served++ }))
+
+	ccr := diam.NewRequest(diam.CreditControl, ApplicationID, nil)
+	ccr.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("pcef;1"))
+	ccr.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(1))
+	ccr.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+	if err := mon.Egress(nil, ccr); err != nil {
+		t.Fatal(err)
+	}
+	cca := newCCA("pcef;1", 1)
+	cca.AddAVP(RATChange.AVP())
+	cca.AddAVP(UsageReport.AVP())
+	cca.AddAVP((&UsageMonitoringInformation{
+		MonitoringKey: []byte("mk1"),
+		Granted:       ServiceUnit{TotalOctets: 1000},
+	}).AVP())
+	h.ServeDIAM(nil, cca)
+	if served != 1 {
+		t.Fatalf("Unexpected # of messages served. Want 1, have %d", served)
+	}
+	ts, err := mon.Triggers("pcef;1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []EventTrigger{RATChange, UsageReport}; !reflect.DeepEqual(ts, want) {
+		t.Fatalf("Unexpected triggers. Want %v, have %v", want, ts)
+	}
+
+	if m, err := mon.Usage("pcef;1", []byte("mk1"), ServiceUnit{TotalOctets: 400}); err != nil || m != nil {
+		t.Fatalf("Unexpected CCR-U before the threshold: %v, %v", m, err)
+	}
+	m, err := mon.Usage("pcef;1", []byte("mk1"), ServiceUnit{TotalOctets: 600})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m == nil {
+		t.Fatal("Missing CCR-U at the threshold")
+	}
+	// The CCR-U must be valid on the wire.
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err = diam.ReadMessage(bytes.NewReader(b), dict.Default); err != nil {
+		t.Fatal(err)
+	}
+	if n := requestNumber(t, m); n != 1 {
+		t.Fatalf("Unexpected CC-Request-Number. Want 1, have %d", n)
+	}
+	if ts, _ := EventTriggers(m); len(ts) != 1 || ts[0] != UsageReport {
+		t.Fatalf("Unexpected Event-Trigger: %v", ts)
+	}
+	if a, err := m.FindAVP(avp.DestinationRealm, 0); err != nil || a.Data.(datatype.DiameterIdentity) != "pcrf.example.com" {
+		t.Fatalf("Unexpected Destination-Realm: %v, %v", a, err)
+	}
+	umis, err := UsageMonitoringInformations(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &UsageMonitoringInformation{MonitoringKey: []byte("mk1"), Used: ServiceUnit{TotalOctets: 1000}}
+	if len(umis) != 1 || !reflect.DeepEqual(umis[0], want) {
+		t.Fatalf("Unexpected Usage-Monitoring-Information. Want %#v, have %#v", want, umis)
+	}
+	// The key waits for a new threshold.
+	if m, err := mon.Usage("pcef;1", []byte("mk1"), ServiceUnit{TotalOctets: 5000}); err != nil || m != nil {
+		t.Fatalf("Unexpected CCR-U without threshold: %v, %v", m, err)
+	}
+	if _, err := mon.Usage("pcef;1", []byte("mk2"), ServiceUnit{TotalOctets: 1}); err != ErrNotMonitored {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNotMonitored, err)
+	}
+
+	if m, err = mon.Trigger("pcef;1", RATChange); err != nil {
+		t.Fatal(err)
+	}
+	if n := requestNumber(t, m); n != 2 {
+		t.Fatalf("Unexpected CC-Request-Number. Want 2, have %d", n)
+	}
+	if _, err = mon.Trigger("pcef;1", TAIChange); err != ErrNotArmed {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNotArmed, err)
+	}
+	if m, err = mon.Trigger("pcef;1", UsageReport); err != nil {
+		t.Fatal(err)
+	}
+	umis, _ = UsageMonitoringInformations(m)
+	if len(umis) != 1 || umis[0].Used.TotalOctets != 5000 {
+		t.Fatalf("Unexpected Usage-Monitoring-Information: %#v", umis)
+	}
+
+	rar := diam.NewRequest(diam.ReAuth, ApplicationID, nil)
+	rar.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("pcef;1"))
+	rar.AddAVP(NoEventTriggers.AVP())
+	rar.AddAVP((&UsageMonitoringInformation{MonitoringKey: []byte("mk1"), Disabled: true}).AVP())
+	h.ServeDIAM(nil, rar)
+	if ts, _ := mon.Triggers("pcef;1"); len(ts) != 0 {
+		t.Fatalf("Unexpected triggers after NO_EVENT_TRIGGERS: %v", ts)
+	}
+	if _, err := mon.Usage("pcef;1", []byte("mk1"), ServiceUnit{TotalOctets: 1}); err != ErrNotMonitored {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNotMonitored, err)
+	}
+
+	h.ServeDIAM(nil, newCCA("pcef;1", 3))
+	s, err := store.Get("pcef;1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.State) != 0 {
+		t.Fatalf("Unexpected state after termination: %v", s.State)
+	}
+}
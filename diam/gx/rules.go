@@ -0,0 +1,517 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gx
+
+import (
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// FlowStatus is the value of the Flow-Status AVP.
+type FlowStatus int32
+
+// Flow-Status values.
+const (
+	EnabledUplink   FlowStatus = 0
+	EnabledDownlink FlowStatus = 1
+	Enabled         FlowStatus = 2
+	Disabled        FlowStatus = 3
+	Removed         FlowStatus = 4
+)
+
+// FlowDirection is the value of the Flow-Direction AVP.
+type FlowDirection int32
+
+// Flow-Direction values.
+const (
+	Unspecified   FlowDirection = 0
+	Downlink      FlowDirection = 1
+	Uplink        FlowDirection = 2
+	Bidirectional FlowDirection = 3
+)
+
+// MeteringMethod is the value of the Metering-Method AVP.
+type MeteringMethod int32
+
+// Metering-Method values.
+const (
+	Duration       MeteringMethod = 0
+	Volume         MeteringMethod = 1
+	DurationVolume MeteringMethod = 2
+	Event          MeteringMethod = 3
+)
+
+// ReportingLevel is the value of the Reporting-Level AVP.
+type ReportingLevel int32
+
+// Reporting-Level values.
+const (
+	ServiceIdentifierLevel     ReportingLevel = 0
+	RatingGroupLevel           ReportingLevel = 1
+	SponsoredConnectivityLevel ReportingLevel = 2
+)
+
+// Values of the Online, Offline, and Pre-emption AVPs.
+const (
+	enableOnline        = 1
+	enableOffline       = 1
+	enablePreemption    = 0
+	enableVulnerability = 0
+)
+
+// ChargingRuleInstall is the Charging-Rule-Install AVP, that installs
+// PCC rules defined by the PCRF, or predefined in the PCEF.
+//
+// Zero fields are not sent.
+type ChargingRuleInstall struct {
+	Definitions      []*ChargingRuleDefinition
+	Names            []string // Predefined rules
+	BaseNames        []string // Predefined groups of rules
+	BearerIdentifier []byte
+	ActivationTime   time.Time
+	DeactivationTime time.Time
+}
+
+// AVP returns the Charging-Rule-Install AVP.
+func (r *ChargingRuleInstall) AVP() *diam.AVP {
+	var l []*diam.AVP
+	for _, d := range r.Definitions {
+		l = append(l, d.AVP())
+	}
+	l = appendNames(l, r.Names, r.BaseNames)
+	if len(r.BearerIdentifier) > 0 {
+		l = append(l, tgpp(avp.BearerIdentifier, flagVM, datatype.OctetString(r.BearerIdentifier)))
+	}
+	if !r.ActivationTime.IsZero() {
+		l = append(l, tgpp(avp.RuleActivationTime, flagVM, datatype.Time(r.ActivationTime)))
+	}
+	if !r.DeactivationTime.IsZero() {
+		l = append(l, tgpp(avp.RuleDeactivationTime, flagVM, datatype.Time(r.DeactivationTime)))
+	}
+	return group(avp.ChargingRuleInstall, flagVM, VendorID, l)
+}
+
+// ParseChargingRuleInstall parses the Charging-Rule-Install AVP a.
+func ParseChargingRuleInstall(a *diam.AVP) (*ChargingRuleInstall, error) {
+	l, err := avps(a, avp.ChargingRuleInstall)
+	if err != nil {
+		return nil, err
+	}
+	r := &ChargingRuleInstall{}
+	for _, a := range l {
+		switch a.Code {
+		case avp.ChargingRuleDefinition:
+			var d *ChargingRuleDefinition
+			if d, err = ParseChargingRuleDefinition(a); err == nil {
+				r.Definitions = append(r.Definitions, d)
+			}
+		case avp.ChargingRuleName:
+			r.Names, err = appendStr(r.Names, a)
+		case avp.ChargingRuleBaseName:
+			r.BaseNames, err = appendStr(r.BaseNames, a)
+		case avp.BearerIdentifier:
+			r.BearerIdentifier, err = octets(a)
+		case avp.RuleActivationTime:
+			r.ActivationTime, err = timestamp(a)
+		case avp.RuleDeactivationTime:
+			r.DeactivationTime, err = timestamp(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// ChargingRuleRemove is the Charging-Rule-Remove AVP, that removes
+// installed PCC rules.
+type ChargingRuleRemove struct {
+	Names     []string
+	BaseNames []string
+}
+
+// AVP returns the Charging-Rule-Remove AVP.
+func (r *ChargingRuleRemove) AVP() *diam.AVP {
+	return group(avp.ChargingRuleRemove, flagVM, VendorID, appendNames(nil, r.Names, r.BaseNames))
+}
+
+// ParseChargingRuleRemove parses the Charging-Rule-Remove AVP a.
+func ParseChargingRuleRemove(a *diam.AVP) (*ChargingRuleRemove, error) {
+	l, err := avps(a, avp.ChargingRuleRemove)
+	if err != nil {
+		return nil, err
+	}
+	r := &ChargingRuleRemove{}
+	for _, a := range l {
+		switch a.Code {
+		case avp.ChargingRuleName:
+			r.Names, err = appendStr(r.Names, a)
+		case avp.ChargingRuleBaseName:
+			r.BaseNames, err = appendStr(r.BaseNames, a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// ChargingRuleDefinition is the Charging-Rule-Definition AVP, that
+// defines a PCC rule.
+//
+// Zero fields are not sent. Fields whose zero value is meaningful on
+// the wire are pointers, and sent when not nil.
+type ChargingRuleDefinition struct {
+	Name              string
+	ServiceIdentifier uint32
+	RatingGroup       uint32
+	Flows             []*FlowInformation
+	FlowStatus        *FlowStatus
+	QoS               *QoSInformation
+	ReportingLevel    *ReportingLevel
+	Online            *bool
+	Offline           *bool
+	MeteringMethod    *MeteringMethod
+	Precedence        uint32
+	MonitoringKey     []byte
+}
+
+// AVP returns the Charging-Rule-Definition AVP.
+func (d *ChargingRuleDefinition) AVP() *diam.AVP {
+	l := []*diam.AVP{
+		tgpp(avp.ChargingRuleName, flagVM, datatype.OctetString(d.Name)),
+	}
+	if d.ServiceIdentifier != 0 {
+		l = append(l, diam.NewAVP(avp.ServiceIdentifier, avp.Mbit, 0, datatype.Unsigned32(d.ServiceIdentifier)))
+	}
+	if d.RatingGroup != 0 {
+		l = append(l, diam.NewAVP(avp.RatingGroup, avp.Mbit, 0, datatype.Unsigned32(d.RatingGroup)))
+	}
+	for _, f := range d.Flows {
+		l = append(l, f.AVP())
+	}
+	if d.FlowStatus != nil {
+		l = append(l, tgpp(avp.FlowStatus, flagVM, datatype.Enumerated(*d.FlowStatus)))
+	}
+	if d.QoS != nil {
+		l = append(l, d.QoS.AVP())
+	}
+	if d.ReportingLevel != nil {
+		l = append(l, tgpp(avp.ReportingLevel, flagVM, datatype.Enumerated(*d.ReportingLevel)))
+	}
+	if d.Online != nil {
+		l = append(l, tgpp(avp.Online, flagVM, enable(*d.Online, enableOnline)))
+	}
+	if d.Offline != nil {
+		l = append(l, tgpp(avp.Offline, flagVM, enable(*d.Offline, enableOffline)))
+	}
+	if d.MeteringMethod != nil {
+		l = append(l, tgpp(avp.MeteringMethod, flagVM, datatype.Enumerated(*d.MeteringMethod)))
+	}
+	if d.Precedence != 0 {
+		l = append(l, tgpp(avp.Precedence, flagVM, datatype.Unsigned32(d.Precedence)))
+	}
+	if len(d.MonitoringKey) > 0 {
+		l = append(l, tgpp(avp.MonitoringKey, flagV, datatype.OctetString(d.MonitoringKey)))
+	}
+	return group(avp.ChargingRuleDefinition, flagVM, VendorID, l)
+}
+
+// ParseChargingRuleDefinition parses the Charging-Rule-Definition AVP a.
+func ParseChargingRuleDefinition(a *diam.AVP) (*ChargingRuleDefinition, error) {
+	l, err := avps(a, avp.ChargingRuleDefinition)
+	if err != nil {
+		return nil, err
+	}
+	d := &ChargingRuleDefinition{}
+	var v int32
+	for _, a := range l {
+		switch a.Code {
+		case avp.ChargingRuleName:
+			d.Name, err = str(a)
+		case avp.ServiceIdentifier:
+			d.ServiceIdentifier, err = unsigned32(a)
+		case avp.RatingGroup:
+			d.RatingGroup, err = unsigned32(a)
+		case avp.FlowInformation:
+			var f *FlowInformation
+			if f, err = ParseFlowInformation(a); err == nil {
+				d.Flows = append(d.Flows, f)
+			}
+		case avp.FlowStatus:
+			if v, err = enumerated(a); err == nil {
+				s := FlowStatus(v)
+				d.FlowStatus = &s
+			}
+		case avp.QoSInformation:
+			d.QoS, err = ParseQoSInformation(a)
+		case avp.ReportingLevel:
+			if v, err = enumerated(a); err == nil {
+				r := ReportingLevel(v)
+				d.ReportingLevel = &r
+			}
+		case avp.Online:
+			d.Online, err = enabled(a, enableOnline)
+		case avp.Offline:
+			d.Offline, err = enabled(a, enableOffline)
+		case avp.MeteringMethod:
+			if v, err = enumerated(a); err == nil {
+				m := MeteringMethod(v)
+				d.MeteringMethod = &m
+			}
+		case avp.Precedence:
+			d.Precedence, err = unsigned32(a)
+		case avp.MonitoringKey:
+			d.MonitoringKey, err = octets(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// FlowInformation is the Flow-Information AVP, that describes a
+// service data flow of a PCC rule.
+type FlowInformation struct {
+	Description            string // IPFilterRule, e.g. "permit out ip from any to 10.0.0.1"
+	PacketFilterIdentifier []byte
+	ToSTrafficClass        []byte
+	Direction              FlowDirection
+}
+
+// AVP returns the Flow-Information AVP.
+func (f *FlowInformation) AVP() *diam.AVP {
+	var l []*diam.AVP
+	if f.Description != "" {
+		l = append(l, tgpp(avp.FlowDescription, flagVM, datatype.IPFilterRule(f.Description)))
+	}
+	if len(f.PacketFilterIdentifier) > 0 {
+		l = append(l, tgpp(avp.PacketFilterIdentifier, flagV, datatype.OctetString(f.PacketFilterIdentifier)))
+	}
+	if len(f.ToSTrafficClass) > 0 {
+		l = append(l, tgpp(avp.ToSTrafficClass, flagVM, datatype.OctetString(f.ToSTrafficClass)))
+	}
+	if f.Direction != Unspecified {
+		l = append(l, tgpp(avp.FlowDirection, flagV, datatype.Enumerated(f.Direction)))
+	}
+	return group(avp.FlowInformation, flagV, VendorID, l)
+}
+
+// ParseFlowInformation parses the Flow-Information AVP a.
+func ParseFlowInformation(a *diam.AVP) (*FlowInformation, error) {
+	l, err := avps(a, avp.FlowInformation)
+	if err != nil {
+		return nil, err
+	}
+	f := &FlowInformation{}
+	var v int32
+	for _, a := range l {
+		switch a.Code {
+		case avp.FlowDescription:
+			f.Description, err = str(a)
+		case avp.PacketFilterIdentifier:
+			f.PacketFilterIdentifier, err = octets(a)
+		case avp.ToSTrafficClass:
+			f.ToSTrafficClass, err = octets(a)
+		case avp.FlowDirection:
+			v, err = enumerated(a)
+			f.Direction = FlowDirection(v)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// QoSInformation is the QoS-Information AVP, that authorizes the QoS
+// of a PCC rule, bearer, or APN. Bitrates are in bits per second.
+//
+// Zero fields are not sent.
+type QoSInformation struct {
+	QCI                      uint32 // QoS-Class-Identifier, 1 to 9
+	MaxRequestedBandwidthUL  uint32
+	MaxRequestedBandwidthDL  uint32
+	GuaranteedBitrateUL      uint32
+	GuaranteedBitrateDL      uint32
+	BearerIdentifier         []byte
+	ARP                      *AllocationRetentionPriority
+	APNAggregateMaxBitrateUL uint32
+	APNAggregateMaxBitrateDL uint32
+}
+
+// AVP returns the QoS-Information AVP.
+func (q *QoSInformation) AVP() *diam.AVP {
+	var l []*diam.AVP
+	if q.QCI != 0 {
+		l = append(l, tgpp(avp.QoSClassIdentifier, flagVM, datatype.Enumerated(q.QCI)))
+	}
+	for _, b := range []struct{ code, v uint32 }{
+		{avp.MaxRequestedBandwidthUL, q.MaxRequestedBandwidthUL},
+		{avp.MaxRequestedBandwidthDL, q.MaxRequestedBandwidthDL},
+		{avp.GuaranteedBitrateUL, q.GuaranteedBitrateUL},
+		{avp.GuaranteedBitrateDL, q.GuaranteedBitrateDL},
+	} {
+		if b.v != 0 {
+			l = append(l, tgpp(b.code, flagVM, datatype.Unsigned32(b.v)))
+		}
+	}
+	if len(q.BearerIdentifier) > 0 {
+		l = append(l, tgpp(avp.BearerIdentifier, flagVM, datatype.OctetString(q.BearerIdentifier)))
+	}
+	if q.ARP != nil {
+		l = append(l, q.ARP.AVP())
+	}
+	if q.APNAggregateMaxBitrateUL != 0 {
+		l = append(l, tgpp(avp.APNAggregateMaxBitrateUL, flagV, datatype.Unsigned32(q.APNAggregateMaxBitrateUL)))
+	}
+	if q.APNAggregateMaxBitrateDL != 0 {
+		l = append(l, tgpp(avp.APNAggregateMaxBitrateDL, flagV, datatype.Unsigned32(q.APNAggregateMaxBitrateDL)))
+	}
+	return group(avp.QoSInformation, flagVM, VendorID, l)
+}
+
+// ParseQoSInformation parses the QoS-Information AVP a.
+func ParseQoSInformation(a *diam.AVP) (*QoSInformation, error) {
+	l, err := avps(a, avp.QoSInformation)
+	if err != nil {
+		return nil, err
+	}
+	q := &QoSInformation{}
+	var v int32
+	for _, a := range l {
+		switch a.Code {
+		case avp.QoSClassIdentifier:
+			v, err = enumerated(a)
+			q.QCI = uint32(v)
+		case avp.MaxRequestedBandwidthUL:
+			q.MaxRequestedBandwidthUL, err = unsigned32(a)
+		case avp.MaxRequestedBandwidthDL:
+			q.MaxRequestedBandwidthDL, err = unsigned32(a)
+		case avp.GuaranteedBitrateUL:
+			q.GuaranteedBitrateUL, err = unsigned32(a)
+		case avp.GuaranteedBitrateDL:
+			q.GuaranteedBitrateDL, err = unsigned32(a)
+		case avp.BearerIdentifier:
+			q.BearerIdentifier, err = octets(a)
+		case avp.AllocationRetentionPriority:
+			q.ARP, err = ParseAllocationRetentionPriority(a)
+		case avp.APNAggregateMaxBitrateUL:
+			q.APNAggregateMaxBitrateUL, err = unsigned32(a)
+		case avp.APNAggregateMaxBitrateDL:
+			q.APNAggregateMaxBitrateDL, err = unsigned32(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// AllocationRetentionPriority is the Allocation-Retention-Priority
+// AVP. PriorityLevel is from 1, the highest, to 15. The pre-emption
+// fields are not sent when nil.
+type AllocationRetentionPriority struct {
+	PriorityLevel           uint32
+	PreemptionCapability    *bool // May pre-empt bearers of lower priority
+	PreemptionVulnerability *bool // May be pre-empted by bearers of higher priority
+}
+
+// AVP returns the Allocation-Retention-Priority AVP.
+func (p *AllocationRetentionPriority) AVP() *diam.AVP {
+	l := []*diam.AVP{
+		tgpp(avp.PriorityLevel, flagV, datatype.Unsigned32(p.PriorityLevel)),
+	}
+	if p.PreemptionCapability != nil {
+		l = append(l, tgpp(avp.PreemptionCapability, flagV, enable(*p.PreemptionCapability, enablePreemption)))
+	}
+	if p.PreemptionVulnerability != nil {
+		l = append(l, tgpp(avp.PreemptionVulnerability, flagV, enable(*p.PreemptionVulnerability, enableVulnerability)))
+	}
+	return group(avp.AllocationRetentionPriority, flagV, VendorID, l)
+}
+
+// ParseAllocationRetentionPriority parses the
+// Allocation-Retention-Priority AVP a.
+func ParseAllocationRetentionPriority(a *diam.AVP) (*AllocationRetentionPriority, error) {
+	l, err := avps(a, avp.AllocationRetentionPriority)
+	if err != nil {
+		return nil, err
+	}
+	p := &AllocationRetentionPriority{}
+	for _, a := range l {
+		switch a.Code {
+		case avp.PriorityLevel:
+			p.PriorityLevel, err = unsigned32(a)
+		case avp.PreemptionCapability:
+			p.PreemptionCapability, err = enabled(a, enablePreemption)
+		case avp.PreemptionVulnerability:
+			p.PreemptionVulnerability, err = enabled(a, enableVulnerability)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ChargingRuleInstalls parses the Charging-Rule-Install AVPs of the
+// message m, e.g. a CCA or RAR.
+func ChargingRuleInstalls(m *diam.Message) ([]*ChargingRuleInstall, error) {
+	var l []*ChargingRuleInstall
+	for _, a := range m.AVP {
+		if a.Code != avp.ChargingRuleInstall || a.VendorID != VendorID {
+			continue
+		}
+		r, err := ParseChargingRuleInstall(a)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, r)
+	}
+	return l, nil
+}
+
+// ChargingRuleRemoves parses the Charging-Rule-Remove AVPs of the
+// message m, e.g. a CCA or RAR.
+func ChargingRuleRemoves(m *diam.Message) ([]*ChargingRuleRemove, error) {
+	var l []*ChargingRuleRemove
+	for _, a := range m.AVP {
+		if a.Code != avp.ChargingRuleRemove || a.VendorID != VendorID {
+			continue
+		}
+		r, err := ParseChargingRuleRemove(a)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, r)
+	}
+	return l, nil
+}
+
+// appendNames appends the Charging-Rule-Name and Charging-Rule-Base-Name
+// AVPs of names and bases to l.
+func appendNames(l []*diam.AVP, names, bases []string) []*diam.AVP {
+	for _, n := range names {
+		l = append(l, tgpp(avp.ChargingRuleName, flagVM, datatype.OctetString(n)))
+	}
+	for _, n := range bases {
+		l = append(l, tgpp(avp.ChargingRuleBaseName, flagVM, datatype.UTF8String(n)))
+	}
+	return l
+}
+
+// appendStr appends the string value of a to l.
+func appendStr(l []string, a *diam.AVP) ([]string, error) {
+	s, err := str(a)
+	if err != nil {
+		return l, err
+	}
+	return append(l, s), nil
+}
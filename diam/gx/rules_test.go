@@ -0,0 +1,112 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gx
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestChargingRules(t *testing.T) {
+	yes, no := true, false
+	enabled, volume := Enabled, Volume
+	install := &ChargingRuleInstall{
+		Definitions: []*ChargingRuleDefinition{{
+			Name:        "video",
+			RatingGroup: 10,
+			Flows: []*FlowInformation{
+				{Description: "permit out 17 from any to 10.0.0.1 5000", Direction: Downlink},
+				{Description: "permit out 17 from 10.0.0.1 5000 to any", Direction: Uplink},
+			},
+			FlowStatus: &enabled,
+			QoS: &QoSInformation{
+				QCI:                 2,
+				GuaranteedBitrateDL: 2000000,
+				GuaranteedBitrateUL: 64000,
+				ARP: &AllocationRetentionPriority{
+					PriorityLevel:           5,
+					PreemptionCapability:    &no,
+					PreemptionVulnerability: &yes,
+				},
+			},
+			Online:         &no,
+			Offline:        &yes,
+			MeteringMethod: &volume,
+			Precedence:     100,
+			MonitoringKey:  []byte("mk1"),
+		}},
+		Names:          []string{"default"},
+		BaseNames:      []string{"gold"},
+		ActivationTime: time.Unix(1420070400, 0),
+	}
+	remove := &ChargingRuleRemove{Names: []string{"old"}}
+
+	m := diam.NewRequest(diam.ReAuth, ApplicationID, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("pcef;1"))
+	m.AddAVP(remove.AVP())
+	m.AddAVP(install.AVP())
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = diam.ReadMessage(bytes.NewReader(b), dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	installs, err := ChargingRuleInstalls(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(installs) != 1 || !reflect.DeepEqual(installs[0], install) {
+		t.Fatalf("Unexpected Charging-Rule-Install.\nWant %#v\nhave %#v", install, installs)
+	}
+	removes, err := ChargingRuleRemoves(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removes) != 1 || !reflect.DeepEqual(removes[0], remove) {
+		t.Fatalf("Unexpected Charging-Rule-Remove.\nWant %#v\nhave %#v", remove, removes)
+	}
+}
+
+func TestChargingRuleDefinition_Flags(t *testing.T) {
+	d := &ChargingRuleDefinition{
+		Name:  "r",
+		Flows: []*FlowInformation{{Direction: Bidirectional}},
+	}
+	a := d.AVP()
+	if a.Flags != avp.Vbit|avp.Mbit || a.VendorID != VendorID {
+		t.Fatalf("Unexpected Charging-Rule-Definition flags %#x, vendor %d", a.Flags, a.VendorID)
+	}
+	f := a.Data.(*diam.GroupedAVP).AVP[1]
+	if f.Code != avp.FlowInformation || f.Flags != avp.Vbit {
+		t.Fatalf("Unexpected Flow-Information code %d, flags %#x", f.Code, f.Flags)
+	}
+}
+
+func TestParseChargingRuleInstall_Errors(t *testing.T) {
+	a := diam.NewAVP(avp.ChargingRuleRemove, avp.Vbit|avp.Mbit, VendorID, &diam.GroupedAVP{})
+	if _, err := ParseChargingRuleInstall(a); err == nil {
+		t.Fatal("Unexpected success parsing Charging-Rule-Remove")
+	}
+	a = diam.NewAVP(avp.ChargingRuleInstall, avp.Vbit|avp.Mbit, VendorID, datatype.OctetString("x"))
+	if _, err := ParseChargingRuleInstall(a); err == nil {
+		t.Fatal("Unexpected success parsing non-grouped AVP")
+	}
+	a = (&ChargingRuleInstall{}).AVP()
+	a.Data.(*diam.GroupedAVP).AVP = []*diam.AVP{
+		diam.NewAVP(avp.ChargingRuleName, avp.Vbit|avp.Mbit, VendorID, datatype.Unsigned32(1)),
+	}
+	if _, err := ParseChargingRuleInstall(a); err == nil {
+		t.Fatal("Unexpected success parsing Charging-Rule-Name of wrong type")
+	}
+}
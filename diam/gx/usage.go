@@ -0,0 +1,246 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package gx
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// EventTrigger is the value of the Event-Trigger AVP. The PCRF arms
+// event triggers in CCA and RAR messages, and the PCEF reports them
+// in CCR-U messages.
+type EventTrigger int32
+
+// Event-Trigger values.
+const (
+	SGSNChange                      EventTrigger = 0
+	QoSChange                       EventTrigger = 1
+	RATChange                       EventTrigger = 2
+	TFTChange                       EventTrigger = 3
+	PLMNChange                      EventTrigger = 4
+	LossOfBearer                    EventTrigger = 5
+	RecoveryOfBearer                EventTrigger = 6
+	IPCANChange                     EventTrigger = 7
+	QoSChangeExceedingAuthorization EventTrigger = 11
+	RAIChange                       EventTrigger = 12
+	UserLocationChange              EventTrigger = 13
+	NoEventTriggers                 EventTrigger = 14
+	OutOfCredit                     EventTrigger = 15
+	ReallocationOfCredit            EventTrigger = 16
+	RevalidationTimeout             EventTrigger = 17
+	UEIPAddressAllocate             EventTrigger = 18
+	UEIPAddressRelease              EventTrigger = 19
+	DefaultEPSBearerQoSChange       EventTrigger = 20
+	ANGWChange                      EventTrigger = 21
+	SuccessfulResourceAllocation    EventTrigger = 22
+	ResourceModificationRequest     EventTrigger = 23
+	PGWTraceControl                 EventTrigger = 24
+	UETimeZoneChange                EventTrigger = 25
+	TAIChange                       EventTrigger = 26
+	ECGIChange                      EventTrigger = 27
+	ChargingCorrelationExchange     EventTrigger = 28
+	APNAMBRModificationFailure      EventTrigger = 29
+	UserCSGInformationChange        EventTrigger = 30
+	UsageReport                     EventTrigger = 33
+)
+
+// AVP returns the Event-Trigger AVP.
+func (t EventTrigger) AVP() *diam.AVP {
+	return tgpp(avp.EventTrigger, flagVM, datatype.Enumerated(t))
+}
+
+// EventTriggers returns the Event-Trigger AVPs of the message m.
+func EventTriggers(m *diam.Message) ([]EventTrigger, error) {
+	var l []EventTrigger
+	for _, a := range m.AVP {
+		if a.Code != avp.EventTrigger || a.VendorID != VendorID {
+			continue
+		}
+		v, err := enumerated(a)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, EventTrigger(v))
+	}
+	return l, nil
+}
+
+// UsageMonitoringLevel is the value of the Usage-Monitoring-Level AVP.
+type UsageMonitoringLevel int32
+
+// Usage-Monitoring-Level values.
+const (
+	SessionLevel UsageMonitoringLevel = 0
+	PCCRuleLevel UsageMonitoringLevel = 1
+	ADCRuleLevel UsageMonitoringLevel = 2
+)
+
+// ServiceUnit is the Granted-Service-Unit or Used-Service-Unit AVP of
+// a usage monitoring key: the usage threshold set by the PCRF, or the
+// usage reported by the PCEF. Zero fields are not sent.
+type ServiceUnit struct {
+	Time         uint32 // Seconds
+	TotalOctets  uint64
+	InputOctets  uint64
+	OutputOctets uint64
+}
+
+// IsZero returns true if no field of u is set.
+func (u ServiceUnit) IsZero() bool {
+	return u == ServiceUnit{}
+}
+
+// Reached returns true if the usage u reached a threshold set in
+// granted.
+func (u ServiceUnit) Reached(granted ServiceUnit) bool {
+	return granted.Time > 0 && u.Time >= granted.Time ||
+		granted.TotalOctets > 0 && u.TotalOctets >= granted.TotalOctets ||
+		granted.InputOctets > 0 && u.InputOctets >= granted.InputOctets ||
+		granted.OutputOctets > 0 && u.OutputOctets >= granted.OutputOctets
+}
+
+// add returns the sum of the usages u and v.
+func (u ServiceUnit) add(v ServiceUnit) ServiceUnit {
+	return ServiceUnit{
+		Time:         u.Time + v.Time,
+		TotalOctets:  u.TotalOctets + v.TotalOctets,
+		InputOctets:  u.InputOctets + v.InputOctets,
+		OutputOctets: u.OutputOctets + v.OutputOctets,
+	}
+}
+
+func (u ServiceUnit) avp(code uint32) *diam.AVP {
+	var l []*diam.AVP
+	if u.Time != 0 {
+		l = append(l, diam.NewAVP(avp.CCTime, avp.Mbit, 0, datatype.Unsigned32(u.Time)))
+	}
+	if u.TotalOctets != 0 {
+		l = append(l, diam.NewAVP(avp.CCTotalOctets, avp.Mbit, 0, datatype.Unsigned64(u.TotalOctets)))
+	}
+	if u.InputOctets != 0 {
+		l = append(l, diam.NewAVP(avp.CCInputOctets, avp.Mbit, 0, datatype.Unsigned64(u.InputOctets)))
+	}
+	if u.OutputOctets != 0 {
+		l = append(l, diam.NewAVP(avp.CCOutputOctets, avp.Mbit, 0, datatype.Unsigned64(u.OutputOctets)))
+	}
+	return group(code, avp.Mbit, 0, l)
+}
+
+func parseServiceUnit(a *diam.AVP) (ServiceUnit, error) {
+	var u ServiceUnit
+	l, err := avps(a, a.Code)
+	if err != nil {
+		return u, err
+	}
+	for _, a := range l {
+		switch a.Code {
+		case avp.CCTime:
+			u.Time, err = unsigned32(a)
+		case avp.CCTotalOctets:
+			u.TotalOctets, err = unsigned64(a)
+		case avp.CCInputOctets:
+			u.InputOctets, err = unsigned64(a)
+		case avp.CCOutputOctets:
+			u.OutputOctets, err = unsigned64(a)
+		}
+		if err != nil {
+			return u, err
+		}
+	}
+	return u, nil
+}
+
+// UsageMonitoringInformation is the Usage-Monitoring-Information AVP.
+// The PCRF sets the usage thresholds of monitoring keys with Granted,
+// and the PCEF reports their usage with Used.
+//
+// Zero fields are not sent.
+type UsageMonitoringInformation struct {
+	MonitoringKey []byte
+	Granted       ServiceUnit
+	Used          ServiceUnit
+	Level         *UsageMonitoringLevel
+	ReportNow     bool // Usage-Monitoring-Report: the PCEF reports the usage
+	Disabled      bool // Usage-Monitoring-Support: monitoring of the key stops
+}
+
+// AVP returns the Usage-Monitoring-Information AVP.
+func (u *UsageMonitoringInformation) AVP() *diam.AVP {
+	var l []*diam.AVP
+	if len(u.MonitoringKey) > 0 {
+		l = append(l, tgpp(avp.MonitoringKey, flagV, datatype.OctetString(u.MonitoringKey)))
+	}
+	if !u.Granted.IsZero() {
+		l = append(l, u.Granted.avp(avp.GrantedServiceUnit))
+	}
+	if !u.Used.IsZero() {
+		l = append(l, u.Used.avp(avp.UsedServiceUnit))
+	}
+	if u.Level != nil {
+		l = append(l, tgpp(avp.UsageMonitoringLevel, flagV, datatype.Enumerated(*u.Level)))
+	}
+	if u.ReportNow {
+		l = append(l, tgpp(avp.UsageMonitoringReport, flagV, datatype.Enumerated(0)))
+	}
+	if u.Disabled {
+		l = append(l, tgpp(avp.UsageMonitoringSupport, flagV, datatype.Enumerated(0)))
+	}
+	return group(avp.UsageMonitoringInformation, flagV, VendorID, l)
+}
+
+// ParseUsageMonitoringInformation parses the
+// Usage-Monitoring-Information AVP a.
+func ParseUsageMonitoringInformation(a *diam.AVP) (*UsageMonitoringInformation, error) {
+	l, err := avps(a, avp.UsageMonitoringInformation)
+	if err != nil {
+		return nil, err
+	}
+	u := &UsageMonitoringInformation{}
+	var v int32
+	for _, a := range l {
+		switch a.Code {
+		case avp.MonitoringKey:
+			u.MonitoringKey, err = octets(a)
+		case avp.GrantedServiceUnit:
+			u.Granted, err = parseServiceUnit(a)
+		case avp.UsedServiceUnit:
+			u.Used, err = parseServiceUnit(a)
+		case avp.UsageMonitoringLevel:
+			if v, err = enumerated(a); err == nil {
+				level := UsageMonitoringLevel(v)
+				u.Level = &level
+			}
+		case avp.UsageMonitoringReport:
+			_, err = enumerated(a)
+			u.ReportNow = err == nil
+		case avp.UsageMonitoringSupport:
+			v, err = enumerated(a)
+			u.Disabled = err == nil && v == 0
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return u, nil
+}
+
+// UsageMonitoringInformations parses the Usage-Monitoring-Information
+// AVPs of the message m.
+func UsageMonitoringInformations(m *diam.Message) ([]*UsageMonitoringInformation, error) {
+	var l []*UsageMonitoringInformation
+	for _, a := range m.AVP {
+		if a.Code != avp.UsageMonitoringInformation || a.VendorID != VendorID {
+			continue
+		}
+		u, err := ParseUsageMonitoringInformation(a)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, u)
+	}
+	return l, nil
+}
@@ -0,0 +1,123 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// IDGenerator generates Hop-by-Hop and End-to-End Identifiers for
+// new messages. It is safe for concurrent use.
+//
+// When NodeID is unset, End-to-End Identifiers follow RFC 6733 section 3:
+// the high order 12 bits contain the low order 12 bits of the current
+// time, and the low order 20 bits a counter that starts at a random value.
+//
+// When multiple instances of an application run behind a load balancer,
+// each instance must be configured with a distinct NodeID, which takes
+// the high order NodeBits bits of the End-to-End Identifier instead of
+// the time, so instances never generate colliding identifiers. NodeID 0
+// is reserved for the time mode, so instances are numbered from 1 to
+// 1<<NodeBits - 1. Use NewIDGenerator, or Validate, to check them when
+// the generator is configured.
+//
+// The remaining 32-NodeBits bits hold the counter, which must not wrap
+// within the 4 minutes RFC 6733 requires End-to-End Identifiers to stay
+// unique: each instance must send less than 1<<(32-NodeBits) requests
+// in 4 minutes, e.g. about 69900 per second with the default 8 bits,
+// and 4369 per second with MaxNodeBits.
+//
+// NodeID and NodeBits must not be modified after the generator is used.
+type IDGenerator struct {
+	NodeID   uint32 // Identifier of this instance in the cluster
+	NodeBits uint   // # of bits used by NodeID (default 8, max MaxNodeBits)
+
+	once sync.Once
+	hbh  uint32 // Hop-by-Hop counter
+	e2e  uint32 // End-to-End counter
+}
+
+// MaxNodeBits is the maximum NodeBits of an IDGenerator, leaving the
+// counter the 20 bits it has in the time mode.
+const MaxNodeBits = 12
+
+// NewIDGenerator creates and initializes a new IDGenerator for the
+// instance nodeID of a cluster, using nodeBits bits of the End-to-End
+// Identifiers. It returns the error of Validate if the generator is
+// not valid.
+func NewIDGenerator(nodeID uint32, nodeBits uint) (*IDGenerator, error) {
+	g := &IDGenerator{NodeID: nodeID, NodeBits: nodeBits}
+	if err := g.Validate(); err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// DefaultIDGenerator is the IDGenerator used by NewMessage.
+var DefaultIDGenerator = &IDGenerator{}
+
+func (g *IDGenerator) init() {
+	g.once.Do(func() {
+		r := rand.New(rand.NewSource(time.Now().UnixNano()))
+		g.hbh = r.Uint32()
+		g.e2e = r.Uint32()
+	})
+}
+
+// HopByHopID returns a new Hop-by-Hop Identifier.
+func (g *IDGenerator) HopByHopID() uint32 {
+	g.init()
+	return atomic.AddUint32(&g.hbh, 1)
+}
+
+var (
+	// ErrNodeID is returned by IDGenerator.Validate when the NodeID
+	// does not fit in NodeBits bits.
+	ErrNodeID = errors.New("diam: NodeID does not fit in NodeBits")
+
+	// ErrNodeBits is returned by IDGenerator.Validate when NodeBits
+	// is larger than MaxNodeBits.
+	ErrNodeBits = errors.New("diam: NodeBits larger than MaxNodeBits")
+)
+
+// Validate returns ErrNodeBits if NodeBits is larger than MaxNodeBits,
+// and ErrNodeID if the NodeID does not fit in NodeBits bits, which
+// would make it collide with another NodeID.
+func (g *IDGenerator) Validate() error {
+	if g.NodeBits > MaxNodeBits {
+		return ErrNodeBits
+	}
+	if g.NodeID>>g.nodeBits() != 0 {
+		return ErrNodeID
+	}
+	return nil
+}
+
+// EndToEndID returns a new End-to-End Identifier. The NodeID of an
+// invalid generator is truncated to NodeBits bits, see Validate.
+func (g *IDGenerator) EndToEndID() uint32 {
+	g.init()
+	n := atomic.AddUint32(&g.e2e, 1)
+	if g.NodeID == 0 {
+		t := uint32(time.Now().Unix())
+		return t<<20 | n&0xfffff
+	}
+	bits := g.nodeBits()
+	return g.NodeID<<(32-bits) | n&(1<<(32-bits)-1)
+}
+
+func (g *IDGenerator) nodeBits() uint {
+	switch {
+	case g.NodeBits == 0:
+		return 8
+	case g.NodeBits > MaxNodeBits:
+		return MaxNodeBits
+	}
+	return g.NodeBits
+}
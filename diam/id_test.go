@@ -0,0 +1,95 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestIDGenerator_NodeID(t *testing.T) {
+	g := &IDGenerator{NodeID: 0xab}
+	for i := 0; i < 10; i++ {
+		if id := g.EndToEndID(); id>>24 != 0xab {
+			t.Fatalf("Unexpected node in End-to-End ID 0x%x", id)
+		}
+	}
+	g = &IDGenerator{NodeID: 0x3ff, NodeBits: 10}
+	if id := g.EndToEndID(); id>>22 != 0x3ff {
+		t.Fatalf("Unexpected node in End-to-End ID 0x%x", id)
+	}
+}
+
+func TestIDGenerator_Collision(t *testing.T) {
+	// Simulate multiple instances behind a load balancer.
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[uint32]uint32)
+	for node := uint32(1); node <= 4; node++ {
+		wg.Add(1)
+		go func(g *IDGenerator) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				id := g.EndToEndID()
+				mu.Lock()
+				if n, ok := seen[id]; ok {
+					t.Errorf("End-to-End ID 0x%x generated by nodes %d and %d",
+						id, n, g.NodeID)
+				}
+				seen[id] = g.NodeID
+				mu.Unlock()
+			}
+		}(&IDGenerator{NodeID: node})
+	}
+	wg.Wait()
+}
+
+func TestNewMessage_GeneratedIDs(t *testing.T) {
+	a := NewRequest(CapabilitiesExchange, 0, nil)
+	b := NewRequest(CapabilitiesExchange, 0, nil)
+	if a.Header.HopByHopID == b.Header.HopByHopID {
+		t.Fatal("Duplicate Hop-by-Hop ID")
+	}
+	if a.Header.EndToEndID == b.Header.EndToEndID {
+		t.Fatal("Duplicate End-to-End ID")
+	}
+}
+
+func TestIDGenerator_Validate(t *testing.T) {
+	valid := []*IDGenerator{
+		{},
+		{NodeID: 255},
+		{NodeID: 1023, NodeBits: 10},
+	}
+	for _, g := range valid {
+		if err := g.Validate(); err != nil {
+			t.Fatalf("Unexpected error for NodeID %d: %v", g.NodeID, err)
+		}
+	}
+	// 257 would be masked to 1 in 8 bits, colliding with node 1.
+	invalid := []*IDGenerator{
+		{NodeID: 256},
+		{NodeID: 257},
+		{NodeID: 1024, NodeBits: 10},
+	}
+	for _, g := range invalid {
+		if err := g.Validate(); err != ErrNodeID {
+			t.Fatalf("Unexpected error for NodeID %d. Want %v, have %v", g.NodeID, ErrNodeID, err)
+		}
+	}
+	if g, err := NewIDGenerator(1, 16); g != nil || err != ErrNodeBits {
+		t.Fatalf("Unexpected error for NodeBits 16. Want %v, have %v", ErrNodeBits, err)
+	}
+	if g, err := NewIDGenerator(4095, MaxNodeBits); g == nil || err != nil {
+		t.Fatalf("Unexpected error for NodeID 4095: %v", err)
+	}
+	if _, err := NewIDGenerator(257, 0); err != ErrNodeID {
+		t.Fatalf("Unexpected error for NodeID 257. Want %v, have %v", ErrNodeID, err)
+	}
+	// Invalid generators don't panic on the hot path.
+	if id := invalid[1].EndToEndID(); id>>24 != 1 {
+		t.Fatalf("Unexpected End-to-End ID %#x for NodeID 257", id)
+	}
+}
@@ -0,0 +1,20 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+// The InterceptFunc type is a hook called with the serialized frames
+// written to a connection, after the egress hooks, see Server.Intercept.
+// It is meant for negative-path conformance tests of peers, with this
+// package as the test driver, e.g. to send messages with a wrong
+// length or reserved flags set.
+//
+// It returns the frame to write instead of b, which it may modify in
+// place. Returning a nil frame drops it silently, e.g. to simulate a
+// lost DWA, and returning an error vetoes the write, which fails with
+// the error.
+//
+// Frames written with Conn.SendBatch hold all the messages of the
+// batch.
+type InterceptFunc func(c Conn, b []byte) ([]byte, error)
@@ -0,0 +1,99 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestServer_Intercept(t *testing.T) {
+	// The peer under test answers the requests it cannot decode.
+	received := make(chan *diam.Message, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		received <- m
+	})
+	srv := diamtest.NewUnstartedServer(smux, nil)
+	srv.Config.AnswerDecodeErrors = true
+	srv.Start()
+	defer srv.Close()
+
+	errVeto := errors.New("vetoed")
+	mode := make(chan string, 1)
+	intercept := func(c diam.Conn, b []byte) ([]byte, error) {
+		switch <-mode {
+		case "corrupt":
+			// Length of the first AVP shorter than its header.
+			b[25], b[26], b[27] = 0, 0, 4
+		case "drop":
+			return nil, nil
+		case "veto":
+			return nil, errVeto
+		}
+		return b, nil
+	}
+	answers := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		answers <- m
+	})
+	cli, err := (&diam.Server{Addr: srv.Addr, Handler: cmux, Intercept: intercept}).Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+
+	mode <- "corrupt"
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-answers:
+		rc, err := a.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.InvalidAVPLenght {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.InvalidAVPLenght, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+
+	mode <- "veto"
+	if _, err = m.WriteTo(cli); err != errVeto {
+		t.Fatalf("Unexpected error. Want %v, have %v", errVeto, err)
+	}
+	mode <- "drop"
+	if n, err := m.WriteTo(cli); n != 0 || err != nil {
+		t.Fatalf("Unexpected write of dropped frame: %d, %v", n, err)
+	}
+	mode <- "pass"
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case r := <-received:
+		if r.Header.HopByHopID != m.Header.HopByHopID {
+			t.Fatalf("Unexpected CCR: %s", r)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCR")
+	}
+	select {
+	case r := <-received:
+		t.Fatalf("Unexpected CCR received: %s", r)
+	default:
+	}
+}
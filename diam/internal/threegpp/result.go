@@ -0,0 +1,117 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package threegpp holds the parts shared by the 3GPP application packages
+// of go-diameter, like gba and lcs.
+package threegpp
+
+import (
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// VendorID is the vendor id of 3GPP.
+const VendorID = 10415
+
+// Result holds the AVPs common to the answers of the 3GPP applications.
+//
+// Either ResultCode or ExperimentalResultCode is sent, the latter with
+// the 3GPP vendor id, e.g. 5001 (DIAMETER_ERROR_USER_UNKNOWN).
+type Result struct {
+	SessionID              string
+	OriginHost             datatype.DiameterIdentity
+	OriginRealm            datatype.DiameterIdentity
+	ResultCode             uint32
+	ExperimentalResultCode uint32
+}
+
+// Answer returns a new answer to the request req, with the AVPs of r.
+// The flags are those of req.Answer: the P bit is copied from req, and
+// the E bit is set for protocol errors.
+func Answer(r *Result, req *diam.Message) *diam.Message {
+	m := req.Answer(r.ResultCode)
+	if r.ExperimentalResultCode != 0 {
+		// Experimental-Result replaces the Result-Code added by Answer.
+		m.AVP = m.AVP[:0]
+		m.Header.MessageLength = diam.HeaderLength
+		m.NewAVP(avp.ExperimentalResult, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(VendorID)),
+				diam.NewAVP(avp.ExperimentalResultCode, avp.Mbit, 0, datatype.Unsigned32(r.ExperimentalResultCode)),
+			},
+		})
+	}
+	m.InsertAVP(diam.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(VendorID)),
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(req.Header.ApplicationID)),
+		},
+	}))
+	m.InsertAVP(diam.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(r.SessionID)))
+	m.NewAVP(avp.AuthSessionState, avp.Mbit, 0, datatype.Enumerated(1))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, r.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, r.OriginRealm)
+	return m
+}
+
+// Parse sets the field of r for the base AVP a, and returns false if
+// a is not one of them.
+func Parse(r *Result, a *diam.AVP) (bool, error) {
+	var err error
+	switch a.Code {
+	case avp.SessionID:
+		r.SessionID, err = str(a)
+	case avp.OriginHost:
+		r.OriginHost, err = identity(a)
+	case avp.OriginRealm:
+		r.OriginRealm, err = identity(a)
+	case avp.ResultCode:
+		r.ResultCode, err = unsigned32(a)
+	case avp.ExperimentalResult:
+		g, ok := a.Data.(*diam.GroupedAVP)
+		if !ok {
+			err = fmt.Errorf("AVP %d is not grouped: %T", a.Code, a.Data)
+			break
+		}
+		for _, c := range g.AVP {
+			if c.Code == avp.ExperimentalResultCode {
+				r.ExperimentalResultCode, err = unsigned32(c)
+			}
+		}
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+func typeError(a *diam.AVP) error {
+	return fmt.Errorf("unexpected data type of AVP %d: %T", a.Code, a.Data)
+}
+
+func unsigned32(a *diam.AVP) (uint32, error) {
+	if v, ok := a.Data.(datatype.Unsigned32); ok {
+		return uint32(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func identity(a *diam.AVP) (datatype.DiameterIdentity, error) {
+	if v, ok := a.Data.(datatype.DiameterIdentity); ok {
+		return v, nil
+	}
+	return "", typeError(a)
+}
+
+func str(a *diam.AVP) (string, error) {
+	switch v := a.Data.(type) {
+	case datatype.OctetString:
+		return string(v), nil
+	case datatype.UTF8String:
+		return string(v), nil
+	}
+	return "", typeError(a)
+}
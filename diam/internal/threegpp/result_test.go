@@ -0,0 +1,59 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package threegpp
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func testRequest() *diam.Message {
+	req := diam.NewRequest(diam.CreditControl, 4, dict.Default)
+	req.Header.CommandFlags |= diam.ProxiableFlag | diam.RetransmittedFlag
+	req.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("ocs;1"))
+	return req
+}
+
+func TestAnswer(t *testing.T) {
+	for _, want := range []Result{
+		{SessionID: "ocs;1", OriginHost: "ocs.example.com", OriginRealm: "example.com", ResultCode: diam.Success},
+		{SessionID: "ocs;1", OriginHost: "ocs.example.com", OriginRealm: "example.com", ResultCode: diam.UnableToDeliver},
+		{SessionID: "ocs;1", OriginHost: "ocs.example.com", OriginRealm: "example.com", ExperimentalResultCode: 5401},
+	} {
+		req := testRequest()
+		m := Answer(&want, req)
+		if err := diam.CheckAnswerFlags(req, m); err != nil {
+			t.Errorf("Result %d: %v", want.ResultCode, err)
+		}
+		if m.Header.CommandFlags&diam.RetransmittedFlag != 0 {
+			t.Errorf("Result %d: T bit copied to the answer", want.ResultCode)
+		}
+		b, err := m.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err = diam.ReadMessage(bytes.NewReader(b), dict.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.AVP[0].Code != avp.SessionID {
+			t.Errorf("Result %d: first AVP is %d, want Session-Id", want.ResultCode, m.AVP[0].Code)
+		}
+		var have Result
+		for _, a := range m.AVP {
+			if _, err := Parse(&have, a); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if have != want {
+			t.Errorf("Unexpected result. Want %#v, have %#v", want, have)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package journal
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// Values of the Accounting-Realtime-Required AVP, see RFC 6733 section
+// 9.8.7 for details.
+const (
+	DeliverAndGrant = 1 // Deny the service when records cannot be delivered
+	GrantAndStore   = 2 // Grant the service and store the records
+	GrantAndLose    = 3 // Grant the service and lose the records
+)
+
+// ErrNoPeer is returned by Buffer when there is no peer to deliver the
+// requests to.
+var ErrNoPeer = errors.New("no peer to deliver the request to")
+
+// Sender sends requests to a peer, e.g. *sm.Peer.
+type Sender interface {
+	Send(m *diam.Message) (int64, error)
+}
+
+// PeerFunc returns the peer requests are delivered to, and false when
+// no peer is available, e.g. none is OPEN in the realm of the requests.
+type PeerFunc func() (Sender, bool)
+
+// Buffer delivers accounting requests to the peer returned by its
+// PeerFunc, and buffers them in a Store while no peer is available.
+// Buffered requests are delivered in order when a peer is available
+// again, before any new request.
+//
+// What happens to requests that cannot be delivered depends on their
+// Accounting-Realtime-Required AVP, or on Realtime if they have none:
+// DELIVER_AND_GRANT requests fail with ErrNoPeer so the service can be
+// denied, GRANT_AND_STORE requests are buffered and GRANT_AND_LOSE
+// requests are dropped.
+//
+// Use a Buffer per destination realm, with the peers of the realm:
+//
+//	buf := journal.NewBuffer(store, func() (journal.Sender, bool) {
+//		p := sm.PeerFor("acct.example.com", 3)
+//		return p, p != nil
+//	})
+//	...
+//	if err := buf.Send(acr); err != nil {
+//		// Deny the service.
+//	}
+//	...
+//	// On sm.PeerUp events.
+//	buf.Flush()
+type Buffer struct {
+	// Realtime is the Accounting-Realtime-Required value of
+	// requests without the AVP. Uses GrantAndStore if zero.
+	Realtime int
+
+	// Dict is the dictionary used to decode the buffered requests.
+	// Uses dict.Default if unset.
+	Dict *dict.Parser
+
+	mu    sync.Mutex
+	store Store
+	peer  PeerFunc
+}
+
+// NewBuffer creates and initializes a new Buffer that delivers requests
+// to the peer returned by peer, and buffers them in the Store s. If s
+// is nil, a MemoryStore is used.
+func NewBuffer(s Store, peer PeerFunc) *Buffer {
+	if s == nil {
+		s = NewMemoryStore()
+	}
+	return &Buffer{store: s, peer: peer}
+}
+
+// Send delivers the request m to the peer after the buffered requests,
+// or buffers it when no peer is available or delivery fails, according
+// to its Accounting-Realtime-Required AVP.
+//
+// It returns ErrNoPeer for DELIVER_AND_GRANT requests that could not be
+// delivered, and nil for GRANT_AND_LOSE requests that were dropped.
+func (b *Buffer) Send(m *diam.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p, ok := b.peer(); ok {
+		if _, err := b.flush(p); err == nil {
+			if _, err = p.Send(m); err == nil {
+				return nil
+			}
+		}
+	}
+	switch b.realtime(m) {
+	case DeliverAndGrant:
+		return ErrNoPeer
+	case GrantAndLose:
+		return nil
+	}
+	data, err := m.Serialize()
+	if err != nil {
+		return err
+	}
+	return b.store.Put(m.Header.EndToEndID, data)
+}
+
+// Flush delivers the buffered requests to the peer, in order, and
+// returns the number of requests delivered. It stops at the first
+// request that cannot be delivered, which remains buffered with the
+// ones after it.
+//
+// Flush should be called when a peer becomes available, e.g. on
+// sm.PeerUp events, and after a restart when the Store is a FileStore.
+func (b *Buffer) Flush() (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.peer()
+	if !ok {
+		return 0, ErrNoPeer
+	}
+	return b.flush(p)
+}
+
+// Len returns the number of buffered requests.
+func (b *Buffer) Len() (int, error) {
+	entries, err := b.store.Load()
+	return len(entries), err
+}
+
+func (b *Buffer) flush(p Sender) (n int, err error) {
+	entries, err := b.store.Load()
+	if err != nil {
+		return 0, err
+	}
+	d := b.Dict
+	if d == nil {
+		d = dict.Default
+	}
+	for _, e := range entries {
+		m, err := diam.ReadMessage(bytes.NewReader(e.Data), d)
+		if err != nil {
+			return n, err
+		}
+		if _, err = p.Send(m); err != nil {
+			return n, err
+		}
+		if err = b.store.Delete(e.Key); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// realtime returns the Accounting-Realtime-Required value of m.
+func (b *Buffer) realtime(m *diam.Message) int {
+	for _, a := range m.AVP {
+		if a.Code != avp.AccountingRealtimeRequired {
+			continue
+		}
+		if v, ok := a.Data.(datatype.Enumerated); ok {
+			return int(v)
+		}
+		break
+	}
+	if b.Realtime == 0 {
+		return GrantAndStore
+	}
+	return b.Realtime
+}
@@ -0,0 +1,127 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package journal
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// testPeer records the requests sent to it while up.
+type testPeer struct {
+	up   bool
+	fail error
+	sent []*diam.Message
+}
+
+func (p *testPeer) Send(m *diam.Message) (int64, error) {
+	if p.fail != nil {
+		return 0, p.fail
+	}
+	p.sent = append(p.sent, m)
+	return int64(m.Len()), nil
+}
+
+func (p *testPeer) peer() (Sender, bool) {
+	return p, p.up
+}
+
+func newACR(realtime int32) *diam.Message {
+	m := diam.NewRequest(diam.Accounting, 3, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if realtime != 0 {
+		m.NewAVP(avp.AccountingRealtimeRequired, avp.Mbit, 0, datatype.Enumerated(realtime))
+	}
+	return m
+}
+
+func TestBuffer(t *testing.T) {
+	p := &testPeer{}
+	b := NewBuffer(nil, p.peer)
+	stored := []*diam.Message{newACR(0), newACR(GrantAndStore)}
+	for _, m := range stored {
+		if err := b.Send(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Send(newACR(DeliverAndGrant)); err != ErrNoPeer {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNoPeer, err)
+	}
+	if err := b.Send(newACR(GrantAndLose)); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := b.Len(); n != 2 {
+		t.Fatalf("Unexpected buffered requests. Want 2, have %d", n)
+	}
+	if _, err := b.Flush(); err != ErrNoPeer {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNoPeer, err)
+	}
+	// Failed deliveries keep the requests buffered.
+	p.up, p.fail = true, errors.New("write failed")
+	if _, err := b.Flush(); err != p.fail {
+		t.Fatalf("Unexpected error. Want %v, have %v", p.fail, err)
+	}
+	if err := b.Send(newACR(DeliverAndGrant)); err != ErrNoPeer {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNoPeer, err)
+	}
+	// Buffered requests are delivered before new ones.
+	p.fail = nil
+	m := newACR(DeliverAndGrant)
+	if err := b.Send(m); err != nil {
+		t.Fatal(err)
+	}
+	want := append(stored, m)
+	if len(p.sent) != len(want) {
+		t.Fatalf("Unexpected # of delivered requests. Want %d, have %d", len(want), len(p.sent))
+	}
+	for i, m := range want {
+		if p.sent[i].Header.EndToEndID != m.Header.EndToEndID {
+			t.Fatalf("Unexpected request %d delivered: %s", i, p.sent[i])
+		}
+	}
+	if n, _ := b.Len(); n != 0 {
+		t.Fatalf("Unexpected buffered requests. Want 0, have %d", n)
+	}
+}
+
+func TestBuffer_FileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "acct.journal")
+	s, err := OpenFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &testPeer{}
+	m := newACR(0)
+	if err = NewBuffer(s, p.peer).Send(m); err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+	// The buffered request survives the restart.
+	if s, err = OpenFile(name); err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	p.up = true
+	n, err := NewBuffer(s, p.peer).Flush()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || p.sent[0].Header.EndToEndID != m.Header.EndToEndID {
+		t.Fatalf("Unexpected delivered requests: %v", p.sent)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package journal provides a write-ahead journal of outgoing requests
+// that are awaiting answers, for guaranteed delivery of accounting
+// traffic across process restarts.
+//
+// Requests are recorded before being sent and removed from the journal
+// when their answer arrives. After a restart, the requests still in the
+// journal are retransmitted with the T (retransmitted) flag set.
+//
+// Example:
+//
+//	store, err := journal.OpenFile("/var/lib/myapp/acct.journal")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	j := journal.New(store)
+//	mux.Handle("ACA", j.Handler(handleACA))
+//	...
+//	// After connecting to the peer.
+//	j.Replay(conn)
+//	...
+//	j.WriteTo(conn, acr)
+//
+// A Buffer holds accounting requests while no peer is available, in
+// memory or in a FileStore, and delivers them in order when a peer is
+// available again, honoring their Accounting-Realtime-Required AVP.
+package journal
@@ -0,0 +1,133 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package journal
+
+import (
+	"io"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// terminationRequest is the TERMINATION_REQUEST value of the
+// CC-Request-Type AVP. See RFC 4006 section 8.3 for details.
+const terminationRequest = 3
+
+// FilterFunc decides whether a request should be recorded in the journal.
+type FilterFunc func(m *diam.Message) bool
+
+// Accounting is the default FilterFunc, and matches Accounting-Request
+// (ACR) and Credit-Control-Request (CCR) messages of type TERMINATION.
+func Accounting(m *diam.Message) bool {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return false
+	}
+	switch m.Header.CommandCode {
+	case diam.Accounting:
+		return true
+	case diam.CreditControl:
+		for _, a := range m.AVP {
+			if a.Code != avp.CCRequestType {
+				continue
+			}
+			v, ok := a.Data.(datatype.Enumerated)
+			return ok && v == terminationRequest
+		}
+	}
+	return false
+}
+
+// Journal records outgoing requests until their answer is received.
+type Journal struct {
+	Filter FilterFunc // Requests to record (uses Accounting if unset)
+	store  Store
+}
+
+// New creates and initializes a new Journal that persists requests
+// in the given Store.
+func New(s Store) *Journal {
+	return &Journal{store: s}
+}
+
+// Record stores the request m in the journal, if it matches the Filter.
+func (j *Journal) Record(m *diam.Message) error {
+	if !j.filter(m) {
+		return nil
+	}
+	b, err := m.Serialize()
+	if err != nil {
+		return err
+	}
+	return j.store.Put(m.Header.EndToEndID, b)
+}
+
+// Ack removes the request answered by m from the journal.
+func (j *Journal) Ack(m *diam.Message) error {
+	if m.Header.CommandFlags&diam.RequestFlag == diam.RequestFlag {
+		return nil
+	}
+	return j.store.Delete(m.Header.EndToEndID)
+}
+
+// WriteTo records the request m in the journal and then writes it to w.
+// The request is not written if it cannot be recorded.
+func (j *Journal) WriteTo(w io.Writer, m *diam.Message) (int64, error) {
+	if err := j.Record(m); err != nil {
+		return 0, err
+	}
+	return m.WriteTo(w)
+}
+
+// Replay writes all requests still in the journal to w, in the order
+// they were recorded, with the T (retransmitted) flag set. Requests
+// remain in the journal until their answer is acknowledged.
+//
+// Replay should be called once after a restart, when the connection
+// to the peer is established.
+func (j *Journal) Replay(w io.Writer) (n int, err error) {
+	entries, err := j.store.Load()
+	if err != nil {
+		return 0, err
+	}
+	for _, e := range entries {
+		if len(e.Data) < diam.HeaderLength {
+			continue
+		}
+		e.Data[4] |= diam.RetransmittedFlag
+		if _, err = w.Write(e.Data); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// Handler returns a diam.Handler that acknowledges answers in the
+// journal before calling h.
+//
+// Errors from the Store are reported to h, if it implements the
+// diam.ErrorReporter interface.
+func (j *Journal) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if err := j.Ack(m); err != nil {
+			if er, ok := h.(diam.ErrorReporter); ok {
+				er.Error(&diam.ErrorReport{
+					Conn:    c,
+					Message: m,
+					Error:   err,
+				})
+			}
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+func (j *Journal) filter(m *diam.Message) bool {
+	if j.Filter == nil {
+		return Accounting(m)
+	}
+	return j.Filter(m)
+}
@@ -0,0 +1,73 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package journal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func newCCR(requestType int32) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(requestType))
+	return m
+}
+
+func TestAccounting(t *testing.T) {
+	acr := diam.NewRequest(diam.Accounting, 3, dict.Default)
+	if !Accounting(acr) {
+		t.Fatal("ACR was not matched")
+	}
+	if !Accounting(newCCR(terminationRequest)) {
+		t.Fatal("CCR-T was not matched")
+	}
+	if Accounting(newCCR(1)) {
+		t.Fatal("CCR-I was matched")
+	}
+	if Accounting(acr.Answer(diam.Success)) {
+		t.Fatal("ACA was matched")
+	}
+}
+
+func TestJournal_Replay(t *testing.T) {
+	j := New(NewMemoryStore())
+	var w bytes.Buffer
+	ccrI, ccrT := newCCR(1), newCCR(terminationRequest)
+	for _, m := range []*diam.Message{ccrI, ccrT} {
+		if _, err := j.WriteTo(&w, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w.Reset()
+	n, err := j.Replay(&w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Unexpected # of replayed messages. Want 1, have %d", n)
+	}
+	m, err := diam.ReadMessage(&w, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Header.EndToEndID != ccrT.Header.EndToEndID {
+		t.Fatalf("Unexpected message replayed: %s", m)
+	}
+	if m.Header.CommandFlags&diam.RetransmittedFlag == 0 {
+		t.Fatal("Replayed message is missing the T flag")
+	}
+	// Acknowledge the answer.
+	j.Handler(diam.HandlerFunc(func(diam.Conn, *diam.Message) {})).ServeDIAM(nil, ccrT.Answer(diam.Success))
+	w.Reset()
+	if n, _ = j.Replay(&w); n != 0 {
+		t.Fatalf("Unexpected # of replayed messages. Want 0, have %d", n)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package journal
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// Store is the storage interface used by the Journal to persist
+// requests. Entries are keyed by the End-to-End Identifier of the
+// request, which is kept unchanged across retransmissions.
+type Store interface {
+	// Put stores the serialized request b under the given key.
+	Put(key uint32, b []byte) error
+
+	// Delete removes the entry under the given key, if any.
+	Delete(key uint32) error
+
+	// Load returns all entries currently in the store in the
+	// same order they were added.
+	Load() ([]Entry, error)
+}
+
+// Entry is a serialized request stored in the journal.
+type Entry struct {
+	Key  uint32 // End-to-End Identifier of the request
+	Data []byte // Serialized request
+}
+
+// MemoryStore is a Store backed by memory, for requests that need not
+// survive process restarts. It is safe for concurrent use.
+type MemoryStore struct {
+	mu      sync.Mutex
+	entries []Entry
+}
+
+// NewMemoryStore creates and initializes a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+// Put implements the Store interface.
+func (s *MemoryStore) Put(key uint32, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, Entry{Key: key, Data: b})
+	return nil
+}
+
+// Delete implements the Store interface.
+func (s *MemoryStore) Delete(key uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if e.Key == key {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Load implements the Store interface.
+func (s *MemoryStore) Load() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	entries := make([]Entry, len(s.entries))
+	copy(entries, s.entries)
+	return entries, nil
+}
+
+// Operations stored in the FileStore log.
+const (
+	opPut byte = iota + 1
+	opDelete
+)
+
+// ErrCorruptJournal is returned by FileStore when the journal
+// file contains a record that cannot be decoded. A truncated last
+// record is not an error, see OpenFile.
+var ErrCorruptJournal = errors.New("corrupt journal")
+
+// FileStore is a Store backed by an append-only log file. Every
+// operation is synced to disk before returning.
+//
+// The log is compacted when the file is opened, so it only grows
+// for as long as the process runs.
+type FileStore struct {
+	mu   sync.Mutex
+	name string
+	f    *os.File
+}
+
+// OpenFile opens or creates the journal file, and returns a FileStore
+// that appends to it.
+//
+// A truncated last record, left by a crash in the middle of an append,
+// is a torn write of a request that was never acknowledged: it is
+// dropped, and the file is compacted to the last complete record.
+func OpenFile(name string) (*FileStore, error) {
+	s := &FileStore{name: name}
+	entries, err := s.read()
+	if err != nil {
+		return nil, err
+	}
+	if err = s.compact(entries); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put implements the Store interface.
+func (s *FileStore) Put(key uint32, b []byte) error {
+	return s.append(opPut, key, b)
+}
+
+// Delete implements the Store interface.
+func (s *FileStore) Delete(key uint32) error {
+	return s.append(opDelete, key, nil)
+}
+
+// Load implements the Store interface.
+func (s *FileStore) Load() ([]Entry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.read()
+}
+
+// Close closes the journal file.
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
+
+func (s *FileStore) append(op byte, key uint32, b []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.f.Write(encodeRecord(op, key, b)); err != nil {
+		return err
+	}
+	return s.f.Sync()
+}
+
+// read replays the log file and returns the entries that have not
+// been deleted, in order. It stops at a truncated last record.
+func (s *FileStore) read() ([]Entry, error) {
+	f, err := os.Open(s.name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var entries []Entry
+	r := bufio.NewReader(f)
+	hdr := make([]byte, 9)
+	for {
+		if _, err = io.ReadFull(r, hdr); err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		key := binary.BigEndian.Uint32(hdr[1:5])
+		switch hdr[0] {
+		case opPut:
+			b := make([]byte, binary.BigEndian.Uint32(hdr[5:9]))
+			if _, err = io.ReadFull(r, b); err == io.ErrUnexpectedEOF || err == io.EOF {
+				return entries, nil
+			} else if err != nil {
+				return nil, err
+			}
+			entries = append(entries, Entry{Key: key, Data: b})
+		case opDelete:
+			for i, e := range entries {
+				if e.Key == key {
+					entries = append(entries[:i], entries[i+1:]...)
+					break
+				}
+			}
+		default:
+			return nil, ErrCorruptJournal
+		}
+	}
+	return entries, nil
+}
+
+// compact rewrites the log file with only the given entries, and
+// leaves it open for appending.
+func (s *FileStore) compact(entries []Entry) error {
+	tmp := s.name + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if _, err = f.Write(encodeRecord(opPut, e.Key, e.Data)); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err = f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	f.Close()
+	if err = os.Rename(tmp, s.name); err != nil {
+		return fmt.Errorf("failed to compact journal: %s", err)
+	}
+	s.f, err = os.OpenFile(s.name, os.O_WRONLY|os.O_APPEND, 0600)
+	return err
+}
+
+// encodeRecord returns a log record: op (1 byte), key (4 bytes),
+// length of b (4 bytes) followed by b.
+func encodeRecord(op byte, key uint32, b []byte) []byte {
+	rec := make([]byte, 9+len(b))
+	rec[0] = op
+	binary.BigEndian.PutUint32(rec[1:5], key)
+	binary.BigEndian.PutUint32(rec[5:9], uint32(len(b)))
+	copy(rec[9:], b)
+	return rec
+}
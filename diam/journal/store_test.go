@@ -0,0 +1,104 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package journal
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.journal")
+	s, err := OpenFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Put(1, []byte("one"))
+	s.Put(2, []byte("two"))
+	s.Put(3, []byte("three"))
+	s.Delete(2)
+	s.Delete(4)
+	s.Close()
+	// Reopen, as if the process had been restarted.
+	s, err = OpenFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	entries, err := s.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Entry{{1, []byte("one")}, {3, []byte("three")}}
+	if len(entries) != len(want) {
+		t.Fatalf("Unexpected # of entries. Want %d, have %d", len(want), len(entries))
+	}
+	for i, e := range entries {
+		if e.Key != want[i].Key || !bytes.Equal(e.Data, want[i].Data) {
+			t.Fatalf("Unexpected entry %d. Want %v, have %v", i, want[i], e)
+		}
+	}
+}
+
+func TestFileStore_Corrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.journal")
+	if err = ioutil.WriteFile(name, []byte{9, 0, 0, 0, 1, 0, 0, 0, 0}, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = OpenFile(name); err != ErrCorruptJournal {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrCorruptJournal, err)
+	}
+}
+
+func TestFileStore_TornWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "journal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "test.journal")
+	one := encodeRecord(opPut, 1, []byte("one"))
+	two := encodeRecord(opPut, 2, []byte("two"))
+	for _, cut := range []int{4, len(two)/2 + 5} {
+		// Crash in the middle of the second append.
+		b := append(append([]byte{}, one...), two[:cut]...)
+		if err = ioutil.WriteFile(name, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+		s, err := OpenFile(name)
+		if err != nil {
+			t.Fatalf("Cut at %d: %v", cut, err)
+		}
+		fi, err := os.Stat(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fi.Size() != int64(len(one)) {
+			t.Fatalf("Cut at %d: journal was not truncated to the last record, size %d", cut, fi.Size())
+		}
+		s.Put(3, []byte("three"))
+		entries, err := s.Load()
+		s.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 || entries[0].Key != 1 || entries[1].Key != 3 {
+			t.Fatalf("Cut at %d: unexpected entries: %v", cut, entries)
+		}
+	}
+}
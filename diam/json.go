@@ -0,0 +1,107 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// BinaryEncoding is the encoding of binary data in JSON strings.
+type BinaryEncoding int
+
+// Binary encodings.
+const (
+	Base64Encoding BinaryEncoding = iota // Standard base64, like encoding/json
+	HexEncoding                          // Lowercase hex, without 0x prefix
+)
+
+// JSONBinaryEncoding is the encoding of OctetString and unknown data
+// exported by Message.MarshalJSON, base64 by default.
+var JSONBinaryEncoding BinaryEncoding
+
+// jsonMessage is the JSON representation of a Message.
+type jsonMessage struct {
+	CommandCode   uint32 `json:"command_code"`
+	Flags         uint8  `json:"flags"`
+	ApplicationID uint32 `json:"application_id"`
+	HopByHopID    uint32 `json:"hop_by_hop_id"`
+	EndToEndID    uint32 `json:"end_to_end_id"`
+	AVP           []*AVP `json:"avp"`
+}
+
+// jsonAVP is the JSON representation of an AVP.
+type jsonAVP struct {
+	Code     uint32      `json:"code"`
+	Flags    uint8       `json:"flags"`
+	VendorID uint32      `json:"vendor_id,omitempty"`
+	Type     string      `json:"type"`
+	Value    interface{} `json:"value"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The values of
+// AVPs are exported in full, regardless of MaxValueLen: text and
+// numbers as JSON strings and numbers, addresses as strings, times in
+// RFC 3339 format, grouped AVPs as arrays of AVPs, and OctetString and
+// unknown data as strings encoded with JSONBinaryEncoding.
+//
+// Messages read in lazy decode mode are decoded entirely first.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	if err := m.DecodeAll(); err != nil {
+		return nil, err
+	}
+	avps := m.AVP
+	if avps == nil {
+		avps = []*AVP{}
+	}
+	return json.Marshal(&jsonMessage{
+		CommandCode:   m.Header.CommandCode,
+		Flags:         m.Header.CommandFlags,
+		ApplicationID: m.Header.ApplicationID,
+		HopByHopID:    m.Header.HopByHopID,
+		EndToEndID:    m.Header.EndToEndID,
+		AVP:           avps,
+	})
+}
+
+// MarshalJSON implements the json.Marshaler interface, see
+// Message.MarshalJSON.
+func (a *AVP) MarshalJSON() ([]byte, error) {
+	ja := &jsonAVP{Code: a.Code, Flags: a.Flags, VendorID: a.VendorID}
+	switch v := a.Data.(type) {
+	case nil:
+	case *GroupedAVP:
+		ja.Type, ja.Value = "Grouped", v.AVP
+	case datatype.OctetString:
+		ja.Value = jsonBinary([]byte(v))
+	case datatype.Address:
+		ja.Value = net.IP(v)
+	case datatype.IPv4:
+		ja.Value = net.IP(v)
+	case datatype.Time:
+		ja.Value = time.Time(v).UTC()
+	case datatype.Raw:
+		ja.Value = jsonBinary(v.Payload)
+	default:
+		ja.Value = v
+	}
+	if ja.Type == "" && a.Data != nil {
+		ja.Type = typeName(a.Data)
+	}
+	return json.Marshal(ja)
+}
+
+// jsonBinary returns the JSON value of binary data b, encoded with
+// JSONBinaryEncoding.
+func jsonBinary(b []byte) interface{} {
+	if JSONBinaryEncoding == HexEncoding {
+		return hex.EncodeToString(b)
+	}
+	return b
+}
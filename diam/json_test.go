@@ -0,0 +1,78 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestMessage_MarshalJSON(t *testing.T) {
+	defer func(n int) { diam.MaxValueLen = n }(diam.MaxValueLen)
+	diam.MaxValueLen = 4
+	payload := strings.Repeat("\x02", 1024)
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString(payload))
+	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP("10.0.0.1")))
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(1)),
+		},
+	})
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var jm struct {
+		CommandCode uint32 `json:"command_code"`
+		AVP         []struct {
+			Code  uint32          `json:"code"`
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+		} `json:"avp"`
+	}
+	if err = json.Unmarshal(b, &jm); err != nil {
+		t.Fatal(err)
+	}
+	if jm.CommandCode != diam.CreditControl || len(jm.AVP) != 4 {
+		t.Fatalf("Unexpected JSON: %s", b)
+	}
+	var session string
+	json.Unmarshal(jm.AVP[0].Value, &session)
+	var eap []byte
+	json.Unmarshal(jm.AVP[1].Value, &eap)
+	if session != "cli;1" || string(eap) != payload {
+		t.Fatalf("Unexpected values: %q, %d bytes", session, len(eap))
+	}
+	for i, want := range []string{`"10.0.0.1"`, `[{"code":450,"flags":64,"type":"Enumerated","value":1}]`} {
+		if v := string(jm.AVP[i+2].Value); v != want {
+			t.Fatalf("Unexpected value of AVP %d. Want %s, have %s", i+2, want, v)
+		}
+	}
+	if jm.AVP[1].Type != "OctetString" || jm.AVP[3].Type != "Grouped" {
+		t.Fatalf("Unexpected types: %s", b)
+	}
+}
+
+func TestMessage_MarshalJSONHex(t *testing.T) {
+	defer func(e diam.BinaryEncoding) { diam.JSONBinaryEncoding = e }(diam.JSONBinaryEncoding)
+	diam.JSONBinaryEncoding = diam.HexEncoding
+	a := diam.NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString("\x01\xab"))
+	b, err := json.Marshal(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"code":33,"flags":64,"type":"OctetString","value":"01ab"}`
+	if string(b) != want {
+		t.Fatalf("Unexpected JSON. Want %s, have %s", want, b)
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// WriteLanes schedules the writes of a connection in priority lanes,
+// so that e.g. watchdogs and session termination are never starved
+// behind a burst of bulk accounting traffic, see Server.WriteLanes.
+//
+// Messages are queued in their lane and written by one goroutine per
+// connection, which always writes the messages of lower lanes first.
+// Messages of the same lane are written in order.
+type WriteLanes struct {
+	// N is the number of lanes (default 2).
+	N int
+
+	// Lane returns the lane of the message with header h, from 0,
+	// the highest priority, to N-1. Lanes out of range are clamped.
+	// Optional, DefaultLane is used by default.
+	//
+	// Messages of connections with a Codec other than the default
+	// are always written in the last lane.
+	Lane func(h *Header) int
+}
+
+// DefaultLane puts the messages of the base protocol, like CER, DWR and
+// DPR, and the Abort-Session and Session-Termination commands in lane 0,
+// and the other messages in lane 1.
+func DefaultLane(h *Header) int {
+	switch h.CommandCode {
+	case CapabilitiesExchange, DeviceWatchdog, DisconnectPeer,
+		AbortSession, SessionTermination:
+		return 0
+	}
+	return 1
+}
+
+// laneWrite is a write queued in a lane.
+type laneWrite struct {
+	ctx   context.Context
+	b     []byte
+	state int           // laneQueued, laneWriting or laneAbandoned
+	n     int           // bytes written
+	err   error         // error of the write
+	done  chan struct{} // closed when written
+}
+
+const (
+	laneQueued = iota
+	laneWriting
+	laneAbandoned
+)
+
+// laneScheduler writes the messages of a connection by priority.
+type laneScheduler struct {
+	cfg   *WriteLanes
+	codec bool                                             // the connection uses another Codec
+	write func(ctx context.Context, b []byte) (int, error) // writes b now
+
+	mu     sync.Mutex // guards lanes and closed
+	lanes  [][]*laneWrite
+	closed bool
+	wake   chan struct{}
+}
+
+func newLaneScheduler(cfg *WriteLanes, codec bool, write func(context.Context, []byte) (int, error)) *laneScheduler {
+	n := cfg.N
+	if n <= 0 {
+		n = 2
+	}
+	return &laneScheduler{
+		cfg:   cfg,
+		codec: codec,
+		write: write,
+		lanes: make([][]*laneWrite, n),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// laneOf returns the lane of the serialized message b.
+func (s *laneScheduler) laneOf(b []byte) int {
+	last := len(s.lanes) - 1
+	if s.codec {
+		return last
+	}
+	h, err := DecodeHeader(b)
+	if err != nil {
+		return last
+	}
+	f := s.cfg.Lane
+	if f == nil {
+		f = DefaultLane
+	}
+	lane := f(h)
+	switch {
+	case lane < 0:
+		return 0
+	case lane > last:
+		return last
+	}
+	return lane
+}
+
+// writeContext queues b in its lane and waits until it is written, or
+// the context is done before it is.
+func (s *laneScheduler) writeContext(ctx context.Context, b []byte) (int, error) {
+	lw := &laneWrite{ctx: ctx, b: b, done: make(chan struct{})}
+	lane := s.laneOf(b)
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return s.write(ctx, b)
+	}
+	s.lanes[lane] = append(s.lanes[lane], lw)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	select {
+	case <-lw.done:
+		return lw.n, lw.err
+	case <-ctx.Done():
+	}
+	s.mu.Lock()
+	if lw.state == laneQueued {
+		lw.state = laneAbandoned
+		s.mu.Unlock()
+		return 0, &WriteError{Len: len(b), Err: ctx.Err()}
+	}
+	s.mu.Unlock()
+	<-lw.done
+	return lw.n, lw.err
+}
+
+// next returns the next write to run, or nil if the lanes are empty.
+func (s *laneScheduler) next() *laneWrite {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, lane := range s.lanes {
+		for len(lane) > 0 {
+			lw := lane[0]
+			lane[0] = nil
+			lane = lane[1:]
+			s.lanes[i] = lane
+			if lw.state == laneQueued {
+				lw.state = laneWriting
+				return lw
+			}
+		}
+	}
+	return nil
+}
+
+// run writes the queued messages until done is closed. Messages queued
+// by then are written without the scheduler.
+func (s *laneScheduler) run(done <-chan struct{}) {
+	for {
+		if lw := s.next(); lw != nil {
+			lw.n, lw.err = s.write(lw.ctx, lw.b)
+			close(lw.done)
+			continue
+		}
+		select {
+		case <-s.wake:
+		case <-done:
+			s.mu.Lock()
+			s.closed = true
+			s.mu.Unlock()
+			for lw := s.next(); lw != nil; lw = s.next() {
+				lw.n, lw.err = s.write(lw.ctx, lw.b)
+				close(lw.done)
+			}
+			return
+		}
+	}
+}
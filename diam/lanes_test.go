@@ -0,0 +1,92 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+func TestLaneScheduler(t *testing.T) {
+	var mu sync.Mutex
+	var written []uint32
+	started := make(chan struct{}, 1)
+	unblock := make(chan struct{})
+	write := func(ctx context.Context, b []byte) (int, error) {
+		h, _ := DecodeHeader(b)
+		if h.HopByHopID == 1 {
+			started <- struct{}{}
+			<-unblock
+		}
+		mu.Lock()
+		written = append(written, h.HopByHopID)
+		mu.Unlock()
+		return len(b), nil
+	}
+	done := make(chan struct{})
+	defer close(done)
+	s := newLaneScheduler(&WriteLanes{}, false, write)
+	go s.run(done)
+	send := func(cmd, hbh uint32, wg *sync.WaitGroup) {
+		m := NewMessage(cmd, RequestFlag, 0, hbh, hbh, nil)
+		b, _ := m.Serialize()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.writeContext(context.Background(), b); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	var wg sync.WaitGroup
+	// The first accounting request blocks the writer.
+	send(Accounting, 1, &wg)
+	<-started
+	for hbh := uint32(2); hbh <= 4; hbh++ {
+		send(Accounting, hbh, &wg)
+		time.Sleep(10 * time.Millisecond)
+	}
+	send(DeviceWatchdog, 5, &wg)
+	time.Sleep(10 * time.Millisecond)
+	close(unblock)
+	wg.Wait()
+	want := []uint32{1, 5, 2, 3, 4}
+	mu.Lock()
+	defer mu.Unlock()
+	for i := range want {
+		if i >= len(written) || written[i] != want[i] {
+			t.Fatalf("Unexpected order of writes. Want %v, have %v", want, written)
+		}
+	}
+}
+
+func TestLaneScheduler_Abandoned(t *testing.T) {
+	unblock := make(chan struct{})
+	write := func(ctx context.Context, b []byte) (int, error) {
+		<-unblock
+		return len(b), nil
+	}
+	done := make(chan struct{})
+	defer close(done)
+	s := newLaneScheduler(&WriteLanes{}, false, write)
+	go s.run(done)
+	b, _ := NewMessage(Accounting, RequestFlag, 0, 1, 1, nil).Serialize()
+	go s.writeContext(context.Background(), b)
+	time.Sleep(10 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := s.writeContext(ctx, b)
+	if werr, ok := err.(*WriteError); !ok || werr.Err != context.Canceled {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	close(unblock)
+	time.Sleep(10 * time.Millisecond)
+	if s.next() != nil {
+		t.Fatal("Unexpected queued write")
+	}
+}
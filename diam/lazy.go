@@ -0,0 +1,117 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import "github.com/ibrohimislam/go-diameter/diam/datatype"
+
+// indexAVPs decodes the headers of the AVPs in b, leaving their data
+// as datatype.Raw to be decoded on first access.
+//
+// In lazy decode mode, maxAVPs limits the number of top level AVPs
+// only.
+func (m *Message) indexAVPs(b []byte, maxAVPs int) error {
+	// The read buffer is recycled, keep a copy of the body.
+	b = append([]byte(nil), b...)
+	for n := 0; n < len(b); {
+		if maxAVPs > 0 && len(m.AVP) == maxAVPs {
+			return &AVPCountError{Max: maxAVPs}
+		}
+		a := &AVP{}
+		payload, err := a.decodeHeader(b[n:])
+		if err != nil {
+			return newAVPDecodeError(InvalidAVPLenght, a, nil, err)
+		}
+		a.Data = datatype.Raw{Flags: a.Flags, Payload: payload}
+		m.AVP = append(m.AVP, a)
+		n += a.Len()
+	}
+	return nil
+}
+
+// DecodeAll decodes the data of all AVPs of a Message read in lazy
+// decode mode. It does nothing for other messages.
+//
+// In lazy decode mode the data of AVPs is kept as datatype.Raw and only
+// decoded when the AVP is looked up with FindAVP, FindAVPs,
+// FindAVPsWithPath, FindAVPByName or Unmarshal. Grouped AVPs are
+// decoded when looking up AVPs that could be embedded in them, except
+// for FindAVP which returns top level AVPs first. Handlers that access
+// the AVP field of messages directly must call DecodeAll first.
+//
+// Errors of AVPs that fail to decode are returned on access.
+func (m *Message) DecodeAll() error {
+	if m.mode&decodeLazy == 0 {
+		return nil
+	}
+	for _, a := range m.AVP {
+		if err := m.decodeLazyAVP(a); err != nil {
+			return err
+		}
+	}
+	m.mode &^= decodeLazy
+	return nil
+}
+
+// decodeLazy decodes the top level AVPs with the given code of a Message
+// read in lazy decode mode, and also the grouped AVPs if grouped is true.
+func (m *Message) decodeLazy(code uint32, grouped bool) error {
+	if m.mode&decodeLazy == 0 {
+		return nil
+	}
+	for _, a := range m.AVP {
+		if _, ok := a.Data.(datatype.Raw); !ok {
+			continue
+		}
+		if a.Code != code && !(grouped && m.isGrouped(a)) {
+			continue
+		}
+		if err := m.decodeLazyAVP(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findLazy returns the first top level AVP with the given code and
+// vendor id of a Message read in lazy decode mode, decoding its data.
+func (m *Message) findLazy(code, vendorID uint32) (*AVP, error) {
+	if m.mode&decodeLazy == 0 {
+		return nil, nil
+	}
+	for _, a := range m.AVP {
+		if a.Code == code && a.VendorID == vendorID {
+			return a, m.decodeLazyAVP(a)
+		}
+	}
+	return nil, nil
+}
+
+func (m *Message) isGrouped(a *AVP) bool {
+	dictAVP, err := m.Dictionary().FindAVPWithVendor(
+		m.Header.ApplicationID,
+		a.Code,
+		a.VendorID,
+	)
+	return err == nil && dictAVP.Data.Type == datatype.GroupedType
+}
+
+// decodeLazyAVP decodes the data of a lazily decoded AVP in place.
+func (m *Message) decodeLazyAVP(a *AVP) error {
+	raw, ok := a.Data.(datatype.Raw)
+	if !ok {
+		return nil
+	}
+	err := a.decodePayload(
+		raw.Payload,
+		m.Header.ApplicationID,
+		m.Dictionary(),
+		m.mode&decodeRelay != 0,
+		nil,
+	)
+	if err != nil {
+		a.Data = raw
+	}
+	return err
+}
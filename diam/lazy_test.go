@@ -0,0 +1,83 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestReadMessage_Lazy(t *testing.T) {
+	m, err := readMessage(bytes.NewReader(testMessage), dict.Default, decodeLazy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range m.AVP {
+		if _, ok := a.Data.(datatype.Raw); !ok {
+			t.Fatalf("Unexpected decoded AVP: %s", a)
+		}
+	}
+	a, err := m.FindAVP(avp.OriginHost, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Data.Type() != datatype.DiameterIdentityType {
+		t.Fatalf("Unexpected data type. Want %d, have %d",
+			datatype.DiameterIdentityType, a.Data.Type())
+	}
+	if _, ok := m.AVP[1].Data.(datatype.Raw); !ok {
+		t.Fatalf("Unexpected decoded AVP: %s", m.AVP[1])
+	}
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, testMessage) {
+		t.Fatalf("Unexpected message.\nWant: %x\nHave: %x", testMessage, b)
+	}
+	avps, err := m.FindAVPsWithPath([]interface{}{avp.VendorSpecificApplicationID, avp.VendorID}, 0)
+	if err != nil || len(avps) != 1 {
+		t.Fatalf("Unexpected AVPs: %v, error: %v", avps, err)
+	}
+	if err = m.DecodeAll(); err != nil {
+		t.Fatal(err)
+	}
+	for _, a := range m.AVP {
+		if _, ok := a.Data.(datatype.Raw); ok {
+			t.Fatalf("Unexpected raw AVP: %s", a)
+		}
+	}
+}
+
+func TestReadMessage_LazyError(t *testing.T) {
+	m := NewRequest(CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	// Address with a short payload fails to decode.
+	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.OctetString("ab"))
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadMessage(bytes.NewReader(b), dict.Default); err == nil {
+		t.Fatal("Unexpected message decoded")
+	}
+	m, err = readMessage(bytes.NewReader(b), dict.Default, decodeLazy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.FindAVP(avp.OriginHost, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.FindAVP(avp.HostIPAddress, 0); err == nil {
+		t.Fatal("Unexpected Host-IP-Address decoded")
+	}
+	if err = m.DecodeAll(); err == nil {
+		t.Fatal("Unexpected message decoded")
+	}
+}
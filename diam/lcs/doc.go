@@ -0,0 +1,28 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package lcs provides helpers for the 3GPP location services
+// interfaces of the EPC: SLh between the GMLC and the HSS (TS 29.173),
+// and SLg between the GMLC and the MME or SGSN (TS 29.172).
+//
+// A GMLC first asks the HSS for the node serving the UE:
+//
+//	rir := &lcs.RoutingInfoRequest{
+//		Header: lcs.Header{
+//			SessionID:        "gmlc.example.com;1",
+//			OriginHost:       "gmlc.example.com",
+//			OriginRealm:      "example.com",
+//			DestinationRealm: "example.com",
+//		},
+//		IMSI: "001010123456789",
+//	}
+//	c.Write(rir.Message(dict.Default))
+//
+// Then asks the MME for the location of the UE, with a
+// ProvideLocationRequest sent to the MME of the RoutingInfoAnswer.
+// The Location-Estimate of the answer is a geographical shape of
+// TS 23.032, and its point is decoded by ParsePoint.
+//
+// The SLg and SLh dictionaries are part of dict.Default.
+package lcs
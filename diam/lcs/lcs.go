@@ -0,0 +1,339 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package lcs
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/internal/threegpp"
+)
+
+// Application ids of the SLg and SLh interfaces.
+const (
+	SLgApplicationID = 16777255
+	SLhApplicationID = 16777291
+)
+
+// VendorID is the vendor id of 3GPP, the vendor of the LCS AVPs.
+const VendorID = threegpp.VendorID
+
+const flagVM = avp.Vbit | avp.Mbit
+
+// Header holds the AVPs common to the requests of SLg and SLh.
+type Header struct {
+	SessionID        string
+	OriginHost       datatype.DiameterIdentity
+	OriginRealm      datatype.DiameterIdentity
+	DestinationRealm datatype.DiameterIdentity
+	DestinationHost  datatype.DiameterIdentity // Mandatory in SLg
+}
+
+// request returns a new request of the application appid, with the
+// AVPs of h.
+func (h *Header) request(cmd, appid uint32, d *dict.Parser) *diam.Message {
+	m := diam.NewRequest(cmd, appid, d)
+	m.Header.CommandFlags |= diam.ProxiableFlag
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(h.SessionID))
+	m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(VendorID)),
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(appid)),
+		},
+	})
+	m.NewAVP(avp.AuthSessionState, avp.Mbit, 0, datatype.Enumerated(1)) // NO_STATE_MAINTAINED
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, h.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, h.OriginRealm)
+	if h.DestinationHost != "" {
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, h.DestinationHost)
+	}
+	m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, h.DestinationRealm)
+	return m
+}
+
+// parse sets the field of h for the base AVP a, and returns false if
+// a is not one of them.
+func (h *Header) parse(a *diam.AVP) (bool, error) {
+	var err error
+	switch a.Code {
+	case avp.SessionID:
+		h.SessionID, err = str(a)
+	case avp.OriginHost:
+		h.OriginHost, err = identity(a)
+	case avp.OriginRealm:
+		h.OriginRealm, err = identity(a)
+	case avp.DestinationRealm:
+		h.DestinationRealm, err = identity(a)
+	case avp.DestinationHost:
+		h.DestinationHost, err = identity(a)
+	default:
+		return false, nil
+	}
+	return true, err
+}
+
+// Result holds the AVPs common to the answers of SLg and SLh.
+//
+// Either ResultCode or ExperimentalResultCode is sent, the latter with
+// the 3GPP vendor id, e.g. 5001 (DIAMETER_ERROR_USER_UNKNOWN).
+type Result = threegpp.Result
+
+// parseResult is threegpp.Parse, with the errors of this package.
+func parseResult(r *Result, a *diam.AVP) (bool, error) {
+	ok, err := threegpp.Parse(r, a)
+	if err != nil {
+		err = fmt.Errorf("lcs: %w", err)
+	}
+	return ok, err
+}
+
+// ServingNode is the Serving-Node AVP, or the Additional-Serving-Node
+// AVP: the MME, SGSN or MSC serving a UE. Zero fields are not sent.
+type ServingNode struct {
+	SGSNNumber          []byte
+	SGSNName            datatype.DiameterIdentity
+	SGSNRealm           datatype.DiameterIdentity
+	MMEName             datatype.DiameterIdentity
+	MMERealm            datatype.DiameterIdentity
+	MSCNumber           []byte
+	LCSCapabilitiesSets uint32
+	GMLCAddress         net.IP
+}
+
+func (n *ServingNode) avp(code uint32) *diam.AVP {
+	var l []*diam.AVP
+	if len(n.SGSNNumber) > 0 {
+		l = append(l, tgpp(avp.SGSNNumber, datatype.OctetString(n.SGSNNumber)))
+	}
+	if n.SGSNName != "" {
+		l = append(l, tgpp(avp.SGSNName, n.SGSNName))
+	}
+	if n.SGSNRealm != "" {
+		l = append(l, tgpp(avp.SGSNRealm, n.SGSNRealm))
+	}
+	if n.MMEName != "" {
+		l = append(l, tgpp(avp.MMEName, n.MMEName))
+	}
+	if n.MMERealm != "" {
+		l = append(l, tgpp(avp.MMERealm, n.MMERealm))
+	}
+	if len(n.MSCNumber) > 0 {
+		l = append(l, tgpp(avp.MSCNumber, datatype.OctetString(n.MSCNumber)))
+	}
+	if n.LCSCapabilitiesSets != 0 {
+		l = append(l, tgpp(avp.LCSCapabilitiesSets, datatype.Unsigned32(n.LCSCapabilitiesSets)))
+	}
+	if n.GMLCAddress != nil {
+		l = append(l, tgpp(avp.GMLCAddress, datatype.Address(n.GMLCAddress)))
+	}
+	return tgpp(code, &diam.GroupedAVP{AVP: l})
+}
+
+// AVP returns the Serving-Node AVP of n.
+func (n *ServingNode) AVP() *diam.AVP {
+	return n.avp(avp.ServingNode)
+}
+
+// ParseServingNode parses the Serving-Node or Additional-Serving-Node
+// AVP a.
+func ParseServingNode(a *diam.AVP) (*ServingNode, error) {
+	if a.Code != avp.ServingNode && a.Code != avp.AdditionalServingNode {
+		return nil, fmt.Errorf("lcs: unexpected AVP code %d, want %d", a.Code, avp.ServingNode)
+	}
+	l, err := avps(a, a.Code)
+	if err != nil {
+		return nil, err
+	}
+	n := &ServingNode{}
+	for _, a := range l {
+		if a.VendorID != VendorID {
+			continue
+		}
+		switch a.Code {
+		case avp.SGSNNumber:
+			n.SGSNNumber, err = octets(a)
+		case avp.SGSNName:
+			n.SGSNName, err = identity(a)
+		case avp.SGSNRealm:
+			n.SGSNRealm, err = identity(a)
+		case avp.MMEName:
+			n.MMEName, err = identity(a)
+		case avp.MMERealm:
+			n.MMERealm, err = identity(a)
+		case avp.MSCNumber:
+			n.MSCNumber, err = octets(a)
+		case avp.LCSCapabilitiesSets:
+			n.LCSCapabilitiesSets, err = unsigned32(a)
+		case avp.GMLCAddress:
+			n.GMLCAddress, err = address(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return n, nil
+}
+
+// Point is an ellipsoid point of TS 23.032, in degrees.
+type Point struct {
+	Latitude  float64 // Positive north of the equator
+	Longitude float64 // Positive east of Greenwich
+}
+
+// Shapes of TS 23.032 that start with an ellipsoid point.
+const (
+	shapePoint                    = 0
+	shapePointUncertaintyCircle   = 1
+	shapePointUncertaintyEllipse  = 3
+	shapePointAltitude            = 8
+	shapePointAltitudeUncertainty = 9
+	shapeArc                      = 10
+)
+
+// Encoding of the ellipsoid point: a 1 octet shape, followed by a 3
+// octets latitude with a sign bit, and a 3 octets longitude in two's
+// complement.
+const (
+	pointLen       = 7
+	latitudeSouth  = 1 << 23
+	latitudeScale  = 1 << 23
+	longitudeScale = 1 << 24
+	latitudeRange  = 90
+	longitudeRange = 360
+)
+
+// ErrUnsupportedShape is returned by ParsePoint for shapes that do not
+// start with a point, like polygons.
+var ErrUnsupportedShape = errors.New("lcs: unsupported shape")
+
+// Bytes returns the Ellipsoid Point shape of p, to be sent in the
+// Location-Estimate AVP.
+func (p Point) Bytes() []byte {
+	lat := uint32(math.Abs(p.Latitude) / latitudeRange * latitudeScale)
+	if lat >= latitudeScale {
+		lat = latitudeScale - 1
+	}
+	if p.Latitude < 0 {
+		lat |= latitudeSouth
+	}
+	lon := uint32(int32(math.Floor(p.Longitude/longitudeRange*longitudeScale))) & 0xffffff
+	return []byte{
+		shapePoint << 4,
+		byte(lat >> 16), byte(lat >> 8), byte(lat),
+		byte(lon >> 16), byte(lon >> 8), byte(lon),
+	}
+}
+
+// ParsePoint returns the point of the geographical shape b, as sent in
+// the Location-Estimate AVP. The uncertainty and altitude of the shape,
+// if any, are ignored.
+func ParsePoint(b []byte) (Point, error) {
+	var p Point
+	if len(b) < pointLen {
+		return p, fmt.Errorf("lcs: shape too short: %d bytes", len(b))
+	}
+	switch b[0] >> 4 {
+	case shapePoint, shapePointUncertaintyCircle, shapePointUncertaintyEllipse,
+		shapePointAltitude, shapePointAltitudeUncertainty, shapeArc:
+	default:
+		return p, ErrUnsupportedShape
+	}
+	lat := uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	p.Latitude = float64(lat&^latitudeSouth) * latitudeRange / latitudeScale
+	if lat&latitudeSouth != 0 {
+		p.Latitude = -p.Latitude
+	}
+	lon := int32(uint32(b[4])<<24|uint32(b[5])<<16|uint32(b[6])<<8) >> 8
+	p.Longitude = float64(lon) * longitudeRange / longitudeScale
+	return p, nil
+}
+
+// checkMessage returns an error if m is not a message of the command
+// cmd of the application appid.
+func checkMessage(m *diam.Message, cmd, appid uint32, request bool) error {
+	if m.Header.CommandCode != cmd || m.Header.ApplicationID != appid {
+		return fmt.Errorf("lcs: unexpected message %d of application %d",
+			m.Header.CommandCode, m.Header.ApplicationID)
+	}
+	if (m.Header.CommandFlags&diam.RequestFlag != 0) != request {
+		return fmt.Errorf("lcs: unexpected Request flag in message %d",
+			m.Header.CommandCode)
+	}
+	return nil
+}
+
+// tgpp returns a new 3GPP AVP.
+func tgpp(code uint32, data datatype.Type) *diam.AVP {
+	return diam.NewAVP(code, flagVM, VendorID, data)
+}
+
+// avps returns the AVPs of the grouped AVP a, which must have the
+// given code.
+func avps(a *diam.AVP, code uint32) ([]*diam.AVP, error) {
+	if a.Code != code {
+		return nil, fmt.Errorf("lcs: unexpected AVP code %d, want %d", a.Code, code)
+	}
+	g, ok := a.Data.(*diam.GroupedAVP)
+	if !ok {
+		return nil, fmt.Errorf("lcs: AVP %d is not grouped: %T", a.Code, a.Data)
+	}
+	return g.AVP, nil
+}
+
+func typeError(a *diam.AVP) error {
+	return fmt.Errorf("lcs: unexpected data type of AVP %d: %T", a.Code, a.Data)
+}
+
+func unsigned32(a *diam.AVP) (uint32, error) {
+	if v, ok := a.Data.(datatype.Unsigned32); ok {
+		return uint32(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func enumerated(a *diam.AVP) (int32, error) {
+	if v, ok := a.Data.(datatype.Enumerated); ok {
+		return int32(v), nil
+	}
+	return 0, typeError(a)
+}
+
+func identity(a *diam.AVP) (datatype.DiameterIdentity, error) {
+	if v, ok := a.Data.(datatype.DiameterIdentity); ok {
+		return v, nil
+	}
+	return "", typeError(a)
+}
+
+func address(a *diam.AVP) (net.IP, error) {
+	if v, ok := a.Data.(datatype.Address); ok {
+		return net.IP(v), nil
+	}
+	return nil, typeError(a)
+}
+
+func octets(a *diam.AVP) ([]byte, error) {
+	s, err := str(a)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(s), nil
+}
+
+func str(a *diam.AVP) (string, error) {
+	switch v := a.Data.(type) {
+	case datatype.OctetString:
+		return string(v), nil
+	case datatype.UTF8String:
+		return string(v), nil
+	}
+	return "", typeError(a)
+}
@@ -0,0 +1,147 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package lcs
+
+import (
+	"bytes"
+	"math"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func roundTrip(t *testing.T, m *diam.Message) *diam.Message {
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = diam.ReadMessage(bytes.NewReader(b), dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestRoutingInfo(t *testing.T) {
+	req := &RoutingInfoRequest{
+		Header: Header{
+			SessionID:        "gmlc;1",
+			OriginHost:       "gmlc.example.com",
+			OriginRealm:      "example.com",
+			DestinationRealm: "example.com",
+		},
+		IMSI:       "001010123456789",
+		GMLCNumber: []byte{0x91, 0x21, 0x43},
+	}
+	m := roundTrip(t, req.Message(dict.Default))
+	r, err := ParseRoutingInfoRequest(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, req) {
+		t.Fatalf("Unexpected RIR. Want %#v, have %#v", req, r)
+	}
+
+	ans := &RoutingInfoAnswer{
+		Result: Result{
+			SessionID:   "gmlc;1",
+			OriginHost:  "hss.example.com",
+			OriginRealm: "example.com",
+			ResultCode:  diam.Success,
+		},
+		IMSI: "001010123456789",
+		ServingNode: &ServingNode{
+			MMEName:             "mme1.example.com",
+			MMERealm:            "example.com",
+			LCSCapabilitiesSets: 1,
+		},
+		Additional:  []*ServingNode{{SGSNName: "sgsn1.example.com", SGSNNumber: []byte{0x91}}},
+		GMLCAddress: net.ParseIP("10.0.0.1").To4(),
+	}
+	a := roundTrip(t, ans.Answer(m))
+	r2, err := ParseRoutingInfoAnswer(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2.GMLCAddress = r2.GMLCAddress.To4()
+	if !reflect.DeepEqual(r2, ans) {
+		t.Fatalf("Unexpected RIA. Want %#v, have %#v", ans, r2)
+	}
+}
+
+func TestProvideLocation(t *testing.T) {
+	acc, class := uint32(20), BestEffort
+	req := &ProvideLocationRequest{
+		Header: Header{
+			SessionID:        "gmlc;2",
+			OriginHost:       "gmlc.example.com",
+			OriginRealm:      "example.com",
+			DestinationRealm: "example.com",
+			DestinationHost:  "mme1.example.com",
+		},
+		LocationType: CurrentOrLastKnownLocation,
+		IMSI:         "001010123456789",
+		ClientName:   "test",
+		ClientType:   ValueAddedServices,
+		QoS:          &QoS{Class: &class, HorizontalAccuracy: &acc},
+	}
+	m := roundTrip(t, req.Message(dict.Default))
+	r, err := ParseProvideLocationRequest(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r, req) {
+		t.Fatalf("Unexpected PLR. Want %#v, have %#v", req, r)
+	}
+
+	yes, age := true, uint32(0)
+	ans := &ProvideLocationAnswer{
+		Result: Result{
+			SessionID:   "gmlc;2",
+			OriginHost:  "mme1.example.com",
+			OriginRealm: "example.com",
+			ResultCode:  diam.Success,
+		},
+		LocationEstimate:  Point{Latitude: 52.5, Longitude: 13.4}.Bytes(),
+		AccuracyFulfilled: &yes,
+		Age:               &age,
+		ECGI:              []byte{0x00, 0xf1, 0x10, 0x00, 0x00, 0x01, 0x01},
+	}
+	r2, err := ParseProvideLocationAnswer(roundTrip(t, ans.Answer(m)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(r2, ans) {
+		t.Fatalf("Unexpected PLA. Want %#v, have %#v", ans, r2)
+	}
+}
+
+func TestPoint(t *testing.T) {
+	for _, want := range []Point{
+		{52.5, 13.4},
+		{-33.8688, 151.2093},
+		{40.7128, -74.006},
+		{-0.0001, -179.9999},
+	} {
+		b := want.Bytes()
+		if len(b) != 7 || b[0] != 0 {
+			t.Fatalf("Unexpected shape: %x", b)
+		}
+		p, err := ParsePoint(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The resolution is 90/2^23 and 360/2^24 degrees.
+		if math.Abs(p.Latitude-want.Latitude) > 1e-4 || math.Abs(p.Longitude-want.Longitude) > 1e-4 {
+			t.Fatalf("Unexpected point. Want %v, have %v", want, p)
+		}
+	}
+	if _, err := ParsePoint([]byte{5 << 4, 0, 0, 0, 0, 0, 0}); err != ErrUnsupportedShape {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrUnsupportedShape, err)
+	}
+}
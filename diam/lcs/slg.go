@@ -0,0 +1,329 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package lcs
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/internal/threegpp"
+)
+
+// LocationType is the value of the SLg-Location-Type AVP.
+type LocationType int32
+
+// SLg-Location-Type values.
+const (
+	CurrentLocation              LocationType = 0
+	CurrentOrLastKnownLocation   LocationType = 1
+	InitialLocation              LocationType = 2
+	ActivateDeferredLocation     LocationType = 3
+	CancelDeferredLocation       LocationType = 4
+	NotificationVerificationOnly LocationType = 5
+)
+
+// ClientType is the value of the LCS-Client-Type AVP.
+type ClientType int32
+
+// LCS-Client-Type values.
+const (
+	EmergencyServices       ClientType = 0
+	ValueAddedServices      ClientType = 1
+	PLMNOperatorServices    ClientType = 2
+	LawfulInterceptServices ClientType = 3
+)
+
+// QoSClass is the value of the LCS-QoS-Class AVP.
+type QoSClass int32
+
+// LCS-QoS-Class values.
+const (
+	Assured    QoSClass = 0
+	BestEffort QoSClass = 1
+)
+
+// ResponseTime is the value of the Response-Time AVP.
+type ResponseTime int32
+
+// Response-Time values.
+const (
+	LowDelay      ResponseTime = 0
+	DelayTolerant ResponseTime = 1
+)
+
+// QoS is the LCS-QoS AVP. Nil fields are not sent.
+type QoS struct {
+	Class              *QoSClass
+	HorizontalAccuracy *uint32 // Uncertainty code of TS 23.032, 0 to 127
+	VerticalAccuracy   *uint32
+	ResponseTime       *ResponseTime
+}
+
+// AVP returns the LCS-QoS AVP of q.
+func (q *QoS) AVP() *diam.AVP {
+	var l []*diam.AVP
+	if q.Class != nil {
+		l = append(l, tgpp(avp.LCSQoSClass, datatype.Enumerated(*q.Class)))
+	}
+	if q.HorizontalAccuracy != nil {
+		l = append(l, tgpp(avp.HorizontalAccuracy, datatype.Unsigned32(*q.HorizontalAccuracy)))
+	}
+	if q.VerticalAccuracy != nil {
+		l = append(l,
+			tgpp(avp.VerticalAccuracy, datatype.Unsigned32(*q.VerticalAccuracy)),
+			tgpp(avp.VerticalRequested, datatype.Enumerated(1)),
+		)
+	}
+	if q.ResponseTime != nil {
+		l = append(l, tgpp(avp.ResponseTime, datatype.Enumerated(*q.ResponseTime)))
+	}
+	return tgpp(avp.LCSQoS, &diam.GroupedAVP{AVP: l})
+}
+
+// ParseQoS parses the LCS-QoS AVP a.
+func ParseQoS(a *diam.AVP) (*QoS, error) {
+	l, err := avps(a, avp.LCSQoS)
+	if err != nil {
+		return nil, err
+	}
+	q := &QoS{}
+	for _, a := range l {
+		if a.VendorID != VendorID {
+			continue
+		}
+		var u uint32
+		var e int32
+		switch a.Code {
+		case avp.LCSQoSClass:
+			if e, err = enumerated(a); err == nil {
+				c := QoSClass(e)
+				q.Class = &c
+			}
+		case avp.HorizontalAccuracy:
+			if u, err = unsigned32(a); err == nil {
+				q.HorizontalAccuracy = &u
+			}
+		case avp.VerticalAccuracy:
+			if u, err = unsigned32(a); err == nil {
+				q.VerticalAccuracy = &u
+			}
+		case avp.ResponseTime:
+			if e, err = enumerated(a); err == nil {
+				t := ResponseTime(e)
+				q.ResponseTime = &t
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return q, nil
+}
+
+// ProvideLocationRequest is the SLg PLR, sent by the GMLC to the MME or
+// SGSN serving a UE, identified by its IMSI, MSISDN or IMEI.
+type ProvideLocationRequest struct {
+	Header
+	LocationType LocationType
+	IMSI         string // User-Name
+	MSISDN       []byte
+	IMEI         string
+	ClientName   string // LCS-EPS-Client-Name, a logical name
+	ClientType   ClientType
+	RequestorID  string // LCS-Requestor-Name, optional
+	Priority     *uint32
+	QoS          *QoS
+	Flags        uint32 // PLR-Flags
+}
+
+// Message returns the PLR message of r.
+func (r *ProvideLocationRequest) Message(d *dict.Parser) *diam.Message {
+	m := r.request(diam.ProvideLocation, SLgApplicationID, d)
+	m.AddAVP(tgpp(avp.SLgLocationType, datatype.Enumerated(r.LocationType)))
+	if r.IMSI != "" {
+		m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String(r.IMSI))
+	}
+	if len(r.MSISDN) > 0 {
+		m.AddAVP(tgpp(avp.MSISDN, datatype.OctetString(r.MSISDN)))
+	}
+	if r.IMEI != "" {
+		m.AddAVP(tgpp(avp.IMEI, datatype.UTF8String(r.IMEI)))
+	}
+	m.AddAVP(tgpp(avp.LCSEPSClientName, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			tgpp(avp.LCSNameString, datatype.UTF8String(r.ClientName)),
+			tgpp(avp.LCSFormatIndicator, datatype.Enumerated(0)), // LOGICAL_NAME
+		},
+	}))
+	m.AddAVP(tgpp(avp.LCSClientType, datatype.Enumerated(r.ClientType)))
+	if r.RequestorID != "" {
+		m.AddAVP(tgpp(avp.LCSRequestorName, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				tgpp(avp.LCSRequestorIDString, datatype.UTF8String(r.RequestorID)),
+				tgpp(avp.LCSFormatIndicator, datatype.Enumerated(0)),
+			},
+		}))
+	}
+	if r.Priority != nil {
+		m.AddAVP(tgpp(avp.LCSPriority, datatype.Unsigned32(*r.Priority)))
+	}
+	if r.QoS != nil {
+		m.AddAVP(r.QoS.AVP())
+	}
+	if r.Flags != 0 {
+		m.AddAVP(tgpp(avp.PLRFlags, datatype.Unsigned32(r.Flags)))
+	}
+	return m
+}
+
+// ParseProvideLocationRequest parses the SLg PLR m.
+func ParseProvideLocationRequest(m *diam.Message) (*ProvideLocationRequest, error) {
+	if err := checkMessage(m, diam.ProvideLocation, SLgApplicationID, true); err != nil {
+		return nil, err
+	}
+	r := &ProvideLocationRequest{}
+	for _, a := range m.AVP {
+		ok, err := r.Header.parse(a)
+		var v int32
+		switch {
+		case ok:
+		case a.Code == avp.UserName:
+			r.IMSI, err = str(a)
+		case a.VendorID != VendorID:
+		case a.Code == avp.SLgLocationType:
+			v, err = enumerated(a)
+			r.LocationType = LocationType(v)
+		case a.Code == avp.MSISDN:
+			r.MSISDN, err = octets(a)
+		case a.Code == avp.IMEI:
+			r.IMEI, err = str(a)
+		case a.Code == avp.LCSEPSClientName:
+			r.ClientName, err = nameString(a, avp.LCSNameString)
+		case a.Code == avp.LCSClientType:
+			v, err = enumerated(a)
+			r.ClientType = ClientType(v)
+		case a.Code == avp.LCSRequestorName:
+			r.RequestorID, err = nameString(a, avp.LCSRequestorIDString)
+		case a.Code == avp.LCSPriority:
+			var p uint32
+			if p, err = unsigned32(a); err == nil {
+				r.Priority = &p
+			}
+		case a.Code == avp.LCSQoS:
+			r.QoS, err = ParseQoS(a)
+		case a.Code == avp.PLRFlags:
+			r.Flags, err = unsigned32(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// nameString returns the string AVP code of the grouped AVP a, such as
+// the LCS-Name-String of the LCS-EPS-Client-Name AVP.
+func nameString(a *diam.AVP, code uint32) (string, error) {
+	l, err := avps(a, a.Code)
+	if err != nil {
+		return "", err
+	}
+	for _, c := range l {
+		if c.Code == code && c.VendorID == VendorID {
+			return str(c)
+		}
+	}
+	return "", nil
+}
+
+// ProvideLocationAnswer is the SLg PLA, sent by the MME or SGSN to the
+// GMLC. Zero fields are not sent.
+type ProvideLocationAnswer struct {
+	Result
+	LocationEstimate  []byte // Shape of TS 23.032, see Point
+	AccuracyFulfilled *bool
+	Age               *uint32 // Age-Of-Location-Estimate, in minutes
+	VelocityEstimate  []byte
+	ECGI              []byte
+	CellGlobalID      []byte
+	ServingNode       *ServingNode
+	Flags             uint32 // PLA-Flags
+}
+
+// Answer returns the PLA message of a, in answer to the PLR req.
+func (a *ProvideLocationAnswer) Answer(req *diam.Message) *diam.Message {
+	m := threegpp.Answer(&a.Result, req)
+	if len(a.LocationEstimate) > 0 {
+		m.AddAVP(tgpp(avp.LocationEstimate, datatype.OctetString(a.LocationEstimate)))
+	}
+	if a.AccuracyFulfilled != nil {
+		v := datatype.Enumerated(1) // REQUESTED_ACCURACY_NOT_FULFILLED
+		if *a.AccuracyFulfilled {
+			v = 0
+		}
+		m.AddAVP(tgpp(avp.AccuracyFulfilmentIndicator, v))
+	}
+	if a.Age != nil {
+		m.AddAVP(tgpp(avp.AgeOfLocationEstimate, datatype.Unsigned32(*a.Age)))
+	}
+	if len(a.VelocityEstimate) > 0 {
+		m.AddAVP(tgpp(avp.VelocityEstimate, datatype.OctetString(a.VelocityEstimate)))
+	}
+	if len(a.ECGI) > 0 {
+		m.AddAVP(tgpp(avp.ECGI, datatype.OctetString(a.ECGI)))
+	}
+	if len(a.CellGlobalID) > 0 {
+		m.AddAVP(tgpp(avp.CellGlobalIdentity, datatype.OctetString(a.CellGlobalID)))
+	}
+	if a.ServingNode != nil {
+		m.AddAVP(a.ServingNode.AVP())
+	}
+	if a.Flags != 0 {
+		m.AddAVP(tgpp(avp.PLAFlags, datatype.Unsigned32(a.Flags)))
+	}
+	return m
+}
+
+// ParseProvideLocationAnswer parses the SLg PLA m.
+func ParseProvideLocationAnswer(m *diam.Message) (*ProvideLocationAnswer, error) {
+	if err := checkMessage(m, diam.ProvideLocation, SLgApplicationID, false); err != nil {
+		return nil, err
+	}
+	r := &ProvideLocationAnswer{}
+	for _, a := range m.AVP {
+		ok, err := parseResult(&r.Result, a)
+		switch {
+		case ok, a.VendorID != VendorID:
+		case a.Code == avp.LocationEstimate:
+			r.LocationEstimate, err = octets(a)
+		case a.Code == avp.AccuracyFulfilmentIndicator:
+			var v int32
+			if v, err = enumerated(a); err == nil {
+				b := v == 0
+				r.AccuracyFulfilled = &b
+			}
+		case a.Code == avp.AgeOfLocationEstimate:
+			var v uint32
+			if v, err = unsigned32(a); err == nil {
+				r.Age = &v
+			}
+		case a.Code == avp.VelocityEstimate:
+			r.VelocityEstimate, err = octets(a)
+		case a.Code == avp.ECGI:
+			r.ECGI, err = octets(a)
+		case a.Code == avp.CellGlobalIdentity:
+			r.CellGlobalID, err = octets(a)
+		case a.Code == avp.ServingNode:
+			r.ServingNode, err = ParseServingNode(a)
+		case a.Code == avp.PLAFlags:
+			r.Flags, err = unsigned32(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
@@ -0,0 +1,140 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package lcs
+
+import (
+	"net"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/internal/threegpp"
+)
+
+// RoutingInfoRequest is the SLh RIR, sent by the GMLC to the HSS to
+// find the nodes serving a UE, identified by its IMSI or MSISDN.
+type RoutingInfoRequest struct {
+	Header
+	IMSI       string // User-Name
+	MSISDN     []byte
+	GMLCNumber []byte // Optional
+}
+
+// Message returns the RIR message of r.
+func (r *RoutingInfoRequest) Message(d *dict.Parser) *diam.Message {
+	m := r.request(diam.LCSRoutingInfo, SLhApplicationID, d)
+	if r.IMSI != "" {
+		m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String(r.IMSI))
+	}
+	if len(r.MSISDN) > 0 {
+		m.AddAVP(tgpp(avp.MSISDN, datatype.OctetString(r.MSISDN)))
+	}
+	if len(r.GMLCNumber) > 0 {
+		m.AddAVP(tgpp(avp.GMLCNumber, datatype.OctetString(r.GMLCNumber)))
+	}
+	return m
+}
+
+// ParseRoutingInfoRequest parses the SLh RIR m.
+func ParseRoutingInfoRequest(m *diam.Message) (*RoutingInfoRequest, error) {
+	if err := checkMessage(m, diam.LCSRoutingInfo, SLhApplicationID, true); err != nil {
+		return nil, err
+	}
+	r := &RoutingInfoRequest{}
+	for _, a := range m.AVP {
+		ok, err := r.Header.parse(a)
+		switch {
+		case ok:
+		case a.Code == avp.UserName:
+			r.IMSI, err = str(a)
+		case a.VendorID != VendorID:
+		case a.Code == avp.MSISDN:
+			r.MSISDN, err = octets(a)
+		case a.Code == avp.GMLCNumber:
+			r.GMLCNumber, err = octets(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// RoutingInfoAnswer is the SLh RIA, sent by the HSS to the GMLC.
+// Zero fields are not sent.
+type RoutingInfoAnswer struct {
+	Result
+	IMSI        string // User-Name
+	MSISDN      []byte
+	LMSI        []byte
+	ServingNode *ServingNode
+	Additional  []*ServingNode // Additional-Serving-Node
+	GMLCAddress net.IP
+	PPRAddress  net.IP
+}
+
+// Answer returns the RIA message of a, in answer to the RIR req.
+func (a *RoutingInfoAnswer) Answer(req *diam.Message) *diam.Message {
+	m := threegpp.Answer(&a.Result, req)
+	if a.IMSI != "" {
+		m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String(a.IMSI))
+	}
+	if len(a.MSISDN) > 0 {
+		m.AddAVP(tgpp(avp.MSISDN, datatype.OctetString(a.MSISDN)))
+	}
+	if len(a.LMSI) > 0 {
+		m.AddAVP(tgpp(avp.LMSI, datatype.OctetString(a.LMSI)))
+	}
+	if a.ServingNode != nil {
+		m.AddAVP(a.ServingNode.AVP())
+	}
+	for _, n := range a.Additional {
+		m.AddAVP(n.avp(avp.AdditionalServingNode))
+	}
+	if a.GMLCAddress != nil {
+		m.AddAVP(tgpp(avp.GMLCAddress, datatype.Address(a.GMLCAddress)))
+	}
+	if a.PPRAddress != nil {
+		m.AddAVP(tgpp(avp.PPRAddress, datatype.Address(a.PPRAddress)))
+	}
+	return m
+}
+
+// ParseRoutingInfoAnswer parses the SLh RIA m.
+func ParseRoutingInfoAnswer(m *diam.Message) (*RoutingInfoAnswer, error) {
+	if err := checkMessage(m, diam.LCSRoutingInfo, SLhApplicationID, false); err != nil {
+		return nil, err
+	}
+	r := &RoutingInfoAnswer{}
+	for _, a := range m.AVP {
+		ok, err := parseResult(&r.Result, a)
+		switch {
+		case ok:
+		case a.Code == avp.UserName:
+			r.IMSI, err = str(a)
+		case a.VendorID != VendorID:
+		case a.Code == avp.MSISDN:
+			r.MSISDN, err = octets(a)
+		case a.Code == avp.LMSI:
+			r.LMSI, err = octets(a)
+		case a.Code == avp.ServingNode:
+			r.ServingNode, err = ParseServingNode(a)
+		case a.Code == avp.AdditionalServingNode:
+			var n *ServingNode
+			if n, err = ParseServingNode(a); err == nil {
+				r.Additional = append(r.Additional, n)
+			}
+		case a.Code == avp.GMLCAddress:
+			r.GMLCAddress, err = address(a)
+		case a.Code == avp.PPRAddress:
+			r.PPRAddress, err = address(a)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
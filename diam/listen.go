@@ -0,0 +1,171 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// ListenFunc announces on the local network address addr of a
+// transport, e.g. tcp or sctp.
+type ListenFunc func(addr string) (net.Listener, error)
+
+// DialFunc connects to the network address addr of a transport, e.g.
+// tcp or sctp.
+type DialFunc func(addr string) (net.Conn, error)
+
+var (
+	networksMu sync.RWMutex
+	networks   = map[string]ListenFunc{
+		"tcp":  tcpListener("tcp"),
+		"tcp4": tcpListener("tcp4"),
+		"tcp6": tcpListener("tcp6"),
+	}
+	dialers = map[string]DialFunc{
+		"tcp":  tcpDialer("tcp"),
+		"tcp4": tcpDialer("tcp4"),
+		"tcp6": tcpDialer("tcp6"),
+	}
+	// Transports that can be canceled while connecting.
+	contextDialers = map[string]bool{"tcp": true, "tcp4": true, "tcp6": true}
+)
+
+func tcpListener(network string) ListenFunc {
+	return func(addr string) (net.Listener, error) {
+		return net.Listen(network, addr)
+	}
+}
+
+func tcpDialer(network string) DialFunc {
+	return func(addr string) (net.Conn, error) {
+		return net.Dial(network, addr)
+	}
+}
+
+// RegisterNetwork makes a transport available to Listen by the
+// provided name. Transports not supported by the net package, like
+// SCTP, can be registered by other packages.
+//
+// If RegisterNetwork is called twice with the same name the last
+// ListenFunc is used.
+func RegisterNetwork(network string, fn ListenFunc) {
+	networksMu.Lock()
+	networks[network] = fn
+	networksMu.Unlock()
+}
+
+// RegisterDialer makes a transport available to Server.Dial and
+// DialNetwork by the provided name, like RegisterNetwork does for
+// Listen.
+//
+// If RegisterDialer is called twice with the same name the last
+// DialFunc is used.
+func RegisterDialer(network string, fn DialFunc) {
+	networksMu.Lock()
+	dialers[network] = fn
+	delete(contextDialers, network)
+	networksMu.Unlock()
+}
+
+// dialNetwork connects to addr using the transport registered as
+// network, until ctx is done. If laddr is set, the connection is bound
+// to that local address, which only TCP transports support.
+func dialNetwork(ctx context.Context, network, addr, laddr string) (net.Conn, error) {
+	networksMu.RLock()
+	fn, ok := dialers[network]
+	cancelable := contextDialers[network]
+	networksMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("diam: unknown network %q", network)
+	}
+	if len(laddr) != 0 && !cancelable {
+		return nil, fmt.Errorf("diam: network %q does not support local addresses", network)
+	}
+	if cancelable {
+		var d net.Dialer
+		if len(laddr) != 0 {
+			a, err := net.ResolveTCPAddr(network, laddr)
+			if err != nil {
+				return nil, err
+			}
+			d.LocalAddr = a
+		}
+		c, err := d.DialContext(ctx, network, addr)
+		if err != nil && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return c, err
+	}
+	return fn.DialContext(ctx, network, addr)
+}
+
+// Dialer establishes the connections of clients, e.g. through a SOCKS
+// proxy or a tunnel, see Server.Dialer. It is implemented by *net.Dialer
+// and DialFunc.
+type Dialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// DialContext implements the Dialer interface. It calls f with addr,
+// ignoring network, and gives up when ctx is done: connections
+// established after ctx is done are closed.
+func (f DialFunc) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if ctx.Done() == nil {
+		return f(addr)
+	}
+	type result struct {
+		c   net.Conn
+		err error
+	}
+	rc := make(chan result, 1)
+	go func() {
+		c, err := f(addr)
+		rc <- result{c, err}
+	}()
+	select {
+	case r := <-rc:
+		return r.c, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-rc; r.c != nil {
+				r.c.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Listen announces on the local network address addr using the
+// transport registered as network. If config is not nil the listener
+// accepts TLS connections.
+//
+// If addr is blank, ":3868" is used.
+//
+// Listeners of different transports can be served by the same
+// Server, see Server.ServeListeners.
+func Listen(network, addr string, config *tls.Config) (net.Listener, error) {
+	networksMu.RLock()
+	fn, ok := networks[network]
+	networksMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("diam: unknown network %q", network)
+	}
+	if len(addr) == 0 {
+		addr = ":3868"
+	}
+	l, err := fn(addr)
+	if err != nil {
+		return nil, err
+	}
+	if config != nil {
+		l = tls.NewListener(l, config)
+	}
+	return l, nil
+}
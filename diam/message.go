@@ -9,9 +9,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"math/rand"
+	"strings"
 	"sync"
-	"time"
 
 	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
@@ -21,10 +20,6 @@ import (
 // MessageBufferLength is the default buffer length for Diameter messages.
 var MessageBufferLength = 1 << 10
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 // Message represents a Diameter message.
 type Message struct {
 	Header *Header
@@ -32,58 +27,145 @@ type Message struct {
 
 	// dictionary parser object used to encode and decode AVPs.
 	dictionary *dict.Parser
+
+	// mode is the decode mode the message was read with.
+	mode decodeMode
+
+	// meta is the metadata of messages read from a connection.
+	meta *Meta
+
+	// arena holds the AVPs of messages decoded in arena mode.
+	arena *avpArena
+}
+
+// maxReadBuffer is the maximum size of the read buffer of connections.
+// Larger messages are read into buffers of their own.
+const maxReadBuffer = 64 << 10
+
+// readBuffer is the buffer messages are read into before decoding.
+// Connections own one buffer, grown to fit the largest message read,
+// while ReadMessage uses pooled buffers.
+//
+// Decoded AVP data must not reference the buffer.
+type readBuffer struct {
+	b     []byte
+	grows uint64 // times the buffer grew
 }
 
 var readerBufferPool sync.Pool
 
-func newReaderBuffer() *bytes.Buffer {
+func newReaderBuffer() *readBuffer {
 	if v := readerBufferPool.Get(); v != nil {
-		return v.(*bytes.Buffer)
+		return v.(*readBuffer)
 	}
-	return bytes.NewBuffer(make([]byte, MessageBufferLength))
+	return &readBuffer{b: make([]byte, MessageBufferLength)}
 }
 
-func putReaderBuffer(b *bytes.Buffer) {
-	if cap(b.Bytes()) == MessageBufferLength {
-		b.Reset()
-		readerBufferPool.Put(b)
+func putReaderBuffer(rb *readBuffer) {
+	if cap(rb.b) == MessageBufferLength {
+		readerBufferPool.Put(rb)
 	}
 }
 
-func readerBufferSlice(buf *bytes.Buffer, l int) []byte {
-	b := buf.Bytes()
-	if l <= MessageBufferLength && cap(b) >= MessageBufferLength {
-		return b[:l]
+// slice returns a slice of l bytes of the buffer, growing it if needed.
+func (rb *readBuffer) slice(l int) []byte {
+	if l <= cap(rb.b) {
+		return rb.b[:l]
 	}
-	return make([]byte, l)
+	if l > maxReadBuffer {
+		return make([]byte, l)
+	}
+	rb.b = make([]byte, l)
+	rb.grows++
+	return rb.b
 }
 
 // ReadMessage reads a binary stream from the reader and uses the given
 // dictionary to parse it.
 func ReadMessage(reader io.Reader, dictionary *dict.Parser) (*Message, error) {
-	fmt.Printf("message received.\n")
+	m, err := readMessage(reader, dictionary, 0)
+	if de, ok := err.(*decodeError); ok {
+		return nil, de.err
+	}
+	return m, err
+}
+
+// decodeMode controls how readMessage decodes messages.
+type decodeMode uint8
+
+const (
+	// decodeRelay accepts messages of commands unknown to the
+	// dictionary, and decodes AVPs unknown to the dictionary as
+	// datatype.Raw.
+	decodeRelay decodeMode = 1 << iota
 
+	// decodeLazy only decodes the AVP headers, and leaves the AVP
+	// data as datatype.Raw until the AVP is looked up.
+	decodeLazy
+
+	// decodeArena allocates the AVPs in an arena owned by the
+	// message, see Message.Release.
+	decodeArena
+)
+
+// decodeError is returned by readMessage for messages read entirely
+// from the reader that cannot be decoded. The reader is positioned at
+// the next message.
+type decodeError struct {
+	err     error
+	unknown bool    // command unknown to the dictionary
+	header  *Header // header of the message
+}
+
+func (e *decodeError) Error() string {
+	return e.err.Error()
+}
+
+// readMessage is like ReadMessage, using the given decode mode.
+func readMessage(reader io.Reader, dictionary *dict.Parser, mode decodeMode) (*Message, error) {
 	buf := newReaderBuffer()
 	defer putReaderBuffer(buf)
+	return readMessageBuffer(reader, dictionary, mode, buf, 0)
+}
+
+// readMessageBuffer is like readMessage, reading into the buffer buf,
+// and limiting the number of AVPs to maxAVPs unless zero.
+func readMessageBuffer(reader io.Reader, dictionary *dict.Parser, mode decodeMode, buf *readBuffer, maxAVPs int) (*Message, error) {
+	fmt.Printf("message received.\n")
 
 	fmt.Printf("parsing header...\n")
 	m := &Message{dictionary: dictionary}
+	m.mode = mode
 	cmd, err := m.readHeader(reader, buf)
-
-	fmt.Printf("decoding Message[%d]...\n", cmd.Code)
 	if err != nil {
-		return nil, err
+		if m.Header == nil || m.Header.MessageLength < HeaderLength {
+			return nil, err
+		}
+		// Skip the body of the unknown command.
+		b := buf.slice(int(m.Header.MessageLength - HeaderLength))
+		if _, rerr := io.ReadFull(reader, b); rerr != nil {
+			return nil, rerr
+		}
+		return nil, &decodeError{err: err, unknown: true, header: m.Header}
 	}
-	if err = m.readBody(reader, buf, cmd); err != nil {
+	fmt.Printf("decoding Message[%d]...\n", m.Header.CommandCode)
+	if err = m.readBody(reader, buf, cmd, maxAVPs); err != nil {
+		if de, ok := err.(*decodeError); ok {
+			de.header = m.Header
+		}
 		return nil, err
 	}
 	return m, nil
 }
 
-func (m *Message) readHeader(r io.Reader, buf *bytes.Buffer) (cmd *dict.Command, err error) {
-	b := buf.Bytes()[:HeaderLength]
+func (m *Message) readHeader(r io.Reader, buf *readBuffer) (cmd *dict.Command, err error) {
+	b := buf.slice(HeaderLength)
 	fmt.Printf("read full...\n")
 	if _, err = io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			// Clean close between messages.
+			return nil, io.EOF
+		}
 		return nil, io.ErrUnexpectedEOF
 	}
 	fmt.Printf("header: %#v\n", b)
@@ -91,36 +173,51 @@ func (m *Message) readHeader(r io.Reader, buf *bytes.Buffer) (cmd *dict.Command,
 	if err != nil {
 		return nil, err
 	}
+	if m.Header.MessageLength < HeaderLength {
+		return nil, fmt.Errorf("Invalid message length: %d", m.Header.MessageLength)
+	}
 	fmt.Printf("find command on dictionary...\n")
 	cmd, err = m.Dictionary().FindCommand(
 		m.Header.ApplicationID,
 		m.Header.CommandCode,
 	)
 	if err != nil {
+		if m.mode&decodeRelay != 0 {
+			return nil, nil
+		}
 		return nil, err
 	}
 	fmt.Printf("command found on dictionary...\n")
 	return cmd, nil
 }
 
-func (m *Message) readBody(r io.Reader, buf *bytes.Buffer, cmd *dict.Command) error {
-	b := readerBufferSlice(buf, int(m.Header.MessageLength-HeaderLength))
+func (m *Message) readBody(r io.Reader, buf *readBuffer, cmd *dict.Command, maxAVPs int) error {
+	b := buf.slice(int(m.Header.MessageLength - HeaderLength))
 	_, err := io.ReadFull(r, b)
 
 	if err != nil {
 		return err
 	}
+	if cmd == nil {
+		// Unknown command in relay mode.
+		if err = m.decodeAVPs(b, maxAVPs); err != nil {
+			return &decodeError{err: err}
+		}
+		return nil
+	}
 	n := m.maxAVPsFor(cmd)
 	if n == 0 {
 		// TODO: fail to load the dictionary instead.
-		return fmt.Errorf(
+		return &decodeError{err: fmt.Errorf(
 			"Command %s (%d) has no AVPs defined in the dictionary.",
-			cmd.Name, cmd.Code)
+			cmd.Name, cmd.Code), unknown: true}
 	}
-	// Pre-allocate max # of AVPs for this message.
-	m.AVP = make([]*AVP, 0, n)
-	if err = m.decodeAVPs(b); err != nil {
-		return err
+	if m.mode&decodeArena == 0 {
+		// Pre-allocate max # of AVPs for this message.
+		m.AVP = make([]*AVP, 0, n)
+	}
+	if err = m.decodeAVPs(b, maxAVPs); err != nil {
+		return &decodeError{err: err}
 	}
 	return nil
 }
@@ -132,27 +229,53 @@ func (m *Message) maxAVPsFor(cmd *dict.Command) int {
 	return len(cmd.Answer.Rule)
 }
 
-func (m *Message) decodeAVPs(b []byte) error {
+// decodeAVPs decodes the AVPs in b, failing with an *AVPCountError
+// if there are more than maxAVPs AVPs, unless maxAVPs is zero.
+func (m *Message) decodeAVPs(b []byte, maxAVPs int) error {
+	if m.mode&decodeLazy != 0 {
+		return m.indexAVPs(b, maxAVPs)
+	}
 	var a *AVP
 	var err error
+	var d *avpDecoder
+	if m.mode&decodeArena != 0 || maxAVPs > 0 {
+		d = &avpDecoder{max: maxAVPs}
+		if m.mode&decodeArena != 0 {
+			d.arena = newArena(len(b))
+			m.arena = d.arena
+		}
+	}
+	ar := d.allocator()
+	start := ar.mark()
+	relay := m.mode&decodeRelay != 0
 	for n := 0; n < len(b); {
-		a, err = DecodeAVP(b[n:], m.Header.ApplicationID, m.Dictionary())
+		a, err = decodeAVP(b[n:], m.Header.ApplicationID, m.Dictionary(), relay, d)
+		if cerr, ok := err.(*AVPCountError); ok {
+			return cerr
+		}
+		if _, ok := err.(*AVPDecodeError); ok {
+			return err
+		}
 		if err != nil {
 			return fmt.Errorf("Failed to decode AVP: %s", err)
 		}
-		m.AVP = append(m.AVP, a)
+		m.AVP = ar.push(m.AVP, a)
 		n += a.Len()
 	}
+	if ar != nil {
+		m.AVP = ar.list(start)
+	}
 	return nil
 }
 
 // NewMessage creates and initializes a Message.
+// Zero hopbyhop or endtoend identifiers are generated by DefaultIDGenerator.
 func NewMessage(cmd uint32, flags uint8, appid, hopbyhop, endtoend uint32, dictionary *dict.Parser) *Message {
 	if hopbyhop == 0 {
-		hopbyhop = rand.Uint32()
+		hopbyhop = DefaultIDGenerator.HopByHopID()
 	}
 	if endtoend == 0 {
-		endtoend = rand.Uint32()
+		endtoend = DefaultIDGenerator.EndToEndID()
 	}
 	return &Message{
 		Header: &Header{
@@ -203,11 +326,24 @@ func (m *Message) NewAVP(code interface{}, flags uint8, vendor uint32, data data
 		}
 		a = NewAVP(dictAVP.Code, flags, vendor, data)
 	}
+	if err := m.checkLength(a); err != nil {
+		return nil, err
+	}
 	m.AVP = append(m.AVP, a)
 	m.Header.MessageLength += uint32(a.Len())
 	return a, nil
 }
 
+// checkLength checks the AVP a against the length limits of the
+// dictionary of the Message, if it has any.
+func (m *Message) checkLength(a *AVP) error {
+	d := m.Dictionary()
+	if !d.HasLengthLimits() {
+		return nil
+	}
+	return checkLength(d, m.Header.ApplicationID, a)
+}
+
 // AddAVP adds the AVP to the Message. It is not safe for concurrent calls.
 func (m *Message) AddAVP(a *AVP) {
 	m.AVP = append(m.AVP, a)
@@ -241,7 +377,23 @@ func putWriterBuffer(b *bytes.Buffer) {
 }
 
 // WriteTo serializes the Message and writes into the writer.
+//
+// When the writer is a Conn whose Handler implements the EgressHandler
+// interface, the egress hooks are called before serialization. Conns
+// of a Server with a Codec serialize the Message with the Codec.
 func (m *Message) WriteTo(writer io.Writer) (int64, error) {
+	if ew, ok := writer.(egressWriter); ok {
+		if err := ew.egress(m); err != nil {
+			return 0, err
+		}
+	}
+	if b, err := encode(writer, m); b != nil || err != nil {
+		if err != nil {
+			return 0, err
+		}
+		n, err := writer.Write(b)
+		return int64(n), err
+	}
 	l := m.Len()
 	buf := newWriterBuffer(l)
 	defer putWriterBuffer(buf)
@@ -358,6 +510,9 @@ func (m *Message) FindAVPs(code interface{}, vendorID uint32) ([]*AVP, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = m.decodeLazy(dictAVP.Code, true); err != nil {
+		return nil, err
+	}
 
 	return findFromAVP(m.AVP, dictAVP.Code, true)
 }
@@ -377,6 +532,12 @@ func (m *Message) FindAVP(code interface{}, vendorID uint32) (*AVP, error) {
 	if err != nil {
 		return nil, err
 	}
+	if a, err := m.findLazy(dictAVP.Code, dictAVP.VendorID); a != nil || err != nil {
+		return a, err
+	}
+	if err = m.decodeLazy(dictAVP.Code, true); err != nil {
+		return nil, err
+	}
 
 	result, err := findFromAVP(m.AVP, dictAVP.Code, false)
 
@@ -386,6 +547,115 @@ func (m *Message) FindAVP(code interface{}, vendorID uint32) (*AVP, error) {
 	return nil, err
 }
 
+// FindAllAVP returns every top level AVP of the Message with the given
+// code and vendor id, in the order they appear, e.g. the repeated
+// Multiple-Services-Credit-Control AVPs of a CCA. Unlike FindAVPs, it
+// does not search embedded AVPs of grouped AVPs, and returns an empty
+// list without error when the AVP is not in the Message.
+// The code can be either the AVP code (int, uint32) or name (string).
+//
+// Example:
+//
+//	mscc, err := m.FindAllAVP(avp.MultipleServicesCreditControl, 0)
+//
+func (m *Message) FindAllAVP(code interface{}, vendorID uint32) ([]*AVP, error) {
+	dictAVP, err := m.Dictionary().FindAVPWithVendor(m.Header.ApplicationID, code, vendorID)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.decodeLazy(dictAVP.Code, false); err != nil {
+		return nil, err
+	}
+	var avps []*AVP
+	for _, a := range m.AVP {
+		if a.Code == dictAVP.Code && a.VendorID == dictAVP.VendorID {
+			avps = append(avps, a)
+		}
+	}
+	return avps, nil
+}
+
+// CountAVP returns the number of top level AVPs of the Message with the
+// given code and vendor id, without decoding them.
+// The code can be either the AVP code (int, uint32) or name (string).
+func (m *Message) CountAVP(code interface{}, vendorID uint32) (int, error) {
+	dictAVP, err := m.Dictionary().FindAVPWithVendor(m.Header.ApplicationID, code, vendorID)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, a := range m.AVP {
+		if a.Code == dictAVP.Code && a.VendorID == dictAVP.VendorID {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// FindAVPByName searches the Message for the AVP with the given name,
+// e.g. "Origin-Host". The code and vendor id of the AVP are resolved
+// through the dictionary of the Message.
+//
+// Like FindAVP, it also searches embedded AVPs of grouped AVPs.
+func (m *Message) FindAVPByName(name string) (*AVP, error) {
+	dictAVP, err := m.Dictionary().FindAVP(m.Header.ApplicationID, name)
+	if err != nil {
+		return nil, err
+	}
+	if a, err := m.findLazy(dictAVP.Code, dictAVP.VendorID); a != nil || err != nil {
+		return a, err
+	}
+	if err = m.decodeLazy(dictAVP.Code, true); err != nil {
+		return nil, err
+	}
+	if a := findByCode(m.AVP, dictAVP.Code, dictAVP.VendorID); a != nil {
+		return a, nil
+	}
+	return nil, fmt.Errorf("AVP %s not found", name)
+}
+
+func findByCode(avps []*AVP, code, vendorID uint32) *AVP {
+	for _, a := range avps {
+		if a.Code == code && a.VendorID == vendorID {
+			return a
+		}
+		if g, ok := a.Data.(*GroupedAVP); ok {
+			if ga := findByCode(g.AVP, code, vendorID); ga != nil {
+				return ga
+			}
+		}
+	}
+	return nil
+}
+
+// NewAVPByName creates a new AVP with the given name, e.g. "Origin-Host",
+// and adds it to the Message. The code, vendor id and the flags that
+// must be set are taken from the dictionary of the Message.
+// It is not safe for concurrent calls.
+func (m *Message) NewAVPByName(name string, data datatype.Type) (*AVP, error) {
+	dictAVP, err := m.Dictionary().FindAVP(m.Header.ApplicationID, name)
+	if err != nil {
+		return nil, err
+	}
+	var flags uint8
+	for _, f := range strings.Split(dictAVP.Must, ",") {
+		switch strings.TrimSpace(f) {
+		case "M":
+			flags |= avp.Mbit
+		case "V":
+			flags |= avp.Vbit
+		case "P":
+			flags |= avp.Pbit
+		}
+	}
+	a := NewAVP(dictAVP.Code, flags, dictAVP.VendorID, data)
+	if err := m.checkLength(a); err != nil {
+		return nil, err
+	}
+	m.AddAVP(a)
+	return a, nil
+}
+
 // FindAVPsWithPath searches the Message for AVPs on specific path.
 // Used for example on group hierarchies.
 // The path elements can be either AVP code (int, uint32), name (string) or combination of them.
@@ -405,15 +675,33 @@ func (m *Message) FindAVPsWithPath(path []interface{}, vendorID uint32) ([]*AVP,
 		}
 		pathCodes[i] = dictAVP.Code
 	}
+	if len(pathCodes) == 0 {
+		if err := m.DecodeAll(); err != nil {
+			return nil, err
+		}
+	} else if err := m.decodeLazy(pathCodes[0], false); err != nil {
+		return nil, err
+	}
 	return avpsWithPath(m.AVP, pathCodes), nil
 }
 
 // Answer creates an answer for the current Message with an embedded
-// Result-Code AVP.
+// Result-Code AVP. The P bit is copied from the Message, and the E bit
+// is set for protocol errors (3xxx), as required by RFC 6733 section 3.
 func (m *Message) Answer(resultCode uint32) *Message {
+	return m.AnswerWithError(resultCode, isProtocolError(resultCode))
+}
+
+// AnswerWithError is like Answer, and sets the E bit of the answer if
+// errorFlag is true regardless of the result code.
+func (m *Message) AnswerWithError(resultCode uint32, errorFlag bool) *Message {
+	flags := m.Header.CommandFlags & ProxiableFlag
+	if errorFlag {
+		flags |= ErrorFlag
+	}
 	nm := NewMessage(
 		m.Header.CommandCode,
-		m.Header.CommandFlags&^RequestFlag, // Reset the Request bit.
+		flags,
 		m.Header.ApplicationID,
 		m.Header.HopByHopID,
 		m.Header.EndToEndID,
@@ -423,7 +711,39 @@ func (m *Message) Answer(resultCode uint32) *Message {
 	return nm
 }
 
+// Equal reports whether m and o have the same header and the same AVPs,
+// in the same order. The MessageLength of the headers is not compared
+// directly, it is derived from the AVPs.
+func (m *Message) Equal(o *Message) bool {
+	if m == nil || o == nil {
+		return m == o
+	}
+	mh, oh := *m.Header, *o.Header
+	mh.MessageLength, oh.MessageLength = 0, 0
+	return mh == oh && equalAVPs(m.AVP, o.AVP)
+}
+
+func equalAVPs(a, b []*AVP) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !a[i].Equal(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the string representation of the Message, with the
+// values of AVPs redacted according to the DefaultRedactionProfile.
 func (m *Message) String() string {
+	return m.format(DefaultRedactionProfile)
+}
+
+// format returns the string representation of the Message, with the
+// values of AVPs redacted according to p.
+func (m *Message) format(p *RedactionProfile) string {
 	var b bytes.Buffer
 	var typ string
 	if m.Header.CommandFlags&RequestFlag == RequestFlag {
@@ -431,16 +751,13 @@ func (m *Message) String() string {
 	} else {
 		typ = "Answer"
 	}
-	if dictCMD, err := m.Dictionary().FindCommand(
-		m.Header.ApplicationID,
-		m.Header.CommandCode,
-	); err != nil {
+	if name, ok := m.CommandName(); !ok {
 		fmt.Fprintf(&b, "Unknown-%s\n%s\n", typ, m.Header)
 	} else {
 		fmt.Fprintf(&b, "%s-%s (%s%c)\n%s\n",
-			dictCMD.Name,
+			name.Name,
 			typ,
-			dictCMD.Short,
+			name.Short,
 			typ[0],
 			m.Header,
 		)
@@ -451,17 +768,17 @@ func (m *Message) String() string {
 			a.Code,
 			a.VendorID,
 		); err != nil {
-			fmt.Fprintf(&b, "\tUnknown %s (%s)\n", a, err)
+			fmt.Fprintf(&b, "\tUnknown %s (%s)\n", p.avpString(a), err)
 		} else if a.Data.Type() == GroupedAVPType {
-			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, printGrouped("\t", m, a, 1))
+			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, printGrouped("\t", m, a, 1, p))
 		} else {
-			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, a)
+			fmt.Fprintf(&b, "\t%s %s\n", dictAVP.Name, p.avpString(a))
 		}
 	}
 	return b.String()
 }
 
-func printGrouped(prefix string, m *Message, a *AVP, indent int) string {
+func printGrouped(prefix string, m *Message, a *AVP, indent int, p *RedactionProfile) string {
 	var b bytes.Buffer
 	fmt.Fprintf(&b, "{Code:%d,Flags:0x%x,Length:%d,VendorId:%d,Value:Grouped{\n",
 		a.Code,
@@ -475,14 +792,14 @@ func printGrouped(prefix string, m *Message, a *AVP, indent int) string {
 			ga.Code,
 			ga.VendorID,
 		); err != nil {
-			fmt.Fprintf(&b, "%s\tUnknown %s (%s),\n", prefix, ga, err)
+			fmt.Fprintf(&b, "%s\tUnknown %s (%s),\n", prefix, p.avpString(ga), err)
 		} else {
 			if ga.Data.Type() == GroupedAVPType {
 				indent++
 				tabs := indentTabs(indent)
-				fmt.Fprintf(&b, "%s%s %s\n", tabs, dictAVP.Name, printGrouped(tabs, m, ga, indent))
+				fmt.Fprintf(&b, "%s%s %s\n", tabs, dictAVP.Name, printGrouped(tabs, m, ga, indent, p))
 			} else {
-				fmt.Fprintf(&b, "%s\t%s %s,\n", prefix, dictAVP.Name, ga)
+				fmt.Fprintf(&b, "%s\t%s %s,\n", prefix, dictAVP.Name, p.avpString(ga))
 			}
 		}
 	}
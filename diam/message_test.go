@@ -212,6 +212,159 @@ func TestMessageFindAVP(t *testing.T) {
 	t.Log(avps)
 }
 
+func TestMessageFindAVPByName(t *testing.T) {
+	m, _ := ReadMessage(bytes.NewReader(testMessage), dict.Default)
+	a, err := m.FindAVPByName("Origin-State-Id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Code != avp.OriginStateID {
+		t.Fatalf("Unexpected code. Want %d, have %d", avp.OriginStateID, a.Code)
+	}
+	if _, err = m.FindAVPByName("Session-Id"); err == nil {
+		t.Fatal("Unexpected Session-Id in message")
+	}
+	if _, err = m.FindAVPByName("No-Such-AVP"); err == nil {
+		t.Fatal("Unexpected AVP resolved from dictionary")
+	}
+}
+
+func TestMessageFindAllAVP(t *testing.T) {
+	m := NewRequest(CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	for _, rg := range []datatype.Unsigned32{1, 2} {
+		m.NewAVP(avp.MultipleServicesCreditControl, avp.Mbit, 0, &GroupedAVP{
+			AVP: []*AVP{NewAVP(avp.RatingGroup, avp.Mbit, 0, rg)},
+		})
+	}
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lazy, err := readMessage(bytes.NewReader(b), dict.Default, decodeLazy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range []*Message{m, lazy} {
+		if n, err := m.CountAVP(avp.MultipleServicesCreditControl, 0); err != nil || n != 2 {
+			t.Fatalf("Unexpected MSCC count %d: %v", n, err)
+		}
+		if n, err := m.CountAVP("Rating-Group", 0); err != nil || n != 0 {
+			t.Fatalf("Unexpected top level Rating-Group count %d: %v", n, err)
+		}
+		avps, err := m.FindAllAVP("Multiple-Services-Credit-Control", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(avps) != 2 {
+			t.Fatalf("Unexpected number of MSCC: %d", len(avps))
+		}
+		for i, a := range avps {
+			g, ok := a.Data.(*GroupedAVP)
+			if !ok {
+				t.Fatalf("Unexpected MSCC data: %#v", a.Data)
+			}
+			if rg := g.AVP[0].Data.(datatype.Unsigned32); rg != datatype.Unsigned32(i+1) {
+				t.Fatalf("Unexpected Rating-Group of MSCC %d: %d", i, rg)
+			}
+		}
+		if avps, err = m.FindAllAVP(avp.OriginHost, 0); err != nil || len(avps) != 0 {
+			t.Fatalf("Unexpected Origin-Host: %v, %v", avps, err)
+		}
+	}
+	if _, err = m.CountAVP("No-Such-AVP", 0); err == nil {
+		t.Fatal("Unexpected AVP resolved from dictionary")
+	}
+}
+
+func TestMessageNewAVPByName(t *testing.T) {
+	m := NewRequest(CapabilitiesExchange, 0, dict.Default)
+	a, err := m.NewAVPByName("Origin-Host", datatype.DiameterIdentity("foobar"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Code != avp.OriginHost {
+		t.Fatalf("Unexpected code. Want %d, have %d", avp.OriginHost, a.Code)
+	}
+	if a.Flags != avp.Mbit {
+		t.Fatalf("Unexpected flags. Want %#x, have %#x", avp.Mbit, a.Flags)
+	}
+	if len(m.AVP) != 1 || int(m.Header.MessageLength) != m.Len() {
+		t.Fatalf("Unexpected message: %s", m)
+	}
+	if _, err = m.FindAVPByName("Origin-Host"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = m.NewAVPByName("No-Such-AVP", datatype.Unsigned32(1)); err == nil {
+		t.Fatal("Unexpected AVP created for unknown name")
+	}
+}
+
+func TestReadMessage_Relay(t *testing.T) {
+	m := NewRequest(CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(9999, avp.Vbit|avp.Pbit, 99, datatype.OctetString("hello"))
+	m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &GroupedAVP{
+		AVP: []*AVP{
+			NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4)),
+			NewAVP(9998, 0, 0, datatype.Unsigned32(1)),
+		},
+	})
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = ReadMessage(bytes.NewReader(b), dict.Default); err == nil {
+		t.Fatal("Unexpected unknown AVPs decoded")
+	}
+	rm, err := readMessage(bytes.NewReader(b), dict.Default, decodeRelay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := rm.AVP[1]
+	if a.Code != 9999 || a.VendorID != 99 {
+		t.Fatalf("Unexpected AVP: %s", a)
+	}
+	raw, ok := a.Data.(datatype.Raw)
+	if !ok {
+		t.Fatalf("Unexpected data type. Want datatype.Raw, have %T", a.Data)
+	}
+	if raw.Flags != avp.Vbit|avp.Pbit {
+		t.Fatalf("Unexpected flags. Want %#x, have %#x", avp.Vbit|avp.Pbit, raw.Flags)
+	}
+	rb, err := rm.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, rb) {
+		t.Fatalf("Unexpected message.\nWant:\n%s\nHave:\n%s", hex.Dump(b), hex.Dump(rb))
+	}
+}
+
+func TestReadMessage_RelayUnknownCommand(t *testing.T) {
+	m := NewMessage(9999, RequestFlag, 1000, 0, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(9999, 0, 0, datatype.OctetString("hello"))
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rm, err := readMessage(bytes.NewReader(b), dict.Default, decodeRelay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rm.AVP) != 2 {
+		t.Fatalf("Unexpected number of AVPs. Want 2, have %d", len(rm.AVP))
+	}
+	rb, err := rm.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, rb) {
+		t.Fatalf("Unexpected message.\nWant:\n%s\nHave:\n%s", hex.Dump(b), hex.Dump(rb))
+	}
+}
+
 func TestMessageFindAVPsWithPath(t *testing.T) {
 	m, _ := ReadMessage(bytes.NewReader(testMessage), dict.Default)
 	if avps, err := m.FindAVPsWithPath(nil, 0); err != nil || len(avps) != len(m.AVP) {
@@ -288,3 +441,44 @@ func BenchmarkWriteMessage(b *testing.B) {
 		m.WriteTo(ioutil.Discard)
 	}
 }
+
+func TestMessage_Answer(t *testing.T) {
+	var tests = []struct {
+		reqFlags  uint8
+		code      uint32
+		errorFlag bool
+		want      uint8
+	}{
+		{RequestFlag, Success, false, 0},
+		{RequestFlag | ProxiableFlag, Success, false, ProxiableFlag},
+		{RequestFlag | ProxiableFlag | RetransmittedFlag, Success, false, ProxiableFlag},
+		{RequestFlag | ProxiableFlag, UnableToDeliver, true, ProxiableFlag | ErrorFlag},
+		{RequestFlag, CommandUnsupported, true, ErrorFlag},
+		{RequestFlag | ErrorFlag, AuthenticationRejected, false, 0},
+	}
+	for _, test := range tests {
+		req := NewMessage(CreditControl, test.reqFlags, 4, 1, 2, nil)
+		a := req.Answer(test.code)
+		if a.Header.CommandFlags != test.want {
+			t.Errorf("Unexpected flags of answer %d to request with flags %#x. Want %#x, have %#x",
+				test.code, test.reqFlags, test.want, a.Header.CommandFlags)
+		}
+		if err := CheckAnswerFlags(req, a); err != nil {
+			t.Errorf("Invalid answer %d to request with flags %#x: %s",
+				test.code, test.reqFlags, err)
+		}
+		a = req.AnswerWithError(test.code, test.errorFlag)
+		if a.Header.CommandFlags != test.want {
+			t.Errorf("Unexpected flags of answer %d with E bit %t. Want %#x, have %#x",
+				test.code, test.errorFlag, test.want, a.Header.CommandFlags)
+		}
+	}
+	req := NewMessage(CreditControl, RequestFlag|ProxiableFlag, 4, 1, 2, nil)
+	a := req.AnswerWithError(AuthorizationRejected, true)
+	if a.Header.CommandFlags != ProxiableFlag|ErrorFlag {
+		t.Fatalf("E bit was not set: %#x", a.Header.CommandFlags)
+	}
+	if a.Header.HopByHopID != 1 || a.Header.EndToEndID != 2 {
+		t.Fatalf("Unexpected identifiers: %d/%d", a.Header.HopByHopID, a.Header.EndToEndID)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"crypto/tls"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// Meta is the metadata of a message received from a connection,
+// populated by the read loop of the Server or client connection.
+//
+// It allows handlers and middleware to know where a message came from
+// and when, without carrying the Conn around.
+type Meta struct {
+	Received time.Time            // Time the message was read
+	Conn     Conn                 // Connection the message was read from
+	Network  string               // Transport network, e.g. "tcp"
+	TLS      *tls.ConnectionState // TLS state, or nil when not using TLS
+
+	// Context is the context of the connection when the message was
+	// read. After the CER/CEA handshake, it carries the identity of
+	// the peer, see the smpeer package.
+	Context context.Context
+}
+
+// Meta returns the metadata of the message, or nil if the message was
+// not read from a connection, e.g. messages created by NewRequest or
+// decoded by ReadMessage.
+func (m *Message) Meta() *Meta {
+	return m.meta
+}
+
+// newMeta returns the metadata of messages read from the connection c.
+func (c *conn) newMeta() *Meta {
+	return &Meta{
+		Received: time.Now(),
+		Conn:     c.writer,
+		Network:  c.rwc.LocalAddr().Network(),
+		TLS:      c.tlsState,
+		Context:  c.writer.Context(),
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// CommandName holds the names of a command, e.g. "CE" and
+// "Capabilities-Exchange" for the Capabilities-Exchange-Request (CER)
+// and Capabilities-Exchange-Answer (CEA).
+//
+// The names are used by the ServeMux to dispatch messages, by the
+// String method of messages and by the metrics of package sla.
+type CommandName struct {
+	Short string
+	Name  string
+}
+
+// Request returns the short name of the request, e.g. "CER".
+func (n CommandName) Request() string {
+	return n.Short + "R"
+}
+
+// Answer returns the short name of the answer, e.g. "CEA".
+func (n CommandName) Answer() string {
+	return n.Short + "A"
+}
+
+// Command codes, see RFC 6733 section 11.2.1. Command codes are 24 bits
+// long, and the last two are reserved for experimental use.
+const (
+	MaxCommandCode         = 1<<24 - 1
+	ExperimentalCommandMin = 16777214
+	ExperimentalCommandMax = 16777215
+)
+
+// IsExperimentalCommand returns true if code is in the range of
+// command codes for experimental use.
+func IsExperimentalCommand(code uint32) bool {
+	return code >= ExperimentalCommandMin && code <= ExperimentalCommandMax
+}
+
+// numericCommandName returns the name of the command code with its
+// code as short name, e.g. "16777214", which is dispatched by the
+// ServeMux as "16777214R" and "16777214A".
+func numericCommandName(code uint32, name string) CommandName {
+	if name == "" {
+		name = "Experimental"
+	}
+	return CommandName{Short: strconv.FormatUint(uint64(code), 10), Name: name}
+}
+
+type commandKey struct {
+	appID uint32
+	code  uint32
+}
+
+var commandNames = struct {
+	sync.RWMutex
+	m map[commandKey]CommandName
+}{m: make(map[commandKey]CommandName)}
+
+// RegisterCommand registers the names of the command code of the
+// application appID, for commands missing from the dictionaries, or to
+// override the names of the dictionaries. Commands registered for the
+// application id 0 apply to all applications, like the commands of the
+// base protocol.
+//
+// RegisterCommand is typically called from an init function, but is
+// safe for concurrent use.
+func RegisterCommand(appID, code uint32, short, name string) {
+	if short == "" {
+		panic("DIAM: empty short command name")
+	}
+	if code > MaxCommandCode {
+		panic("DIAM: command code larger than 24 bits")
+	}
+	commandNames.Lock()
+	commandNames.m[commandKey{appID, code}] = CommandName{Short: short, Name: name}
+	commandNames.Unlock()
+}
+
+// LookupCommand returns the names of the command code of the
+// application appID: the names registered with RegisterCommand, or
+// else the ones of the dictionary d. If d is nil, dict.Default is used.
+//
+// Commands of the dictionary without short name, and experimental
+// commands not registered nor in the dictionary, have their code as
+// short name, e.g. "16777214", so handlers can be registered for them
+// as "16777214R" and "16777214A".
+func LookupCommand(d *dict.Parser, appID, code uint32) (CommandName, bool) {
+	commandNames.RLock()
+	n, ok := commandNames.m[commandKey{appID, code}]
+	if !ok {
+		n, ok = commandNames.m[commandKey{0, code}]
+	}
+	commandNames.RUnlock()
+	if ok {
+		return n, true
+	}
+	if d == nil {
+		d = dict.Default
+	}
+	cmd, err := d.FindCommand(appID, code)
+	switch {
+	case err == nil && cmd.Short == "":
+		return numericCommandName(code, cmd.Name), true
+	case err == nil:
+		return CommandName{Short: cmd.Short, Name: cmd.Name}, true
+	case IsExperimentalCommand(code):
+		return numericCommandName(code, ""), true
+	}
+	return CommandName{}, false
+}
+
+// CommandApps returns the ids of the applications of the dictionary d
+// that define a command with the given short name, e.g. "RA" or "MA",
+// in the order they are loaded. If d is nil, dict.Default is used.
+//
+// Handlers registered with ServeMux.Handle for a short name defined by
+// more than one application serve the commands of all of them, e.g.
+// "MAR" serves both the SIP (6) and the Cx (16777221) Multimedia-Auth
+// requests, which have different command codes. Register them with
+// ServeMux.HandleApp instead.
+func CommandApps(d *dict.Parser, short string) []uint32 {
+	if d == nil {
+		d = dict.Default
+	}
+	var ids []uint32
+	for _, app := range d.Apps() {
+		for _, cmd := range app.Command {
+			if cmd.Short == short {
+				ids = append(ids, app.ID)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// CommandName returns the names of the command of m. See LookupCommand
+// for details.
+func (m *Message) CommandName() (CommandName, bool) {
+	return LookupCommand(m.Dictionary(), m.Header.ApplicationID, m.Header.CommandCode)
+}
+
+// commandLabel returns the short name of m, e.g. "CER", or its command
+// code if the command is unknown.
+func commandLabel(m *Message) string {
+	n, ok := m.CommandName()
+	switch {
+	case !ok:
+		return strconv.FormatUint(uint64(m.Header.CommandCode), 10)
+	case m.Header.CommandFlags&RequestFlag != 0:
+		return n.Request()
+	default:
+		return n.Answer()
+	}
+}
+
+// registeredCommands returns the commands registered for appID.
+func registeredCommands(appID uint32) []CommandName {
+	commandNames.RLock()
+	defer commandNames.RUnlock()
+	var l []CommandName
+	for k, n := range commandNames.m {
+		if k.appID == appID {
+			l = append(l, n)
+		}
+	}
+	return l
+}
@@ -0,0 +1,139 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestLookupCommand(t *testing.T) {
+	n, ok := LookupCommand(nil, 0, CapabilitiesExchange)
+	if !ok || n.Request() != "CER" || n.Answer() != "CEA" || n.Name != "Capabilities-Exchange" {
+		t.Fatalf("Unexpected command name: %#v", n)
+	}
+	// Base commands apply to all applications.
+	if n, ok = LookupCommand(dict.Default, 4, DeviceWatchdog); !ok || n.Short != "DW" {
+		t.Fatalf("Unexpected command name: %#v", n)
+	}
+	if _, ok = LookupCommand(nil, 1000, 16777000); ok {
+		t.Fatal("Unexpected name of unknown command")
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	RegisterCommand(16777000, 8388999, "XY", "Custom-Command")
+	n, ok := LookupCommand(nil, 16777000, 8388999)
+	if !ok || n.Short != "XY" || n.Name != "Custom-Command" {
+		t.Fatalf("Unexpected command name: %#v", n)
+	}
+	// Not in the dictionary, dispatched by name.
+	m := NewRequest(8388999, 16777000, nil)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("a"))
+	if s := m.String(); !strings.HasPrefix(s, "Custom-Command-Request (XYR)") {
+		t.Fatalf("Unexpected message string: %s", s)
+	}
+	mux := NewServeMux()
+	done := make(chan struct{}, 1)
+	mux.HandleFunc("XYR", func(c Conn, m *Message) { done <- struct{}{} })
+	if !mux.supports(m) {
+		t.Fatal("Registered command not supported by the mux")
+	}
+	mux.ServeDIAM(nil, m)
+	select {
+	case <-done:
+	default:
+		t.Fatal("Registered command not dispatched")
+	}
+}
+
+func TestLookupCommand_Experimental(t *testing.T) {
+	d, err := dict.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = d.Load(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="16777001">
+		<command code="16777214" name="Vendor-Probe">
+			<request></request>
+			<answer></answer>
+		</command>
+	</application>
+</diameter>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// In the dictionary, without short name.
+	n, ok := LookupCommand(d, 16777001, ExperimentalCommandMin)
+	if !ok || n.Request() != "16777214R" || n.Name != "Vendor-Probe" {
+		t.Fatalf("Unexpected command name: %#v", n)
+	}
+	// Not in the dictionary.
+	n, ok = LookupCommand(d, 16777001, ExperimentalCommandMax)
+	if !ok || n.Answer() != "16777215A" || n.Name != "Experimental" {
+		t.Fatalf("Unexpected command name: %#v", n)
+	}
+	m := NewRequest(ExperimentalCommandMin, 16777001, d)
+	if s := m.String(); !strings.HasPrefix(s, "Vendor-Probe-Request (16777214R)") {
+		t.Fatalf("Unexpected message string: %s", s)
+	}
+	mux := NewServeMux()
+	done := make(chan struct{}, 1)
+	mux.HandleAppFunc(16777001, "16777214R", func(c Conn, m *Message) { done <- struct{}{} })
+	mux.ServeDIAM(nil, m)
+	select {
+	case <-done:
+	default:
+		t.Fatal("Experimental command not dispatched")
+	}
+	err = d.Load(strings.NewReader(`<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+	<application id="16777002">
+		<command code="16777216" short="XX" name="Too-Large"></command>
+	</application>
+</diameter>`))
+	if err == nil {
+		t.Fatal("Command code larger than 24 bits was loaded")
+	}
+}
+
+func TestCommandApps(t *testing.T) {
+	ids := CommandApps(nil, "MA")
+	if len(ids) != 2 {
+		t.Fatalf("Unexpected applications of MA: %v", ids)
+	}
+	if ids := CommandApps(nil, "No-Such-Command"); len(ids) != 0 {
+		t.Fatalf("Unexpected applications: %v", ids)
+	}
+	// The SIP and Cx Multimedia-Auth requests share their short name,
+	// and are dispatched to the handler of their application.
+	mux := NewServeMux()
+	appc := make(chan uint32, 1)
+	for _, id := range ids {
+		id := id
+		mux.HandleAppFunc(id, "MAR", func(c Conn, m *Message) { appc <- id })
+	}
+	for _, tc := range []struct {
+		appID, code uint32
+	}{
+		{6, 286},
+		{16777221, 303},
+	} {
+		mux.ServeDIAM(nil, NewRequest(tc.code, tc.appID, nil))
+		select {
+		case id := <-appc:
+			if id != tc.appID {
+				t.Fatalf("Unexpected handler. Want app %d, have %d", tc.appID, id)
+			}
+		case err := <-mux.ErrorReports():
+			t.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"sort"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+// SortAVPs reorders the AVPs of the Message, and the embedded AVPs of its
+// grouped AVPs, in the order of the Command Code Format of the dictionary:
+// fixed AVPs first, then the required AVPs and then the optional AVPs,
+// each in the order their rules are declared. Session-Id rules are always
+// treated as fixed. AVPs without rules keep their relative order, at the
+// end.
+//
+// Messages of commands unknown to the dictionary are left unchanged.
+// It is not safe for concurrent calls.
+func (m *Message) SortAVPs() {
+	cmd, err := m.Dictionary().FindCommand(
+		m.Header.ApplicationID,
+		m.Header.CommandCode,
+	)
+	if err != nil {
+		return
+	}
+	rules := cmd.Answer.Rule
+	if m.Header.CommandFlags&RequestFlag == RequestFlag {
+		rules = cmd.Request.Rule
+	}
+	m.sortAVPs(m.AVP, rules)
+}
+
+// CanonicalOrder returns an EgressFunc that sorts outgoing messages with
+// Message.SortAVPs, for peers that require AVPs in the order of the
+// Command Code Format. It also makes the serialized messages stable,
+// which is useful for byte-level tests.
+func CanonicalOrder() EgressFunc {
+	return func(c Conn, m *Message) error {
+		m.SortAVPs()
+		return nil
+	}
+}
+
+type avpRank struct {
+	class int // 0 fixed, 1 required, 2 optional, 3 unknown
+	index int
+}
+
+func (m *Message) sortAVPs(avps []*AVP, rules []*dict.Rule) {
+	dp := m.Dictionary()
+	ranks := make(map[[2]uint32]avpRank, len(rules))
+	for i, r := range rules {
+		dictAVP, err := dp.FindAVP(m.Header.ApplicationID, r.AVP)
+		if err != nil {
+			continue
+		}
+		class := 2
+		switch {
+		case r.Fixed || dictAVP.Code == avp.SessionID:
+			class = 0
+		case r.Required:
+			class = 1
+		}
+		ranks[[2]uint32{dictAVP.Code, dictAVP.VendorID}] = avpRank{class, i}
+	}
+	s := byRank{avps: avps, ranks: make([]avpRank, len(avps))}
+	for i, a := range avps {
+		if r, ok := ranks[[2]uint32{a.Code, a.VendorID}]; ok {
+			s.ranks[i] = r
+		} else {
+			s.ranks[i] = avpRank{class: 3}
+		}
+	}
+	sort.Stable(s)
+	for _, a := range avps {
+		g, ok := a.Data.(*GroupedAVP)
+		if !ok {
+			continue
+		}
+		dictAVP, err := dp.FindAVPWithVendor(
+			m.Header.ApplicationID,
+			a.Code,
+			a.VendorID,
+		)
+		if err != nil {
+			continue
+		}
+		m.sortAVPs(g.AVP, dictAVP.Data.Rule)
+	}
+}
+
+// byRank sorts AVPs by their rank in the Command Code Format.
+type byRank struct {
+	avps  []*AVP
+	ranks []avpRank
+}
+
+func (s byRank) Len() int { return len(s.avps) }
+
+func (s byRank) Swap(i, j int) {
+	s.avps[i], s.avps[j] = s.avps[j], s.avps[i]
+	s.ranks[i], s.ranks[j] = s.ranks[j], s.ranks[i]
+}
+
+func (s byRank) Less(i, j int) bool {
+	if s.ranks[i].class != s.ranks[j].class {
+		return s.ranks[i].class < s.ranks[j].class
+	}
+	return s.ranks[i].index < s.ranks[j].index
+}
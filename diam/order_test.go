@@ -0,0 +1,112 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func avpCodes(avps []*AVP) []uint32 {
+	codes := make([]uint32, len(avps))
+	for i, a := range avps {
+		codes[i] = a.Code
+	}
+	return codes
+}
+
+func TestMessageSortAVPs(t *testing.T) {
+	m := NewRequest(CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(9999, 0, 0, datatype.Unsigned32(1))
+	m.NewAVP(avp.ProductName, 0, 0, datatype.UTF8String("go-diameter"))
+	m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &GroupedAVP{
+		AVP: []*AVP{
+			NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+			NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4)),
+		},
+	})
+	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("localhost"))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.SortAVPs()
+	want := []uint32{
+		avp.OriginHost,
+		avp.OriginRealm,
+		avp.ProductName,
+		avp.OriginStateID,
+		avp.VendorSpecificApplicationID,
+		9999,
+	}
+	have := avpCodes(m.AVP)
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("Unexpected order. Want %v, have %v", want, have)
+		}
+	}
+	g := m.AVP[4].Data.(*GroupedAVP)
+	if g.AVP[0].Code != avp.AuthApplicationID {
+		t.Fatalf("Unexpected grouped order. Want %d first, have %v",
+			avp.AuthApplicationID, avpCodes(g.AVP))
+	}
+}
+
+func TestMessageSortAVPs_Fixed(t *testing.T) {
+	var fixedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<diameter>
+  <application id="0">
+    <command code="1000" short="FX" name="Fixed">
+      <request>
+        <rule avp="Origin-Host" required="true" max="1"/>
+        <rule avp="Destination-Host" required="false" max="1"/>
+        <rule avp="Origin-Realm" fixed="true" required="true" max="1"/>
+      </request>
+      <answer>
+      </answer>
+    </command>
+    <avp name="Session-Id" code="263" must="M" may="P" must-not="V" may-encrypt="Y">
+      <data type="UTF8String"/>
+    </avp>
+    <avp name="Origin-Host" code="264" must="M" may="P" must-not="V" may-encrypt="-">
+      <data type="DiameterIdentity"/>
+    </avp>
+    <avp name="Destination-Host" code="293" must="M" may="P" must-not="V" may-encrypt="-">
+      <data type="DiameterIdentity"/>
+    </avp>
+    <avp name="Origin-Realm" code="296" must="M" may="P" must-not="V" may-encrypt="-">
+      <data type="DiameterIdentity"/>
+    </avp>
+  </application>
+</diameter>`
+	dp, err := dict.NewParser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = dp.Load(bytes.NewReader([]byte(fixedXML))); err != nil {
+		t.Fatal(err)
+	}
+	m := NewRequest(1000, 0, dp)
+	m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity("srv"))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1;2"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("localhost"))
+	m.SortAVPs()
+	// Session-Id has no rule in this command, so it goes last.
+	want := []uint32{
+		avp.OriginRealm,
+		avp.OriginHost,
+		avp.DestinationHost,
+		avp.SessionID,
+	}
+	have := avpCodes(m.AVP)
+	for i := range want {
+		if have[i] != want[i] {
+			t.Fatalf("Unexpected order. Want %v, have %v", want, have)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// Proxy-Info AVPs are added to requests by stateless proxies, which
+// keep their state in the Proxy-State instead of locally. Answers
+// carry the Proxy-Info AVPs of their request untouched and in the same
+// order, and each proxy removes its own from the answer before
+// forwarding it. See RFC 6733 sections 6.2 and 6.7.3 for details.
+
+// ProxyInfoError reports a malformed Proxy-Info AVP: one that is not
+// grouped, or that does not have exactly one Proxy-Host and one
+// Proxy-State.
+type ProxyInfoError struct {
+	AVP    *AVP   // The offending Proxy-Info AVP
+	Code   uint32 // Result-Code of the answer, e.g. DIAMETER_MISSING_AVP
+	Failed *AVP   // AVP for the Failed-AVP of the answer
+	Reason string
+}
+
+// Error implements the error interface.
+func (e *ProxyInfoError) Error() string {
+	return fmt.Sprintf("invalid Proxy-Info AVP: %s", e.Reason)
+}
+
+// ProxyInfo returns the top level Proxy-Info AVPs of the Message, in
+// order. Messages read in lazy decode mode are decoded entirely first.
+func (m *Message) ProxyInfo() ([]*AVP, error) {
+	if err := m.DecodeAll(); err != nil {
+		return nil, err
+	}
+	var avps []*AVP
+	for _, a := range m.AVP {
+		if a.Code == avp.ProxyInfo && a.VendorID == 0 {
+			avps = append(avps, a)
+		}
+	}
+	return avps, nil
+}
+
+// CheckProxyInfo returns a *ProxyInfoError for each malformed Proxy-Info
+// AVP of the Message.
+func (m *Message) CheckProxyInfo() ([]*ProxyInfoError, error) {
+	avps, err := m.ProxyInfo()
+	if err != nil {
+		return nil, err
+	}
+	var errs []*ProxyInfoError
+	for _, a := range avps {
+		if err := checkProxyInfo(a); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs, nil
+}
+
+func checkProxyInfo(a *AVP) *ProxyInfoError {
+	g, ok := a.Data.(*GroupedAVP)
+	if !ok {
+		return &ProxyInfoError{AVP: a, Code: InvalidAVPValue, Failed: a, Reason: "not grouped"}
+	}
+	var host, state []*AVP
+	for _, e := range g.AVP {
+		switch {
+		case e.VendorID != 0:
+		case e.Code == avp.ProxyHost:
+			host = append(host, e)
+		case e.Code == avp.ProxyState:
+			state = append(state, e)
+		}
+	}
+	switch {
+	case len(host) == 0:
+		return &ProxyInfoError{AVP: a, Code: MissingAVP, Reason: "missing Proxy-Host",
+			Failed: NewAVP(avp.ProxyHost, avp.Mbit, 0, datatype.DiameterIdentity(""))}
+	case len(state) == 0:
+		return &ProxyInfoError{AVP: a, Code: MissingAVP, Reason: "missing Proxy-State",
+			Failed: NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString(""))}
+	case len(host) > 1:
+		return &ProxyInfoError{AVP: a, Code: AVPOccursTooManyTimes, Failed: host[1],
+			Reason: "more than one Proxy-Host"}
+	case len(state) > 1:
+		return &ProxyInfoError{AVP: a, Code: AVPOccursTooManyTimes, Failed: state[1],
+			Reason: "more than one Proxy-State"}
+	}
+	return nil
+}
+
+// CopyProxyInfo adds the Proxy-Info AVPs of the request to the answer,
+// untouched and in the same order, as required for all answers.
+func CopyProxyInfo(answer, request *Message) error {
+	avps, err := request.ProxyInfo()
+	if err != nil {
+		return err
+	}
+	for _, a := range avps {
+		answer.AddAVP(a)
+	}
+	return nil
+}
+
+// StripProxyInfo removes the last Proxy-Info AVP of the Message whose
+// Proxy-Host is host, and returns it, or nil if there is none. Proxies
+// call it on answers to their requests before forwarding them, to get
+// back the Proxy-State they added.
+func (m *Message) StripProxyInfo(host datatype.DiameterIdentity) (*AVP, error) {
+	if err := m.DecodeAll(); err != nil {
+		return nil, err
+	}
+	for i := len(m.AVP) - 1; i >= 0; i-- {
+		a := m.AVP[i]
+		if a.Code != avp.ProxyInfo || a.VendorID != 0 || proxyHost(a) != host {
+			continue
+		}
+		m.Header.MessageLength -= uint32(a.Len())
+		m.AVP = append(m.AVP[:i], m.AVP[i+1:]...)
+		return a, nil
+	}
+	return nil, nil
+}
+
+// proxyHost returns the Proxy-Host of the Proxy-Info AVP a.
+func proxyHost(a *AVP) datatype.DiameterIdentity {
+	if g, ok := a.Data.(*GroupedAVP); ok {
+		for _, e := range g.AVP {
+			if e.Code == avp.ProxyHost && e.VendorID == 0 {
+				host, _ := e.Data.(datatype.DiameterIdentity)
+				return host
+			}
+		}
+	}
+	return ""
+}
+
+// ProxyInfoMode is what a ProxyInfoValidator does with requests that
+// have malformed Proxy-Info AVPs.
+type ProxyInfoMode int
+
+// Proxy-Info modes.
+const (
+	// ProxyInfoIgnore does not check the requests.
+	ProxyInfoIgnore ProxyInfoMode = iota
+
+	// ProxyInfoWarn reports the errors and handles the requests as
+	// usual.
+	ProxyInfoWarn
+
+	// ProxyInfoReject reports the errors and answers the requests
+	// with the Result-Code of the first error.
+	ProxyInfoReject
+)
+
+// ProxyInfoValidator checks the Proxy-Info AVPs of incoming requests.
+//
+// Example:
+//
+//	v := &diam.ProxyInfoValidator{Mode: diam.ProxyInfoReject, Reporter: mux}
+//	mux.Handle("CCR", v.Handler(handleCCR))
+type ProxyInfoValidator struct {
+	Mode ProxyInfoMode
+
+	// Reporter receives an ErrorReport with a *ProxyInfoError for
+	// each malformed Proxy-Info AVP, and the errors of messages that
+	// cannot be decoded. Optional.
+	Reporter ErrorReporter
+}
+
+// Handler returns a Handler that checks the Proxy-Info AVPs of incoming
+// requests before calling h, according to the mode of v. Answers are
+// passed to h unchecked.
+//
+// Rejected requests are answered with their Proxy-Info AVPs, and the
+// AVP of the first error in the Failed-AVP.
+func (v *ProxyInfoValidator) Handler(h Handler) Handler {
+	return HandlerFunc(func(c Conn, m *Message) {
+		if v.Mode == ProxyInfoIgnore || !isRequest(m) {
+			h.ServeDIAM(c, m)
+			return
+		}
+		errs, err := m.CheckProxyInfo()
+		if err != nil {
+			v.report(c, m, err)
+			return
+		}
+		for _, e := range errs {
+			v.report(c, m, e)
+		}
+		if len(errs) == 0 || v.Mode == ProxyInfoWarn {
+			h.ServeDIAM(c, m)
+			return
+		}
+		a := m.Answer(errs[0].Code)
+		a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &GroupedAVP{AVP: []*AVP{errs[0].Failed}})
+		CopyProxyInfo(a, m)
+		if _, err := a.WriteTo(c); err != nil {
+			v.report(c, m, err)
+		}
+	})
+}
+
+func (v *ProxyInfoValidator) report(c Conn, m *Message, err error) {
+	if v.Reporter != nil {
+		v.Reporter.Error(&ErrorReport{Conn: c, Message: m, Error: err})
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func newProxyInfo(host, state string) *diam.GroupedAVP {
+	g := &diam.GroupedAVP{}
+	if host != "" {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.ProxyHost, avp.Mbit, 0, datatype.DiameterIdentity(host)))
+	}
+	if state != "" {
+		g.AVP = append(g.AVP, diam.NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString(state)))
+	}
+	return g
+}
+
+func newProxiedCCR(infos ...*diam.GroupedAVP) *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	for _, g := range infos {
+		m.NewAVP(avp.ProxyInfo, avp.Mbit, 0, g)
+	}
+	return m
+}
+
+// readBack serializes m and reads it back.
+func readBack(t *testing.T, m *diam.Message) *diam.Message {
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := diam.ReadMessage(bytes.NewReader(b), m.Dictionary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestMessage_CheckProxyInfo(t *testing.T) {
+	m := newProxiedCCR(newProxyInfo("p1", "s1"), newProxyInfo("p2", ""), newProxyInfo("", "s3"))
+	errs, err := m.CheckProxyInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("Unexpected number of errors: %v", errs)
+	}
+	for i, code := range []uint32{avp.ProxyState, avp.ProxyHost} {
+		if errs[i].Code != diam.MissingAVP || errs[i].Failed.Code != code {
+			t.Fatalf("Unexpected error %d: %v", i, errs[i])
+		}
+	}
+	twice := newProxyInfo("p1", "s1")
+	twice.AVP = append(twice.AVP, diam.NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString("s2")))
+	if errs, _ = newProxiedCCR(twice).CheckProxyInfo(); len(errs) != 1 || errs[0].Code != diam.AVPOccursTooManyTimes {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+}
+
+func TestCopyProxyInfo(t *testing.T) {
+	req := readBack(t, newProxiedCCR(newProxyInfo("p1", "s1"), newProxyInfo("p2", "s2")))
+	a := req.Answer(diam.Success)
+	if err := diam.CopyProxyInfo(a, req); err != nil {
+		t.Fatal(err)
+	}
+	a = readBack(t, a)
+	want, _ := req.ProxyInfo()
+	have, err := a.ProxyInfo()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(have) != len(want) {
+		t.Fatalf("Unexpected Proxy-Info AVPs: %v", have)
+	}
+	for i := range want {
+		if !have[i].Equal(want[i]) {
+			t.Fatalf("Unexpected Proxy-Info %d. Want %s, have %s", i, want[i], have[i])
+		}
+	}
+
+	// The proxy removes its own Proxy-Info, the last one it added.
+	pi, err := a.StripProxyInfo("p2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pi == nil || !pi.Equal(want[1]) {
+		t.Fatalf("Unexpected stripped Proxy-Info: %v", pi)
+	}
+	if pi, _ = a.StripProxyInfo("p3"); pi != nil {
+		t.Fatalf("Unexpected stripped Proxy-Info: %v", pi)
+	}
+	a = readBack(t, a)
+	if have, _ = a.ProxyInfo(); len(have) != 1 || !have[0].Equal(want[0]) {
+		t.Fatalf("Unexpected Proxy-Info AVPs after strip: %v", have)
+	}
+}
+
+func TestProxyInfoValidator(t *testing.T) {
+	handled := make(chan *diam.Message, 1)
+	h := diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		handled <- m
+	})
+	smux := diam.NewServeMux()
+	v := &diam.ProxyInfoValidator{Mode: diam.ProxyInfoReject, Reporter: smux}
+	c := diamtest.NewFakeConn(nil, nil)
+	req := newProxiedCCR(newProxyInfo("p1", "s1"), newProxyInfo("p2", ""))
+	v.Handler(h).ServeDIAM(c, req)
+	select {
+	case <-handled:
+		t.Fatal("Rejected CCR was handled")
+	default:
+	}
+	w := c.Written()
+	if len(w) != 1 {
+		t.Fatalf("Unexpected number of answers: %d", len(w))
+	}
+	rc, err := w[0].FindAVP(avp.ResultCode, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := rc.Data.(datatype.Unsigned32); v != diam.MissingAVP {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.MissingAVP, v)
+	}
+	if pi, _ := w[0].ProxyInfo(); len(pi) != 2 {
+		t.Fatalf("Unexpected Proxy-Info AVPs in answer: %v", pi)
+	}
+	if err := <-smux.ErrorReports(); err.Error.(*diam.ProxyInfoError).Failed.Code != avp.ProxyState {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	v.Mode = diam.ProxyInfoWarn
+	v.Handler(h).ServeDIAM(c, req)
+	select {
+	case <-handled:
+	default:
+		t.Fatal("CCR was not handled")
+	}
+}
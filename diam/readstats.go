@@ -0,0 +1,61 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import "time"
+
+// ReadStats are the statistics of the reader of a connection, which
+// reassembles messages split across network reads into one buffer per
+// connection.
+type ReadStats struct {
+	Messages   uint64 // Messages read, including the ones that failed to decode
+	Bytes      uint64 // Bytes of the messages read
+	Reads      uint64 // Reads from the network
+	Partial    uint64 // Messages that needed more than one read to complete
+	Grows      uint64 // Times the read buffer grew
+	BufferSize int    // Current size of the read buffer
+}
+
+// ReadStatsOf returns the read statistics of the connection c, and
+// false for connections that are not created by this package.
+func ReadStatsOf(c Conn) (ReadStats, bool) {
+	if rc, ok := c.(interface {
+		readStats() ReadStats
+	}); ok {
+		return rc.readStats(), true
+	}
+	return ReadStats{}, false
+}
+
+// readStats returns the read statistics of the connection.
+func (c *conn) readStats() ReadStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	return c.stats
+}
+
+func (w *response) readStats() ReadStats {
+	return w.conn.readStats()
+}
+
+// countRead updates the read statistics after reading a message of n
+// bytes, using the number of network reads done while reading it and
+// whether it started in data already buffered.
+func (c *conn) countRead(n uint32, reads uint64, buffered bool) {
+	if n == 0 {
+		return
+	}
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.stats.Messages++
+	c.stats.Bytes += uint64(n)
+	c.cstats.LastRead = time.Now()
+	c.stats.Reads = c.sr.reads
+	if reads > 1 || (buffered && reads > 0) {
+		c.stats.Partial++
+	}
+	c.stats.Grows = c.rbuf.grows
+	c.stats.BufferSize = cap(c.rbuf.b)
+}
@@ -0,0 +1,91 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestReadStats_Fragmented(t *testing.T) {
+	mc := make(chan *diam.Message, 3)
+	conns := make(chan diam.Conn, 3)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+		conns <- c
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	cli, err := net.Dial("tcp", srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	var b bytes.Buffer
+	if _, err = sendCER(&b); err != nil {
+		t.Fatal(err)
+	}
+	cer := b.Bytes()
+	// Split the first message across segments.
+	for i := 0; i < len(cer); i += 7 {
+		end := i + 7
+		if end > len(cer) {
+			end = len(cer)
+		}
+		if _, err = cli.Write(cer[i:end]); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	// Then two messages at once.
+	if _, err = cli.Write(append(append([]byte(nil), cer...), cer...)); err != nil {
+		t.Fatal(err)
+	}
+	var msgs []*diam.Message
+	var c diam.Conn
+	for i := 0; i < 3; i++ {
+		select {
+		case m := <-mc:
+			msgs = append(msgs, m)
+			c = <-conns
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for CER")
+		}
+	}
+	// Messages must not share the read buffer.
+	for _, m := range msgs {
+		a, err := m.FindAVP(avp.HostIPAddress, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ip := net.IP(a.Data.(datatype.Address)); !ip.Equal(net.ParseIP("127.0.0.1")) {
+			t.Fatalf("Unexpected Host-IP-Address %s", ip)
+		}
+	}
+	stats, ok := diam.ReadStatsOf(c)
+	if !ok {
+		t.Fatal("Missing read stats")
+	}
+	if stats.Messages != 3 || stats.Bytes != uint64(3*len(cer)) {
+		t.Fatalf("Unexpected stats. Want 3 messages of %d bytes, have %+v", len(cer), stats)
+	}
+	if stats.Partial == 0 || stats.Partial > 2 || stats.Reads < 3 {
+		t.Fatalf("Unexpected reassembly stats: %+v", stats)
+	}
+	if stats.BufferSize != diam.MessageBufferLength {
+		t.Fatalf("Unexpected buffer size. Want %d, have %d", diam.MessageBufferLength, stats.BufferSize)
+	}
+	if _, ok = diam.ReadStatsOf(nil); ok {
+		t.Fatal("Unexpected read stats for nil connection")
+	}
+}
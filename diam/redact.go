@@ -0,0 +1,99 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// RedactionProfile defines the AVPs whose values are redacted when
+// printing messages, e.g. to keep subscriber identities like the IMSI
+// and MSISDN out of production debug logs. Redacted AVPs keep their
+// code, flags, length and vendor id, and only their value is replaced.
+//
+// Grouped AVPs are printed with their embedded AVPs, which are
+// redacted on their own.
+//
+// Example:
+//
+//	diam.DefaultRedactionProfile = &diam.RedactionProfile{
+//		Hash:     []uint32{avp.UserName, avp.SubscriptionIDData},
+//		Truncate: []uint32{avp.CalledStationID},
+//		Keep:     4,
+//	}
+type RedactionProfile struct {
+	// Hash lists the codes of AVPs whose values are replaced by
+	// a hash, so equal values can still be correlated across
+	// log lines.
+	Hash []uint32
+
+	// Truncate lists the codes of AVPs whose values are cut to
+	// their first Keep bytes.
+	Truncate []uint32
+	Keep     int
+
+	// Salt is prepended to values before hashing, optional.
+	Salt []byte
+}
+
+// DefaultRedactionProfile is the RedactionProfile used by
+// Message.String. It is nil by default, which prints all values,
+// and must not be modified while messages are printed.
+var DefaultRedactionProfile *RedactionProfile
+
+// containsCode returns true if code is in codes.
+func containsCode(codes []uint32, code uint32) bool {
+	for _, c := range codes {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// avpString returns the string representation of the AVP a, with its
+// value redacted according to p. A nil profile redacts nothing.
+func (p *RedactionProfile) avpString(a *AVP) string {
+	if p == nil || a.Data == nil || a.Data.Type() == GroupedAVPType {
+		return a.String()
+	}
+	var v string
+	switch {
+	case containsCode(p.Hash, a.Code):
+		h := sha256.New()
+		h.Write(p.Salt)
+		h.Write(a.Data.Serialize())
+		v = fmt.Sprintf("Redacted{sha256:%x}", h.Sum(nil)[:8])
+	case containsCode(p.Truncate, a.Code):
+		v = fmt.Sprintf("Redacted{%s}", cutValue(a.Data, p.Keep))
+	default:
+		return a.String()
+	}
+	return fmt.Sprintf("{Code:%d,Flags:0x%x,Length:%d,VendorId:%d,Value:%s}",
+		a.Code,
+		a.Flags,
+		a.Len(),
+		a.VendorID,
+		v,
+	)
+}
+
+// isText returns true if data is of a text data type.
+func isText(data datatype.Type) bool {
+	switch data.(type) {
+	case datatype.UTF8String, datatype.DiameterIdentity, datatype.DiameterURI:
+		return true
+	}
+	return false
+}
+
+// StringRedacted returns the string representation of the Message like
+// String, with the values of AVPs redacted according to p.
+func (m *Message) StringRedacted(p *RedactionProfile) string {
+	return m.format(p)
+}
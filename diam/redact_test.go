@@ -0,0 +1,58 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestMessage_StringRedacted(t *testing.T) {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String("001010123456789"))
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.SubscriptionIDType, avp.Mbit, 0, datatype.Enumerated(0)),
+			diam.NewAVP(avp.SubscriptionIDData, avp.Mbit, 0, datatype.UTF8String("5511999999999")),
+		},
+	})
+	p := &diam.RedactionProfile{
+		Hash:     []uint32{avp.SubscriptionIDData},
+		Truncate: []uint32{avp.UserName},
+		Keep:     5,
+	}
+	s := m.StringRedacted(p)
+	for _, secret := range []string{"001010123456789", "5511999999999"} {
+		if strings.Contains(s, secret) {
+			t.Fatalf("Value %q was not redacted:\n%s", secret, s)
+		}
+	}
+	for _, want := range []string{
+		"cli;1",
+		"Redacted{00101...}",
+		"Redacted{sha256:",
+		"Subscription-Id-Type",
+	} {
+		if !strings.Contains(s, want) {
+			t.Fatalf("Missing %q in:\n%s", want, s)
+		}
+	}
+	if s != m.StringRedacted(p) {
+		t.Fatal("Redaction is not deterministic")
+	}
+	if s == m.String() {
+		t.Fatal("Unexpected redaction without DefaultRedactionProfile")
+	}
+	diam.DefaultRedactionProfile = p
+	defer func() { diam.DefaultRedactionProfile = nil }()
+	if s != m.String() {
+		t.Fatal("DefaultRedactionProfile was not used by String")
+	}
+}
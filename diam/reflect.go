@@ -348,6 +348,9 @@ func (m *Message) Unmarshal(dst interface{}) error {
 	if v.Kind() != reflect.Ptr {
 		return errors.New("dst is not a pointer to struct")
 	}
+	if err := m.DecodeAll(); err != nil {
+		return err
+	}
 	return scanStruct(m, v, m.AVP)
 }
 
@@ -0,0 +1,84 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// ErrBudgetExpired is returned by Table.Forward for requests whose
+// timeout budget expired before they were forwarded. The requests are
+// answered with DIAMETER_UNABLE_TO_DELIVER (3002) instead.
+var ErrBudgetExpired = errors.New("request timeout budget expired")
+
+// Budget propagates the answer timeout of requests along the agents of
+// their path, so requests are not forwarded when the client already
+// gave up on them.
+//
+// The remaining budget is carried in an Unsigned32 AVP, in
+// milliseconds. Each agent forwards requests with the budget they were
+// received with, minus the time the agent held them and PerHop, and
+// waits for the answer no longer than the remaining budget. Diameter
+// has no standard AVP for it: all the agents of a path must agree on
+// the code and vendor of the AVP.
+type Budget struct {
+	Code     uint32 // Code of the AVP carrying the budget
+	VendorID uint32 // Vendor of the AVP, 0 for none
+
+	// Default is the budget of requests received without the AVP,
+	// e.g. from clients. Uses the timeout of the Table if zero.
+	Default time.Duration
+
+	// PerHop is subtracted from the budget at each hop, to account
+	// for the transit time to the next one.
+	PerHop time.Duration
+}
+
+// Remaining returns the remaining budget of the request m at the time
+// now, starting from def when m has no budget AVP. The time m was held
+// is measured from the time it was read, see diam.Meta.
+func (b *Budget) Remaining(m *diam.Message, now time.Time, def time.Duration) time.Duration {
+	if b.Default > 0 {
+		def = b.Default
+	}
+	left := def
+	for _, a := range m.AVP {
+		if a.Code != b.Code || a.VendorID != b.VendorID {
+			continue
+		}
+		if v, ok := a.Data.(datatype.Unsigned32); ok {
+			left = time.Duration(v) * time.Millisecond
+		}
+		break
+	}
+	if meta := m.Meta(); meta != nil && !meta.Received.IsZero() {
+		left -= now.Sub(meta.Received)
+	}
+	return left - b.PerHop
+}
+
+// set returns the AVPs of the request with the budget AVP set to left.
+// The AVPs are copied, not modified.
+func (b *Budget) set(avps []*diam.AVP, left time.Duration) []*diam.AVP {
+	var flags uint8
+	if b.VendorID != 0 {
+		flags = avp.Vbit
+	}
+	a := diam.NewAVP(b.Code, flags, b.VendorID, datatype.Unsigned32(left/time.Millisecond))
+	l := make([]*diam.AVP, len(avps), len(avps)+1)
+	copy(l, avps)
+	for i, c := range l {
+		if c.Code == b.Code && c.VendorID == b.VendorID {
+			l[i] = a
+			return l
+		}
+	}
+	return append(l, a)
+}
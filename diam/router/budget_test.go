@@ -0,0 +1,110 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestTable_Budget(t *testing.T) {
+	// The server records the budget of forwarded requests, and the
+	// answers written to the inbound connection.
+	budgets := make(chan datatype.Unsigned32, 1)
+	answers := make(chan *diam.Message, 1)
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		a, err := m.FindAVP(avp.AcctInterimInterval, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		budgets <- a.Data.(datatype.Unsigned32)
+	})
+	mux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		answers <- m
+	})
+	srv := diamtest.NewServer(mux, nil)
+	defer srv.Close()
+	in, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer in.Close()
+	out, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	clock := diamtest.NewFakeClock(time.Unix(0, 0))
+	table := New(10 * time.Second)
+	table.Clock = clock
+	// Any Unsigned32 AVP known to the dictionaries of the path.
+	table.Budget = &Budget{Code: avp.AcctInterimInterval, PerHop: 100 * time.Millisecond}
+	expired := make(chan *Pending, 1)
+	table.Expired = func(p *Pending) {
+		expired <- p
+	}
+
+	m := newCCR()
+	m.NewAVP(avp.AcctInterimInterval, avp.Mbit, 0, datatype.Unsigned32(1000))
+	if _, err = table.Forward(in, m, out); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case v := <-budgets:
+		if v != 900 {
+			t.Fatalf("Unexpected budget. Want 900, have %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCR")
+	}
+	if v, _ := m.FindAVP(avp.AcctInterimInterval, 0); v.Data != datatype.Unsigned32(1000) {
+		t.Fatalf("Unexpected budget in the original request: %v", v.Data)
+	}
+	// Answers are not waited for longer than the budget.
+	clock.Advance(900 * time.Millisecond)
+	select {
+	case <-expired:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for request to expire")
+	}
+
+	// Requests with no budget left are answered.
+	m = newCCR()
+	m.NewAVP(avp.AcctInterimInterval, avp.Mbit, 0, datatype.Unsigned32(50))
+	if _, err = table.Forward(in, m, out); err != ErrBudgetExpired {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrBudgetExpired, err)
+	}
+	select {
+	case a := <-answers:
+		rc, err := a.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.UnableToDeliver {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.UnableToDeliver, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	if n := table.Len(); n != 0 {
+		t.Fatalf("Unexpected pending requests. Want 0, have %d", n)
+	}
+
+	// Requests without the AVP get the timeout of the Table.
+	if _, err = table.Forward(in, newCCR(), out); err != nil {
+		t.Fatal(err)
+	}
+	if v := <-budgets; v != 9900 {
+		t.Fatalf("Unexpected budget. Want 9900, have %d", v)
+	}
+}
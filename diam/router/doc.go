@@ -0,0 +1,59 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package router provides the answer path of Diameter agents: relays
+// and proxies that forward requests from one connection to another.
+//
+// When a request is forwarded, its Hop-by-Hop Identifier is replaced
+// by one that is unique on the outbound connection, and the original
+// identifier and inbound connection are stored in a Table. When the
+// answer returns, the Table restores the original Hop-by-Hop Identifier
+// and writes the answer to the inbound connection. Requests that are
+// not answered within the timeout are removed from the Table.
+//
+// See RFC 6733 section 6.2 for details.
+//
+// A Table with a Budget propagates the timeout of requests to the next
+// hop in an AVP, shrinking it at each hop, and answers requests whose
+// budget expired with DIAMETER_UNABLE_TO_DELIVER instead of forwarding
+// them.
+//
+// Example:
+//
+//	t := router.New(10 * time.Second)
+//	t.Expired = func(p *router.Pending) {
+//		p.Request.Answer(diam.UnableToDeliver).WriteTo(p.Conn)
+//	}
+//	// Requests from the downstream peers.
+//	mux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+//		t.Forward(c, m, upstream)
+//	})
+//	// Answers from the upstream peer.
+//	mux.Handle("CCA", t)
+//
+// Proxies bridging peers that use different vendor code spaces can
+// re-map AVPs per peer with a Translator:
+//
+//	tr := &router.Translator{Peers: map[string]router.Translation{
+//		"legacy.example.com": {{9001, 5535}: {avp.RatingGroup, 0}},
+//	}}
+//	mux.Handle("CCR", tr.Handler(forwardCCR))
+//	mux.HandleEgress(tr.Egress())
+//
+// A Mirror duplicates a sample of the requests to a mirror peer, e.g.
+// a new OCS under validation, while clients get the primary's answers:
+//
+//	mirror := router.NewMirror(shadow, 10 * time.Second)
+//	mirror.Sample = 100
+//	mux.Handle("CCR", mirror.Handler(forwardCCR))
+//	shadowMux.Handle("CCA", mirror)
+//
+// Clients send requests with a Requester, which fails over to the next
+// peer when a peer does not answer in time, within the deadline of the
+// context of the request:
+//
+//	r := router.NewRequester(time.Second)
+//	mux.Handle("CCA", r)
+//	a, err := r.SendRequest(ctx, ccr, primary, secondary)
+package router
@@ -0,0 +1,124 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// Mirror duplicates requests to a mirror peer, e.g. to validate a new
+// server against production traffic. Clients only get the answers of
+// the primary server: answers from the mirror peer are passed to
+// Answered, if set, and dropped.
+//
+// Mirrored requests carry a new Hop-by-Hop Identifier, unique on the
+// mirror connection, and the End-to-End Identifier of the original.
+type Mirror struct {
+	// Commands is the list of command codes mirrored. All requests
+	// are mirrored when empty.
+	Commands []uint32
+
+	// Sample mirrors one in every Sample of the requests that match
+	// Commands. All of them are mirrored when Sample is 0 or 1.
+	Sample int
+
+	// Filter is called for requests that match Commands and
+	// returns false for the ones not to mirror. Optional.
+	Filter func(m *diam.Message) bool
+
+	// Answered is called with the mirrored requests and the answers
+	// of the mirror peer, for comparison with the primary. Optional.
+	Answered func(req, answer *diam.Message)
+
+	// Error is called when a request could not be written to the
+	// mirror peer. Optional.
+	Error func(m *diam.Message, err error)
+
+	// Clock is the source of time of the timeouts of mirrored
+	// requests. Must be set before calling Handler. Uses
+	// diam.SystemClock if unset.
+	Clock diam.Clock
+
+	out   diam.Conn
+	table *Table
+	count uint64
+}
+
+// NewMirror creates and initializes a new Mirror that duplicates
+// requests to the connection out, and waits for the answers of the
+// mirror peer for the given timeout, or DefaultTimeout if zero.
+//
+// Answers from the mirror peer must be passed to the Mirror, e.g.
+// registering it as the handler of the answers on out:
+//
+//	mux.Handle("CCA", mirror)
+func NewMirror(out diam.Conn, timeout time.Duration) *Mirror {
+	return &Mirror{out: out, table: New(timeout)}
+}
+
+// Handler returns a diam.Handler that mirrors the requests selected
+// by the Mirror before calling h, which handles them as usual.
+//
+// Requests are written to the mirror peer before h is called, so h
+// is free to modify or release them.
+func (mr *Mirror) Handler(h diam.Handler) diam.Handler {
+	mr.table.Clock = mr.Clock
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if mr.selects(m) {
+			if _, err := mr.table.Forward(c, m, mr.out); err != nil && mr.Error != nil {
+				mr.Error(m, err)
+			}
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+// ServeDIAM implements the diam.Handler interface. It takes the answers
+// of the mirror peer and passes them to Answered.
+func (mr *Mirror) ServeDIAM(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandFlags&diam.RequestFlag != 0 {
+		return
+	}
+	p, ok := mr.table.Route(c, m)
+	if ok && mr.Answered != nil {
+		mr.Answered(p.Request, m)
+	}
+}
+
+// Len returns the number of mirrored requests waiting for the answer
+// of the mirror peer.
+func (mr *Mirror) Len() int {
+	return mr.table.Len()
+}
+
+// selects returns true if the message m is to be mirrored.
+func (mr *Mirror) selects(m *diam.Message) bool {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return false
+	}
+	if len(mr.Commands) > 0 {
+		found := false
+		for _, code := range mr.Commands {
+			if code == m.Header.CommandCode {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if mr.Filter != nil && !mr.Filter(m) {
+		return false
+	}
+	if mr.Sample > 1 {
+		n := atomic.AddUint64(&mr.count, 1)
+		return n%uint64(mr.Sample) == 1
+	}
+	return true
+}
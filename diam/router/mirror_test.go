@@ -0,0 +1,105 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestMirror(t *testing.T) {
+	// The primary answers with success, the mirror peer with a failure.
+	pmux := diam.NewServeMux()
+	pmux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	primary := diamtest.NewServer(pmux, nil)
+	defer primary.Close()
+	mmux := diam.NewServeMux()
+	mmux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.UnableToComply).WriteTo(c)
+	})
+	mirrorSrv := diamtest.NewServer(mmux, nil)
+	defer mirrorSrv.Close()
+
+	table := New(time.Second)
+	pcmux := diam.NewServeMux()
+	pcmux.Handle("CCA", table)
+	pout, err := diam.Dial(primary.Addr, pcmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pout.Close()
+	mcmux := diam.NewServeMux()
+	mout, err := diam.Dial(mirrorSrv.Addr, mcmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer mout.Close()
+	mirror := NewMirror(mout, time.Second)
+	mirror.Sample = 2
+	mirror.Commands = []uint32{diam.CreditControl}
+	mirrored := make(chan *diam.Message, 4)
+	mirror.Answered = func(req, a *diam.Message) {
+		if req.Header.EndToEndID != a.Header.EndToEndID {
+			t.Errorf("Unexpected End-to-End ID. Want %#x, have %#x",
+				req.Header.EndToEndID, a.Header.EndToEndID)
+		}
+		mirrored <- a
+	}
+	mcmux.Handle("CCA", mirror)
+
+	amux := diam.NewServeMux()
+	amux.Handle("CCR", mirror.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if _, err := table.Forward(c, m, pout); err != nil {
+			t.Error(err)
+		}
+	})))
+	asrv := diamtest.NewServer(amux, nil)
+	defer asrv.Close()
+
+	mc := make(chan *diam.Message, 4)
+	c := dial(t, asrv.Addr, mc)
+	defer c.Close()
+	for i := 0; i < 4; i++ {
+		if _, err = newCCR().WriteTo(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case a := <-mc:
+			rc, err := a.FindAVP(avp.ResultCode, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := rc.Data.(datatype.Unsigned32); v != diam.Success {
+				t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, v)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for CCA")
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-mirrored:
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for mirrored CCA")
+		}
+	}
+	select {
+	case <-mirrored:
+		t.Fatal("Unexpected mirrored CCA, only half of the requests are sampled")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if n := mirror.Len(); n != 0 {
+		t.Fatalf("Unexpected pending requests. Want 0, have %d", n)
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// ErrAnswerTimeout is returned by Requester.SendRequest when no peer
+// answered the request within its timeout.
+var ErrAnswerTimeout = errors.New("timeout waiting for answer")
+
+// ErrNoPeers is returned by Requester.SendRequest when called without
+// peers.
+var ErrNoPeers = errors.New("no peers to send the request to")
+
+// Requester sends requests of clients to upstream peers and waits for
+// their answers, failing over to the next peer when a peer does not
+// answer in time or the request could not be written to it.
+//
+// Attempts are bounded by the deadline of the context of the request:
+// each attempt waits no longer than the timeout of the Requester nor
+// than the time left before the deadline, so a retry on a secondary
+// peer only uses the remaining budget instead of a fixed interval.
+//
+// Retransmissions carry the T flag and the End-to-End Identifier of
+// the original request, see RFC 6733 section 5.5.4.
+//
+// Answers from the peers must be passed to the Requester, e.g.
+// registering it as the handler of the answers:
+//
+//	r := router.NewRequester(time.Second)
+//	mux.Handle("CCA", r)
+//	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+//	defer cancel()
+//	a, err := r.SendRequest(ctx, ccr, primary, secondary)
+type Requester struct {
+	// IDs generates the Hop-by-Hop Identifiers of the requests
+	// sent. Uses diam.DefaultIDGenerator if unset.
+	IDs *diam.IDGenerator
+
+	// Clock is the source of time of the timeouts of attempts.
+	// Uses diam.SystemClock if unset.
+	Clock diam.Clock
+
+	// Budget, if set, sends the timeout of each attempt to the
+	// peer in the budget AVP, so agents in the path do not keep
+	// the request longer than the Requester waits for it. Optional.
+	Budget *Budget
+
+	// Orphan is called with the answers that match no request
+	// waited for, e.g. late answers of attempts that timed out,
+	// which are otherwise dropped. Optional.
+	Orphan OrphanFunc
+
+	timeout time.Duration
+	mu      sync.Mutex
+	pending map[key]chan *diam.Message
+	orphans uint64 // answers that matched no request
+}
+
+// NewRequester creates and initializes a new Requester that waits for
+// the answer of each peer for the given timeout, or DefaultTimeout if
+// zero.
+func NewRequester(timeout time.Duration) *Requester {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Requester{
+		timeout: timeout,
+		pending: make(map[key]chan *diam.Message),
+	}
+}
+
+// SendRequest writes the request m to the first of the peers and
+// returns its answer. When the peer does not answer in time, or the
+// request could not be written to it, the request is retransmitted to
+// the next peer, until the peers are exhausted or the deadline of ctx
+// expires. The message m is not modified.
+//
+// It returns the error of the last attempt, ErrAnswerTimeout when it
+// timed out, or the error of ctx when ctx is done.
+func (r *Requester) SendRequest(ctx context.Context, m *diam.Message, peers ...diam.Conn) (*diam.Message, error) {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return nil, ErrNotRequest
+	}
+	err := ErrNoPeers
+	for i, out := range peers {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		timeout, bounded := r.timeout, false
+		if deadline, ok := ctx.Deadline(); ok {
+			left := deadline.Sub(r.clock().Now())
+			if left <= 0 {
+				return nil, context.DeadlineExceeded
+			}
+			if left < timeout {
+				timeout, bounded = left, true
+			}
+		}
+		var a *diam.Message
+		a, err = r.attempt(ctx, m, out, timeout, i > 0)
+		if err == nil {
+			return a, nil
+		}
+		if err == ErrAnswerTimeout && bounded {
+			// The attempt used the rest of the budget.
+			return nil, context.DeadlineExceeded
+		}
+	}
+	return nil, err
+}
+
+// attempt writes the request m to the connection out and waits for its
+// answer for the given timeout.
+func (r *Requester) attempt(ctx context.Context, m *diam.Message, out diam.Conn, timeout time.Duration, retransmit bool) (*diam.Message, error) {
+	fm := *m
+	h := *m.Header
+	h.HopByHopID = r.ids().HopByHopID()
+	if retransmit {
+		h.CommandFlags |= diam.RetransmittedFlag
+	}
+	if r.Budget != nil {
+		left := timeout - r.Budget.PerHop
+		if left < 0 {
+			left = 0
+		}
+		fm.AVP = r.Budget.set(m.AVP, left)
+	}
+	h.MessageLength = uint32(fm.Len())
+	fm.Header = &h
+	k := key{out, h.HopByHopID}
+	ac := make(chan *diam.Message, 1)
+	r.mu.Lock()
+	r.pending[k] = ac
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		delete(r.pending, k)
+		r.mu.Unlock()
+	}()
+	expired := make(chan struct{})
+	timer := r.clock().AfterFunc(timeout, func() { close(expired) })
+	defer timer.Stop()
+	if _, err := fm.WriteToContext(ctx, out); err != nil {
+		return nil, err
+	}
+	select {
+	case a := <-ac:
+		a.Header.HopByHopID = m.Header.HopByHopID
+		return a, nil
+	case <-expired:
+		return nil, ErrAnswerTimeout
+	case <-out.Done():
+		return nil, out.Err()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// ServeDIAM implements the diam.Handler interface. It passes the
+// answers of the peers to the SendRequest calls waiting for them.
+// Answers of requests that are no longer waited for are dropped, see
+// Orphan.
+func (r *Requester) ServeDIAM(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandFlags&diam.RequestFlag != 0 {
+		return
+	}
+	k := key{c, m.Header.HopByHopID}
+	r.mu.Lock()
+	ac, ok := r.pending[k]
+	delete(r.pending, k)
+	if !ok {
+		r.orphans++
+	}
+	r.mu.Unlock()
+	if ok {
+		ac <- m
+	} else if r.Orphan != nil {
+		r.Orphan(c, m)
+	}
+}
+
+// Orphans returns the number of answers received that matched no
+// request waited for.
+func (r *Requester) Orphans() uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.orphans
+}
+
+// Len returns the number of requests waiting for their answer.
+func (r *Requester) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.pending)
+}
+
+func (r *Requester) ids() *diam.IDGenerator {
+	if r.IDs == nil {
+		return diam.DefaultIDGenerator
+	}
+	return r.IDs
+}
+
+func (r *Requester) clock() diam.Clock {
+	if r.Clock == nil {
+		return diam.SystemClock
+	}
+	return r.Clock
+}
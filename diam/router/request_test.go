@@ -0,0 +1,164 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestRequester_SendRequest(t *testing.T) {
+	// The primary never answers, the secondary records the requests
+	// and answers them.
+	pmux := diam.NewServeMux()
+	pmux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {})
+	primary := diamtest.NewServer(pmux, nil)
+	defer primary.Close()
+	reqs := make(chan *diam.Message, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		reqs <- m
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	secondary := diamtest.NewServer(smux, nil)
+	defer secondary.Close()
+
+	r := NewRequester(200 * time.Millisecond)
+	r.Budget = &Budget{Code: avp.AcctInterimInterval}
+	mux := diam.NewServeMux()
+	mux.Handle("CCA", r)
+	pout, err := diam.Dial(primary.Addr, mux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pout.Close()
+	sout, err := diam.Dial(secondary.Addr, mux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sout.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	m := newCCR()
+	a, err := r.SendRequest(ctx, m, pout, sout)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.HopByHopID != m.Header.HopByHopID {
+		t.Fatalf("Unexpected Hop-by-Hop ID. Want %#x, have %#x",
+			m.Header.HopByHopID, a.Header.HopByHopID)
+	}
+	var req *diam.Message
+	select {
+	case req = <-reqs:
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the retry")
+	}
+	if req.Header.CommandFlags&diam.RetransmittedFlag == 0 {
+		t.Fatal("Retransmitted request without T flag")
+	}
+	if req.Header.EndToEndID != m.Header.EndToEndID {
+		t.Fatalf("Unexpected End-to-End ID. Want %#x, have %#x",
+			m.Header.EndToEndID, req.Header.EndToEndID)
+	}
+	b, err := req.FindAVP(avp.AcctInterimInterval, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := b.Data.(datatype.Unsigned32); v != 200 {
+		t.Fatalf("Unexpected budget. Want 200, have %d", v)
+	}
+	if m.Header.CommandFlags&diam.RetransmittedFlag != 0 {
+		t.Fatal("Original request was modified")
+	}
+	if n := r.Len(); n != 0 {
+		t.Fatalf("Unexpected pending requests: %d", n)
+	}
+}
+
+func TestRequester_Deadline(t *testing.T) {
+	// Neither peer answers: the retry on the secondary only uses
+	// what is left of the deadline.
+	pmux := diam.NewServeMux()
+	pmux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {})
+	primary := diamtest.NewServer(pmux, nil)
+	defer primary.Close()
+	budgets := make(chan datatype.Unsigned32, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		if b, err := m.FindAVP(avp.AcctInterimInterval, 0); err == nil {
+			budgets <- b.Data.(datatype.Unsigned32)
+		}
+	})
+	secondary := diamtest.NewServer(smux, nil)
+	defer secondary.Close()
+
+	r := NewRequester(200 * time.Millisecond)
+	r.Budget = &Budget{Code: avp.AcctInterimInterval}
+	mux := diam.NewServeMux()
+	mux.Handle("CCA", r)
+	pout, err := diam.Dial(primary.Addr, mux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pout.Close()
+	sout, err := diam.Dial(secondary.Addr, mux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sout.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = r.SendRequest(ctx, newCCR(), pout, sout)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Fatalf("SendRequest took %s, past the deadline", d)
+	}
+	select {
+	case v := <-budgets:
+		if v > 100 {
+			t.Fatalf("Unexpected budget of the retry: %d", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the retry")
+	}
+	if _, err = r.SendRequest(ctx, newCCR(), pout, sout); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error after the deadline: %v", err)
+	}
+}
+
+func TestRequester_Orphan(t *testing.T) {
+	r := NewRequester(0)
+	orphans := make(chan *diam.Message, 1)
+	r.Orphan = func(c diam.Conn, m *diam.Message) {
+		orphans <- m
+	}
+	c := diamtest.NewFakeConn(nil, nil)
+	a := newCCR().Answer(diam.Success)
+	r.ServeDIAM(c, a)
+	select {
+	case m := <-orphans:
+		if m != a {
+			t.Fatalf("Unexpected orphan: %s", m)
+		}
+	default:
+		t.Fatal("Late answer not reported as orphan")
+	}
+	if n := r.Orphans(); n != 1 {
+		t.Fatalf("Unexpected # of orphans. Want 1, have %d", n)
+	}
+}
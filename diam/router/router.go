@@ -0,0 +1,249 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// DefaultTimeout is the time requests are kept in the Table waiting for
+// their answer, when unset in New.
+const DefaultTimeout = 30 * time.Second
+
+// ErrNotRequest is returned by Table.Forward for messages that are not
+// requests.
+var ErrNotRequest = errors.New("message is not a request")
+
+// Pending is a forwarded request waiting for its answer.
+type Pending struct {
+	Conn       diam.Conn     // Inbound connection, the answer is sent to
+	HopByHopID uint32        // Original Hop-by-Hop Identifier
+	Request    *diam.Message // Forwarded request, with the original identifier
+	Out        diam.Conn     // Outbound connection, the request was sent to
+	Sent       time.Time     // Time the request was forwarded
+
+	timer diam.Timer
+}
+
+// OrphanFunc is called with the answers received from the connection
+// c that match no pending request, e.g. late answers of requests that
+// expired, for logging or analysis.
+type OrphanFunc func(c diam.Conn, m *diam.Message)
+
+type key struct {
+	conn       diam.Conn
+	hopByHopID uint32
+}
+
+// tableShards is the number of shards of a Table, a power of two.
+const tableShards = 64
+
+// shard is a part of the forwarded requests of a Table, selected by
+// their Hop-by-Hop Identifier, so requests forwarded concurrently
+// rarely contend for the same lock.
+type shard struct {
+	mu      sync.Mutex
+	pending map[key]*Pending
+	orphans uint64   // answers that matched no request
+	_       [40]byte // pads shards to separate cache lines
+}
+
+// Table stores the forwarded requests of an agent and routes their
+// answers back to the inbound connection. It is safe for concurrent
+// use, and the requests are sharded by Hop-by-Hop Identifier so it
+// scales with the number of cores.
+type Table struct {
+	// IDs generates the Hop-by-Hop Identifiers of forwarded
+	// requests. Uses diam.DefaultIDGenerator if unset.
+	IDs *diam.IDGenerator
+
+	// Expired is called when a request is removed from the Table
+	// because it was not answered within the timeout. Agents
+	// typically answer with diam.UnableToDeliver. Optional.
+	Expired func(p *Pending)
+
+	// Clock is the source of time of the Table. Uses
+	// diam.SystemClock if unset.
+	Clock diam.Clock
+
+	// Budget, if set, propagates the timeout of requests to the
+	// next hop, and answers requests whose budget expired instead
+	// of forwarding them. Requests wait for their answer no longer
+	// than their remaining budget. Optional.
+	Budget *Budget
+
+	// Orphan is called with the answers that match no request in
+	// the Table, which are otherwise dropped. Optional.
+	Orphan OrphanFunc
+
+	timeout time.Duration
+	shards  [tableShards]shard
+}
+
+// New creates and initializes a new Table that keeps requests for the
+// given timeout, or DefaultTimeout if zero.
+func New(timeout time.Duration) *Table {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	t := &Table{timeout: timeout}
+	for i := range t.shards {
+		t.shards[i].pending = make(map[key]*Pending)
+	}
+	return t
+}
+
+// shard returns the shard of the requests with the key k.
+func (t *Table) shard(k key) *shard {
+	return &t.shards[k.hopByHopID&(tableShards-1)]
+}
+
+// Forward writes the request m, received from the connection in, to the
+// connection out with a new Hop-by-Hop Identifier, and stores the
+// original one until the answer is routed. The message m is not
+// modified.
+func (t *Table) Forward(in diam.Conn, m *diam.Message, out diam.Conn) (int64, error) {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return 0, ErrNotRequest
+	}
+	now := t.clock().Now()
+	fm := *m
+	timeout := t.timeout
+	if t.Budget != nil {
+		left := t.Budget.Remaining(m, now, t.timeout)
+		if left <= 0 {
+			m.Answer(diam.UnableToDeliver).WriteTo(in)
+			return 0, ErrBudgetExpired
+		}
+		if left < timeout {
+			timeout = left
+		}
+		fm.AVP = t.Budget.set(m.AVP, left)
+	}
+	p := &Pending{
+		Conn:       in,
+		HopByHopID: m.Header.HopByHopID,
+		Request:    m,
+		Out:        out,
+		Sent:       now,
+	}
+	k := key{out, t.ids().HopByHopID()}
+	s := t.shard(k)
+	s.mu.Lock()
+	s.pending[k] = p
+	p.timer = t.clock().AfterFunc(timeout, func() { t.expire(k, p) })
+	s.mu.Unlock()
+	h := *m.Header
+	h.HopByHopID = k.hopByHopID
+	h.MessageLength = uint32(fm.Len())
+	fm.Header = &h
+	n, err := fm.WriteTo(out)
+	if err != nil {
+		t.remove(k, p)
+	}
+	return n, err
+}
+
+// Route removes the request answered by m, received from the connection
+// out, from the Table and restores the original Hop-by-Hop Identifier
+// of the answer. It returns false if the request is not in the Table,
+// e.g. because it expired, and the answer is counted as an orphan and
+// passed to Orphan.
+func (t *Table) Route(out diam.Conn, m *diam.Message) (*Pending, bool) {
+	k := key{out, m.Header.HopByHopID}
+	s := t.shard(k)
+	s.mu.Lock()
+	p, ok := s.pending[k]
+	if ok {
+		delete(s.pending, k)
+		p.timer.Stop()
+	} else {
+		s.orphans++
+	}
+	s.mu.Unlock()
+	if !ok {
+		if t.Orphan != nil {
+			t.Orphan(out, m)
+		}
+		return nil, false
+	}
+	m.Header.HopByHopID = p.HopByHopID
+	return p, true
+}
+
+// ServeDIAM implements the diam.Handler interface. It routes answers
+// to the inbound connection of their request. Answers of requests not
+// in the Table are dropped, see Orphan.
+func (t *Table) ServeDIAM(c diam.Conn, m *diam.Message) {
+	if m.Header.CommandFlags&diam.RequestFlag != 0 {
+		return
+	}
+	if p, ok := t.Route(c, m); ok {
+		m.WriteTo(p.Conn)
+	}
+}
+
+// Len returns the number of requests waiting for their answer.
+func (t *Table) Len() int {
+	n := 0
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mu.Lock()
+		n += len(s.pending)
+		s.mu.Unlock()
+	}
+	return n
+}
+
+// Orphans returns the number of answers received that matched no
+// request in the Table.
+func (t *Table) Orphans() uint64 {
+	var n uint64
+	for i := range t.shards {
+		s := &t.shards[i]
+		s.mu.Lock()
+		n += s.orphans
+		s.mu.Unlock()
+	}
+	return n
+}
+
+func (t *Table) ids() *diam.IDGenerator {
+	if t.IDs == nil {
+		return diam.DefaultIDGenerator
+	}
+	return t.IDs
+}
+
+func (t *Table) clock() diam.Clock {
+	if t.Clock == nil {
+		return diam.SystemClock
+	}
+	return t.Clock
+}
+
+// remove removes p from the Table, and returns false if it was no
+// longer there.
+func (t *Table) remove(k key, p *Pending) bool {
+	s := t.shard(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending[k] != p {
+		return false
+	}
+	delete(s.pending, k)
+	p.timer.Stop()
+	return true
+}
+
+func (t *Table) expire(k key, p *Pending) {
+	if t.remove(k, p) && t.Expired != nil {
+		t.Expired(p)
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+// agent starts an upstream server that answers CCR with the given
+// handler, and an agent that forwards CCR to it using a new Table.
+// It returns the Table and the address of the agent.
+func agent(t *testing.T, timeout time.Duration, upstream diam.HandlerFunc) (*Table, string, func()) {
+	umux := diam.NewServeMux()
+	umux.HandleFunc("CCR", upstream)
+	usrv := diamtest.NewServer(umux, nil)
+	table := New(timeout)
+	omux := diam.NewServeMux()
+	omux.Handle("CCA", table)
+	out, err := diam.Dial(usrv.Addr, omux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	amux := diam.NewServeMux()
+	amux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		if _, err := table.Forward(c, m, out); err != nil {
+			t.Error(err)
+		}
+	})
+	asrv := diamtest.NewServer(amux, nil)
+	return table, asrv.Addr, func() {
+		asrv.Close()
+		out.Close()
+		usrv.Close()
+	}
+}
+
+func dial(t *testing.T, addr string, mc chan *diam.Message) diam.Conn {
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	c, err := diam.Dial(addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func newCCR() *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	return m
+}
+
+func TestTable_Route(t *testing.T) {
+	hbh := make(chan uint32, 1)
+	table, addr, done := agent(t, time.Second, func(c diam.Conn, m *diam.Message) {
+		hbh <- m.Header.HopByHopID
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	defer done()
+	mc := make(chan *diam.Message, 1)
+	c := dial(t, addr, mc)
+	defer c.Close()
+	m := newCCR()
+	if _, err := m.WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-mc:
+		if a.Header.HopByHopID != m.Header.HopByHopID {
+			t.Fatalf("Unexpected Hop-by-Hop ID. Want %#x, have %#x",
+				m.Header.HopByHopID, a.Header.HopByHopID)
+		}
+		if a.Header.EndToEndID != m.Header.EndToEndID {
+			t.Fatalf("Unexpected End-to-End ID. Want %#x, have %#x",
+				m.Header.EndToEndID, a.Header.EndToEndID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	if id := <-hbh; id == m.Header.HopByHopID {
+		t.Fatal("Hop-by-Hop ID was not replaced in the forwarded request")
+	}
+	if n := table.Len(); n != 0 {
+		t.Fatalf("Unexpected pending requests. Want 0, have %d", n)
+	}
+}
+
+func TestTable_Expired(t *testing.T) {
+	table, addr, done := agent(t, 50*time.Millisecond, func(c diam.Conn, m *diam.Message) {})
+	defer done()
+	expired := make(chan *Pending, 1)
+	table.Expired = func(p *Pending) {
+		expired <- p
+		a := p.Request.Answer(diam.UnableToDeliver)
+		a.Header.CommandFlags |= diam.ErrorFlag
+		a.WriteTo(p.Conn)
+	}
+	mc := make(chan *diam.Message, 1)
+	c := dial(t, addr, mc)
+	defer c.Close()
+	m := newCCR()
+	if _, err := m.WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case p := <-expired:
+		if p.HopByHopID != m.Header.HopByHopID {
+			t.Fatalf("Unexpected Hop-by-Hop ID. Want %#x, have %#x",
+				m.Header.HopByHopID, p.HopByHopID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for request to expire")
+	}
+	select {
+	case a := <-mc:
+		if a.Header.HopByHopID != m.Header.HopByHopID {
+			t.Fatalf("Unexpected Hop-by-Hop ID. Want %#x, have %#x",
+				m.Header.HopByHopID, a.Header.HopByHopID)
+		}
+		rc, err := a.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.UnableToDeliver {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.UnableToDeliver, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	if n := table.Len(); n != 0 {
+		t.Fatalf("Unexpected pending requests. Want 0, have %d", n)
+	}
+}
+
+func TestTable_Clock(t *testing.T) {
+	clock := diamtest.NewFakeClock(time.Unix(0, 0))
+	table := New(10 * time.Second)
+	table.Clock = clock
+	expired := make(chan *Pending, 1)
+	table.Expired = func(p *Pending) {
+		expired <- p
+	}
+	mux := diam.NewServeMux()
+	srv := diamtest.NewServer(mux, nil)
+	defer srv.Close()
+	out, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+	if _, err = table.Forward(nil, newCCR(), out); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(9 * time.Second)
+	if n := table.Len(); n != 1 {
+		t.Fatalf("Unexpected pending requests. Want 1, have %d", n)
+	}
+	clock.Advance(time.Second)
+	select {
+	case p := <-expired:
+		if !p.Sent.Equal(time.Unix(0, 0)) {
+			t.Fatalf("Unexpected send time: %s", p.Sent)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for request to expire")
+	}
+}
+
+func TestTable_RouteUnknown(t *testing.T) {
+	table := New(0)
+	var orphan *diam.Message
+	table.Orphan = func(c diam.Conn, m *diam.Message) {
+		orphan = m
+	}
+	a := newCCR().Answer(diam.Success)
+	if _, ok := table.Route(nil, a); ok {
+		t.Fatal("Unexpected route for unknown answer")
+	}
+	if orphan != a || table.Orphans() != 1 {
+		t.Fatalf("Unknown answer not reported as orphan: %d orphans", table.Orphans())
+	}
+	if _, err := table.Forward(nil, a, nil); err != ErrNotRequest {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNotRequest, err)
+	}
+}
+
+// discardConn is a diam.Conn that discards the messages written to it,
+// keeping the Hop-by-Hop Identifier of the last one.
+type discardConn struct {
+	diam.Conn
+	hopByHopID uint32
+}
+
+func (c *discardConn) Write(b []byte) (int, error) {
+	c.hopByHopID = binary.BigEndian.Uint32(b[12:16])
+	return len(b), nil
+}
+
+// BenchmarkTable forwards requests and routes their answers from
+// parallel goroutines. Run it with -cpu 1,2,4,8 to check that the Table
+// scales with the number of cores.
+func BenchmarkTable(b *testing.B) {
+	table := New(time.Minute)
+	in := &discardConn{}
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		out := &discardConn{}
+		m := newCCR()
+		a := m.Answer(diam.Success)
+		for pb.Next() {
+			if _, err := table.Forward(in, m, out); err != nil {
+				b.Fatal(err)
+			}
+			a.Header.HopByHopID = out.hopByHopID
+			if _, ok := table.Route(out, a); !ok {
+				b.Fatal("Answer not routed")
+			}
+		}
+	})
+	if n := table.Len(); n != 0 {
+		b.Fatalf("Unexpected pending requests: %d", n)
+	}
+}
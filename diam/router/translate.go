@@ -0,0 +1,163 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+// AVPKey identifies an AVP by code and vendor id.
+type AVPKey struct {
+	Code     uint32
+	VendorID uint32
+}
+
+// Translation maps AVPs from one vendor code space to another, e.g.
+// a proprietary AVP of a legacy peer to the standard Rating-Group:
+//
+//	router.Translation{{9001, 5535}: {avp.RatingGroup, 0}}
+type Translation map[AVPKey]AVPKey
+
+// Reverse returns the translation in the opposite direction.
+func (tr Translation) Reverse() Translation {
+	r := make(Translation, len(tr))
+	for from, to := range tr {
+		r[to] = from
+	}
+	return r
+}
+
+// Apply translates the AVPs of the message m, including the AVPs
+// embedded in grouped AVPs. The V bit of translated AVPs is set
+// according to their new vendor id, and their data is decoded again
+// with the data type of the new AVP in the dictionary of m, if any.
+//
+// Translated AVPs, and the grouped AVPs that contain them, are copies:
+// AVPs of m shared with other messages are not modified.
+func (tr Translation) Apply(m *diam.Message) {
+	if len(tr) == 0 {
+		return
+	}
+	if avps, ok := tr.apply(m, m.AVP); ok {
+		m.AVP = avps
+		m.Header.MessageLength = uint32(m.Len())
+	}
+}
+
+// apply returns the translation of avps, and false if none of them
+// was translated.
+func (tr Translation) apply(m *diam.Message, avps []*diam.AVP) ([]*diam.AVP, bool) {
+	var r []*diam.AVP
+	for i, a := range avps {
+		t := tr.translate(m, a)
+		if t == a {
+			if r != nil {
+				r = append(r, a)
+			}
+			continue
+		}
+		if r == nil {
+			r = make([]*diam.AVP, i, len(avps))
+			copy(r, avps[:i])
+		}
+		r = append(r, t)
+	}
+	return r, r != nil
+}
+
+// translate returns the translation of a, or a itself when unchanged.
+func (tr Translation) translate(m *diam.Message, a *diam.AVP) *diam.AVP {
+	data := a.Data
+	if g, ok := a.Data.(*diam.GroupedAVP); ok {
+		if avps, ok := tr.apply(m, g.AVP); ok {
+			data = &diam.GroupedAVP{AVP: avps}
+		}
+	}
+	to, ok := tr[AVPKey{a.Code, a.VendorID}]
+	if !ok {
+		if data == a.Data {
+			return a
+		}
+		return diam.NewAVP(a.Code, a.Flags, a.VendorID, data)
+	}
+	flags := a.Flags &^ avp.Vbit
+	if _, grouped := data.(*diam.GroupedAVP); !grouped {
+		data = decodeAs(m, to, data)
+	}
+	return diam.NewAVP(to.Code, flags, to.VendorID, data)
+}
+
+// decodeAs decodes data with the data type of the AVP k in the
+// dictionary of m, or returns data unchanged if k is unknown.
+func decodeAs(m *diam.Message, k AVPKey, data datatype.Type) datatype.Type {
+	dictAVP, err := m.Dictionary().FindAVPWithVendor(
+		m.Header.ApplicationID,
+		k.Code,
+		k.VendorID,
+	)
+	if err != nil || dictAVP.Data.Type == data.Type() ||
+		dictAVP.Data.Type == datatype.GroupedType {
+		return data
+	}
+	v, err := datatype.Decode(dictAVP.Data.Type, data.Serialize())
+	if err != nil {
+		return data
+	}
+	return v
+}
+
+// Translator applies per-peer AVP translations, for proxies that bridge
+// peers using different vendor code spaces. Peers are identified by
+// the Origin-Host they advertised in the CER/CEA handshake.
+//
+// AVPs of messages received from a peer are translated with its
+// Translation, and AVPs of messages sent to it with the reverse.
+// Peers must not be modified after the Translator is used.
+type Translator struct {
+	Peers map[string]Translation // Translations by peer Origin-Host
+}
+
+// Handler returns a diam.Handler that translates the messages received
+// from peers before calling h.
+func (t *Translator) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		t.peer(c).Apply(m)
+		h.ServeDIAM(c, m)
+	})
+}
+
+// Egress returns a diam.EgressFunc that translates the messages sent
+// to peers, in reverse.
+func (t *Translator) Egress() diam.EgressFunc {
+	reverse := make(map[string]Translation, len(t.Peers))
+	for host, tr := range t.Peers {
+		reverse[host] = tr.Reverse()
+	}
+	return func(c diam.Conn, m *diam.Message) error {
+		if host, ok := peerHost(c); ok {
+			reverse[host].Apply(m)
+		}
+		return nil
+	}
+}
+
+// peer returns the Translation of the peer of c, or nil.
+func (t *Translator) peer(c diam.Conn) Translation {
+	if host, ok := peerHost(c); ok {
+		return t.Peers[host]
+	}
+	return nil
+}
+
+func peerHost(c diam.Conn) (string, bool) {
+	meta, ok := smpeer.FromContext(c.Context())
+	if !ok {
+		return "", false
+	}
+	return string(meta.OriginHost), true
+}
@@ -0,0 +1,122 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package router
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+var testTranslation = Translation{{9001, 5535}: {avp.RatingGroup, 0}}
+
+// legacyCCR returns a CCR with the proprietary rating group AVP 9001 of
+// vendor 5535, as decoded in relay mode.
+func legacyCCR() *diam.Message {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.MultipleServicesCreditControl, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(9001, avp.Mbit, 5535, datatype.Raw{Payload: []byte{0, 0, 0, 10}}),
+		},
+	})
+	return m
+}
+
+func TestTranslation_Apply(t *testing.T) {
+	m := legacyCCR()
+	orig := m.AVP[1]
+	testTranslation.Apply(m)
+	if m.AVP[0] == nil || m.AVP[0].Code != avp.SessionID {
+		t.Fatalf("Unexpected AVP: %s", m.AVP[0])
+	}
+	g := m.AVP[1].Data.(*diam.GroupedAVP)
+	rg := g.AVP[0]
+	if rg.Code != avp.RatingGroup || rg.VendorID != 0 {
+		t.Fatalf("Unexpected AVP. Want Rating-Group, have %s", rg)
+	}
+	if rg.Flags != avp.Mbit {
+		t.Fatalf("Unexpected flags. Want %#x, have %#x", avp.Mbit, rg.Flags)
+	}
+	if v, ok := rg.Data.(datatype.Unsigned32); !ok || v != 10 {
+		t.Fatalf("Unexpected Rating-Group. Want Unsigned32{10}, have %s", rg.Data)
+	}
+	if a := orig.Data.(*diam.GroupedAVP).AVP[0]; a.Code != 9001 {
+		t.Fatalf("Original AVP was modified: %s", a)
+	}
+	if _, err := m.FindAVP(avp.RatingGroup, 0); err != nil {
+		t.Fatal(err)
+	}
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := m.Header.MessageLength; int(n) != len(b) {
+		t.Fatalf("Unexpected Message-Length. Want %d, have %d", len(b), n)
+	}
+	if _, err = diam.ReadMessage(bytes.NewReader(b), m.Dictionary()); err != nil {
+		t.Fatal(err)
+	}
+
+	testTranslation.Reverse().Apply(m)
+	a := m.AVP[1].Data.(*diam.GroupedAVP).AVP[0]
+	if a.Code != 9001 || a.VendorID != 5535 || a.Flags != avp.Mbit|avp.Vbit {
+		t.Fatalf("Unexpected AVP. Want 9001 of vendor 5535, have %s", a)
+	}
+	if b, err = m.Serialize(); err != nil {
+		t.Fatal(err)
+	}
+	legacy := legacyCCR()
+	legacy.Header.HopByHopID = m.Header.HopByHopID
+	legacy.Header.EndToEndID = m.Header.EndToEndID
+	o, err := legacy.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(o) {
+		t.Fatalf("Unexpected message after translation and reverse.\nWant %x\nHave %x", o, b)
+	}
+}
+
+// peerConn is a diam.Conn of a peer that passed the handshake.
+type peerConn struct {
+	diam.Conn
+	ctx context.Context
+}
+
+func (c *peerConn) Context() context.Context { return c.ctx }
+
+func newPeerConn(host string) diam.Conn {
+	meta := &smpeer.Metadata{OriginHost: datatype.DiameterIdentity(host)}
+	return &peerConn{ctx: smpeer.NewContext(context.Background(), meta)}
+}
+
+func TestTranslator(t *testing.T) {
+	tr := &Translator{Peers: map[string]Translation{"legacy": testTranslation}}
+	var have *diam.Message
+	h := tr.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		have = m
+	}))
+	h.ServeDIAM(newPeerConn("other"), legacyCCR())
+	if a := have.AVP[1].Data.(*diam.GroupedAVP).AVP[0]; a.Code != 9001 {
+		t.Fatalf("Unexpected translation for other peer: %s", a)
+	}
+	h.ServeDIAM(newPeerConn("legacy"), legacyCCR())
+	if a := have.AVP[1].Data.(*diam.GroupedAVP).AVP[0]; a.Code != avp.RatingGroup {
+		t.Fatalf("Unexpected AVP. Want Rating-Group, have %s", a)
+	}
+	if err := tr.Egress()(newPeerConn("legacy"), have); err != nil {
+		t.Fatal(err)
+	}
+	if a := have.AVP[1].Data.(*diam.GroupedAVP).AVP[0]; a.Code != 9001 {
+		t.Fatalf("Unexpected AVP. Want 9001, have %s", a)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// SCTP transport for Linux, using one-to-one style sockets (RFC 6458
+// section 4), which behave like TCP sockets: Listen and Dial wrap them
+// in the net package, so SCTP connections are served like TCP ones.
+//
+// Associations use a single address of each peer; multi-homing is not
+// configured.
+
+func init() {
+	for network, tcp := range map[string]string{
+		"sctp":  "tcp",
+		"sctp4": "tcp4",
+		"sctp6": "tcp6",
+	} {
+		RegisterNetwork(network, sctpListener(tcp))
+		RegisterDialer(network, sctpDialer(tcp))
+	}
+}
+
+func sctpListener(tcp string) ListenFunc {
+	return func(addr string) (net.Listener, error) {
+		laddr, err := net.ResolveTCPAddr(tcp, addr)
+		if err != nil {
+			return nil, err
+		}
+		fd, sa, err := sctpSocket(tcp, laddr)
+		if err != nil {
+			return nil, sctpError("listen", addr, err)
+		}
+		syscall.SetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+		if err = syscall.Bind(fd, sa); err == nil {
+			err = syscall.Listen(fd, syscall.SOMAXCONN)
+		}
+		if err != nil {
+			syscall.Close(fd)
+			return nil, sctpError("listen", addr, err)
+		}
+		f := os.NewFile(uintptr(fd), "sctp:"+addr)
+		defer f.Close()
+		return net.FileListener(f)
+	}
+}
+
+func sctpDialer(tcp string) DialFunc {
+	return func(addr string) (net.Conn, error) {
+		raddr, err := net.ResolveTCPAddr(tcp, addr)
+		if err != nil {
+			return nil, err
+		}
+		if raddr.IP == nil {
+			raddr.IP = net.IPv4(127, 0, 0, 1)
+		}
+		fd, sa, err := sctpSocket(tcp, raddr)
+		if err != nil {
+			return nil, sctpError("dial", addr, err)
+		}
+		if err = syscall.Connect(fd, sa); err != nil {
+			syscall.Close(fd)
+			return nil, sctpError("dial", addr, err)
+		}
+		f := os.NewFile(uintptr(fd), "sctp:"+addr)
+		defer f.Close()
+		return net.FileConn(f)
+	}
+}
+
+// sctpSocket returns a one-to-one SCTP socket of the family of addr,
+// and the socket address of addr. Addresses without IP use IPv6 with
+// IPv4-mapped addresses, unless tcp is "tcp4".
+func sctpSocket(tcp string, addr *net.TCPAddr) (int, syscall.Sockaddr, error) {
+	ip4 := addr.IP.To4()
+	if tcp == "tcp4" || (ip4 != nil && tcp != "tcp6") {
+		sa := &syscall.SockaddrInet4{Port: addr.Port}
+		if ip4 != nil {
+			copy(sa.Addr[:], ip4)
+		}
+		fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_SCTP)
+		return fd, sa, err
+	}
+	sa := &syscall.SockaddrInet6{Port: addr.Port}
+	copy(sa.Addr[:], addr.IP.To16())
+	fd, err := syscall.Socket(syscall.AF_INET6, syscall.SOCK_STREAM|syscall.SOCK_CLOEXEC, syscall.IPPROTO_SCTP)
+	if err == nil && tcp != "tcp6" {
+		syscall.SetsockoptInt(fd, syscall.IPPROTO_IPV6, syscall.IPV6_V6ONLY, 0)
+	}
+	return fd, sa, err
+}
+
+func sctpError(op, addr string, err error) error {
+	return &net.OpError{Op: op, Net: "sctp", Addr: sctpAddr(addr), Err: os.NewSyscallError("sctp", err)}
+}
+
+// sctpAddr is the net.Addr of errors.
+type sctpAddr string
+
+func (a sctpAddr) Network() string { return "sctp" }
+func (a sctpAddr) String() string  { return string(a) }
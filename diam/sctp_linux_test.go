@@ -0,0 +1,48 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+func TestSCTP(t *testing.T) {
+	l, err := diam.Listen("sctp", "127.0.0.1:0", nil)
+	if err != nil {
+		if oe, ok := err.(*net.OpError); ok {
+			if se, ok := oe.Err.(*os.SyscallError); ok && se.Err == syscall.EPROTONOSUPPORT {
+				t.Skip("SCTP is not supported by the kernel")
+			}
+		}
+		t.Fatal(err)
+	}
+	smux := diam.NewServeMux()
+	smux.Handle("CER", handleCER(make(chan error, 1), false))
+	srv := &diam.Server{Handler: smux}
+	go srv.Serve(l)
+	defer l.Close()
+	wait := make(chan struct{})
+	cmux := diam.NewServeMux()
+	cmux.Handle("CEA", handleCEA(make(chan error, 1), wait))
+	cli, err := diam.DialNetwork("sctp", l.Addr().String(), cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	sendCER(cli)
+	select {
+	case <-wait:
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: no CEA received over SCTP")
+	}
+}
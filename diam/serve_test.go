@@ -5,16 +5,20 @@
 package diam_test
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/ibrohimislam/go-diameter/diam"
 	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
 )
 
 func TestCapabilitiesExchange(t *testing.T) {
@@ -203,3 +207,600 @@ func handleCEA(errc chan error, wait chan struct{}) diam.HandlerFunc {
 		<-c.(diam.CloseNotifier).CloseNotify()
 	}
 }
+
+func TestServer_ReloadDict(t *testing.T) {
+	base, err := dict.NewParser("dict/testdata/base.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{}, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		done <- struct{}{}
+	})
+	srv := diamtest.NewServer(smux, base)
+	defer srv.Close()
+	sendCCR := func() {
+		cli, err := diam.Dial(srv.Addr, nil, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cli.Close()
+		m := diam.NewRequest(diam.CreditControl, 4, nil)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		if _, err = m.WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Credit control is not in the base dictionary.
+	sendCCR()
+	select {
+	case <-smux.ErrorReports():
+	case <-done:
+		t.Fatal("Unexpected CCR decoded with the base dictionary")
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for error report")
+	}
+	srv.Config.ReloadDict(dict.Default)
+	sendCCR()
+	select {
+	case <-done:
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CCR")
+	}
+}
+
+func TestServer_ServeListeners(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.Handle("CER", handleCER(make(chan error, 1), false))
+	srv := &diam.Server{Handler: smux}
+	var ls []net.Listener
+	for _, network := range []string{"tcp", "tcp4"} {
+		l, err := diam.Listen(network, "127.0.0.1:0", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ls = append(ls, l)
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- srv.ServeListeners(ls...) }()
+	for _, l := range ls {
+		wait := make(chan struct{})
+		cmux := diam.NewServeMux()
+		cmux.Handle("CEA", handleCEA(make(chan error, 1), wait))
+		cli, err := diam.Dial(l.Addr().String(), cmux, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sendCER(cli)
+		select {
+		case <-wait:
+		case err := <-smux.ErrorReports():
+			t.Fatal(err)
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out: no CEA received from %s", l.Addr())
+		}
+		cli.Close()
+	}
+	ls[0].Close()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Fatal("Unexpected nil error from ServeListeners")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for ServeListeners to return")
+	}
+	if _, err := ls[1].Accept(); err == nil {
+		t.Fatal("Listener was not closed")
+	}
+}
+
+func TestDialNetwork(t *testing.T) {
+	dials := make(chan string, 1)
+	diam.RegisterDialer("test", func(addr string) (net.Conn, error) {
+		dials <- addr
+		return net.Dial("tcp", addr)
+	})
+	smux := diam.NewServeMux()
+	smux.Handle("CER", handleCER(make(chan error, 1), false))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	wait := make(chan struct{})
+	cmux := diam.NewServeMux()
+	cmux.Handle("CEA", handleCEA(make(chan error, 1), wait))
+	cli, err := diam.DialNetwork("test", srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if addr := <-dials; addr != srv.Addr {
+		t.Fatalf("Unexpected address. Want %s, have %s", srv.Addr, addr)
+	}
+	sendCER(cli)
+	select {
+	case <-wait:
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: no CEA received")
+	}
+	if _, err = diam.DialNetwork("foobar", srv.Addr, nil, nil); err == nil {
+		t.Fatal("Unexpected nil error for unknown network")
+	}
+}
+
+func TestDialContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	diam.RegisterDialer("test-blocking", func(addr string) (net.Conn, error) {
+		<-release
+		return nil, errors.New("released")
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	srv := &diam.Server{Addr: "localhost:3868", Network: "test-blocking"}
+	if _, err := srv.DialContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.DeadlineExceeded, err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := diam.DialContext(ctx, "127.0.0.1:1", nil, nil); err != context.Canceled {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.Canceled, err)
+	}
+}
+
+func TestServer_Dialer(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.Handle("CER", handleCER(make(chan error, 1), false))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	dials := make(chan string, 1)
+	wait := make(chan struct{})
+	cmux := diam.NewServeMux()
+	cmux.Handle("CEA", handleCEA(make(chan error, 1), wait))
+	cs := &diam.Server{
+		Addr:    "peer.example.com:3868",
+		Network: "tunnel",
+		Handler: cmux,
+		Dialer: diam.DialFunc(func(addr string) (net.Conn, error) {
+			dials <- addr
+			return net.Dial("tcp", srv.Addr)
+		}),
+	}
+	cli, err := cs.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if addr := <-dials; addr != cs.Addr {
+		t.Fatalf("Unexpected address. Want %s, have %s", cs.Addr, addr)
+	}
+	sendCER(cli)
+	select {
+	case <-wait:
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: no CEA received")
+	}
+	cs.Dialer = &net.Dialer{}
+	cs.Network, cs.Addr = "tcp", srv.Addr
+	if cli, err = cs.Dial(); err != nil {
+		t.Fatal(err)
+	}
+	cli.Close()
+}
+
+func TestServer_LocalAddr(t *testing.T) {
+	srv := diamtest.NewServer(diam.NewServeMux(), nil)
+	defer srv.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	laddr := l.Addr().String()
+	l.Close()
+	cs := &diam.Server{Addr: srv.Addr, Handler: diam.NewServeMux(), LocalAddr: laddr}
+	cli, err := cs.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if a := cli.LocalAddr().String(); a != laddr {
+		t.Fatalf("Unexpected local address. Want %s, have %s", laddr, a)
+	}
+	cs.Network = "sctp"
+	if _, err = cs.Dial(); err == nil {
+		t.Fatal("Unexpected nil error for local address over a registered network")
+	}
+}
+
+func TestListen_UnknownNetwork(t *testing.T) {
+	if _, err := diam.Listen("foobar", ":0", nil); err == nil {
+		t.Fatal("Unexpected nil error for unknown network")
+	}
+}
+
+func TestServeMux_HandleApp(t *testing.T) {
+	appc := make(chan uint32, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		appc <- 0
+	})
+	smux.HandleAppFunc(4, "CCR", func(c diam.Conn, m *diam.Message) {
+		appc <- m.Header.ApplicationID
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case id := <-appc:
+		if id != 4 {
+			t.Fatalf("Unexpected handler. Want app 4, have %d", id)
+		}
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CCR")
+	}
+}
+
+func TestServeMux_Routes(t *testing.T) {
+	mux := diam.NewServeMux()
+	h := func(c diam.Conn, m *diam.Message) {}
+	mux.HandleAppFunc(16777238, "CCR", h)
+	mux.HandleFunc("DWR", h)
+	mux.HandleAppFunc(4, "CCR", h)
+	mux.HandleFunc("ALL", h)
+	want := []diam.Route{
+		{Command: "ALL", AllApps: true},
+		{Command: "DWR", AllApps: true},
+		{Command: "CCR", ApplicationID: 4},
+		{Command: "CCR", ApplicationID: 16777238},
+	}
+	have := mux.Routes()
+	if len(have) != len(want) {
+		t.Fatalf("Unexpected routes. Want %v, have %v", want, have)
+	}
+	for i, r := range want {
+		if have[i] != r {
+			t.Fatalf("Unexpected route %d. Want %v, have %v", i, r, have[i])
+		}
+	}
+	if mux.HasDefault() {
+		t.Fatal("Unexpected default handler")
+	}
+	mux.HandleDefault(diam.ResultCodeHandler(diam.UnableToDeliver))
+	if !mux.HasDefault() {
+		t.Fatal("Default handler not reported")
+	}
+}
+
+func TestServeMux_ApplicationUnsupported(t *testing.T) {
+	srv := diamtest.NewServer(diam.NewServeMux(), nil)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		if m.Header.CommandFlags&diam.ErrorFlag == 0 {
+			t.Fatal("Unexpected answer without the E bit")
+		}
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.ApplicationUnsupported {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.ApplicationUnsupported, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CCA")
+	}
+}
+
+func TestServeMux_SIP(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("MAR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("MAA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.MultimediaAuth, 6, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.SIPAOR, avp.Mbit, 0, datatype.UTF8String("sip:alice@example.com"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.Success {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, v)
+		}
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for MAA")
+	}
+}
+
+func TestServeMux_HandleDefault(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {})
+	smux.HandleDefault(diam.ResultCodeHandler(diam.CommandUnsupported))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("RAA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.ReAuth, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		if m.Header.CommandFlags&diam.ErrorFlag == 0 {
+			t.Fatal("Unexpected answer without the E bit")
+		}
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.CommandUnsupported {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.CommandUnsupported, v)
+		}
+	case err := <-smux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for RAA")
+	}
+}
+
+func TestServeMux_HandleDefaultUnsupported(t *testing.T) {
+	// Relay agents with only a default handler forward requests of
+	// all applications.
+	smux := diam.NewServeMux()
+	smux.HandleDefault(diam.ResultCodeHandler(diam.UnableToDeliver))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		rc, err := m.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.UnableToDeliver {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.UnableToDeliver, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CCA")
+	}
+}
+
+func TestMessageMeta(t *testing.T) {
+	type key int
+	mc := make(chan *diam.Message, 1)
+	conns := make(chan diam.Conn, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+		c.SetContext(context.WithValue(c.Context(), key(0), "peer"))
+		mc <- m
+		conns <- c
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, diam.NewServeMux(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if m := diam.NewRequest(diam.CapabilitiesExchange, 0, nil); m.Meta() != nil {
+		t.Fatal("Unexpected metadata in new message")
+	}
+	before := time.Now()
+	for i := 0; i < 2; i++ {
+		if _, err = sendCER(cli); err != nil {
+			t.Fatal(err)
+		}
+		var m *diam.Message
+		select {
+		case m = <-mc:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for CER")
+		}
+		meta := m.Meta()
+		if meta == nil {
+			t.Fatal("Missing metadata")
+		}
+		if meta.Conn != <-conns {
+			t.Fatal("Unexpected Conn in metadata")
+		}
+		if meta.Network != "tcp" {
+			t.Fatalf("Unexpected Network. Want tcp, have %q", meta.Network)
+		}
+		if meta.TLS != nil {
+			t.Fatal("Unexpected TLS state")
+		}
+		if meta.Received.Before(before) {
+			t.Fatalf("Unexpected receive time %s before %s", meta.Received, before)
+		}
+		// The context is the one of the connection when the message
+		// was read, before the handler of the first CER changed it.
+		v := meta.Context.Value(key(0))
+		if i == 0 && v != nil {
+			t.Fatalf("Unexpected context value %v", v)
+		}
+		if i == 1 && v != "peer" {
+			t.Fatalf("Unexpected context value. Want peer, have %v", v)
+		}
+	}
+}
+
+func TestConn_DoneErr(t *testing.T) {
+	conns := make(chan diam.Conn, 1)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+		conns <- c
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, diam.NewServeMux(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cli.Err() != nil {
+		t.Fatalf("Unexpected error on open connection: %v", cli.Err())
+	}
+	if _, err = sendCER(cli); err != nil {
+		t.Fatal(err)
+	}
+	var c diam.Conn
+	select {
+	case c = <-conns:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CER")
+	}
+	cli.Close()
+	select {
+	case <-cli.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for client Done")
+	}
+	if cli.Err() != diam.ErrConnClosed {
+		t.Fatalf("Unexpected client error. Want %v, have %v", diam.ErrConnClosed, cli.Err())
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for server Done")
+	}
+	if c.Err() != io.EOF {
+		t.Fatalf("Unexpected server error. Want %v, have %v", io.EOF, c.Err())
+	}
+	// The first reason wins.
+	diam.CloseWithError(c, errors.New("late"))
+	if c.Err() != io.EOF {
+		t.Fatalf("Unexpected server error after close. Want %v, have %v", io.EOF, c.Err())
+	}
+}
+
+func TestMessage_WriteToContext(t *testing.T) {
+	// A peer that never reads.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		<-time.After(5 * time.Second)
+		c.Close()
+	}()
+	cli, err := diam.Dial(l.Addr().String(), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, nil)
+	m.NewAVP(avp.ProxyState, 0, 0, datatype.OctetString(make([]byte, 1<<20)))
+
+	// Done contexts fail before writing.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = m.WriteToContext(ctx, cli)
+	werr, ok := err.(*diam.WriteError)
+	if !ok || werr.Err != context.Canceled || werr.Written != 0 || !werr.Temporary() {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Writes to the stalled peer time out once its buffers are full.
+	for i := 0; i < 256; i++ {
+		ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+		_, err = m.WriteToContext(ctx, cli)
+		cancel()
+		if err != nil {
+			break
+		}
+	}
+	werr, ok = err.(*diam.WriteError)
+	if !ok || !werr.Timeout() {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if werr.Partial() != werr.Closed {
+		t.Fatalf("Unexpected write error: %#v", werr)
+	}
+	if werr.Closed {
+		select {
+		case <-cli.Done():
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for the connection to close")
+		}
+		if cli.Err() != werr {
+			t.Fatalf("Unexpected close reason. Want %v, have %v", werr, cli.Err())
+		}
+	}
+}
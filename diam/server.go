@@ -15,11 +15,14 @@ import (
 	"log"
 	"net"
 	"runtime"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"golang.org/x/net/context"
 
+	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/dict"
 )
 
@@ -43,12 +46,49 @@ type Conn interface {
 	Dictionary() *dict.Parser       // Dictionary parser of the connection
 	Context() context.Context       // Returns the internal context
 	SetContext(ctx context.Context) // Stores a new context
+
+	// Done returns a channel that is closed when the connection
+	// is closed, by either side.
+	Done() <-chan struct{}
+
+	// Err returns nil while the connection is open, and the reason
+	// it was closed after Done is closed: ErrConnClosed when closed
+	// with Close, io.EOF when closed by the peer, the error that
+	// caused the read loop to stop, or the error given to
+	// CloseWithError.
+	Err() error
+
+	// Stats returns the statistics of the connection.
+	Stats() ConnStats
+
+	// SendBatch writes the messages with a single write, and
+	// returns the error of each message.
+	SendBatch(msgs []*Message) []error
+}
+
+// ErrConnClosed is returned by Conn.Err for connections closed with
+// Conn.Close.
+var ErrConnClosed = errors.New("diam: connection closed")
+
+// CloseWithError closes the connection c, recording err as the reason
+// returned by c.Err. Connections that are not created by this package
+// are closed with Close.
+func CloseWithError(c Conn, err error) {
+	if ec, ok := c.(interface {
+		closeWithError(err error)
+	}); ok {
+		ec.closeWithError(err)
+		return
+	}
+	c.Close()
 }
 
 // The CloseNotifier interface is implemented by Conns which
 // allow detecting when the underlying connection has gone away.
 //
 // This mechanism can be used to detect if a peer has disconnected.
+//
+// Deprecated: Use Conn.Done and Conn.Err instead.
 type CloseNotifier interface {
 	// CloseNotify returns a channel that is closed
 	// when the client connection has gone away.
@@ -59,13 +99,15 @@ type CloseNotifier interface {
 // reads and switches, if its mutex is held.
 type liveSwitchReader struct {
 	sync.Mutex
-	r io.Reader
+	r     io.Reader
+	reads uint64 // number of reads, only accessed by the reader
 }
 
 func (sr *liveSwitchReader) Read(p []byte) (n int, err error) {
 	sr.Lock()
 	r := sr.r
 	sr.Unlock()
+	sr.reads++
 	return r.Read(p)
 }
 
@@ -73,14 +115,46 @@ func (sr *liveSwitchReader) Read(p []byte) (n int, err error) {
 type conn struct {
 	server   *Server              // the Server on which the connection arrived
 	rwc      net.Conn             // i/o connection
+	secure   SecureConn           // TLS or DTLS connection under rwc, or nil
 	sr       liveSwitchReader     // reads from rwc
-	buf      *bufio.ReadWriter    // buffered(sr, rwc)
+	buf      *bufio.ReadWriter    // buffered(sr), writes go to rwc, see write
 	tlsState *tls.ConnectionState // or nil when not using TLS
 	writer   *response            // the diam.Conn exposed to handlers
 
 	mu           sync.Mutex // guards the following
 	closeNotifyc chan struct{}
 	clientGone   bool
+	done         chan struct{} // closed by closeWithError
+	err          error         // reason the connection was closed
+
+	lanes *laneScheduler // or nil, see Server.WriteLanes
+
+	flood floodCounter // decode errors, used by the read loop only
+	rbuf  readBuffer   // message buffer, used by the read loop only
+
+	statsMu  sync.Mutex // guards stats, cstats, pending, reserved and answered
+	stats    ReadStats
+	cstats   ConnStats
+	pending  map[uint32]struct{} // Hop-by-Hop IDs of unanswered requests
+	reserved int                 // requests being written, see FlowControl
+	answered chan struct{}       // closed when a request is answered, or nil
+}
+
+// closeWithError closes the connection, recording err as the reason
+// unless it was already closed.
+func (c *conn) closeWithError(err error) {
+	if err == nil {
+		err = ErrConnClosed
+	}
+	c.mu.Lock()
+	if c.err != nil {
+		c.mu.Unlock()
+		return
+	}
+	c.err = err
+	close(c.done)
+	c.mu.Unlock()
+	c.rwc.Close()
 }
 
 func (c *conn) closeNotify() <-chan struct{} {
@@ -116,13 +190,24 @@ func (c *conn) notifyClientGone() {
 
 // Create new connection from rwc.
 func (srv *Server) newConn(rwc net.Conn) (c *conn, err error) {
+	secure, _ := rwc.(SecureConn)
+	if srv.WrapConn != nil {
+		rwc = srv.WrapConn(rwc)
+	}
 	c = &conn{
 		server: srv,
 		rwc:    rwc,
+		secure: secure,
 		sr:     liveSwitchReader{r: rwc},
+		done:   make(chan struct{}),
+		rbuf:   readBuffer{b: make([]byte, MessageBufferLength)},
 	}
-	c.buf = bufio.NewReadWriter(bufio.NewReader(&c.sr), bufio.NewWriter(rwc))
+	c.buf = bufio.NewReadWriter(bufio.NewReader(&c.sr), nil)
 	c.writer = &response{conn: c}
+	if srv.WriteLanes != nil {
+		c.lanes = newLaneScheduler(srv.WriteLanes, srv.Codec != nil, c.writer.writeNow)
+		go c.lanes.run(c.done)
+	}
 	return c, nil
 }
 
@@ -131,10 +216,23 @@ func (c *conn) readMessage() (*Message, error) {
 	if c.server.ReadTimeout > 0 {
 		c.rwc.SetReadDeadline(time.Now().Add(c.server.ReadTimeout))
 	}
-	m, err := ReadMessage(c.buf.Reader, c.dictionary())
+	if codec := c.server.Codec; codec != nil {
+		return c.readCodecMessage(codec)
+	}
+	reads, buffered := c.sr.reads, c.buf.Reader.Buffered() > 0
+	m, err := readMessageBuffer(c.buf.Reader, c.dictionary(), c.server.decodeMode(), &c.rbuf, c.server.MaxAVPs)
+	if m != nil {
+		c.countRead(m.Header.MessageLength, c.sr.reads-reads, buffered)
+		c.countAnswer(m.Header)
+	} else if de, ok := err.(*decodeError); ok {
+		c.countRead(de.header.MessageLength, c.sr.reads-reads, buffered)
+		c.countDecodeError()
+		c.countAnswer(de.header)
+	}
 	if err != nil {
 		return nil, err
 	}
+	m.meta = c.newMeta()
 	return m, nil
 }
 
@@ -147,28 +245,42 @@ func (c *conn) serve() {
 			log.Printf("diam: panic serving %v: %v\n%s",
 				c.rwc.RemoteAddr().String(), err, buf)
 		}
-		c.rwc.Close()
+		c.closeWithError(ErrConnClosed)
 	}()
-	if tlsConn, ok := c.rwc.(*tls.Conn); ok {
-		if err := tlsConn.Handshake(); err != nil {
+	if sc := c.secure; sc != nil {
+		if err := sc.Handshake(); err != nil {
+			c.closeWithError(err)
 			return
 		}
 		c.tlsState = &tls.ConnectionState{}
-		*c.tlsState = tlsConn.ConnectionState()
+		*c.tlsState = sc.ConnectionState()
 	}
 	for {
 		m, err := c.readMessage()
+		if de, ok := err.(*decodeError); ok {
+			err = de.err
+			g := c.server.FloodGuard
+			if g != nil || c.server.AnswerDecodeErrors {
+				c.reportError(err)
+				c.answerDecodeError(de)
+				if g == nil {
+					continue
+				}
+				ferr := c.flood.add(g, de, time.Now())
+				if ferr == nil {
+					continue
+				}
+				if g.Disconnect != nil {
+					g.Disconnect(c.writer, ferr)
+				}
+				err = ferr
+			}
+		}
 		if err != nil {
-			c.rwc.Close()
+			c.closeWithError(err)
 			// Report errors to the channel, except EOF.
 			if err != io.EOF && err != io.ErrUnexpectedEOF {
-				h := c.server.Handler
-				if h == nil {
-					h = DefaultServeMux
-				}
-				if er, ok := h.(ErrorReporter); ok {
-					er.Error(&ErrorReport{c.writer, m, err})
-				}
+				c.reportError(err)
 			}
 			break
 		}
@@ -177,13 +289,58 @@ func (c *conn) serve() {
 	}
 }
 
+// answerDecodeError answers the request that could not be decoded
+// with the error de. Requests with too many AVPs are always answered,
+// and other errors only when the server answers decode errors.
+func (c *conn) answerDecodeError(de *decodeError) {
+	switch err := de.err.(type) {
+	case *AVPCountError:
+		c.rejectRequest(de.header, UnableToComply, nil)
+	case *AVPDecodeError:
+		if c.server.AnswerDecodeErrors {
+			c.rejectRequest(de.header, err.ResultCode, err.FailedAVP)
+		}
+	default:
+		if c.server.AnswerDecodeErrors && de.unknown {
+			c.rejectRequest(de.header, CommandUnsupported, nil)
+		}
+	}
+}
+
+// rejectRequest answers the request with header h, that could not be
+// decoded, with the result code code and the Failed-AVP failed, if not
+// nil. The E bit is set for protocol errors (3xxx). Answers to other
+// messages are not sent.
+func (c *conn) rejectRequest(h *Header, code uint32, failed *AVP) {
+	if h.CommandFlags&RequestFlag == 0 {
+		return
+	}
+	m := &Message{Header: h, dictionary: c.dictionary()}
+	a := m.Answer(code)
+	if failed != nil {
+		a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &GroupedAVP{AVP: []*AVP{failed}})
+	}
+	if _, err := a.WriteTo(c.writer); err != nil {
+		c.reportError(err)
+	}
+}
+
+// reportError reports the error err to the ErrorReporter of the
+// server's handler, if any.
+func (c *conn) reportError(err error) {
+	h := c.server.Handler
+	if h == nil {
+		h = DefaultServeMux
+	}
+	if er, ok := h.(ErrorReporter); ok {
+		er.Error(&ErrorReport{Conn: c.writer, Error: err})
+	}
+}
+
 // dictionary returns the dictionary parser associated to the Server instance
 // or dict.Default.
 func (c *conn) dictionary() *dict.Parser {
-	if c.server.Dict == nil {
-		return dict.Default
-	}
-	return c.server.Dict
+	return c.server.dictionary()
 }
 
 // A response represents the server side of a diameter response.
@@ -195,26 +352,43 @@ type response struct {
 	ctx  context.Context // context for this Conn
 }
 
-// Write writes the message m to the connection.
+// Write writes the message m to the connection. Failed writes return
+// a *WriteError.
 func (w *response) Write(b []byte) (int, error) {
-	w.mu.Lock()
-	defer w.mu.Unlock()
-	if w.conn.server.WriteTimeout > 0 {
-		w.conn.rwc.SetWriteDeadline(time.Now().Add(w.conn.server.WriteTimeout))
-	}
-	n, err := w.conn.buf.Writer.Write(b)
-	if err != nil {
-		return 0, err
+	return w.writeContext(context.Background(), b)
+}
+
+// egress calls the egress hooks of the connection's handler.
+func (w *response) egress(m *Message) error {
+	h := w.conn.server.Handler
+	if h == nil {
+		h = DefaultServeMux
 	}
-	if err = w.conn.buf.Writer.Flush(); err != nil {
-		return 0, err
+	if eh, ok := h.(EgressHandler); ok {
+		return eh.Egress(w, m)
 	}
-	return n, nil
+	return nil
 }
 
 // Close closes the connection.
 func (w *response) Close() {
-	w.conn.rwc.Close()
+	w.conn.closeWithError(ErrConnClosed)
+}
+
+func (w *response) closeWithError(err error) {
+	w.conn.closeWithError(err)
+}
+
+// Done returns a channel that is closed when the connection is closed.
+func (w *response) Done() <-chan struct{} {
+	return w.conn.done
+}
+
+// Err returns the reason the connection was closed, or nil.
+func (w *response) Err() error {
+	w.conn.mu.Lock()
+	defer w.conn.mu.Unlock()
+	return w.conn.err
 }
 
 // LocalAddr returns the local address of the connection.
@@ -239,6 +413,8 @@ func (w *response) Dictionary() *dict.Parser {
 }
 
 // CloseNotify implements the CloseNotifier interface.
+//
+// Deprecated: Use Done instead.
 func (w *response) CloseNotify() <-chan struct{} {
 	return w.conn.closeNotify()
 }
@@ -303,9 +479,17 @@ func (er *ErrorReport) String() string {
 // command from the incoming message against a list of
 // registered commands and calls the handler.
 type ServeMux struct {
-	e  chan *ErrorReport
-	mu sync.RWMutex // Guards m.
-	m  map[string]muxEntry
+	e     chan *ErrorReport
+	mu    sync.RWMutex // Guards m, apps, def and unsup.
+	m     map[string]muxEntry
+	apps  map[uint32]map[string]muxEntry // Handlers per application id
+	def   Handler                        // Handler for unmatched requests
+	unsup Handler                        // Handler for unsupported applications
+
+	// Egress hooks are read without mu, which is held while handlers
+	// run and write their answers.
+	egmu sync.Mutex   // Serializes HandleEgress
+	eg   atomic.Value // []EgressFunc, copied on write
 }
 
 type muxEntry struct {
@@ -316,8 +500,9 @@ type muxEntry struct {
 // NewServeMux allocates and returns a new ServeMux.
 func NewServeMux() *ServeMux {
 	return &ServeMux{
-		e: make(chan *ErrorReport, 1),
-		m: make(map[string]muxEntry),
+		e:    make(chan *ErrorReport, 1),
+		m:    make(map[string]muxEntry),
+		apps: make(map[uint32]map[string]muxEntry),
 	}
 }
 
@@ -340,28 +525,48 @@ func (mux *ServeMux) ErrorReports() <-chan *ErrorReport {
 // ServeDIAM dispatches the request to the handler that match the code
 // in the incoming message. If the special "ALL" handler is registered
 // it is used as a catch-all. Otherwise an ErrorReport is sent out.
+//
+// Handlers registered for the application id of the message with
+// HandleApp take precedence over the ones registered with Handle.
+//
+// Requests with no handler are passed to the default handler, if one
+// is registered with HandleDefault.
+//
+// Otherwise, requests of applications other than the base protocol for
+// which there are no handlers at all are answered with the result code
+// DIAMETER_APPLICATION_UNSUPPORTED (3007), unless another handler is
+// registered with HandleUnsupported. The answer has no Origin-Host and
+// Origin-Realm AVPs, which may be added by an egress hook. See
+// HandleEgress for details.
 func (mux *ServeMux) ServeDIAM(c Conn, m *Message) {
 	mux.mu.RLock()
 	defer mux.mu.RUnlock()
-	dcmd, err := m.Dictionary().FindCommand(
-		m.Header.ApplicationID,
-		m.Header.CommandCode,
-	)
-	if err != nil {
+	name, ok := m.CommandName()
+	if !ok {
 		// Try the catch-all.
 		mux.serve("ALL", c, m)
 		return
 	}
 	var cmd string
 	if m.Header.CommandFlags&RequestFlag == RequestFlag {
-		cmd = dcmd.Short + "R"
+		cmd = name.Request()
 	} else {
-		cmd = dcmd.Short + "A"
+		cmd = name.Answer()
 	}
 	mux.serve(cmd, c, m)
 }
 
 func (mux *ServeMux) serve(cmd string, c Conn, m *Message) {
+	if app, ok := mux.apps[m.Header.ApplicationID]; ok {
+		entry, ok := app[cmd]
+		if !ok {
+			entry, ok = app["ALL"]
+		}
+		if ok {
+			entry.h.ServeDIAM(c, m)
+			return
+		}
+	}
 	entry, ok := mux.m[cmd]
 	if ok {
 		entry.h.ServeDIAM(c, m)
@@ -373,15 +578,39 @@ func (mux *ServeMux) serve(cmd string, c Conn, m *Message) {
 		entry.h.ServeDIAM(c, m)
 		return
 	}
+	if mux.def != nil && m.Header.CommandFlags&RequestFlag == RequestFlag {
+		mux.def.ServeDIAM(c, m)
+		return
+	}
+	if m.Header.CommandFlags&RequestFlag == RequestFlag && !mux.supports(m) {
+		if mux.unsup != nil {
+			mux.unsup.ServeDIAM(c, m)
+			return
+		}
+		a := m.Answer(ApplicationUnsupported)
+		if _, err := a.WriteTo(c); err != nil {
+			mux.Error(&ErrorReport{
+				Conn:    c,
+				Message: m,
+				Error:   err,
+			})
+		}
+		return
+	}
 	mux.Error(&ErrorReport{
 		Conn:    c,
 		Message: m,
-		Error:   errors.New("unhandled message"),
+		Error:   fmt.Errorf("unhandled message %s", commandLabel(m)),
 	})
 }
 
 // Handle registers the handler for the given code.
 // If a handler already exists for code, Handle panics.
+//
+// The handler serves the command of every application that defines the
+// short name of cmd, e.g. "RAR" of the base protocol, Gx and Rx. Use
+// HandleApp for handlers of the command of a single application, and
+// CommandApps to find the applications that share a short name.
 func (mux *ServeMux) Handle(cmd string, handler Handler) {
 	mux.mu.Lock()
 	defer mux.mu.Unlock()
@@ -397,6 +626,164 @@ func (mux *ServeMux) HandleFunc(cmd string, handler func(Conn, *Message)) {
 	mux.Handle(cmd, HandlerFunc(handler))
 }
 
+// supports returns true if the message is from the base protocol or
+// any of the handlers of the mux serve the application of the message.
+func (mux *ServeMux) supports(m *Message) bool {
+	appID := m.Header.ApplicationID
+	if appID == 0 {
+		return true
+	}
+	if _, ok := mux.apps[appID]; ok {
+		return true
+	}
+	names := registeredCommands(appID)
+	if app, err := m.Dictionary().App(appID); err == nil {
+		for _, cmd := range app.Command {
+			names = append(names, CommandName{Short: cmd.Short, Name: cmd.Name})
+		}
+	}
+	for _, n := range names {
+		_, req := mux.m[n.Request()]
+		_, ans := mux.m[n.Answer()]
+		if req || ans {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleApp registers the handler for the given command of the
+// application appID. Special cmd "ALL" may be used as a catch all
+// for the application.
+func (mux *ServeMux) HandleApp(appID uint32, cmd string, handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	if handler == nil {
+		panic("DIAM: nil handler")
+	}
+	app, ok := mux.apps[appID]
+	if !ok {
+		app = make(map[string]muxEntry)
+		mux.apps[appID] = app
+	}
+	app[cmd] = muxEntry{h: handler, cmd: cmd}
+}
+
+// HandleAppFunc registers the handler function for the given command
+// of the application appID.
+func (mux *ServeMux) HandleAppFunc(appID uint32, cmd string, handler func(Conn, *Message)) {
+	mux.HandleApp(appID, cmd, HandlerFunc(handler))
+}
+
+// HandleDefault registers the handler for requests that do not match
+// any other handler, including "ALL", and of applications the mux does
+// not support otherwise. Without a default handler these requests are
+// answered with DIAMETER_APPLICATION_UNSUPPORTED (3007) or dropped, see
+// ServeDIAM.
+//
+// The default handler may answer with an error, see ResultCodeHandler,
+// or forward the request to another peer.
+func (mux *ServeMux) HandleDefault(handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.def = handler
+}
+
+// HandleUnsupported registers the handler for requests of applications
+// the mux does not support, instead of the DIAMETER_APPLICATION_UNSUPPORTED
+// (3007) answer, e.g. to only answer peers that passed the handshake.
+// See ServeDIAM for details.
+func (mux *ServeMux) HandleUnsupported(handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.unsup = handler
+}
+
+// Route is a command handler registered in a ServeMux, see Routes.
+type Route struct {
+	Command       string `json:"command"`                  // Short name, e.g. "CCR", or "ALL"
+	ApplicationID uint32 `json:"application_id,omitempty"` // Application of HandleApp handlers
+	AllApps       bool   `json:"all_apps"`                 // True for Handle handlers, which serve every application
+}
+
+// Routes returns the command handlers registered in the mux, sorted by
+// application and command, with the ones registered with Handle
+// first. It is meant for admin interfaces and debugging, to present
+// what the mux serves at runtime.
+func (mux *ServeMux) Routes() []Route {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	routes := make([]Route, 0, len(mux.m))
+	for cmd := range mux.m {
+		routes = append(routes, Route{Command: cmd, AllApps: true})
+	}
+	for appID, app := range mux.apps {
+		for cmd := range app {
+			routes = append(routes, Route{Command: cmd, ApplicationID: appID})
+		}
+	}
+	sort.Sort(byRoute(routes))
+	return routes
+}
+
+// HasDefault returns true if the mux has a default handler, see
+// HandleDefault.
+func (mux *ServeMux) HasDefault() bool {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	return mux.def != nil
+}
+
+type byRoute []Route
+
+func (s byRoute) Len() int      { return len(s) }
+func (s byRoute) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byRoute) Less(i, j int) bool {
+	if s[i].AllApps != s[j].AllApps {
+		return s[i].AllApps
+	}
+	if s[i].ApplicationID != s[j].ApplicationID {
+		return s[i].ApplicationID < s[j].ApplicationID
+	}
+	return s[i].Command < s[j].Command
+}
+
+// ResultCodeHandler returns a handler that answers requests with the
+// given result code, e.g. DIAMETER_COMMAND_UNSUPPORTED (3001),
+// DIAMETER_UNABLE_TO_DELIVER (3002) or DIAMETER_UNABLE_TO_COMPLY (5012).
+// The E bit is set in answers with protocol errors (3xxx).
+func ResultCodeHandler(resultCode uint32) Handler {
+	return HandlerFunc(func(c Conn, m *Message) {
+		if m.Header.CommandFlags&RequestFlag == 0 {
+			return
+		}
+		m.Answer(resultCode).WriteTo(c)
+	})
+}
+
+// HandleEgress registers an egress hook, called for messages written
+// to connections served by the mux. Hooks are called in the order
+// they are registered, until one returns an error.
+func (mux *ServeMux) HandleEgress(f EgressFunc) {
+	mux.egmu.Lock()
+	defer mux.egmu.Unlock()
+	old, _ := mux.eg.Load().([]EgressFunc)
+	eg := make([]EgressFunc, len(old), len(old)+1)
+	copy(eg, old)
+	mux.eg.Store(append(eg, f))
+}
+
+// Egress implements the EgressHandler interface.
+func (mux *ServeMux) Egress(c Conn, m *Message) error {
+	eg, _ := mux.eg.Load().([]EgressFunc)
+	for _, f := range eg {
+		if err := f(c, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Handle registers the handler object for the given command
 // in the DefaultServeMux.
 func Handle(cmd string, handler Handler) {
@@ -409,6 +796,18 @@ func HandleFunc(cmd string, handler func(Conn, *Message)) {
 	DefaultServeMux.HandleFunc(cmd, handler)
 }
 
+// HandleApp registers the handler object for the given command of the
+// application appID in the DefaultServeMux.
+func HandleApp(appID uint32, cmd string, handler Handler) {
+	DefaultServeMux.HandleApp(appID, cmd, handler)
+}
+
+// HandleAppFunc registers the handler function for the given command
+// of the application appID in the DefaultServeMux.
+func HandleAppFunc(appID uint32, cmd string, handler func(Conn, *Message)) {
+	DefaultServeMux.HandleAppFunc(appID, cmd, handler)
+}
+
 // ErrorReports returns the ErrorReport channel of the DefaultServeMux.
 func ErrorReports() <-chan *ErrorReport {
 	return DefaultServeMux.ErrorReports()
@@ -426,11 +825,146 @@ func Serve(l net.Listener, handler Handler) error {
 // A Server defines parameters for running a diameter server.
 type Server struct {
 	Addr         string        // TCP address to listen on, ":3868" if empty
+	Network      string        // Transport of Addr, e.g. "sctp" or "tcp4", "tcp" if empty, see Listen
 	Handler      Handler       // handler to invoke, DefaultServeMux if nil
 	Dict         *dict.Parser  // diameter dictionaries for this server
 	ReadTimeout  time.Duration // maximum duration before timing out read of the request
 	WriteTimeout time.Duration // maximum duration before timing out write of the response
-	TLSConfig    *tls.Config   // optional TLS config, used by ListenAndServeTLS
+	TLSConfig    *tls.Config   // optional TLS config, used by ListenAndServeTLS and DialTLS
+
+	// LocalAddr is the local address of the connections of Dial and
+	// friends, e.g. "10.0.0.1:0" to pin the source IP, or
+	// "10.0.0.1:3868" to pin the port too. Only TCP transports support
+	// it, and it is not used with Dialer. Optional.
+	LocalAddr string
+
+	// Dialer establishes the connections of Dial and friends instead
+	// of the transport registered as Network, e.g. through a SOCKS
+	// proxy or a tunnel, or in memory for tests. It is called with
+	// Network and Addr. Optional.
+	Dialer Dialer
+
+	// Relay enables the relay mode for reading messages: messages of
+	// commands unknown to the dictionary are accepted, and AVPs unknown
+	// to the dictionary are decoded as datatype.Raw, which serializes
+	// back bit-exact.
+	Relay bool
+
+	// LazyDecode enables the lazy decode mode for reading messages:
+	// only the header and the AVP boundaries are decoded, and the
+	// data of AVPs is decoded when they are looked up with FindAVP
+	// and friends. It reduces CPU usage for agents that forward most
+	// messages untouched. See Message.DecodeAll for details.
+	LazyDecode bool
+
+	// ArenaDecode enables the arena decode mode for reading messages:
+	// the AVPs of each message, including the ones embedded in grouped
+	// AVPs, are allocated in a few large blocks owned by the message,
+	// which handlers release with Message.Release when done. It
+	// reduces allocations and GC work for messages with hundreds of
+	// AVPs, like S6a Subscription-Data. It has no effect with
+	// LazyDecode.
+	ArenaDecode bool
+
+	// MaxAVPs is the maximum number of AVPs, top level and embedded
+	// in grouped AVPs, of messages read. Zero means no limit. With
+	// LazyDecode, only top level AVPs are limited.
+	//
+	// Messages over the limit are not decoded any further, which
+	// protects against messages with thousands of tiny AVPs. They are
+	// handled like other messages that cannot be decoded, see
+	// FloodGuard, and requests skipped under a FloodGuard are answered
+	// with DIAMETER_UNABLE_TO_COMPLY (5012).
+	//
+	// The answer is a permanent failure rather than a protocol error
+	// (3xxx): none of the protocol errors of RFC 6733 section 7.1.3
+	// describes the content of a message, and they are meant for
+	// failures that another peer may not have, so agents may retry
+	// the request elsewhere. A request over the limit fails the same
+	// way at every peer, and must not be retried unchanged.
+	MaxAVPs int
+
+	// FloodGuard limits the rate of messages that cannot be decoded
+	// on each connection. When nil, connections are closed on the
+	// first message that cannot be decoded, unless AnswerDecodeErrors
+	// is set.
+	FloodGuard *FloodGuard
+
+	// WriteLanes schedules the writes of connections by priority,
+	// see WriteLanes. When nil, messages are written in the order
+	// writers acquire the connection.
+	WriteLanes *WriteLanes
+
+	// FlowControl limits the outstanding requests of each
+	// connection, see FlowControl. Optional.
+	FlowControl *FlowControl
+
+	// Intercept is called with the frames written to connections,
+	// and may modify or veto them, see InterceptFunc. For tests
+	// only, optional.
+	Intercept InterceptFunc
+
+	// WrapConn wraps the connections accepted and dialed by the
+	// server, inside TLS if any, e.g. with Compress. It is for
+	// non-standard transports agreed on out-of-band, optional.
+	WrapConn ConnWrapper
+
+	// Codec is the wire format of messages, the binary format of
+	// RFC 6733 when nil. Relay, LazyDecode, ArenaDecode, MaxAVPs,
+	// FloodGuard and AnswerDecodeErrors only apply to the default.
+	Codec Codec
+
+	// AnswerDecodeErrors keeps connections open after messages that
+	// are read entirely but cannot be decoded, which are reported to
+	// the ErrorReporter of the Handler and skipped. Requests are
+	// answered according to the error: DIAMETER_COMMAND_UNSUPPORTED
+	// (3001) for commands unknown to the dictionary, and the
+	// ResultCode and FailedAVP of the *AVPDecodeError for AVPs that
+	// cannot be decoded. Answers that cannot be decoded are dropped.
+	//
+	// Errors that break the framing of the stream, like an invalid
+	// header or a truncated message, always close the connection.
+	// Combine it with a FloodGuard to disconnect peers sending too
+	// many messages that cannot be decoded.
+	AnswerDecodeErrors bool
+
+	dict atomic.Value // *dict.Parser set by ReloadDict
+}
+
+// decodeMode returns the decode mode for messages read by the server.
+func (srv *Server) decodeMode() decodeMode {
+	var mode decodeMode
+	if srv.Relay {
+		mode |= decodeRelay
+	}
+	if srv.LazyDecode {
+		mode |= decodeLazy
+	}
+	if srv.ArenaDecode {
+		mode |= decodeArena
+	}
+	return mode
+}
+
+// ReloadDict atomically replaces the dictionary parser of the server.
+//
+// Established connections are not dropped. Messages read from now on,
+// on new or established connections, are decoded using the new
+// dictionary.
+func (srv *Server) ReloadDict(dp *dict.Parser) {
+	srv.dict.Store(dp)
+}
+
+// dictionary returns the dictionary parser set by ReloadDict, the
+// one set in the Dict field, or dict.Default.
+func (srv *Server) dictionary() *dict.Parser {
+	if dp, ok := srv.dict.Load().(*dict.Parser); ok && dp != nil {
+		return dp
+	}
+	if srv.Dict == nil {
+		return dict.Default
+	}
+	return srv.Dict
 }
 
 // serverHandler delegates to either the server's Handler or DefaultServeMux.
@@ -446,21 +980,39 @@ func (sh serverHandler) ServeDIAM(w Conn, m *Message) {
 	handler.ServeDIAM(w, m)
 }
 
-// ListenAndServe listens on the TCP network address srv.Addr and then
-// calls Serve to handle requests on incoming connections.  If
-// srv.Addr is blank, ":3868" is used.
+// ListenAndServe listens on the network address srv.Addr of the
+// transport srv.Network, TCP by default, and then calls Serve to handle
+// requests on incoming connections.  If srv.Addr is blank, ":3868" is
+// used.
 func (srv *Server) ListenAndServe() error {
-	addr := srv.Addr
-	if len(addr) == 0 {
-		addr = ":3868"
-	}
-	l, e := net.Listen("tcp", addr)
+	l, e := Listen(srv.network(), srv.Addr, nil)
 	if e != nil {
 		return e
 	}
 	return srv.Serve(l)
 }
 
+// network returns the transport of the server.
+func (srv *Server) network() string {
+	if len(srv.Network) == 0 {
+		return "tcp"
+	}
+	return srv.Network
+}
+
+// dial connects to addr with srv.Dialer, or with the transport
+// registered as network from srv.LocalAddr.
+func (srv *Server) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	if srv.Dialer == nil {
+		return dialNetwork(ctx, network, addr, srv.LocalAddr)
+	}
+	c, err := srv.Dialer.DialContext(ctx, network, addr)
+	if err != nil && ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return c, err
+}
+
 // Serve accepts incoming connections on the Listener l, creating a
 // new service goroutine for each.  The service goroutines read requests and
 // then call srv.Handler to reply to them.
@@ -494,6 +1046,32 @@ func (srv *Server) Serve(l net.Listener) error {
 	}
 }
 
+// ServeListeners accepts incoming connections on all the listeners,
+// e.g. plain TCP, TLS and SCTP on different ports, and serves them
+// with the same Handler and dictionary.
+//
+// ServeListeners blocks until one of the listeners fails, then closes
+// all the others and returns the first error.
+func (srv *Server) ServeListeners(ls ...net.Listener) error {
+	if len(ls) == 0 {
+		return errors.New("diam: no listeners")
+	}
+	errc := make(chan error, len(ls))
+	for _, l := range ls {
+		go func(l net.Listener) {
+			errc <- srv.Serve(l)
+		}(l)
+	}
+	err := <-errc
+	for _, l := range ls {
+		l.Close()
+	}
+	for i := 1; i < len(ls); i++ {
+		<-errc
+	}
+	return err
+}
+
 // ListenAndServe listens on the TCP network address addr
 // and then calls Serve with handler to handle requests
 // on incoming connections.
@@ -506,8 +1084,9 @@ func ListenAndServe(addr string, handler Handler, dp *dict.Parser) error {
 	return server.ListenAndServe()
 }
 
-// ListenAndServeTLS listens on the TCP network address srv.Addr and
-// then calls Serve to handle requests on incoming TLS connections.
+// ListenAndServeTLS listens on the network address srv.Addr of the
+// transport srv.Network, TCP by default, and then calls Serve to handle
+// requests on incoming TLS connections.
 //
 // Filenames containing a certificate and matching private key for
 // the server must be provided. If the certificate is signed by a
@@ -530,11 +1109,10 @@ func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
 	if err != nil {
 		return err
 	}
-	conn, err := net.Listen("tcp", addr)
+	tlsListener, err := Listen(srv.network(), addr, config)
 	if err != nil {
 		return err
 	}
-	tlsListener := tls.NewListener(conn, config)
 	return srv.Serve(tlsListener)
 }
 
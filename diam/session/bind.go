@@ -0,0 +1,159 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// Binder binds sessions to the server that answered their first
+// request, e.g. the OCS of a credit control session, and sends the
+// subsequent requests of the session to it by adding its Origin-Host
+// as Destination-Host.
+//
+// Sessions are bound when the first answer without the E bit is
+// received, storing the Origin-Host of the answer as the Peer of the
+// session in the Store. The Binder is installed on clients:
+//
+//	b := session.NewBinder(store, time.Hour)
+//	mux.HandleEgress(b.Egress)
+//	mux.Handle("CCA", b.Handler(handleCCA))
+type Binder struct {
+	// Applications is the list of applications whose sessions are
+	// bound. Sessions of all applications are bound when empty.
+	Applications []uint32
+
+	// Override replaces the Destination-Host of requests with the
+	// peer of their session. By default, requests that already
+	// carry a Destination-Host are not modified.
+	Override bool
+
+	store Store
+	ttl   time.Duration
+}
+
+// NewBinder creates and initializes a new Binder that keeps sessions
+// in the store. Bound sessions expire after ttl, or never if zero.
+func NewBinder(store Store, ttl time.Duration) *Binder {
+	return &Binder{store: store, ttl: ttl}
+}
+
+// Egress is a diam.EgressFunc that adds the Destination-Host of bound
+// sessions to their requests. Requests of sessions that cannot be
+// loaded from the Store are sent unmodified.
+func (b *Binder) Egress(c diam.Conn, m *diam.Message) error {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 || !b.binds(m) {
+		return nil
+	}
+	id, ok := sessionID(m)
+	if !ok {
+		return nil
+	}
+	s, err := b.store.Get(id)
+	if err != nil || s.Peer == "" {
+		return nil
+	}
+	for i, a := range m.AVP {
+		if a.Code != avp.DestinationHost || a.VendorID != 0 {
+			continue
+		}
+		if b.Override {
+			host := diam.NewAVP(avp.DestinationHost, avp.Mbit, 0, s.Peer)
+			m.Header.MessageLength += uint32(host.Len()) - uint32(a.Len())
+			m.AVP[i] = host
+		}
+		return nil
+	}
+	m.NewAVP(avp.DestinationHost, avp.Mbit, 0, s.Peer)
+	return nil
+}
+
+// Handler returns a diam.Handler that binds the sessions of the
+// answers received before calling h.
+func (b *Binder) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if m.Header.CommandFlags&(diam.RequestFlag|diam.ErrorFlag) == 0 && b.binds(m) {
+			b.bind(m)
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+// Unbind removes the binding of the session with the given id, e.g.
+// after the session is terminated, keeping its other state.
+func (b *Binder) Unbind(id string) error {
+	s, err := b.store.Get(id)
+	if err == ErrNotFound {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if s.Peer == "" {
+		return nil
+	}
+	s.Peer = ""
+	return b.store.Put(s, b.ttl)
+}
+
+// bind stores the Origin-Host of the answer m as the peer of its
+// session, unless the session is already bound.
+func (b *Binder) bind(m *diam.Message) error {
+	id, ok := sessionID(m)
+	if !ok {
+		return nil
+	}
+	var host datatype.DiameterIdentity
+	for _, a := range m.AVP {
+		if a.Code == avp.OriginHost && a.VendorID == 0 {
+			host, _ = a.Data.(datatype.DiameterIdentity)
+			break
+		}
+	}
+	if host == "" {
+		return nil
+	}
+	s, err := b.store.Get(id)
+	switch {
+	case err == ErrNotFound:
+		s = &Session{ID: id, ApplicationID: m.Header.ApplicationID}
+	case err != nil:
+		return err
+	case s.Peer != "":
+		return nil
+	}
+	s.Peer = host
+	return b.store.Put(s, b.ttl)
+}
+
+// binds returns true if sessions of the application of m are bound.
+func (b *Binder) binds(m *diam.Message) bool {
+	if len(b.Applications) == 0 {
+		return true
+	}
+	for _, id := range b.Applications {
+		if id == m.Header.ApplicationID {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionID returns the Session-Id of the message m.
+func sessionID(m *diam.Message) (string, bool) {
+	for _, a := range m.AVP {
+		if a.Code != avp.SessionID || a.VendorID != 0 {
+			continue
+		}
+		if v, ok := a.Data.(datatype.UTF8String); ok {
+			return string(v), true
+		}
+		return "", false
+	}
+	return "", false
+}
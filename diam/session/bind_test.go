@@ -0,0 +1,123 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestBinder(t *testing.T) {
+	// The server answers with its Origin-Host, and reports the
+	// Destination-Host of requests.
+	dest := make(chan datatype.DiameterIdentity, 2)
+	smux := diam.NewServeMux()
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		var host datatype.DiameterIdentity
+		if a, err := m.FindAVP(avp.DestinationHost, 0); err == nil {
+			host = a.Data.(datatype.DiameterIdentity)
+		}
+		dest <- host
+		sid, _ := m.FindAVP(avp.SessionID, 0)
+		a := m.Answer(diam.Success)
+		a.AddAVP(sid)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("ocs1"))
+		a.WriteTo(c)
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	store := NewMemoryStore()
+	b := NewBinder(store, time.Minute)
+	answered := make(chan struct{}, 2)
+	cmux := diam.NewServeMux()
+	cmux.HandleEgress(b.Egress)
+	cmux.Handle("CCA", b.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		answered <- struct{}{}
+	})))
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	for _, want := range []datatype.DiameterIdentity{"", "ocs1"} {
+		m := diam.NewRequest(diam.CreditControl, 4, nil)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		if _, err = m.WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case host := <-dest:
+			if host != want {
+				t.Fatalf("Unexpected Destination-Host. Want %q, have %q", want, host)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for CCR")
+		}
+		select {
+		case <-answered:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for CCA")
+		}
+	}
+	s, err := store.Get("cli;1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Peer != "ocs1" || s.ApplicationID != 4 {
+		t.Fatalf("Unexpected session: %#v", s)
+	}
+	if err = b.Unbind("cli;1"); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ = store.Get("cli;1"); s.Peer != "" {
+		t.Fatalf("Unexpected peer after Unbind: %q", s.Peer)
+	}
+}
+
+func TestBinder_Egress(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put(&Session{ID: "cli;1", Peer: "ocs1.example.com"}, 0)
+	b := NewBinder(store, 0)
+	for _, test := range []struct {
+		override bool
+		apps     []uint32
+		want     datatype.DiameterIdentity
+	}{
+		{false, nil, "other"},
+		{true, nil, "ocs1.example.com"},
+		{true, []uint32{16777238}, "other"},
+	} {
+		b.Override = test.override
+		b.Applications = test.apps
+		m := diam.NewRequest(diam.CreditControl, 4, nil)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity("other"))
+		if err := b.Egress(nil, m); err != nil {
+			t.Fatal(err)
+		}
+		// Read back the frame, as written to the peer.
+		frame, err := m.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m, err = diam.ReadMessage(bytes.NewReader(frame), m.Dictionary()); err != nil {
+			t.Fatal(err)
+		}
+		a, err := m.FindAVP(avp.DestinationHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if host := a.Data.(datatype.DiameterIdentity); host != test.want {
+			t.Fatalf("Unexpected Destination-Host. Want %q, have %q", test.want, host)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// DefaultInSessionCommands are the commands checked by a Dispatcher
+// when its Commands field is empty: RAR, ASR and STR.
+var DefaultInSessionCommands = []uint32{
+	diam.ReAuth,
+	diam.AbortSession,
+	diam.SessionTermination,
+}
+
+// Dispatcher passes in-session requests to their handler only if their
+// session is in the Store. Requests of unknown sessions are answered
+// with DIAMETER_UNKNOWN_SESSION_ID (5002), as required by RFC 6733
+// section 7.1.5:
+//
+//	d := session.NewDispatcher(store)
+//	d.OriginHost = "client.example.com"
+//	d.OriginRealm = "example.com"
+//	mux.Handle("RAR", d.Handler(handleRAR))
+//
+// Requests without a Session-Id, or of commands other than the ones of
+// Commands, are always passed to the handler.
+type Dispatcher struct {
+	// OriginHost and OriginRealm of the answers. When empty, the
+	// Destination-Host and Destination-Realm of the request are used.
+	OriginHost  datatype.DiameterIdentity
+	OriginRealm datatype.DiameterIdentity
+
+	// Commands is the list of the in-session commands whose sessions
+	// are checked. DefaultInSessionCommands is used when empty.
+	Commands []uint32
+
+	// UnknownSession, if set, is called instead of sending the
+	// default answer to requests of unknown sessions. The default
+	// answer m is built but not sent; the function may modify and
+	// send it, or answer the request req differently.
+	UnknownSession func(c diam.Conn, req, m *diam.Message)
+
+	store Store
+}
+
+// NewDispatcher creates and initializes a new Dispatcher that looks up
+// sessions in the store.
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{store: store}
+}
+
+// Handler returns a diam.Handler that calls h for requests of known
+// sessions, and answers the requests of unknown sessions.
+func (d *Dispatcher) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if !d.checks(m) {
+			h.ServeDIAM(c, m)
+			return
+		}
+		id, ok := sessionID(m)
+		if !ok {
+			h.ServeDIAM(c, m)
+			return
+		}
+		if _, err := d.store.Get(id); err != ErrNotFound {
+			// Store errors other than ErrNotFound do not
+			// prove the session is unknown.
+			h.ServeDIAM(c, m)
+			return
+		}
+		a := d.answer(m, id)
+		if d.UnknownSession != nil {
+			d.UnknownSession(c, m, a)
+			return
+		}
+		a.WriteTo(c)
+	})
+}
+
+// checks returns true if the session of the request m is checked.
+func (d *Dispatcher) checks(m *diam.Message) bool {
+	if m.Header.CommandFlags&diam.RequestFlag == 0 {
+		return false
+	}
+	cmds := d.Commands
+	if len(cmds) == 0 {
+		cmds = DefaultInSessionCommands
+	}
+	for _, code := range cmds {
+		if code == m.Header.CommandCode {
+			return true
+		}
+	}
+	return false
+}
+
+// answer returns the DIAMETER_UNKNOWN_SESSION_ID answer to the request
+// m of the session id.
+func (d *Dispatcher) answer(m *diam.Message, id string) *diam.Message {
+	host, realm := d.OriginHost, d.OriginRealm
+	for _, a := range m.AVP {
+		if a.VendorID != 0 {
+			continue
+		}
+		switch {
+		case a.Code == avp.DestinationHost && host == "":
+			host, _ = a.Data.(datatype.DiameterIdentity)
+		case a.Code == avp.DestinationRealm && realm == "":
+			realm, _ = a.Data.(datatype.DiameterIdentity)
+		}
+	}
+	a := m.Answer(diam.UnknownSessionID)
+	a.InsertAVP(diam.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(id)))
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, host)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, realm)
+	return a
+}
@@ -0,0 +1,103 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestDispatcher(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put(&Session{ID: "srv;1"}, 0)
+	d := NewDispatcher(store)
+	d.OriginRealm = "example.com"
+	served := make(chan string, 1)
+	smux := diam.NewServeMux()
+	smux.Handle("RAR", d.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		id, _ := sessionID(m)
+		served <- id
+		m.Answer(diam.Success).WriteTo(c)
+	})))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	answers := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("RAA", func(c diam.Conn, m *diam.Message) {
+		answers <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	rar := func(id string) *diam.Message {
+		m := diam.NewRequest(diam.ReAuth, 4, nil)
+		m.Header.CommandFlags |= diam.RetransmittedFlag
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(id))
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity("client"))
+		m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("client.realm"))
+		if _, err := m.WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case a := <-answers:
+			return a
+		case <-time.After(time.Second):
+			t.Fatal("Timeout waiting for RAA")
+		}
+		return nil
+	}
+
+	rar("srv;1")
+	if id := <-served; id != "srv;1" {
+		t.Fatalf("Unexpected session served. Want srv;1, have %s", id)
+	}
+	a := rar("srv;2")
+	select {
+	case id := <-served:
+		t.Fatalf("Unexpected session served: %s", id)
+	default:
+	}
+	for code, want := range map[uint32]interface{}{
+		avp.SessionID:   datatype.UTF8String("srv;2"),
+		avp.ResultCode:  datatype.Unsigned32(diam.UnknownSessionID),
+		avp.OriginHost:  datatype.DiameterIdentity("client"),
+		avp.OriginRealm: datatype.DiameterIdentity("example.com"),
+	} {
+		v, err := a.FindAVP(code, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v.Data != want {
+			t.Fatalf("Unexpected AVP %d. Want %v, have %v", code, want, v.Data)
+		}
+	}
+	if a.AVP[0].Code != avp.SessionID {
+		t.Fatalf("Unexpected first AVP. Want Session-Id, have %d", a.AVP[0].Code)
+	}
+	if err := diam.CheckFlags(a); err != nil {
+		t.Fatal(err)
+	}
+	if a.Header.CommandFlags&diam.RetransmittedFlag != 0 {
+		t.Fatal("Unexpected T bit copied from the request")
+	}
+
+	// Override the answer.
+	d.UnknownSession = func(c diam.Conn, req, m *diam.Message) {
+		m.AVP[1] = diam.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.UnableToComply))
+		m.WriteTo(c)
+	}
+	a = rar("srv;3")
+	if v, _ := a.FindAVP(avp.ResultCode, 0); v.Data != datatype.Unsigned32(diam.UnableToComply) {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %v", diam.UnableToComply, v.Data)
+	}
+}
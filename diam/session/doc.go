@@ -0,0 +1,24 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package session provides the session subsystem: the state of diameter
+// sessions, identified by their Session-Id, and the stores that keep it.
+//
+// Sessions are kept in a Store. This package ships an in-memory store
+// for single instance deployments, and a Redis store so clustered
+// deployments can share session state and survive instance restarts.
+//
+// Clients can bind sessions to the server that answered their first
+// request with a Binder, which adds the Destination-Host of the server
+// to the subsequent requests of the session.
+//
+// A Dispatcher answers the in-session requests of sessions missing from
+// the Store, such as RAR and ASR, with DIAMETER_UNKNOWN_SESSION_ID.
+//
+// A Reaper removes the sessions without traffic for a given time from
+// the Store, e.g. sessions the peers never terminated.
+//
+// PurgeRestarted removes the sessions anchored on peers that restart,
+// as detected by the state machine from their Origin-State-Id.
+package session
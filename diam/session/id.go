@@ -0,0 +1,58 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// IDGenerator generates Session-Id values in the format recommended
+// by RFC 6733 section 8.8:
+//
+//	<DiameterIdentity>;<high 32 bits>;<low 32 bits>[;<optional value>]
+//
+// The high 32 bits are initialized with the time the generator is
+// created, and the low 32 bits are a counter. NodeID, if set, is added
+// as the optional value so multiple instances sharing the same
+// Diameter identity behind a load balancer never generate colliding
+// Session-Id values, even when started at the same time.
+type IDGenerator struct {
+	identity string
+	nodeID   string
+
+	mu   sync.Mutex // guards high and low
+	high uint32
+	low  uint32
+}
+
+// NewIDGenerator creates and initializes a new IDGenerator for the
+// given Diameter identity (Origin-Host) and node identifier. The node
+// identifier may be empty on single instance deployments.
+func NewIDGenerator(identity datatype.DiameterIdentity, nodeID string) *IDGenerator {
+	return &IDGenerator{
+		identity: string(identity),
+		nodeID:   nodeID,
+		high:     uint32(time.Now().Unix()),
+	}
+}
+
+// New returns a new Session-Id.
+func (g *IDGenerator) New() datatype.UTF8String {
+	g.mu.Lock()
+	g.low++
+	if g.low == 0 {
+		g.high++
+	}
+	high, low := g.high, g.low
+	g.mu.Unlock()
+	if g.nodeID == "" {
+		return datatype.UTF8String(fmt.Sprintf("%s;%d;%d", g.identity, high, low))
+	}
+	return datatype.UTF8String(fmt.Sprintf("%s;%d;%d;%s", g.identity, high, low, g.nodeID))
+}
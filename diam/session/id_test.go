@@ -0,0 +1,53 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestIDGenerator(t *testing.T) {
+	g := NewIDGenerator("cli", "node1")
+	id := string(g.New())
+	if !strings.HasPrefix(id, "cli;") || !strings.HasSuffix(id, ";node1") {
+		t.Fatalf("Unexpected Session-Id: %s", id)
+	}
+	if n := strings.Count(id, ";"); n != 3 {
+		t.Fatalf("Unexpected # of fields in Session-Id %s: %d", id, n+1)
+	}
+}
+
+func TestIDGenerator_Collision(t *testing.T) {
+	// Instances created at the same time share the high 32 bits,
+	// and only the node identifier keeps them apart.
+	gens := []*IDGenerator{
+		NewIDGenerator("cli", "node1"),
+		NewIDGenerator("cli", "node2"),
+		NewIDGenerator("cli", "node3"),
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	seen := make(map[datatype.UTF8String]bool)
+	for _, g := range gens {
+		wg.Add(1)
+		go func(g *IDGenerator) {
+			defer wg.Done()
+			for i := 0; i < 10000; i++ {
+				id := g.New()
+				mu.Lock()
+				if seen[id] {
+					t.Errorf("Duplicate Session-Id: %s", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}(g)
+	}
+	wg.Wait()
+}
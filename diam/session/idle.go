@@ -0,0 +1,207 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// IdleFunc is called by a Reaper for each session idle for longer than
+// its timeout, e.g. to send an ASR or STR for the session or release
+// resources bound to it. The session is deleted from the Store when
+// IdleFunc returns true, and kept otherwise, e.g. while waiting for
+// the answer to the ASR or STR, until it is found idle again.
+type IdleFunc func(s *Session) bool
+
+// MinReapInterval is the shortest time between scans of the Store by
+// a Reaper.
+const MinReapInterval = 10 * time.Millisecond
+
+// Reaper removes sessions without traffic from the Store, preventing
+// the unbounded growth of the session table when peers do not
+// terminate their sessions:
+//
+//	r := session.NewReaper(store, 30*time.Minute, func(s *session.Session) bool {
+//		sendASR(s)
+//		return true
+//	})
+//	inner := diam.NewServeMux()
+//	inner.Handle("CCR", ccrHandler)
+//	mux.HandleEgress(r.Egress)
+//	mux.Handle("ALL", r.Handler(inner))
+//	r.Start()
+//	defer r.Stop()
+//
+// The traffic of sessions is tracked by the Handler and Egress of the
+// Reaper. Sessions without tracked traffic, e.g. after a restart, are
+// idle since their last update in the Store.
+type Reaper struct {
+	// Interval is the time between scans of the Store. Uses a
+	// tenth of the idle timeout if unset, and no less than
+	// MinReapInterval.
+	Interval time.Duration
+
+	// Clock is the source of time of the Reaper. Uses
+	// diam.SystemClock if unset.
+	Clock diam.Clock
+
+	store Store
+	idle  time.Duration
+	fn    IdleFunc
+
+	mu    sync.Mutex
+	seen  map[string]time.Time // last traffic of sessions
+	timer diam.Timer           // nil when stopped
+}
+
+// NewReaper creates and initializes a new Reaper that calls fn for the
+// sessions in the store without traffic for longer than idle. When fn
+// is nil, idle sessions are deleted. NewReaper panics if idle is not
+// positive.
+func NewReaper(store Store, idle time.Duration, fn IdleFunc) *Reaper {
+	if idle <= 0 {
+		panic("session: non-positive idle timeout")
+	}
+	return &Reaper{
+		store: store,
+		idle:  idle,
+		fn:    fn,
+		seen:  make(map[string]time.Time),
+	}
+}
+
+// Handler returns a diam.Handler that records the traffic of the
+// sessions of incoming messages before calling h.
+func (r *Reaper) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		r.touch(m)
+		h.ServeDIAM(c, m)
+	})
+}
+
+// Egress is a diam.EgressFunc that records the traffic of the sessions
+// of outgoing messages.
+func (r *Reaper) Egress(c diam.Conn, m *diam.Message) error {
+	r.touch(m)
+	return nil
+}
+
+// Forget stops tracking the traffic of the session with the given id,
+// e.g. after the session is terminated and deleted from the Store.
+func (r *Reaper) Forget(id string) {
+	r.mu.Lock()
+	delete(r.seen, id)
+	r.mu.Unlock()
+}
+
+// Start starts scanning the Store for idle sessions periodically,
+// until Stop is called.
+func (r *Reaper) Start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer == nil {
+		r.timer = r.clock().AfterFunc(r.interval(), r.tick)
+	}
+}
+
+// Stop stops the periodic scans started by Start.
+func (r *Reaper) Stop() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer.Stop()
+		r.timer = nil
+	}
+}
+
+func (r *Reaper) tick() {
+	r.Reap()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.timer != nil {
+		r.timer = r.clock().AfterFunc(r.interval(), r.tick)
+	}
+}
+
+// Reap scans the Store once, calling the IdleFunc for the sessions
+// idle for longer than the timeout, and returns the number of sessions
+// deleted.
+func (r *Reaper) Reap() (int, error) {
+	now := r.clock().Now()
+	var idle []*Session
+	r.mu.Lock()
+	seen := make(map[string]time.Time, len(r.seen))
+	for id, t := range r.seen {
+		seen[id] = t
+	}
+	r.mu.Unlock()
+	err := r.store.Scan(func(s *Session) bool {
+		last := s.Updated
+		if t, ok := seen[s.ID]; ok && t.After(last) {
+			last = t
+		}
+		delete(seen, s.ID)
+		if now.Sub(last) > r.idle {
+			idle = append(idle, s)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	// Sessions no longer in the Store are not tracked.
+	r.mu.Lock()
+	for id := range seen {
+		delete(r.seen, id)
+	}
+	r.mu.Unlock()
+	n := 0
+	for _, s := range idle {
+		if r.fn != nil && !r.fn(s) {
+			r.mark(s.ID, now)
+			continue
+		}
+		if err = r.store.Delete(s.ID); err != nil {
+			return n, err
+		}
+		r.Forget(s.ID)
+		n++
+	}
+	return n, nil
+}
+
+// touch records the traffic of the session of the message m.
+func (r *Reaper) touch(m *diam.Message) {
+	if id, ok := sessionID(m); ok {
+		r.mark(id, r.clock().Now())
+	}
+}
+
+func (r *Reaper) mark(id string, t time.Time) {
+	r.mu.Lock()
+	r.seen[id] = t
+	r.mu.Unlock()
+}
+
+func (r *Reaper) interval() time.Duration {
+	d := r.Interval
+	if d <= 0 {
+		d = r.idle / 10
+	}
+	if d < MinReapInterval {
+		return MinReapInterval
+	}
+	return d
+}
+
+func (r *Reaper) clock() diam.Clock {
+	if r.Clock == nil {
+		return diam.SystemClock
+	}
+	return r.Clock
+}
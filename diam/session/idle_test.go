@@ -0,0 +1,100 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestReaper(t *testing.T) {
+	clk := diamtest.NewFakeClock(time.Unix(1e9, 0))
+	store := NewMemoryStore()
+	store.Clock = clk
+	for _, id := range []string{"active", "idle", "kept"} {
+		store.Put(&Session{ID: id}, 0)
+	}
+	var called []string
+	r := NewReaper(store, time.Minute, func(s *Session) bool {
+		called = append(called, s.ID)
+		return s.ID != "kept"
+	})
+	r.Clock = clk
+	clk.Advance(50 * time.Second)
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("active"))
+	r.Egress(nil, m)
+	clk.Advance(20 * time.Second)
+	n, err := r.Reap()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Unexpected number of sessions reaped. Want 1, have %d", n)
+	}
+	if len(called) != 2 {
+		t.Fatalf("Unexpected idle sessions: %v", called)
+	}
+	if _, err = store.Get("idle"); err != ErrNotFound {
+		t.Fatalf("Idle session was not deleted: %v", err)
+	}
+	for _, id := range []string{"active", "kept"} {
+		if _, err = store.Get(id); err != nil {
+			t.Fatalf("Session %q was deleted: %v", id, err)
+		}
+	}
+	// The kept session is not idle until the timeout elapses again.
+	called = nil
+	clk.Advance(45 * time.Second)
+	if n, _ = r.Reap(); n != 1 || len(called) != 1 || called[0] != "active" {
+		t.Fatalf("Unexpected idle sessions: %v", called)
+	}
+}
+
+func TestReaper_Start(t *testing.T) {
+	clk := diamtest.NewFakeClock(time.Unix(1e9, 0))
+	store := NewMemoryStore()
+	store.Clock = clk
+	store.Put(&Session{ID: "idle"}, 0)
+	reaped := make(chan string, 1)
+	r := NewReaper(store, time.Minute, func(s *Session) bool {
+		reaped <- s.ID
+		return true
+	})
+	r.Clock = clk
+	r.Start()
+	defer r.Stop()
+	clk.Advance(2 * time.Minute)
+	select {
+	case id := <-reaped:
+		if id != "idle" {
+			t.Fatalf("Unexpected session reaped: %q", id)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for idle session")
+	}
+}
+
+func TestReaper_Interval(t *testing.T) {
+	r := NewReaper(NewMemoryStore(), time.Nanosecond, nil)
+	if d := r.interval(); d != MinReapInterval {
+		t.Fatalf("Unexpected interval. Want %s, have %s", MinReapInterval, d)
+	}
+	r = NewReaper(NewMemoryStore(), time.Minute, nil)
+	if d := r.interval(); d != 6*time.Second {
+		t.Fatalf("Unexpected interval. Want 6s, have %s", d)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("NewReaper accepted a zero idle timeout")
+		}
+	}()
+	NewReaper(NewMemoryStore(), 0, nil)
+}
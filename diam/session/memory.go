@@ -0,0 +1,110 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// MemoryStore is a Store that keeps sessions in memory.
+type MemoryStore struct {
+	// Clock is the source of time of session updates and expiry.
+	// Uses diam.SystemClock if unset.
+	Clock diam.Clock
+
+	mu sync.RWMutex
+	m  map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	s       Session
+	expires time.Time // zero if the session never expires
+}
+
+func (e memoryEntry) expired(now time.Time) bool {
+	return !e.expires.IsZero() && now.After(e.expires)
+}
+
+// NewMemoryStore creates and initializes a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{m: make(map[string]memoryEntry)}
+}
+
+// Get implements the Store interface.
+func (ms *MemoryStore) Get(id string) (*Session, error) {
+	ms.mu.RLock()
+	e, ok := ms.m[id]
+	ms.mu.RUnlock()
+	if !ok || e.expired(ms.now()) {
+		return nil, ErrNotFound
+	}
+	return e.s.copy(), nil
+}
+
+// Put implements the Store interface.
+func (ms *MemoryStore) Put(s *Session, ttl time.Duration) error {
+	now := ms.now()
+	e := memoryEntry{s: *s.copy()}
+	e.s.Updated = now
+	if ttl > 0 {
+		e.expires = now.Add(ttl)
+	}
+	ms.mu.Lock()
+	ms.m[s.ID] = e
+	ms.mu.Unlock()
+	return nil
+}
+
+// Delete implements the Store interface.
+func (ms *MemoryStore) Delete(id string) error {
+	ms.mu.Lock()
+	delete(ms.m, id)
+	ms.mu.Unlock()
+	return nil
+}
+
+// Scan implements the Store interface. Expired sessions found
+// while scanning are removed from the store.
+func (ms *MemoryStore) Scan(fn func(s *Session) bool) error {
+	now := ms.now()
+	var live []*Session
+	ms.mu.Lock()
+	for id, e := range ms.m {
+		if e.expired(now) {
+			delete(ms.m, id)
+			continue
+		}
+		live = append(live, e.s.copy())
+	}
+	ms.mu.Unlock()
+	for _, s := range live {
+		if !fn(s) {
+			break
+		}
+	}
+	return nil
+}
+
+func (ms *MemoryStore) now() time.Time {
+	if ms.Clock == nil {
+		return time.Now()
+	}
+	return ms.Clock.Now()
+}
+
+// copy returns a copy of the session that does not share its state map.
+func (s *Session) copy() *Session {
+	c := *s
+	if s.State != nil {
+		c.State = make(map[string]string, len(s.State))
+		for k, v := range s.State {
+			c.State[k] = v
+		}
+	}
+	return &c
+}
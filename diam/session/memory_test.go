@@ -0,0 +1,25 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import "testing"
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestMemoryStore_Copy(t *testing.T) {
+	ms := NewMemoryStore()
+	s := &Session{ID: "x", State: map[string]string{"k": "v"}}
+	ms.Put(s, 0)
+	s.State["k"] = "changed"
+	have, err := ms.Get("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.State["k"] != "v" {
+		t.Fatal("Stored session shares state with the caller")
+	}
+}
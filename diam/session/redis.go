@@ -0,0 +1,166 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// RedisConn is the interface of the Redis client used by RedisStore.
+// It is satisfied by the redis.Conn type of the redigo package, and
+// can be easily adapted from other clients.
+//
+// Bulk string replies are expected as []byte, arrays as []interface{}
+// and missing keys as nil.
+type RedisConn interface {
+	Do(cmd string, args ...interface{}) (reply interface{}, err error)
+}
+
+// DefaultRedisPrefix is the default prefix of session keys in Redis.
+const DefaultRedisPrefix = "diam:session:"
+
+// RedisStore is a Store that keeps sessions in Redis, encoded as JSON.
+type RedisStore struct {
+	Prefix string     // Prefix of session keys (uses DefaultRedisPrefix if unset)
+	Clock  diam.Clock // Source of time of updates (uses diam.SystemClock if unset)
+
+	mu   sync.Mutex // guards conn
+	conn RedisConn
+}
+
+// NewRedisStore creates and initializes a new RedisStore that uses
+// the given connection.
+func NewRedisStore(conn RedisConn) *RedisStore {
+	return &RedisStore{conn: conn}
+}
+
+// Get implements the Store interface.
+func (rs *RedisStore) Get(id string) (*Session, error) {
+	reply, err := rs.do("GET", rs.key(id))
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNotFound
+	}
+	b, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("unexpected redis reply: %#v", reply)
+	}
+	s := new(Session)
+	if err = json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Put implements the Store interface.
+func (rs *RedisStore) Put(s *Session, ttl time.Duration) error {
+	c := *s
+	c.Updated = rs.now()
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return err
+	}
+	if ttl > 0 {
+		ms := int64(ttl / time.Millisecond)
+		if ms == 0 {
+			ms = 1
+		}
+		_, err = rs.do("SET", rs.key(s.ID), b, "PX", ms)
+	} else {
+		_, err = rs.do("SET", rs.key(s.ID), b)
+	}
+	return err
+}
+
+// Delete implements the Store interface.
+func (rs *RedisStore) Delete(id string) error {
+	_, err := rs.do("DEL", rs.key(id))
+	return err
+}
+
+// Scan implements the Store interface. It iterates over the keys
+// using the SCAN command, so sessions added or removed during
+// the scan may or may not be visited.
+func (rs *RedisStore) Scan(fn func(s *Session) bool) error {
+	cursor := "0"
+	prefix := rs.prefix()
+	for {
+		reply, err := rs.do("SCAN", cursor, "MATCH", prefix+"*", "COUNT", 100)
+		if err != nil {
+			return err
+		}
+		values, ok := reply.([]interface{})
+		if !ok || len(values) != 2 {
+			return fmt.Errorf("unexpected redis reply: %#v", reply)
+		}
+		cursor, err = redisString(values[0])
+		if err != nil {
+			return err
+		}
+		keys, _ := values[1].([]interface{})
+		for _, k := range keys {
+			key, err := redisString(k)
+			if err != nil {
+				return err
+			}
+			s, err := rs.Get(key[len(prefix):])
+			if err == ErrNotFound {
+				continue // Expired since the SCAN.
+			} else if err != nil {
+				return err
+			}
+			if !fn(s) {
+				return nil
+			}
+		}
+		if cursor == "0" {
+			return nil
+		}
+	}
+}
+
+func (rs *RedisStore) do(cmd string, args ...interface{}) (interface{}, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return rs.conn.Do(cmd, args...)
+}
+
+func (rs *RedisStore) prefix() string {
+	if rs.Prefix == "" {
+		return DefaultRedisPrefix
+	}
+	return rs.Prefix
+}
+
+func (rs *RedisStore) key(id string) string {
+	return rs.prefix() + id
+}
+
+func (rs *RedisStore) now() time.Time {
+	if rs.Clock == nil {
+		return time.Now()
+	}
+	return rs.Clock.Now()
+}
+
+func redisString(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case []byte:
+		return string(v), nil
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	}
+	return "", fmt.Errorf("unexpected redis reply: %#v", v)
+}
@@ -0,0 +1,77 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRedis implements the few Redis commands used by RedisStore.
+type fakeRedis struct {
+	data    map[string][]byte
+	expires map[string]time.Time
+}
+
+func newFakeRedis() *fakeRedis {
+	return &fakeRedis{
+		data:    make(map[string][]byte),
+		expires: make(map[string]time.Time),
+	}
+}
+
+func (r *fakeRedis) Do(cmd string, args ...interface{}) (interface{}, error) {
+	for k, t := range r.expires {
+		if time.Now().After(t) {
+			delete(r.data, k)
+			delete(r.expires, k)
+		}
+	}
+	switch cmd {
+	case "GET":
+		if v, ok := r.data[args[0].(string)]; ok {
+			return v, nil
+		}
+		return nil, nil
+	case "SET":
+		k := args[0].(string)
+		r.data[k] = args[1].([]byte)
+		delete(r.expires, k)
+		if len(args) == 4 && args[2] == "PX" {
+			ms := args[3].(int64)
+			r.expires[k] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		}
+		return "OK", nil
+	case "DEL":
+		delete(r.data, args[0].(string))
+		return int64(1), nil
+	case "SCAN":
+		prefix := strings.TrimSuffix(args[2].(string), "*")
+		var keys []interface{}
+		for k := range r.data {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, []byte(k))
+			}
+		}
+		return []interface{}{[]byte("0"), keys}, nil
+	}
+	return nil, fmt.Errorf("unsupported command %s", cmd)
+}
+
+func TestRedisStore(t *testing.T) {
+	testStore(t, NewRedisStore(newFakeRedis()))
+}
+
+func TestRedisStore_Prefix(t *testing.T) {
+	r := newFakeRedis()
+	rs := NewRedisStore(r)
+	rs.Prefix = "test:"
+	rs.Put(&Session{ID: "x"}, 0)
+	if _, ok := r.data["test:x"]; !ok {
+		t.Fatal("Session was not stored with the custom prefix")
+	}
+}
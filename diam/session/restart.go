@@ -0,0 +1,56 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/sm"
+)
+
+// Purge deletes the sessions of the store anchored on the peer, those
+// whose Peer is its Origin-Host, and returns the number of sessions
+// deleted.
+func Purge(store Store, peer datatype.DiameterIdentity) (int, error) {
+	var ids []string
+	err := store.Scan(func(s *Session) bool {
+		if s.Peer == peer {
+			ids = append(ids, s.ID)
+		}
+		return true
+	})
+	if err != nil {
+		return 0, err
+	}
+	for n, id := range ids {
+		if err = store.Delete(id); err != nil {
+			return n, err
+		}
+	}
+	return len(ids), nil
+}
+
+// PurgeRestarted purges the sessions anchored on the peers of the
+// sm.PeerRestarted events received from c, as the peers lost their
+// state when they restarted. It returns when c is closed. Errors
+// purging the sessions are sent to the reporter, which is optional.
+//
+// Example:
+//
+//	go session.PurgeRestarted(store, sm.Events().Subscribe(10), mux)
+func PurgeRestarted(store Store, c <-chan *sm.Event, reporter diam.ErrorReporter) {
+	for e := range c {
+		if e.Type != sm.PeerRestarted || e.Peer == nil {
+			continue
+		}
+		if _, err := Purge(store, e.Peer.OriginHost); err != nil && reporter != nil {
+			reporter.Error(&diam.ErrorReport{
+				Conn:    e.Conn,
+				Message: e.Message,
+				Error:   err,
+			})
+		}
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/sm"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+func TestPurgeRestarted(t *testing.T) {
+	store := NewMemoryStore()
+	store.Put(&Session{ID: "cli;1", Peer: "ocs1"}, 0)
+	store.Put(&Session{ID: "cli;2", Peer: "ocs2"}, 0)
+	store.Put(&Session{ID: "cli;3", Peer: "ocs1"}, 0)
+	c := make(chan *sm.Event, 2)
+	peer := &smpeer.Metadata{OriginHost: "ocs1"}
+	c <- &sm.Event{Type: sm.PeerUp, Peer: &smpeer.Metadata{OriginHost: "ocs2"}}
+	c <- &sm.Event{Type: sm.PeerRestarted, Peer: peer}
+	close(c)
+	PurgeRestarted(store, c, nil)
+	for id, want := range map[string]bool{"cli;1": false, "cli;2": true, "cli;3": false} {
+		if _, err := store.Get(id); (err == nil) != want {
+			t.Fatalf("Unexpected session %s in the store: %v", id, err)
+		}
+	}
+}
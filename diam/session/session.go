@@ -0,0 +1,44 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"errors"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// ErrNotFound is returned by Store.Get when the session does not exist
+// or has expired.
+var ErrNotFound = errors.New("session not found")
+
+// Session is the state of a diameter session.
+type Session struct {
+	ID            string                    // Session-Id AVP
+	ApplicationID uint32                    // Application of the session
+	Peer          datatype.DiameterIdentity // Origin-Host of the peer serving the session
+	Updated       time.Time                 // Last time the session was stored
+	State         map[string]string         // Application specific state
+}
+
+// Store is the storage interface used by the session subsystem.
+//
+// Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the session with the given id, or ErrNotFound.
+	Get(id string) (*Session, error)
+
+	// Put stores the session. The session expires after ttl,
+	// or never if ttl is zero.
+	Put(s *Session, ttl time.Duration) error
+
+	// Delete removes the session with the given id, if it exists.
+	Delete(id string) error
+
+	// Scan calls fn for each session in the store, until fn
+	// returns false.
+	Scan(fn func(s *Session) bool) error
+}
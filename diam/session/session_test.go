@@ -0,0 +1,57 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+// testStore runs the same set of checks against any Store.
+func testStore(t *testing.T, s Store) {
+	want := &Session{
+		ID:            "cli;1;2",
+		ApplicationID: 4,
+		Peer:          "srv",
+		State:         map[string]string{"foo": "bar"},
+	}
+	if err := s.Put(want, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(&Session{ID: "cli;1;3"}, time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	have, err := s.Get(want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if have.ApplicationID != 4 || have.Peer != "srv" || have.State["foo"] != "bar" {
+		t.Fatalf("Unexpected session. Want %#v, have %#v", want, have)
+	}
+	if have.Updated.IsZero() {
+		t.Fatal("Updated time was not set")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err = s.Get("cli;1;3"); err != ErrNotFound {
+		t.Fatalf("Unexpected error for expired session: %v", err)
+	}
+	var n int
+	err = s.Scan(func(s *Session) bool {
+		n++
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Fatalf("Unexpected # of sessions. Want 1, have %d", n)
+	}
+	if err = s.Delete(want.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = s.Get(want.ID); err != ErrNotFound {
+		t.Fatalf("Unexpected error for deleted session: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sla
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// Class is the outcome of a transaction.
+type Class int
+
+// Transaction classes.
+const (
+	Success          Class = iota // 1xxx and 2xxx
+	ProtocolError                 // 3xxx
+	TransientFailure              // 4xxx
+	PermanentFailure              // 5xxx
+	Timeout                       // Not answered within the timeout
+	NoResultCode                  // Answered without a valid result code
+	numClasses
+)
+
+var classNames = [numClasses]string{
+	Success:          "Success",
+	ProtocolError:    "ProtocolError",
+	TransientFailure: "TransientFailure",
+	PermanentFailure: "PermanentFailure",
+	Timeout:          "Timeout",
+	NoResultCode:     "NoResultCode",
+}
+
+// String returns the name of the class.
+func (c Class) String() string {
+	if c >= 0 && c < numClasses {
+		return classNames[c]
+	}
+	return "Unknown"
+}
+
+// ClassOf returns the class of the result code.
+func ClassOf(code uint32) Class {
+	switch code / 1000 {
+	case 1, 2:
+		return Success
+	case 3:
+		return ProtocolError
+	case 4:
+		return TransientFailure
+	case 5:
+		return PermanentFailure
+	}
+	return NoResultCode
+}
+
+// Classify returns the class of the answer m, from its Result-Code or
+// Experimental-Result-Code.
+func Classify(m *diam.Message) Class {
+	if code, ok := ResultCode(m); ok {
+		return ClassOf(code)
+	}
+	return NoResultCode
+}
+
+// ResultCode returns the Result-Code of the answer m, or the
+// Experimental-Result-Code of its Experimental-Result AVP.
+func ResultCode(m *diam.Message) (uint32, bool) {
+	for _, a := range m.AVP {
+		switch {
+		case a.Code == avp.ResultCode && a.VendorID == 0:
+			if v, ok := a.Data.(datatype.Unsigned32); ok {
+				return uint32(v), true
+			}
+		case a.Code == avp.ExperimentalResult && a.VendorID == 0:
+			g, ok := a.Data.(*diam.GroupedAVP)
+			if !ok {
+				continue
+			}
+			for _, e := range g.AVP {
+				if e.Code != avp.ExperimentalResultCode {
+					continue
+				}
+				if v, ok := e.Data.(datatype.Unsigned32); ok {
+					return uint32(v), true
+				}
+			}
+		}
+	}
+	return 0, false
+}
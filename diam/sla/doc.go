@@ -0,0 +1,22 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+// Package sla classifies completed Diameter transactions by result and
+// reports success rate and latency per application and command.
+//
+// Transactions are classified by the Result-Code, or the
+// Experimental-Result-Code, of their answer: success (1xxx and 2xxx),
+// protocol error (3xxx), transient failure (4xxx), permanent failure
+// (5xxx), and requests not answered within the timeout.
+//
+// A Reporter follows the requests sent and received on connections
+// through an egress hook and a handler wrapper:
+//
+//	r := &sla.Reporter{Timeout: 5 * time.Second}
+//	mux.HandleEgress(r.Egress)
+//	mux.Handle("CCR", r.Handler(handleCCR)) // Requests from peers.
+//	mux.Handle("RAA", r.Handler(handleRAA)) // Answers from peers.
+//	...
+//	r.WriteReport(os.Stdout)
+package sla
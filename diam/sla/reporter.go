@@ -0,0 +1,357 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sla
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// DefaultTimeout is the time requests are awaited before they are
+// classified as Timeout, when the Reporter has no Timeout.
+const DefaultTimeout = 30 * time.Second
+
+// DefaultBuckets are the upper bounds of the latency histogram, when
+// the Reporter has no Buckets.
+var DefaultBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+}
+
+// Key identifies the transactions of a command.
+type Key struct {
+	ApplicationID uint32
+	CommandCode   uint32
+	Inbound       bool // Requests received from peers
+}
+
+// Stats are the statistics of the transactions of a command.
+type Stats struct {
+	Command string             // Short name of the command, e.g. CC
+	Count   [numClasses]uint64 // Transactions by Class
+	Buckets []time.Duration    // Upper bounds of the latency histogram
+	Latency []uint64           // Answered transactions by bucket, plus one for larger latencies
+	Sum     time.Duration      // Sum of the latency of answered transactions
+}
+
+func newStats(buckets []time.Duration) *Stats {
+	return &Stats{
+		Buckets: buckets,
+		Latency: make([]uint64, len(buckets)+1),
+	}
+}
+
+// Total returns the number of transactions.
+func (s *Stats) Total() uint64 {
+	var n uint64
+	for _, v := range s.Count {
+		n += v
+	}
+	return n
+}
+
+// answered returns the number of answered transactions.
+func (s *Stats) answered() uint64 {
+	return s.Total() - s.Count[Timeout]
+}
+
+// SuccessRate returns the ratio of successful transactions, between 0
+// and 1. Timeouts count as failures. It returns 1 when there are no
+// transactions.
+func (s *Stats) SuccessRate() float64 {
+	total := s.Total()
+	if total == 0 {
+		return 1
+	}
+	return float64(s.Count[Success]) / float64(total)
+}
+
+// MeanLatency returns the mean latency of answered transactions.
+func (s *Stats) MeanLatency() time.Duration {
+	n := s.answered()
+	if n == 0 {
+		return 0
+	}
+	return s.Sum / time.Duration(n)
+}
+
+// Percentile returns the upper bound of the histogram bucket that
+// contains the p-th percentile (0 to 100) of the latency of answered
+// transactions. Latencies over the last bucket are reported as the
+// last bucket.
+func (s *Stats) Percentile(p float64) time.Duration {
+	n := s.answered()
+	if n == 0 || len(s.Buckets) == 0 {
+		return 0
+	}
+	rank := uint64(p / 100 * float64(n))
+	if rank >= n {
+		rank = n - 1
+	}
+	var seen uint64
+	for i, v := range s.Latency {
+		seen += v
+		if seen > rank {
+			if i == len(s.Buckets) {
+				i--
+			}
+			return s.Buckets[i]
+		}
+	}
+	return s.Buckets[len(s.Buckets)-1]
+}
+
+func (s *Stats) observe(class Class, latency time.Duration) {
+	s.Count[class]++
+	if class == Timeout {
+		return
+	}
+	s.Sum += latency
+	i := sort.Search(len(s.Buckets), func(i int) bool {
+		return latency <= s.Buckets[i]
+	})
+	s.Latency[i]++
+}
+
+func (s *Stats) clone() Stats {
+	c := *s
+	c.Latency = append([]uint64(nil), s.Latency...)
+	return c
+}
+
+// txKey identifies a pending transaction.
+type txKey struct {
+	conn     diam.Conn
+	hopByHop uint32
+	endToEnd uint32
+	inbound  bool
+}
+
+type tx struct {
+	key   Key
+	name  string
+	start time.Time
+	timer diam.Timer
+}
+
+// Reporter classifies the transactions of the connections it follows,
+// through Egress and Handler, and collects their Stats.
+//
+// Transactions may also be reported directly with Observe. The zero
+// value is ready to use. Timeout, Buckets and Clock must not be modified
+// after the Reporter is used.
+type Reporter struct {
+	Timeout time.Duration   // Time to await answers (uses DefaultTimeout if unset)
+	Buckets []time.Duration // Latency histogram (uses DefaultBuckets if unset)
+	Clock   diam.Clock      // Source of time (uses diam.SystemClock if unset)
+
+	mu      sync.Mutex
+	pending map[txKey]*tx
+	stats   map[Key]*Stats
+}
+
+// Egress is a diam.EgressFunc that starts the transactions of requests
+// sent and completes the transactions of answers sent.
+func (r *Reporter) Egress(c diam.Conn, m *diam.Message) error {
+	if isRequest(m) {
+		r.start(c, m, false)
+	} else {
+		r.complete(c, m, true)
+	}
+	return nil
+}
+
+// Handler returns a diam.Handler that starts the transactions of
+// requests received and completes the transactions of answers
+// received, before calling h.
+func (r *Reporter) Handler(h diam.Handler) diam.Handler {
+	return diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		if isRequest(m) {
+			r.start(c, m, true)
+		} else {
+			r.complete(c, m, false)
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+// Observe reports a transaction completed with the answer a after the
+// given latency. A nil answer reports a Timeout. The request req may be
+// the answer itself, only its header and dictionary are used.
+func (r *Reporter) Observe(req, a *diam.Message, latency time.Duration) {
+	class := Timeout
+	if a != nil {
+		class = Classify(a)
+	}
+	r.mu.Lock()
+	r.statsOf(keyOf(req, false), commandName(req)).observe(class, latency)
+	r.mu.Unlock()
+}
+
+// Snapshot returns a copy of the statistics collected so far.
+func (r *Reporter) Snapshot() map[Key]Stats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := make(map[Key]Stats, len(r.stats))
+	for k, v := range r.stats {
+		s[k] = v.clone()
+	}
+	return s
+}
+
+// Reset clears the statistics collected so far. Pending transactions
+// are not affected.
+func (r *Reporter) Reset() {
+	r.mu.Lock()
+	r.stats = nil
+	r.mu.Unlock()
+}
+
+// WriteReport writes a table with the success rate and latency of the
+// transactions of each command to w.
+func (r *Reporter) WriteReport(w io.Writer) error {
+	snap := r.Snapshot()
+	keys := make(byKey, 0, len(snap))
+	for k := range snap {
+		keys = append(keys, k)
+	}
+	sort.Sort(keys)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "APP\tCOMMAND\tDIR\tTOTAL\tSUCCESS\t3XXX\t4XXX\t5XXX\tTIMEOUT\tMEAN\tP50\tP99")
+	for _, k := range keys {
+		s := snap[k]
+		dir := "out"
+		if k.Inbound {
+			dir = "in"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%.2f%%\t%d\t%d\t%d\t%d\t%s\t%s\t%s\n",
+			k.ApplicationID, s.Command, dir, s.Total(),
+			100*s.SuccessRate(),
+			s.Count[ProtocolError], s.Count[TransientFailure],
+			s.Count[PermanentFailure], s.Count[Timeout],
+			s.MeanLatency(), s.Percentile(50), s.Percentile(99))
+	}
+	return tw.Flush()
+}
+
+// start records the request m, sent or received on c.
+func (r *Reporter) start(c diam.Conn, m *diam.Message, inbound bool) {
+	k := txKey{c, m.Header.HopByHopID, m.Header.EndToEndID, inbound}
+	t := &tx{key: keyOf(m, inbound), name: commandName(m), start: r.clock().Now()}
+	timeout := r.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.pending == nil {
+		r.pending = make(map[txKey]*tx)
+	}
+	if old, ok := r.pending[k]; ok {
+		// Retransmission, keep the original start time.
+		old.timer.Reset(timeout)
+		return
+	}
+	r.pending[k] = t
+	t.timer = r.clock().AfterFunc(timeout, func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if r.pending[k] != t {
+			return
+		}
+		delete(r.pending, k)
+		r.statsOf(t.key, t.name).observe(Timeout, 0)
+	})
+}
+
+// complete records the answer m, sent or received on c, to a request
+// in the opposite direction.
+func (r *Reporter) complete(c diam.Conn, m *diam.Message, inbound bool) {
+	k := txKey{c, m.Header.HopByHopID, m.Header.EndToEndID, inbound}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	t, ok := r.pending[k]
+	if !ok {
+		return
+	}
+	delete(r.pending, k)
+	t.timer.Stop()
+	r.statsOf(t.key, t.name).observe(Classify(m), r.clock().Now().Sub(t.start))
+}
+
+func (r *Reporter) clock() diam.Clock {
+	if r.Clock == nil {
+		return diam.SystemClock
+	}
+	return r.Clock
+}
+
+// statsOf returns the Stats of the key k, creating them if needed.
+// Must be called with the lock held.
+func (r *Reporter) statsOf(k Key, name string) *Stats {
+	if r.stats == nil {
+		r.stats = make(map[Key]*Stats)
+	}
+	s, ok := r.stats[k]
+	if !ok {
+		buckets := r.Buckets
+		if buckets == nil {
+			buckets = DefaultBuckets
+		}
+		s = newStats(buckets)
+		s.Command = name
+		r.stats[k] = s
+	}
+	return s
+}
+
+func keyOf(m *diam.Message, inbound bool) Key {
+	return Key{
+		ApplicationID: m.Header.ApplicationID,
+		CommandCode:   m.Header.CommandCode,
+		Inbound:       inbound,
+	}
+}
+
+// commandName returns the short name of the command of m, or its code.
+func commandName(m *diam.Message) string {
+	n, ok := m.CommandName()
+	if !ok {
+		return fmt.Sprintf("%d", m.Header.CommandCode)
+	}
+	return n.Short
+}
+
+func isRequest(m *diam.Message) bool {
+	return m.Header.CommandFlags&diam.RequestFlag != 0
+}
+
+type byKey []Key
+
+func (k byKey) Len() int      { return len(k) }
+func (k byKey) Swap(i, j int) { k[i], k[j] = k[j], k[i] }
+func (k byKey) Less(i, j int) bool {
+	switch {
+	case k[i].ApplicationID != k[j].ApplicationID:
+		return k[i].ApplicationID < k[j].ApplicationID
+	case k[i].CommandCode != k[j].CommandCode:
+		return k[i].CommandCode < k[j].CommandCode
+	}
+	return !k[i].Inbound && k[j].Inbound
+}
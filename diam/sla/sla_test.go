@@ -0,0 +1,162 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sla
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestClassify(t *testing.T) {
+	experimental := func(code uint32) *diam.Message {
+		m := diam.NewMessage(diam.Accounting, 0, 3, 0, 0, dict.Default)
+		m.NewAVP(avp.ExperimentalResult, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{
+				diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+				diam.NewAVP(avp.ExperimentalResultCode, avp.Mbit, 0, datatype.Unsigned32(code)),
+			},
+		})
+		return m
+	}
+	req := diam.NewRequest(diam.Accounting, 3, dict.Default)
+	for _, test := range []struct {
+		m    *diam.Message
+		want Class
+	}{
+		{req.Answer(diam.Success), Success},
+		{req.Answer(diam.LimitedSuccess), Success},
+		{req.Answer(diam.TooBusy), ProtocolError},
+		{req.Answer(diam.AuthenticationRejected), TransientFailure},
+		{req.Answer(diam.UnableToComply), PermanentFailure},
+		{req.Answer(0), NoResultCode},
+		{experimental(5030), PermanentFailure},
+		{diam.NewMessage(diam.Accounting, 0, 3, 0, 0, dict.Default), NoResultCode},
+	} {
+		if c := Classify(test.m); c != test.want {
+			t.Errorf("Unexpected class. Want %s, have %s:\n%s", test.want, c, test.m)
+		}
+	}
+}
+
+func TestStats_Percentile(t *testing.T) {
+	s := newStats([]time.Duration{time.Millisecond, 10 * time.Millisecond})
+	for i := 0; i < 8; i++ {
+		s.observe(Success, 500*time.Microsecond)
+	}
+	s.observe(PermanentFailure, 5*time.Millisecond)
+	s.observe(Success, time.Second)
+	s.observe(Timeout, 0)
+	if v := s.Percentile(50); v != time.Millisecond {
+		t.Fatalf("Unexpected p50. Want %s, have %s", time.Millisecond, v)
+	}
+	if v := s.Percentile(99); v != 10*time.Millisecond {
+		t.Fatalf("Unexpected p99. Want %s, have %s", 10*time.Millisecond, v)
+	}
+	if v := s.SuccessRate(); v != 9.0/11 {
+		t.Fatalf("Unexpected success rate. Want %f, have %f", 9.0/11, v)
+	}
+	want := (8*500*time.Microsecond + 5*time.Millisecond + time.Second) / 10
+	if v := s.MeanLatency(); v != want {
+		t.Fatalf("Unexpected mean latency. Want %s, have %s", want, v)
+	}
+}
+
+func TestReporter(t *testing.T) {
+	// The server answers by Accounting-Record-Number: 1 with success,
+	// 2 with a permanent failure, and never answers 3.
+	smux := diam.NewServeMux()
+	srvReporter := &Reporter{}
+	smux.HandleEgress(srvReporter.Egress)
+	smux.Handle("ACR", srvReporter.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		rn, err := m.FindAVP(avp.AccountingRecordNumber, 0)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		switch rn.Data.(datatype.Unsigned32) {
+		case 1:
+			m.Answer(diam.Success).WriteTo(c)
+		case 2:
+			m.Answer(diam.UnableToComply).WriteTo(c)
+		}
+	})))
+	srv := diamtest.NewServer(smux, dict.Default)
+	defer srv.Close()
+
+	r := &Reporter{Timeout: 100 * time.Millisecond}
+	mc := make(chan *diam.Message, 3)
+	cmux := diam.NewServeMux()
+	cmux.HandleEgress(r.Egress)
+	cmux.Handle("ACA", r.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})))
+	cli, err := diam.Dial(srv.Addr, cmux, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	for i := 1; i <= 3; i++ {
+		m := diam.NewRequest(diam.Accounting, 3, dict.Default)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		m.NewAVP(avp.AccountingRecordNumber, avp.Mbit, 0, datatype.Unsigned32(i))
+		if _, err = m.WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case <-mc:
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for ACA")
+		}
+	}
+	time.Sleep(200 * time.Millisecond)
+
+	key := Key{ApplicationID: 3, CommandCode: diam.Accounting}
+	s, ok := r.Snapshot()[key]
+	if !ok {
+		t.Fatalf("Missing outbound stats: %v", r.Snapshot())
+	}
+	if s.Command != "AC" {
+		t.Fatalf("Unexpected command. Want AC, have %s", s.Command)
+	}
+	if s.Count[Success] != 1 || s.Count[PermanentFailure] != 1 || s.Count[Timeout] != 1 {
+		t.Fatalf("Unexpected counts: %v", s.Count)
+	}
+	key.Inbound = true
+	s, ok = srvReporter.Snapshot()[key]
+	if !ok {
+		t.Fatalf("Missing inbound stats: %v", srvReporter.Snapshot())
+	}
+	// The server reporter keeps the unanswered request pending.
+	if s.Total() != 2 || s.SuccessRate() != 0.5 {
+		t.Fatalf("Unexpected inbound stats: %v", s.Count)
+	}
+
+	var b bytes.Buffer
+	if err = r.WriteReport(&b); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Unexpected report:\n%s", b.String())
+	}
+	fields := strings.Fields(lines[1])
+	if fields[0] != "3" || fields[1] != "AC" || fields[2] != "out" || fields[3] != "3" || fields[4] != "33.33%" {
+		t.Fatalf("Unexpected report:\n%s", b.String())
+	}
+	r.Reset()
+	if len(r.Snapshot()) != 0 {
+		t.Fatalf("Unexpected stats after Reset: %v", r.Snapshot())
+	}
+}
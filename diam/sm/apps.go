@@ -0,0 +1,37 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// VendorAuthApplicationID returns a Vendor-Specific-Application-Id AVP
+// with the Vendor-Id and Auth-Application-Id AVPs of the application,
+// e.g. for the VendorSpecificApplicationID of Clients:
+//
+//	cli.VendorSpecificApplicationID = []*diam.AVP{
+//		sm.VendorAuthApplicationID(10415, 16777238), // Gx
+//	}
+func VendorAuthApplicationID(vendorID, appID uint32) *diam.AVP {
+	return vendorApplicationID(vendorID, avp.AuthApplicationID, appID)
+}
+
+// VendorAcctApplicationID returns a Vendor-Specific-Application-Id AVP
+// with the Vendor-Id and Acct-Application-Id AVPs of the application.
+func VendorAcctApplicationID(vendorID, appID uint32) *diam.AVP {
+	return vendorApplicationID(vendorID, avp.AcctApplicationID, appID)
+}
+
+func vendorApplicationID(vendorID, code, appID uint32) *diam.AVP {
+	return diam.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(vendorID)),
+			diam.NewAVP(code, avp.Mbit, 0, datatype.Unsigned32(appID)),
+		},
+	})
+}
@@ -0,0 +1,38 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestVendorApplicationID(t *testing.T) {
+	var tests = []struct {
+		a    *diam.AVP
+		code uint32
+	}{
+		{VendorAuthApplicationID(10415, 4), avp.AuthApplicationID},
+		{VendorAcctApplicationID(10415, 4), avp.AcctApplicationID},
+	}
+	for _, test := range tests {
+		if test.a.Code != avp.VendorSpecificApplicationID || test.a.Flags != avp.Mbit {
+			t.Fatalf("Unexpected AVP: %s", test.a)
+		}
+		g, ok := test.a.Data.(*diam.GroupedAVP)
+		if !ok || len(g.AVP) != 2 {
+			t.Fatalf("Unexpected data: %s", test.a)
+		}
+		if g.AVP[0].Code != avp.VendorID || g.AVP[0].Data != datatype.Unsigned32(10415) {
+			t.Fatalf("Unexpected Vendor-Id: %s", g.AVP[0])
+		}
+		if g.AVP[1].Code != test.code || g.AVP[1].Data != datatype.Unsigned32(4) {
+			t.Fatalf("Unexpected application id: %s", g.AVP[1])
+		}
+	}
+}
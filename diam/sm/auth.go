@@ -0,0 +1,210 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// ErrNoAllowedApplication is the reason peers are rejected when none
+// of the applications in common with them is allowed by PeerLimits.
+var ErrNoAllowedApplication = errors.New("no allowed application in common with peer")
+
+// ErrPolicyViolation is the reason connections are closed when peers
+// send requests not allowed by PeerLimits with CloseOnViolation set.
+var ErrPolicyViolation = errors.New("peer sent a request not allowed by its limits")
+
+// PeerIdentity is the identity of a peer that sent a valid CER,
+// presented to the Authenticator.
+type PeerIdentity struct {
+	OriginHost   datatype.DiameterIdentity
+	OriginRealm  datatype.DiameterIdentity
+	RemoteAddr   net.Addr
+	TLS          *tls.ConnectionState // TLS state, or nil when not using TLS
+	Applications []uint32             // Applications in common with the peer
+	CER          *diam.Message
+}
+
+// PeerLimits are the limits applied to a peer admitted by the
+// Authenticator.
+type PeerLimits struct {
+	// MaxTPS is the maximum number of application requests per
+	// second accepted from the peer. Requests over the limit are
+	// answered with DIAMETER_TOO_BUSY (3004). Zero means unlimited.
+	// Base protocol requests such as DWR are not limited.
+	MaxTPS int
+
+	// Applications is the list of applications the peer is allowed
+	// to use, out of the ones in common with it. Only the allowed
+	// applications are advertised in the CEA, and requests of other
+	// applications are answered with DIAMETER_APPLICATION_UNSUPPORTED
+	// (3007). When unset, all applications in common are allowed.
+	Applications []uint32
+
+	// Commands is the list of command codes the peer is allowed to
+	// send requests of, e.g. the ones of S6a on a roaming
+	// interconnect. Requests of other commands are answered with
+	// DIAMETER_COMMAND_UNSUPPORTED (3001). When unset, all commands
+	// are allowed. Base protocol requests are always allowed.
+	Commands []uint32
+
+	// DeniedCommands is the list of command codes the peer is not
+	// allowed to send requests of, answered like the ones missing
+	// from Commands.
+	DeniedCommands []uint32
+
+	// CloseOnViolation closes the connection with ErrPolicyViolation
+	// when the peer sends a request of an application or command it
+	// is not allowed to use, instead of answering it.
+	CloseOnViolation bool
+}
+
+// Authenticator admits or rejects peers during CER processing, e.g.
+// consulting an allow list, LDAP or a database.
+//
+// Authenticate returns the limits of the admitted peer, or nil for no
+// limits. Peers are rejected with a non-nil error: the CEA carries
+// DIAMETER_UNKNOWN_PEER (3010) and the connection is closed with the
+// error, see diam.Conn.Err.
+//
+// Authenticate is called from the read loop of the connection, and
+// delays other messages from the peer until it returns.
+type Authenticator interface {
+	Authenticate(peer *PeerIdentity) (*PeerLimits, error)
+}
+
+// The AuthenticatorFunc type is an adapter to allow the use of
+// ordinary functions as Authenticators.
+type AuthenticatorFunc func(peer *PeerIdentity) (*PeerLimits, error)
+
+// Authenticate calls f(peer).
+func (f AuthenticatorFunc) Authenticate(peer *PeerIdentity) (*PeerLimits, error) {
+	return f(peer)
+}
+
+// Authenticate sets the Authenticator called for each CER received
+// by the state machine. All peers with a valid CER are admitted when
+// no Authenticator is set.
+func (sm *StateMachine) Authenticate(a Authenticator) {
+	sm.auth.Store(authenticator{a})
+}
+
+// authenticator wraps Authenticators stored in atomic.Value, which
+// requires a consistent concrete type.
+type authenticator struct {
+	Authenticator
+}
+
+// authenticate calls the Authenticator of the state machine, if any.
+func (sm *StateMachine) authenticate(peer *PeerIdentity) (*PeerLimits, error) {
+	a, ok := sm.auth.Load().(authenticator)
+	if !ok || a.Authenticator == nil {
+		return nil, nil
+	}
+	return a.Authenticate(peer)
+}
+
+// allowedApps returns the applications in apps allowed by the limits.
+func (l *PeerLimits) allowedApps(apps []uint32) []uint32 {
+	if l == nil || l.Applications == nil {
+		return apps
+	}
+	var allowed []uint32
+	for _, id := range apps {
+		if l.allows(id) {
+			allowed = append(allowed, id)
+		}
+	}
+	return allowed
+}
+
+// allows returns true if the application id is allowed by the limits.
+func (l *PeerLimits) allows(id uint32) bool {
+	if l == nil || l.Applications == nil || id == 0 {
+		return true
+	}
+	for _, app := range l.Applications {
+		if app == id {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsCommand returns true if the command code is allowed by the
+// limits.
+func (l *PeerLimits) allowsCommand(code uint32) bool {
+	for _, c := range l.DeniedCommands {
+		if c == code {
+			return false
+		}
+	}
+	if l.Commands == nil {
+		return true
+	}
+	for _, c := range l.Commands {
+		if c == code {
+			return true
+		}
+	}
+	return false
+}
+
+// admit returns true if the request m from the peer, received at the
+// time now, is within its limits, and otherwise answers it.
+func (p *Peer) admit(m *diam.Message, now time.Time) bool {
+	if p.Limits == nil || m.Header.ApplicationID == 0 {
+		return true
+	}
+	code := uint32(0)
+	switch {
+	case !p.Limits.allows(m.Header.ApplicationID):
+		code = diam.ApplicationUnsupported
+	case !p.Limits.allowsCommand(m.Header.CommandCode):
+		code = diam.CommandUnsupported
+	case !p.take(now):
+		code = diam.TooBusy
+	default:
+		return true
+	}
+	if code != diam.TooBusy && p.Limits.CloseOnViolation {
+		diam.CloseWithError(p.Conn, ErrPolicyViolation)
+		return false
+	}
+	m.Answer(code).WriteTo(p.Conn)
+	return false
+}
+
+// take takes a token from the bucket of MaxTPS tokens of the peer,
+// refilled at MaxTPS tokens per second, and returns false if the
+// bucket is empty.
+func (p *Peer) take(now time.Time) bool {
+	max := float64(p.Limits.MaxTPS)
+	if max <= 0 {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.last.IsZero() {
+		p.tokens = max
+	} else {
+		p.tokens += now.Sub(p.last).Seconds() * max
+		if p.tokens > max {
+			p.tokens = max
+		}
+	}
+	p.last = now
+	if p.tokens < 1 {
+		return false
+	}
+	p.tokens--
+	return true
+}
@@ -0,0 +1,183 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+var errBadPeer = errors.New("bad peer")
+
+func authServer(t *testing.T) (*StateMachine, *diamtest.Server) {
+	sm := New(serverSettings)
+	sm.Authenticate(AuthenticatorFunc(func(p *PeerIdentity) (*PeerLimits, error) {
+		if p.OriginHost != clientSettings.OriginHost {
+			return nil, errBadPeer
+		}
+		if p.RemoteAddr == nil || p.TLS != nil || p.CER == nil {
+			t.Errorf("Unexpected peer identity: %#v", p)
+		}
+		return &PeerLimits{MaxTPS: 1, Applications: []uint32{1002}}, nil
+	}))
+	sm.HandleFunc("RAR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	return sm, diamtest.NewServer(sm, dict.Default)
+}
+
+func authClient(host datatype.DiameterIdentity) *Client {
+	settings := *clientSettings
+	settings.OriginHost = host
+	return &Client{
+		Handler: New(&settings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001)),
+		},
+		AuthApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(1002)),
+		},
+	}
+}
+
+func TestStateMachine_Authenticate_Reject(t *testing.T) {
+	sm, srv := authServer(t)
+	defer srv.Close()
+	events := sm.Events().Subscribe(10)
+	if _, err := authClient("bad").Dial(srv.Addr); err == nil {
+		t.Fatal("Unexpected handshake success")
+	}
+	e := waitEvent(t, events, HandshakeFailed)
+	if e.Error != errBadPeer {
+		t.Fatalf("Unexpected error. Want %v, have %v", errBadPeer, e.Error)
+	}
+	select {
+	case <-e.Conn.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the connection to close")
+	}
+	if e.Conn.Err() != errBadPeer {
+		t.Fatalf("Unexpected close reason. Want %v, have %v", errBadPeer, e.Conn.Err())
+	}
+}
+
+func TestStateMachine_Authenticate_Limits(t *testing.T) {
+	_, srv := authServer(t)
+	defer srv.Close()
+	cli := authClient(clientSettings.OriginHost)
+	mc := make(chan *diam.Message, 3)
+	cli.Handler.HandleFunc("RAA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	meta, _ := smpeer.FromContext(c.Context())
+	if len(meta.Applications) != 1 || meta.Applications[0] != 1002 {
+		t.Fatalf("Unexpected applications in CEA: %v", meta.Applications)
+	}
+	for _, test := range []struct {
+		app  uint32
+		code uint32
+	}{
+		{1002, diam.Success},
+		{1002, diam.TooBusy},
+		{1001, diam.ApplicationUnsupported},
+	} {
+		m := diam.NewRequest(diam.ReAuth, test.app, dict.Default)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+		if _, err = m.WriteTo(c); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case a := <-mc:
+			if !testResultCode(a, test.code) {
+				t.Fatalf("Unexpected answer to app %d. Want Result-Code %d:\n%s", test.app, test.code, a)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for RAA")
+		}
+	}
+}
+
+func TestStateMachine_Authenticate_Commands(t *testing.T) {
+	limits := &PeerLimits{
+		Commands:       []uint32{diam.ReAuth, diam.AbortSession},
+		DeniedCommands: []uint32{diam.AbortSession},
+	}
+	sm := New(serverSettings)
+	sm.Authenticate(AuthenticatorFunc(func(p *PeerIdentity) (*PeerLimits, error) {
+		return limits, nil
+	}))
+	sm.HandleFunc("RAR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	cli := authClient(clientSettings.OriginHost)
+	mc := make(chan *diam.Message, 3)
+	cli.Handler.HandleFunc("ALL", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	for _, test := range []struct {
+		cmd  uint32
+		code uint32
+	}{
+		{diam.ReAuth, diam.Success},
+		{diam.AbortSession, diam.CommandUnsupported},
+		{diam.SessionTermination, diam.CommandUnsupported},
+	} {
+		m := diam.NewRequest(test.cmd, 1002, dict.Default)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		if _, err = m.WriteTo(c); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case a := <-mc:
+			if !testResultCode(a, test.code) {
+				t.Fatalf("Unexpected answer to command %d. Want Result-Code %d:\n%s", test.cmd, test.code, a)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for answer to command %d", test.cmd)
+		}
+	}
+
+	// Close the connection on violations.
+	limits.CloseOnViolation = true
+	c2, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	m := diam.NewRequest(diam.AbortSession, 1002, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;2"))
+	if _, err = m.WriteTo(c2); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c2.Done():
+	case a := <-mc:
+		t.Fatalf("Unexpected answer:\n%s", a)
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for the connection to close")
+	}
+}
@@ -0,0 +1,66 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+// PeerCapabilities is the capabilities exchange of a peering, as
+// exported by StateMachine.ExportCapabilities.
+type PeerCapabilities struct {
+	LocalAddr  string `json:"local_addr"`
+	RemoteAddr string `json:"remote_addr"`
+	Draining   bool   `json:"draining"`
+	*smpeer.Capabilities
+}
+
+// Capabilities returns the snapshot of the capabilities exchange of
+// every peer, including the ones draining, ordered by Origin-Host.
+func (sm *StateMachine) Capabilities() []*PeerCapabilities {
+	sm.peersMu.RLock()
+	caps := make([]*PeerCapabilities, 0, len(sm.peers))
+	for c, p := range sm.peers {
+		if p.Metadata.Capabilities == nil {
+			continue
+		}
+		caps = append(caps, &PeerCapabilities{
+			LocalAddr:    c.LocalAddr().String(),
+			RemoteAddr:   c.RemoteAddr().String(),
+			Draining:     p.Draining(),
+			Capabilities: p.Metadata.Capabilities,
+		})
+	}
+	sm.peersMu.RUnlock()
+	sort.Sort(byOriginHost(caps))
+	return caps
+}
+
+// ExportCapabilities writes the snapshot of the capabilities exchange
+// of every peer to w, as a JSON array. See Capabilities.
+func (sm *StateMachine) ExportCapabilities(w io.Writer) error {
+	b, err := json.MarshalIndent(sm.Capabilities(), "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+type byOriginHost []*PeerCapabilities
+
+func (s byOriginHost) Len() int      { return len(s) }
+func (s byOriginHost) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+
+func (s byOriginHost) Less(i, j int) bool {
+	if s[i].Remote.OriginHost != s[j].Remote.OriginHost {
+		return s[i].Remote.OriginHost < s[j].Remote.OriginHost
+	}
+	return s[i].RemoteAddr < s[j].RemoteAddr
+}
@@ -0,0 +1,102 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestCapabilities(t *testing.T) {
+	ssm := New(serverSettings)
+	srv := diamtest.NewServer(ssm, dict.Default)
+	defer srv.Close()
+	events := ssm.Events().Subscribe(10)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AuthApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4)),
+		},
+		VendorSpecificApplicationID: []*diam.AVP{
+			VendorAuthApplicationID(10415, 1),
+		},
+	}
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case e := <-events:
+		if e.Type != PeerUp {
+			t.Fatalf("Unexpected event. Want PeerUp, have %s", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for handshake")
+	}
+
+	caps := cli.Handler.Capabilities()
+	if len(caps) != 1 {
+		t.Fatalf("Unexpected number of peers. Want 1, have %d", len(caps))
+	}
+	cc := caps[0]
+	if !cc.Initiator {
+		t.Fatal("Client is not the initiator")
+	}
+	if cc.ResultCode != diam.Success {
+		t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, cc.ResultCode)
+	}
+	if cc.Local.OriginHost != "cli" || cc.Remote.OriginHost != "srv" {
+		t.Fatalf("Unexpected Origin-Host. Want cli/srv, have %s/%s",
+			cc.Local.OriginHost, cc.Remote.OriginHost)
+	}
+	if len(cc.Local.VendorSpecificApplicationID) != 1 ||
+		cc.Local.VendorSpecificApplicationID[0].AuthApplicationID != 1 {
+		t.Fatalf("Unexpected Vendor-Specific-Application-Id: %#v",
+			cc.Local.VendorSpecificApplicationID)
+	}
+	if len(cc.Applications) != 2 {
+		t.Fatalf("Unexpected negotiated applications. Want 2, have %v", cc.Applications)
+	}
+
+	caps = ssm.Capabilities()
+	if len(caps) != 1 {
+		t.Fatalf("Unexpected number of peers. Want 1, have %d", len(caps))
+	}
+	sc := caps[0]
+	if sc.Initiator {
+		t.Fatal("Server is the initiator")
+	}
+	if sc.Local.OriginHost != "srv" || sc.Remote.OriginHost != "cli" {
+		t.Fatalf("Unexpected Origin-Host. Want srv/cli, have %s/%s",
+			sc.Local.OriginHost, sc.Remote.OriginHost)
+	}
+	if len(sc.Local.AuthApplicationID) != 1 || sc.Local.AuthApplicationID[0] != 4 {
+		t.Fatalf("Unexpected advertised Auth-Application-Id: %v", sc.Local.AuthApplicationID)
+	}
+	if sc.Remote.FirmwareRevision != 1 {
+		t.Fatalf("Unexpected Firmware-Revision. Want 1, have %d", sc.Remote.FirmwareRevision)
+	}
+
+	var b bytes.Buffer
+	if err = ssm.ExportCapabilities(&b); err != nil {
+		t.Fatal(err)
+	}
+	var v []map[string]interface{}
+	if err = json.Unmarshal(b.Bytes(), &v); err != nil {
+		t.Fatal(err)
+	}
+	if len(v) != 1 || v[0]["remote_addr"] == nil || v[0]["local"] == nil {
+		t.Fatalf("Unexpected JSON: %s", b.Bytes())
+	}
+}
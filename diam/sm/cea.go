@@ -13,24 +13,35 @@ import (
 )
 
 // handleCEA handles Capabilities-Exchange-Answer messages.
-func handleCEA(sm *StateMachine, errc chan error) diam.HandlerFunc {
+//
+// The applications in the CEA are negotiated with the local ones, the
+// applications the client sent in the CER.
+func handleCEA(sm *StateMachine, cer *diam.Message, local []uint32, errc chan error) diam.HandlerFunc {
 	return func(c diam.Conn, m *diam.Message) {
-		cea := new(smparser.CEA)
+		cea := &smparser.CEA{Local: local}
 		if err := cea.Parse(m); err != nil {
+			sm.handshakeFailed(c, m, err)
 			errc <- err
 			return
 		}
-		if cea.ResultCode != diam.Success {
-			errc <- &ErrFailedResultCode{Code: cea.ResultCode}
+		if !cea.Success() {
+			err := &ErrFailedResultCode{Code: cea.Code(), CEA: cea}
+			sm.handshakeFailed(c, m, err)
+			errc <- err
 			return
 		}
 		meta := smpeer.FromCEA(cea)
+		meta.Capabilities = &smpeer.Capabilities{
+			Time:         sm.clock().Now(),
+			Initiator:    true,
+			ResultCode:   cea.Code(),
+			Local:        smpeer.NewAdvertised(cer),
+			Remote:       smpeer.NewAdvertised(m),
+			Applications: meta.Applications,
+		}
 		c.SetContext(smpeer.NewContext(c.Context(), meta))
 		// Notify about peer passing the handshake.
-		select {
-		case sm.hsNotifyc <- c:
-		default:
-		}
+		sm.peerUp(c, meta, nil)
 		// Done receiving and validating this CEA.
 		close(errc)
 	}
@@ -40,6 +51,7 @@ func handleCEA(sm *StateMachine, errc chan error) diam.HandlerFunc {
 // answer (CEA) contains a Result-Code AVP that is not success (2001).
 type ErrFailedResultCode struct {
 	Code uint32
+	CEA  *smparser.CEA // The answer, with details of the failure
 }
 
 // Error implements the error interface.
@@ -5,6 +5,7 @@
 package sm
 
 import (
+	"errors"
 	"fmt"
 	"net"
 
@@ -20,31 +21,77 @@ import (
 // If mandatory AVPs such as Origin-Host or Origin-Realm
 // are missing, we close the connection.
 //
+// Handshake failures are sent to the ErrorReport channel, and
+// capabilities mismatches carry the applications of both peers,
+// see smparser.ErrNoCommonApplication.
+//
+// Peers with a valid CER are admitted by the peer table and the
+// Authenticator of the state machine, if any, see
+// StateMachine.ReloadTables and StateMachine.Authenticate.
+//
+// CERs received after the handshake are handled according to the
+// DuplicateCER policy of the Settings.
+//
 // See RFC 6733 section 5.3 for details.
 func handleCER(sm *StateMachine) diam.HandlerFunc {
 	return func(c diam.Conn, m *diam.Message) {
 		ctx := c.Context()
-		if _, ok := smpeer.FromContext(ctx); ok {
-			// Ignore retransmission.
+		if meta, ok := smpeer.FromContext(ctx); ok {
+			sm.duplicateCER(c, m, meta)
 			return
 		}
-		cer := new(smparser.CER)
+		cfg := sm.settingsFor(c, originRealm(m))
+		cer := &smparser.CER{
+			Local:          cfg.Applications,
+			InbandSecurity: cfg.InbandSecurity,
+		}
 		failedAVP, err := cer.Parse(m)
 		if err != nil {
+			sm.handshakeFailed(c, m, err)
+			sm.Error(&diam.ErrorReport{
+				Conn:    c,
+				Message: m,
+				Error:   err,
+			})
 			if failedAVP != nil {
-				err = errorCEA(sm, c, m, cer, failedAVP)
-				if err != nil {
+				code := uint32(diam.NoCommonApplication)
+				if failedAVP == cer.InbandSecurityID {
+					code = diam.NoCommonSecurity
+				}
+				if werr := errorCEA(cfg, c, m, cer, code, failedAVP); werr != nil {
 					sm.Error(&diam.ErrorReport{
 						Conn:    c,
 						Message: m,
-						Error:   err,
+						Error:   werr,
 					})
 				}
 			}
-			c.Close()
+			diam.CloseWithError(c, err)
 			return
 		}
-		err = successCEA(sm, c, m, cer)
+		limits, apps, err := sm.admitCER(c, m, cer)
+		if err != nil {
+			sm.handshakeFailed(c, m, err)
+			sm.Error(&diam.ErrorReport{
+				Conn:    c,
+				Message: m,
+				Error:   err,
+			})
+			code := uint32(diam.UnknownPeer)
+			if err == ErrNoAllowedApplication {
+				code = diam.NoCommonApplication
+			}
+			if werr := errorCEA(cfg, c, m, cer, code, nil); werr != nil {
+				sm.Error(&diam.ErrorReport{
+					Conn:    c,
+					Message: m,
+					Error:   werr,
+				})
+			}
+			diam.CloseWithError(c, err)
+			return
+		}
+		a, err := successCEA(cfg, c, m, cer, apps)
 		if err != nil {
 			sm.Error(&diam.ErrorReport{
 				Conn:    c,
@@ -54,82 +101,228 @@ func handleCER(sm *StateMachine) diam.HandlerFunc {
 			return
 		}
 		meta := smpeer.FromCER(cer)
+		meta.Applications = apps
+		meta.Capabilities = &smpeer.Capabilities{
+			Time:         sm.clock().Now(),
+			ResultCode:   diam.Success,
+			Local:        smpeer.NewAdvertised(a),
+			Remote:       smpeer.NewAdvertised(m),
+			Applications: meta.Applications,
+		}
 		c.SetContext(smpeer.NewContext(ctx, meta))
 		// Notify about peer passing the handshake.
-		select {
-		case sm.hsNotifyc <- c:
-		default:
-		}
+		sm.peerUp(c, meta, limits)
+	}
+}
+
+// ErrDuplicateCER is reported by servers rejecting a CER received on a
+// connection that already passed the handshake, see RejectCER.
+var ErrDuplicateCER = errors.New("CER received after the handshake")
+
+// CERPolicy is what servers do with a CER received on a connection
+// that already passed the handshake, e.g. a retransmission of the
+// first CER whose CEA was delayed.
+type CERPolicy int
+
+// CER policies.
+const (
+	// IgnoreCER drops the CER.
+	IgnoreCER CERPolicy = iota
+
+	// AnswerCER answers the CER with a success CEA advertising the
+	// applications negotiated in the handshake, like in the R-Open
+	// state of RFC 6733 section 5.6. The metadata of the peer is not
+	// changed.
+	AnswerCER
+
+	// RejectCER answers the CER with DIAMETER_UNABLE_TO_COMPLY
+	// (5012) and reports ErrDuplicateCER. The connection stays open.
+	RejectCER
+)
+
+// duplicateCER handles the CER m received on the connection c, whose
+// peer with metadata meta already passed the handshake.
+func (sm *StateMachine) duplicateCER(c diam.Conn, m *diam.Message, meta *smpeer.Metadata) {
+	cfg := sm.settingsFor(c, originRealm(m))
+	if cfg.DuplicateCER == IgnoreCER {
+		return
+	}
+	cer := &smparser.CER{
+		Local:          cfg.Applications,
+		InbandSecurity: cfg.InbandSecurity,
+	}
+	if _, err := cer.Parse(m); err != nil {
+		sm.Error(&diam.ErrorReport{Conn: c, Message: m, Error: err})
+		return
+	}
+	var err error
+	if cfg.DuplicateCER == AnswerCER {
+		_, err = successCEA(cfg, c, m, cer, meta.Applications)
+	} else {
+		sm.Error(&diam.ErrorReport{Conn: c, Message: m, Error: ErrDuplicateCER})
+		err = errorCEA(cfg, c, m, cer, diam.UnableToComply, nil)
+	}
+	if err != nil {
+		sm.Error(&diam.ErrorReport{Conn: c, Message: m, Error: err})
 	}
 }
 
-// errorCEA sends an error answer indicating that the CER failed due to
-// an unsupported (acct/auth) application, and includes the AVP that
-// caused the failure in the message.
-func errorCEA(sm *StateMachine, c diam.Conn, m *diam.Message, cer *smparser.CER, failedAVP *diam.AVP) error {
+// admitCER checks the peer of the parsed CER against the peer table
+// and the Authenticator of the state machine, and returns its limits
+// and allowed applications.
+func (sm *StateMachine) admitCER(c diam.Conn, m *diam.Message, cer *smparser.CER) (*PeerLimits, []uint32, error) {
+	entry, err := sm.peerEntry(cer.OriginHost)
+	if err != nil {
+		return nil, nil, err
+	}
+	limits, err := sm.authenticate(&PeerIdentity{
+		OriginHost:   cer.OriginHost,
+		OriginRealm:  cer.OriginRealm,
+		RemoteAddr:   c.RemoteAddr(),
+		TLS:          c.TLS(),
+		Applications: cer.Applications(),
+		CER:          m,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if limits == nil && entry != nil {
+		limits = entry.Limits
+	}
+	apps := limits.allowedApps(cer.Applications())
+	if len(apps) == 0 && len(cer.Applications()) > 0 {
+		return nil, nil, ErrNoAllowedApplication
+	}
+	return limits, apps, nil
+}
+
+// errorCEA sends an error answer with the given result code, e.g.
+// indicating that the CER failed due to an unsupported (acct/auth)
+// application. The AVP that caused the failure, if any, is included
+// in the message.
+func errorCEA(cfg *Settings, c diam.Conn, m *diam.Message, cer *smparser.CER, code uint32, failedAVP *diam.AVP) error {
 	hostIP, _, err := net.SplitHostPort(c.LocalAddr().String())
 	if err != nil {
 		return fmt.Errorf("failed to parse own ip %q: %s", c.LocalAddr(), err)
 	}
-	var a *diam.Message
-	if failedAVP == cer.InbandSecurityID {
-		a = m.Answer(diam.NoCommonSecurity)
-	} else {
-		a = m.Answer(diam.NoCommonApplication)
-	}
-	a.Header.CommandFlags |= diam.ErrorFlag
-	a.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
-	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
+	a := m.AnswerWithError(code, true)
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
 	a.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP(hostIP)))
-	a.NewAVP(avp.VendorID, avp.Mbit, 0, sm.cfg.VendorID)
-	a.NewAVP(avp.ProductName, 0, 0, sm.cfg.ProductName)
+	a.NewAVP(avp.VendorID, avp.Mbit, 0, cfg.VendorID)
+	a.NewAVP(avp.ProductName, 0, 0, cfg.ProductName)
 	if cer.OriginStateID != nil {
 		a.AddAVP(cer.OriginStateID)
 	}
-	a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &diam.GroupedAVP{
-		AVP: []*diam.AVP{failedAVP},
-	})
-	if sm.cfg.FirmwareRevision != 0 {
-		a.NewAVP(avp.FirmwareRevision, avp.Mbit, 0, sm.cfg.FirmwareRevision)
+	if failedAVP != nil {
+		a.NewAVP(avp.FailedAVP, avp.Mbit, 0, &diam.GroupedAVP{
+			AVP: []*diam.AVP{failedAVP},
+		})
+	}
+	if cfg.FirmwareRevision != 0 {
+		a.NewAVP(avp.FirmwareRevision, 0, 0, cfg.FirmwareRevision)
 	}
 	_, err = a.WriteTo(c)
 	return err
 }
 
 // successCEA sends a success answer indicating that the CER was successfully
-// parsed and accepted by the server.
-func successCEA(sm *StateMachine, c diam.Conn, m *diam.Message, cer *smparser.CER) error {
+// parsed and accepted by the server, and returns the answer. The answer
+// advertises the given applications in common with the peer.
+func successCEA(cfg *Settings, c diam.Conn, m *diam.Message, cer *smparser.CER, apps []uint32) (*diam.Message, error) {
 	hostIP, _, err := net.SplitHostPort(c.LocalAddr().String())
 	if err != nil {
-		return fmt.Errorf("failed to parse own ip %q: %s", c.LocalAddr(), err)
+		return nil, fmt.Errorf("failed to parse own ip %q: %s", c.LocalAddr(), err)
 	}
 	a := m.Answer(diam.Success)
-	a.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
-	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
+	a.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	a.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
 	a.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(net.ParseIP(hostIP)))
-	a.NewAVP(avp.VendorID, avp.Mbit, 0, sm.cfg.VendorID)
-	a.NewAVP(avp.ProductName, 0, 0, sm.cfg.ProductName)
+	a.NewAVP(avp.VendorID, avp.Mbit, 0, cfg.VendorID)
+	a.NewAVP(avp.ProductName, 0, 0, cfg.ProductName)
 	if cer.OriginStateID != nil {
 		a.AddAVP(cer.OriginStateID)
 	}
-	if cer.AcctApplicationID != nil {
-		for _, acct := range cer.AcctApplicationID {
-			a.AddAVP(acct)
-		}
+	for _, id := range cfg.SupportedVendorID {
+		a.NewAVP(avp.SupportedVendorID, avp.Mbit, 0, id)
+	}
+	if cer.InbandSecurityID != nil {
+		a.AddAVP(cer.InbandSecurityID)
+	}
+	if isRelay(cfg.Applications) {
+		// Relays advertise the relay application only.
+		apps = nil
+		relay := datatype.Unsigned32(smparser.RelayApplicationID)
+		a.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, relay)
+	}
+	for _, acct := range negotiated(cer.AcctApplicationID, apps) {
+		a.AddAVP(acct)
+	}
+	for _, auth := range negotiated(cer.AuthApplicationID, apps) {
+		a.AddAVP(auth)
 	}
-	if cer.AuthApplicationID != nil {
-		for _, auth := range cer.AuthApplicationID {
-			a.AddAVP(auth)
+	for _, vs := range negotiated(cer.VendorSpecificApplicationID, apps) {
+		a.AddAVP(vs)
+	}
+	if cfg.FirmwareRevision != 0 {
+		a.NewAVP(avp.FirmwareRevision, 0, 0, cfg.FirmwareRevision)
+	}
+	_, err = a.WriteTo(c)
+	return a, err
+}
+
+// negotiated returns the application AVPs, including the grouped
+// Vendor-Specific-Application-Id, that carry one of the given ids.
+func negotiated(avps []*diam.AVP, ids []uint32) []*diam.AVP {
+	var apps []*diam.AVP
+	for _, a := range avps {
+		if g, ok := a.Data.(*diam.GroupedAVP); ok {
+			if len(negotiated(g.AVP, ids)) > 0 {
+				apps = append(apps, a)
+			}
+			continue
+		}
+		if a.Code != avp.AcctApplicationID && a.Code != avp.AuthApplicationID {
+			continue
+		}
+		v, ok := a.Data.(datatype.Unsigned32)
+		if !ok {
+			continue
+		}
+		for _, id := range ids {
+			if uint32(v) == id {
+				apps = append(apps, a)
+				break
+			}
 		}
 	}
-	if cer.VendorSpecificApplicationID != nil {
-		for _, vs := range cer.VendorSpecificApplicationID {
-			a.AddAVP(vs)
+	return apps
+}
+
+// isRelay returns true if the list of applications contains the
+// relay application.
+func isRelay(apps []uint32) bool {
+	for _, id := range apps {
+		if id == smparser.RelayApplicationID {
+			return true
 		}
 	}
-	if sm.cfg.FirmwareRevision != 0 {
-		a.NewAVP(avp.FirmwareRevision, avp.Mbit, 0, sm.cfg.FirmwareRevision)
+	return false
+}
+
+// originRealm returns the Origin-Realm of the message m, or an empty
+// identity.
+func originRealm(m *diam.Message) datatype.DiameterIdentity {
+	for _, a := range m.AVP {
+		if a.Code != avp.OriginRealm {
+			continue
+		}
+		switch v := a.Data.(type) {
+		case datatype.DiameterIdentity:
+			return v
+		case datatype.OctetString:
+			return datatype.DiameterIdentity(v)
+		}
 	}
-	_, err = a.WriteTo(c)
-	return err
+	return ""
 }
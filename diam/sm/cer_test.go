@@ -13,6 +13,7 @@ import (
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/diamtest"
 	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
 	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
 )
 
@@ -432,3 +433,286 @@ func TestHandleCER_InbandSecurity(t *testing.T) {
 		t.Fatal("No message received")
 	}
 }
+
+func TestHandleCER_Applications(t *testing.T) {
+	settings := *serverSettings
+	settings.Applications = []uint32{1001}
+	sm := New(&settings)
+	events := sm.Events().Subscribe(1)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CEA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, mux, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, localhostAddress)
+	m.NewAVP(avp.VendorID, avp.Mbit, 0, clientSettings.VendorID)
+	m.NewAVP(avp.ProductName, 0, 0, clientSettings.ProductName)
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(1002))
+	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001))
+	_, err = m.WriteTo(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-mc:
+		if !testResultCode(resp, diam.Success) {
+			t.Fatalf("Unexpected result code.\n%s", resp)
+		}
+		if _, err := resp.FindAVP(avp.AuthApplicationID, 0); err == nil {
+			t.Fatalf("Unexpected Auth-Application-Id in CEA.\n%s", resp)
+		}
+	case err := <-mux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("No message received")
+	}
+	select {
+	case ev := <-events:
+		if !ev.Peer.Supports(1001) || ev.Peer.Supports(1002) {
+			t.Fatalf("Unexpected applications in metadata: %v", ev.Peer.Applications)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No PeerUp event received")
+	}
+}
+
+func TestHandleCER_Relay(t *testing.T) {
+	settings := *serverSettings
+	settings.Applications = []uint32{smparser.RelayApplicationID}
+	srv := diamtest.NewServer(New(&settings), dict.Default)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CEA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, mux, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, localhostAddress)
+	m.NewAVP(avp.VendorID, avp.Mbit, 0, clientSettings.VendorID)
+	m.NewAVP(avp.ProductName, 0, 0, clientSettings.ProductName)
+	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001))
+	_, err = m.WriteTo(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-mc:
+		if !testResultCode(resp, diam.Success) {
+			t.Fatalf("Unexpected result code.\n%s", resp)
+		}
+		a, err := resp.FindAVP(avp.AuthApplicationID, 0)
+		if err != nil {
+			t.Fatalf("Missing relay application in CEA.\n%s", resp)
+		}
+		if v := a.Data.(datatype.Unsigned32); uint32(v) != smparser.RelayApplicationID {
+			t.Fatalf("Unexpected Auth-Application-Id. Want %d, have %d", smparser.RelayApplicationID, v)
+		}
+		if _, err := resp.FindAVP(avp.AcctApplicationID, 0); err == nil {
+			t.Fatalf("Unexpected Acct-Application-Id in CEA.\n%s", resp)
+		}
+	case err := <-mux.ErrorReports():
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("No message received")
+	}
+}
+
+func TestHandleCER_NoCommonApplication_ErrorReport(t *testing.T) {
+	settings := *serverSettings
+	settings.Applications = []uint32{1001}
+	sm := New(&settings)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	cli, err := diam.Dial(srv.Addr, diam.NewServeMux(), dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, localhostAddress)
+	m.NewAVP(avp.VendorID, avp.Mbit, 0, clientSettings.VendorID)
+	m.NewAVP(avp.ProductName, 0, 0, clientSettings.ProductName)
+	m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(1002))
+	m.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1000)),
+		},
+	})
+	_, err = m.WriteTo(cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case report := <-sm.ErrorReports():
+		e, ok := report.Error.(*smparser.ErrNoCommonApplication)
+		if !ok {
+			t.Fatalf("Unexpected error: %v", report.Error)
+		}
+		if len(e.PeerApplications) != 2 || e.PeerApplications[0] != 1002 || e.PeerApplications[1] != 1000 {
+			t.Fatalf("Unexpected peer applications: %v", e.PeerApplications)
+		}
+		if len(e.LocalApplications) != 1 || e.LocalApplications[0] != 1001 {
+			t.Fatalf("Unexpected local applications: %v", e.LocalApplications)
+		}
+		if len(e.VendorIDs) != 1 || e.VendorIDs[0] != 10415 {
+			t.Fatalf("Unexpected vendors: %v", e.VendorIDs)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No error report received")
+	}
+}
+
+func TestHandleCER_PeerCapabilities(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(1)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	settings := *clientSettings
+	settings.SupportedVendorID = []datatype.Unsigned32{10415, 5535}
+	mc := make(chan *diam.Message, 1)
+	cli := &Client{
+		Handler: New(&settings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.mux.HandleEgress(func(c diam.Conn, m *diam.Message) error {
+		if m.Header.CommandCode == diam.CapabilitiesExchange {
+			mc <- m
+		}
+		return nil
+	})
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case m := <-mc:
+		for _, a := range m.AVP {
+			switch a.Code {
+			case avp.ProductName, avp.FirmwareRevision:
+				if a.Flags&avp.Mbit != 0 {
+					t.Fatalf("Unexpected M bit in AVP %d", a.Code)
+				}
+			}
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No CER sent")
+	}
+	select {
+	case ev := <-events:
+		meta := ev.Peer
+		if meta.VendorID != uint32(settings.VendorID) {
+			t.Fatalf("Unexpected Vendor-Id. Want %d, have %d", settings.VendorID, meta.VendorID)
+		}
+		if meta.ProductName != settings.ProductName {
+			t.Fatalf("Unexpected Product-Name. Want %q, have %q", settings.ProductName, meta.ProductName)
+		}
+		if meta.FirmwareRevision != uint32(settings.FirmwareRevision) {
+			t.Fatalf("Unexpected Firmware-Revision. Want %d, have %d", settings.FirmwareRevision, meta.FirmwareRevision)
+		}
+		if v := meta.SupportedVendorID; len(v) != 2 || v[0] != 10415 || v[1] != 5535 {
+			t.Fatalf("Unexpected Supported-Vendor-Id: %v", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("No PeerUp event received")
+	}
+}
+
+func TestHandleCER_Duplicate(t *testing.T) {
+	var tests = []struct {
+		policy CERPolicy
+		code   uint32 // Result-Code of the second CEA, zero if none
+		err    error
+	}{
+		{IgnoreCER, 0, nil},
+		{AnswerCER, diam.Success, nil},
+		{RejectCER, diam.UnableToComply, ErrDuplicateCER},
+	}
+	for _, test := range tests {
+		settings := *serverSettings
+		settings.DuplicateCER = test.policy
+		sm := New(&settings)
+		srv := diamtest.NewServer(sm, dict.Default)
+		mc := make(chan *diam.Message, 2)
+		mux := diam.NewServeMux()
+		mux.HandleFunc("CEA", func(c diam.Conn, m *diam.Message) {
+			mc <- m
+		})
+		cli, err := diam.Dial(srv.Addr, mux, dict.Default)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 2; i++ {
+			m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
+			m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+			m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+			m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, localhostAddress)
+			m.NewAVP(avp.VendorID, avp.Mbit, 0, clientSettings.VendorID)
+			m.NewAVP(avp.ProductName, 0, 0, clientSettings.ProductName)
+			m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001))
+			if _, err = m.WriteTo(cli); err != nil {
+				t.Fatal(err)
+			}
+			want, timeout := uint32(diam.Success), time.Second
+			if i == 1 {
+				want = test.code
+				if want == 0 {
+					timeout = 100 * time.Millisecond
+				}
+			}
+			select {
+			case a := <-mc:
+				if want == 0 {
+					t.Fatalf("Policy %d: unexpected CEA:\n%s", test.policy, a)
+				}
+				if !testResultCode(a, want) {
+					t.Fatalf("Policy %d: unexpected Result-Code. Want %d:\n%s", test.policy, want, a)
+				}
+				if a.Header.HopByHopID != m.Header.HopByHopID {
+					t.Fatalf("Policy %d: CEA does not answer the CER", test.policy)
+				}
+			case <-time.After(timeout):
+				if want != 0 {
+					t.Fatalf("Policy %d: no CEA received", test.policy)
+				}
+			}
+		}
+		if test.err != nil {
+			select {
+			case err := <-sm.ErrorReports():
+				if err.Error != test.err {
+					t.Fatalf("Policy %d: unexpected error. Want %v, have %v", test.policy, test.err, err.Error)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("Policy %d: no error reported", test.policy)
+			}
+		}
+		if n := len(sm.Peers()); n != 1 {
+			t.Fatalf("Policy %d: unexpected # of peers: %d", test.policy, n)
+		}
+		cli.Close()
+		srv.Close()
+	}
+}
@@ -5,15 +5,19 @@
 package sm
 
 import (
+	"crypto/tls"
 	"errors"
 	"net"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/ibrohimislam/go-diameter/diam"
 	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/dict"
 	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
 )
 
 var (
@@ -28,6 +32,15 @@ var (
 	// handshake timeout only occurs after all retransmits are
 	// attempted and none has an aswer.
 	ErrHandshakeTimeout = errors.New("handshake timeout (no response)")
+
+	// ErrWatchdogTimeout is the reason returned by Conn.Err for
+	// connections closed by the client after DWR retransmissions
+	// are not answered.
+	ErrWatchdogTimeout = errors.New("watchdog timeout (no response)")
+
+	// ErrNoAddress is returned by DialAny when it is called without
+	// addresses.
+	ErrNoAddress = errors.New("no address to dial")
 )
 
 // A Client is a diameter client that automatically performs a handshake
@@ -52,39 +65,204 @@ type Client struct {
 	AcctApplicationID           []*diam.AVP   // Acct applications
 	AuthApplicationID           []*diam.AVP   // Auth applications
 	VendorSpecificApplicationID []*diam.AVP   // Vendor specific applications
+
+	// WatchdogIntervals are the intervals between DWRs of specific
+	// peers by Origin-Host, overriding WatchdogInterval. Intervals
+	// can be changed at runtime with Peer.SetWatchdogInterval.
+	WatchdogIntervals map[datatype.DiameterIdentity]time.Duration
+
+	// InbandSecurityID is advertised in CER, NO_INBAND_SECURITY (0)
+	// by default. Set OmitInbandSecurityID to not send the AVP.
+	InbandSecurityID     datatype.Unsigned32
+	OmitInbandSecurityID bool
+
+	// WriteLanes schedules the writes of connections by priority, so
+	// watchdogs and session-critical messages like ASR and STR are
+	// not starved behind bursts of accounting, see diam.WriteLanes.
+	// Optional.
+	WriteLanes *diam.WriteLanes
+
+	// FlowControl limits the outstanding requests of connections,
+	// see diam.FlowControl. Optional.
+	FlowControl *diam.FlowControl
+
+	// Intercept may modify or veto the frames written to
+	// connections, see diam.InterceptFunc. For tests only.
+	Intercept diam.InterceptFunc
+
+	// Network is the transport used by Dial, e.g. "sctp", or "tcp4"
+	// and "tcp6" to dial IPv4 or IPv6 addresses only, see
+	// diam.RegisterDialer. Uses "tcp" if unset.
+	Network string
+
+	// LocalAddr is the local address of the connections, e.g. to
+	// pin the source IP advertised in the Host-IP-Address, see
+	// diam.Server.LocalAddr. Optional.
+	LocalAddr string
+
+	// Dialer establishes the connections instead of the transport of
+	// Network, e.g. through a SOCKS proxy, see diam.Server.Dialer.
+	// Optional.
+	Dialer diam.Dialer
+
+	// FallbackDelay is how long DialAny waits for an address to
+	// complete the handshake before it dials the next one in
+	// parallel, Happy Eyeballs style. Zero dials the addresses one
+	// after the other.
+	FallbackDelay time.Duration
+
+	// TLSConfig is the TLS configuration of DialTLS, e.g. with the
+	// RootCAs and ServerName used to verify the server. Without it,
+	// the certificate of the server is not verified. Optional.
+	TLSConfig *tls.Config
+
+	// WrapConn wraps the connections, e.g. with diam.Compress, see
+	// diam.Server.WrapConn. Optional.
+	WrapConn diam.ConnWrapper
 }
 
 // Dial calls the address set as ip:port, performs a handshake and optionally
 // start a watchdog goroutine in background.
 func (cli *Client) Dial(addr string) (diam.Conn, error) {
-	return cli.dial(func() (diam.Conn, error) {
-		return diam.Dial(addr, cli.Handler, cli.Dict)
+	return cli.DialContext(context.Background(), addr)
+}
+
+// DialContext is like Dial, but gives up connecting and the handshake
+// when ctx is done, e.g. at its deadline, closing the connection and
+// returning the error of ctx. The connection and its watchdog are not
+// affected by ctx after the handshake.
+func (cli *Client) DialContext(ctx context.Context, addr string) (diam.Conn, error) {
+	return cli.dial(ctx, func() (diam.Conn, error) {
+		return cli.server(addr).DialContext(ctx)
 	})
 }
 
-// DialTLS is like Dial, but using TLS.
+// DialAny is like DialContext, but dials each of the addresses in
+// order until one completes the handshake, and returns its connection.
+// With a FallbackDelay, slow addresses are raced with the next ones,
+// and the connections that lose the race are closed.
+//
+// It returns the error of the first address when all fail, or the
+// error of ctx when it is done.
+func (cli *Client) DialAny(ctx context.Context, addrs ...string) (diam.Conn, error) {
+	if len(addrs) == 0 {
+		return nil, ErrNoAddress
+	}
+	if _, err := cli.validate(); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		c   diam.Conn
+		err error
+	}
+	results := make(chan result, len(addrs))
+	next, pending := 0, 0
+	dialNext := func() {
+		addr := addrs[next]
+		next++
+		pending++
+		go func() {
+			c, err := cli.DialContext(ctx, addr)
+			results <- result{c, err}
+		}()
+	}
+	dialNext()
+	var firstErr error
+	for pending > 0 {
+		var fallback <-chan time.Time
+		if cli.FallbackDelay > 0 && next < len(addrs) {
+			fallback = cli.Handler.clock().After(cli.FallbackDelay)
+		}
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil {
+				// Close the connections that lose the race.
+				go func(n int) {
+					for ; n > 0; n-- {
+						if r := <-results; r.c != nil {
+							r.c.Close()
+						}
+					}
+				}(pending)
+				return r.c, nil
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(addrs) && ctx.Err() == nil {
+				dialNext()
+			}
+		case <-fallback:
+			dialNext()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, firstErr
+}
+
+// DialTLS is like Dial, but using TLS, with the certificate of the
+// files and the TLSConfig of the client. The files are optional when
+// the TLSConfig has certificates.
 func (cli *Client) DialTLS(addr, certFile, keyFile string) (diam.Conn, error) {
-	return cli.dial(func() (diam.Conn, error) {
-		return diam.DialTLS(addr, certFile, keyFile, cli.Handler, cli.Dict)
+	return cli.DialTLSContext(context.Background(), addr, certFile, keyFile)
+}
+
+// DialTLSContext is like DialContext, but using TLS.
+func (cli *Client) DialTLSContext(ctx context.Context, addr, certFile, keyFile string) (diam.Conn, error) {
+	return cli.dial(ctx, func() (diam.Conn, error) {
+		return cli.server(addr).DialTLSContext(ctx, certFile, keyFile)
 	})
 }
 
+// DialDTLS is like Dial, but using DTLS over the transport of Network,
+// SCTP if unset. See diam.RegisterDTLS.
+func (cli *Client) DialDTLS(addr, certFile, keyFile string) (diam.Conn, error) {
+	return cli.dial(context.Background(), func() (diam.Conn, error) {
+		return cli.server(addr).DialDTLS(certFile, keyFile)
+	})
+}
+
+// server returns the diam.Server used to dial addr.
+func (cli *Client) server(addr string) *diam.Server {
+	return &diam.Server{
+		Addr:        addr,
+		Network:     cli.Network,
+		Dialer:      cli.Dialer,
+		LocalAddr:   cli.LocalAddr,
+		Handler:     cli.Handler,
+		Dict:        cli.Dict,
+		WriteLanes:  cli.WriteLanes,
+		FlowControl: cli.FlowControl,
+		Intercept:   cli.Intercept,
+		WrapConn:    cli.WrapConn,
+		TLSConfig:   cli.TLSConfig,
+	}
+}
+
 type dialFunc func() (diam.Conn, error)
 
-func (cli *Client) dial(f dialFunc) (diam.Conn, error) {
-	if err := cli.validate(); err != nil {
+func (cli *Client) dial(ctx context.Context, f dialFunc) (diam.Conn, error) {
+	local, err := cli.validate()
+	if err != nil {
 		return nil, err
 	}
 	c, err := f()
 	if err != nil {
 		return nil, err
 	}
-	return cli.handshake(c)
+	return cli.handshake(ctx, c, local)
 }
 
-func (cli *Client) validate() error {
+// validate checks the client configuration, and returns the IDs of
+// the applications supported by the client.
+func (cli *Client) validate() ([]uint32, error) {
 	if cli.Handler == nil {
-		return ErrMissingStateMachine
+		return nil, ErrMissingStateMachine
 	}
 	if cli.Dict == nil {
 		cli.Dict = dict.Default
@@ -106,68 +284,144 @@ func (cli *Client) validate() error {
 	// before sending a CER.
 	_, err := app.Parse(cli.Dict)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	if err = validateVendorApplications(cli.VendorSpecificApplicationID); err != nil {
+		return nil, err
+	}
+	if err = cli.Handler.Settings().Validate(); err != nil {
+		return nil, err
+	}
+	return app.ID(), nil
 }
 
-func (cli *Client) handshake(c diam.Conn) (diam.Conn, error) {
+func (cli *Client) handshake(ctx context.Context, c diam.Conn, local []uint32) (diam.Conn, error) {
 	ip, _, err := net.SplitHostPort(c.LocalAddr().String())
 	if err != nil {
 		return nil, err
 	}
-	m := cli.makeCER(net.ParseIP(ip))
+	m := cli.makeCER(c, net.ParseIP(ip))
 	// Ignore CER, but not DWR.
 	cli.Handler.mux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {})
-	// Handle CEA and DWA.
-	errc := make(chan error)
+	// Handle CEA and DWA of the connection. The CEA handler must not
+	// block when the handshake is given up before the CEA arrives.
+	errc := make(chan error, 1)
 	dwac := make(chan struct{})
-	cli.Handler.mux.Handle("CEA", handleCEA(cli.Handler, errc))
-	cli.Handler.mux.Handle("DWA", handshakeOK(handleDWA(cli.Handler, dwac)))
+	cli.Handler.addClientConn(c, &clientConn{cea: handleCEA(cli.Handler, m, local, errc), dwac: dwac})
+	cli.Handler.mux.HandleFunc("CEA", cli.Handler.handleClientCEA)
+	cli.Handler.mux.Handle("DWA", cli.Handler.handshakeOK(cli.Handler.handleClientDWA))
 	for i := 0; i < (int(cli.MaxRetransmits) + 1); i++ {
 		_, err := m.WriteTo(c)
 		if err != nil {
+			cli.Handler.removeClientConn(c)
 			return nil, err
 		}
 		select {
 		case err := <-errc: // Wait for CEA.
 			if err != nil {
 				close(errc)
+				cli.Handler.removeClientConn(c)
 				return nil, err
 			}
+			go func() {
+				<-c.Done()
+				cli.Handler.removeClientConn(c)
+			}()
 			if cli.EnableWatchdog {
 				go cli.watchdog(c, dwac)
 			}
 			return c, nil
-		case <-time.After(cli.RetransmitInterval):
+		case <-ctx.Done():
+			cli.Handler.removeClientConn(c)
+			cli.Handler.handshakeFailed(c, m, ctx.Err())
+			diam.CloseWithError(c, ctx.Err())
+			return nil, ctx.Err()
+		case <-cli.Handler.clock().After(cli.RetransmitInterval):
 		}
 	}
-	c.Close()
+	cli.Handler.removeClientConn(c)
+	cli.Handler.handshakeFailed(c, m, ErrHandshakeTimeout)
+	diam.CloseWithError(c, ErrHandshakeTimeout)
 	return nil, ErrHandshakeTimeout
 }
 
-func (cli *Client) makeCER(ip net.IP) *diam.Message {
+// clientConn is a connection dialed by a Client: the handler of its
+// CEA, until it is received, and the channel of the DWAs of its
+// watchdog. Connections dialed concurrently share the handlers of the
+// state machine, which dispatch the answers to their connection.
+type clientConn struct {
+	cea  diam.HandlerFunc
+	dwac chan struct{}
+}
+
+func (sm *StateMachine) addClientConn(c diam.Conn, cc *clientConn) {
+	sm.clientsMu.Lock()
+	if sm.clients == nil {
+		sm.clients = make(map[diam.Conn]*clientConn)
+	}
+	sm.clients[c] = cc
+	sm.clientsMu.Unlock()
+}
+
+func (sm *StateMachine) removeClientConn(c diam.Conn) {
+	sm.clientsMu.Lock()
+	delete(sm.clients, c)
+	sm.clientsMu.Unlock()
+}
+
+// handleClientCEA handles the first CEA of connections dialed by
+// Clients. Other CEAs are dropped.
+func (sm *StateMachine) handleClientCEA(c diam.Conn, m *diam.Message) {
+	var h diam.HandlerFunc
+	sm.clientsMu.Lock()
+	if cc, ok := sm.clients[c]; ok {
+		h, cc.cea = cc.cea, nil
+	}
+	sm.clientsMu.Unlock()
+	if h != nil {
+		h(c, m)
+	}
+}
+
+// handleClientDWA handles the DWAs of connections dialed by Clients.
+func (sm *StateMachine) handleClientDWA(c diam.Conn, m *diam.Message) {
+	sm.clientsMu.Lock()
+	cc, ok := sm.clients[c]
+	sm.clientsMu.Unlock()
+	if ok {
+		handleDWA(sm, cc.dwac)(c, m)
+	}
+}
+
+func (cli *Client) makeCER(c diam.Conn, ip net.IP) *diam.Message {
+	cfg := cli.Handler.settingsFor(c, "")
 	m := diam.NewRequest(diam.CapabilitiesExchange, 0, cli.Dict)
-	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cli.Handler.cfg.OriginHost)
-	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cli.Handler.cfg.OriginRealm)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
 	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, datatype.Address(ip))
-	m.NewAVP(avp.VendorID, avp.Mbit, 0, cli.Handler.cfg.VendorID)
-	m.NewAVP(avp.ProductName, 0, 0, cli.Handler.cfg.ProductName)
-	if cli.Handler.cfg.OriginStateID != 0 {
-		stateid := datatype.Unsigned32(cli.Handler.cfg.OriginStateID)
+	m.NewAVP(avp.VendorID, avp.Mbit, 0, cfg.VendorID)
+	m.NewAVP(avp.ProductName, 0, 0, cfg.ProductName)
+	if cfg.OriginStateID != 0 {
+		stateid := datatype.Unsigned32(cfg.OriginStateID)
 		m.NewAVP(avp.OriginStateID, avp.Mbit, 0, stateid)
 	}
 	if cli.SupportedVendorID != nil {
 		for _, a := range cli.SupportedVendorID {
 			m.AddAVP(a)
 		}
+	} else {
+		for _, id := range cfg.SupportedVendorID {
+			m.NewAVP(avp.SupportedVendorID, avp.Mbit, 0, id)
+		}
 	}
 	if cli.AuthApplicationID != nil {
 		for _, a := range cli.AuthApplicationID {
 			m.AddAVP(a)
 		}
 	}
-	m.NewAVP(avp.InbandSecurityID, avp.Mbit, 0, datatype.Unsigned32(0))
+	if !cli.OmitInbandSecurityID {
+		m.NewAVP(avp.InbandSecurityID, avp.Mbit, 0, cli.InbandSecurityID)
+	}
 	if cli.AcctApplicationID != nil {
 		for _, a := range cli.AcctApplicationID {
 			m.AddAVP(a)
@@ -178,27 +432,47 @@ func (cli *Client) makeCER(ip net.IP) *diam.Message {
 			m.AddAVP(a)
 		}
 	}
-	if cli.Handler.cfg.FirmwareRevision != 0 {
-		m.NewAVP(avp.FirmwareRevision, avp.Mbit, 0, cli.Handler.cfg.FirmwareRevision)
+	if cfg.FirmwareRevision != 0 {
+		m.NewAVP(avp.FirmwareRevision, 0, 0, cfg.FirmwareRevision)
 	}
 	return m
 }
 
 func (cli *Client) watchdog(c diam.Conn, dwac chan struct{}) {
-	disconnect := c.(diam.CloseNotifier).CloseNotify()
-	var osid uint32 = uint32(cli.Handler.cfg.OriginStateID)
+	disconnect := c.Done()
+	var osid uint32 = uint32(cli.Handler.SettingsFor(c).OriginStateID)
+	p, _ := cli.Handler.peer(c)
 	for {
+		interval := cli.WatchdogInterval
+		var changed chan struct{}
+		if p != nil {
+			interval = cli.watchdogInterval(p)
+			changed = p.twc
+		}
 		select {
 		case <-disconnect:
 			return
-		case <-time.After(cli.WatchdogInterval):
+		case <-changed:
+			// Reschedule with the new interval.
+		case <-cli.Handler.clock().After(interval):
 			cli.dwr(c, osid, dwac)
 		}
 	}
 }
 
+// watchdogInterval returns the interval between DWRs sent to the peer.
+func (cli *Client) watchdogInterval(p *Peer) time.Duration {
+	if d := p.WatchdogInterval(); d > 0 {
+		return d
+	}
+	if d := cli.WatchdogIntervals[p.Metadata.OriginHost]; d > 0 {
+		return d
+	}
+	return cli.WatchdogInterval
+}
+
 func (cli *Client) dwr(c diam.Conn, osid uint32, dwac chan struct{}) {
-	m := cli.makeDWR(osid)
+	m := cli.makeDWR(c, osid)
 	for i := 0; i < (int(cli.MaxRetransmits) + 1); i++ {
 		_, err := m.WriteTo(c)
 		if err != nil {
@@ -207,17 +481,25 @@ func (cli *Client) dwr(c diam.Conn, osid uint32, dwac chan struct{}) {
 		select {
 		case <-dwac:
 			return
-		case <-time.After(cli.RetransmitInterval):
+		case <-cli.Handler.clock().After(cli.RetransmitInterval):
 		}
 	}
 	// Watchdog failed, disconnect.
-	c.Close()
+	meta, _ := smpeer.FromContext(c.Context())
+	cli.Handler.events.Publish(&Event{
+		Type:    WatchdogTimeout,
+		Conn:    c,
+		Peer:    meta,
+		Message: m,
+	})
+	diam.CloseWithError(c, ErrWatchdogTimeout)
 }
 
-func (cli *Client) makeDWR(osid uint32) *diam.Message {
+func (cli *Client) makeDWR(c diam.Conn, osid uint32) *diam.Message {
+	cfg := cli.Handler.SettingsFor(c)
 	m := diam.NewRequest(diam.DeviceWatchdog, 0, cli.Dict)
-	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cli.Handler.cfg.OriginHost)
-	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cli.Handler.cfg.OriginRealm)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
 	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(osid))
 	return m
 }
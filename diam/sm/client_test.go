@@ -5,18 +5,23 @@
 package sm
 
 import (
+	"errors"
+	"net"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"golang.org/x/net/context"
+
 	"github.com/ibrohimislam/go-diameter/diam"
 	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/diamtest"
 	"github.com/ibrohimislam/go-diameter/diam/dict"
 	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
 )
 
 func TestClient_Dial_MissingStateMachine(t *testing.T) {
@@ -79,11 +84,7 @@ func TestClient_Handshake(t *testing.T) {
 			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
 		},
 		VendorSpecificApplicationID: []*diam.AVP{
-			diam.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
-				AVP: []*diam.AVP{
-					diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
-				},
-			}),
+			VendorAcctApplicationID(uint32(clientSettings.VendorID), 0),
 		},
 	}
 	c, err := cli.Dial(srv.Addr)
@@ -93,6 +94,30 @@ func TestClient_Handshake(t *testing.T) {
 	c.Close()
 }
 
+func TestClient_Network(t *testing.T) {
+	srv := diamtest.NewServer(New(serverSettings), dict.Default)
+	defer srv.Close()
+	if ip, _, _ := net.SplitHostPort(srv.Addr); net.ParseIP(ip).To4() == nil {
+		t.Skip("No IPv4 loopback")
+	}
+	cli := &Client{
+		Handler: New(clientSettings),
+		Network: "tcp6",
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	if _, err := cli.Dial(srv.Addr); err == nil {
+		t.Fatal("Unexpected nil error dialing an IPv4 address over tcp6")
+	}
+	cli.Network = "tcp4"
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
 func TestClient_Handshake_Notify(t *testing.T) {
 	srv := diamtest.NewServer(New(serverSettings), dict.Default)
 	defer srv.Close()
@@ -108,11 +133,7 @@ func TestClient_Handshake_Notify(t *testing.T) {
 			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
 		},
 		VendorSpecificApplicationID: []*diam.AVP{
-			diam.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
-				AVP: []*diam.AVP{
-					diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
-				},
-			}),
+			VendorAcctApplicationID(uint32(clientSettings.VendorID), 0),
 		},
 	}
 	handshakeOK := make(chan struct{})
@@ -190,6 +211,109 @@ func TestClient_Handshake_FailedResultCode(t *testing.T) {
 	}
 }
 
+func TestClient_DialContext(t *testing.T) {
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+		// Do nothing to force timeout.
+	})
+	srv := diamtest.NewServer(mux, dict.Default)
+	defer srv.Close()
+	cli := &Client{
+		Handler:            New(clientSettings),
+		RetransmitInterval: time.Hour,
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	events := cli.Handler.Events().Subscribe(1)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := cli.DialContext(ctx, srv.Addr); err != context.DeadlineExceeded {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.DeadlineExceeded, err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("DialContext took %s, past the deadline", d)
+	}
+	if e := waitEvent(t, events, HandshakeFailed); e.Error != context.DeadlineExceeded {
+		t.Fatalf("Unexpected HandshakeFailed error: %v", e.Error)
+	}
+	// Canceled before connecting.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	if _, err := cli.DialContext(ctx, srv.Addr); err != context.Canceled {
+		t.Fatalf("Unexpected error. Want %v, have %v", context.Canceled, err)
+	}
+}
+
+func TestClient_DialAny(t *testing.T) {
+	srv := diamtest.NewServer(New(serverSettings), dict.Default)
+	defer srv.Close()
+	// A server that never answers the CER.
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {})
+	silent := diamtest.NewServer(mux, dict.Default)
+	defer silent.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+	cli := &Client{
+		Handler:            New(clientSettings),
+		RetransmitInterval: time.Hour,
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	if _, err = cli.DialAny(context.Background()); err != ErrNoAddress {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrNoAddress, err)
+	}
+	// Addresses are tried in order.
+	c, err := cli.DialAny(context.Background(), closed, srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := c.RemoteAddr().String(); a != srv.Addr {
+		t.Fatalf("Unexpected address. Want %s, have %s", srv.Addr, a)
+	}
+	c.Close()
+	if _, err = cli.DialAny(context.Background(), closed); err == nil {
+		t.Fatal("Unexpected nil error dialing a closed port")
+	}
+	// Slow addresses are raced with the next ones.
+	cli.FallbackDelay = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if c, err = cli.DialAny(ctx, silent.Addr, srv.Addr); err != nil {
+		t.Fatal(err)
+	}
+	if a := c.RemoteAddr().String(); a != srv.Addr {
+		t.Fatalf("Unexpected address. Want %s, have %s", srv.Addr, a)
+	}
+	c.Close()
+	// Concurrent handshakes get the CEA of their own connection.
+	errc := make(chan error, 4)
+	for i := 0; i < cap(errc); i++ {
+		go func() {
+			c, err := cli.DialContext(ctx, srv.Addr)
+			if err == nil {
+				defer c.Close()
+				if _, ok := smpeer.FromContext(c.Context()); !ok {
+					err = errors.New("handshake completed without metadata")
+				}
+			}
+			errc <- err
+		}()
+	}
+	for i := 0; i < cap(errc); i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
 func TestClient_Handshake_RetransmitTimeout(t *testing.T) {
 	mux := diam.NewServeMux()
 	var retransmits uint32
@@ -247,6 +371,35 @@ func TestClient_Watchdog(t *testing.T) {
 	}
 }
 
+func TestClient_WriteLanes(t *testing.T) {
+	srv := diamtest.NewServer(New(serverSettings), dict.Default)
+	defer srv.Close()
+	cli := &Client{
+		EnableWatchdog:   true,
+		WatchdogInterval: 100 * time.Millisecond,
+		Handler:          New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+		WriteLanes: &diam.WriteLanes{},
+	}
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	resp := make(chan struct{}, 1)
+	dwa := handleDWA(cli.Handler, resp)
+	cli.Handler.mux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		dwa(c, m)
+	})
+	select {
+	case <-resp:
+	case <-time.After(200 * time.Millisecond):
+		t.Fatal("Timeout waiting for DWA")
+	}
+}
+
 func TestClient_Watchdog_Timeout(t *testing.T) {
 	sm := New(serverSettings)
 	var once sync.Once
@@ -276,3 +429,152 @@ func TestClient_Watchdog_Timeout(t *testing.T) {
 		t.Fatal("Timeout waiting for watchdog to disconnect client")
 	}
 }
+
+func TestClient_Watchdog_FakeClock(t *testing.T) {
+	sm := New(serverSettings)
+	sm.mux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {})
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	clock := diamtest.NewFakeClock(time.Unix(0, 0))
+	cli := &Client{
+		MaxRetransmits:     2,
+		RetransmitInterval: 10 * time.Second,
+		EnableWatchdog:     true,
+		WatchdogInterval:   30 * time.Second,
+		Handler:            New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.UseClock(clock)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	// The retransmission timer of the CER and the watchdog timer.
+	clock.BlockUntil(2)
+	clock.Advance(30 * time.Second)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-c.Done():
+			t.Fatalf("Unexpected disconnect after %d DWR", i)
+		default:
+		}
+		clock.BlockUntil(1)
+		clock.Advance(10 * time.Second)
+	}
+	select {
+	case <-c.Done():
+		if c.Err() != ErrWatchdogTimeout {
+			t.Fatalf("Unexpected error. Want %v, have %v", ErrWatchdogTimeout, c.Err())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for watchdog to disconnect client")
+	}
+}
+
+func TestClient_InbandSecurity(t *testing.T) {
+	settings := *serverSettings
+	settings.InbandSecurity = []datatype.Unsigned32{smparser.TLSInbandSecurity}
+	srv := diamtest.NewServer(New(&settings), dict.Default)
+	defer srv.Close()
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	c, err := cli.Dial(srv.Addr)
+	if err == nil {
+		c.Close()
+		t.Fatal("Unexpected handshake without in-band security")
+	}
+	if e, ok := err.(*ErrFailedResultCode); !ok || e.Code != diam.NoCommonSecurity {
+		t.Fatal("Unexpected error:", err)
+	}
+	cli.InbandSecurityID = smparser.TLSInbandSecurity
+	c, err = cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+}
+
+func TestClient_OmitInbandSecurityID(t *testing.T) {
+	cli := &Client{
+		Handler:              New(clientSettings),
+		OmitInbandSecurityID: true,
+	}
+	m := cli.makeCER(nil, net.ParseIP("127.0.0.1"))
+	for _, a := range m.AVP {
+		if a.Code == avp.InbandSecurityID {
+			t.Fatal("Unexpected Inband-Security-Id in CER")
+		}
+	}
+}
+
+func TestClient_WatchdogIntervals(t *testing.T) {
+	sm := New(serverSettings)
+	dwrc := make(chan *diam.Message, 1)
+	dwr := handleDWR(sm)
+	sm.mux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		dwrc <- m
+		dwr(c, m)
+	})
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	clock := diamtest.NewFakeClock(time.Unix(0, 0))
+	cli := &Client{
+		RetransmitInterval: time.Hour,
+		EnableWatchdog:     true,
+		WatchdogInterval:   30 * time.Second,
+		WatchdogIntervals: map[datatype.DiameterIdentity]time.Duration{
+			serverSettings.OriginHost: 10 * time.Second,
+		},
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.UseClock(clock)
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitDWR := func(want bool) {
+		timeout := time.Second
+		if !want {
+			timeout = 50 * time.Millisecond
+		}
+		select {
+		case <-dwrc:
+			if !want {
+				t.Fatal("Unexpected DWR")
+			}
+		case <-time.After(timeout):
+			if want {
+				t.Fatal("No DWR received")
+			}
+		}
+	}
+	// The retransmission timer of the CER and the watchdog timer.
+	clock.BlockUntil(2)
+	clock.Advance(10 * time.Second)
+	waitDWR(true)
+	// The retransmission timers of the CER and the DWR are left
+	// behind, with the next watchdog timer.
+	clock.BlockUntil(3)
+	peers := cli.Handler.Peers()
+	if len(peers) != 1 {
+		t.Fatalf("Unexpected # of peers: %d", len(peers))
+	}
+	peers[0].SetWatchdogInterval(time.Minute)
+	// The watchdog timer of the previous interval is left behind.
+	clock.BlockUntil(4)
+	clock.Advance(30 * time.Second)
+	waitDWR(false)
+	clock.Advance(30 * time.Second)
+	waitDWR(true)
+}
@@ -0,0 +1,69 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+// Config is a read-only snapshot of the effective configuration of a
+// state machine, as exported by StateMachine.ExportConfig. It is meant
+// for admin interfaces and debugging endpoints.
+type Config struct {
+	// Settings are the current Settings, see Reload. Connections
+	// may use others, see ResolveSettings.
+	Settings *Settings `json:"settings"`
+
+	// Applications are the applications supported by servers. When
+	// empty, all the applications in the dictionary are supported.
+	Applications []uint32 `json:"applications,omitempty"`
+
+	// Relay is true if the state machine is a relay agent, see
+	// smparser.RelayApplicationID.
+	Relay bool `json:"relay"`
+
+	// Routes are the handlers registered in the state machine,
+	// including the ones of the state machine itself, like CER.
+	Routes []diam.Route `json:"routes"`
+
+	// Default is true if a default handler is registered, see
+	// HandleDefault.
+	Default bool `json:"default"`
+
+	// Tables are the current peer and routing tables, see
+	// ReloadTables.
+	Tables *Tables `json:"tables"`
+}
+
+// Config returns a snapshot of the effective configuration of the state
+// machine. The Settings and Tables must not be modified, see Reload
+// and ReloadTables.
+func (sm *StateMachine) Config() *Config {
+	cfg := sm.Settings()
+	apps := make([]uint32, len(cfg.Applications))
+	copy(apps, cfg.Applications)
+	return &Config{
+		Settings:     cfg,
+		Applications: apps,
+		Relay:        isRelay(cfg.Applications),
+		Routes:       sm.mux.Routes(),
+		Default:      sm.mux.HasDefault(),
+		Tables:       sm.Tables(),
+	}
+}
+
+// ExportConfig writes the snapshot of the effective configuration of
+// the state machine to w, as a JSON object. See Config.
+func (sm *StateMachine) ExportConfig(w io.Writer) error {
+	b, err := json.MarshalIndent(sm.Config(), "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
@@ -0,0 +1,49 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+)
+
+func TestStateMachine_Config(t *testing.T) {
+	sm := New(serverSettings)
+	sm.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {})
+	sm.HandleAppFunc(4, "RAA", func(c diam.Conn, m *diam.Message) {})
+	cfg := sm.Config()
+	if cfg.Settings != serverSettings || cfg.Default || cfg.Relay {
+		t.Fatalf("Unexpected config: %#v", cfg)
+	}
+	want := []diam.Route{
+		{Command: "CCR", AllApps: true},
+		{Command: "CER", AllApps: true},
+		{Command: "DPA", AllApps: true},
+		{Command: "DWR", AllApps: true},
+		{Command: "RAA", ApplicationID: 4},
+	}
+	if len(cfg.Routes) != len(want) {
+		t.Fatalf("Unexpected routes. Want %v, have %v", want, cfg.Routes)
+	}
+	for i, r := range want {
+		if cfg.Routes[i] != r {
+			t.Fatalf("Unexpected route %d. Want %v, have %v", i, r, cfg.Routes[i])
+		}
+	}
+	var b bytes.Buffer
+	if err := sm.ExportConfig(&b); err != nil {
+		t.Fatal(err)
+	}
+	var have Config
+	if err := json.Unmarshal(b.Bytes(), &have); err != nil {
+		t.Fatal(err)
+	}
+	if have.Settings.OriginHost != serverSettings.OriginHost || len(have.Routes) != len(want) {
+		t.Fatalf("Unexpected exported config: %s", b.String())
+	}
+}
@@ -7,4 +7,13 @@
 // It currently handles CER/CEA handshakes, and automatic DWR/DWA. Peers
 // that pass the handshake get metadata associated to their connection.
 // See the peer sub-package for details on the metadata.
+//
+// Changes in the state of peers are published as events, see EventBus.
+//
+// Servers keep a table of the peers that passed the handshake, and can
+// gracefully disconnect them with DPR/DPA, see Server.DrainPeer.
+//
+// The settings, and the peer and routing tables of the state machine,
+// are reloaded at runtime without dropping established peers, see
+// StateMachine.Reload and StateMachine.ReloadTables.
 package sm
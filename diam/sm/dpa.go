@@ -0,0 +1,36 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
+)
+
+// handleDPA handles Disconnect-Peer-Answer messages, sent by peers
+// in response to the DPR sent by DrainPeer.
+func handleDPA(sm *StateMachine) diam.HandlerFunc {
+	return func(c diam.Conn, m *diam.Message) {
+		dpa := new(smparser.DPA)
+		if err := dpa.Parse(m); err != nil {
+			sm.Error(&diam.ErrorReport{
+				Conn:    c,
+				Message: m,
+				Error:   err,
+			})
+		}
+		p, ok := sm.peer(c)
+		if !ok {
+			return
+		}
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		select {
+		case <-p.dpac:
+		default:
+			close(p.dpac)
+		}
+	}
+}
@@ -26,6 +26,7 @@ func handleDWA(sm *StateMachine, dwac chan struct{}) diam.HandlerFunc {
 		if dwa.ResultCode != diam.Success {
 			return
 		}
+		sm.checkOriginState(c, m, dwa.OriginStateID)
 		select {
 		case dwac <- dwaACK:
 		default:
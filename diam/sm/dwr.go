@@ -29,12 +29,18 @@ func handleDWR(sm *StateMachine) diam.HandlerFunc {
 			})
 			return
 		}
+		if dwr.OriginStateID != nil {
+			if v, ok := dwr.OriginStateID.Data.(datatype.Unsigned32); ok {
+				sm.checkOriginState(c, m, uint32(v))
+			}
+		}
+		cfg := sm.SettingsFor(c)
 		a := m.Answer(diam.Success)
-		a.NewAVP(avp.OriginHost, avp.Mbit, 0, sm.cfg.OriginHost)
-		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, sm.cfg.OriginRealm)
-		if sm.cfg.OriginStateID != 0 {
-			stateid := datatype.Unsigned32(sm.cfg.OriginStateID)
-			m.NewAVP(avp.OriginStateID, avp.Mbit, 0, stateid)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
+		if cfg.OriginStateID != 0 {
+			stateid := datatype.Unsigned32(cfg.OriginStateID)
+			a.NewAVP(avp.OriginStateID, avp.Mbit, 0, stateid)
 		}
 		_, err = a.WriteTo(c)
 		if err != nil {
@@ -46,3 +52,19 @@ func handleDWR(sm *StateMachine) diam.HandlerFunc {
 		}
 	}
 }
+
+// checkOriginState publishes a PeerRestarted event when the
+// Origin-State-Id osid received in the DWR or DWA m differs from the
+// one previously received from the peer of the connection c.
+func (sm *StateMachine) checkOriginState(c diam.Conn, m *diam.Message, osid uint32) {
+	p, ok := sm.peer(c)
+	if !ok || !p.restarted(osid) {
+		return
+	}
+	sm.events.Publish(&Event{
+		Type:    PeerRestarted,
+		Conn:    c,
+		Peer:    p.Metadata,
+		Message: m,
+	})
+}
@@ -138,3 +138,60 @@ func TestHandleDWR_Fail(t *testing.T) {
 		t.Fatal("No DWA received")
 	}
 }
+
+func TestHandleDWR_PeerRestarted(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	mc := make(chan *diam.Message, 1)
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CEA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	mux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, mux, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CapabilitiesExchange, 1001, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+	m.NewAVP(avp.HostIPAddress, avp.Mbit, 0, localhostAddress)
+	m.NewAVP(avp.VendorID, avp.Mbit, 0, clientSettings.VendorID)
+	m.NewAVP(avp.ProductName, 0, 0, clientSettings.ProductName)
+	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
+	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	<-mc
+	waitEvent(t, events, PeerUp)
+	// The same Origin-State-Id of the CER, then a new one.
+	for _, osid := range []datatype.Unsigned32{1, 2} {
+		m = diam.NewRequest(diam.DeviceWatchdog, 0, dict.Default)
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+		m.NewAVP(avp.OriginStateID, avp.Mbit, 0, osid)
+		if _, err = m.WriteTo(cli); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-mc:
+		case <-time.After(time.Second):
+			t.Fatal("No DWA received")
+		}
+	}
+	e := waitEvent(t, events, PeerRestarted)
+	if e.Peer == nil || e.Peer.OriginHost != clientSettings.OriginHost {
+		t.Fatalf("Unexpected peer metadata: %#v", e.Peer)
+	}
+	select {
+	case e = <-events:
+		t.Fatalf("Unexpected event: %s", e.Type)
+	default:
+	}
+}
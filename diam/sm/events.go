@@ -0,0 +1,119 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+// EventType is the type of an Event published by the state machine.
+type EventType int
+
+// Event types.
+const (
+	// PeerUp is published when a peer passes the CER/CEA handshake.
+	PeerUp EventType = iota
+
+	// PeerDown is published when the connection of a peer that
+	// passed the handshake is closed.
+	PeerDown
+
+	// HandshakeFailed is published when the CER/CEA handshake fails.
+	HandshakeFailed
+
+	// WatchdogTimeout is published when a peer does not answer
+	// DWR and the connection is closed.
+	WatchdogTimeout
+
+	// MessageDropped is published when a message is discarded
+	// because the peer has not passed the handshake.
+	MessageDropped
+
+	// StoreFailed is published when the metadata of a peer could
+	// not be saved to the store, see StateMachine.UseStore.
+	StoreFailed
+
+	// PeerRestarted is published when the Origin-State-Id in a DWR
+	// or DWA differs from the one previously received from the peer,
+	// meaning the peer restarted and lost the state of its sessions.
+	PeerRestarted
+)
+
+var eventNames = map[EventType]string{
+	PeerUp:          "PeerUp",
+	PeerDown:        "PeerDown",
+	HandshakeFailed: "HandshakeFailed",
+	WatchdogTimeout: "WatchdogTimeout",
+	MessageDropped:  "MessageDropped",
+	StoreFailed:     "StoreFailed",
+	PeerRestarted:   "PeerRestarted",
+}
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	if name, ok := eventNames[t]; ok {
+		return name
+	}
+	return "Unknown"
+}
+
+// Event is published by the state machine when the state of a peer
+// changes or a message is dropped.
+type Event struct {
+	Type    EventType
+	Conn    diam.Conn        // Connection of the peer
+	Peer    *smpeer.Metadata // Peer metadata, nil before the handshake
+	Message *diam.Message    // Message that caused the event, if any
+	Error   error            // Error that caused the event, if any
+}
+
+// EventBus delivers state machine events to multiple subscribers.
+//
+// Events are delivered without blocking the state machine. Events are
+// discarded for subscribers whose channel buffer is full.
+type EventBus struct {
+	mu   sync.RWMutex
+	subs map[<-chan *Event]chan *Event
+}
+
+// NewEventBus creates and initializes a new EventBus.
+func NewEventBus() *EventBus {
+	return &EventBus{subs: make(map[<-chan *Event]chan *Event)}
+}
+
+// Subscribe returns a channel that receives all events published
+// from now on. The channel buffers up to size events.
+func (b *EventBus) Subscribe(size int) <-chan *Event {
+	c := make(chan *Event, size)
+	b.mu.Lock()
+	b.subs[c] = c
+	b.mu.Unlock()
+	return c
+}
+
+// Unsubscribe stops delivering events to the channel c, and closes it.
+func (b *EventBus) Unsubscribe(c <-chan *Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if sc, ok := b.subs[c]; ok {
+		delete(b.subs, c)
+		close(sc)
+	}
+}
+
+// Publish delivers the event to all subscribers.
+func (b *EventBus) Publish(e *Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, c := range b.subs {
+		select {
+		case c <- e:
+		default:
+		}
+	}
+}
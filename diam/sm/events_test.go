@@ -0,0 +1,120 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestEventBus(t *testing.T) {
+	b := NewEventBus()
+	c1 := b.Subscribe(1)
+	c2 := b.Subscribe(1)
+	b.Publish(&Event{Type: PeerUp})
+	b.Publish(&Event{Type: PeerDown}) // Discarded, buffers are full.
+	for _, c := range []<-chan *Event{c1, c2} {
+		if e := <-c; e.Type != PeerUp {
+			t.Fatalf("Unexpected event. Want PeerUp, have %s", e.Type)
+		}
+	}
+	b.Unsubscribe(c1)
+	if _, ok := <-c1; ok {
+		t.Fatal("Channel was not closed by Unsubscribe")
+	}
+	b.Publish(&Event{Type: MessageDropped})
+	if e := <-c2; e.Type != MessageDropped {
+		t.Fatalf("Unexpected event. Want MessageDropped, have %s", e.Type)
+	}
+}
+
+func waitEvent(t *testing.T, c <-chan *Event, want EventType) *Event {
+	select {
+	case e := <-c:
+		if e.Type != want {
+			t.Fatalf("Unexpected event. Want %s, have %s", want, e.Type)
+		}
+		return e
+	case <-time.After(time.Second):
+		t.Fatalf("Timed out waiting for %s", want)
+	}
+	return nil
+}
+
+func TestStateMachine_Events(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := waitEvent(t, events, PeerUp)
+	if e.Peer == nil || e.Peer.OriginHost != clientSettings.OriginHost {
+		t.Fatalf("Unexpected peer metadata: %#v", e.Peer)
+	}
+	c.Close()
+	if e = waitEvent(t, events, PeerDown); e.Error != io.EOF {
+		t.Fatalf("Unexpected PeerDown error. Want %v, have %v", io.EOF, e.Error)
+	}
+}
+
+func TestStateMachine_Events_HandshakeFailed(t *testing.T) {
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CER", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c) // Missing mandatory AVPs.
+	})
+	srv := diamtest.NewServer(mux, dict.Default)
+	defer srv.Close()
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	events := cli.Handler.Events().Subscribe(10)
+	if _, err := cli.Dial(srv.Addr); err == nil {
+		t.Fatal("Unexpected handshake succeeded")
+	}
+	if e := waitEvent(t, events, HandshakeFailed); e.Error == nil {
+		t.Fatal("HandshakeFailed event has no error")
+	}
+}
+
+func TestStateMachine_Events_MessageDropped(t *testing.T) {
+	sm := New(serverSettings)
+	sm.HandleFunc("RAR", func(c diam.Conn, m *diam.Message) {})
+	events := sm.Events().Subscribe(10)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	c, err := diam.Dial(srv.Addr, nil, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	m := diam.NewRequest(diam.ReAuth, 0, dict.Default)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("foobar"))
+	if _, err = m.WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	e := waitEvent(t, events, MessageDropped)
+	if e.Message == nil || e.Message.Header.CommandCode != diam.ReAuth {
+		t.Fatalf("Unexpected message in event: %v", e.Message)
+	}
+}
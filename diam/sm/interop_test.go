@@ -0,0 +1,290 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+//go:build interop
+// +build interop
+
+// Interoperability tests against freeDiameter, running in Docker.
+//
+// The tests are only built with the interop build tag, and require
+// the docker command with access to a Docker daemon on Linux, as the
+// freeDiameter container uses the host network:
+//
+//	go test -tags interop -v -run Interop ./diam/sm
+//
+// The freeDiameter image is built from testdata/freediameter. The Go
+// client connects to freeDiameter on 127.0.0.1:13868, and freeDiameter
+// connects to the Go server on 127.0.0.1:13869 to relay Credit-Control
+// requests to it.
+
+package sm
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+const (
+	interopImage    = "go-diameter-freediameter"
+	interopFDAddr   = "127.0.0.1:13868"
+	interopPeerAddr = "127.0.0.1:13869"
+	interopFDHost   = "fd.example.com"
+	interopSrvHost  = "go-server.example.com"
+)
+
+var (
+	// interopErr is set when the freeDiameter container is not
+	// available, and the interop tests are skipped.
+	interopErr error
+
+	interopServerSettings = &Settings{
+		OriginHost:    interopSrvHost,
+		OriginRealm:   "example.com",
+		VendorID:      13,
+		ProductName:   "go-diameter",
+		OriginStateID: datatype.Unsigned32(time.Now().Unix()),
+		Applications:  []uint32{4},
+	}
+
+	interopClientSettings = &Settings{
+		OriginHost:    "go-client.example.com",
+		OriginRealm:   "example.com",
+		VendorID:      13,
+		ProductName:   "go-diameter",
+		OriginStateID: datatype.Unsigned32(time.Now().Unix()),
+	}
+)
+
+func TestMain(m *testing.M) {
+	l, err := net.Listen("tcp", interopPeerAddr)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "interop: server:", err)
+		os.Exit(1)
+	}
+	srv := &Server{Handler: interopServer()}
+	go srv.Serve(l)
+	id, err := startFreeDiameter()
+	if err != nil {
+		interopErr = err
+	}
+	code := m.Run()
+	if id != "" {
+		exec.Command("docker", "rm", "-f", id).Run()
+	}
+	l.Close()
+	os.Exit(code)
+}
+
+// startFreeDiameter builds the freeDiameter image and starts a container,
+// returning its id once freeDiameter accepts connections.
+func startFreeDiameter() (string, error) {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return "", err
+	}
+	build := exec.Command("docker", "build", "-t", interopImage, "testdata/freediameter")
+	if out, err := build.CombinedOutput(); err != nil {
+		return "", fmt.Errorf("docker build: %v\n%s", err, out)
+	}
+	_, fdPort, _ := net.SplitHostPort(interopFDAddr)
+	_, peerPort, _ := net.SplitHostPort(interopPeerAddr)
+	out, err := exec.Command("docker", "run", "-d", "--rm",
+		"--network", "host",
+		"-e", "FD_PORT="+fdPort,
+		"-e", "GO_PEER_PORT="+peerPort,
+		interopImage,
+	).Output()
+	if err != nil {
+		return "", fmt.Errorf("docker run: %v", err)
+	}
+	id := strings.TrimSpace(string(out))
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		c, err := net.DialTimeout("tcp", interopFDAddr, time.Second)
+		if err == nil {
+			c.Close()
+			return id, nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return id, fmt.Errorf("freeDiameter did not start on %s", interopFDAddr)
+}
+
+// interopServer returns the state machine of the Go server, which
+// answers Credit-Control requests relayed by freeDiameter.
+func interopServer() *StateMachine {
+	sm := New(interopServerSettings)
+	sm.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		a := m.Answer(diam.Success)
+		for _, code := range []uint32{
+			avp.SessionID,
+			avp.AuthApplicationID,
+			avp.CCRequestType,
+			avp.CCRequestNumber,
+		} {
+			if v, err := m.FindAVP(code, 0); err == nil {
+				a.InsertAVP(v)
+			}
+		}
+		a.WriteTo(c)
+	})
+	return sm
+}
+
+// interopDial connects a Go client to freeDiameter.
+func interopDial(t *testing.T, cli *Client) diam.Conn {
+	if interopErr != nil {
+		t.Skip("freeDiameter is not available:", interopErr)
+	}
+	if cli.Handler == nil {
+		cli.Handler = New(interopClientSettings)
+	}
+	cli.AuthApplicationID = []*diam.AVP{
+		diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4)),
+	}
+	c, err := cli.Dial(interopFDAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestInterop_Handshake(t *testing.T) {
+	cli := &Client{}
+	c := interopDial(t, cli)
+	defer c.Close()
+	peers := cli.Handler.Peers()
+	if len(peers) != 1 {
+		t.Fatalf("Unexpected number of peers. Want 1, have %d", len(peers))
+	}
+	if host := peers[0].Metadata.OriginHost; host != interopFDHost {
+		t.Fatalf("Unexpected Origin-Host. Want %q, have %q", interopFDHost, host)
+	}
+}
+
+func TestInterop_Watchdog(t *testing.T) {
+	cli := &Client{
+		EnableWatchdog:   true,
+		WatchdogInterval: time.Second,
+	}
+	c := interopDial(t, cli)
+	defer c.Close()
+	dwac := make(chan struct{}, 1)
+	cli.Handler.mux.HandleFunc("DWA", handleDWA(cli.Handler, dwac))
+	select {
+	case <-dwac:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for DWA")
+	}
+	// freeDiameter sends its own DWR after TwTimer (6s), which must
+	// be answered by the state machine to keep the connection.
+	select {
+	case <-c.(diam.CloseNotifier).CloseNotify():
+		t.Fatal("Unexpected disconnection by freeDiameter")
+	case <-time.After(10 * time.Second):
+	}
+}
+
+func TestInterop_DisconnectPeer(t *testing.T) {
+	cli := &Client{}
+	c := interopDial(t, cli)
+	defer c.Close()
+	dpac := make(chan *diam.Message, 1)
+	cli.Handler.mux.HandleFunc("DPA", func(c diam.Conn, m *diam.Message) {
+		dpac <- m
+	})
+	cfg := cli.Handler.Settings()
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, DoNotWantToTalkToYou)
+	if _, err := m.WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case dpa := <-dpac:
+		rc, err := dpa.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if code := rc.Data.(datatype.Unsigned32); code != diam.Success {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timeout waiting for DPA")
+	}
+	select {
+	case <-c.(diam.CloseNotifier).CloseNotify():
+	case <-time.After(10 * time.Second):
+		t.Fatal("Timeout waiting for freeDiameter to disconnect")
+	}
+}
+
+func TestInterop_CreditControl(t *testing.T) {
+	cli := &Client{}
+	ccac := make(chan *diam.Message, 1)
+	cli.Handler = New(interopClientSettings)
+	cli.Handler.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		ccac <- m
+	})
+	c := interopDial(t, cli)
+	defer c.Close()
+	cfg := cli.Handler.Settings()
+	ccr := func() *diam.Message {
+		m := diam.NewRequest(diam.CreditControl, 4, dict.Default)
+		m.Header.CommandFlags |= diam.ProxiableFlag
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String(string(cfg.OriginHost)+";1;1"))
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
+		m.NewAVP(avp.DestinationRealm, avp.Mbit, 0, datatype.DiameterIdentity("example.com"))
+		m.NewAVP(avp.DestinationHost, avp.Mbit, 0, datatype.DiameterIdentity(interopSrvHost))
+		m.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4))
+		m.NewAVP(avp.ServiceContextID, avp.Mbit, 0, datatype.UTF8String("32251@3gpp.org"))
+		m.NewAVP(avp.CCRequestType, avp.Mbit, 0, datatype.Enumerated(1))
+		m.NewAVP(avp.CCRequestNumber, avp.Mbit, 0, datatype.Unsigned32(0))
+		return m
+	}
+	// freeDiameter connects to the Go server in background, retry
+	// until it routes the request.
+	deadline := time.After(30 * time.Second)
+	for {
+		if _, err := ccr().WriteTo(c); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case cca := <-ccac:
+			rc, err := cca.FindAVP(avp.ResultCode, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := rc.Data.(datatype.Unsigned32)
+			if code == diam.UnableToDeliver {
+				time.Sleep(time.Second)
+				continue
+			}
+			if code != diam.Success {
+				t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.Success, code)
+			}
+			oh, err := cca.FindAVP(avp.OriginHost, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if host := oh.Data.(datatype.DiameterIdentity); host != interopSrvHost {
+				t.Fatalf("Unexpected Origin-Host. Want %q, have %q", interopSrvHost, host)
+			}
+			return
+		case <-deadline:
+			t.Fatal("Timeout waiting for CCA")
+		}
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+// ErrPeerDraining is returned by Peer.Send when the peer is being
+// drained and no longer accepts new requests.
+var ErrPeerDraining = errors.New("peer is draining")
+
+// Peer is a connection that passed the CER/CEA handshake.
+type Peer struct {
+	Conn     diam.Conn
+	Metadata *smpeer.Metadata
+	Limits   *PeerLimits // Limits set by the Authenticator, or nil
+
+	mu       sync.Mutex
+	draining bool
+	sent     map[uint32]struct{} // hop-by-hop ids of unanswered requests
+	serving  int                 // requests from the peer being handled
+	changed  chan struct{}       // signals a change in outstanding requests
+	dpac     chan struct{}       // closed when DPA is received
+	tokens   float64             // MaxTPS bucket, see take
+	last     time.Time           // last refill of the bucket
+	osid     uint32              // last Origin-State-Id of the peer
+	tw       time.Duration       // watchdog interval, see SetWatchdogInterval
+	twc      chan struct{}       // signals changes of tw
+}
+
+func newPeer(c diam.Conn, meta *smpeer.Metadata, limits *PeerLimits) *Peer {
+	p := &Peer{
+		Conn:     c,
+		Metadata: meta,
+		Limits:   limits,
+		sent:     make(map[uint32]struct{}),
+		changed:  make(chan struct{}, 1),
+		dpac:     make(chan struct{}),
+		twc:      make(chan struct{}, 1),
+	}
+	if meta != nil && meta.Capabilities != nil && meta.Capabilities.Remote != nil {
+		p.osid = meta.Capabilities.Remote.OriginStateID
+	}
+	return p
+}
+
+// restarted records the Origin-State-Id osid received from the peer,
+// and returns true if it differs from the previous one, meaning the
+// peer restarted. Zero values, from peers that do not send the AVP,
+// are ignored.
+func (p *Peer) restarted(osid uint32) bool {
+	if osid == 0 {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	prev := p.osid
+	p.osid = osid
+	return prev != 0 && prev != osid
+}
+
+// Send writes the message m to the peer. Requests are tracked until
+// their answer is received, see Outstanding.
+//
+// Requests are not sent after the peer starts draining.
+func (p *Peer) Send(m *diam.Message) (int64, error) {
+	isReq := m.Header.CommandFlags&diam.RequestFlag == diam.RequestFlag
+	if isReq {
+		p.mu.Lock()
+		if p.draining {
+			p.mu.Unlock()
+			return 0, ErrPeerDraining
+		}
+		p.sent[m.Header.HopByHopID] = struct{}{}
+		p.mu.Unlock()
+	}
+	n, err := m.WriteTo(p.Conn)
+	if err != nil && isReq {
+		p.answered(m.Header.HopByHopID)
+	}
+	return n, err
+}
+
+// WatchdogInterval returns the interval between the DWRs sent to the
+// peer set with SetWatchdogInterval, or zero if unset.
+func (p *Peer) WatchdogInterval() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tw
+}
+
+// SetWatchdogInterval sets the interval between the DWRs sent to the
+// peer by Clients with EnableWatchdog, overriding the intervals of the
+// Client, e.g. when an interconnect partner mandates its own cadence.
+// The next DWR is rescheduled with the new interval. Zero restores the
+// interval of the Client.
+func (p *Peer) SetWatchdogInterval(d time.Duration) {
+	p.mu.Lock()
+	p.tw = d
+	p.mu.Unlock()
+	select {
+	case p.twc <- struct{}{}:
+	default:
+	}
+}
+
+// Draining returns true if the peer is being drained.
+func (p *Peer) Draining() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.draining
+}
+
+// Outstanding returns the number of requests sent to the peer that
+// have not been answered, plus the number of requests from the peer
+// still being handled. Only the requests sent with Send are counted,
+// not the ones written directly to the connection.
+func (p *Peer) Outstanding() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sent) + p.serving
+}
+
+func (p *Peer) drain() {
+	p.mu.Lock()
+	p.draining = true
+	p.mu.Unlock()
+}
+
+func (p *Peer) answered(hopByHopID uint32) {
+	p.mu.Lock()
+	delete(p.sent, hopByHopID)
+	p.mu.Unlock()
+	p.notify()
+}
+
+func (p *Peer) serve(done bool) {
+	p.mu.Lock()
+	if done {
+		p.serving--
+	} else {
+		p.serving++
+	}
+	p.mu.Unlock()
+	if done {
+		p.notify()
+	}
+}
+
+func (p *Peer) notify() {
+	select {
+	case p.changed <- struct{}{}:
+	default:
+	}
+}
+
+// addPeer adds the connection c to the peer table, and removes it
+// when the connection is closed.
+func (sm *StateMachine) addPeer(c diam.Conn, meta *smpeer.Metadata, limits *PeerLimits) *Peer {
+	p := newPeer(c, meta, limits)
+	sm.peersMu.Lock()
+	sm.peers[c] = p
+	sm.peersMu.Unlock()
+	return p
+}
+
+func (sm *StateMachine) removePeer(c diam.Conn) {
+	sm.peersMu.Lock()
+	delete(sm.peers, c)
+	sm.peersMu.Unlock()
+}
+
+// peer returns the Peer of the connection c, if it passed the handshake.
+func (sm *StateMachine) peer(c diam.Conn) (*Peer, bool) {
+	sm.peersMu.RLock()
+	defer sm.peersMu.RUnlock()
+	p, ok := sm.peers[c]
+	return p, ok
+}
+
+// Peers returns the peers that passed the handshake and are not
+// draining. These are the peers eligible to receive new requests.
+func (sm *StateMachine) Peers() []*Peer {
+	sm.peersMu.RLock()
+	defer sm.peersMu.RUnlock()
+	peers := make([]*Peer, 0, len(sm.peers))
+	for _, p := range sm.peers {
+		if !p.Draining() {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
+
+// PeerFor returns the peer of the realm that supports the application
+// appID and has the fewest outstanding requests, or nil if no such peer
+// passed the handshake or all of them are draining. An empty realm
+// matches the peers of all realms.
+func (sm *StateMachine) PeerFor(realm datatype.DiameterIdentity, appID uint32) *Peer {
+	var best *Peer
+	for _, p := range sm.Peers() {
+		if realm != "" && p.Metadata.OriginRealm != realm {
+			continue
+		}
+		if !p.Metadata.Supports(appID) {
+			continue
+		}
+		if best == nil || p.Outstanding() < best.Outstanding() {
+			best = p
+		}
+	}
+	return best
+}
+
+// peersByHost returns all connections of the peer identified by
+// its Origin-Host, including the ones draining.
+func (sm *StateMachine) peersByHost(host string) []*Peer {
+	sm.peersMu.RLock()
+	defer sm.peersMu.RUnlock()
+	var peers []*Peer
+	for _, p := range sm.peers {
+		if string(p.Metadata.OriginHost) == host {
+			peers = append(peers, p)
+		}
+	}
+	return peers
+}
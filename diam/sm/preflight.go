@@ -0,0 +1,126 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+// ErrDPATimeout is the error of PreflightResults of peers that did
+// not answer the DPR.
+var ErrDPATimeout = errors.New("DPR timeout (no DPA)")
+
+// PreflightResult is the outcome of the preflight check of a peer, see
+// Client.Preflight.
+type PreflightResult struct {
+	Addr         string               `json:"addr"`
+	Error        string               `json:"error,omitempty"`
+	Capabilities *smpeer.Capabilities `json:"capabilities,omitempty"` // Nil if the handshake failed
+	DPA          bool                 `json:"dpa"`                    // True if the DPR was answered
+
+	Err error `json:"-"` // Error of the check, nil on success
+}
+
+// Preflight checks the peers at addrs, e.g. as a deployment smoke test
+// before serving traffic: it dials each of them in parallel like
+// DialContext, records the capabilities negotiated in the handshake,
+// sends DPR when dpr is true and closes the connection. The DPR has the
+// Disconnect-Cause DoNotWantToTalkToYou, as the probe does not intend
+// to reconnect. The watchdog is not started, regardless of
+// EnableWatchdog.
+//
+// It returns the results in the order of addrs, and the error of the
+// first peer that failed. DPRs not answered within RetransmitInterval
+// fail with ErrDPATimeout. See WritePreflight for a summary.
+func (cli *Client) Preflight(ctx context.Context, dpr bool, addrs ...string) ([]*PreflightResult, error) {
+	if _, err := cli.validate(); err != nil {
+		return nil, err
+	}
+	probe := *cli
+	probe.EnableWatchdog = false
+	results := make([]*PreflightResult, len(addrs))
+	done := make(chan struct{}, len(addrs))
+	for i, addr := range addrs {
+		results[i] = &PreflightResult{Addr: addr}
+		go func(r *PreflightResult) {
+			probe.preflight(ctx, dpr, r)
+			done <- struct{}{}
+		}(results[i])
+	}
+	for range addrs {
+		<-done
+	}
+	var err error
+	for _, r := range results {
+		if r.Err != nil {
+			r.Error = r.Err.Error()
+			if err == nil {
+				err = r.Err
+			}
+		}
+	}
+	return results, err
+}
+
+func (cli *Client) preflight(ctx context.Context, dpr bool, r *PreflightResult) {
+	c, err := cli.DialContext(ctx, r.Addr)
+	if err != nil {
+		r.Err = err
+		return
+	}
+	defer c.Close()
+	if meta, ok := smpeer.FromContext(c.Context()); ok {
+		r.Capabilities = meta.Capabilities
+	}
+	if !dpr {
+		return
+	}
+	p, ok := cli.Handler.peer(c)
+	if !ok {
+		r.Err = diam.ErrConnClosed
+		return
+	}
+	if r.Err = cli.Handler.sendDPR(c, DoNotWantToTalkToYou); r.Err != nil {
+		return
+	}
+	select {
+	case <-p.dpac:
+		r.DPA = true
+	case <-ctx.Done():
+		r.Err = ctx.Err()
+	case <-cli.Handler.clock().After(cli.RetransmitInterval):
+		r.Err = ErrDPATimeout
+	}
+}
+
+// WritePreflight writes a summary of the results of Preflight to w, a
+// line per peer with its identity and negotiated applications, or the
+// error of the check.
+func WritePreflight(w io.Writer, results []*PreflightResult) error {
+	for _, r := range results {
+		var err error
+		switch {
+		case r.Err != nil:
+			_, err = fmt.Fprintf(w, "FAIL %s: %v\n", r.Addr, r.Err)
+		case r.Capabilities != nil && r.Capabilities.Remote != nil:
+			remote := r.Capabilities.Remote
+			_, err = fmt.Fprintf(w, "OK   %s: %s (%s) %s, applications %v\n", r.Addr,
+				remote.OriginHost, remote.OriginRealm, remote.ProductName, r.Capabilities.Applications)
+		default:
+			_, err = fmt.Fprintf(w, "OK   %s\n", r.Addr)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
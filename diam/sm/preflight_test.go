@@ -0,0 +1,90 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestClient_Preflight(t *testing.T) {
+	causes := make(chan datatype.Enumerated, 1)
+	mux := New(serverSettings)
+	mux.HandleFunc("DPR", func(c diam.Conn, m *diam.Message) {
+		if cause, err := m.FindAVP(avp.DisconnectCause, 0); err == nil {
+			causes <- cause.Data.(datatype.Enumerated)
+		}
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, serverSettings.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, serverSettings.OriginRealm)
+		a.WriteTo(c)
+	})
+	srv := diamtest.NewServer(mux, dict.Default)
+	defer srv.Close()
+	// A server that does not answer DPR.
+	silent := diamtest.NewServer(New(serverSettings), dict.Default)
+	defer silent.Close()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	cli := &Client{
+		Handler:            New(clientSettings),
+		RetransmitInterval: 100 * time.Millisecond,
+		EnableWatchdog:     true,
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	results, err := cli.Preflight(context.Background(), true, srv.Addr, silent.Addr, closed)
+	if len(results) != 3 {
+		t.Fatalf("Unexpected number of results: %d", len(results))
+	}
+	if err != results[1].Err {
+		t.Fatalf("Unexpected error. Want %v, have %v", results[1].Err, err)
+	}
+	ok := results[0]
+	if ok.Err != nil || !ok.DPA || ok.Capabilities == nil {
+		t.Fatalf("Unexpected result: %+v", ok)
+	}
+	if host := ok.Capabilities.Remote.OriginHost; host != string(serverSettings.OriginHost) {
+		t.Fatalf("Unexpected Origin-Host. Want %s, have %s", serverSettings.OriginHost, host)
+	}
+	if cause := <-causes; cause != DoNotWantToTalkToYou {
+		t.Fatalf("Unexpected Disconnect-Cause. Want %d, have %d", DoNotWantToTalkToYou, cause)
+	}
+	if !cli.EnableWatchdog {
+		t.Fatal("Preflight modified the client")
+	}
+	if r := results[1]; r.Err != ErrDPATimeout || r.Capabilities == nil {
+		t.Fatalf("Unexpected result: %+v", r)
+	}
+	if r := results[2]; r.Err == nil || r.Error == "" || r.Capabilities != nil {
+		t.Fatalf("Unexpected result: %+v", r)
+	}
+
+	var b bytes.Buffer
+	if err = WritePreflight(&b, results); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "OK   "+srv.Addr) || !strings.HasPrefix(lines[2], "FAIL "+closed) {
+		t.Fatalf("Unexpected summary:\n%s", b.String())
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
+)
+
+var (
+	// ErrPeerNotFound is returned by DrainPeer when there is no peer
+	// with the given Origin-Host.
+	ErrPeerNotFound = errors.New("peer not found")
+
+	// ErrPeerDrained is the reason returned by Conn.Err for
+	// connections closed by DrainPeer, after DPR.
+	ErrPeerDrained = errors.New("peer disconnected with DPR")
+)
+
+// Disconnect-Cause values. See RFC 6733 section 5.4.3 for details.
+const (
+	Rebooting            = smparser.Rebooting
+	Busy                 = smparser.Busy
+	DoNotWantToTalkToYou = smparser.DoNotWantToTalkToYou
+)
+
+// A Server is a diameter server that runs the state machine on the
+// connections accepted by all its listeners, which share the same
+// peer table.
+type Server struct {
+	Addr            string              // TCP address to listen on, ":3868" if empty
+	Network         string              // Transport of Addr, e.g. "sctp" or "tcp6", "tcp" if empty
+	Handler         *StateMachine       // Message handler
+	Dict            *dict.Parser        // Dictionary parser (uses dict.Default if unset)
+	ReadTimeout     time.Duration       // Maximum duration before timing out read of the request
+	WriteTimeout    time.Duration       // Maximum duration before timing out write of the response
+	TLSConfig       *tls.Config         // Optional TLS config, used by ListenAndServeTLS
+	DrainTimeout    time.Duration       // Maximum wait for outstanding answers and DPA (default 5s)
+	DisconnectCause datatype.Enumerated // Disconnect-Cause sent in DPR by DrainPeer (default Rebooting)
+
+	// FloodGuard limits the rate of messages that cannot be decoded
+	// on each connection, see diam.FloodGuard. When its Disconnect
+	// function is unset, peers that passed the handshake are sent
+	// DPR with DoNotWantToTalkToYou before the connection is closed.
+	FloodGuard *diam.FloodGuard
+
+	// AnswerDecodeErrors keeps connections open after messages that
+	// cannot be decoded, see diam.Server.AnswerDecodeErrors.
+	AnswerDecodeErrors bool
+
+	// Codec is the wire format of messages, see diam.Server.Codec.
+	Codec diam.Codec
+
+	// WrapConn wraps the connections, e.g. with diam.Compress, see
+	// diam.Server.WrapConn. Optional.
+	WrapConn diam.ConnWrapper
+
+	// FlowControl limits the outstanding requests of connections,
+	// see diam.FlowControl. Optional.
+	FlowControl *diam.FlowControl
+
+	// Intercept may modify or veto the frames written to
+	// connections, see diam.InterceptFunc. For tests only.
+	Intercept diam.InterceptFunc
+
+	once sync.Once
+	srv  *diam.Server
+}
+
+func (srv *Server) server() *diam.Server {
+	srv.once.Do(func() {
+		srv.srv = &diam.Server{
+			Addr:         srv.Addr,
+			Network:      srv.Network,
+			Handler:      srv.Handler,
+			Dict:         srv.Dict,
+			ReadTimeout:  srv.ReadTimeout,
+			WriteTimeout: srv.WriteTimeout,
+			TLSConfig:    srv.TLSConfig,
+
+			AnswerDecodeErrors: srv.AnswerDecodeErrors,
+			Codec:              srv.Codec,
+			WrapConn:           srv.WrapConn,
+			FlowControl:        srv.FlowControl,
+			Intercept:          srv.Intercept,
+		}
+		if g := srv.FloodGuard; g != nil && g.Disconnect == nil {
+			guard := *g
+			guard.Disconnect = srv.disconnectFlood
+			srv.srv.FloodGuard = &guard
+		} else {
+			srv.srv.FloodGuard = g
+		}
+	})
+	return srv.srv
+}
+
+// validate checks the state machine of the server and its Settings.
+func (srv *Server) validate() error {
+	if srv.Handler == nil {
+		return ErrMissingStateMachine
+	}
+	return srv.Handler.Settings().Validate()
+}
+
+// ListenAndServe listens on the network address srv.Addr of the
+// transport srv.Network, TCP by default, and then calls Serve to handle
+// requests on incoming connections.
+func (srv *Server) ListenAndServe() error {
+	if err := srv.validate(); err != nil {
+		return err
+	}
+	return srv.server().ListenAndServe()
+}
+
+// ListenAndServeTLS is like ListenAndServe, but for incoming TLS
+// connections.
+func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	if err := srv.validate(); err != nil {
+		return err
+	}
+	return srv.server().ListenAndServeTLS(certFile, keyFile)
+}
+
+// ListenAndServeDTLS is like ListenAndServe, but for incoming DTLS
+// connections over the transport of srv.Network, SCTP if unset. See
+// diam.RegisterDTLS.
+func (srv *Server) ListenAndServeDTLS(certFile, keyFile string) error {
+	if err := srv.validate(); err != nil {
+		return err
+	}
+	return srv.server().ListenAndServeDTLS(certFile, keyFile)
+}
+
+// Serve accepts incoming connections on the Listener l.
+func (srv *Server) Serve(l net.Listener) error {
+	if err := srv.validate(); err != nil {
+		return err
+	}
+	return srv.server().Serve(l)
+}
+
+// ServeListeners accepts incoming connections on all the listeners.
+// See diam.Server.ServeListeners for details.
+func (srv *Server) ServeListeners(ls ...net.Listener) error {
+	if err := srv.validate(); err != nil {
+		return err
+	}
+	return srv.server().ServeListeners(ls...)
+}
+
+// ReloadDict atomically replaces the dictionary parser of the server,
+// see diam.Server.ReloadDict. Established peers are not dropped.
+func (srv *Server) ReloadDict(dp *dict.Parser) {
+	srv.server().ReloadDict(dp)
+}
+
+// Peers returns the peers that passed the handshake and are not
+// draining, see StateMachine.Peers.
+func (srv *Server) Peers() []*Peer {
+	if srv.Handler == nil {
+		return nil
+	}
+	return srv.Handler.Peers()
+}
+
+// SendToPeer sends the message m, e.g. a RAR or ASR, to the peer
+// identified by originHost, see Peer.Send. When the peer has multiple
+// connections, m is sent over the one with the fewest outstanding
+// requests.
+//
+// SendToPeer returns ErrPeerNotFound if the peer has not passed the
+// handshake, and ErrPeerDraining if all its connections are draining.
+func (srv *Server) SendToPeer(originHost string, m *diam.Message) (int64, error) {
+	if srv.Handler == nil {
+		return 0, ErrMissingStateMachine
+	}
+	peers := srv.Handler.peersByHost(originHost)
+	if len(peers) == 0 {
+		return 0, ErrPeerNotFound
+	}
+	var best *Peer
+	for _, p := range peers {
+		if !p.Draining() && (best == nil || p.Outstanding() < best.Outstanding()) {
+			best = p
+		}
+	}
+	if best == nil {
+		return 0, ErrPeerDraining
+	}
+	return best.Send(m)
+}
+
+// DrainPeer gracefully disconnects all connections of the peer
+// identified by originHost, for maintenance of the peer.
+//
+// The peer is immediately removed from the peers eligible for new
+// requests, see StateMachine.Peers. DrainPeer then waits for the
+// outstanding requests of the peer to complete, sends DPR and waits
+// for DPA before closing the connection. Each wait is limited by
+// DrainTimeout, and ends when the connection is closed.
+//
+// Only the requests sent with Peer.Send or Server.SendToPeer, and the
+// requests from the peer being handled, are outstanding. Requests
+// written directly to the connection are not awaited.
+//
+// DrainPeer returns after all connections of the peer are closed.
+func (srv *Server) DrainPeer(originHost string) error {
+	if srv.Handler == nil {
+		return ErrMissingStateMachine
+	}
+	peers := srv.Handler.peersByHost(originHost)
+	if len(peers) == 0 {
+		return ErrPeerNotFound
+	}
+	errc := make(chan error, len(peers))
+	for _, p := range peers {
+		go func(p *Peer) {
+			errc <- srv.drain(p)
+		}(p)
+	}
+	var err error
+	for range peers {
+		if e := <-errc; e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+func (srv *Server) drain(p *Peer) error {
+	defer diam.CloseWithError(p.Conn, ErrPeerDrained)
+	p.drain()
+	timeout := srv.DrainTimeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+	clock := srv.Handler.clock()
+	deadline := clock.After(timeout)
+wait:
+	for p.Outstanding() > 0 {
+		select {
+		case <-p.changed:
+		case <-deadline:
+			break wait
+		case <-p.Conn.Done():
+			return nil
+		}
+	}
+	if err := srv.Handler.sendDPR(p.Conn, srv.DisconnectCause); err != nil {
+		return err
+	}
+	select {
+	case <-p.dpac:
+	case <-clock.After(timeout):
+	case <-p.Conn.Done():
+	}
+	return nil
+}
+
+// disconnectFlood sends DPR to peers disconnected by the FloodGuard,
+// if they passed the handshake. The DPA is not awaited.
+func (srv *Server) disconnectFlood(c diam.Conn, err error) {
+	if _, ok := srv.Handler.peer(c); !ok {
+		return
+	}
+	srv.Handler.sendDPR(c, DoNotWantToTalkToYou)
+}
+
+// sendDPR sends DPR with the given Disconnect-Cause to the peer of c.
+func (sm *StateMachine) sendDPR(c diam.Conn, cause datatype.Enumerated) error {
+	cfg := sm.SettingsFor(c)
+	m := diam.NewRequest(diam.DisconnectPeer, 0, c.Dictionary())
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, cause)
+	_, err := m.WriteTo(c)
+	return err
+}
@@ -0,0 +1,303 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestServer_DrainPeer_MissingStateMachine(t *testing.T) {
+	srv := &Server{}
+	if err := srv.DrainPeer("cli"); err != ErrMissingStateMachine {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrMissingStateMachine, err)
+	}
+}
+
+func TestServer_DrainPeer(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := &Server{Handler: sm, DrainTimeout: time.Second}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+	if err = srv.DrainPeer("cli"); err != ErrPeerNotFound {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrPeerNotFound, err)
+	}
+
+	dprc := make(chan *diam.Message, 1)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.HandleFunc("DPR", func(c diam.Conn, m *diam.Message) {
+		dprc <- m
+		a := m.Answer(diam.Success)
+		a.NewAVP(avp.OriginHost, avp.Mbit, 0, clientSettings.OriginHost)
+		a.NewAVP(avp.OriginRealm, avp.Mbit, 0, clientSettings.OriginRealm)
+		a.WriteTo(c)
+	})
+	c, err := cli.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case ev := <-events:
+		if ev.Type != PeerUp {
+			t.Fatalf("Unexpected event. Want PeerUp, have %s", ev.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for PeerUp")
+	}
+	peers := sm.Peers()
+	if len(peers) != 1 {
+		t.Fatalf("Unexpected number of peers. Want 1, have %d", len(peers))
+	}
+	p := peers[0]
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.DrainPeer("cli") }()
+	select {
+	case m := <-dprc:
+		a, err := m.FindAVP(avp.DisconnectCause, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := a.Data.(datatype.Enumerated); v != Rebooting {
+			t.Fatalf("Unexpected Disconnect-Cause. Want %d, have %d", Rebooting, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DPR")
+	}
+	if n := len(sm.Peers()); n != 0 {
+		t.Fatalf("Unexpected number of peers. Want 0, have %d", n)
+	}
+	if _, err = p.Send(diam.NewRequest(diam.DeviceWatchdog, 0, nil)); err != ErrPeerDraining {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrPeerDraining, err)
+	}
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(500 * time.Millisecond):
+		t.Fatal("Timed out: DPA was not handled")
+	}
+	select {
+	case <-c.(diam.CloseNotifier).CloseNotify():
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for connection to be closed")
+	}
+}
+
+func TestServer_DrainPeer_Closed(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := &Server{Handler: sm, DrainTimeout: 5 * time.Second}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+
+	rarc := make(chan *diam.Message, 1)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	// The RAR is never answered.
+	cli.Handler.HandleFunc("RAR", func(c diam.Conn, m *diam.Message) {
+		rarc <- m
+	})
+	c, err := cli.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case <-events:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for PeerUp")
+	}
+	m := diam.NewRequest(diam.ReAuth, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("srv;1"))
+	if _, err = srv.SendToPeer("cli", m); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rarc:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for RAR")
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.DrainPeer("cli") }()
+	time.Sleep(50 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-errc:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out: DrainPeer waited for a closed connection")
+	}
+}
+
+func TestServer_SendToPeer(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := &Server{Handler: sm}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+	m := diam.NewRequest(diam.ReAuth, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("srv;1"))
+	if _, err = srv.SendToPeer("cli", m); err != ErrPeerNotFound {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrPeerNotFound, err)
+	}
+
+	rarc := make(chan *diam.Message, 1)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.HandleFunc("RAR", func(c diam.Conn, m *diam.Message) {
+		rarc <- m
+	})
+	c, err := cli.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	select {
+	case ev := <-events:
+		if ev.Type != PeerUp {
+			t.Fatalf("Unexpected event. Want PeerUp, have %s", ev.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for PeerUp")
+	}
+	peers := srv.Peers()
+	if len(peers) != 1 || peers[0].Metadata.OriginHost != "cli" {
+		t.Fatalf("Unexpected peers: %v", peers)
+	}
+	if p := sm.PeerFor(clientSettings.OriginRealm, 0); p != peers[0] {
+		t.Fatalf("Unexpected peer for the realm: %v", p)
+	}
+	if p := sm.PeerFor("other.realm", 0); p != nil {
+		t.Fatalf("Unexpected peer for another realm: %v", p)
+	}
+	if p := sm.PeerFor("", 4); p != nil {
+		t.Fatalf("Unexpected peer for an application not negotiated: %v", p)
+	}
+	if _, err = srv.SendToPeer("cli", m); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case rar := <-rarc:
+		if rar.Header.HopByHopID != m.Header.HopByHopID {
+			t.Fatalf("Unexpected RAR: %s", rar)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for RAR")
+	}
+	if n := peers[0].Outstanding(); n != 1 {
+		t.Fatalf("Unexpected outstanding requests. Want 1, have %d", n)
+	}
+	peers[0].drain()
+	if _, err = srv.SendToPeer("cli", m); err != ErrPeerDraining {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrPeerDraining, err)
+	}
+	if p := sm.PeerFor("", 0); p != nil {
+		t.Fatalf("Unexpected draining peer: %v", p)
+	}
+}
+
+func TestServer_FloodGuard(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := &Server{
+		Handler:    sm,
+		FloodGuard: &diam.FloodGuard{MaxUnknownCommands: 1},
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go srv.Serve(l)
+	dprc := make(chan *diam.Message, 1)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.HandleFunc("DPR", func(c diam.Conn, m *diam.Message) {
+		dprc <- m
+	})
+	c, err := cli.Dial(l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitEvent(t, events, PeerUp)
+	unknown := diam.NewRequest(9999, 0, nil).Header.Serialize()
+	for i := 0; i < 2; i++ {
+		if _, err = c.Write(unknown); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case m := <-dprc:
+		a, err := m.FindAVP(avp.DisconnectCause, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := a.Data.(datatype.Enumerated); v != DoNotWantToTalkToYou {
+			t.Fatalf("Unexpected Disconnect-Cause. Want %d, have %d", DoNotWantToTalkToYou, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DPR")
+	}
+	e := waitEvent(t, events, PeerDown)
+	if _, ok := e.Error.(*diam.FloodError); !ok {
+		t.Fatalf("Unexpected PeerDown error: %v", e.Error)
+	}
+}
+
+func TestPeer_Outstanding(t *testing.T) {
+	p := newPeer(nil, nil, nil)
+	p.sent[1] = struct{}{}
+	p.serve(false)
+	if n := p.Outstanding(); n != 2 {
+		t.Fatalf("Unexpected outstanding requests. Want 2, have %d", n)
+	}
+	p.answered(1)
+	p.serve(true)
+	if n := p.Outstanding(); n != 0 {
+		t.Fatalf("Unexpected outstanding requests. Want 0, have %d", n)
+	}
+}
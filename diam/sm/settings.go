@@ -0,0 +1,115 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// SettingsError is returned by Settings.Validate, and by Clients and
+// Servers, for settings that would produce invalid CER or CEA.
+type SettingsError struct {
+	Field  string // Name of the invalid field, e.g. OriginHost
+	Reason string // Description of the problem
+}
+
+// Error implements the error interface.
+func (e *SettingsError) Error() string {
+	return fmt.Sprintf("invalid settings: %s %s", e.Field, e.Reason)
+}
+
+// Validate checks that OriginHost and OriginRealm are valid
+// DiameterIdentities, and that ProductName is set.
+//
+// DiameterIdentities are FQDNs, see RFC 6733 section 4.3.1. Labels may
+// contain underscores, which are common in lab deployments.
+func (s *Settings) Validate() error {
+	if err := validateIdentity("OriginHost", s.OriginHost); err != nil {
+		return err
+	}
+	if err := validateIdentity("OriginRealm", s.OriginRealm); err != nil {
+		return err
+	}
+	if s.ProductName == "" {
+		return &SettingsError{"ProductName", "is empty"}
+	}
+	return nil
+}
+
+func validateIdentity(field string, id datatype.DiameterIdentity) error {
+	if id == "" {
+		return &SettingsError{field, "is empty"}
+	}
+	if len(id) > 255 {
+		return &SettingsError{field, fmt.Sprintf("%q is longer than 255 characters", id)}
+	}
+	for _, label := range strings.Split(string(id), ".") {
+		reason := ""
+		switch {
+		case label == "":
+			reason = "has an empty label"
+		case len(label) > 63:
+			reason = fmt.Sprintf("has label %q longer than 63 characters", label)
+		case label[0] == '-' || label[len(label)-1] == '-':
+			reason = fmt.Sprintf("has label %q starting or ending with '-'", label)
+		}
+		for _, c := range label {
+			if reason != "" {
+				break
+			}
+			if !isIdentityChar(c) {
+				reason = fmt.Sprintf("has invalid character %q", c)
+			}
+		}
+		if reason != "" {
+			return &SettingsError{field, fmt.Sprintf("%q is not a valid DiameterIdentity: %s", id, reason)}
+		}
+	}
+	return nil
+}
+
+func isIdentityChar(c rune) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' ||
+		c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+// validateVendorApplications checks that the Vendor-Specific-Application-Id
+// AVPs have a non-zero Vendor-Id and exactly one Auth-Application-Id or
+// Acct-Application-Id, see VendorAuthApplicationID.
+func validateVendorApplications(avps []*diam.AVP) error {
+	for _, a := range avps {
+		g, ok := a.Data.(*diam.GroupedAVP)
+		if a.Code != avp.VendorSpecificApplicationID || !ok {
+			return &SettingsError{"VendorSpecificApplicationID",
+				fmt.Sprintf("has AVP %d that is not a grouped Vendor-Specific-Application-Id", a.Code)}
+		}
+		vendor, apps := false, 0
+		for _, ga := range g.AVP {
+			switch ga.Code {
+			case avp.VendorID:
+				if v, ok := ga.Data.(datatype.Unsigned32); !ok || v == 0 {
+					return &SettingsError{"VendorSpecificApplicationID",
+						"has a Vendor-Id of zero, use AuthApplicationID or AcctApplicationID for IETF applications"}
+				}
+				vendor = true
+			case avp.AuthApplicationID, avp.AcctApplicationID:
+				apps++
+			}
+		}
+		if !vendor {
+			return &SettingsError{"VendorSpecificApplicationID", "has an AVP without Vendor-Id"}
+		}
+		if apps != 1 {
+			return &SettingsError{"VendorSpecificApplicationID",
+				fmt.Sprintf("has an AVP with %d application ids, want one Auth-Application-Id or Acct-Application-Id", apps)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestSettings_Validate(t *testing.T) {
+	var tests = []struct {
+		host, realm datatype.DiameterIdentity
+		product     datatype.UTF8String
+		field       string // empty if valid
+	}{
+		{"srv", "test", "go-diameter", ""},
+		{"pcrf_1.epc.mnc001.mcc001.3gppnetwork.org", "example.com", "go-diameter", ""},
+		{"", "test", "go-diameter", "OriginHost"},
+		{"srv host", "test", "go-diameter", "OriginHost"},
+		{"srv..example.com", "test", "go-diameter", "OriginHost"},
+		{"-srv.example.com", "test", "go-diameter", "OriginHost"},
+		{datatype.DiameterIdentity("srv." + strings.Repeat("x", 64)), "test", "go-diameter", "OriginHost"},
+		{"srv", "", "go-diameter", "OriginRealm"},
+		{"srv", "example.com.", "go-diameter", "OriginRealm"},
+		{"srv", "test", "", "ProductName"},
+	}
+	for _, test := range tests {
+		s := &Settings{
+			OriginHost:  test.host,
+			OriginRealm: test.realm,
+			ProductName: test.product,
+		}
+		err := s.Validate()
+		if test.field == "" {
+			if err != nil {
+				t.Errorf("Unexpected error for %q/%q: %s", test.host, test.realm, err)
+			}
+			continue
+		}
+		se, ok := err.(*SettingsError)
+		if !ok || se.Field != test.field {
+			t.Errorf("Unexpected error for %q/%q/%q. Want %s, have %v",
+				test.host, test.realm, test.product, test.field, err)
+		}
+	}
+}
+
+func TestClient_Dial_InvalidSettings(t *testing.T) {
+	settings := *clientSettings
+	settings.OriginHost = "cli host"
+	cli := &Client{
+		Handler: New(&settings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	if _, err := cli.Dial(":0"); err == nil || !strings.Contains(err.Error(), "OriginHost") {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	cli = &Client{
+		Handler: New(clientSettings),
+		VendorSpecificApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.VendorSpecificApplicationID, avp.Mbit, 0, &diam.GroupedAVP{
+				AVP: []*diam.AVP{
+					diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(4)),
+				},
+			}),
+		},
+	}
+	_, err := cli.Dial(":0")
+	if se, ok := err.(*SettingsError); !ok || se.Field != "VendorSpecificApplicationID" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestServer_InvalidSettings(t *testing.T) {
+	srv := &Server{Handler: New(&Settings{OriginHost: "srv", OriginRealm: "test"})}
+	err := srv.ListenAndServe()
+	if se, ok := err.(*SettingsError); !ok || se.Field != "ProductName" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestNew_InvalidSettings(t *testing.T) {
+	sm := New(&Settings{OriginHost: "srv", OriginRealm: "test"})
+	select {
+	case report := <-sm.ErrorReports():
+		if se, ok := report.Error.(*SettingsError); !ok || se.Field != "ProductName" {
+			t.Fatalf("Unexpected error: %v", report.Error)
+		}
+	default:
+		t.Fatal("Invalid settings were not reported")
+	}
+	sm = New(serverSettings)
+	select {
+	case report := <-sm.ErrorReports():
+		t.Fatalf("Unexpected error: %v", report.Error)
+	default:
+	}
+}
@@ -6,8 +6,11 @@ package sm
 
 import (
 	"fmt"
+	"sync"
+	"sync/atomic"
 
 	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
 )
@@ -30,38 +33,232 @@ type Settings struct {
 
 	// FirmwareRevision is optional, and not added if unset.
 	FirmwareRevision datatype.Unsigned32
+
+	// SupportedVendorID is the list of vendors supported locally,
+	// sent in CEA on servers and in CER on clients, unless the
+	// client has its own list. Optional.
+	SupportedVendorID []datatype.Unsigned32
+
+	// Applications is the list of application IDs supported by
+	// servers. When set, CER is accepted if at least one of its
+	// applications is in the list, and the applications in common
+	// are sent in the CEA and stored in the peer metadata.
+	//
+	// When unset, all applications in the dictionary are supported.
+	//
+	// Relay agents set smparser.RelayApplicationID, which accepts all
+	// applications and is the only one advertised in CEA.
+	//
+	// Clients support the applications in the CER, see Client.
+	Applications []uint32
+
+	// InbandSecurity is the list of Inband-Security-Id values
+	// accepted by servers in CER, e.g. smparser.TLSInbandSecurity
+	// on deployments where it is mandatory. When unset, only
+	// smparser.NoInbandSecurity is accepted.
+	InbandSecurity []datatype.Unsigned32
+
+	// DuplicateCER is what servers do with a CER received on a
+	// connection that already passed the handshake. Uses IgnoreCER
+	// if unset.
+	DuplicateCER CERPolicy
 }
 
 // StateMachine is a specialized type of diam.ServeMux that handles
 // the CER/CEA handshake and DWR/DWA messages for clients or servers.
 //
 // Other handlers registered in the state machine are only executed
-// after the peer has passed the initial CER/CEA handshake.
+// after the peer has passed the initial CER/CEA handshake. Requests of
+// unsupported applications are only answered with
+// DIAMETER_APPLICATION_UNSUPPORTED (3007) after the handshake too.
 type StateMachine struct {
-	cfg       *Settings
+	cfg       atomic.Value // *Settings
+	resolver  atomic.Value // SettingsFunc
+	auth      atomic.Value // authenticator
+	clk       atomic.Value // clockValue
+	store     atomic.Value // storeValue
+	tables    atomic.Value // *Tables
 	mux       *diam.ServeMux
 	hsNotifyc chan diam.Conn // handshake notifier
+	events    *EventBus
+
+	peersMu sync.RWMutex
+	peers   map[diam.Conn]*Peer
+
+	clientsMu sync.Mutex
+	clients   map[diam.Conn]*clientConn // connections dialed by Clients
 }
 
 // New creates and initializes a new StateMachine for clients or servers.
+//
+// The settings are validated by New, which reports the error of
+// Settings.Validate to ErrorReports, and again when Clients dial and
+// Servers start, which fail with that error.
 func New(settings *Settings) *StateMachine {
 	sm := &StateMachine{
-		cfg:       settings,
 		mux:       diam.NewServeMux(),
 		hsNotifyc: make(chan diam.Conn),
+		events:    NewEventBus(),
+		peers:     make(map[diam.Conn]*Peer),
 	}
+	sm.cfg.Store(settings)
 	sm.mux.Handle("CER", handleCER(sm))
-	sm.mux.Handle("DWR", handshakeOK(handleDWR(sm)))
+	sm.mux.Handle("DWR", sm.handshakeOK(handleDWR(sm)))
+	sm.mux.Handle("DPA", sm.handshakeOK(handleDPA(sm)))
+	sm.mux.HandleUnsupported(sm.handshakeOK(
+		diam.ResultCodeHandler(diam.ApplicationUnsupported).ServeDIAM))
+	sm.mux.HandleEgress(sm.addOrigin)
+	if settings != nil {
+		if err := settings.Validate(); err != nil {
+			sm.Error(&diam.ErrorReport{Error: err})
+		}
+	}
 	return sm
 }
 
 // Settings return the Settings object used by this StateMachine.
 func (sm *StateMachine) Settings() *Settings {
-	return sm.cfg
+	return sm.cfg.Load().(*Settings)
+}
+
+// Reload atomically replaces the Settings used by this StateMachine.
+//
+// Established peers are not dropped, and the capabilities they
+// negotiated are kept. Messages sent from now on use the new settings,
+// like CEA to new peers, and DWA and DPR to established peers.
+//
+// Reload only replaces the Settings. Dictionaries are replaced with
+// Server.ReloadDict, and the peer and routing tables with ReloadTables.
+//
+// The Settings object must not be modified after it is passed to
+// Reload or New. Create a new one instead.
+func (sm *StateMachine) Reload(settings *Settings) {
+	sm.cfg.Store(settings)
+}
+
+// UseClock sets the Clock of the state machine, the source of time of
+// peer capabilities and PeerLimits, and of the retransmissions and
+// watchdog of Clients and the drain timeouts of Servers using the
+// state machine. Uses diam.SystemClock if unset.
+func (sm *StateMachine) UseClock(c diam.Clock) {
+	sm.clk.Store(clockValue{c})
+}
+
+// clockValue wraps Clocks stored in atomic.Value, which requires a
+// consistent concrete type.
+type clockValue struct {
+	diam.Clock
+}
+
+func (sm *StateMachine) clock() diam.Clock {
+	if c, ok := sm.clk.Load().(clockValue); ok && c.Clock != nil {
+		return c.Clock
+	}
+	return diam.SystemClock
+}
+
+// UseStore sets the store where the metadata of peers is saved when
+// they pass the handshake, so it is known across restarts, see
+// PeerMetadata. Errors saving the metadata are published as StoreFailed
+// events. Optional.
+func (sm *StateMachine) UseStore(s smpeer.Store) {
+	sm.store.Store(storeValue{s})
+}
+
+// storeValue wraps Stores stored in atomic.Value, which requires a
+// consistent concrete type.
+type storeValue struct {
+	smpeer.Store
+}
+
+// PeerMetadata returns the metadata of the peer identified by its
+// Origin-Host: the metadata of its connection if it passed the
+// handshake, or the metadata saved in the store otherwise.
+func (sm *StateMachine) PeerMetadata(originHost datatype.DiameterIdentity) (*smpeer.Metadata, bool) {
+	if peers := sm.peersByHost(string(originHost)); len(peers) > 0 {
+		return peers[0].Metadata, true
+	}
+	if s, ok := sm.store.Load().(storeValue); ok && s.Store != nil {
+		return s.Load(originHost)
+	}
+	return nil, false
+}
+
+// savePeer saves the metadata of the peer of the connection c to the
+// store, if any.
+func (sm *StateMachine) savePeer(c diam.Conn, meta *smpeer.Metadata) {
+	s, ok := sm.store.Load().(storeValue)
+	if !ok || s.Store == nil {
+		return
+	}
+	if err := s.Save(meta); err != nil {
+		sm.events.Publish(&Event{
+			Type:  StoreFailed,
+			Conn:  c,
+			Peer:  meta,
+			Error: err,
+		})
+	}
+}
+
+// SettingsFunc returns the Settings of the connection c, for state
+// machines presenting different identities, e.g. based on the local
+// address of c (the listener) or the realm of the peer.
+//
+// peerRealm is the Origin-Realm of the peer's CER or CEA, or empty when
+// unknown, like in the CER sent by clients. When SettingsFunc returns
+// nil, the Settings of the StateMachine are used.
+type SettingsFunc func(c diam.Conn, peerRealm datatype.DiameterIdentity) *Settings
+
+// ResolveSettings sets the function used to resolve the Settings of
+// each connection, for multi-tenancy. The Settings of the StateMachine
+// are used for connections f does not resolve.
+//
+// The CER/CEA handshake, DWR/DWA, DPR and the Origin-Host and
+// Origin-Realm AVPs added to answers use the resolved Settings.
+// f must return the same Settings for a connection and realm, and
+// the Settings must not be modified.
+func (sm *StateMachine) ResolveSettings(f SettingsFunc) {
+	sm.resolver.Store(f)
+}
+
+// SettingsFor returns the Settings of the connection c, resolved by the
+// function set with ResolveSettings, or the Settings of the
+// StateMachine.
+func (sm *StateMachine) SettingsFor(c diam.Conn) *Settings {
+	var realm datatype.DiameterIdentity
+	if meta, ok := smpeer.FromContext(c.Context()); ok {
+		realm = meta.OriginRealm
+	}
+	return sm.settingsFor(c, realm)
+}
+
+func (sm *StateMachine) settingsFor(c diam.Conn, peerRealm datatype.DiameterIdentity) *Settings {
+	if f, ok := sm.resolver.Load().(SettingsFunc); ok && f != nil {
+		if cfg := f(c, peerRealm); cfg != nil {
+			return cfg
+		}
+	}
+	return sm.Settings()
 }
 
 // ServeDIAM implements the diam.Handler interface.
+//
+// Requests and answers of peers that passed the handshake are
+// accounted for in the Peer's outstanding requests. Requests over the
+// Peer's limits are answered by the state machine, see PeerLimits.
 func (sm *StateMachine) ServeDIAM(c diam.Conn, m *diam.Message) {
+	if p, ok := sm.peer(c); ok {
+		if m.Header.CommandFlags&diam.RequestFlag == diam.RequestFlag {
+			if !p.admit(m, sm.clock().Now()) {
+				return
+			}
+			p.serve(false)
+			defer p.serve(true)
+		} else {
+			defer p.answered(m.Header.HopByHopID)
+		}
+	}
 	sm.mux.ServeDIAM(c, m)
 }
 
@@ -73,13 +270,69 @@ func (sm *StateMachine) Handle(cmd string, handler diam.Handler) {
 // HandleFunc implements the diam.Handler interface.
 func (sm *StateMachine) HandleFunc(cmd string, handler diam.HandlerFunc) {
 	switch cmd {
-	case "CER", "CEA", "DWR", "DWA":
+	case "CER", "CEA", "DWR", "DWA", "DPA":
 		sm.Error(&diam.ErrorReport{
 			Error: fmt.Errorf("cannot overwrite %s command in the state machine", cmd),
 		})
 	default:
-		sm.mux.Handle(cmd, handshakeOK(handler))
+		sm.mux.Handle(cmd, sm.handshakeOK(handler))
+	}
+}
+
+// HandleApp registers the handler for the given command of the
+// application appID. See diam.ServeMux.HandleApp for details.
+func (sm *StateMachine) HandleApp(appID uint32, cmd string, handler diam.Handler) {
+	sm.HandleAppFunc(appID, cmd, handler.ServeDIAM)
+}
+
+// HandleAppFunc registers the handler function for the given command
+// of the application appID.
+func (sm *StateMachine) HandleAppFunc(appID uint32, cmd string, handler diam.HandlerFunc) {
+	sm.mux.HandleApp(appID, cmd, sm.handshakeOK(handler))
+}
+
+// HandleDefault registers the handler for requests that do not match
+// any other handler. See diam.ServeMux.HandleDefault for details.
+func (sm *StateMachine) HandleDefault(handler diam.Handler) {
+	sm.mux.HandleDefault(sm.handshakeOK(handler.ServeDIAM))
+}
+
+// HandleEgress registers an egress hook in the state machine. Hooks
+// are also called for CER/CEA, DWR/DWA and other messages sent by
+// the state machine itself.
+func (sm *StateMachine) HandleEgress(f diam.EgressFunc) {
+	sm.mux.HandleEgress(f)
+}
+
+// Egress implements the diam.EgressHandler interface.
+func (sm *StateMachine) Egress(c diam.Conn, m *diam.Message) error {
+	return sm.mux.Egress(c, m)
+}
+
+// addOrigin adds the Origin-Host and Origin-Realm AVPs from Settings
+// to answers without them, like the ones sent by diam.ServeMux for
+// unsupported applications.
+func (sm *StateMachine) addOrigin(c diam.Conn, m *diam.Message) error {
+	if m.Header.CommandFlags&diam.RequestFlag == diam.RequestFlag {
+		return nil
 	}
+	var host, realm bool
+	for _, a := range m.AVP {
+		switch a.Code {
+		case avp.OriginHost:
+			host = true
+		case avp.OriginRealm:
+			realm = true
+		}
+	}
+	cfg := sm.SettingsFor(c)
+	if !host {
+		m.NewAVP(avp.OriginHost, avp.Mbit, 0, cfg.OriginHost)
+	}
+	if !realm {
+		m.NewAVP(avp.OriginRealm, avp.Mbit, 0, cfg.OriginRealm)
+	}
+	return nil
 }
 
 // Error implements the diam.ErrorReporter interface.
@@ -97,6 +350,34 @@ func (sm *StateMachine) HandshakeNotify() <-chan diam.Conn {
 	return sm.hsNotifyc
 }
 
+// Events returns the EventBus of this StateMachine.
+func (sm *StateMachine) Events() *EventBus {
+	return sm.events
+}
+
+// peerUp adds the peer to the peer table and notifies about it
+// passing the handshake, then watches the connection to remove the
+// peer and publish the PeerDown event when it is closed.
+func (sm *StateMachine) peerUp(c diam.Conn, meta *smpeer.Metadata, limits *PeerLimits) {
+	sm.addPeer(c, meta, limits)
+	sm.savePeer(c, meta)
+	select {
+	case sm.hsNotifyc <- c:
+	default:
+	}
+	sm.events.Publish(&Event{Type: PeerUp, Conn: c, Peer: meta})
+	go func() {
+		<-c.Done()
+		sm.removePeer(c)
+		sm.events.Publish(&Event{
+			Type:  PeerDown,
+			Conn:  c,
+			Peer:  meta,
+			Error: c.Err(),
+		})
+	}()
+}
+
 // The HandshakeNotifier interface is implemented by Handlers
 // that allow detecting peers that have passed the CER/CEA
 // handshake.
@@ -106,14 +387,25 @@ type HandshakeNotifier interface {
 	HandshakeNotify() <-chan diam.Conn
 }
 
+// handshakeFailed publishes the HandshakeFailed event.
+func (sm *StateMachine) handshakeFailed(c diam.Conn, m *diam.Message, err error) {
+	sm.events.Publish(&Event{
+		Type:    HandshakeFailed,
+		Conn:    c,
+		Message: m,
+		Error:   err,
+	})
+}
+
 // handshakeOK is a wrapper for state machine handlers that only
 // calls the designated handler function if the peer has passed the
-// CER/CEA handshake.
-type handshakeOK diam.HandlerFunc
-
-// ServeDIAM implements the diam.Handler interface.
-func (f handshakeOK) ServeDIAM(c diam.Conn, m *diam.Message) {
-	if _, ok := smpeer.FromContext(c.Context()); ok {
-		f(c, m)
+// CER/CEA handshake. Other messages are dropped.
+func (sm *StateMachine) handshakeOK(h diam.HandlerFunc) diam.HandlerFunc {
+	return func(c diam.Conn, m *diam.Message) {
+		if _, ok := smpeer.FromContext(c.Context()); ok {
+			h(c, m)
+			return
+		}
+		sm.events.Publish(&Event{Type: MessageDropped, Conn: c, Message: m})
 	}
 }
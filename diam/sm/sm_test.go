@@ -13,6 +13,7 @@ import (
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/diamtest"
 	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
 )
 
 func testResultCode(m *diam.Message, want uint32) bool {
@@ -142,3 +143,214 @@ func TestStateMachine(t *testing.T) {
 		t.Fatal("No DWR message received")
 	}
 }
+
+func TestStateMachine_Reload(t *testing.T) {
+	sm := New(serverSettings)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	reloaded := *serverSettings
+	reloaded.OriginHost = "reloaded"
+	sm.Reload(&reloaded)
+	if sm.Settings() != &reloaded {
+		t.Fatal("Settings were not reloaded")
+	}
+	mc := make(chan *diam.Message, 1)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.HandleFunc("ALL", func(c diam.Conn, m *diam.Message) {})
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	cli.Handler.mux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	if _, err = cli.makeDWR(c, 1).WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		a, err := m.FindAVP(avp.OriginHost, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := a.Data.(datatype.DiameterIdentity); v != "reloaded" {
+			t.Fatalf("Unexpected Origin-Host. Want reloaded, have %s", v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DWA")
+	}
+}
+
+func TestStateMachine_ResolveSettings(t *testing.T) {
+	sm := New(serverSettings)
+	tenant := *serverSettings
+	tenant.OriginHost = "tenant"
+	tenant.OriginRealm = "tenant.realm"
+	sm.ResolveSettings(func(c diam.Conn, realm datatype.DiameterIdentity) *Settings {
+		if realm == "tenant.realm" {
+			return &tenant
+		}
+		return nil
+	})
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	for _, want := range []struct {
+		realm datatype.DiameterIdentity
+		host  datatype.DiameterIdentity
+	}{
+		{"test", serverSettings.OriginHost},
+		{"tenant.realm", "tenant"},
+	} {
+		settings := *clientSettings
+		settings.OriginRealm = want.realm
+		mc := make(chan *diam.Message, 1)
+		cli := &Client{
+			Handler: New(&settings),
+			AcctApplicationID: []*diam.AVP{
+				diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+			},
+		}
+		c, err := cli.Dial(srv.Addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		meta, _ := smpeer.FromContext(c.Context())
+		if meta.OriginHost != want.host {
+			t.Fatalf("Unexpected Origin-Host in CEA. Want %s, have %s", want.host, meta.OriginHost)
+		}
+		cli.Handler.mux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+			mc <- m
+		})
+		if _, err = cli.makeDWR(c, 1).WriteTo(c); err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case m := <-mc:
+			a, err := m.FindAVP(avp.OriginHost, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v := a.Data.(datatype.DiameterIdentity); v != want.host {
+				t.Fatalf("Unexpected Origin-Host in DWA. Want %s, have %s", want.host, v)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for DWA")
+		}
+		c.Close()
+	}
+}
+
+func TestStateMachine_UseStore(t *testing.T) {
+	store := smpeer.NewMemoryStore()
+	sm := New(serverSettings)
+	sm.UseStore(store)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	events := sm.Events().Subscribe(10)
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case e := <-events:
+		if e.Type != PeerUp {
+			t.Fatalf("Unexpected event. Want PeerUp, have %s", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for handshake")
+	}
+	if meta, ok := store.Load("cli"); !ok || !meta.Supports(0) {
+		t.Fatalf("Unexpected metadata in the store: %#v", meta)
+	}
+	c.Close()
+	select {
+	case e := <-events:
+		if e.Type != PeerDown {
+			t.Fatalf("Unexpected event. Want PeerDown, have %s", e.Type)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for disconnection")
+	}
+
+	// A new state machine knows the peer from the store.
+	sm = New(serverSettings)
+	if _, ok := sm.PeerMetadata("cli"); ok {
+		t.Fatal("Unexpected metadata without store")
+	}
+	sm.UseStore(store)
+	if meta, ok := sm.PeerMetadata("cli"); !ok || meta.OriginHost != "cli" {
+		t.Fatalf("Unexpected metadata: %#v", meta)
+	}
+}
+
+func TestStateMachine_ApplicationUnsupported(t *testing.T) {
+	sm := New(serverSettings)
+	events := sm.Events().Subscribe(10)
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	newCCR := func() *diam.Message {
+		m := diam.NewRequest(diam.CreditControl, 4, dict.Default)
+		m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+		return m
+	}
+
+	// Before the handshake, the request is dropped.
+	mc := make(chan *diam.Message, 1)
+	mux := diam.NewServeMux()
+	mux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	raw, err := diam.Dial(srv.Addr, mux, dict.Default)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer raw.Close()
+	if _, err = newCCR().WriteTo(raw); err != nil {
+		t.Fatal(err)
+	}
+	waitEvent(t, events, MessageDropped)
+	select {
+	case m := <-mc:
+		t.Fatalf("Unexpected answer before the handshake:\n%s", m)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// After the handshake, it is answered with 3007.
+	cli := &Client{
+		Handler: New(clientSettings),
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(0)),
+		},
+	}
+	cli.Handler.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	c, err := cli.Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if _, err = newCCR().WriteTo(c); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case m := <-mc:
+		if !testResultCode(m, diam.ApplicationUnsupported) {
+			t.Fatalf("Unexpected answer:\n%s", m)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for CCA")
+	}
+}
@@ -11,17 +11,79 @@ import (
 	"github.com/ibrohimislam/go-diameter/diam/dict"
 )
 
+// RelayApplicationID is the application ID advertised by relay agents,
+// which support all applications. See RFC 6733 section 2.4 for details.
+const RelayApplicationID uint32 = 0xffffffff
+
 // Application validates accounting, auth, and vendor specific application IDs.
 type Application struct {
 	AcctApplicationID           []*diam.AVP
 	AuthApplicationID           []*diam.AVP
 	VendorSpecificApplicationID []*diam.AVP
-	id                          []uint32 // List of supported application IDs.
+
+	// Local is the list of application IDs supported locally. When
+	// set, the applications are negotiated: the ones not in Local
+	// are left out, and Parse only fails when no application is in
+	// common. When unset, all applications must exist in the
+	// dictionary.
+	Local []uint32
+
+	id        []uint32 // List of supported application IDs.
+	uncommon  *diam.AVP
+	uncommonE error
 }
 
 // Parse ensures all acct or auth applications in the CE
-// exist in this server's dictionary.
+// exist in this server's dictionary, or negotiates the applications
+// in common with Local.
 func (app *Application) Parse(d *dict.Parser) (failedAVP *diam.AVP, err error) {
+	failedAVP, err = app.parse(d)
+	if e, ok := err.(*ErrNoCommonApplication); ok {
+		app.describe(d, e)
+	}
+	return failedAVP, err
+}
+
+// describe adds the applications of the peer and the local ones to
+// the error.
+func (app *Application) describe(d *dict.Parser, e *ErrNoCommonApplication) {
+	e.PeerApplications, e.VendorIDs = advertised(nil, nil, app.AcctApplicationID)
+	e.PeerApplications, e.VendorIDs = advertised(e.PeerApplications, e.VendorIDs, app.AuthApplicationID)
+	e.PeerApplications, e.VendorIDs = advertised(e.PeerApplications, e.VendorIDs, app.VendorSpecificApplicationID)
+	if app.Local != nil {
+		e.LocalApplications = app.Local
+		return
+	}
+	for _, a := range d.Apps() {
+		if !hasApplication(e.LocalApplications, a.ID) {
+			e.LocalApplications = append(e.LocalApplications, a.ID)
+		}
+	}
+}
+
+// advertised appends the application ids and vendor ids in avps,
+// including the ones embedded in Vendor-Specific-Application-Id.
+func advertised(ids, vendors []uint32, avps []*diam.AVP) ([]uint32, []uint32) {
+	for _, a := range avps {
+		switch a.Code {
+		case avp.AcctApplicationID, avp.AuthApplicationID:
+			if v, ok := a.Data.(datatype.Unsigned32); ok {
+				ids = append(ids, uint32(v))
+			}
+		case avp.VendorID:
+			if v, ok := a.Data.(datatype.Unsigned32); ok {
+				vendors = append(vendors, uint32(v))
+			}
+		case avp.VendorSpecificApplicationID:
+			if g, ok := a.Data.(*diam.GroupedAVP); ok {
+				ids, vendors = advertised(ids, vendors, g.AVP)
+			}
+		}
+	}
+	return ids, vendors
+}
+
+func (app *Application) parse(d *dict.Parser) (failedAVP *diam.AVP, err error) {
 	failedAVP, err = app.validateAll(d, avp.AcctApplicationID, app.AcctApplicationID)
 	if err != nil {
 		return failedAVP, err
@@ -39,6 +101,9 @@ func (app *Application) Parse(d *dict.Parser) (failedAVP *diam.AVP, err error) {
 		}
 	}
 	if app.ID() == nil {
+		if app.uncommon != nil {
+			return app.uncommon, app.uncommonE
+		}
 		return nil, ErrMissingApplication
 	}
 	return nil, nil
@@ -96,16 +161,29 @@ func (app *Application) validate(d *dict.Parser, appType uint32, appAVP *diam.AV
 		return appAVP, &ErrUnexpectedAVP{appAVP}
 	}
 	id := uint32(appID)
-	if id == 0xffffffff { // relay application id
+	if id == RelayApplicationID {
+		// The peer is a relay and supports all applications.
+		app.id = append(app.id, id)
+		return nil, nil
+	}
+	if app.Local != nil {
+		// A local relay supports all applications of the peer.
+		if !hasApplication(app.Local, id) && !hasApplication(app.Local, RelayApplicationID) {
+			if app.uncommon == nil {
+				app.uncommon = appAVP
+				app.uncommonE = &ErrNoCommonApplication{ID: id, Type: typ}
+			}
+			return nil, nil
+		}
 		app.id = append(app.id, id)
 		return nil, nil
 	}
 	avp, err := d.App(id)
 	if err != nil {
-		return appAVP, &ErrNoCommonApplication{id, typ}
+		return appAVP, &ErrNoCommonApplication{ID: id, Type: typ}
 	}
 	if len(avp.Type) > 0 && avp.Type != typ {
-		return appAVP, &ErrNoCommonApplication{id, typ}
+		return appAVP, &ErrNoCommonApplication{ID: id, Type: typ}
 	}
 	app.id = append(app.id, id)
 	return nil, nil
@@ -116,3 +194,25 @@ func (app *Application) validate(d *dict.Parser, appType uint32, appAVP *diam.AV
 func (app *Application) ID() []uint32 {
 	return app.id
 }
+
+func hasApplication(ids []uint32, id uint32) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// supportedVendors validates the Supported-Vendor-Id AVPs and returns
+// their values.
+func supportedVendors(avps []*diam.AVP) (ids []uint32, failedAVP *diam.AVP, err error) {
+	for _, a := range avps {
+		v, ok := a.Data.(datatype.Unsigned32)
+		if a.Code != avp.SupportedVendorID || !ok {
+			return nil, a, &ErrUnexpectedAVP{a}
+		}
+		ids = append(ids, uint32(v))
+	}
+	return ids, nil, nil
+}
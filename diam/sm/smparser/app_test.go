@@ -54,3 +54,62 @@ func TestUnexpectedAVP_BadGroup(t *testing.T) {
 		t.Fatalf("Unexpected failed avp. Want %q, have %q", a, failedAVP)
 	}
 }
+
+func TestApplication_Local(t *testing.T) {
+	app := &Application{
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001)),
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(9999)),
+		},
+		Local: []uint32{1001},
+	}
+	if _, err := app.Parse(dict.Default); err != nil {
+		t.Fatal(err)
+	}
+	if ids := app.ID(); len(ids) != 1 || ids[0] != 1001 {
+		t.Fatalf("Unexpected applications. Want [1001], have %v", ids)
+	}
+}
+
+func TestApplication_Local_NoCommonApplication(t *testing.T) {
+	a := diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, datatype.Unsigned32(1002))
+	app := &Application{
+		AuthApplicationID: []*diam.AVP{a},
+		Local:             []uint32{1001},
+	}
+	failedAVP, err := app.Parse(dict.Default)
+	if _, ok := err.(*ErrNoCommonApplication); !ok {
+		t.Fatal("Unexpected error:", err)
+	}
+	if failedAVP != a {
+		t.Fatalf("Unexpected failed avp. Want %q, have %q", a, failedAVP)
+	}
+}
+
+func TestApplication_Relay(t *testing.T) {
+	relay := datatype.Unsigned32(RelayApplicationID)
+	app := &Application{
+		AuthApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AuthApplicationID, avp.Mbit, 0, relay),
+		},
+		Local: []uint32{1001},
+	}
+	if _, err := app.Parse(dict.Default); err != nil {
+		t.Fatal(err)
+	}
+	if ids := app.ID(); len(ids) != 1 || ids[0] != RelayApplicationID {
+		t.Fatalf("Unexpected applications. Want [%d], have %v", RelayApplicationID, ids)
+	}
+	app = &Application{
+		AcctApplicationID: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(9999)),
+		},
+		Local: []uint32{RelayApplicationID},
+	}
+	if _, err := app.Parse(dict.Default); err != nil {
+		t.Fatal(err)
+	}
+	if ids := app.ID(); len(ids) != 1 || ids[0] != 9999 {
+		t.Fatalf("Unexpected applications. Want [9999], have %v", ids)
+	}
+}
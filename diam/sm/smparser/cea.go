@@ -6,6 +6,7 @@ package smparser
 
 import (
 	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 )
 
@@ -15,25 +16,68 @@ type CEA struct {
 	ResultCode                  uint32                    `avp:"Result-Code"`
 	OriginHost                  datatype.DiameterIdentity `avp:"Origin-Host"`
 	OriginRealm                 datatype.DiameterIdentity `avp:"Origin-Realm"`
+	HostIPAddress               []*diam.AVP               `avp:"Host-IP-Address"`
+	VendorID                    uint32                    `avp:"Vendor-Id"`
+	ProductName                 datatype.UTF8String       `avp:"Product-Name"`
 	OriginStateID               uint32                    `avp:"Origin-State-Id"`
-	AcctApplicationID           []*diam.AVP               `avp:"Acct-Application-Id"`
+	ErrorMessage                datatype.UTF8String       `avp:"Error-Message"`
+	FailedAVP                   *diam.AVP                 `avp:"Failed-AVP"`
+	SupportedVendorID           []*diam.AVP               `avp:"Supported-Vendor-Id"`
 	AuthApplicationID           []*diam.AVP               `avp:"Auth-Application-Id"`
+	InbandSecurityID            *diam.AVP                 `avp:"Inband-Security-Id"`
+	AcctApplicationID           []*diam.AVP               `avp:"Acct-Application-Id"`
 	VendorSpecificApplicationID []*diam.AVP               `avp:"Vendor-Specific-Application-Id"`
-	appID                       []uint32                  // List of supported application IDs.
+	FirmwareRevision            uint32                    `avp:"Firmware-Revision"`
+	ExperimentalResult          *diam.AVP                 `avp:"Experimental-Result"`
+
+	// ExperimentalResultCode and ExperimentalVendorID are taken
+	// from the Experimental-Result AVP, if present.
+	ExperimentalResultCode uint32
+	ExperimentalVendorID   uint32
+
+	// FailedAVPs are the AVPs embedded in the Failed-AVP, if present.
+	FailedAVPs []*diam.AVP
+
+	// Local is the list of applications supported locally, see
+	// Application.Local for details.
+	Local []uint32
+
+	appID    []uint32 // List of negotiated application IDs.
+	vendorID []uint32 // List of supported vendor IDs.
 }
 
 // Parse parses and validates the given message.
+//
+// Answers with a failure result code are only checked for mandatory
+// AVPs, the applications they advertise are not validated. Use Success
+// to check the result.
 func (cea *CEA) Parse(m *diam.Message) (err error) {
+	if err = checkFlags(m, "CEA", false); err != nil {
+		return err
+	}
 	if err = m.Unmarshal(cea); err != nil {
 		return err
 	}
+	cea.parseExperimentalResult()
+	if cea.FailedAVP != nil {
+		if g, ok := cea.FailedAVP.Data.(*diam.GroupedAVP); ok {
+			cea.FailedAVPs = g.AVP
+		}
+	}
 	if err = cea.sanityCheck(); err != nil {
 		return err
 	}
+	if cea.vendorID, _, err = supportedVendors(cea.SupportedVendorID); err != nil {
+		return err
+	}
+	if !cea.Success() {
+		return nil
+	}
 	app := &Application{
 		AcctApplicationID:           cea.AcctApplicationID,
 		AuthApplicationID:           cea.AuthApplicationID,
 		VendorSpecificApplicationID: cea.VendorSpecificApplicationID,
+		Local:                       cea.Local,
 	}
 	if _, err := app.Parse(m.Dictionary()); err != nil {
 		return err
@@ -42,9 +86,31 @@ func (cea *CEA) Parse(m *diam.Message) (err error) {
 	return nil
 }
 
+func (cea *CEA) parseExperimentalResult() {
+	if cea.ExperimentalResult == nil {
+		return
+	}
+	g, ok := cea.ExperimentalResult.Data.(*diam.GroupedAVP)
+	if !ok {
+		return
+	}
+	for _, a := range g.AVP {
+		v, ok := a.Data.(datatype.Unsigned32)
+		if !ok {
+			continue
+		}
+		switch a.Code {
+		case avp.ExperimentalResultCode:
+			cea.ExperimentalResultCode = uint32(v)
+		case avp.VendorID:
+			cea.ExperimentalVendorID = uint32(v)
+		}
+	}
+}
+
 // sanityCheck ensures mandatory AVPs are present.
 func (cea *CEA) sanityCheck() error {
-	if cea.ResultCode == 0 {
+	if cea.ResultCode == 0 && cea.ExperimentalResultCode == 0 {
 		return ErrMissingResultCode
 	}
 	if len(cea.OriginHost) == 0 {
@@ -56,7 +122,33 @@ func (cea *CEA) sanityCheck() error {
 	return nil
 }
 
-// Applications return a list of supported Application IDs.
+// Code returns the Result-Code, or the Experimental-Result-Code when
+// the answer has no Result-Code.
+func (cea *CEA) Code() uint32 {
+	if cea.ResultCode != 0 {
+		return cea.ResultCode
+	}
+	return cea.ExperimentalResultCode
+}
+
+// Experimental returns true if the result of the answer is in the
+// Experimental-Result AVP instead of Result-Code.
+func (cea *CEA) Experimental() bool {
+	return cea.ResultCode == 0 && cea.ExperimentalResultCode != 0
+}
+
+// Success returns true if the answer has the DIAMETER_SUCCESS (2001)
+// result code.
+func (cea *CEA) Success() bool {
+	return cea.Code() == diam.Success
+}
+
+// Applications return the list of negotiated Application IDs.
 func (cea *CEA) Applications() []uint32 {
 	return cea.appID
 }
+
+// SupportedVendors returns the list of Supported-Vendor-Id values.
+func (cea *CEA) SupportedVendors() []uint32 {
+	return cea.vendorID
+}
@@ -54,7 +54,7 @@ func TestCEA_MissingOriginRealm(t *testing.T) {
 }
 
 func TestCEA_MissingApplication(t *testing.T) {
-	m := diam.NewMessage(diam.CapabilitiesExchange, 0, 0, 0, 0, dict.Default)
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
 	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
 	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
 	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
@@ -69,11 +69,11 @@ func TestCEA_MissingApplication(t *testing.T) {
 }
 
 func TestCEA_NoCommonApplication(t *testing.T) {
-	m := diam.NewMessage(diam.CapabilitiesExchange, 0, 0, 0, 0, dict.Default)
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
 	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
 	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
 	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
-	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(2))
+	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1000))
 	cer := new(CER)
 	_, err := cer.Parse(m)
 	if err == nil {
@@ -83,10 +83,10 @@ func TestCEA_NoCommonApplication(t *testing.T) {
 	if !ok {
 		t.Fatal("Unexpected error:", err.Error())
 	}
-	if appErr.ID != 2 {
-		t.Fatalf("Unexpected app ID. Want 2, have %d", appErr.ID)
+	if appErr.ID != 1000 {
+		t.Fatalf("Unexpected app ID. Want 1000, have %d", appErr.ID)
 	}
-	if !strings.Contains(appErr.Error(), "acct application 2") {
+	if !strings.Contains(appErr.Error(), "acct application 1000") {
 		t.Fatalf("Unexpected error message: %s", appErr)
 	}
 }
@@ -136,3 +136,67 @@ func TestCEA(t *testing.T) {
 		}
 	}
 }
+
+func TestCEA_ExperimentalResult(t *testing.T) {
+	m := diam.NewMessage(diam.CapabilitiesExchange, 0, 0, 0, 0, dict.Default)
+	m.NewAVP(avp.ExperimentalResult, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.VendorID, avp.Mbit, 0, datatype.Unsigned32(10415)),
+			diam.NewAVP(avp.ExperimentalResultCode, avp.Mbit, 0, datatype.Unsigned32(5001)),
+		},
+	})
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	cea := new(CEA)
+	if err := cea.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+	if !cea.Experimental() {
+		t.Fatal("Unexpected CEA without Experimental-Result")
+	}
+	if cea.Success() {
+		t.Fatal("Unexpected successful CEA")
+	}
+	if cea.Code() != 5001 {
+		t.Fatalf("Unexpected code. Want 5001, have %d", cea.Code())
+	}
+	if cea.ExperimentalVendorID != 10415 {
+		t.Fatalf("Unexpected vendor. Want 10415, have %d", cea.ExperimentalVendorID)
+	}
+}
+
+func TestCEA_FailedAVP(t *testing.T) {
+	m := diam.NewMessage(diam.CapabilitiesExchange, 0, 0, 0, 0, dict.Default)
+	m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.NoCommonApplication))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.ErrorMessage, 0, 0, datatype.UTF8String("no common application"))
+	m.NewAVP(avp.FailedAVP, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1000)),
+		},
+	})
+	cea := new(CEA)
+	if err := cea.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+	if cea.Success() || cea.Experimental() {
+		t.Fatal("Unexpected result in CEA")
+	}
+	if cea.ErrorMessage != "no common application" {
+		t.Fatalf("Unexpected Error-Message. Have %q", cea.ErrorMessage)
+	}
+	if len(cea.FailedAVPs) != 1 || cea.FailedAVPs[0].Code != avp.AcctApplicationID {
+		t.Fatalf("Unexpected Failed-AVP contents: %v", cea.FailedAVPs)
+	}
+}
+
+func TestCEA_UnexpectedRole(t *testing.T) {
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success))
+	cea := new(CEA)
+	err := cea.Parse(m)
+	if e, ok := err.(*ErrUnexpectedRole); !ok || e.Request {
+		t.Fatal("Unexpected error:", err)
+	}
+}
@@ -6,6 +6,7 @@ package smparser
 
 import (
 	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 )
 
@@ -14,14 +15,36 @@ import (
 type CER struct {
 	OriginHost                  datatype.DiameterIdentity `avp:"Origin-Host"`
 	OriginRealm                 datatype.DiameterIdentity `avp:"Origin-Realm"`
+	HostIPAddress               []*diam.AVP               `avp:"Host-IP-Address"`
+	VendorID                    uint32                    `avp:"Vendor-Id"`
+	ProductName                 datatype.UTF8String       `avp:"Product-Name"`
 	OriginStateID               *diam.AVP                 `avp:"Origin-State-Id"`
+	SupportedVendorID           []*diam.AVP               `avp:"Supported-Vendor-Id"`
 	InbandSecurityID            *diam.AVP                 `avp:"Inband-Security-Id"`
 	AcctApplicationID           []*diam.AVP               `avp:"Acct-Application-Id"`
 	AuthApplicationID           []*diam.AVP               `avp:"Auth-Application-Id"`
 	VendorSpecificApplicationID []*diam.AVP               `avp:"Vendor-Specific-Application-Id"`
-	appID                       []uint32                  // List of supported application IDs.
+	FirmwareRevision            uint32                    `avp:"Firmware-Revision"`
+	appID                       []uint32                  // List of negotiated application IDs.
+	vendorID                    []uint32                  // List of supported vendor IDs.
+
+	// Local is the list of applications supported locally, see
+	// Application.Local for details.
+	Local []uint32
+
+	// InbandSecurity is the list of Inband-Security-Id values
+	// accepted locally. When unset, only NoInbandSecurity is
+	// accepted. A CER without Inband-Security-Id is the same as
+	// one with NoInbandSecurity.
+	InbandSecurity []datatype.Unsigned32
 }
 
+// Inband-Security-Id values. See RFC 6733 section 6.10 for details.
+const (
+	NoInbandSecurity  = datatype.Unsigned32(0)
+	TLSInbandSecurity = datatype.Unsigned32(1)
+)
+
 // Parse parses and validates the given message, and returns nil when
 // all AVPs are ok, and all accounting or authentication applications
 // in the CER match the applications in our dictionary. If one or more
@@ -29,23 +52,29 @@ type CER struct {
 // error. If all mandatory AVPs are present but no common application
 // is found, then it returns the failedAVP (with the application that
 // we don't support in our dictionary) and an error. Another cause
-// for error is the presence of Inband Security, we don't support that.
+// for error is an Inband-Security-Id not accepted locally, see
+// InbandSecurity.
 func (cer *CER) Parse(m *diam.Message) (failedAVP *diam.AVP, err error) {
+	if err = checkFlags(m, "CER", true); err != nil {
+		return nil, err
+	}
 	if err = m.Unmarshal(cer); err != nil {
 		return nil, err
 	}
 	if err = cer.sanityCheck(); err != nil {
 		return nil, err
 	}
-	if cer.InbandSecurityID != nil {
-		if v := cer.InbandSecurityID.Data.(datatype.Unsigned32); v != 0 {
-			return cer.InbandSecurityID, ErrNoCommonSecurity
-		}
+	if cer.vendorID, failedAVP, err = supportedVendors(cer.SupportedVendorID); err != nil {
+		return failedAVP, err
+	}
+	if failedAVP, err = cer.checkInbandSecurity(); err != nil {
+		return failedAVP, err
 	}
 	app := &Application{
 		AcctApplicationID:           cer.AcctApplicationID,
 		AuthApplicationID:           cer.AuthApplicationID,
 		VendorSpecificApplicationID: cer.VendorSpecificApplicationID,
+		Local:                       cer.Local,
 	}
 	if failedAVP, err = app.Parse(m.Dictionary()); err != nil {
 		return failedAVP, err
@@ -54,6 +83,32 @@ func (cer *CER) Parse(m *diam.Message) (failedAVP *diam.AVP, err error) {
 	return nil, nil
 }
 
+// checkInbandSecurity ensures the Inband-Security-Id of the CER is
+// accepted locally. When it is not, the AVP is returned as failedAVP.
+func (cer *CER) checkInbandSecurity() (failedAVP *diam.AVP, err error) {
+	v := NoInbandSecurity
+	if cer.InbandSecurityID != nil {
+		id, ok := cer.InbandSecurityID.Data.(datatype.Unsigned32)
+		if !ok {
+			return cer.InbandSecurityID, &ErrUnexpectedAVP{cer.InbandSecurityID}
+		}
+		v = id
+	}
+	accepted := cer.InbandSecurity
+	if accepted == nil {
+		accepted = []datatype.Unsigned32{NoInbandSecurity}
+	}
+	for _, id := range accepted {
+		if id == v {
+			return nil, nil
+		}
+	}
+	if cer.InbandSecurityID == nil {
+		cer.InbandSecurityID = diam.NewAVP(avp.InbandSecurityID, avp.Mbit, 0, v)
+	}
+	return cer.InbandSecurityID, ErrNoCommonSecurity
+}
+
 // sanityCheck ensures mandatory AVPs are present.
 func (cer *CER) sanityCheck() error {
 	if len(cer.OriginHost) == 0 {
@@ -65,7 +120,12 @@ func (cer *CER) sanityCheck() error {
 	return nil
 }
 
-// Applications return a list of supported Application IDs.
+// Applications return the list of negotiated Application IDs.
 func (cer *CER) Applications() []uint32 {
 	return cer.appID
 }
+
+// SupportedVendors returns the list of Supported-Vendor-Id values.
+func (cer *CER) SupportedVendors() []uint32 {
+	return cer.vendorID
+}
@@ -61,7 +61,7 @@ func TestCER_NoCommonApplication(t *testing.T) {
 	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
 	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
 	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
-	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(2))
+	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1000))
 	cer := new(CER)
 	_, err := cer.Parse(m)
 	if err == nil {
@@ -71,10 +71,10 @@ func TestCER_NoCommonApplication(t *testing.T) {
 	if !ok {
 		t.Fatal("Unexpected error:", err.Error())
 	}
-	if appErr.ID != 2 {
-		t.Fatalf("Unexpected app ID. Want 2, have %d", appErr.ID)
+	if appErr.ID != 1000 {
+		t.Fatalf("Unexpected app ID. Want 1000, have %d", appErr.ID)
 	}
-	if !strings.Contains(appErr.Error(), "acct application 2") {
+	if !strings.Contains(appErr.Error(), "acct application 1000") {
 		t.Fatalf("Unexpected error message: %s", appErr)
 	}
 }
@@ -302,3 +302,45 @@ func TestCER_FailedVSAuthAppID(t *testing.T) {
 		t.Fatalf("Unexpected app ID. Want 1000, have %d", appErr.ID)
 	}
 }
+
+func TestCER_UnexpectedRole(t *testing.T) {
+	m := diam.NewMessage(diam.CapabilitiesExchange, 0, 0, 0, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	cer := new(CER)
+	_, err := cer.Parse(m)
+	if e, ok := err.(*ErrUnexpectedRole); !ok || !e.Request {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestCER_Proxiable(t *testing.T) {
+	m := diam.NewMessage(diam.CapabilitiesExchange, diam.RequestFlag|diam.ProxiableFlag, 0, 0, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	cer := new(CER)
+	_, err := cer.Parse(m)
+	if _, ok := err.(*ErrProxiable); !ok {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestCER_InbandSecurity(t *testing.T) {
+	m := diam.NewRequest(diam.CapabilitiesExchange, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.AcctApplicationID, avp.Mbit, 0, datatype.Unsigned32(1001))
+	cer := &CER{InbandSecurity: []datatype.Unsigned32{TLSInbandSecurity}}
+	failedAVP, err := cer.Parse(m)
+	if err != ErrNoCommonSecurity {
+		t.Fatal("Unexpected error:", err)
+	}
+	if failedAVP == nil || failedAVP.Code != avp.InbandSecurityID {
+		t.Fatalf("Unexpected failed avp: %v", failedAVP)
+	}
+	m.NewAVP(avp.InbandSecurityID, avp.Mbit, 0, TLSInbandSecurity)
+	cer = &CER{InbandSecurity: []datatype.Unsigned32{TLSInbandSecurity}}
+	if _, err = cer.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -4,27 +4,8 @@
 
 package smparser
 
-import (
-	"bytes"
-
-	"github.com/ibrohimislam/go-diameter/diam/dict"
-)
+import testdict "github.com/ibrohimislam/go-diameter/diam/diamtest/dict"
 
 func init() {
-	dict.Default.Load(bytes.NewReader([]byte(acctDictionary)))
-	dict.Default.Load(bytes.NewReader([]byte(authDictionary)))
+	testdict.LoadDefault()
 }
-
-var acctDictionary = `<?xml version="1.0" encoding="UTF-8"?>
-<diameter>
-	<application id="1001" type="acct">
-	</application>
-</diameter>
-`
-
-var authDictionary = `<?xml version="1.0" encoding="UTF-8"?>
-<diameter>
-	<application id="1002" type="auth">
-	</application>
-</diameter>
-`
@@ -0,0 +1,46 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// DPA is a Disconnect-Peer-Answer message.
+// See RFC 6733 section 5.4.2 for details.
+type DPA struct {
+	ResultCode   uint32                    `avp:"Result-Code"`
+	OriginHost   datatype.DiameterIdentity `avp:"Origin-Host"`
+	OriginRealm  datatype.DiameterIdentity `avp:"Origin-Realm"`
+	ErrorMessage datatype.UTF8String       `avp:"Error-Message"`
+	FailedAVP    *diam.AVP                 `avp:"Failed-AVP"`
+}
+
+// Parse parses and validates the given message, and returns nil when
+// all mandatory AVPs are present.
+func (dpa *DPA) Parse(m *diam.Message) error {
+	if err := checkFlags(m, "DPA", false); err != nil {
+		return err
+	}
+	if err := m.Unmarshal(dpa); err != nil {
+		return err
+	}
+	return dpa.sanityCheck()
+}
+
+// sanityCheck ensures all mandatory AVPs are present.
+func (dpa *DPA) sanityCheck() error {
+	if dpa.ResultCode == 0 {
+		return ErrMissingResultCode
+	}
+	if len(dpa.OriginHost) == 0 {
+		return ErrMissingOriginHost
+	}
+	if len(dpa.OriginRealm) == 0 {
+		return ErrMissingOriginRealm
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// Disconnect-Cause values. See RFC 6733 section 5.4.3 for details.
+const (
+	Rebooting            = datatype.Enumerated(0)
+	Busy                 = datatype.Enumerated(1)
+	DoNotWantToTalkToYou = datatype.Enumerated(2)
+)
+
+// DPR is a Disconnect-Peer-Request message.
+// See RFC 6733 section 5.4.1 for details.
+type DPR struct {
+	OriginHost      datatype.DiameterIdentity `avp:"Origin-Host"`
+	OriginRealm     datatype.DiameterIdentity `avp:"Origin-Realm"`
+	DisconnectCause datatype.Enumerated       `avp:"Disconnect-Cause"`
+}
+
+// Parse parses and validates the given message, and returns nil when
+// all mandatory AVPs are present and the Disconnect-Cause is valid.
+func (dpr *DPR) Parse(m *diam.Message) error {
+	if err := checkFlags(m, "DPR", true); err != nil {
+		return err
+	}
+	if err := m.Unmarshal(dpr); err != nil {
+		return err
+	}
+	if err := dpr.sanityCheck(); err != nil {
+		return err
+	}
+	for _, a := range m.AVP {
+		if a.Code == avp.DisconnectCause {
+			return dpr.validCause()
+		}
+	}
+	return ErrMissingDisconnectCause
+}
+
+// sanityCheck ensures all mandatory AVPs are present.
+func (dpr *DPR) sanityCheck() error {
+	if len(dpr.OriginHost) == 0 {
+		return ErrMissingOriginHost
+	}
+	if len(dpr.OriginRealm) == 0 {
+		return ErrMissingOriginRealm
+	}
+	return nil
+}
+
+func (dpr *DPR) validCause() error {
+	switch dpr.DisconnectCause {
+	case Rebooting, Busy, DoNotWantToTalkToYou:
+		return nil
+	}
+	return &ErrInvalidDisconnectCause{Value: dpr.DisconnectCause}
+}
@@ -0,0 +1,64 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import (
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+)
+
+func TestDPR_MissingDisconnectCause(t *testing.T) {
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	dpr := new(DPR)
+	if err := dpr.Parse(m); err != ErrMissingDisconnectCause {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestDPR_InvalidDisconnectCause(t *testing.T) {
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, datatype.Enumerated(9))
+	dpr := new(DPR)
+	err := dpr.Parse(m)
+	if e, ok := err.(*ErrInvalidDisconnectCause); !ok || e.Value != 9 {
+		t.Fatal("Unexpected error:", err)
+	}
+}
+
+func TestDPR_OK(t *testing.T) {
+	m := diam.NewRequest(diam.DisconnectPeer, 0, dict.Default)
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	m.NewAVP(avp.DisconnectCause, avp.Mbit, 0, Busy)
+	dpr := new(DPR)
+	if err := dpr.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+	if dpr.DisconnectCause != Busy {
+		t.Fatalf("Unexpected Disconnect-Cause. Want %d, have %d", Busy, dpr.DisconnectCause)
+	}
+}
+
+func TestDPA(t *testing.T) {
+	m := diam.NewMessage(diam.DisconnectPeer, 0, 0, 0, 0, dict.Default)
+	m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success))
+	dpa := new(DPA)
+	if err := dpa.Parse(m); err != ErrMissingOriginHost {
+		t.Fatal("Unexpected error:", err)
+	}
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
+	if err := dpa.Parse(m); err != nil {
+		t.Fatal(err)
+	}
+}
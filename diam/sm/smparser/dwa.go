@@ -4,19 +4,44 @@
 
 package smparser
 
-import "github.com/ibrohimislam/go-diameter/diam"
+import (
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
 
 // DWA is a Device-Watchdog-Answer message.
 // See RFC 6733 section 5.5.2 for details.
 type DWA struct {
-	ResultCode    uint32 `avp:"Result-Code"`
-	OriginStateID uint32 `avp:"Origin-State-Id"`
+	ResultCode    uint32                    `avp:"Result-Code"`
+	OriginHost    datatype.DiameterIdentity `avp:"Origin-Host"`
+	OriginRealm   datatype.DiameterIdentity `avp:"Origin-Realm"`
+	ErrorMessage  datatype.UTF8String       `avp:"Error-Message"`
+	FailedAVP     *diam.AVP                 `avp:"Failed-AVP"`
+	OriginStateID uint32                    `avp:"Origin-State-Id"`
 }
 
-// Parse parses the given message.
+// Parse parses and validates the given message, and returns nil when
+// all mandatory AVPs are present.
 func (dwa *DWA) Parse(m *diam.Message) error {
+	if err := checkFlags(m, "DWA", false); err != nil {
+		return err
+	}
 	if err := m.Unmarshal(dwa); err != nil {
 		return err
 	}
+	return dwa.sanityCheck()
+}
+
+// sanityCheck ensures all mandatory AVPs are present.
+func (dwa *DWA) sanityCheck() error {
+	if dwa.ResultCode == 0 {
+		return ErrMissingResultCode
+	}
+	if len(dwa.OriginHost) == 0 {
+		return ErrMissingOriginHost
+	}
+	if len(dwa.OriginRealm) == 0 {
+		return ErrMissingOriginRealm
+	}
 	return nil
 }
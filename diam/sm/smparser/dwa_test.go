@@ -15,6 +15,8 @@ import (
 func TestDWA(t *testing.T) {
 	m := diam.NewMessage(diam.CapabilitiesExchange, 0, 0, 0, 0, nil)
 	m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success))
+	m.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("foobar"))
+	m.NewAVP(avp.OriginRealm, avp.Mbit, 0, datatype.DiameterIdentity("test"))
 	m.NewAVP(avp.OriginStateID, avp.Mbit, 0, datatype.Unsigned32(1))
 	dwa := new(DWA)
 	if err := dwa.Parse(m); err != nil {
@@ -28,3 +30,12 @@ func TestDWA(t *testing.T) {
 		t.Fatalf("Unexpected Result-Code. Want 1, have %d", dwa.OriginStateID)
 	}
 }
+
+func TestDWA_MissingOriginHost(t *testing.T) {
+	m := diam.NewMessage(diam.DeviceWatchdog, 0, 0, 0, 0, nil)
+	m.NewAVP(avp.ResultCode, avp.Mbit, 0, datatype.Unsigned32(diam.Success))
+	dwa := new(DWA)
+	if err := dwa.Parse(m); err != ErrMissingOriginHost {
+		t.Fatal("Unexpected error:", err)
+	}
+}
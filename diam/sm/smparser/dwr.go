@@ -20,9 +20,12 @@ type DWR struct {
 // Parse parses and validates the given message, and returns nil when
 // all AVPs are ok.
 func (dwr *DWR) Parse(m *diam.Message) error {
+	if err := checkFlags(m, "DWR", true); err != nil {
+		return err
+	}
 	err := m.Unmarshal(dwr)
 	if err != nil {
-		return nil
+		return err
 	}
 	if err = dwr.sanityCheck(); err != nil {
 		return err
@@ -9,6 +9,7 @@ import (
 	"fmt"
 
 	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
 )
 
 var (
@@ -31,22 +32,47 @@ var (
 	ErrMissingApplication = errors.New("missing application")
 
 	// ErrNoCommonSecurity is returned by Parse when
-	// the CER contains the Inband-Security-Id.
-	// We currently don't support that.
+	// the Inband-Security-Id of the CER is not accepted.
 	ErrNoCommonSecurity = errors.New("no common security")
+
+	// ErrMissingDisconnectCause is returned by Parse when
+	// the DPR does not contain a Disconnect-Cause AVP.
+	ErrMissingDisconnectCause = errors.New("missing Disconnect-Cause")
 )
 
+// ErrInvalidDisconnectCause is returned by Parse when the
+// Disconnect-Cause AVP of the DPR has an unknown value.
+type ErrInvalidDisconnectCause struct {
+	Value datatype.Enumerated
+}
+
+// Error implements the error interface.
+func (e *ErrInvalidDisconnectCause) Error() string {
+	return fmt.Sprintf("invalid Disconnect-Cause: %d", e.Value)
+}
+
 // ErrNoCommonApplication is returned by Parse when the
 // application IDs in the CER don't match the applications
 // defined in our dictionary.
+//
+// The error carries the applications on both sides of the
+// handshake, to diagnose interoperability failures.
 type ErrNoCommonApplication struct {
 	ID   uint32
 	Type string
+
+	PeerApplications  []uint32 // All applications advertised by the peer
+	LocalApplications []uint32 // All applications supported locally
+	VendorIDs         []uint32 // Vendor-Id of the peer's vendor specific applications
 }
 
 // Error implements the error interface.
 func (e *ErrNoCommonApplication) Error() string {
-	return fmt.Sprintf("%s application %d is not supported", e.Type, e.ID)
+	if e.PeerApplications == nil && e.LocalApplications == nil {
+		return fmt.Sprintf("%s application %d is not supported", e.Type, e.ID)
+	}
+	return fmt.Sprintf("%s application %d is not supported (peer applications %v, local applications %v, vendors %v)",
+		e.Type, e.ID, e.PeerApplications, e.LocalApplications, e.VendorIDs)
 }
 
 // ErrUnexpectedAVP is returned by Parse when the code of the AVP passed
@@ -60,3 +86,30 @@ type ErrUnexpectedAVP struct {
 func (e *ErrUnexpectedAVP) Error() string {
 	return fmt.Sprintf("unexpected AVP: %s", e.AVP)
 }
+
+// ErrUnexpectedRole is returned by Parse when the R (request) bit
+// of the message is not the expected for the command, e.g. an answer
+// is passed to the CER parser.
+type ErrUnexpectedRole struct {
+	Command string // Short name of the command, e.g. CER
+	Request bool   // Whether the command must be a request
+}
+
+// Error implements the error interface.
+func (e *ErrUnexpectedRole) Error() string {
+	if e.Request {
+		return fmt.Sprintf("%s must be a request", e.Command)
+	}
+	return fmt.Sprintf("%s must be an answer", e.Command)
+}
+
+// ErrProxiable is returned by Parse when the P (proxiable) bit is set
+// in a message that must not be proxied, like CER and DWR.
+type ErrProxiable struct {
+	Command string // Short name of the command, e.g. CER
+}
+
+// Error implements the error interface.
+func (e *ErrProxiable) Error() string {
+	return fmt.Sprintf("%s must not be proxiable", e.Command)
+}
@@ -0,0 +1,21 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smparser
+
+import "github.com/ibrohimislam/go-diameter/diam"
+
+// checkFlags ensures the message has the R bit set for requests, or
+// unset for answers, and does not have the P bit set. Base protocol
+// messages of the state machine are never proxiable.
+func checkFlags(m *diam.Message, cmd string, request bool) error {
+	flags := m.Header.CommandFlags
+	if (flags&diam.RequestFlag == diam.RequestFlag) != request {
+		return &ErrUnexpectedRole{Command: cmd, Request: request}
+	}
+	if flags&diam.ProxiableFlag == diam.ProxiableFlag {
+		return &ErrProxiable{Command: cmd}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpeer
+
+import (
+	"net"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// Capabilities is a snapshot of the capabilities exchange (CER/CEA)
+// with a peer: what was advertised locally, what the peer advertised
+// and the applications negotiated. It is meant for audit and
+// troubleshooting, and is encoded to JSON as is.
+type Capabilities struct {
+	Time         time.Time   `json:"time"`         // Time of the handshake
+	Initiator    bool        `json:"initiator"`    // True if we sent the CER
+	ResultCode   uint32      `json:"result_code"`  // Result-Code of the CEA
+	Local        *Advertised `json:"local"`        // What we advertised
+	Remote       *Advertised `json:"remote"`       // What the peer advertised
+	Applications []uint32    `json:"applications"` // Negotiated applications
+}
+
+// Advertised holds the capabilities advertised by one side of the
+// capabilities exchange, in a CER or CEA.
+type Advertised struct {
+	OriginHost                  string                `json:"origin_host"`
+	OriginRealm                 string                `json:"origin_realm"`
+	HostIPAddress               []string              `json:"host_ip_address,omitempty"`
+	VendorID                    uint32                `json:"vendor_id"`
+	ProductName                 string                `json:"product_name"`
+	OriginStateID               uint32                `json:"origin_state_id,omitempty"`
+	SupportedVendorID           []uint32              `json:"supported_vendor_id,omitempty"`
+	AuthApplicationID           []uint32              `json:"auth_application_id,omitempty"`
+	AcctApplicationID           []uint32              `json:"acct_application_id,omitempty"`
+	VendorSpecificApplicationID []VendorSpecificAppID `json:"vendor_specific_application_id,omitempty"`
+	InbandSecurityID            []uint32              `json:"inband_security_id,omitempty"`
+	FirmwareRevision            uint32                `json:"firmware_revision,omitempty"`
+}
+
+// VendorSpecificAppID is a Vendor-Specific-Application-Id AVP.
+type VendorSpecificAppID struct {
+	VendorID          uint32 `json:"vendor_id"`
+	AuthApplicationID uint32 `json:"auth_application_id,omitempty"`
+	AcctApplicationID uint32 `json:"acct_application_id,omitempty"`
+}
+
+// NewAdvertised returns the capabilities advertised in the CER or CEA
+// message m. AVPs with unexpected data types are ignored.
+func NewAdvertised(m *diam.Message) *Advertised {
+	adv := &Advertised{}
+	for _, a := range m.AVP {
+		switch a.Code {
+		case avp.OriginHost:
+			adv.OriginHost = identity(a)
+		case avp.OriginRealm:
+			adv.OriginRealm = identity(a)
+		case avp.HostIPAddress:
+			if v, ok := a.Data.(datatype.Address); ok {
+				adv.HostIPAddress = append(adv.HostIPAddress, net.IP(v).String())
+			}
+		case avp.VendorID:
+			adv.VendorID, _ = unsigned32(a)
+		case avp.ProductName:
+			if v, ok := a.Data.(datatype.UTF8String); ok {
+				adv.ProductName = string(v)
+			}
+		case avp.OriginStateID:
+			adv.OriginStateID, _ = unsigned32(a)
+		case avp.SupportedVendorID:
+			adv.SupportedVendorID = appendUnsigned32(adv.SupportedVendorID, a)
+		case avp.AuthApplicationID:
+			adv.AuthApplicationID = appendUnsigned32(adv.AuthApplicationID, a)
+		case avp.AcctApplicationID:
+			adv.AcctApplicationID = appendUnsigned32(adv.AcctApplicationID, a)
+		case avp.InbandSecurityID:
+			adv.InbandSecurityID = appendUnsigned32(adv.InbandSecurityID, a)
+		case avp.FirmwareRevision:
+			adv.FirmwareRevision, _ = unsigned32(a)
+		case avp.VendorSpecificApplicationID:
+			g, ok := a.Data.(*diam.GroupedAVP)
+			if !ok {
+				continue
+			}
+			var vs VendorSpecificAppID
+			for _, ga := range g.AVP {
+				switch ga.Code {
+				case avp.VendorID:
+					vs.VendorID, _ = unsigned32(ga)
+				case avp.AuthApplicationID:
+					vs.AuthApplicationID, _ = unsigned32(ga)
+				case avp.AcctApplicationID:
+					vs.AcctApplicationID, _ = unsigned32(ga)
+				}
+			}
+			adv.VendorSpecificApplicationID = append(adv.VendorSpecificApplicationID, vs)
+		}
+	}
+	return adv
+}
+
+func identity(a *diam.AVP) string {
+	if v, ok := a.Data.(datatype.DiameterIdentity); ok {
+		return string(v)
+	}
+	return ""
+}
+
+func unsigned32(a *diam.AVP) (uint32, bool) {
+	switch v := a.Data.(type) {
+	case datatype.Unsigned32:
+		return uint32(v), true
+	case datatype.Enumerated:
+		return uint32(v), true
+	}
+	return 0, false
+}
+
+func appendUnsigned32(s []uint32, a *diam.AVP) []uint32 {
+	if v, ok := unsigned32(a); ok {
+		return append(s, v)
+	}
+	return s
+}
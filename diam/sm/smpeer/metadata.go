@@ -7,6 +7,7 @@ package smpeer
 import (
 	"golang.org/x/net/context"
 
+	"github.com/ibrohimislam/go-diameter/diam"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
 )
@@ -18,26 +19,53 @@ const metadataKey key = 0
 // Metadata contains information about a diameter peer, acquired
 // during the CER/CEA handshake.
 type Metadata struct {
-	OriginHost   datatype.DiameterIdentity
-	OriginRealm  datatype.DiameterIdentity
-	Applications []uint32 // Acct or Auth IDs supported by the peer.
+	OriginHost        datatype.DiameterIdentity
+	OriginRealm       datatype.DiameterIdentity
+	Applications      []uint32 // Acct or Auth IDs negotiated with the peer.
+	VendorID          uint32
+	ProductName       datatype.UTF8String
+	FirmwareRevision  uint32   // Zero if not advertised by the peer.
+	SupportedVendorID []uint32 // Vendors supported by the peer.
+
+	// Capabilities is the snapshot of the capabilities exchange
+	// with the peer, set by the state machine.
+	Capabilities *Capabilities
+}
+
+// Supports returns true if the application id was negotiated with
+// the peer during the handshake, or the peer is a relay.
+func (m *Metadata) Supports(id uint32) bool {
+	for _, app := range m.Applications {
+		if app == id || app == smparser.RelayApplicationID {
+			return true
+		}
+	}
+	return false
 }
 
 // FromCER creates a Metadata object from data in the CER.
 func FromCER(cer *smparser.CER) *Metadata {
 	return &Metadata{
-		OriginHost:   cer.OriginHost,
-		OriginRealm:  cer.OriginRealm,
-		Applications: cer.Applications(),
+		OriginHost:        cer.OriginHost,
+		OriginRealm:       cer.OriginRealm,
+		Applications:      cer.Applications(),
+		VendorID:          cer.VendorID,
+		ProductName:       cer.ProductName,
+		FirmwareRevision:  cer.FirmwareRevision,
+		SupportedVendorID: cer.SupportedVendors(),
 	}
 }
 
 // FromCEA creates a Metadata object from data in the CEA.
 func FromCEA(cea *smparser.CEA) *Metadata {
 	return &Metadata{
-		OriginHost:   cea.OriginHost,
-		OriginRealm:  cea.OriginRealm,
-		Applications: cea.Applications(),
+		OriginHost:        cea.OriginHost,
+		OriginRealm:       cea.OriginRealm,
+		Applications:      cea.Applications(),
+		VendorID:          cea.VendorID,
+		ProductName:       cea.ProductName,
+		FirmwareRevision:  cea.FirmwareRevision,
+		SupportedVendorID: cea.SupportedVendors(),
 	}
 }
 
@@ -51,3 +79,13 @@ func FromContext(ctx context.Context) (*Metadata, bool) {
 	meta, ok := ctx.Value(metadataKey).(*Metadata)
 	return meta, ok
 }
+
+// FromMessage returns the metadata of the peer a message was received
+// from, if the peer had passed the CER/CEA handshake. See diam.Meta.
+func FromMessage(m *diam.Message) (*Metadata, bool) {
+	meta := m.Meta()
+	if meta == nil || meta.Context == nil {
+		return nil, false
+	}
+	return FromContext(meta.Context)
+}
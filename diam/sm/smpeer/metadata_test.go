@@ -9,6 +9,7 @@ import (
 
 	"golang.org/x/net/context"
 
+	"github.com/ibrohimislam/go-diameter/diam"
 	"github.com/ibrohimislam/go-diameter/diam/datatype"
 	"github.com/ibrohimislam/go-diameter/diam/sm/smparser"
 )
@@ -60,3 +61,10 @@ func TestFromCEA(t *testing.T) {
 		t.Fatalf("Unexpected Metadata. Want %#v, have %#v", meta, data)
 	}
 }
+
+func TestFromMessage(t *testing.T) {
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	if _, ok := FromMessage(m); ok {
+		t.Fatal("Unexpected Metadata in a message not read from a connection")
+	}
+}
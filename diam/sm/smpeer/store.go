@@ -0,0 +1,131 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpeer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// The Store interface is implemented by stores of the Metadata of
+// peers, keyed by their Origin-Host, so it can be used for peers that
+// are known from previous handshakes, e.g. after a restart and before
+// the peers reconnect.
+type Store interface {
+	// Load returns the last Metadata saved for the peer.
+	Load(originHost datatype.DiameterIdentity) (*Metadata, bool)
+
+	// Save saves the Metadata of a peer, replacing the previous one.
+	Save(meta *Metadata) error
+}
+
+// MemoryStore is a Store that keeps Metadata in memory. It is safe for
+// concurrent use.
+type MemoryStore struct {
+	mu sync.RWMutex
+	m  map[datatype.DiameterIdentity]*Metadata
+}
+
+// NewMemoryStore creates and initializes a new MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{m: make(map[datatype.DiameterIdentity]*Metadata)}
+}
+
+// Load implements the Store interface.
+func (s *MemoryStore) Load(originHost datatype.DiameterIdentity) (*Metadata, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	meta, ok := s.m[originHost]
+	return meta, ok
+}
+
+// Save implements the Store interface.
+func (s *MemoryStore) Save(meta *Metadata) error {
+	s.mu.Lock()
+	s.m[meta.OriginHost] = meta
+	s.mu.Unlock()
+	return nil
+}
+
+// All returns the Metadata of all peers in the store, ordered by
+// Origin-Host.
+func (s *MemoryStore) All() []*Metadata {
+	s.mu.RLock()
+	all := make([]*Metadata, 0, len(s.m))
+	for _, meta := range s.m {
+		all = append(all, meta)
+	}
+	s.mu.RUnlock()
+	sort.Sort(byOriginHost(all))
+	return all
+}
+
+type byOriginHost []*Metadata
+
+func (s byOriginHost) Len() int           { return len(s) }
+func (s byOriginHost) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byOriginHost) Less(i, j int) bool { return s[i].OriginHost < s[j].OriginHost }
+
+// FileStore is a MemoryStore persisted to a file as a JSON array,
+// which is rewritten on every Save. It is safe for concurrent use.
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex // serializes writes to the file
+}
+
+// NewFileStore creates and initializes a new FileStore with the
+// Metadata saved in the file, if it exists.
+func NewFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var all []*Metadata
+	if err = json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	for _, meta := range all {
+		s.MemoryStore.Save(meta)
+	}
+	return s, nil
+}
+
+// Save implements the Store interface. The file is replaced
+// atomically, so it is not left truncated by crashes.
+func (s *FileStore) Save(meta *Metadata) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.MemoryStore.Save(meta)
+	b, err := json.MarshalIndent(s.All(), "", "\t")
+	if err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path))
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), s.path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,62 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package smpeer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestFileStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smpeer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "peers.json")
+	s, err := NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Load("foobar"); ok {
+		t.Fatal("Unexpected metadata in empty store")
+	}
+	for _, host := range []string{"foobar", "baz"} {
+		meta := &Metadata{
+			OriginHost:        datatype.DiameterIdentity(host),
+			OriginRealm:       "test",
+			Applications:      []uint32{4},
+			SupportedVendorID: []uint32{10415},
+			Capabilities:      &Capabilities{ResultCode: 2001},
+		}
+		if err = s.Save(meta); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err = NewFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all := s.All(); len(all) != 2 || all[0].OriginHost != "baz" {
+		t.Fatalf("Unexpected metadata in the store: %v", all)
+	}
+	meta, ok := s.Load("foobar")
+	if !ok {
+		t.Fatal("Metadata was not persisted")
+	}
+	if !meta.Supports(4) || meta.Supports(5) {
+		t.Fatalf("Unexpected applications: %v", meta.Applications)
+	}
+	if len(meta.SupportedVendorID) != 1 || meta.SupportedVendorID[0] != 10415 {
+		t.Fatalf("Unexpected supported vendors: %v", meta.SupportedVendorID)
+	}
+	if meta.Capabilities == nil || meta.Capabilities.ResultCode != 2001 {
+		t.Fatalf("Unexpected capabilities: %#v", meta.Capabilities)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"errors"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// ErrUnknownPeer is the reason peers are rejected when the peer table
+// of the state machine is set and does not list them.
+var ErrUnknownPeer = errors.New("peer not in the peer table")
+
+// PeerEntry is an entry of the peer table of a state machine, a peer
+// known by configuration, see RFC 6733 section 2.6.
+type PeerEntry struct {
+	// Host is the DiameterIdentity of the peer, its Origin-Host.
+	Host datatype.DiameterIdentity `json:"host"`
+
+	// Limits are the limits applied to the peer, unless the
+	// Authenticator returns others. Optional.
+	Limits *PeerLimits `json:"limits,omitempty"`
+}
+
+// Route is an entry of the routing table of a state machine, see RFC
+// 6733 section 2.7. Requests to Realm, of the application AppID unless
+// zero, are sent to the first of Peers that is available.
+type Route struct {
+	Realm datatype.DiameterIdentity   `json:"realm"`
+	AppID uint32                      `json:"app_id,omitempty"`
+	Peers []datatype.DiameterIdentity `json:"peers"`
+}
+
+// Tables are the peer and routing tables of a state machine, see
+// ReloadTables.
+type Tables struct {
+	// Peers is the peer table. When set, only the peers it lists
+	// pass the handshake, and the others are rejected with
+	// DIAMETER_UNKNOWN_PEER (3010) and ErrUnknownPeer. When unset,
+	// all peers are admitted, see Authenticate.
+	Peers []PeerEntry `json:"peers,omitempty"`
+
+	// Routes is the routing table, see Route. Routes are matched in
+	// order.
+	Routes []Route `json:"routes,omitempty"`
+}
+
+// ReloadTables atomically replaces the peer and routing tables of the
+// state machine.
+//
+// Established peers are not dropped, even when they are removed from
+// the peer table, which only applies to new handshakes. Use
+// Server.DrainPeer to disconnect them.
+//
+// The Tables object must not be modified after it is passed to
+// ReloadTables. Create a new one instead.
+func (sm *StateMachine) ReloadTables(t *Tables) {
+	sm.tables.Store(t)
+}
+
+// Tables returns the peer and routing tables of the state machine, or
+// an empty Tables if unset. They must not be modified, see
+// ReloadTables.
+func (sm *StateMachine) Tables() *Tables {
+	if t, ok := sm.tables.Load().(*Tables); ok && t != nil {
+		return t
+	}
+	return &Tables{}
+}
+
+// peerEntry returns the entry of the peer table for the peer host,
+// nil if the table is unset, or ErrUnknownPeer if it does not list
+// the peer.
+func (sm *StateMachine) peerEntry(host datatype.DiameterIdentity) (*PeerEntry, error) {
+	t := sm.Tables()
+	if len(t.Peers) == 0 {
+		return nil, nil
+	}
+	for i := range t.Peers {
+		if t.Peers[i].Host == host {
+			return &t.Peers[i], nil
+		}
+	}
+	return nil, ErrUnknownPeer
+}
+
+// Route returns the peer to send requests of the application appID to
+// the realm, according to the routing table: the first peer of the
+// first matching Route that passed the handshake, supports appID and
+// is not draining, or nil if there is none. When it has several
+// connections, the one with the fewest outstanding requests is used.
+//
+// Without a matching Route, Route returns PeerFor(realm, appID).
+func (sm *StateMachine) Route(realm datatype.DiameterIdentity, appID uint32) *Peer {
+	for _, r := range sm.Tables().Routes {
+		if r.Realm != realm || (r.AppID != 0 && r.AppID != appID) {
+			continue
+		}
+		peers := sm.Peers()
+		for _, host := range r.Peers {
+			var best *Peer
+			for _, p := range peers {
+				if p.Metadata.OriginHost != host || !p.Metadata.Supports(appID) {
+					continue
+				}
+				if best == nil || p.Outstanding() < best.Outstanding() {
+					best = p
+				}
+			}
+			if best != nil {
+				return best
+			}
+		}
+		return nil
+	}
+	return sm.PeerFor(realm, appID)
+}
@@ -0,0 +1,71 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package sm
+
+import (
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+	"github.com/ibrohimislam/go-diameter/diam/dict"
+	"github.com/ibrohimislam/go-diameter/diam/sm/smpeer"
+)
+
+func TestStateMachine_ReloadTables(t *testing.T) {
+	sm := New(serverSettings)
+	sm.ReloadTables(&Tables{Peers: []PeerEntry{{Host: "other"}}})
+	srv := diamtest.NewServer(sm, dict.Default)
+	defer srv.Close()
+	events := sm.Events().Subscribe(10)
+
+	// Peers missing from the peer table are rejected.
+	if _, err := authClient(clientSettings.OriginHost).Dial(srv.Addr); err == nil {
+		t.Fatal("Unexpected handshake success")
+	}
+	if e := waitEvent(t, events, HandshakeFailed); e.Error != ErrUnknownPeer {
+		t.Fatalf("Unexpected error. Want %v, have %v", ErrUnknownPeer, e.Error)
+	}
+
+	// Peers added to the table are admitted, with their limits.
+	sm.ReloadTables(&Tables{Peers: []PeerEntry{
+		{Host: "other"},
+		{Host: clientSettings.OriginHost, Limits: &PeerLimits{Applications: []uint32{1002}}},
+	}})
+	c, err := authClient(clientSettings.OriginHost).Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	waitEvent(t, events, PeerUp)
+	meta, _ := smpeer.FromContext(c.Context())
+	if len(meta.Applications) != 1 || meta.Applications[0] != 1002 {
+		t.Fatalf("Unexpected applications in CEA: %v", meta.Applications)
+	}
+
+	// Established peers are kept when removed from the table.
+	sm.ReloadTables(&Tables{
+		Peers: []PeerEntry{{Host: "other"}},
+		Routes: []Route{
+			{Realm: "test", AppID: 1001, Peers: []datatype.DiameterIdentity{"other"}},
+			{Realm: "test", Peers: []datatype.DiameterIdentity{"other", clientSettings.OriginHost}},
+		},
+	})
+	peers := sm.Peers()
+	if len(peers) != 1 {
+		t.Fatalf("Unexpected number of peers. Want 1, have %d", len(peers))
+	}
+	if p := sm.Route("test", 1002); p != peers[0] {
+		t.Fatalf("Unexpected route to app 1002. Want %v, have %v", peers[0], p)
+	}
+	if p := sm.Route("test", 1001); p != nil {
+		t.Fatalf("Unexpected route to app 1001: %v", p)
+	}
+	if p := sm.Route("other.realm", 1002); p != nil {
+		t.Fatalf("Unexpected route to other.realm: %v", p)
+	}
+	if cfg := sm.Config(); len(cfg.Tables.Routes) != 2 {
+		t.Fatalf("Unexpected tables in config: %#v", cfg.Tables)
+	}
+}
@@ -0,0 +1,15 @@
+#!/bin/sh
+# Generates the TLS credentials required by freeDiameter, even when TLS
+# is not used, fills in the ports and starts the daemon in foreground.
+set -e
+
+cd /etc/freeDiameter
+openssl req -x509 -newkey rsa:2048 -nodes -days 1 \
+    -subj "/CN=fd.example.com" -keyout key.pem -out cert.pem 2>/dev/null
+
+sed -i \
+    -e "s/@FD_PORT@/${FD_PORT:-3868}/" \
+    -e "s/@GO_PEER_PORT@/${GO_PEER_PORT:-3869}/" \
+    freeDiameter.conf
+
+exec freeDiameterd -c /etc/freeDiameter/freeDiameter.conf
@@ -0,0 +1,95 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// ConnStats are the statistics of a connection, returned by Conn.Stats,
+// e.g. for per-peer dashboards or to decide when a peer is drained.
+type ConnStats struct {
+	BytesIn      uint64    // Bytes of the messages read
+	BytesOut     uint64    // Bytes of the messages written
+	MessagesIn   uint64    // Messages read, including the ones that failed to decode
+	MessagesOut  uint64    // Messages written
+	LastRead     time.Time // Time the last message was read, or zero
+	LastWrite    time.Time // Time the last message was written, or zero
+	DecodeErrors uint64    // Messages read that failed to decode
+
+	// Outstanding is the number of requests written to the
+	// connection whose answers were not read yet, matched by
+	// Hop-by-Hop Identifier. It is not tracked for connections
+	// with a Codec other than the default.
+	Outstanding int
+}
+
+// Stats returns the statistics of the connection.
+func (w *response) Stats() ConnStats {
+	return w.conn.connStats()
+}
+
+func (c *conn) connStats() ConnStats {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	s := c.cstats
+	s.BytesIn = c.stats.Bytes
+	s.MessagesIn = c.stats.Messages
+	s.Outstanding = len(c.pending)
+	return s
+}
+
+// countWrite updates the statistics after writing the serialized
+// messages in b, and tracks the requests among them until answered.
+// Messages of codecs other than the default are counted as one message,
+// and requests are not tracked.
+func (c *conn) countWrite(b []byte) {
+	now := time.Now()
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+	c.cstats.LastWrite = now
+	if c.server.Codec != nil {
+		// Messages of other codecs can't be parsed.
+		c.cstats.MessagesOut++
+		c.cstats.BytesOut += uint64(len(b))
+		return
+	}
+	for len(b) >= HeaderLength {
+		l := int(uint24to32(b[1:4]))
+		if l < HeaderLength || l > len(b) {
+			break
+		}
+		c.cstats.MessagesOut++
+		c.cstats.BytesOut += uint64(l)
+		if b[4]&RequestFlag != 0 {
+			if c.pending == nil {
+				c.pending = make(map[uint32]struct{})
+			}
+			c.pending[binary.BigEndian.Uint32(b[12:16])] = struct{}{}
+		}
+		b = b[l:]
+	}
+}
+
+// countAnswer stops tracking the request answered by the answer
+// with header h.
+func (c *conn) countAnswer(h *Header) {
+	if h.CommandFlags&RequestFlag != 0 {
+		return
+	}
+	c.statsMu.Lock()
+	delete(c.pending, h.HopByHopID)
+	c.wakeWriters()
+	c.statsMu.Unlock()
+}
+
+// countDecodeError updates the statistics after reading a message that
+// failed to decode.
+func (c *conn) countDecodeError() {
+	c.statsMu.Lock()
+	c.cstats.DecodeErrors++
+	c.statsMu.Unlock()
+}
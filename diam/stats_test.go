@@ -0,0 +1,71 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func TestConn_Stats(t *testing.T) {
+	smux := diam.NewServeMux()
+	smux.HandleFunc("DWR", func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})
+	smux.HandleFunc("CCR", func(c diam.Conn, m *diam.Message) {
+		// Never answered.
+	})
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("DWA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	if s := cli.Stats(); s.MessagesOut != 0 || !s.LastWrite.IsZero() {
+		t.Fatalf("Unexpected stats of new connection: %+v", s)
+	}
+	ccr := diam.NewRequest(diam.CreditControl, 4, nil)
+	ccr.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	if _, err = ccr.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	dwr := diam.NewRequest(diam.DeviceWatchdog, 0, nil)
+	dwr.NewAVP(avp.OriginHost, avp.Mbit, 0, datatype.DiameterIdentity("cli"))
+	if _, err = dwr.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-mc:
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for DWA")
+	}
+	s := cli.Stats()
+	want := diam.ConnStats{
+		BytesOut:    uint64(ccr.Len() + dwr.Len()),
+		MessagesOut: 2,
+		MessagesIn:  1,
+		BytesIn:     s.BytesIn,
+		Outstanding: 1,
+	}
+	if s.BytesIn == 0 || s.LastRead.IsZero() || s.LastWrite.IsZero() {
+		t.Fatalf("Unexpected stats: %+v", s)
+	}
+	s.LastRead, s.LastWrite = time.Time{}, time.Time{}
+	if s != want {
+		t.Fatalf("Unexpected stats. Want %+v, have %+v", want, s)
+	}
+}
@@ -0,0 +1,291 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// The AVPTransformer interface is implemented by transforms of the
+// data of AVPs, like encryption of proprietary AVPs or masking of
+// subscriber identities, see AVPTransforms.
+type AVPTransformer interface {
+	// Encode returns the data of the AVP a of a message written
+	// to the connection c.
+	Encode(c Conn, a *AVP) (datatype.Type, error)
+
+	// Decode returns the data of the AVP a of a message read
+	// from the connection c.
+	Decode(c Conn, a *AVP) (datatype.Type, error)
+}
+
+// AVPTransformError is returned when an AVPTransformer fails.
+type AVPTransformError struct {
+	Code     uint32 // Code of the AVP
+	VendorID uint32 // Vendor id of the AVP
+	Err      error  // Error of the transformer
+}
+
+// Error implements the error interface.
+func (e *AVPTransformError) Error() string {
+	return fmt.Sprintf("failed to transform AVP %d (vendor %d): %s",
+		e.Code, e.VendorID, e.Err)
+}
+
+type transformKey struct {
+	code     uint32
+	vendorID uint32
+}
+
+// AVPTransforms applies AVPTransformers to the AVPs of the messages
+// sent and received by handlers, including the AVPs embedded in grouped
+// AVPs, so handlers deal with plain data only.
+//
+// Transformed AVPs, and the grouped AVPs that contain them, are copies:
+// AVPs shared with other messages are not modified.
+//
+// Example:
+//
+//	t := &diam.AVPTransforms{Reporter: mux}
+//	t.Register(9001, 5535, diam.CipherTransformer(aead))
+//	mux.HandleEgress(t.Egress)
+//	mux.Handle("CCR", t.Handler(handleCCR))
+type AVPTransforms struct {
+	Reporter ErrorReporter // Receives the errors of incoming messages, optional
+
+	mu sync.RWMutex
+	m  map[transformKey]AVPTransformer
+}
+
+// Register registers the transformer for the AVPs with the given code
+// and vendor id, replacing any previous one.
+func (t *AVPTransforms) Register(code, vendorID uint32, tr AVPTransformer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.m == nil {
+		t.m = make(map[transformKey]AVPTransformer)
+	}
+	t.m[transformKey{code, vendorID}] = tr
+}
+
+// Egress is an EgressFunc that encodes the AVPs of outgoing messages.
+func (t *AVPTransforms) Egress(c Conn, m *Message) error {
+	return t.apply(c, m, true)
+}
+
+// Decode decodes the AVPs of the message m read from the connection c.
+// It is called by the handlers returned by Handler.
+func (t *AVPTransforms) Decode(c Conn, m *Message) error {
+	return t.apply(c, m, false)
+}
+
+// Handler returns a Handler that decodes the AVPs of incoming messages
+// before calling h. Messages that fail to decode are reported to the
+// Reporter of t: requests are answered with DIAMETER_INVALID_AVP_VALUE
+// (5004) and answers are dropped.
+func (t *AVPTransforms) Handler(h Handler) Handler {
+	return HandlerFunc(func(c Conn, m *Message) {
+		if err := t.Decode(c, m); err != nil {
+			t.report(c, m, err)
+			if isRequest(m) {
+				a := m.Answer(InvalidAVPValue)
+				if _, err := a.WriteTo(c); err != nil {
+					t.report(c, m, err)
+				}
+			}
+			return
+		}
+		h.ServeDIAM(c, m)
+	})
+}
+
+func (t *AVPTransforms) report(c Conn, m *Message, err error) {
+	if t.Reporter != nil {
+		t.Reporter.Error(&ErrorReport{Conn: c, Message: m, Error: err})
+	}
+}
+
+// apply encodes or decodes the AVPs of m.
+func (t *AVPTransforms) apply(c Conn, m *Message, encode bool) error {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.m) == 0 {
+		return nil
+	}
+	if err := m.DecodeAll(); err != nil {
+		return err
+	}
+	avps, ok, err := t.transformAVPs(c, m.AVP, encode)
+	if err != nil {
+		return err
+	}
+	if ok {
+		m.AVP = avps
+		m.Header.MessageLength = uint32(m.Len())
+	}
+	return nil
+}
+
+// transformAVPs returns the transformation of avps, and false if none
+// of them was transformed.
+func (t *AVPTransforms) transformAVPs(c Conn, avps []*AVP, encode bool) ([]*AVP, bool, error) {
+	var r []*AVP
+	for i, a := range avps {
+		ta, err := t.transform(c, a, encode)
+		if err != nil {
+			return nil, false, err
+		}
+		if ta == a {
+			if r != nil {
+				r = append(r, a)
+			}
+			continue
+		}
+		if r == nil {
+			r = make([]*AVP, i, len(avps))
+			copy(r, avps[:i])
+		}
+		r = append(r, ta)
+	}
+	return r, r != nil, nil
+}
+
+// transform returns the transformation of a, or a itself when unchanged.
+func (t *AVPTransforms) transform(c Conn, a *AVP, encode bool) (*AVP, error) {
+	data := a.Data
+	if g, ok := a.Data.(*GroupedAVP); ok {
+		avps, ok, err := t.transformAVPs(c, g.AVP, encode)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			data = &GroupedAVP{AVP: avps}
+		}
+	}
+	tr, ok := t.m[transformKey{a.Code, a.VendorID}]
+	if ok {
+		var err error
+		in := a
+		if data != a.Data {
+			in = NewAVP(a.Code, a.Flags, a.VendorID, data)
+		}
+		if encode {
+			data, err = tr.Encode(c, in)
+		} else {
+			data, err = tr.Decode(c, in)
+		}
+		if err != nil {
+			return nil, &AVPTransformError{
+				Code:     a.Code,
+				VendorID: a.VendorID,
+				Err:      err,
+			}
+		}
+	}
+	if data == a.Data {
+		return a, nil
+	}
+	return NewAVP(a.Code, a.Flags, a.VendorID, data), nil
+}
+
+// withPayload returns data of the same type as orig, decoded from b.
+func withPayload(orig datatype.Type, b []byte) (datatype.Type, error) {
+	if r, ok := orig.(datatype.Raw); ok {
+		return datatype.Raw{Flags: r.Flags, Payload: b}, nil
+	}
+	return datatype.Decode(orig.Type(), b)
+}
+
+type maskTransformer struct {
+	keep int
+}
+
+// MaskTransformer returns an AVPTransformer that replaces all but the
+// last keep bytes of the data of outgoing AVPs with '*', e.g. to hide
+// the MSISDN or IMSI of subscribers in messages sent to trace or
+// mirror peers. The data of incoming AVPs is not modified.
+//
+// It is meant for AVPs of type OctetString and UTF8String.
+func MaskTransformer(keep int) AVPTransformer {
+	return &maskTransformer{keep: keep}
+}
+
+// Encode implements the AVPTransformer interface.
+func (t *maskTransformer) Encode(c Conn, a *AVP) (datatype.Type, error) {
+	b := a.Data.Serialize()
+	n := len(b) - t.keep
+	if n <= 0 {
+		return a.Data, nil
+	}
+	masked := make([]byte, len(b))
+	copy(masked, bytes.Repeat([]byte{'*'}, n))
+	copy(masked[n:], b[n:])
+	return withPayload(a.Data, masked)
+}
+
+// Decode implements the AVPTransformer interface.
+func (t *maskTransformer) Decode(c Conn, a *AVP) (datatype.Type, error) {
+	return a.Data, nil
+}
+
+type cipherTransformer struct {
+	aead cipher.AEAD
+}
+
+// CipherTransformer returns an AVPTransformer that encrypts the data
+// of outgoing AVPs and decrypts the data of incoming AVPs with the
+// given AEAD, e.g. AES-GCM with a key shared by the peers.
+//
+// Encrypted data is the random nonce followed by the sealed data, and
+// the code and vendor id of the AVP are authenticated with it. The AVPs
+// must be of type OctetString in the dictionaries of both peers, and
+// are decrypted as such.
+func CipherTransformer(aead cipher.AEAD) AVPTransformer {
+	return &cipherTransformer{aead: aead}
+}
+
+// Encode implements the AVPTransformer interface.
+func (t *cipherTransformer) Encode(c Conn, a *AVP) (datatype.Type, error) {
+	nonce := make([]byte, t.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	b := t.aead.Seal(nonce, nonce, a.Data.Serialize(), cipherAD(a))
+	if _, ok := a.Data.(datatype.Raw); ok {
+		return withPayload(a.Data, b)
+	}
+	return datatype.OctetString(b), nil
+}
+
+// Decode implements the AVPTransformer interface.
+func (t *cipherTransformer) Decode(c Conn, a *AVP) (datatype.Type, error) {
+	b := a.Data.Serialize()
+	n := t.aead.NonceSize()
+	if len(b) < n+t.aead.Overhead() {
+		return nil, errors.New("encrypted data too short")
+	}
+	p, err := t.aead.Open(nil, b[:n], b[n:], cipherAD(a))
+	if err != nil {
+		return nil, err
+	}
+	return withPayload(a.Data, p)
+}
+
+// cipherAD returns the additional data authenticated with the AVP a.
+func cipherAD(a *AVP) []byte {
+	ad := make([]byte, 8)
+	binary.BigEndian.PutUint32(ad[0:4], a.Code)
+	binary.BigEndian.PutUint32(ad[4:8], a.VendorID)
+	return ad
+}
@@ -0,0 +1,150 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam_test
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+	"time"
+
+	"github.com/ibrohimislam/go-diameter/diam"
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+	"github.com/ibrohimislam/go-diameter/diam/diamtest"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	block, err := aes.NewCipher([]byte("0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestAVPTransforms_Cipher(t *testing.T) {
+	tr := &diam.AVPTransforms{}
+	tr.Register(avp.Class, 0, diam.CipherTransformer(testAEAD(t)))
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	class, _ := m.NewAVP(avp.Class, avp.Mbit, 0, datatype.OctetString("secret"))
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &diam.GroupedAVP{
+		AVP: []*diam.AVP{
+			diam.NewAVP(avp.Class, avp.Mbit, 0, datatype.OctetString("nested")),
+		},
+	})
+	if err := tr.Egress(nil, m); err != nil {
+		t.Fatal(err)
+	}
+	if class.Data.(datatype.OctetString) != "secret" {
+		t.Fatal("Original AVP was modified")
+	}
+	a, err := m.FindAVP(avp.Class, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Data.(datatype.OctetString) == "secret" {
+		t.Fatal("AVP was not encrypted")
+	}
+	if m.Header.MessageLength != uint32(m.Len()) {
+		t.Fatalf("Unexpected message length %d, want %d", m.Header.MessageLength, m.Len())
+	}
+	// Decode a copy read from the wire.
+	b, err := m.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err = diam.ReadMessage(bytes.NewReader(b), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.Decode(nil, m); err != nil {
+		t.Fatal(err)
+	}
+	avps, err := m.FindAVPs(avp.Class, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(avps) != 2 {
+		t.Fatalf("Unexpected number of Class AVPs: %d", len(avps))
+	}
+	for i, want := range []datatype.OctetString{"secret", "nested"} {
+		if v := avps[i].Data.(datatype.OctetString); v != want {
+			t.Fatalf("Unexpected Class %d. Want %q, have %q", i, want, v)
+		}
+	}
+}
+
+func TestAVPTransforms_Mask(t *testing.T) {
+	tr := &diam.AVPTransforms{}
+	tr.Register(avp.UserName, 0, diam.MaskTransformer(4))
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String("001010123456789"))
+	if err := tr.Egress(nil, m); err != nil {
+		t.Fatal(err)
+	}
+	a, err := m.FindAVP(avp.UserName, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := datatype.UTF8String("***********6789")
+	if v := a.Data.(datatype.UTF8String); v != want {
+		t.Fatalf("Unexpected User-Name. Want %q, have %q", want, v)
+	}
+}
+
+func TestAVPTransforms_HandlerError(t *testing.T) {
+	tr := &diam.AVPTransforms{}
+	tr.Register(avp.Class, 0, diam.CipherTransformer(testAEAD(t)))
+	smux := diam.NewServeMux()
+	tr.Reporter = smux
+	smux.Handle("CCR", tr.Handler(diam.HandlerFunc(func(c diam.Conn, m *diam.Message) {
+		m.Answer(diam.Success).WriteTo(c)
+	})))
+	srv := diamtest.NewServer(smux, nil)
+	defer srv.Close()
+
+	mc := make(chan *diam.Message, 1)
+	cmux := diam.NewServeMux()
+	cmux.HandleFunc("CCA", func(c diam.Conn, m *diam.Message) {
+		mc <- m
+	})
+	cli, err := diam.Dial(srv.Addr, cmux, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+	m := diam.NewRequest(diam.CreditControl, 4, nil)
+	m.NewAVP(avp.SessionID, avp.Mbit, 0, datatype.UTF8String("cli;1"))
+	m.NewAVP(avp.Class, avp.Mbit, 0, datatype.OctetString("not encrypted"))
+	if _, err = m.WriteTo(cli); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case a := <-mc:
+		rc, err := a.FindAVP(avp.ResultCode, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v := rc.Data.(datatype.Unsigned32); v != diam.InvalidAVPValue {
+			t.Fatalf("Unexpected Result-Code. Want %d, have %d", diam.InvalidAVPValue, v)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for CCA")
+	}
+	select {
+	case err := <-smux.ErrorReports():
+		if _, ok := err.Error.(*diam.AVPTransformError); !ok {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for error report")
+	}
+}
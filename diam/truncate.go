@@ -0,0 +1,54 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+// MaxValueLen is the maximum length of the values printed by AVP.String
+// and Message.String. Longer values, e.g. EAP payloads or User-Data
+// XML, are cut to their first MaxValueLen bytes, or less to keep
+// text on a rune boundary, and annotated with their length, to keep
+// log lines short. Zero means no limit.
+//
+// Values of grouped AVPs are not truncated, their embedded AVPs are.
+// Message.MarshalJSON always exports the full values.
+var MaxValueLen int
+
+// value returns the data of the AVP a for printing, truncated to
+// MaxValueLen.
+func (a *AVP) value() interface{} {
+	if MaxValueLen <= 0 || a.Data == nil || a.Data.Type() == GroupedAVPType || a.Data.Len() <= MaxValueLen {
+		return a.Data
+	}
+	return fmt.Sprintf("%s{%s},Truncated:%d bytes", typeName(a.Data), cutValue(a.Data, MaxValueLen), a.Data.Len())
+}
+
+// cutValue returns the first n bytes of data for printing, followed by
+// an ellipsis. Text is cut on a rune boundary, other data is printed
+// in hex.
+func cutValue(data datatype.Type, n int) string {
+	b := data.Serialize()
+	if n > len(b) {
+		n = len(b)
+	}
+	if !isText(data) {
+		return fmt.Sprintf("%#x...", b[:n])
+	}
+	for n > 0 && n < len(b) && !utf8.RuneStart(b[n]) {
+		n--
+	}
+	return fmt.Sprintf("%s...", b[:n])
+}
+
+// typeName returns the name of the data type of data, e.g. OctetString.
+func typeName(data datatype.Type) string {
+	return strings.TrimPrefix(fmt.Sprintf("%T", data), "datatype.")
+}
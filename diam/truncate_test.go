@@ -0,0 +1,48 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ibrohimislam/go-diameter/diam/avp"
+	"github.com/ibrohimislam/go-diameter/diam/datatype"
+)
+
+func TestAVP_StringTruncated(t *testing.T) {
+	defer func(n int) { MaxValueLen = n }(MaxValueLen)
+	MaxValueLen = 4
+	state := NewAVP(avp.ProxyState, avp.Mbit, 0, datatype.OctetString(strings.Repeat("\x01", 4096)))
+	want := "Value:OctetString{0x01010101...},Truncated:4096 bytes}"
+	if s := state.String(); !strings.HasSuffix(s, want) {
+		t.Fatalf("Unexpected string. Want suffix %q, have %q", want, s)
+	}
+	name := NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String("alice@example.com"))
+	want = "Value:UTF8String{alic...},Truncated:17 bytes}"
+	if s := name.String(); !strings.HasSuffix(s, want) {
+		t.Fatalf("Unexpected string. Want suffix %q, have %q", want, s)
+	}
+	short := NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String("bob"))
+	if s := short.String(); strings.Contains(s, "Truncated") {
+		t.Fatalf("Unexpected truncated value: %q", s)
+	}
+	m := NewRequest(CreditControl, 4, nil)
+	m.NewAVP(avp.SubscriptionID, avp.Mbit, 0, &GroupedAVP{AVP: []*AVP{name}})
+	if s := m.String(); !strings.Contains(s, "UTF8String{alic...},Truncated:17 bytes") {
+		t.Fatalf("Embedded AVP was not truncated: %s", s)
+	}
+}
+
+func TestAVP_StringTruncatedRune(t *testing.T) {
+	defer func(n int) { MaxValueLen = n }(MaxValueLen)
+	MaxValueLen = 4
+	// The 4th byte is in the middle of "é".
+	name := NewAVP(avp.UserName, avp.Mbit, 0, datatype.UTF8String("josé@example.com"))
+	want := "Value:UTF8String{jos...},Truncated:17 bytes}"
+	if s := name.String(); !strings.HasSuffix(s, want) {
+		t.Fatalf("Unexpected string. Want suffix %q, have %q", want, s)
+	}
+}
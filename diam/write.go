@@ -0,0 +1,210 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// WriteError is the error returned when a message could not be written
+// to a connection, e.g. because the peer stalled past the write
+// deadline, the context of WriteToContext expired, or the transport
+// failed.
+//
+// Messages partially written break the framing of the stream, so the
+// connection is closed with the WriteError. Otherwise the connection
+// is still usable, and the message may be retried on it or failed
+// over to another peer.
+type WriteError struct {
+	Len     int   // Length of the message
+	Written int   // Bytes written before the error
+	Closed  bool  // The connection was closed because of the error
+	Err     error // Error of the transport, or of the context
+}
+
+func (e *WriteError) Error() string {
+	if e.Written > 0 {
+		return fmt.Sprintf("diam: short write of %d of %d bytes: %v", e.Written, e.Len, e.Err)
+	}
+	return fmt.Sprintf("diam: write failed: %v", e.Err)
+}
+
+// Partial returns true if part of the message was written.
+func (e *WriteError) Partial() bool {
+	return e.Written > 0
+}
+
+// Timeout returns true if the write timed out or its context deadline
+// was exceeded.
+func (e *WriteError) Timeout() bool {
+	if e.Err == context.DeadlineExceeded {
+		return true
+	}
+	ne, ok := e.Err.(net.Error)
+	return ok && ne.Timeout()
+}
+
+// Temporary returns true if the connection is still usable.
+func (e *WriteError) Temporary() bool {
+	return !e.Closed
+}
+
+// contextWriter is implemented by connections that support
+// WriteToContext.
+type contextWriter interface {
+	writeContext(ctx context.Context, b []byte) (int, error)
+}
+
+// WriteToContext is like WriteTo, but the write is abandoned when the
+// context is done, in addition to the WriteTimeout of the Server.
+//
+// Failed writes return a *WriteError. Writers other than connections
+// of this package only have the context checked before writing.
+func (m *Message) WriteToContext(ctx context.Context, writer io.Writer) (int64, error) {
+	cw, ok := writer.(contextWriter)
+	if !ok {
+		if err := ctx.Err(); err != nil {
+			return 0, &WriteError{Len: m.Len(), Err: err}
+		}
+		return m.WriteTo(writer)
+	}
+	if ew, ok := writer.(egressWriter); ok {
+		if err := ew.egress(m); err != nil {
+			return 0, err
+		}
+	}
+	if b, err := encode(writer, m); b != nil || err != nil {
+		if err != nil {
+			return 0, err
+		}
+		n, err := cw.writeContext(ctx, b)
+		return int64(n), err
+	}
+	l := m.Len()
+	buf := newWriterBuffer(l)
+	defer putWriterBuffer(buf)
+	b := buf.Bytes()[0:l]
+	if err := m.SerializeTo(b); err != nil {
+		return 0, err
+	}
+	n, err := cw.writeContext(ctx, b)
+	return int64(n), err
+}
+
+// aLongTimeAgo is a deadline in the past, that interrupts writes.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// writeContext writes b to the connection until the context is done or
+// the WriteTimeout of the server expires. Writes of requests are
+// limited by the FlowControl of the server, and writes are scheduled by
+// its WriteLanes, if any. Frames are passed to the Intercept func of
+// the server first, if any.
+func (w *response) writeContext(ctx context.Context, b []byte) (int, error) {
+	if f := w.conn.server.Intercept; f != nil {
+		var err error
+		if b, err = f(w, b); err != nil || b == nil {
+			return 0, err
+		}
+	}
+	reserved, err := w.conn.acquire(ctx, b)
+	if err != nil {
+		return 0, err
+	}
+	defer w.conn.release(reserved)
+	if s := w.conn.lanes; s != nil {
+		return s.writeContext(ctx, b)
+	}
+	return w.writeNow(ctx, b)
+}
+
+// writeNow is like writeContext, without scheduling.
+func (w *response) writeNow(ctx context.Context, b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	c := w.conn
+	if err := ctx.Err(); err != nil {
+		return 0, &WriteError{Len: len(b), Err: err}
+	}
+	deadline, _ := ctx.Deadline()
+	if t := c.server.WriteTimeout; t > 0 {
+		if d := time.Now().Add(t); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	// Always set, to clear the deadline of previous writes.
+	c.rwc.SetWriteDeadline(deadline)
+	// The goroutine interrupting the write on cancelation must exit
+	// before w.mu is released, and its deadline must not outlive
+	// the write.
+	var (
+		wg       sync.WaitGroup
+		canceled bool
+	)
+	stop := make(chan struct{})
+	if done := ctx.Done(); done != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case <-done:
+				canceled = true
+				c.rwc.SetWriteDeadline(aLongTimeAgo)
+			case <-stop:
+			}
+		}()
+	}
+	n, err := c.write(b)
+	close(stop)
+	wg.Wait()
+	if canceled {
+		c.rwc.SetWriteDeadline(time.Time{})
+	}
+	if err == nil {
+		c.countWrite(b)
+		return n, nil
+	}
+	if cerr := ctx.Err(); cerr != nil {
+		err = cerr
+	}
+	werr := &WriteError{Len: len(b), Written: n, Err: err}
+	// TLS and DTLS connections can't be written after a failed write.
+	if n > 0 || c.secure != nil || !isTimeout(err) {
+		werr.Closed = true
+		c.closeWithError(werr)
+	}
+	return n, werr
+}
+
+// write writes b to the connection, handling short writes.
+func (c *conn) write(b []byte) (int, error) {
+	n := 0
+	for n < len(b) {
+		nw, err := c.rwc.Write(b[n:])
+		n += nw
+		if err != nil {
+			return n, err
+		}
+		if nw == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// isTimeout returns true if err is a timeout, or the error of a done
+// context, after which the connection is still usable.
+func isTimeout(err error) bool {
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		return true
+	}
+	ne, ok := err.(net.Error)
+	return ok && ne.Timeout()
+}
@@ -0,0 +1,125 @@
+// Copyright 2013-2015 go-diameter authors. All rights reserved.
+// Use of this source code is governed by a BSD-style license that can be
+// found in the LICENSE file.
+
+package diam
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// shortConn is a net.Conn that writes at most max bytes per Write, and
+// fails after limit bytes if limit > 0.
+type shortConn struct {
+	net.Conn
+	buf   bytes.Buffer
+	max   int
+	limit int
+}
+
+var errBroken = errors.New("broken")
+
+func (c *shortConn) Write(b []byte) (int, error) {
+	if c.limit > 0 && c.buf.Len() >= c.limit {
+		return 0, errBroken
+	}
+	if len(b) > c.max {
+		b = b[:c.max]
+	}
+	return c.buf.Write(b)
+}
+
+func (c *shortConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *shortConn) Close() error                     { return nil }
+
+func TestConn_ShortWrites(t *testing.T) {
+	rwc := &shortConn{max: 3}
+	c, _ := (&Server{}).newConn(rwc)
+	b := []byte("0123456789")
+	n, err := c.writer.Write(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(b) || !bytes.Equal(rwc.buf.Bytes(), b) {
+		t.Fatalf("Unexpected write. Want %q, have %q", b, rwc.buf.Bytes())
+	}
+	rwc = &shortConn{max: 0}
+	c, _ = (&Server{}).newConn(rwc)
+	_, err = c.writer.Write(b)
+	werr, ok := err.(*WriteError)
+	if !ok || werr.Err != io.ErrShortWrite {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestConn_PartialWrite(t *testing.T) {
+	rwc := &shortConn{max: 3, limit: 6}
+	c, _ := (&Server{}).newConn(rwc)
+	n, err := c.writer.Write([]byte("0123456789"))
+	werr, ok := err.(*WriteError)
+	if !ok {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if n != 6 || werr.Written != 6 || werr.Len != 10 || !werr.Partial() {
+		t.Fatalf("Unexpected write error: %#v", werr)
+	}
+	if !werr.Closed || werr.Temporary() || werr.Err != errBroken {
+		t.Fatalf("Unexpected write error: %#v", werr)
+	}
+	select {
+	case <-c.done:
+	default:
+		t.Fatal("Connection was not closed after a partial write")
+	}
+	if c.writer.Err() != werr {
+		t.Fatalf("Unexpected close reason. Want %v, have %v", werr, c.writer.Err())
+	}
+}
+
+// cancelConn is a net.Conn whose Write cancels the context of the
+// write, and returns once the write is interrupted.
+type cancelConn struct {
+	shortConn
+	cancel    context.CancelFunc
+	mu        sync.Mutex
+	deadline  time.Time
+	interrupt chan struct{}
+}
+
+func (c *cancelConn) Write(b []byte) (int, error) {
+	c.cancel()
+	<-c.interrupt
+	return len(b), nil
+}
+
+func (c *cancelConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	c.deadline = t
+	c.mu.Unlock()
+	if t.Equal(aLongTimeAgo) {
+		close(c.interrupt)
+	}
+	return nil
+}
+
+func TestConn_WriteCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rwc := &cancelConn{cancel: cancel, interrupt: make(chan struct{})}
+	c, _ := (&Server{}).newConn(rwc)
+	if _, err := c.writer.writeNow(ctx, []byte("0123456789")); err != nil {
+		t.Fatal(err)
+	}
+	rwc.mu.Lock()
+	defer rwc.mu.Unlock()
+	if !rwc.deadline.IsZero() {
+		t.Fatalf("Write deadline left set to %s", rwc.deadline)
+	}
+}
@@ -149,8 +149,8 @@ func main() {
 		}
 		log.Println("Client connected, handshake ok")
 		backoff = 1
-		<-c.(diam.CloseNotifier).CloseNotify()
-		log.Println("Client disconnected")
+		<-c.Done()
+		log.Println("Client disconnected:", c.Err())
 	}
 }
 
@@ -127,7 +127,7 @@ func Pump(src, dst diam.Conn, srcChan, dstChan chan *diam.Message) {
 			if _, err := m.WriteTo(src); err != nil {
 				src.Close() // triggers the case below
 			}
-		case <-src.(diam.CloseNotifier).CloseNotify():
+		case <-src.Done():
 			liveMu.Lock()
 			defer liveMu.Unlock()
 			if _, ok := liveBridge[src.RemoteAddr().String()]; ok {